@@ -0,0 +1,102 @@
+// Code generated by go run ./cmd/genclient. DO NOT EDIT.
+
+import { BaseClient } from './runtime';
+import type { Paginated } from './runtime';
+import type {
+  BulkDeleteRequest,
+  BulkDeleteResponse,
+  File,
+  Team,
+  TeamInvite,
+  Template,
+} from './types';
+
+export class Be0Client extends BaseClient {
+  listFiles(query: { page?: number; limit?: number; sort?: string; order?: string; q?: string; include?: string; exclude?: string; fields?: string; ids?: string } = {}): Promise<Paginated<File>> {
+    return this.request<Paginated<File>>('GET', `/api/v1/files`, { query });
+  }
+
+  getFile(id: string, query: { include?: string; fields?: string } = {}): Promise<File> {
+    return this.request<File>('GET', `/api/v1/files/${encodeURIComponent(id)}`, { query });
+  }
+
+  restoreFile(id: string): Promise<File> {
+    return this.request<File>('POST', `/api/v1/files/${encodeURIComponent(id)}/restore`);
+  }
+
+  listTeamInvites(query: { page?: number; limit?: number; sort?: string; order?: string; q?: string; include?: string; exclude?: string; fields?: string; ids?: string } = {}): Promise<Paginated<TeamInvite>> {
+    return this.request<Paginated<TeamInvite>>('GET', `/api/v1/team-invitations`, { query });
+  }
+
+  bulkDeleteTeamInvites(body: BulkDeleteRequest): Promise<BulkDeleteResponse> {
+    return this.request<BulkDeleteResponse>('POST', `/api/v1/team-invitations/bulk-delete`, { body });
+  }
+
+  deleteTeamInvite(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/team-invitations/${encodeURIComponent(id)}`);
+  }
+
+  restoreTeamInvite(id: string): Promise<TeamInvite> {
+    return this.request<TeamInvite>('POST', `/api/v1/team-invitations/${encodeURIComponent(id)}/restore`);
+  }
+
+  listTeams(query: { page?: number; limit?: number; sort?: string; order?: string; q?: string; include?: string; exclude?: string; fields?: string; ids?: string } = {}): Promise<Paginated<Team>> {
+    return this.request<Paginated<Team>>('GET', `/api/v1/teams`, { query });
+  }
+
+  createTeam(body: Team): Promise<Team> {
+    return this.request<Team>('POST', `/api/v1/teams`, { body });
+  }
+
+  getTeam(id: string, query: { include?: string; fields?: string } = {}): Promise<Team> {
+    return this.request<Team>('GET', `/api/v1/teams/${encodeURIComponent(id)}`, { query });
+  }
+
+  updateTeam(id: string, body: Team): Promise<Team> {
+    return this.request<Team>('PUT', `/api/v1/teams/${encodeURIComponent(id)}`, { body });
+  }
+
+  patchTeam(id: string, body: Partial<Team>): Promise<Team> {
+    return this.request<Team>('PATCH', `/api/v1/teams/${encodeURIComponent(id)}`, { body });
+  }
+
+  deleteTeam(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/teams/${encodeURIComponent(id)}`);
+  }
+
+  restoreTeam(id: string): Promise<Team> {
+    return this.request<Team>('POST', `/api/v1/teams/${encodeURIComponent(id)}/restore`);
+  }
+
+  listTemplates(query: { page?: number; limit?: number; sort?: string; order?: string; q?: string; include?: string; exclude?: string; fields?: string; ids?: string } = {}): Promise<Paginated<Template>> {
+    return this.request<Paginated<Template>>('GET', `/api/v1/templates`, { query });
+  }
+
+  createTemplate(body: Template): Promise<Template> {
+    return this.request<Template>('POST', `/api/v1/templates`, { body });
+  }
+
+  bulkDeleteTemplates(body: BulkDeleteRequest): Promise<BulkDeleteResponse> {
+    return this.request<BulkDeleteResponse>('POST', `/api/v1/templates/bulk-delete`, { body });
+  }
+
+  getTemplate(id: string, query: { include?: string; fields?: string } = {}): Promise<Template> {
+    return this.request<Template>('GET', `/api/v1/templates/${encodeURIComponent(id)}`, { query });
+  }
+
+  updateTemplate(id: string, body: Template): Promise<Template> {
+    return this.request<Template>('PUT', `/api/v1/templates/${encodeURIComponent(id)}`, { body });
+  }
+
+  patchTemplate(id: string, body: Partial<Template>): Promise<Template> {
+    return this.request<Template>('PATCH', `/api/v1/templates/${encodeURIComponent(id)}`, { body });
+  }
+
+  deleteTemplate(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/templates/${encodeURIComponent(id)}`);
+  }
+
+  restoreTemplate(id: string): Promise<Template> {
+    return this.request<Template>('POST', `/api/v1/templates/${encodeURIComponent(id)}/restore`);
+  }
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"be0/internal/anonymize"
+	"be0/internal/app"
+	"be0/internal/cdc"
+	"be0/internal/config"
+	"be0/internal/db"
+	"be0/internal/fixtures"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const usage = `Usage: be0ctl <command> [options]
+
+Commands:
+  db migrate                            Run database migrations, e.g. before deploying with MIGRATE_ON_START=false
+  seed --profile demo [--wipe]          Create or update fixture data for local development
+  anonymize [--dsn <dsn>] [--force]     Rewrite personal data in a restored production copy
+  events replay --from <RFC3339 time>   Republish outbox events created since the given time
+  permissions backfill [--dry-run]      Grant missing default permissions to existing users
+`
+
+func main() {
+	log := logger.New("be0ctl")
+
+	if len(os.Args) >= 2 && os.Args[1] == "seed" {
+		if err := seed(os.Args[2:], log); err != nil {
+			log.Error("❌ Seeding failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Error("❌ Failed to load environment variables", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Error("❌ Failed to load configuration", err)
+		os.Exit(1)
+	}
+
+	if os.Args[1] == "anonymize" {
+		if err := anonymizeDatabase(cfg, os.Args[2:], log); err != nil {
+			log.Error("❌ Anonymization failed", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) < 3 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] + " " + os.Args[2] {
+	case "db migrate":
+		if err := migrate(cfg, log); err != nil {
+			log.Error("❌ Migration failed", err)
+			os.Exit(1)
+		}
+	case "events replay":
+		if err := replayEvents(cfg, os.Args[3:], log); err != nil {
+			log.Error("❌ Replay failed", err)
+			os.Exit(1)
+		}
+	case "permissions backfill":
+		if err := backfillPermissions(cfg, os.Args[3:], log); err != nil {
+			log.Error("❌ Backfill failed", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+// seed upserts a fixture profile, optionally truncating the data tables first
+func seed(args []string, log *logger.Logger) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	profile := fs.String("profile", "demo", "fixture profile to seed")
+	wipe := fs.Bool("wipe", false, "truncate all non-system tables first, refused on production-like databases")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	application, err := app.Bootstrap(log)
+	if err != nil {
+		return err
+	}
+	defer application.Close()
+
+	if *wipe {
+		schema, err := db.Schema()
+		if err != nil {
+			return err
+		}
+		// Files are not auto-migrated but hold fixture rows too
+		tables := append(schema.Tables, "files")
+		if err := fixtures.Wipe(application.DB, tables, application.Config.Server.Environment == "production"); err != nil {
+			return err
+		}
+	}
+
+	result, err := fixtures.Seed(context.Background(), application.DB, application.Storage, *profile)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range slices.Sorted(maps.Keys(result.Upserted)) {
+		fmt.Printf("%-14s %d\n", table, result.Upserted[table])
+	}
+	for _, email := range slices.Sorted(maps.Keys(result.Credentials)) {
+		fmt.Printf("login          %s / %s\n", email, result.Credentials[email])
+	}
+	log.Success("✅ Seeded profile %s into team %s", *profile, result.TeamID)
+	return nil
+}
+
+// anonymizeDatabase rewrites personal data in place, then checks no real-looking emails are left.
+// The target defaults to the configured database and is refused when it is a production host.
+func anonymizeDatabase(cfg *config.Config, args []string, log *logger.Logger) error {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "database to anonymize, defaults to the configured one")
+	batchSize := fs.Int("batch-size", 1000, "rows rewritten per transaction")
+	force := fs.Bool("force", false, "run even when the target looks like production")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target := *dsn
+	if target == "" {
+		target = db.DSN(cfg)
+		if cfg.Server.Environment == "production" && !*force {
+			return fmt.Errorf("APP_ENV is production, pass --dsn for the copy or --force")
+		}
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("--batch-size must be positive")
+	}
+
+	production, err := anonymize.IsProduction(target, cfg.Database.ProductionHosts)
+	if err != nil {
+		return err
+	}
+	if production && !*force {
+		return fmt.Errorf("the target is a production host (DB_PRODUCTION_HOSTS), pass --force to rewrite it anyway")
+	}
+
+	conn, err := gorm.Open(postgres.Open(target), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if sqlDB, err := conn.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	ctx := context.Background()
+	rewritten, err := anonymize.Run(ctx, conn, *batchSize, func(table string, done, total int64) {
+		fmt.Printf("%-26s %d/%d\n", table, done, total)
+	})
+	if err != nil {
+		return err
+	}
+
+	remaining, err := anonymize.Verify(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	var left int64
+	for _, table := range slices.Sorted(maps.Keys(remaining)) {
+		fmt.Printf("%-26s %d real-looking emails left\n", table, remaining[table])
+		left += remaining[table]
+	}
+	if left > 0 {
+		return fmt.Errorf("%d rows still hold real-looking emails", left)
+	}
+
+	var total int64
+	for _, count := range rewritten {
+		total += count
+	}
+	log.Success("✅ Anonymized %d rows in %d tables", total, len(rewritten))
+	return nil
+}
+
+// migrate runs the migrations Connect skips when MIGRATE_ON_START is off
+func migrate(cfg *config.Config, log *logger.Logger) error {
+	cfg.Database.MigrateOnStart = true
+	if err := db.Connect(cfg); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Success("✅ Database migrated")
+	return nil
+}
+
+// replayEvents republishes outbox events to the configured broker
+func replayEvents(cfg *config.Config, args []string, log *logger.Logger) error {
+	fs := flag.NewFlagSet("events replay", flag.ExitOnError)
+	from := fs.String("from", "", "replay events created at or after this RFC3339 time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *from == "" {
+		return fmt.Errorf("--from is required")
+	}
+
+	fromTime, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		return fmt.Errorf("invalid --from time: %w", err)
+	}
+
+	if err := db.Connect(cfg); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	publisher, err := cdc.NewPublisher(cfg.CDC)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	relay := cdc.NewRelay(db.GetDB(), publisher, cfg.CDC.SubjectPrefix, time.Duration(cfg.CDC.PollInterval)*time.Second, cfg.CDC.BatchSize)
+	count, err := relay.Replay(context.Background(), fromTime)
+	if err != nil {
+		return err
+	}
+
+	log.Success("✅ Replayed %d events since %s", count, fromTime.Format(time.RFC3339))
+	return nil
+}
+
+// backfillPermissions grants every default permission users are missing for their role.
+// With --dry-run it only reports affected user counts per role.
+func backfillPermissions(cfg *config.Config, args []string, log *logger.Logger) error {
+	fs := flag.NewFlagSet("permissions backfill", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report affected users per role without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := db.Connect(cfg); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var result *models.PermissionBackfillResult
+	if *dryRun {
+		var err error
+		result, err = models.BackfillPermissions(ctx, db.GetDB(), nil, true, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		job := models.Job{Type: models.JobTypePermissionBackfill, Status: models.JobStatusQueued}
+		if err := db.GetDB().Create(&job).Error; err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+		var err error
+		result, err = tasks.RunPermissionBackfill(ctx, db.GetDB(), &job, nil, false)
+		if err != nil {
+			return err
+		}
+		log.Info("job %s", job.ID)
+	}
+
+	for _, role := range []models.UserRole{models.UserRoleSuperAdmin, models.UserRoleAdmin, models.UserRoleMember} {
+		fmt.Printf("%-12s users=%d inserted=%d\n", role, result.AffectedUsers[role], result.InsertedPermissions[role])
+	}
+
+	if *dryRun {
+		log.Success("✅ Dry run complete, nothing was written")
+	} else {
+		log.Success("✅ Backfill complete")
+	}
+	return nil
+}
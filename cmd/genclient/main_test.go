@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestCommittedClientIsUpToDate fails when an API change was committed without
+// regenerating clients/typescript, like genclient -check in CI
+func TestCommittedClientIsUpToDate(t *testing.T) {
+	files, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale := staleFiles(filepath.Join("..", "..", "clients", "typescript"), files); len(stale) > 0 {
+		t.Errorf("out of date: %v, run go run ./cmd/genclient", stale)
+	}
+}
+
+func TestStaleFilesReportsDrift(t *testing.T) {
+	files, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	for _, path := range sortedPaths(files) {
+		target := filepath.Join(out, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, files[path], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stale := staleFiles(out, files); len(stale) != 0 {
+		t.Fatalf("fresh output reported stale: %v", stale)
+	}
+
+	// ✏️ An edited file and a deleted one are both drift
+	paths := sortedPaths(files)
+	edited, deleted := paths[0], paths[len(paths)-1]
+	if err := os.WriteFile(filepath.Join(out, edited), append(files[edited], "// edited\n"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(out, deleted)); err != nil {
+		t.Fatal(err)
+	}
+	if stale := staleFiles(out, files); !slices.Equal(stale, []string{edited, deleted}) {
+		t.Errorf("stale %v, want %v", stale, []string{edited, deleted})
+	}
+}
@@ -27,7 +27,7 @@ func helper() {
 		log.Error("❌ Failed to load configuration", err)
 		return
 	}
-	err = crypto.InitializeKeys(cfg.Crypto.PrivateKey)
+	err = crypto.InitializeKeys(cfg.Crypto.PrivateKey, cfg.Crypto.PreviousKeys()...)
 	if err != nil {
 		log.Error("❌ Failed to initialize keys", err)
 		return
@@ -2,9 +2,11 @@ package main
 
 import (
 	"be0/docs/swagger"
+	"be0/internal/cdc"
 	"be0/internal/handlers"
-	"be0/internal/utils/crypto"
+	"be0/internal/handlers/notifications"
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -12,14 +14,15 @@ import (
 	"time"
 
 	"be0/internal/api"
+	"be0/internal/app"
 	"be0/internal/config"
 	"be0/internal/db"
-	"be0/internal/models"
-	"be0/internal/services"
+	"be0/internal/plugins"
+	"be0/internal/rpc"
 	"be0/internal/tasks"
+	"be0/internal/utils"
 	"be0/internal/utils/logger"
-
-	"github.com/joho/godotenv"
+	"be0/internal/webhooks"
 )
 
 // 🚀 Main function
@@ -41,115 +44,212 @@ import (
 // @name X-API-KEY
 
 func main() {
+	mode := flag.String("mode", "", "parts to run: api, worker or all (defaults to RUN_MODE, then all)")
+	flag.Parse()
 
 	logger := logger.New("kori")
 
-	// check if .env file exists
-	if _, err := os.Stat(".env"); os.IsNotExist(err) {
-		logger.Info("No .env file found, skipping environment variable loading")
-	} else {
-		logger.Info("Loading environment variables from .env file")
-		if err := godotenv.Load(); err != nil {
-			log.Fatalf("Failed to load environment variables: %v", err)
-		}
-	}
-
-	// Load configuration
-	cfg, err := config.Load()
+	application, err := app.Bootstrap(logger)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	// Initialize keys
-	if err := crypto.InitializeKeys(
-		cfg.Crypto.PrivateKey); err != nil {
-		log.Fatalf("Failed to initialize keys: %v", err)
-	}
-
-	// Connect to database
-	if err := db.Connect(cfg); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to start: %v", err)
 	}
 	defer func() {
-		err := db.Close()
+		err := application.Close()
 		if err != nil {
 			log.Fatalf("Failed to close database connection: %v", err)
 		}
 	}()
 
-	db_instance := db.GetDB()
+	cfg := application.Config
+	db_instance := application.DB
+	s3Service := application.Storage
 
-	// Initialize task handlers
-	taskHandler := tasks.NewTaskHandler(db_instance)
+	// The flag wins over RUN_MODE
+	if *mode != "" {
+		if cfg.Mode, err = config.ParseRunMode(*mode); err != nil {
+			log.Fatalf("Invalid --mode: %v", err)
+		}
+	}
 
-	// Initialize task server
-	taskServer := tasks.NewServer(
-		cfg.Redis.Addr,
-		cfg.Redis.Password,
-		cfg.Redis.Username,
-		cfg.Redis.DB,
-		taskHandler,
-		logger,
-	)
+	// 🧩 Compiled-in plugins, see plugins_enabled.go. Their routes and tasks are added by the servers.
+	if err := plugins.Start(cfg, db_instance); err != nil {
+		log.Fatalf("Failed to start plugins: %v", err)
+	}
 
-	// Create a context for task server
+	// Create a context for the background workers
 	serverCtx, serverCancel := context.WithCancel(context.Background())
 	defer serverCancel()
 
-	// Start task server
-	go func() {
-		if err := taskServer.Start(serverCtx); err != nil {
-			logger.Error("Task server error", err)
-		}
-	}()
+	// API processes still enqueue tasks, the workers process them
+	taskClient := tasks.NewTaskClient(cfg.Redis)
 
-	// Initialize task scheduler
-	taskScheduler := tasks.NewScheduler(
-		cfg.Redis.Addr,
-		cfg.Redis.Password,
-		cfg.Redis.Username,
-		cfg.Redis.DB,
-		logger,
+	var (
+		taskServer    *tasks.Server
+		taskScheduler *tasks.Scheduler
+		healthServer  *app.HealthServer
+		apiServer     *api.Server
+		grpcServer    *rpc.Server
 	)
 
-	// Start task scheduler
-	go func() {
-		if err := taskScheduler.Start(); err != nil {
-			logger.Error("Task scheduler error", err)
-		}
-	}()
+	if cfg.Mode.RunsWorker() {
+		// Initialize task handlers
+		taskHandler := tasks.NewTaskHandler(db_instance)
 
-	// Initialize API server
-	apiServer := api.NewServer(cfg, db_instance)
-	go func() {
+		// Initialize task server
+		taskServer = tasks.NewServer(cfg.Worker, cfg.Redis, taskHandler, logger)
+
+		// Start task server
+		go func() {
+			if err := taskServer.Start(serverCtx); err != nil {
+				logger.Error("Task server error", err)
+			}
+		}()
 
-		// Initialize S3 service
-		s3Service, err := services.NewS3Service(
-			cfg.Storage.S3.BucketName,
-			cfg.Storage.S3.Endpoint,
-			cfg.Storage.S3.Region,
-			cfg.Storage.S3.AccessKey,
-			cfg.Storage.S3.SecretKey,
-		)
+		// Initialize task scheduler
+		taskScheduler = tasks.NewScheduler(cfg.Redis, logger)
+
+		// Start task scheduler
+		go func() {
+			if err := taskScheduler.Start(); err != nil {
+				logger.Error("Task scheduler error", err)
+			}
+		}()
+
+		// Initialize change data capture relay, replicas share the outbox through SKIP LOCKED
+		publisher, err := cdc.NewPublisher(cfg.CDC)
 		if err != nil {
-			log.Fatalf("Failed to initialize S3 service: %v", err)
+			log.Fatalf("Failed to initialize CDC publisher: %v", err)
+		}
+		defer publisher.Close()
+
+		relay := cdc.NewRelay(db_instance, publisher, cfg.CDC.SubjectPrefix, time.Duration(cfg.CDC.PollInterval)*time.Second, cfg.CDC.BatchSize)
+		go relay.Start(serverCtx)
+
+		// Report task queue sizes on /metrics
+		if cfg.Metrics.QueuePollInterval > 0 {
+			go tasks.WatchQueueMetrics(serverCtx, cfg.Redis, time.Duration(cfg.Metrics.QueuePollInterval)*time.Second)
+		}
+	}
+
+	if cfg.Mode.ServesAPI() {
+		// Seed permissions and backfill new ones to existing users
+		if _, err := tasks.NewPermissionMigrator(db_instance, taskClient).Run(context.Background()); err != nil {
+			logger.Warn("Warning: Failed to seed permissions: %v", err)
+		} else {
+			logger.Success("Successfully seeded permissions")
+		}
+
+		// Fill new columns of large tables in the background, resuming any earlier run
+		if err := tasks.StartBackfills(context.Background(), db_instance, taskClient); err != nil {
+			logger.Warn("Warning: Failed to start backfills: %v", err)
+		}
+
+		// Deliver team events to webhook subscribers
+		webhooks.Start(db_instance, taskClient)
+
+		// Email invitees and other recipients of the app's own notifications
+		notifications.Start(taskClient, cfg.Server.PublicURL)
+	}
+
+	// Startup banner, the same details are served by GET /api/v1/admin/config
+	build := utils.GetBuildInfo()
+	logger.Info("be0 %s (revision %s, %s) in %s mode", build.Version, build.Revision, build.GoVersion, cfg.Mode)
+	if schema, err := db.Schema(); err == nil {
+		logger.Info("Schema version %s (%d tables)", schema.Version, len(schema.Tables))
+	}
+	logger.Info("Providers: %v", handlers.Providers(cfg))
+	logger.Info("Features: %v", cfg.Features())
+
+	if cfg.Mode.ServesAPI() {
+		// Initialize API server
+		apiServer = api.NewServer(cfg, db_instance, application.Redis)
+
+		// Metrics go to an internal port instead of the API when METRICS_PORT is set
+		if cfg.Metrics.Port > 0 {
+			healthServer = app.NewHealthServer(cfg.Server.Host, cfg.Metrics.Port)
+			go func() {
+				logger.Success("Metrics server started on port %d", cfg.Metrics.Port)
+				if err := healthServer.Start(); err != nil {
+					logger.Error("Metrics server error", err)
+				}
+			}()
+		}
+
+		// Warm up connections and prepared statements, the readiness check reports ready afterwards
+		warmup := func() {
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Database.WarmupTimeout)*time.Second)
+			defer cancel()
+
+			if err := db.Warmup(ctx, cfg.Database.MinIdleConns); err != nil {
+				logger.Warn("Database warm-up incomplete: %v", err)
+			}
+			if cfg.Storage.Warmup {
+				if err := s3Service.Warmup(ctx); err != nil {
+					logger.Warn("Storage warm-up incomplete: %v", err)
+				}
+			}
+
+			logger.Success("Warm-up finished in %s", time.Since(start))
+			apiServer.MarkReady()
+			if healthServer != nil {
+				healthServer.MarkReady()
+			}
+		}
+		if cfg.Server.WaitForWarmup {
+			warmup()
+		} else {
+			go warmup()
 		}
 
-		// Register the URL generator
-		models.RegisterFileURLGenerator(s3Service)
-		handlers.RegisterStorageHandler(s3Service)
+		// Initialize internal gRPC server
+		if cfg.GRPC.Enabled {
+			grpcServer, err = rpc.NewServer(cfg.GRPC, db_instance, apiServer.Auth())
+			if err != nil {
+				log.Fatalf("Failed to initialize gRPC server: %v", err)
+			}
+			go func() {
+				if err := grpcServer.Start(); err != nil {
+					logger.Error("gRPC server error", err)
+				}
+			}()
+		}
+		go func() {
+			logger.Success("API server started")
 
-		logger.Success("API server started")
+			// Swagger documentation
+			swagger.SwaggerInfo.Title = "be0 API Documentation"
+			swagger.SwaggerInfo.Description = "API documentation for be0 application"
+			swagger.SwaggerInfo.Version = "1.0"
+			swagger.SwaggerInfo.Host = "api.be0.com"
+			swagger.SwaggerInfo.Schemes = []string{"https"}
 
-		// Swagger documentation
-		swagger.SwaggerInfo.Title = "be0 API Documentation"
-		swagger.SwaggerInfo.Description = "API documentation for be0 application"
-		swagger.SwaggerInfo.Version = "1.0"
-		swagger.SwaggerInfo.Host = "api.be0.com"
-		swagger.SwaggerInfo.Schemes = []string{"https"}
+			if err := apiServer.Start(); err != nil {
+				logger.Error("API server error", err)
+			}
+		}()
+	} else {
+		// Workers only expose health and metrics
+		healthServer = app.NewHealthServer(cfg.Server.Host, cfg.Worker.HealthPort)
+		healthServer.MarkReady()
+		go func() {
+			logger.Success("Health server started on port %d", cfg.Worker.HealthPort)
+			if err := healthServer.Start(); err != nil {
+				logger.Error("Health server error", err)
+			}
+		}()
+	}
 
-		if err := apiServer.Start(); err != nil {
-			logger.Error("API server error", err)
+	// Reload the configuration on SIGHUP, e.g. after editing .env
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if _, err := config.Reload(); err != nil {
+				logger.Error("Failed to reload configuration, keeping the current one", err)
+				continue
+			}
+			logger.Success("Configuration reloaded")
 		}
 	}()
 
@@ -163,14 +263,33 @@ func main() {
 	defer cancel()
 
 	// Stop task scheduler
-	taskScheduler.Stop()
+	if taskScheduler != nil {
+		taskScheduler.Stop()
+	}
 
-	// Stop task server
+	// Stop task server, in-flight tasks get WORKER_SHUTDOWN_TIMEOUT to finish
+	if taskServer != nil {
+		taskServer.Shutdown()
+	}
 	serverCancel()
 
+	// Stop gRPC server
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+
 	// Shutdown API server
-	if err := apiServer.Shutdown(ctx); err != nil {
-		logger.Error("Failed to shutdown API server", err)
+	if apiServer != nil {
+		if err := apiServer.Shutdown(ctx); err != nil {
+			logger.Error("Failed to shutdown API server", err)
+		}
+	}
+
+	// Shutdown health server
+	if healthServer != nil {
+		if err := healthServer.Shutdown(ctx); err != nil {
+			logger.Error("Failed to shutdown health server", err)
+		}
 	}
 
 	logger.Info("Servers shutdown gracefully")
@@ -0,0 +1,9 @@
+//go:build plugins
+
+package main
+
+// Plugins compiled in with -tags plugins, e.g. go build -tags plugins ./cmd. Add an import
+// for each local plugin package, they register themselves when imported.
+import (
+	_ "be0/internal/plugins/example"
+)
@@ -0,0 +1,177 @@
+// Package anonymize rewrites personal data in copies of the production database,
+// so restored dumps can be used for local development
+package anonymize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"be0/internal/utils/logger"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var log = logger.New("anonymize")
+
+// randomString is a random hex string per row, enough to make tokens and codes unusable
+const randomString = "md5(random()::text || clock_timestamp()::text)"
+
+var firstNames = []string{
+	"Alex", "Blake", "Casey", "Dana", "Eden", "Finley", "Gray", "Harper", "Indigo", "Jordan",
+	"Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker", "Quinn", "Riley", "Sage", "Taylor",
+}
+
+var lastNames = []string{
+	"Archer", "Brooks", "Carter", "Dawson", "Ellis", "Foster", "Garner", "Hayes", "Irving", "Jensen",
+	"Keller", "Lawson", "Mercer", "Nolan", "Oliver", "Porter", "Reed", "Sawyer", "Turner", "Walsh",
+}
+
+// pick returns an SQL expression choosing a name from list by hashing the row ID,
+// so a row gets the same name on every run
+func pick(list []string, salt string) string {
+	quoted := make([]string, len(list))
+	for i, name := range list {
+		quoted[i] = "'" + name + "'"
+	}
+	return fmt.Sprintf("(ARRAY[%s])[(1 + abs(hashtext(id::text || '%s')::bigint) %% %d)::int]", strings.Join(quoted, ","), salt, len(list))
+}
+
+// Rule rewrites the personal columns of a table. Set is the SET clause of an UPDATE and may refer to the row's columns.
+type Rule struct {
+	Table string
+	Set   string
+}
+
+// Rules lists the tables holding personal data or secrets. File paths are left intact.
+func Rules() []Rule {
+	return []Rule{
+		{"users", `email = 'user-' || id || '@example.invalid', ` +
+			`first_name = ` + pick(firstNames, "first") + `, last_name = ` + pick(lastNames, "last") + `, ` +
+			`password = ` + randomString + `, provider_id = '', provider_data = NULL, ` +
+			`two_factor_enabled = false, two_factor_secret = '', two_factor_pending_secret = ''`},
+		{"two_factor_recovery_codes", `code_hash = ` + randomString},
+		{"team_invites", `email = 'invite-' || id || '@example.invalid', ` +
+			`name = ` + pick(firstNames, "first") + ` || ' ' || ` + pick(lastNames, "last") + `, code = ` + randomString},
+		{"password_resets", `code_hash = ` + randomString + `, ip_address = '', user_agent = ''`},
+		{"email_verifications", `code = ` + randomString},
+		{"auth_transactions", `token_id = ` + randomString + `, refresh = ` + randomString + `, ip_address = '', user_agent = '', device_id = ''`},
+		{"api_keys", `key_hash = ` + randomString},
+		{"recovery_requests", `token_hash = ` + randomString + `, reason = 'Anonymized'`},
+		{"file_shares", `token = ` + randomString + `, ` +
+			`password_hash = CASE WHEN coalesce(password_hash, '') = '' THEN password_hash ELSE ` + randomString + ` END`},
+		{"file_contents", `text = ''`},
+		{"webhook_subscriptions", `url = '', secret = ` + randomString},
+		{"audit_entries", `ip_address = '', metadata = NULL`},
+		{"sandbox_emails", `"to" = 'sandbox-' || id || '@example.invalid', subject = 'Anonymized', text = '', html = ''`},
+		{"sandbox_webhooks", `url = '', payload = '{}'`},
+		{"outbox_events", `payload = '{}'`},
+	}
+}
+
+// emailColumns are checked for real-looking addresses once the rules ran
+var emailColumns = []struct{ table, column string }{
+	{"users", "email"},
+	{"team_invites", "email"},
+	{"sandbox_emails", `"to"`},
+}
+
+// realEmail matches addresses that were not rewritten
+const realEmail = `%s ~* '^[^@[:space:]]+@[^@[:space:]]+\.[a-z]{2,}$' AND %s NOT LIKE '%%@example.invalid'`
+
+// Progress is called after every batch with the rows rewritten so far and the table's row count
+type Progress func(table string, done, total int64)
+
+// Run applies every rule in batches of batchSize rows, one transaction per batch.
+// Tables missing from the database, e.g. in dumps of older versions, are skipped.
+// It returns the rows rewritten per table.
+func Run(ctx context.Context, db *gorm.DB, batchSize int, progress Progress) (map[string]int64, error) {
+	db = db.WithContext(ctx)
+	rewritten := make(map[string]int64)
+
+	for _, rule := range Rules() {
+		if !db.Migrator().HasTable(rule.Table) {
+			log.Warn("Skipping %s, the table does not exist", rule.Table)
+			continue
+		}
+
+		var total int64
+		if err := db.Table(rule.Table).Count(&total).Error; err != nil {
+			return rewritten, fmt.Errorf("failed to count %s: %w", rule.Table, err)
+		}
+
+		// 🔁 Batches are keyed by ID, so rows are visited once however long the run takes
+		lastID := ""
+		for {
+			var ids []string
+			err := db.Transaction(func(tx *gorm.DB) error {
+				query := tx.Table(rule.Table).Order("id").Limit(batchSize)
+				if lastID != "" {
+					query = query.Where("id > ?", lastID)
+				}
+				if err := query.Pluck("id", &ids).Error; err != nil {
+					return err
+				}
+				if len(ids) == 0 {
+					return nil
+				}
+				return tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE id IN ?", rule.Table, rule.Set), ids).Error
+			})
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to anonymize %s: %w", rule.Table, err)
+			}
+			if len(ids) == 0 {
+				break
+			}
+
+			lastID = ids[len(ids)-1]
+			rewritten[rule.Table] += int64(len(ids))
+			if progress != nil {
+				progress(rule.Table, rewritten[rule.Table], total)
+			}
+		}
+	}
+
+	return rewritten, nil
+}
+
+// Verify counts the rows per table whose email still looks like a real address
+func Verify(ctx context.Context, db *gorm.DB) (map[string]int64, error) {
+	db = db.WithContext(ctx)
+	remaining := make(map[string]int64)
+
+	for _, c := range emailColumns {
+		if !db.Migrator().HasTable(c.table) {
+			continue
+		}
+		var count int64
+		if err := db.Table(c.table).Where(fmt.Sprintf(realEmail, c.column, c.column)).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to verify %s: %w", c.table, err)
+		}
+		remaining[c.table] = count
+	}
+
+	return remaining, nil
+}
+
+// IsProduction reports whether a DSN points at one of the production hosts
+func IsProduction(dsn string, productionHosts []string) (bool, error) {
+	cfg, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return false, fmt.Errorf("invalid DSN: %w", err)
+	}
+
+	hosts := []string{cfg.Host}
+	for _, fallback := range cfg.Fallbacks {
+		hosts = append(hosts, fallback.Host)
+	}
+	for _, host := range hosts {
+		for _, production := range productionHosts {
+			if strings.EqualFold(host, production) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,106 @@
+package anonymize
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/testutil/containers"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(containers.Run(m))
+}
+
+func TestIsProduction(t *testing.T) {
+	hosts := []string{"db.prod.internal", "10.0.0.5"}
+	tests := []struct {
+		dsn  string
+		want bool
+	}{
+		{"postgres://be0@db.prod.internal:5432/be0", true},
+		{"postgres://be0@DB.PROD.INTERNAL/be0", true},
+		{"host=localhost port=5432 dbname=be0", false},
+		{"postgres://be0@replica.local,10.0.0.5/be0", true},
+		{"postgres://be0@localhost/be0", false},
+	}
+	for _, tt := range tests {
+		got, err := IsProduction(tt.dsn, hosts)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %v, %v, want %v", tt.dsn, got, err, tt.want)
+		}
+	}
+	if _, err := IsProduction("postgres://%zz", hosts); err == nil {
+		t.Error("an invalid DSN was accepted")
+	}
+}
+
+func TestRunRewritesPersonalData(t *testing.T) {
+	gdb := containers.Postgres(t)
+	for _, rule := range Rules() {
+		if !gdb.Migrator().HasTable(rule.Table) {
+			t.Errorf("rule for %s, which the migrations do not create", rule.Table)
+		}
+	}
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	var users []*models.User
+	for range 3 {
+		user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+		if err := gdb.Model(user).Updates(map[string]interface{}{"email": "grace." + user.ID[:8] + "@acme.com", "first_name": "Grace"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	file := &models.File{TeamID: team.ID, UserID: &users[0].ID, Path: "team/report.pdf", Name: "report.pdf", Size: 1, Type: "application/pdf"}
+	if err := gdb.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := Verify(context.Background(), gdb); err != nil || remaining["users"] != 3 {
+		t.Fatalf("before the run %v real emails remain, %v", remaining, err)
+	}
+
+	// 🔁 One row per batch, every row must still be visited once
+	progressed := map[string]int64{}
+	rewritten, err := Run(context.Background(), gdb, 1, func(table string, done, total int64) {
+		if done > total {
+			t.Errorf("%s: %d of %d rows", table, done, total)
+		}
+		progressed[table] = done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewritten["users"] != 3 || progressed["users"] != 3 {
+		t.Errorf("rewrote %d users, progress reported %d, want 3", rewritten["users"], progressed["users"])
+	}
+	if remaining, err := Verify(context.Background(), gdb); err != nil || remaining["users"] != 0 {
+		t.Errorf("after the run %v real emails remain, %v", remaining, err)
+	}
+
+	var first models.User
+	if err := gdb.First(&first, "id = ?", users[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if first.Email != "user-"+first.ID+"@example.invalid" || first.FirstName == "Grace" || strings.HasPrefix(first.Password, "$2") {
+		t.Errorf("user left as %s %s with password %s", first.Email, first.FirstName, first.Password)
+	}
+
+	// 📌 Names are derived from the row, a second run gives the same ones, paths stay
+	if _, err := Run(context.Background(), gdb, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+	var again models.User
+	gdb.First(&again, "id = ?", first.ID)
+	if again.FirstName != first.FirstName || again.LastName != first.LastName {
+		t.Errorf("renamed from %s %s to %s %s", first.FirstName, first.LastName, again.FirstName, again.LastName)
+	}
+	var stored models.File
+	gdb.Select("path").First(&stored, "id = ?", file.ID)
+	if stored.Path != "team/report.pdf" {
+		t.Errorf("file path rewritten to %q", stored.Path)
+	}
+}
@@ -1,35 +1,115 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/redact"
 	"be0/internal/services"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 // BaseController provides generic CRUD operations for any model
 type BaseController[T any] struct {
 	service services.BaseService[T]
+	// includes maps the lowercase relations clients may include to their declared spelling
+	includes map[string]string
+}
+
+// Option configures a BaseController
+type Option func(*controllerOptions)
+
+type controllerOptions struct {
+	includes []string
+}
+
+// WithIncludes declares the relations clients may preload with ?include=, such as "Team" or
+// "Users.Team". Declaring a nested relation allows its parents. Without it nothing can be
+// included.
+func WithIncludes(relations ...string) Option {
+	return func(o *controllerOptions) {
+		o.includes = append(o.includes, relations...)
+	}
 }
 
 // NewBaseController creates a new base controller
-func NewBaseController[T any](service services.BaseService[T]) *BaseController[T] {
+func NewBaseController[T any](service services.BaseService[T], opts ...Option) *BaseController[T] {
+	var o controllerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	includes := map[string]string{}
+	for _, relation := range o.includes {
+		parts := strings.Split(relation, ".")
+		for i := range parts {
+			path := strings.Join(parts[:i+1], ".")
+			includes[strings.ToLower(path)] = path
+		}
+	}
 	return &BaseController[T]{
-		service: service,
+		service:  service,
+		includes: includes,
 	}
 }
 
-// parseIncludes parses the include query parameter and returns a slice of relationships to preload
-func parseIncludes(ctx echo.Context) []string {
+// MaxIncludeDepth is how many relations deep an include may reach, "Users.Team" is two
+const MaxIncludeDepth = 2
+
+// parseIncludes returns the relations of the include query parameter to preload, spelled as
+// declared with WithIncludes. Relations that weren't declared, and ones deeper than
+// MaxIncludeDepth, are a 400.
+func (c *BaseController[T]) parseIncludes(ctx echo.Context) ([]string, error) {
 	include := ctx.QueryParam("include")
 	if include == "" {
-		return nil
+		return nil, nil
 	}
-	return strings.Split(include, ",")
+
+	var includes []string
+	for _, name := range strings.Split(include, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.Count(name, ".") >= MaxIncludeDepth {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("include %q is too deep, at most %d levels are allowed", name, MaxIncludeDepth))
+		}
+		// 🛡️ Only declared relations are preloaded, GORM would load any relation it knows
+		relation, ok := c.includes[strings.ToLower(name)]
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, c.unknownInclude(name))
+		}
+		if !slices.Contains(includes, relation) {
+			includes = append(includes, relation)
+		}
+	}
+	return includes, nil
+}
+
+// unknownInclude is the error message of an include that wasn't declared
+func (c *BaseController[T]) unknownInclude(name string) string {
+	if len(c.includes) == 0 {
+		return fmt.Sprintf("unknown include %q, this resource has no includes", name)
+	}
+	valid := make([]string, 0, len(c.includes))
+	for _, relation := range c.includes {
+		valid = append(valid, relation)
+	}
+	sort.Strings(valid)
+	return fmt.Sprintf("unknown include %q, valid includes are %s", name, strings.Join(valid, ", "))
 }
 
 // parseExcludes parses the exclude query parameter and returns a slice of fields to exclude
@@ -41,6 +121,105 @@ func parseExcludes(ctx echo.Context) []string {
 	return strings.Split(exclude, ",")
 }
 
+// parseFields parses the fields query parameter, the sparse fieldset of Get and List
+func parseFields(ctx echo.Context) []string {
+	fields := ctx.QueryParam("fields")
+	if fields == "" {
+		return nil
+	}
+	return strings.Split(fields, ",")
+}
+
+// selectFields checks the sparse fieldset of the request, nil when it asks for all fields
+func (c *BaseController[T]) selectFields(ctx echo.Context, includes []string) (*services.Selection, error) {
+	names := parseFields(ctx)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	fields, err := c.service.Fields()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	selection, err := fields.Select(names, includes)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return selection, nil
+}
+
+// sparse keeps only the keys of a sparse fieldset in the object, or list of objects, data
+func sparse(data interface{}, selection *services.Selection) (interface{}, error) {
+	if selection == nil {
+		return data, nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var out interface{}
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool, len(selection.Keys))
+	for _, key := range selection.Keys {
+		keep[key] = true
+	}
+	trim := func(value interface{}) {
+		if object, ok := value.(map[string]interface{}); ok {
+			for key := range object {
+				if !keep[key] {
+					delete(object, key)
+				}
+			}
+		}
+	}
+	if list, ok := out.([]interface{}); ok {
+		for _, item := range list {
+			trim(item)
+		}
+	} else {
+		trim(out)
+	}
+	return out, nil
+}
+
+// writeContext returns the request context of a write, failing it with
+// services.ErrPreconditionFailed unless the entity matches the request's If-Match
+func writeContext(ctx echo.Context) context.Context {
+	return services.WithIfMatch(requestContext(ctx), ctx.Request().Header.Get("If-Match"))
+}
+
+// requestContext returns the request context annotated with the acting user, team and request ID
+func requestContext(ctx echo.Context) context.Context {
+	userID, _ := ctx.Get("userID").(string)
+	teamID, _ := ctx.Get("teamID").(string)
+	requestID := ctx.Response().Header().Get(echo.HeaderXRequestID)
+	return services.WithActor(ctx.Request().Context(), userID, teamID, requestID)
+}
+
+// toResponse returns the API shape of an entity, using its ResponseMapper when it implements one
+func toResponse[T any](entity *T) interface{} {
+	if mapper, ok := any(entity).(models.ResponseMapper); ok {
+		return mapper.ToResponse()
+	}
+	return entity
+}
+
+// toResponses maps a list of entities with toResponse
+func toResponses[T any](entities []T) interface{} {
+	if _, ok := any(new(T)).(models.ResponseMapper); !ok {
+		return entities
+	}
+	responses := make([]interface{}, len(entities))
+	for i := range entities {
+		responses[i] = toResponse(&entities[i])
+	}
+	return responses
+}
+
 // Create handles creation of new entities
 func (c *BaseController[T]) Create(ctx echo.Context) error {
 	var entity T
@@ -52,12 +231,22 @@ func (c *BaseController[T]) Create(ctx echo.Context) error {
 		return err
 	}
 
-	includes := parseIncludes(ctx)
-	if err := c.service.Create(ctx.Request().Context(), &entity, includes...); err != nil {
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.service.Create(requestContext(ctx), &entity, includes...); err != nil {
+		if errors.Is(err, services.ErrRejected) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		var rejection *models.TemplateRejection
+		if errors.As(err, &rejection) {
+			return ctx.JSON(http.StatusUnprocessableEntity, rejection)
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return ctx.JSON(http.StatusCreated, entity)
+	return redact.JSON(ctx, http.StatusCreated, toResponse(&entity))
 }
 
 // Get handles retrieval of a single entity
@@ -66,13 +255,41 @@ func (c *BaseController[T]) Get(ctx echo.Context) error {
 	if id == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "missing id parameter")
 	}
-	includes := parseIncludes(ctx)
-	entity, err := c.service.Get(ctx.Request().Context(), id, includes...)
+	if err := checkQueryLimits(ctx); err != nil {
+		return err
+	}
+	if err := middleware.CheckQueryParams(ctx, "include", "fields"); err != nil {
+		return err
+	}
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	selection, err := c.selectFields(ctx, includes)
+	if err != nil {
+		return err
+	}
+	entity, err := c.service.Get(services.WithFields(requestContext(ctx), parseFields(ctx)...), id, includes...)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusNotFound, "entity not found")
 	}
 
-	return ctx.JSON(http.StatusOK, entity)
+	// 🏷️ Pollers holding the current version get an empty 304
+	if tag := services.EntityTag(entity); tag != "" {
+		ctx.Response().Header().Set("ETag", tag)
+		if services.TagMatches(ctx.Request().Header.Get("If-None-Match"), tag) {
+			return ctx.NoContent(http.StatusNotModified)
+		}
+	}
+
+	data, err := redact.Value(toResponse(entity), redact.ForCaller(ctx))
+	if err == nil {
+		data, err = sparse(data, selection)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, data)
 }
 
 func (c *BaseController[T]) applyFilters(ctx echo.Context, filters map[string]interface{}) map[string]interface{} {
@@ -97,8 +314,20 @@ func (c *BaseController[T]) applyFilters(ctx echo.Context, filters map[string]in
 	return filters
 }
 
+// listParams are query parameters of List that are not field filters
+var listParams = map[string]bool{
+	"page": true, "limit": true, "include": true, "exclude": true, "fields": true, "sort": true, "order": true, "q": true, "ids": true,
+}
+
 // List handles retrieval of multiple entities with pagination and filtering
 func (c *BaseController[T]) List(ctx echo.Context) error {
+	if err := checkQueryLimits(ctx); err != nil {
+		return err
+	}
+	if _, ok := ctx.QueryParams()["ids"]; ok {
+		return c.listByIDs(ctx)
+	}
+
 	// Parse pagination parameters
 	page, _ := strconv.Atoi(ctx.QueryParam("page"))
 	limit, _ := strconv.Atoi(ctx.QueryParam("limit"))
@@ -110,50 +339,180 @@ func (c *BaseController[T]) List(ctx echo.Context) error {
 		limit = 10
 	}
 
-	// Parse filters from query parameters
+	fields, err := c.service.Fields()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// 🧐 Strict requests learn about typos such as inlcude instead of filtering on them
+	if middleware.StrictParams(ctx) {
+		known := fields.Names()
+		for param := range listParams {
+			known = append(known, param)
+		}
+		if err := middleware.CheckQueryParams(ctx, known...); err != nil {
+			return err
+		}
+	}
+
+	// Parse filters from query parameters, clients use JSON field names
 	filters := make(map[string]interface{})
 	for key, values := range ctx.QueryParams() {
-		if key != "page" && key != "limit" && key != "include" && key != "exclude" && key != "sort" && key != "order" && len(values) > 0 {
-			filters[key] = values[0]
+		if listParams[key] || len(values) == 0 {
+			continue
 		}
+		field, err := fields.Resolve(key)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if field.Virtual {
+			return echo.NewHTTPError(http.StatusBadRequest, "cannot filter on computed field "+field.JSONName)
+		}
+		filters[field.Key()] = values[0]
 	}
 
 	filters = c.applyFilters(ctx, filters)
 
-	includes := parseIncludes(ctx)
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	selection, err := c.selectFields(ctx, includes)
+	if err != nil {
+		return err
+	}
 
+	// Columns are omitted from the query, computed fields are skipped in the hooks
 	excludeFields := make(map[string]bool)
-
-	for _, field := range exclude {
-		excludeFields[field] = true
-
+	var excludedVirtual []string
+	for _, name := range exclude {
+		field, err := fields.Resolve(name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if field.Relation != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "cannot exclude related field "+field.JSONName)
+		}
+		if field.Virtual {
+			excludedVirtual = append(excludedVirtual, field.JSONName)
+		} else {
+			excludeFields[field.Column] = true
+		}
 	}
-	// we also need to sort the fields based on the fields in the entity and the order of the sort query parameter
-	sort := ctx.QueryParam("sort")
-	order := ctx.QueryParam("order")
+
 	var sortFields []string
-	if sort != "" {
-		sortFields = strings.Split(sort, ",")
-		var entity T
-		entityType := reflect.TypeOf(entity)
-		for _, field := range sortFields {
-			if _, found := entityType.FieldByName(field); found {
-				sortFields = append(sortFields, field)
+	if sort := ctx.QueryParam("sort"); sort != "" {
+		for _, name := range strings.Split(sort, ",") {
+			field, err := fields.Resolve(name)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if field.Virtual {
+				return echo.NewHTTPError(http.StatusBadRequest, "cannot sort on computed field "+field.JSONName)
 			}
+			if field.Relation != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "cannot sort on related field "+field.JSONName)
+			}
+			sortFields = append(sortFields, field.Column)
 		}
 	}
 
-	entities, total, err := c.service.List(ctx.Request().Context(), page, limit, filters, excludeFields, sortFields, order, includes...)
+	order := strings.ToLower(ctx.QueryParam("order"))
+	switch order {
+	case "":
+		order = "asc"
+	case "asc", "desc":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "order must be asc or desc")
+	}
+
+	q := strings.TrimSpace(ctx.QueryParam("q"))
+	if _, ok := any(new(T)).(models.Searchable); q != "" && !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "search is not supported for this resource")
+	}
+
+	listCtx := models.WithExcludedFields(requestContext(ctx), excludedVirtual...)
+	listCtx = models.WithSearch(listCtx, q)
+	listCtx = services.WithFields(listCtx, parseFields(ctx)...)
+	entities, total, err := c.service.List(listCtx, page, limit, filters, excludeFields, sortFields, order, includes...)
 
+	if errors.Is(err, services.ErrInvalidListQuery) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	data, err := redact.Value(toResponses(entities), redact.ForCaller(ctx))
+	if err == nil {
+		data, err = sparse(data, selection)
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
-		"data":  entities,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+		"data":       data,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
+// listByIDs answers List for ?ids=, the entities of up to services.MaxGetMany IDs in the order
+// given, without the ones that are missing
+func (c *BaseController[T]) listByIDs(ctx echo.Context) error {
+	// 📑 The order of ids is the order of the answer, filters and sorting don't apply, and the
+	// answer is a single page
+	var others []string
+	for key := range ctx.QueryParams() {
+		switch key {
+		case "ids", "include", "fields", "page", "limit":
+		default:
+			others = append(others, key)
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		return echo.NewHTTPError(http.StatusBadRequest, "ids only combines with include and fields, not "+strings.Join(others, ", "))
+	}
+	var ids []string
+	for _, id := range strings.Split(ctx.QueryParam("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	selection, err := c.selectFields(ctx, includes)
+	if err != nil {
+		return err
+	}
+
+	entities, err := c.service.GetMany(services.WithFields(requestContext(ctx), parseFields(ctx)...), ids, includes...)
+	if errors.Is(err, services.ErrTooManyGetIDs) || errors.Is(err, services.ErrInvalidID) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	data, err := redact.Value(toResponses(entities), redact.ForCaller(ctx))
+	if err == nil {
+		data, err = sparse(data, selection)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":       data,
+		"total":      len(entities),
+		"page":       1,
+		"limit":      services.MaxGetMany,
+		"totalPages": 1,
 	})
 }
 
@@ -173,12 +532,80 @@ func (c *BaseController[T]) Update(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	includes := parseIncludes(ctx)
-	if err := c.service.Update(ctx.Request().Context(), id, &entity, includes...); err != nil {
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.service.Update(writeContext(ctx), id, &entity, includes...); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		}
+		if errors.Is(err, services.ErrRejected) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, services.ErrPreconditionFailed) {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "the entity changed since it was read")
+		}
+		if errors.Is(err, services.ErrStaleVersion) || errors.Is(err, models.ErrConflict) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		var rejection *models.TemplateRejection
+		if errors.As(err, &rejection) {
+			return ctx.JSON(http.StatusUnprocessableEntity, rejection)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return redact.JSON(ctx, http.StatusOK, toResponse(&entity))
+}
+
+// Patch handles partial updates: only the fields in the body change, and explicit nulls,
+// false and 0 are written
+func (c *BaseController[T]) Patch(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing id parameter")
+	}
+
+	var changes map[string]interface{}
+	decoder := json.NewDecoder(ctx.Request().Body)
+	// Numbers stay exact on their way to the entity
+	decoder.UseNumber()
+	if err := decoder.Decode(&changes); err != nil || changes == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "request body must be a JSON object")
+	}
+
+	includes, err := c.parseIncludes(ctx)
+	if err != nil {
+		return err
+	}
+	entity, err := c.service.Patch(writeContext(ctx), id, changes, func(entity *T) error {
+		if err := ctx.Validate(entity); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return nil
+	}, includes...)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		var rejection *models.TemplateRejection
+		switch {
+		case errors.As(err, &httpErr):
+			return httpErr
+		case errors.Is(err, services.ErrInvalidPatch), errors.Is(err, services.ErrRejected):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		case errors.Is(err, services.ErrPreconditionFailed):
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "the entity changed since it was read")
+		case errors.Is(err, services.ErrStaleVersion), errors.Is(err, models.ErrConflict):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.As(err, &rejection):
+			return ctx.JSON(http.StatusUnprocessableEntity, rejection)
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return ctx.JSON(http.StatusOK, entity)
+	return redact.JSON(ctx, http.StatusOK, toResponse(entity))
 }
 
 // Delete handles deletion of an entity
@@ -188,32 +615,114 @@ func (c *BaseController[T]) Delete(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "missing id parameter")
 	}
 
-	if err := c.service.Delete(ctx.Request().Context(), id); err != nil {
+	if err := c.service.Delete(writeContext(ctx), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		}
+		if errors.Is(err, services.ErrPreconditionFailed) {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "the entity changed since it was read")
+		}
+		if errors.Is(err, services.ErrRejected) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	return ctx.NoContent(http.StatusNoContent)
 }
 
+// BulkDeleteRequest lists the entities to delete, at most services.MaxBulkDelete
+type BulkDeleteRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BulkDeleteResponse lists the entities deleted, IDs that were missing, already deleted or
+// of another team are left out
+type BulkDeleteResponse struct {
+	Deleted []string `json:"deleted"`
+}
+
+// BulkDelete handles soft-deleting many entities of the caller's team at once
+func (c *BaseController[T]) BulkDelete(ctx echo.Context) error {
+	var req BulkDeleteRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if len(req.IDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "ids is required")
+	}
+	if len(req.IDs) > services.MaxBulkDelete {
+		return echo.NewHTTPError(http.StatusBadRequest, services.ErrTooManyIDs.Error())
+	}
+	for _, id := range req.IDs {
+		if !middleware.IsValidUUID(id) {
+			return echo.NewHTTPError(http.StatusBadRequest, "ids must be valid UUIDs")
+		}
+	}
+
+	deleted, err := c.service.DeleteMany(requestContext(ctx), req.IDs)
+	if err != nil {
+		if errors.Is(err, services.ErrRejected) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, BulkDeleteResponse{Deleted: deleted})
+}
+
+// Restore handles undoing the deletion of an entity
+func (c *BaseController[T]) Restore(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing id parameter")
+	}
+
+	// 🏢 Deleted entities of other teams are not found, as if they didn't exist
+	reqCtx := requestContext(ctx)
+	if err := c.service.Restore(reqCtx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "deleted entity not found")
+		}
+		if errors.Is(err, models.ErrConflict) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		if errors.Is(err, services.ErrRejected) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	entity, err := c.service.Get(reqCtx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return redact.JSON(ctx, http.StatusOK, toResponse(entity))
+}
+
 // RegisterRoutes registers CRUD routes for the controller
 func (c *BaseController[T]) RegisterRoutes(g *echo.Group, path string, methods ...string) {
 	if len(methods) == 0 {
-		methods = []string{"POST", "GET", "PUT", "DELETE"}
+		methods = []string{"POST", "GET", "PUT", "PATCH", "DELETE"}
 	}
 
+	validateID := middleware.ValidateUUIDParams()
+
 	for _, method := range methods {
 		switch method {
 		case "POST":
 			// validate the request body
 			g.POST(path, c.Create)
 		case "GET":
-			g.GET(path+"/:id", c.Get)
+			g.GET(path+"/:id", c.Get, validateID)
 			g.GET(path, c.List)
 		case "PUT":
 			// validate the request body
-			g.PUT(path+"/:id", c.Update)
+			g.PUT(path+"/:id", c.Update, validateID)
+		case "PATCH":
+			g.PATCH(path+"/:id", c.Patch, validateID)
 		case "DELETE":
-			g.DELETE(path+"/:id", c.Delete)
+			g.DELETE(path+"/:id", c.Delete, validateID)
 		}
 	}
 }
@@ -0,0 +1,598 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/api/validator"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func TestMaliciousListQueriesNeverReachTheDatabase(t *testing.T) {
+	for _, query := range []string{
+		url.Values{"1=1);DROP TABLE templates;--": {"x"}}.Encode(),
+		url.Values{"name\" OR \"1\"=\"1": {"x"}}.Encode(),
+		url.Values{"sort": {"name;DROP TABLE templates"}}.Encode(),
+		url.Values{"sort": {"(SELECT password FROM users LIMIT 1)"}}.Encode(),
+		url.Values{"order": {"desc; DROP TABLE templates"}}.Encode(),
+		url.Values{"exclude": {"id\" --"}}.Encode(),
+	} {
+		controller, service := newRecordingController(t)
+		if status := call(t, controller.List, query); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, status)
+		}
+		if service.calls != 0 {
+			t.Errorf("%s: reached the service", query)
+		}
+	}
+
+	// camelCase and column names are both accepted
+	controller, service := newRecordingController(t)
+	if status := call(t, controller.List, "teamId=x&created_at=y&sort=createdAt"); status != http.StatusOK || service.calls != 1 {
+		t.Errorf("status %d with %d service calls, want 200 and 1", status, service.calls)
+	}
+}
+
+func TestListTotalsCoverEveryPage(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	for i := range 25 {
+		testutil.CreateTeam(t, gdb, fmt.Sprintf("Team %02d", i))
+	}
+	// Deleted rows are neither listed nor counted
+	deleted := testutil.CreateTeam(t, gdb, "Deleted")
+	if err := gdb.Model(deleted).Update("is_deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	controller := NewBaseController[models.Team](services.NewBaseService(gdb, models.Team{}), WithIncludes("Users"))
+
+	seen := map[string]bool{}
+	for page, size := range map[int]int{1: 10, 2: 10, 3: 5} {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/teams?page=%d&limit=10&include=Users", page), nil)
+		rec := httptest.NewRecorder()
+		if err := controller.List(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Data       []models.Team `json:"data"`
+			Total      int64         `json:"total"`
+			TotalPages int64         `json:"totalPages"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Total != 25 || body.TotalPages != 3 || len(body.Data) != size {
+			t.Errorf("page %d: total %d, totalPages %d and %d teams, want 25, 3 and %d", page, body.Total, body.TotalPages, len(body.Data), size)
+		}
+		for _, team := range body.Data {
+			seen[team.ID] = true
+		}
+	}
+	if len(seen) != 25 || seen[deleted.ID] {
+		t.Errorf("listed %d distinct teams, want the 25 live ones", len(seen))
+	}
+}
+
+func TestPatchStatuses(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	NewBaseController[models.Team](services.NewBaseService(gdb, models.Team{})).RegisterRoutes(e.Group(""), "/teams")
+
+	for _, tc := range []struct {
+		id, body string
+		status   int
+	}{
+		{team.ID, `{"sandboxMode": true}`, http.StatusOK},
+		{team.ID, `{"colour": "red"}`, http.StatusBadRequest},
+		{team.ID, `{"slug": "other"}`, http.StatusBadRequest},
+		{team.ID, `[{"name": "Acme"}]`, http.StatusBadRequest},
+		{team.ID, `null`, http.StatusBadRequest},
+		// ❌ The patched team is validated as a whole
+		{team.ID, `{"name": "A"}`, http.StatusBadRequest},
+		{"00000000-0000-0000-0000-000000000000", `{"name": "Ghost"}`, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodPatch, "/teams/"+tc.id, strings.NewReader(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.body, rec.Code, tc.status, rec.Body)
+		}
+	}
+
+	var stored models.Team
+	if err := gdb.First(&stored, "id = ?", team.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.SandboxMode || stored.Name != "Acme" {
+		t.Errorf("sandbox mode %v name %q, want only sandbox mode changed", stored.SandboxMode, stored.Name)
+	}
+}
+
+func TestBulkDeleteAndRestore(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	controller := NewBaseController[models.Template](services.NewBaseService(gdb, models.Template{}))
+	e := echo.New()
+	e.POST("/templates/bulk-delete", controller.BulkDelete)
+	e.POST("/templates/:id/restore", controller.Restore)
+
+	request := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tooMany, _ := json.Marshal(BulkDeleteRequest{IDs: make([]string, services.MaxBulkDelete+1)})
+	for _, body := range []string{`{}`, `{"ids": []}`, `{"ids": ["not-a-uuid"]}`, string(tooMany)} {
+		if rec := request("/templates/bulk-delete", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%.40s: status %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := request("/templates/bulk-delete", `{"ids": ["`+template.ID+`"]}`)
+	var deleted BulkDeleteResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &deleted); err != nil || rec.Code != http.StatusOK || len(deleted.Deleted) != 1 {
+		t.Fatalf("status %d body %s", rec.Code, rec.Body)
+	}
+
+	if rec := request("/templates/"+template.ID+"/restore", ""); rec.Code != http.StatusOK {
+		t.Errorf("restore: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	// 🔁 Only deleted templates can be restored
+	if rec := request("/templates/"+template.ID+"/restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second restore: status %d, want 404", rec.Code)
+	}
+}
+
+func TestRestoreStaysInTheCallersTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	template := &models.Template{TeamID: acme.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	service := services.NewBaseService(gdb, models.Template{})
+	if err := service.Delete(context.Background(), template.ID); err != nil {
+		t.Fatal(err)
+	}
+	controller := NewBaseController[models.Template](service)
+	e := echo.New()
+	e.POST("/templates/:id/restore", controller.Restore, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("teamID", c.Request().Header.Get("X-Team"))
+			return next(c)
+		}
+	})
+	restore := func(teamID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/templates/"+template.ID+"/restore", nil)
+		req.Header.Set("X-Team", teamID)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 🏢 Another team's deleted template is not found, and stays deleted
+	if status := restore(globex.ID); status != http.StatusNotFound {
+		t.Errorf("cross-team restore: status %d, want 404", status)
+	}
+	var stored models.Template
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil || !stored.IsDeleted {
+		t.Fatalf("after the cross-team restore: %+v, %v", stored, err)
+	}
+	if status := restore(acme.ID); status != http.StatusOK {
+		t.Errorf("restore in the own team: status %d, want 200", status)
+	}
+}
+
+func TestSparseFieldsetResponses(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	controller := NewBaseController[models.Team](services.NewBaseService(gdb, models.Team{}), WithIncludes("Users"))
+	e := echo.New()
+	e.GET("/teams", controller.List)
+	e.GET("/teams/:id", controller.Get)
+
+	get := func(target string) (int, []byte) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code, rec.Body.Bytes()
+	}
+
+	status, body := get("/teams/" + team.ID + "?fields=name,sandboxMode")
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil || status != http.StatusOK {
+		t.Fatalf("status %d body %s", status, body)
+	}
+	if len(object) != 4 || object["id"] != team.ID || object["version"] != float64(1) || object["name"] != "Acme" || object["sandboxMode"] != false {
+		t.Errorf("got %v, want only id, version, name and sandboxMode", object)
+	}
+
+	status, body = get("/teams?fields=name&include=Users")
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil || status != http.StatusOK || len(list.Data) != 1 {
+		t.Fatalf("status %d body %s", status, body)
+	}
+	if _, ok := list.Data[0]["slug"]; ok || list.Data[0]["name"] != "Acme" {
+		t.Errorf("got %v, want id, name and users", list.Data[0])
+	}
+
+	// ❌ Unknown fields are named along with the valid ones
+	for _, target := range []string{"/teams?fields=colour", "/teams/" + team.ID + "?fields=name,colour"} {
+		status, body := get(target)
+		if status != http.StatusBadRequest || !strings.Contains(string(body), "valid fields are") {
+			t.Errorf("%s: status %d body %s, want 400 listing the valid fields", target, status, body)
+		}
+	}
+}
+
+func TestIncludesAreDeclaredAndTeamScoped(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	template := &models.Template{TeamID: acme.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	controller := NewBaseController[models.Template](services.NewBaseService(gdb, models.Template{}), WithIncludes("Team", "Team.Users"))
+	e := echo.New()
+	e.GET("/templates/:id", controller.Get, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("teamID", c.Request().Header.Get("X-Team"))
+			return next(c)
+		}
+	})
+	get := func(teamID, include string) (int, string, models.Template) {
+		req := httptest.NewRequest(http.MethodGet, "/templates/"+template.ID+"?include="+include, nil)
+		req.Header.Set("X-Team", teamID)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var loaded models.Template
+		json.Unmarshal(rec.Body.Bytes(), &loaded)
+		return rec.Code, rec.Body.String(), loaded
+	}
+
+	status, body, loaded := get(acme.ID, "team.users")
+	if status != http.StatusOK || loaded.Team == nil || len(loaded.Team.Users) != 1 {
+		t.Errorf("allowed include: status %d body %s", status, body)
+	}
+
+	// ❌ Undeclared relations are named along with the valid ones, deep ones rejected
+	for include, want := range map[string]string{
+		"Files":                 "valid includes are Team, Team.Users",
+		"Team.Invites":          "unknown include",
+		"Team.Users.Team":       "too deep",
+		"Team.Users.Team.Users": "too deep",
+	} {
+		if status, body, _ := get(acme.ID, include); status != http.StatusBadRequest || !strings.Contains(body, want) {
+			t.Errorf("%s: status %d body %s, want 400 with %q", include, status, body, want)
+		}
+	}
+
+	// 🏢 Another team's users never come along with an include
+	status, body, loaded = get(globex.ID, "Team.Users")
+	if status != http.StatusOK || loaded.Team == nil || len(loaded.Team.Users) != 0 {
+		t.Errorf("cross-team include: status %d body %s, want no users", status, body)
+	}
+}
+
+func TestListByIDs(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	var ids []string
+	for _, name := range []string{"First", "Second", "Third"} {
+		template := &models.Template{TeamID: team.ID, Name: name, Subject: name, HTML: "<p>" + name + "</p>"}
+		if err := gdb.Create(template).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, template.ID)
+	}
+	controller := NewBaseController[models.Template](services.NewBaseService(gdb, models.Template{}), WithIncludes("Team"))
+	e := echo.New()
+	e.GET("/templates", controller.List)
+	list := func(query string) (int, string, []map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates?"+query, nil))
+		var page struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		return rec.Code, rec.Body.String(), page.Data
+	}
+
+	missing := "00000000-0000-0000-0000-000000000000"
+	status, body, data := list("ids=" + ids[2] + "," + missing + "," + ids[0] + "&include=Team&fields=name")
+	if status != http.StatusOK || len(data) != 2 {
+		t.Fatalf("status %d body %s", status, body)
+	}
+	for i, want := range []string{"Third", "First"} {
+		team, _ := data[i]["team"].(map[string]interface{})
+		if data[i]["name"] != want || data[i]["subject"] != nil || team["name"] != "Acme" {
+			t.Errorf("data[%d] = %v, want %s with only its name and team", i, data[i], want)
+		}
+	}
+
+	// 🔢 The cap holds for ids longer than the query string limit
+	many := make([]string, services.MaxGetMany)
+	for i := range many {
+		many[i] = missing
+	}
+	if status, body, _ := list("ids=" + strings.Join(many, ",")); status != http.StatusOK {
+		t.Errorf("%d ids: status %d body %s, want 200", len(many), status, body)
+	}
+	for query, want := range map[string]string{
+		"ids=" + strings.Join(append(many, missing), ","): "at most 200 ids",
+		"ids=" + ids[0] + ",nope":                         "not a UUID",
+		"ids=" + ids[0] + "&name=First&sort=name":         "not name, sort",
+	} {
+		if status, body, _ := list(query); status != http.StatusBadRequest || !strings.Contains(body, want) {
+			t.Errorf("%.60s: status %d body %s, want 400 with %q", query, status, body, want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	NewBaseController[models.Team](services.NewBaseService(gdb, models.Team{})).RegisterRoutes(e.Group(""), "/teams")
+
+	request := func(method, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/teams/"+team.ID, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without conditional headers a get answers as always, tagged with the version
+	rec := request(http.MethodGet, "", nil)
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(tag, `W/"`) || !strings.Contains(rec.Body.String(), "Acme") {
+		t.Fatalf("get: status %d etag %q: %s", rec.Code, tag, rec.Body)
+	}
+	if again := request(http.MethodGet, "", nil).Header().Get("ETag"); again != tag {
+		t.Errorf("etag changed from %s to %s without a write", tag, again)
+	}
+
+	// 🏷️ A poller holding the current version gets an empty 304
+	rec = request(http.MethodGet, "", map[string]string{"If-None-Match": tag})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != tag {
+		t.Errorf("get with a matching If-None-Match: status %d etag %q body %q", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+	if rec = request(http.MethodGet, "", map[string]string{"If-None-Match": `W/"other"`}); rec.Code != http.StatusOK {
+		t.Errorf("get with another If-None-Match: status %d", rec.Code)
+	}
+
+	time.Sleep(time.Millisecond)
+	if rec = request(http.MethodPatch, `{"sandboxMode": true}`, map[string]string{"If-Match": tag}); rec.Code != http.StatusOK {
+		t.Fatalf("patch with the current If-Match: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec = request(http.MethodPatch, `{"name": "Other"}`, nil); rec.Code != http.StatusOK {
+		t.Errorf("patch without If-Match: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// ❌ Writes holding the old version fail instead of overwriting the edits since
+	for _, tc := range []struct{ method, body string }{
+		{http.MethodPut, `{"name": "Lost", "slug": "acme"}`},
+		{http.MethodPatch, `{"name": "Lost"}`},
+		{http.MethodDelete, ""},
+	} {
+		if rec := request(tc.method, tc.body, map[string]string{"If-Match": tag}); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s with a stale If-Match: status %d, want 412: %s", tc.method, rec.Code, rec.Body)
+		}
+	}
+	if rec = request(http.MethodGet, "", map[string]string{"If-None-Match": tag}); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Other") {
+		t.Errorf("get after the writes: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestStaleVersionConflicts(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	NewBaseController[models.Team](services.NewBaseService(gdb, models.Team{})).RegisterRoutes(e.Group(""), "/teams")
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/teams/"+team.ID, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		method, body string
+		status       int
+	}{
+		{http.MethodPut, `{"name": "Acme North", "version": 1}`, http.StatusOK},
+		// 🔢 Both were based on version 1, which the first update moved on
+		{http.MethodPut, `{"name": "Acme South", "version": 1}`, http.StatusConflict},
+		{http.MethodPatch, `{"name": "Acme South", "version": 1}`, http.StatusConflict},
+		{http.MethodPatch, `{"name": "Acme South", "version": 2}`, http.StatusOK},
+		{http.MethodPatch, `{"name": "Acme West", "version": "2"}`, http.StatusBadRequest},
+		// Updates without a version are not checked
+		{http.MethodPatch, `{"name": "Acme East"}`, http.StatusOK},
+	} {
+		if rec := request(tc.method, tc.body); rec.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.body, rec.Code, tc.status, rec.Body)
+		}
+	}
+
+	var stored map[string]interface{}
+	if err := json.Unmarshal(request(http.MethodGet, "").Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored["name"] != "Acme East" || stored["version"] != float64(4) {
+		t.Errorf("got name %v at version %v, want Acme East at 4", stored["name"], stored["version"])
+	}
+}
+
+func TestHookErrorStatuses(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := services.NewBaseService(gdb, models.Team{})
+	service.RegisterHook(services.HookBeforeUpdate, func(ctx context.Context, tx *gorm.DB, change services.Change[models.Team]) error {
+		switch change.New.Name {
+		case "Reserved":
+			return fmt.Errorf("%w: the name is reserved", services.ErrRejected)
+		case "Broken":
+			return errors.New("directory unreachable")
+		}
+		return nil
+	})
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	NewBaseController[models.Team](service).RegisterRoutes(e.Group(""), "/teams")
+
+	for _, tc := range []struct {
+		method, body string
+		status       int
+	}{
+		// ❌ Rejections are the caller's mistake, other hook errors ours
+		{http.MethodPatch, `{"name": "Reserved"}`, http.StatusBadRequest},
+		{http.MethodPut, `{"name": "Reserved"}`, http.StatusBadRequest},
+		{http.MethodPatch, `{"name": "Broken"}`, http.StatusInternalServerError},
+		{http.MethodPatch, `{"name": "Acme North"}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, "/teams/"+team.ID, strings.NewReader(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.body, rec.Code, tc.status, rec.Body)
+		}
+	}
+}
+
+func TestUsersAreServedAsUserResponses(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	if err := gdb.Model(user).Updates(map[string]interface{}{"provider_id": "google-123", "two_factor_secret": "sealed"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	controller := NewBaseController[models.User](services.NewBaseService(gdb, models.User{}))
+	e := echo.New()
+	e.GET("/users", controller.List)
+	e.GET("/users/:id", controller.Get)
+
+	want := []string{"createdAt", "email", "firstName", "id", "isActive", "lastName", "provider", "role", "teamId", "twoFactorEnabled", "updatedAt"}
+	keys := func(object map[string]interface{}) []string {
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/"+user.ID, nil))
+	var object map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &object); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d body %s", rec.Code, rec.Body)
+	}
+	// 🙈 Columns such as the provider ID and version stay out of the response
+	if got := keys(object); !slices.Equal(got, want) {
+		t.Errorf("Get returned %v, want %v", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK || len(list.Data) != 1 {
+		t.Fatalf("status %d body %s", rec.Code, rec.Body)
+	}
+	if got := keys(list.Data[0]); !slices.Equal(got, want) {
+		t.Errorf("List returned %v, want %v", got, want)
+	}
+}
+
+func TestListResolvesClientFieldNames(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	for _, name := range []string{"a.pdf", "b.pdf", "c.png"} {
+		file := &models.File{TeamID: team.ID, Path: name, Name: name, Size: 1, Type: "application/pdf"}
+		if err := gdb.Create(file).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller := NewBaseController[models.File](services.NewBaseService(gdb, models.File{}))
+	e := echo.New()
+	e.GET("/files", controller.List)
+
+	list := func(query string) (int, []string) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files?"+query, nil))
+		var body struct {
+			Data []models.File `json:"data"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var names []string
+		for _, file := range body.Data {
+			names = append(names, file.Name)
+		}
+		return rec.Code, names
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		names  []string
+	}{
+		{"sort=name&order=desc", http.StatusOK, []string{"c.png", "b.pdf", "a.pdf"}},
+		{"name=b.pdf", http.StatusOK, []string{"b.pdf"}},
+		{"teamId=" + team.ID + "&sort=createdAt,name", http.StatusOK, []string{"a.pdf", "b.pdf", "c.png"}},
+		{"team_id=" + team.ID + "&sort=name&order=DESC", http.StatusOK, []string{"c.png", "b.pdf", "a.pdf"}},
+		// ❌ Unknown names, computed fields and bad orders are refused instead of ignored
+		{"colour=red", http.StatusBadRequest, nil},
+		{"Name=b.pdf", http.StatusBadRequest, nil},
+		{"signedUrl=x", http.StatusBadRequest, nil},
+		{"sort=signedUrl", http.StatusBadRequest, nil},
+		{"sort=size&order=sideways", http.StatusBadRequest, nil},
+		{"exclude=colour", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		status, names := list(tt.query)
+		if status != tt.status || !slices.Equal(names, tt.names) {
+			t.Errorf("%s: status %d with %v, want %d with %v", tt.query, status, names, tt.status, tt.names)
+		}
+	}
+}
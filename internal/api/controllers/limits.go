@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"be0/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// QueryLimits bound what a List or Get query string may ask for, so pathological
+// requests are rejected before anything is sent to the database
+type QueryLimits struct {
+	MaxQueryLength int // bytes of the raw query string
+	MaxFilters     int // field filter values
+	MaxIncludes    int // relationships to preload
+	MaxSortFields  int
+	MaxExcludes    int
+}
+
+// DefaultQueryLimits apply until SetQueryLimits is called
+var DefaultQueryLimits = QueryLimits{
+	MaxQueryLength: 4096,
+	MaxFilters:     20,
+	MaxIncludes:    10,
+	MaxSortFields:  5,
+	MaxExcludes:    50,
+}
+
+var queryLimits atomic.Pointer[QueryLimits]
+
+// maxIDsLength is the length of an ids parameter of services.MaxGetMany UUIDs, commas encoded
+var maxIDsLength = len("ids=") + services.MaxGetMany*len("00000000-0000-0000-0000-000000000000%2C")
+
+func init() {
+	limits := DefaultQueryLimits
+	queryLimits.Store(&limits)
+}
+
+// SetQueryLimits replaces the limits of every controller, zero values keep the default
+func SetQueryLimits(limits QueryLimits) {
+	if limits.MaxQueryLength <= 0 {
+		limits.MaxQueryLength = DefaultQueryLimits.MaxQueryLength
+	}
+	if limits.MaxFilters <= 0 {
+		limits.MaxFilters = DefaultQueryLimits.MaxFilters
+	}
+	if limits.MaxIncludes <= 0 {
+		limits.MaxIncludes = DefaultQueryLimits.MaxIncludes
+	}
+	if limits.MaxSortFields <= 0 {
+		limits.MaxSortFields = DefaultQueryLimits.MaxSortFields
+	}
+	if limits.MaxExcludes <= 0 {
+		limits.MaxExcludes = DefaultQueryLimits.MaxExcludes
+	}
+	queryLimits.Store(&limits)
+}
+
+// checkQueryLimits rejects query strings that exceed the configured limits
+func checkQueryLimits(ctx echo.Context) error {
+	limits := queryLimits.Load()
+
+	// 🔢 ids is bounded by services.MaxGetMany instead, 200 UUIDs are longer than the default limit
+	length, idsLength := 0, 0
+	for _, pair := range strings.Split(ctx.Request().URL.RawQuery, "&") {
+		if strings.HasPrefix(pair, "ids=") {
+			idsLength += len(pair)
+		} else {
+			length += len(pair) + 1
+		}
+	}
+	if length > limits.MaxQueryLength+1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "query string is too long, at most "+strconv.Itoa(limits.MaxQueryLength)+" bytes are allowed")
+	}
+	if idsLength > maxIDsLength {
+		return tooMany("ids", services.MaxGetMany)
+	}
+
+	filters := 0
+	for key, values := range ctx.QueryParams() {
+		if !listParams[key] {
+			filters += len(values)
+		}
+	}
+	if filters > limits.MaxFilters {
+		return tooMany("filters", limits.MaxFilters)
+	}
+
+	for _, param := range []struct {
+		name  string
+		limit int
+	}{
+		{"include", limits.MaxIncludes},
+		{"sort", limits.MaxSortFields},
+		{"exclude", limits.MaxExcludes},
+	} {
+		if value := ctx.QueryParam(param.name); value != "" && strings.Count(value, ",")+1 > param.limit {
+			return tooMany(param.name+" fields", param.limit)
+		}
+	}
+
+	return nil
+}
+
+func tooMany(what string, limit int) error {
+	return echo.NewHTTPError(http.StatusBadRequest, "too many "+what+", at most "+strconv.Itoa(limit)+" are allowed")
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// recordingService counts the calls that would reach the database
+type recordingService struct {
+	services.BaseService[models.Template]
+	calls int
+}
+
+func (s *recordingService) Get(context.Context, string, ...string) (*models.Template, error) {
+	s.calls++
+	return &models.Template{}, nil
+}
+
+func (s *recordingService) GetMany(context.Context, []string, ...string) ([]models.Template, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *recordingService) List(context.Context, int, int, map[string]interface{}, map[string]bool, []string, string, ...string) ([]models.Template, int64, error) {
+	s.calls++
+	return nil, 0, nil
+}
+
+func newRecordingController(t testing.TB) (*BaseController[models.Template], *recordingService) {
+	t.Helper()
+	service := &recordingService{BaseService: services.NewBaseService(testutil.NewDB(t), models.Template{})}
+	return NewBaseController[models.Template](service, WithIncludes("Team")), service
+}
+
+// useQueryLimits applies limits for the test and restores the defaults after it
+func useQueryLimits(t testing.TB, limits QueryLimits) {
+	t.Helper()
+	SetQueryLimits(limits)
+	t.Cleanup(func() { SetQueryLimits(DefaultQueryLimits) })
+}
+
+// call runs the List or Get handler with a raw query string and returns the status
+func call(t testing.TB, handler echo.HandlerFunc, rawQuery string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/templates", nil)
+	req.URL.RawQuery = rawQuery
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("00000000-0000-0000-0000-000000000001")
+
+	err := handler(c)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code
+}
+
+// repeat joins n parameters built by param
+func repeat(n int, param func(i int) string) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = param(i)
+	}
+	return strings.Join(params, "&")
+}
+
+// fieldList joins n copies of field with commas
+func fieldList(n int, field string) string {
+	return strings.TrimSuffix(strings.Repeat(field+",", n), ",")
+}
+
+func TestPathologicalQueriesNeverReachTheDatabase(t *testing.T) {
+	// The length limit is raised so each count limit is hit on its own
+	useQueryLimits(t, QueryLimits{MaxQueryLength: 1 << 20})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "thousands of filters", query: repeat(5000, func(int) string { return "name=x" })},
+		{name: "thousands of distinct filters", query: repeat(5000, func(i int) string { return "f" + strconv.Itoa(i) + "=x" })},
+		{name: "one filter over the limit", query: repeat(DefaultQueryLimits.MaxFilters+1, func(int) string { return "name=x" })},
+		{name: "thousands of includes", query: "include=" + fieldList(5000, "Team")},
+		{name: "thousands of sort fields", query: "sort=" + fieldList(5000, "name")},
+		{name: "thousands of excluded fields", query: "exclude=" + fieldList(5000, "html")},
+		{name: "empty sort fields", query: "sort=" + strings.Repeat(",", DefaultQueryLimits.MaxSortFields)},
+		{name: "encoded separators", query: "include=" + url.QueryEscape(fieldList(DefaultQueryLimits.MaxIncludes+1, "Team"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, service := newRecordingController(t)
+			if status := call(t, controller.List, tt.query); status != http.StatusBadRequest {
+				t.Errorf("List: status %d, want 400", status)
+			}
+			if status := call(t, controller.Get, tt.query); status != http.StatusBadRequest {
+				t.Errorf("Get: status %d, want 400", status)
+			}
+			if service.calls != 0 {
+				t.Errorf("%d calls reached the service, want none", service.calls)
+			}
+		})
+	}
+}
+
+func TestOverlongQueryStringIsRejected(t *testing.T) {
+	useQueryLimits(t, QueryLimits{MaxQueryLength: 64})
+	controller, service := newRecordingController(t)
+
+	if status := call(t, controller.List, "q="+strings.Repeat("a", 63)); status != http.StatusBadRequest {
+		t.Errorf("65 bytes: status %d, want 400", status)
+	}
+	if service.calls != 0 {
+		t.Errorf("%d calls reached the service, want none", service.calls)
+	}
+	if status := call(t, controller.Get, "include=Team"); status != http.StatusOK {
+		t.Errorf("short query: status %d, want 200", status)
+	}
+}
+
+func TestQueriesWithinTheLimitsAreServed(t *testing.T) {
+	controller, service := newRecordingController(t)
+
+	query := strings.Join([]string{
+		repeat(DefaultQueryLimits.MaxFilters, func(int) string { return "name=x" }),
+		"sort=" + fieldList(DefaultQueryLimits.MaxSortFields, "name"),
+		"exclude=" + fieldList(DefaultQueryLimits.MaxExcludes, "html"),
+		"include=" + fieldList(DefaultQueryLimits.MaxIncludes, "Team"),
+		"page=2&limit=5&order=desc",
+	}, "&")
+	if status := call(t, controller.List, query); status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	if service.calls != 1 {
+		t.Errorf("%d service calls, want 1", service.calls)
+	}
+}
+
+// FuzzQueryLimits throws arbitrary query strings at List and Get: neither may panic, and a
+// query over a limit never reaches the service
+func FuzzQueryLimits(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"name=x&page=1",
+		"sort=,,,,,,&order=sideways",
+		"include=" + fieldList(20, "Team"),
+		repeat(30, func(i int) string { return "f" + strconv.Itoa(i) + "=%zz" }),
+		"%%%&&&===;;;",
+		"q=" + strings.Repeat("%00", 2000),
+		"ids=" + strings.Repeat("00000000-0000-0000-0000-000000000000,", 300),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		controller, service := newRecordingController(t)
+		req := httptest.NewRequest(http.MethodGet, "/templates", nil)
+		req.URL.RawQuery = rawQuery
+		overLimit := checkQueryLimits(echo.New().NewContext(req, httptest.NewRecorder())) != nil
+
+		for _, handler := range []echo.HandlerFunc{controller.List, controller.Get} {
+			status := call(t, handler, rawQuery)
+			if overLimit && status != http.StatusBadRequest {
+				t.Errorf("status %d for a query over the limits, want 400", status)
+			}
+		}
+		if overLimit && service.calls != 0 {
+			t.Errorf("%d calls reached the service for a query over the limits", service.calls)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/api/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// callStrict runs handler with X-Strict-Params: true and returns the error it answered with
+func callStrict(handler echo.HandlerFunc, rawQuery string) *echo.HTTPError {
+	req := httptest.NewRequest(http.MethodGet, "/templates", nil)
+	req.URL.RawQuery = rawQuery
+	req.Header.Set(middleware.HeaderStrictParams, "true")
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("00000000-0000-0000-0000-000000000001")
+
+	var httpErr *echo.HTTPError
+	if errors.As(handler(c), &httpErr) {
+		return httpErr
+	}
+	return nil
+}
+
+func TestStrictParamsSuggestKnownNames(t *testing.T) {
+	controller, service := newRecordingController(t)
+
+	tests := []struct {
+		handler    echo.HandlerFunc
+		query      string
+		suggestion string
+	}{
+		{controller.List, "inlcude=Team", "include"},
+		{controller.List, "srot=name", "sort"},
+		{controller.List, "nmae=welcome", "name"},
+		{controller.Get, "inlcude=Team", "include"},
+	}
+	for _, tt := range tests {
+		httpErr := callStrict(tt.handler, tt.query)
+		if httpErr == nil || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %v, want a 400", tt.query, httpErr)
+			continue
+		}
+		suggestions := httpErr.Message.(map[string]interface{})["suggestions"].(map[string]string)
+		for _, suggestion := range suggestions {
+			if suggestion != tt.suggestion {
+				t.Errorf("%s: suggested %q, want %q", tt.query, suggestion, tt.suggestion)
+			}
+		}
+	}
+	if service.calls != 0 {
+		t.Errorf("%d calls reached the service, want none", service.calls)
+	}
+
+	// Field filters, list parameters and column names are all known
+	if httpErr := callStrict(controller.List, "name=welcome&created_at=2026-01-01&page=1&sort=name&order=desc"); httpErr != nil {
+		t.Errorf("known parameters rejected: %v", httpErr)
+	}
+	if status := call(t, controller.Get, "inlcude=Team"); status != http.StatusOK {
+		t.Errorf("lenient Get answered %d, want 200", status)
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/handlers"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/testutil"
+	"be0/internal/testutil/containers"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibiken/asynq"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(containers.Run(m))
+}
+
+// memoryStorage keeps uploads in memory in place of S3
+type memoryStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memoryStorage) UploadFile(_ context.Context, file []byte, filename string, _ types.ObjectCannedACL, _ string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[filename] = file
+	return "https://storage.test/" + filename, nil
+}
+
+func (s *memoryStorage) GetSignedURL(_ context.Context, path string, _ time.Duration) (string, error) {
+	return "https://storage.test/" + path + "?signature=test", nil
+}
+
+func (s *memoryStorage) SetObjectACL(context.Context, string, types.ObjectCannedACL) error {
+	return nil
+}
+
+func (s *memoryStorage) EffectiveACL(acl types.ObjectCannedACL) types.ObjectCannedACL {
+	return acl
+}
+
+// flowServer builds the API server on the container Postgres and Redis
+func flowServer(t *testing.T) (*Server, *models.User, config.RedisConfig) {
+	t.Helper()
+	gdb := containers.Postgres(t)
+	redisConfig := containers.Redis(t)
+	testutil.UseDB(t, gdb)
+	testutil.UseJWTSecret(t)
+
+	cfg := testutil.UseConfig(t)
+	cfg.JWT.Secret = testutil.JWTSecret
+	cfg.Redis = redisConfig
+
+	storage := &memoryStorage{objects: map[string][]byte{}}
+	handlers.RegisterStorageHandler(storage)
+	models.RegisterFileURLGenerator(storage)
+	t.Cleanup(func() {
+		handlers.RegisterStorageHandler(nil)
+		models.RegisterFileURLGenerator(nil)
+	})
+
+	redisClient := tasks.NewRedisClient(redisConfig)
+	t.Cleanup(func() { redisClient.Close() })
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	return NewServer(cfg, gdb, redisClient), user, redisConfig
+}
+
+// call sends a request through the whole middleware stack and decodes the JSON response
+func call(t *testing.T, s *Server, method, path, token, contentType string, body io.Reader) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+
+	var decoded map[string]interface{}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: response %q is not a JSON object: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code, decoded
+}
+
+func callJSON(t *testing.T, s *Server, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	return call(t, s, method, path, token, "application/json", reader)
+}
+
+func TestLoginCRUDUploadFlow(t *testing.T) {
+	s, user, redisConfig := flowServer(t)
+
+	// 🔑 Sign in
+	status, body := callJSON(t, s, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": user.Email, "password": testutil.Password,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d %v", status, body)
+	}
+	token, _ := body["token"].(string)
+	if token == "" {
+		t.Fatalf("login: no token in %v", body)
+	}
+
+	// 📝 Create, read, update, list and delete a template
+	template := map[string]interface{}{
+		"teamId":    user.TeamID,
+		"name":      "Welcome",
+		"subject":   "Hello",
+		"html":      "<p>Hello {{name}}</p>",
+		"variables": []string{"name"},
+	}
+	status, body = callJSON(t, s, http.MethodPost, "/api/v1/templates", token, template)
+	if status != http.StatusCreated {
+		t.Fatalf("create template: status %d %v", status, body)
+	}
+	id, _ := body["id"].(string)
+	if id == "" {
+		t.Fatalf("create template: no id in %v", body)
+	}
+
+	template["subject"] = "Welcome aboard"
+	if status, body = callJSON(t, s, http.MethodPut, "/api/v1/templates/"+id, token, template); status != http.StatusOK {
+		t.Fatalf("update template: status %d %v", status, body)
+	}
+	status, body = callJSON(t, s, http.MethodGet, "/api/v1/templates/"+id, token, nil)
+	if status != http.StatusOK || body["subject"] != "Welcome aboard" {
+		t.Fatalf("get template: status %d %v, want the updated subject", status, body)
+	}
+	status, body = callJSON(t, s, http.MethodGet, "/api/v1/templates", token, nil)
+	if status != http.StatusOK || body["total"] != float64(1) {
+		t.Fatalf("list templates: status %d %v, want 1 template", status, body)
+	}
+	if status, body = callJSON(t, s, http.MethodDelete, "/api/v1/templates/"+id, token, nil); status != http.StatusNoContent {
+		t.Fatalf("delete template: status %d %v", status, body)
+	}
+	if status, _ = callJSON(t, s, http.MethodGet, "/api/v1/templates/"+id, token, nil); status != http.StatusNotFound {
+		t.Fatalf("get deleted template: status %d, want 404", status)
+	}
+
+	// 📤 Upload a document
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="report.pdf"`)
+	header.Set("Content-Type", "application/pdf")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("%PDF-1.4 report"))
+	writer.Close()
+
+	status, body = call(t, s, http.MethodPost, "/api/v1/files/upload", token, writer.FormDataContentType(), &form)
+	if status != http.StatusOK {
+		t.Fatalf("upload: status %d %v", status, body)
+	}
+	fileID, _ := body["file"].(string)
+	if fileID == "" {
+		t.Fatalf("upload: no file id in %v", body)
+	}
+
+	status, body = callJSON(t, s, http.MethodGet, "/api/v1/files/"+fileID, token, nil)
+	if status != http.StatusOK || body["name"] != "report.pdf" || body["signedUrl"] == nil {
+		t.Fatalf("get file: status %d %v, want report.pdf with a signed URL", status, body)
+	}
+
+	// The document is queued for text extraction on the real Redis
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisConfig.Addr, DB: redisConfig.DB})
+	defer inspector.Close()
+	pending, err := inspector.ListPendingTasks(tasks.QueueLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Type != tasks.TaskTypeFileExtract {
+		t.Errorf("pending tasks %v, want one %s", pending, tasks.TaskTypeFileExtract)
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"be0/internal/utils/crypto"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JWKS is a JSON Web Key Set (RFC 7517)
+type JWKS struct {
+	Keys []crypto.JWK `json:"keys"`
+}
+
+// serveJWKS publishes the public key of RS256 access tokens, so other services can verify them offline
+// @Summary JSON Web Key Set
+// @Description The public key verifying RS256 access tokens, matched by the kid header of a token. Tokens are RS256 when JWT_ALGORITHM=RS256.
+// @Produce json
+// @Success 200 {object} JWKS
+// @Failure 404 {object} map[string]string "No key pair configured"
+// @Router /.well-known/jwks.json [get]
+func (s *Server) serveJWKS(c echo.Context) error {
+	key, err := crypto.PublicJWK()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "no signing key configured")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	return c.JSON(http.StatusOK, JWKS{Keys: []crypto.JWK{key}})
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"be0/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdmissionConfig configures admission control under overload
+type AdmissionConfig struct {
+	// MaxInFlight is how many requests are served at once, the rest wait in their team's queue
+	MaxInFlight int
+	// QueueSize is how many requests a single team can have waiting
+	QueueSize int
+	// ReadWait and WriteWait are how long a read (GET, HEAD, OPTIONS) or write may wait
+	// for a slot before it is shed with 429
+	ReadWait  time.Duration
+	WriteWait time.Duration
+}
+
+// admissionWaiter is a queued request, ready is closed once it holds a slot
+type admissionWaiter struct {
+	ready   chan struct{}
+	granted bool
+	elem    *list.Element
+}
+
+// admissionQueue holds the waiting requests of one team
+type admissionQueue struct {
+	team    string
+	waiters *list.List
+	elem    *list.Element // position in the round-robin ring while the queue has waiters
+}
+
+// Admission limits concurrent requests. When all slots are taken, requests wait in a
+// bounded per-team queue and freed slots go to teams in turn, so one busy team
+// cannot starve the others.
+type Admission struct {
+	cfg      AdmissionConfig
+	mu       sync.Mutex
+	inFlight int
+	queues   map[string]*admissionQueue
+	ring     *list.List // teams with waiters, served round-robin from the front
+}
+
+// NewAdmission creates an admission controller
+func NewAdmission(cfg AdmissionConfig) *Admission {
+	return &Admission{
+		cfg:    cfg,
+		queues: map[string]*admissionQueue{},
+		ring:   list.New(),
+	}
+}
+
+// Middleware admits, queues or sheds requests. It must run after authentication,
+// requests without a team share one queue.
+func (a *Admission) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			class, wait := "write", a.cfg.WriteWait
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				class, wait = "read", a.cfg.ReadWait
+			}
+
+			team, _ := c.Get("teamID").(string)
+
+			start := time.Now()
+			admitted, reason := a.acquire(c, team, wait)
+			metrics.ObserveAdmissionWait(class, time.Since(start))
+			if !admitted {
+				metrics.ObserveAdmissionShed(class, reason)
+				c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "Server is busy, please retry shortly",
+					"code":  "overloaded",
+				})
+			}
+			defer a.release()
+
+			return next(c)
+		}
+	}
+}
+
+// acquire takes a slot, waiting up to wait for one. The reason is set when the request is shed.
+func (a *Admission) acquire(c echo.Context, team string, wait time.Duration) (bool, string) {
+	a.mu.Lock()
+	if a.inFlight < a.cfg.MaxInFlight && a.ring.Len() == 0 {
+		a.inFlight++
+		a.mu.Unlock()
+		return true, ""
+	}
+	if wait <= 0 {
+		a.mu.Unlock()
+		return false, "no_budget"
+	}
+
+	queue := a.queues[team]
+	if queue == nil {
+		queue = &admissionQueue{team: team, waiters: list.New()}
+		a.queues[team] = queue
+	}
+	if queue.waiters.Len() >= a.cfg.QueueSize {
+		a.mu.Unlock()
+		return false, "queue_full"
+	}
+
+	waiter := &admissionWaiter{ready: make(chan struct{})}
+	waiter.elem = queue.waiters.PushBack(waiter)
+	if queue.elem == nil {
+		queue.elem = a.ring.PushBack(queue)
+	}
+	metrics.SetAdmissionQueued(a.queuedLocked())
+	a.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	reason := "timeout"
+	select {
+	case <-waiter.ready:
+		return true, ""
+	case <-timer.C:
+	case <-c.Request().Context().Done():
+		reason = "canceled"
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The slot may have been handed over while the timer fired
+	if waiter.granted {
+		return true, ""
+	}
+	a.removeLocked(queue, waiter)
+	return false, reason
+}
+
+// release frees a slot and hands it to the next team in turn
+func (a *Admission) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inFlight--
+	for a.inFlight < a.cfg.MaxInFlight && a.ring.Len() > 0 {
+		queue := a.ring.Front().Value.(*admissionQueue)
+		waiter := queue.waiters.Front().Value.(*admissionWaiter)
+		a.removeLocked(queue, waiter)
+
+		// 🔄 The team goes to the back of the ring if it still has waiters
+		if queue.elem != nil {
+			a.ring.MoveToBack(queue.elem)
+		}
+
+		waiter.granted = true
+		a.inFlight++
+		close(waiter.ready)
+	}
+}
+
+// removeLocked takes a waiter out of its team queue, dropping the queue once empty
+func (a *Admission) removeLocked(queue *admissionQueue, waiter *admissionWaiter) {
+	queue.waiters.Remove(waiter.elem)
+	if queue.waiters.Len() == 0 {
+		a.ring.Remove(queue.elem)
+		queue.elem = nil
+		delete(a.queues, queue.team)
+	}
+	metrics.SetAdmissionQueued(a.queuedLocked())
+}
+
+// queuedLocked counts waiting requests across teams
+func (a *Admission) queuedLocked() int {
+	queued := 0
+	for _, queue := range a.queues {
+		queued += queue.waiters.Len()
+	}
+	return queued
+}
+
+// retryAfterSeconds suggests a retry delay of at least a second
+func retryAfterSeconds(wait time.Duration) int {
+	if seconds := int(wait.Round(time.Second) / time.Second); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
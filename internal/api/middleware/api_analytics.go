@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// APIRequestLogger writes the request logs of API keys in the background, see batchWriter
+type APIRequestLogger struct {
+	*batchWriter[models.APIRequestLog]
+	sampleRate float64
+}
+
+// NewAPIRequestLogger starts a writer buffering up to bufferSize logs, stop it with Close.
+// Successful requests are recorded at sampleRate, failed ones always.
+func NewAPIRequestLogger(db *gorm.DB, bufferSize int, sampleRate float64) *APIRequestLogger {
+	return &APIRequestLogger{
+		batchWriter: newBatchWriter[models.APIRequestLog](db, "API request log", bufferSize),
+		sampleRate:  sampleRate,
+	}
+}
+
+// Record queues a log without blocking, successful requests are sampled
+func (l *APIRequestLogger) Record(entry models.APIRequestLog) {
+	if entry.StatusCode < http.StatusBadRequest && rand.Float64() >= l.sampleRate {
+		return
+	}
+	l.record(entry)
+}
+
+// APIAnalytics records the route, status and latency of every request made with an API key
+// once the handler completes. Must be registered before the auth middleware of the routes,
+// which identifies the key.
+func APIAnalytics(logger *APIRequestLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			apiKeyID, _ := c.Get("apiKeyID").(string)
+			teamID := GetTeamID(c)
+			if apiKeyID == "" || teamID == "" {
+				return err
+			}
+
+			// 🔢 Route patterns keep raw IDs out of the analytics
+			route, ok := routePath(c)
+			if !ok {
+				route = "unmatched"
+			}
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				// The error handler has not answered yet
+				status = http.StatusInternalServerError
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				}
+			}
+
+			requestedAt := start.UTC()
+			logger.Record(models.APIRequestLog{
+				APIKeyID:      apiKeyID,
+				TeamID:        teamID,
+				Method:        c.Request().Method,
+				Route:         route,
+				StatusCode:    status,
+				LatencyBucket: models.APIRequestLatencyBucket(time.Since(start)),
+				RequestedAt:   requestedAt,
+				Day:           requestedAt.Format(time.DateOnly),
+			})
+			return err
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAPIAnalyticsRecordsAPIKeyRequests(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+
+	// Successful requests are never sampled, failed ones always recorded
+	logger := NewAPIRequestLogger(gdb, 16, 0)
+	e := echo.New()
+	e.Use(APIAnalytics(logger))
+	withKey := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("apiKeyID", "0b6a2c1e-8f5d-4d7e-9a43-1c2b3d4e5f60")
+			c.Set("teamID", team.ID)
+			return next(c)
+		}
+	}
+	withUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("userID", "user")
+			c.Set("teamID", team.ID)
+			return next(c)
+		}
+	}
+	e.GET("/api/v1/files/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		return c.NoContent(http.StatusOK)
+	}, withKey)
+	e.GET("/api/v1/profile", func(c echo.Context) error { return c.NoContent(http.StatusBadRequest) }, withUser)
+
+	for _, target := range []string{"/api/v1/files/present", "/api/v1/files/missing", "/api/v1/profile"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	logger.Close()
+
+	var logs []models.APIRequestLog
+	if err := gdb.Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("recorded %d requests, want the failed API key request only", len(logs))
+	}
+	got := logs[0]
+	if got.Route != "/api/v1/files/:id" || got.StatusCode != http.StatusNotFound || got.TeamID != team.ID {
+		t.Errorf("recorded %+v, want the route pattern with 404", got)
+	}
+	if got.LatencyBucket != models.APIRequestLatencyBuckets[0].Name || got.Day != got.RequestedAt.Format("2006-01-02") {
+		t.Errorf("latency bucket %q and day %q of %v", got.LatencyBucket, got.Day, got.RequestedAt)
+	}
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"be0/internal/db"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyCacheTTL is how long a resolved key is trusted before it is looked up again,
+	// which bounds how long a deleted key keeps working on other instances
+	apiKeyCacheTTL = 30 * time.Second
+	// apiKeyCacheSize caps the cache, unknown keys are cached too so guessing does not reach the database
+	apiKeyCacheSize = 1024
+	// apiKeyTouchInterval throttles last_used_at updates of a key
+	apiKeyTouchInterval = time.Minute
+)
+
+type apiKeyCacheEntry struct {
+	info      APIKeyInfo
+	found     bool
+	cachedAt  time.Time
+	touchedAt time.Time
+}
+
+var apiKeyCache = struct {
+	sync.Mutex
+	entries map[string]*apiKeyCacheEntry // by key hash
+}{entries: make(map[string]*apiKeyCacheEntry)}
+
+// lookupAPIKey resolves a raw key through the cache, falling back to the database. Keys of
+// deleted teams are not found. Use of a found key is recorded in the background.
+func lookupAPIKey(key string) (APIKeyInfo, bool, error) {
+	hash := models.HashAPIKey(key)
+	now := time.Now()
+
+	apiKeyCache.Lock()
+	entry, ok := apiKeyCache.entries[hash]
+	apiKeyCache.Unlock()
+
+	if !ok || now.Sub(entry.cachedAt) > apiKeyCacheTTL {
+		var apiKey models.APIKey
+		err := db.DB.Joins("JOIN teams ON teams.id = api_keys.team_id AND teams.is_deleted = false").
+			Where("api_keys.key_hash = ? AND api_keys.is_deleted = false", hash).
+			First(&apiKey).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return APIKeyInfo{}, false, err
+		}
+
+		entry = &apiKeyCacheEntry{found: err == nil, cachedAt: now}
+		if entry.found {
+			entry.info = APIKeyInfo{ID: apiKey.ID, TeamID: apiKey.TeamID, Permissions: apiKey.Permissions}
+			if apiKey.ExpiresAt != nil {
+				entry.info.ExpiresAt = *apiKey.ExpiresAt
+			}
+			if apiKey.LastUsedAt != nil {
+				entry.touchedAt = *apiKey.LastUsedAt
+			}
+		}
+
+		apiKeyCache.Lock()
+		if len(apiKeyCache.entries) >= apiKeyCacheSize {
+			apiKeyCache.entries = make(map[string]*apiKeyCacheEntry)
+		}
+		apiKeyCache.entries[hash] = entry
+		apiKeyCache.Unlock()
+	}
+
+	if !entry.found || (!entry.info.ExpiresAt.IsZero() && entry.info.ExpiresAt.Before(now)) {
+		return APIKeyInfo{}, false, nil
+	}
+
+	// 🕒 Reads must not wait on a write, last_used_at is updated at most once a minute per instance
+	apiKeyCache.Lock()
+	touch := now.Sub(entry.touchedAt) > apiKeyTouchInterval
+	if touch {
+		entry.touchedAt = now
+	}
+	apiKeyCache.Unlock()
+	if touch {
+		go touchAPIKey(entry.info.ID, now.UTC())
+	}
+
+	return entry.info, true, nil
+}
+
+func touchAPIKey(id string, usedAt time.Time) {
+	if err := db.DB.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error; err != nil {
+		log.Error("Failed to record API key use: %v", err)
+	}
+}
+
+// InvalidateAPIKey drops a key from this instance's cache, e.g. once it was deleted
+func InvalidateAPIKey(id string) {
+	apiKeyCache.Lock()
+	defer apiKeyCache.Unlock()
+	for hash, entry := range apiKeyCache.entries {
+		if entry.info.ID == id {
+			delete(apiKeyCache.entries, hash)
+		}
+	}
+}
+
+// APIKeyAllows reports whether API key permissions allow an HTTP method
+func APIKeyAllows(permissions []string, method string) bool {
+	if slices.Contains(permissions, models.APIKeyPermissionAdmin) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return slices.Contains(permissions, models.APIKeyPermissionRead)
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return slices.Contains(permissions, models.APIKeyPermissionWrite)
+	case http.MethodDelete:
+		return slices.Contains(permissions, models.APIKeyPermissionDelete)
+	default:
+		return false
+	}
+}
+
+// APIKeyScopes maps API key permissions to the "resource:action" scopes RequirePermissions
+// checks. Keys are limited by action on every resource of their team.
+func APIKeyScopes(permissions []string) []string {
+	var scopes []string
+	for _, permission := range permissions {
+		switch permission {
+		case models.APIKeyPermissionAdmin:
+			scopes = append(scopes, scopeWildcard+":"+scopeWildcard)
+		case models.APIKeyPermissionRead:
+			scopes = append(scopes, scopeWildcard+":"+ActionRead)
+		case models.APIKeyPermissionWrite:
+			scopes = append(scopes, scopeWildcard+":"+ActionCreate, scopeWildcard+":"+ActionUpdate)
+		case models.APIKeyPermissionDelete:
+			scopes = append(scopes, scopeWildcard+":"+ActionDelete)
+		}
+	}
+	return scopes
+}
+
+func (m *AuthMiddleware) validateAPIKey(c echo.Context, key string, next echo.HandlerFunc) error {
+	info, ok, err := lookupAPIKey(key)
+	if err != nil {
+		requestLog(c).Error("Failed to look up API key: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify API key")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+	}
+
+	if !APIKeyAllows(info.Permissions, c.Request().Method) {
+		return denyPermission(c, permissionDenial{
+			APIKeyID:      info.ID,
+			TeamID:        info.TeamID,
+			RequiredScope: scopeWildcard + ":" + GetRequiredPermissionForMethod(c.Request().Method),
+			YourScopes:    APIKeyScopes(info.Permissions),
+		})
+	}
+
+	if err := injectTeamID(c, info.TeamID); err != nil {
+		return err
+	}
+
+	// Set context values, API keys act for their team without a user
+	c.Set("teamID", info.TeamID)
+	c.Set("permissions", info.Permissions)
+	c.Set("scopes", APIKeyScopes(info.Permissions))
+	c.Set("apiKeyID", info.ID)
+	c.Set("isAPIKey", true)
+	addLogFields(c, logger.Fields{"team_id": info.TeamID, "api_key_id": info.ID})
+
+	return next(c)
+}
@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// maxAuditBodySize is the largest JSON body stored, larger bodies are only hashed
+const maxAuditBodySize = 16 << 10
+
+// auditRedactedFields are redacted from stored bodies, matching any field whose name contains one
+var auditRedactedFields = []string{"password", "token", "secret"}
+
+// AuditLogger writes audit logs in the background, see batchWriter
+type AuditLogger struct {
+	*batchWriter[models.AuditLog]
+}
+
+// NewAuditLogger starts a writer buffering up to bufferSize records, stop it with Close
+func NewAuditLogger(db *gorm.DB, bufferSize int) *AuditLogger {
+	return &AuditLogger{newBatchWriter[models.AuditLog](db, "Audit log", bufferSize)}
+}
+
+// Record queues a record without blocking, it is dropped when the buffer is full
+func (l *AuditLogger) Record(record models.AuditLog) {
+	l.record(record)
+}
+
+// AuditLog records every POST, PUT, PATCH and DELETE of a signed-in user or API key once the
+// handler completes, including denied and failed requests. Must be registered before the
+// auth middleware of the routes, which identifies the caller.
+func AuditLog(logger *AuditLogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			switch req.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				return next(c)
+			}
+
+			start := time.Now()
+			body := newAuditBody(req)
+
+			err := next(c)
+
+			// 🕶️ Requests of unknown callers, such as sign-ins, are not audited
+			teamID := GetTeamID(c)
+			if teamID == "" {
+				return err
+			}
+
+			path, _ := routePath(c)
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				// The error handler has not answered yet
+				status = http.StatusInternalServerError
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				}
+			}
+
+			record := models.AuditLog{
+				TeamID:     teamID,
+				Method:     req.Method,
+				Path:       req.URL.Path,
+				Resource:   resourceOfPath(path),
+				ResourceID: auditResourceID(c),
+				StatusCode: status,
+				IPAddress:  utils.GetIPAddress(req),
+				UserAgent:  req.UserAgent(),
+				LatencyMs:  time.Since(start).Milliseconds(),
+			}
+			if userID := GetUserID(c); userID != "" {
+				record.UserID = &userID
+			}
+			if apiKeyID, ok := c.Get("apiKeyID").(string); ok && apiKeyID != "" {
+				record.APIKeyID = &apiKeyID
+			}
+			record.BodyHash, record.Body = body.finish()
+
+			logger.Record(record)
+			return err
+		}
+	}
+}
+
+// auditResourceID is the id path parameter, or the last parameter of routes naming the resource otherwise
+func auditResourceID(c echo.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	if values := c.ParamValues(); len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+// auditBody hashes a request body as the handler reads it, JSON bodies are kept for storage
+type auditBody struct {
+	hash   hash.Hash
+	size   int64
+	stream io.Reader // the body of requests other than JSON, once hashed
+	json   []byte
+}
+
+func (b *auditBody) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	return b.hash.Write(p)
+}
+
+func newAuditBody(req *http.Request) *auditBody {
+	b := &auditBody{hash: sha256.New()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return b
+	}
+
+	// JSON bodies are small, they are read up front to be stored
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) &&
+		req.ContentLength >= 0 && req.ContentLength <= maxAuditBodySize {
+		b.json, _ = io.ReadAll(req.Body)
+		b.Write(b.json)
+		req.Body = io.NopCloser(bytes.NewReader(b.json))
+		return b
+	}
+
+	// Other bodies, such as uploads, are hashed as they stream to the handler
+	b.stream = req.Body
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(req.Body, b), req.Body}
+	return b
+}
+
+// finish returns the hash of the whole body, empty without one, and the redacted JSON body
+func (b *auditBody) finish() (string, datatypes.JSON) {
+	if b.stream != nil {
+		// What the handler left unread still belongs to the body
+		io.Copy(b, b.stream)
+	}
+	if b.size == 0 {
+		return "", nil
+	}
+	sum := hex.EncodeToString(b.hash.Sum(nil))
+
+	var doc interface{}
+	if len(b.json) == 0 || json.Unmarshal(b.json, &doc) != nil {
+		return sum, nil
+	}
+	redacted, err := json.Marshal(redactAuditFields(doc))
+	if err != nil {
+		return sum, nil
+	}
+	return sum, datatypes.JSON(redacted)
+}
+
+// redactAuditFields replaces the values of fields whose name contains a redacted word, at any depth
+func redactAuditFields(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if auditFieldRedacted(k) {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactAuditFields(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactAuditFields(child)
+		}
+	}
+	return node
+}
+
+func auditFieldRedacted(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range auditRedactedFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
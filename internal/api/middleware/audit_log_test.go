@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAuditLogRecordsMutatingRequests(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	logger := NewAuditLogger(gdb, 16)
+	e := echo.New()
+	e.Use(AuditLog(logger))
+	signedIn := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("userID", user.ID)
+			c.Set("teamID", team.ID)
+			return next(c)
+		}
+	}
+	handler := func(c echo.Context) error {
+		var body map[string]interface{}
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusCreated)
+	}
+	e.POST("/api/v1/api-keys/:id", handler, signedIn)
+	e.GET("/api/v1/api-keys/:id", handler, signedIn)
+	e.POST("/api/v1/auth/login", handler)
+
+	send := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(http.MethodPost, "/api/v1/api-keys/0b6a2c1e-8f5d-4d7e-9a43-1c2b3d4e5f60",
+		`{"name":"ci","secretKey":"s3cr3t","nested":{"Password":"hunter2"},"tokens":["a"]}`)
+	send(http.MethodGet, "/api/v1/api-keys/0b6a2c1e-8f5d-4d7e-9a43-1c2b3d4e5f60", "")
+	send(http.MethodPost, "/api/v1/auth/login", `{"password":"hunter2"}`)
+	logger.Close()
+
+	var logs []models.AuditLog
+	if err := gdb.Find(&logs).Error; err != nil {
+		t.Fatalf("failed to list audit logs: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d audit logs, want only the signed-in POST", len(logs))
+	}
+
+	got := logs[0]
+	if got.UserID == nil || *got.UserID != user.ID || got.TeamID != team.ID {
+		t.Errorf("audit log of user %v in team %s, want %s in %s", got.UserID, got.TeamID, user.ID, team.ID)
+	}
+	if got.Resource != "api_keys" || got.ResourceID != "0b6a2c1e-8f5d-4d7e-9a43-1c2b3d4e5f60" {
+		t.Errorf("audit log of %s %s, want the api key", got.Resource, got.ResourceID)
+	}
+	if got.StatusCode != http.StatusCreated || got.BodyHash == "" {
+		t.Errorf("audit log with status %d and hash %q", got.StatusCode, got.BodyHash)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(got.Body, &body); err != nil {
+		t.Fatalf("stored body is not JSON: %v", err)
+	}
+	if body["name"] != "ci" {
+		t.Errorf("name = %v, want it kept", body["name"])
+	}
+	if body["secretKey"] != redactedValue || body["tokens"] != redactedValue {
+		t.Errorf("secrets were stored: %s", got.Body)
+	}
+	if nested, _ := body["nested"].(map[string]interface{}); nested["Password"] != redactedValue {
+		t.Errorf("nested password was stored: %s", got.Body)
+	}
+}
+
+func TestAuditLoggerDropsRecordsWhenFull(t *testing.T) {
+	// The writer is never started, so the buffer stays full
+	logger := &AuditLogger{&batchWriter[models.AuditLog]{name: "Audit log", records: make(chan models.AuditLog, 1)}}
+	logger.Record(models.AuditLog{})
+	logger.Record(models.AuditLog{})
+	if dropped := logger.dropped.Load(); dropped != 1 {
+		t.Errorf("dropped %d records, want 1", dropped)
+	}
+}
@@ -3,55 +3,108 @@ package middleware
 import (
 	"be0/internal/db"
 	"be0/internal/models"
+	"be0/internal/utils"
 	"be0/internal/utils/logger"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 var log = logger.New("auth_middleware")
 
+// SandboxHeader is set on responses for teams in sandbox mode
+const SandboxHeader = "X-Be0-Sandbox"
+
+// APIKeyHeader carries API keys, which authenticate requests as their team
+const APIKeyHeader = "X-API-KEY"
+
+// DeviceIDHeader names the device a client signs in on, see models.DeviceID
+const DeviceIDHeader = "X-Device-Id"
+
 type AuthMiddleware struct {
 	jwtSecret string
-	apiKeys   map[string]APIKeyInfo
 }
 
 type APIKeyInfo struct {
+	ID          string
 	TeamID      string
 	Permissions []string
 	ExpiresAt   time.Time
 }
 
 type Claims struct {
-	UserID string   `json:"user_id"`
-	TeamID string   `json:"team_id"`
-	Email  string   `json:"email"`
-	Role   string   `json:"role"`
+	UserID string `json:"user_id"`
+	TeamID string `json:"team_id"`
+	// OrgID is the organization of the team, empty for standalone teams
+	OrgID string `json:"org_id,omitempty"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	// Scopes are the user's "resource:action" permissions, e.g. teams:read
 	Scopes []string `json:"scopes"`
+	// ImpersonatedBy is set on tokens a super admin acts as this user with
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	// Ext holds the claims added by utils.RegisterClaimEnricher
+	Ext map[string]any `json:"ext,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenClaims are the claims of the access token a request was made with, see GetTokenClaims
+type TokenClaims struct {
+	TokenID   string
+	UserID    string
+	TeamID    string
+	OrgID     string
+	Role      string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Ext holds the claims added by enrichers, e.g. plan
+	Ext map[string]any
+}
+
+// ExtString returns an enriched claim that is a string, empty when it is missing or not a string
+func (t *TokenClaims) ExtString(key string) string {
+	value, _ := t.Ext[key].(string)
+	return value
+}
+
 func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtSecret: jwtSecret,
-		apiKeys:   make(map[string]APIKeyInfo),
 	}
 }
 
-func (m *AuthMiddleware) RegisterAPIKey(key string, info APIKeyInfo) {
-	m.apiKeys[key] = info
+// ResolveAPIKey returns the info bound to an API key if it exists and has not expired
+func (m *AuthMiddleware) ResolveAPIKey(key string) (APIKeyInfo, bool) {
+	info, ok, err := lookupAPIKey(key)
+	if err != nil {
+		log.Error("Failed to look up API key: %v", err)
+		return APIKeyInfo{}, false
+	}
+	return info, ok
 }
 
 func (m *AuthMiddleware) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		// 🛡️ Once authenticated, requests to a resource need the permission of their action
+		next = m.requireDefaultPermission(next)
+
 		return func(c echo.Context) error {
+			// 🔑 API keys authenticate as their team
+			if key := c.Request().Header.Get(APIKeyHeader); key != "" {
+				return m.validateAPIKey(c, key, next)
+			}
+
 			// Check JWT Token
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
@@ -72,7 +125,7 @@ func (m *AuthMiddleware) Middleware() echo.MiddlewareFunc {
 	}
 }
 
-func (m *AuthMiddleware) getResourceFromPath(path string) string {
+func getResourceFromPath(path string) string {
 	// Remove API version prefix if exists
 	path = strings.TrimPrefix(path, "/api/v1")
 
@@ -84,18 +137,119 @@ func (m *AuthMiddleware) getResourceFromPath(path string) string {
 	return "unknown"
 }
 
+// routePath returns the path of the route a request matched, or the request path and false
+// when it matched none
+func routePath(c echo.Context) (string, bool) {
+	if path := c.Path(); path != "" && !strings.HasSuffix(path, "*") {
+		return path, true
+	}
+	return c.Request().URL.Path, false
+}
+
+// resourceOfPath names the resource of a path like the permissions do, e.g. api_keys for /api/v1/api-keys
+func resourceOfPath(path string) string {
+	return strings.ReplaceAll(getResourceFromPath(path), "-", "_")
+}
+
+// defaultPermission derives the "resource:action" permission a route of a seeded resource
+// needs, e.g. files:create to POST /api/v1/files. The action follows the method on the
+// resource and its items, other routes of a resource need to read it and check their own
+// action with RequirePermissions. The caller's own account under /users/me is exempt.
+// Requests matching no route are checked by their path, so they are denied before they 404.
+func (m *AuthMiddleware) defaultPermission(c echo.Context) string {
+	path, matched := routePath(c)
+	resource := resourceOfPath(path)
+	if !models.IsDefaultResource(resource) {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/v1"), "/"), "/")
+	if resource == "users" && len(parts) > 1 && parts[1] == "me" {
+		return ""
+	}
+
+	action := ActionRead
+	if len(parts) == 1 || (len(parts) == 2 && (!matched || strings.HasPrefix(parts[1], ":"))) {
+		action = GetRequiredPermissionForMethod(c.Request().Method)
+	}
+	return resource + ":" + action
+}
+
+// requireDefaultPermission checks the default permission of the route before next, see defaultPermission
+func (m *AuthMiddleware) requireDefaultPermission(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if required := m.defaultPermission(c); required != "" {
+			if err := checkPermissions(c, db.DB, required); err != nil {
+				return err
+			}
+		}
+		return next(c)
+	}
+}
+
+// ExplainPermission dry-runs the resource permission check of a request with method to path
+// for the caller of c. path is routed through the server's routes like the request would be.
+// Routes may declare further permissions, which are checked once the request reaches them.
+func (m *AuthMiddleware) ExplainPermission(c echo.Context, db *gorm.DB, method, path string) (*PermissionDecision, error) {
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	probe := c.Echo().NewContext(req, nil)
+	c.Echo().Router().Find(method, req.URL.Path, probe)
+
+	// 🔑 API keys are limited by method before any route is checked
+	var keyStep []PermissionStep
+	if IsAPIKey(c) {
+		permissions, _ := c.Get("permissions").([]string)
+		if !APIKeyAllows(permissions, method) {
+			scopes := APIKeyScopes(permissions)
+			if scopes == nil {
+				scopes = []string{}
+			}
+			return &PermissionDecision{
+				RequiredScopes: []string{scopeWildcard + ":" + GetRequiredPermissionForMethod(method)},
+				MissingScope:   scopeWildcard + ":" + GetRequiredPermissionForMethod(method),
+				YourScopes:     scopes,
+				Steps: []PermissionStep{{Step: StepAPIKey, Passed: false,
+					Detail: fmt.Sprintf("The API key's permissions %v do not allow %s requests", permissions, method)}},
+			}, nil
+		}
+		keyStep = append(keyStep, PermissionStep{Step: StepAPIKey, Passed: true,
+			Detail: fmt.Sprintf("The API key's permissions %v allow %s requests", permissions, method)})
+	}
+
+	var required []string
+	route := method + " " + req.URL.Path
+	step := PermissionStep{Step: StepRoute, Passed: true, Detail: route + " needs no resource permission"}
+	if permission := m.defaultPermission(probe); permission != "" {
+		required = append(required, permission)
+		step.Detail = route + " needs " + permission
+	}
+
+	decision, err := resolvePermissions(c, db, method, required...)
+	if err != nil {
+		return nil, err
+	}
+	if len(required) > 0 {
+		decision.Steps = append(append(keyStep, step), decision.Steps...)
+	} else {
+		decision.Steps = append(keyStep, step)
+	}
+	return decision, nil
+}
+
 func (m *AuthMiddleware) validateJWT(c echo.Context, tokenString string, next echo.HandlerFunc) error {
 
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, utils.AccessTokenKey(m.jwtSecret))
 
 	if err != nil || !token.Valid {
-		log.Error("Error parsing JWT token: %v", err)
+		requestLog(c).Error("Error parsing JWT token: %v", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+	// 🏷️ Tokens of another issuer or audience, and tokens without a jti, are rejected
+	if err := utils.ValidateAccessClaims(&claims.RegisteredClaims); err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
 	}
 
@@ -106,90 +260,205 @@ func (m *AuthMiddleware) validateJWT(c echo.Context, tokenString string, next ec
 
 	// Verify auth transaction
 	transaction := &models.AuthTransaction{}
-	if err := db.DB.Where("user_id = ? AND team_id = ? AND token = ?",
-		claims.UserID, claims.TeamID, tokenString).First(transaction).Error; err != nil {
+	if err := db.DB.Where("user_id = ? AND team_id = ? AND token_id = ? AND expires_at > ? AND is_deleted = false",
+		claims.UserID, claims.TeamID, claims.ID, time.Now().UTC()).First(transaction).Error; err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Auth transaction not found")
 	}
 
 	// Verify user exists
 	user := &models.User{}
-	if err := db.DB.Where("id = ?", claims.UserID).First(user).Error; err != nil {
+	if err := db.DB.Where("id = ? AND is_deleted = false", claims.UserID).First(user).Error; err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
 	}
-
-	log.Info("User found: %s", user.Email)
-
-	// Verify team membership
-	team := &models.Team{}
-	if err := db.DB.Joins("JOIN users ON users.team_id = teams.id").
-		Where("teams.id = ? AND users.id = ?", claims.TeamID, claims.UserID).
-		First(team).Error; err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Team not found")
+	if !user.IsActive {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Account is deactivated")
 	}
 
-	requestContentType := strings.Split(c.Request().Header.Get("Content-Type"), ";")[0]
-
-	log.Info("Request content type: %s", requestContentType)
-
-	if (c.Request().Method == "POST" || c.Request().Method == "PUT") && requestContentType != "multipart/form-data" {
-		body := c.Request().Body
-		defer func(body io.ReadCloser) {
-			err := body.Close()
-			if err != nil {
-				log.Error("Failed to close request body", err)
-			}
-		}(body)
-
-		var bodyMap map[string]interface{}
-		if err := json.NewDecoder(body).Decode(&bodyMap); err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON Fbody")
-		}
+	requestLog(c).Info("User found: %s", user.Email)
 
-		bodyMap["teamId"] = team.ID
-		newBody, err := json.Marshal(bodyMap)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to encode body")
+	// Verify team membership, organization admins act in every team of their organization
+	team, orgAdmin, err := models.TeamAccess(db.DB, user, claims.TeamID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, models.ErrNoTeamAccess) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Team not found")
 		}
+		requestLog(c).Error("Failed to check team access: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check team access")
+	}
 
-		c.Request().Body = io.NopCloser(bytes.NewBuffer(newBody))
+	if err := injectTeamID(c, team.ID); err != nil {
+		return err
 	}
 
-	// Check method-based permissions
-	method := c.Request().Method
-	requiredScope := GetRequiredPermissionForMethod(method)
-	if requiredScope == "" {
+	// Check the request method maps to an action
+	if GetRequiredPermissionForMethod(c.Request().Method) == "" {
 		return echo.NewHTTPError(http.StatusForbidden, "Invalid request method")
 	}
 
-	// Admin role has all permissions
+	// Admin role has all permissions, other roles are checked per resource by RequirePermissions
+	role := claims.Role
 	if user.Role == models.UserRoleAdmin || user.Role == models.UserRoleSuperAdmin {
 		c.Set("hasAdminAccess", true)
-	} else {
-		// Check if user has the required scope
-		hasPermission := false
-		for _, scope := range claims.Scopes {
-			if ValidateMethodPermission(method, scope) {
-				hasPermission = true
-				break
-			}
+	}
+	// 🏢 Organization admins are admins of every team in the organization
+	if orgAdmin {
+		c.Set("hasAdminAccess", true)
+		if models.UserRole(role).Rank() < models.UserRoleAdmin.Rank() {
+			role = string(models.UserRoleAdmin)
 		}
+	}
 
-		if !hasPermission {
-			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
-		}
+	// ⏳ Sessions older than the team's maximum age must sign in again
+	if maxAge := team.AuthPolicy.SessionMaxAge; maxAge > 0 && time.Since(transaction.CreatedAt.Time) > time.Duration(maxAge)*time.Minute {
+		return echo.NewHTTPError(http.StatusUnauthorized, map[string]string{
+			"code":    "session_max_age_exceeded",
+			"message": "Session exceeded the team's maximum age, sign in again",
+		})
+	}
+
+	// 🧪 Let UIs badge sandboxed teams
+	if team.SandboxMode {
+		c.Response().Header().Set(SandboxHeader, "true")
 	}
 
 	// Set context values
 	c.Set("userID", claims.UserID)
 	c.Set("teamID", claims.TeamID)
 	c.Set("email", claims.Email)
-	c.Set("role", claims.Role)
+	c.Set("role", role)
+	if team.OrganizationID != nil {
+		c.Set("orgID", *team.OrganizationID)
+	}
+	c.Set("isOrgAdmin", orgAdmin)
 	c.Set("scopes", claims.Scopes)
+	c.Set("sessionID", transaction.ID)
 	c.Set("isAPIKey", false)
+	addLogFields(c, logger.Fields{"user_id": claims.UserID, "team_id": claims.TeamID})
+	tokenClaims := &TokenClaims{
+		TokenID: claims.ID,
+		UserID:  claims.UserID,
+		TeamID:  claims.TeamID,
+		OrgID:   claims.OrgID,
+		Role:    claims.Role,
+		Scopes:  claims.Scopes,
+		Ext:     claims.Ext,
+	}
+	if claims.IssuedAt != nil {
+		tokenClaims.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		tokenClaims.ExpiresAt = claims.ExpiresAt.Time
+	}
+	c.Set("tokenClaims", tokenClaims)
+
+	// 🕵️ Requests made as another user are audited under the super admin
+	if claims.ImpersonatedBy != "" {
+		c.Set("impersonatedBy", claims.ImpersonatedBy)
+		return auditImpersonation(c, claims.ImpersonatedBy, next)
+	}
 
 	return next(c)
 }
 
+// auditImpersonation runs an impersonated request and records it in the audit log
+func auditImpersonation(c echo.Context, impersonatorID string, next echo.HandlerFunc) error {
+	err := next(c)
+
+	status := c.Response().Status
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+	} else if err != nil {
+		status = http.StatusInternalServerError
+	}
+
+	if auditErr := models.RecordAudit(db.DB, models.AuditEntry{
+		ActorID:    impersonatorID,
+		TeamID:     GetTeamID(c),
+		Action:     models.AuditImpersonatedRequest,
+		TargetType: "user",
+		TargetID:   GetUserID(c),
+		IPAddress:  c.RealIP(),
+	}, map[string]interface{}{
+		"sessionId": GetSessionID(c),
+		"method":    c.Request().Method,
+		"path":      c.Request().URL.Path,
+		"status":    status,
+	}); auditErr != nil {
+		requestLog(c).Error("Failed to audit impersonated request: %v", auditErr)
+	}
+
+	return err
+}
+
+// injectTeamID sets teamId in JSON bodies of POST and PUT requests to the authenticated team.
+// Objects get teamId set, arrays get it set on each object element. Empty, non-JSON and
+// malformed bodies are passed on unchanged so the handler reports its own binding errors.
+func injectTeamID(c echo.Context, teamID string) error {
+	req := c.Request()
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		return nil
+	}
+	if !isJSONContentType(req.Header.Get(echo.HeaderContentType)) || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	if closeErr := req.Body.Close(); closeErr != nil {
+		requestLog(c).Error("Failed to close request body", closeErr)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+	}
+	// Put the original bytes back, replaced below only when there is something to inject
+	setBody(req, raw)
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	// UseNumber keeps large integers intact through the roundtrip
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || decoder.More() {
+		return nil
+	}
+
+	switch body := decoded.(type) {
+	case map[string]interface{}:
+		body["teamId"] = teamID
+	case []interface{}:
+		for _, element := range body {
+			if object, ok := element.(map[string]interface{}); ok {
+				object["teamId"] = teamID
+			}
+		}
+	default:
+		return nil
+	}
+
+	newBody, err := json.Marshal(decoded)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to encode request body")
+	}
+	setBody(req, newBody)
+	return nil
+}
+
+// isJSONContentType reports whether a body of this content type is bound as JSON, a missing
+// content type is treated as JSON
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.ToLower(strings.Split(contentType, ";")[0]))
+	return mediaType == "" || mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// setBody replaces the request body, keeping the content length in sync
+func setBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+}
+
 // GetUserID Helper functions to get values from context
 func GetUserID(c echo.Context) string {
 	if id, ok := c.Get("userID").(string); ok {
@@ -205,6 +474,44 @@ func GetTeamID(c echo.Context) string {
 	return ""
 }
 
+// GetOrgID returns the organization of the request's team, empty for standalone teams and API keys
+func GetOrgID(c echo.Context) string {
+	if id, ok := c.Get("orgID").(string); ok {
+		return id
+	}
+	return ""
+}
+
+// IsOrgAdmin reports whether the user administers the organization of the request's team
+func IsOrgAdmin(c echo.Context) bool {
+	if isOrgAdmin, ok := c.Get("isOrgAdmin").(bool); ok {
+		return isOrgAdmin
+	}
+	return false
+}
+
+// GetTokenClaims returns the claims of the request's access token, false for API keys
+func GetTokenClaims(c echo.Context) (*TokenClaims, bool) {
+	claims, ok := c.Get("tokenClaims").(*TokenClaims)
+	return claims, ok
+}
+
+// GetSessionID returns the ID of the session the request was signed in with, empty for API keys
+func GetSessionID(c echo.Context) string {
+	if id, ok := c.Get("sessionID").(string); ok {
+		return id
+	}
+	return ""
+}
+
+// GetImpersonator returns the super admin acting as the user, empty unless the request uses an impersonation session
+func GetImpersonator(c echo.Context) string {
+	if id, ok := c.Get("impersonatedBy").(string); ok {
+		return id
+	}
+	return ""
+}
+
 func GetUserRole(c echo.Context) string {
 	if role, ok := c.Get("role").(string); ok {
 		return role
@@ -244,9 +551,9 @@ func HasPermission(c echo.Context, requiredScope string) bool {
 		return true
 	}
 
-	scopes := GetScopes(c)
-	for _, scope := range scopes {
-		if scope == requiredScope {
+	resource, action, _ := strings.Cut(requiredScope, ":")
+	for _, scope := range GetScopes(c) {
+		if ScopeAllows(scope, resource, action) {
 			return true
 		}
 	}
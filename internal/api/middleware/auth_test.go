@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+func TestInjectTeamID(t *testing.T) {
+	const teamID = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name: "object", method: http.MethodPost, contentType: echo.MIMEApplicationJSON,
+			body: `{"name":"Acme","teamId":"another-team"}`,
+			want: `{"name":"Acme","teamId":"` + teamID + `"}`,
+		},
+		{
+			name: "object with charset", method: http.MethodPut, contentType: echo.MIMEApplicationJSONCharsetUTF8,
+			body: `{"count":12345678901234567890}`,
+			want: `{"count":12345678901234567890,"teamId":"` + teamID + `"}`,
+		},
+		{
+			name: "array", method: http.MethodPost, contentType: echo.MIMEApplicationJSON,
+			body: `[{"name":"Acme"},{"name":"Globex","teamId":"another-team"},"text",1]`,
+			want: `[{"name":"Acme","teamId":"` + teamID + `"},{"name":"Globex","teamId":"` + teamID + `"},"text",1]`,
+		},
+		{name: "empty array", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: `[]`, want: `[]`},
+		{name: "empty", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: ``, want: ``},
+		{name: "whitespace", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: " \n", want: " \n"},
+		{name: "malformed", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: `{"name":`, want: `{"name":`},
+		{name: "trailing data", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: `{} {}`, want: `{} {}`},
+		{name: "scalar", method: http.MethodPost, contentType: echo.MIMEApplicationJSON, body: `"Acme"`, want: `"Acme"`},
+		{name: "form", method: http.MethodPost, contentType: echo.MIMEApplicationForm, body: `name=Acme`, want: `name=Acme`},
+		{name: "patch", method: http.MethodPatch, contentType: echo.MIMEApplicationJSON, body: `{"name":"Acme"}`, want: `{"name":"Acme"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			if err := injectTeamID(c, teamID); err != nil {
+				t.Fatalf("injectTeamID: %v", err)
+			}
+
+			got, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body %s, want %s", got, tt.want)
+			}
+			if c.Request().ContentLength != int64(len(got)) {
+				t.Errorf("content length %d, body has %d bytes", c.Request().ContentLength, len(got))
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareFindsSessionsByTokenID(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	e := echo.New()
+	e.GET("/api/v1/users/me", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+		NewAuthMiddleware(testutil.JWTSecret).Middleware())
+	status := func(token string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	issue := func() (string, string) {
+		t.Helper()
+		token, tokenID, err := utils.GenerateJWT(*user, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token, tokenID
+	}
+
+	first, firstID := issue()
+	session := models.AuthTransaction{UserID: user.ID, TeamID: team.ID, TokenID: firstID,
+		Refresh: models.HashRefreshToken("refresh-1"), ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if err := gdb.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := status(first); got != http.StatusOK {
+		t.Fatalf("token of the session got %d, want 200", got)
+	}
+
+	// 🔑 A validly signed token of the same user is refused unless a session holds its jti
+	unknown, _ := issue()
+	if got := status(unknown); got != http.StatusUnauthorized {
+		t.Errorf("token without a session got %d, want 401", got)
+	}
+
+	// A refresh moves the session to the new token, the previous one stops working
+	second, secondID := issue()
+	if _, err := models.RotateSession(gdb, session.ID, user.ID, models.HashRefreshToken("refresh-1"), secondID,
+		models.HashRefreshToken("refresh-2"), time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(second); got != http.StatusOK {
+		t.Errorf("refreshed token got %d, want 200", got)
+	}
+	if got := status(first); got != http.StatusUnauthorized {
+		t.Errorf("replaced token got %d, want 401", got)
+	}
+
+	// 🏷️ The live session's jti does not rescue a token minted for another audience
+	foreign, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.Claims{
+		UserID: user.ID,
+		TeamID: team.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        secondID,
+			Issuer:    utils.DefaultIssuer,
+			Audience:  jwt.ClaimStrings{"another-api"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte(testutil.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := status(foreign); got != http.StatusUnauthorized {
+		t.Errorf("token for another audience got %d, want 401", got)
+	}
+}
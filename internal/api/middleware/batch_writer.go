@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// writerBatchSize is the most records written in one insert
+const writerBatchSize = 100
+
+// batchWriter inserts records in the background, records wait in a buffered channel so a
+// request never waits on the database
+type batchWriter[T any] struct {
+	db      *gorm.DB
+	name    string // what the records are, for logs
+	records chan T
+	done    chan struct{}
+	close   sync.Once
+	dropped atomic.Int64
+}
+
+// newBatchWriter starts a writer buffering up to bufferSize records, stop it with Close
+func newBatchWriter[T any](db *gorm.DB, name string, bufferSize int) *batchWriter[T] {
+	w := &batchWriter[T]{
+		db:      db,
+		name:    name,
+		records: make(chan T, max(bufferSize, 1)),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// record queues a record without blocking, it is dropped when the buffer is full
+func (w *batchWriter[T]) record(record T) {
+	select {
+	case w.records <- record:
+	default:
+		if dropped := w.dropped.Add(1); dropped == 1 || dropped%100 == 0 {
+			log.Warn("%s buffer is full, %d records dropped", w.name, dropped)
+		}
+	}
+}
+
+// Close writes the queued records and stops the writer
+func (w *batchWriter[T]) Close() {
+	w.close.Do(func() { close(w.records) })
+	<-w.done
+}
+
+// run writes records in batches of those already queued until Close
+func (w *batchWriter[T]) run() {
+	defer close(w.done)
+	for record := range w.records {
+		batch := []T{record}
+	fill:
+		for len(batch) < writerBatchSize {
+			select {
+			case next, ok := <-w.records:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+
+		if err := w.db.Create(&batch).Error; err != nil {
+			log.Error("Failed to write %d %s records: %v", err, len(batch), w.name)
+		}
+	}
+}
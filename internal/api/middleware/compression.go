@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+// CompressionConfig configures response compression
+type CompressionConfig struct {
+	// Level is the gzip level, 0 disables compression
+	Level int
+	// MinLength is the smallest response, in bytes, worth compressing
+	MinLength int
+	// SkipAccept lists Accept prefixes that are never compressed, e.g. event streams,
+	// which gzip buffering would hold back, and already-compressed images
+	SkipAccept []string
+}
+
+var (
+	uncompressedRoutes   = map[string]bool{}
+	uncompressedRoutesMu sync.RWMutex
+)
+
+// NoCompression opts a route out of response compression, e.g. streams and proxied files:
+//
+//	middleware.NoCompression(api.GET("/images/:fileId", handler))
+func NoCompression(route *echo.Route) *echo.Route {
+	uncompressedRoutesMu.Lock()
+	defer uncompressedRoutesMu.Unlock()
+	uncompressedRoutes[route.Method+" "+route.Path] = true
+	return route
+}
+
+// Compression gzips responses except for opted-out routes, streaming and media requests
+func Compression(cfg CompressionConfig) echo.MiddlewareFunc {
+	if cfg.Level == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	return echomiddleware.GzipWithConfig(echomiddleware.GzipConfig{
+		Level:     cfg.Level,
+		MinLength: cfg.MinLength,
+		Skipper: func(c echo.Context) bool {
+			return skipCompression(c, cfg.SkipAccept)
+		},
+	})
+}
+
+func skipCompression(c echo.Context, skipAccept []string) bool {
+	req := c.Request()
+
+	// Routes are matched before middleware runs, so c.Path() is the registered route
+	uncompressedRoutesMu.RLock()
+	optedOut := uncompressedRoutes[req.Method+" "+c.Path()]
+	uncompressedRoutesMu.RUnlock()
+	if optedOut {
+		return true
+	}
+
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || req.Method == http.MethodHead {
+		return true
+	}
+
+	accept := strings.ToLower(req.Header.Get(echo.HeaderAccept))
+	for _, prefix := range skipAccept {
+		if strings.HasPrefix(accept, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// compressedServer serves an event stream, a large JSON body and an opted-out route behind
+// the compression middleware. The stream sends its second event once next is signalled.
+func compressedServer(t *testing.T, next <-chan struct{}) *httptest.Server {
+	t.Helper()
+	e := echo.New()
+	e.Use(Compression(CompressionConfig{Level: 5, MinLength: 16, SkipAccept: []string{"text/event-stream", "image/"}}))
+
+	e.GET("/events", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		for i := 1; i <= 2; i++ {
+			if i > 1 {
+				select {
+				case <-next:
+				case <-c.Request().Context().Done():
+					return nil
+				}
+			}
+			fmt.Fprintf(c.Response(), "event: tick\ndata: %d\n\n", i)
+			c.Response().Flush()
+		}
+		return nil
+	})
+	large := strings.Repeat("compressible ", 200)
+	e.GET("/json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"body": large})
+	})
+	NoCompression(e.GET("/raw", func(c echo.Context) error {
+		return c.String(http.StatusOK, large)
+	}))
+
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, url, accept string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Asked for explicitly, so the transport hands over the body as sent
+	req.Header.Set("Accept-Encoding", "gzip")
+	if accept != "" {
+		req.Header.Set(echo.HeaderAccept, accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestEventStreamsArriveEventByEventBehindGzip(t *testing.T) {
+	next := make(chan struct{})
+	server := compressedServer(t, next)
+
+	resp := get(t, server.URL+"/events", "text/event-stream")
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Fatalf("event stream sent with Content-Encoding %q", encoding)
+	}
+
+	// 📡 The first event must arrive while the handler is still waiting to send the second
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				events <- strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	for i, want := range []string{"1", "2"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("event %d is %q, want %q", i+1, got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event %d was held back", i+1)
+		}
+		if i == 0 {
+			close(next)
+		}
+	}
+}
+
+func TestCompressionSkipsOptedOutRoutes(t *testing.T) {
+	server := compressedServer(t, nil)
+
+	tests := []struct {
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"/json", "application/json", "gzip"},
+		{"/json", "image/webp", ""},
+		{"/raw", "", ""},
+	}
+	for _, tt := range tests {
+		resp := get(t, server.URL+tt.path, tt.accept)
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != tt.encoding {
+			t.Errorf("%s accepting %q: Content-Encoding %q, want %q", tt.path, tt.accept, encoding, tt.encoding)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// DebugCaptureHeader lets a super admin capture a single request
+const DebugCaptureHeader = "X-Debug-Capture"
+
+// redactedValue replaces every redacted field
+const redactedValue = "[REDACTED]"
+
+// DebugCaptureConfig configures request/response capture for debugging
+type DebugCaptureConfig struct {
+	// Enabled is the master switch, capture never happens when it is false
+	Enabled bool
+	// Teams lists team IDs whose requests are always captured
+	Teams []string
+	// RedactPaths lists JSON paths to redact. A single key matches at any depth,
+	// dotted paths match from the root and "*" matches any key or array index.
+	RedactPaths []string
+	// MaxBodySize is the number of bytes kept per body after redaction
+	MaxBodySize int
+	// TTL is how long captured requests are kept
+	TTL time.Duration
+	// BufferSize is the number of captured requests kept in the ring buffer
+	BufferSize int64
+}
+
+// CapturedRequest is a sanitized request/response pair
+type CapturedRequest struct {
+	RequestID    string            `json:"requestId"`
+	Method       string            `json:"method"`
+	Path         string            `json:"path"`
+	Query        string            `json:"query,omitempty"`
+	Status       int               `json:"status"`
+	TeamID       string            `json:"teamId,omitempty"`
+	UserID       string            `json:"userId,omitempty"`
+	Headers      map[string]string `json:"headers"`
+	RequestBody  string            `json:"requestBody,omitempty"`
+	ResponseBody string            `json:"responseBody,omitempty"`
+	Truncated    bool              `json:"truncated"`
+	DurationMs   int64             `json:"durationMs"`
+	CapturedAt   time.Time         `json:"capturedAt"`
+}
+
+// DebugCaptureStore keeps captured requests in a short-lived Redis ring buffer
+type DebugCaptureStore struct {
+	redis *redis.Client
+	cfg   DebugCaptureConfig
+}
+
+func NewDebugCaptureStore(client *redis.Client, cfg DebugCaptureConfig) *DebugCaptureStore {
+	return &DebugCaptureStore{redis: client, cfg: cfg}
+}
+
+func debugCaptureKey(requestID string) string {
+	return fmt.Sprintf("debug_capture:request:%s", requestID)
+}
+
+const debugCaptureIndexKey = "debug_capture:requests"
+
+// Save stores a captured request and evicts the oldest ones beyond the buffer size
+func (s *DebugCaptureStore) Save(ctx context.Context, record *CapturedRequest) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, debugCaptureKey(record.RequestID), data, s.cfg.TTL)
+	pipe.LPush(ctx, debugCaptureIndexKey, record.RequestID)
+	pipe.LTrim(ctx, debugCaptureIndexKey, 0, s.cfg.BufferSize-1)
+	pipe.Expire(ctx, debugCaptureIndexKey, s.cfg.TTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Get returns a captured request by request ID
+func (s *DebugCaptureStore) Get(ctx context.Context, requestID string) (*CapturedRequest, error) {
+	data, err := s.redis.Get(ctx, debugCaptureKey(requestID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var record CapturedRequest
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// DebugCapture records sanitized request and response bodies for teams listed in the config,
+// or for a single request when a super admin sends the X-Debug-Capture header.
+// Must be registered after the auth middleware.
+func DebugCapture(store *DebugCaptureStore) echo.MiddlewareFunc {
+	cfg := store.cfg
+	teams := make(map[string]bool, len(cfg.Teams))
+	for _, id := range cfg.Teams {
+		teams[id] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !cfg.Enabled || !shouldCapture(c, teams) {
+				return next(c)
+			}
+
+			start := time.Now()
+			req := c.Request()
+
+			var reqBody []byte
+			if req.Body != nil {
+				reqBody, _ = io.ReadAll(req.Body)
+				req.Body = io.NopCloser(bytes.NewReader(reqBody))
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer, limit: cfg.MaxBodySize * 4}
+			c.Response().Writer = recorder
+
+			err := next(c)
+
+			requestBody, reqTruncated := sanitizeBody(reqBody, req.Header.Get(echo.HeaderContentType), cfg)
+			responseBody, resTruncated := sanitizeBody(recorder.body.Bytes(), c.Response().Header().Get(echo.HeaderContentType), cfg)
+
+			record := &CapturedRequest{
+				RequestID:    c.Response().Header().Get(echo.HeaderXRequestID),
+				Method:       req.Method,
+				Path:         req.URL.Path,
+				Query:        redactQuery(req.URL.Query(), cfg.RedactPaths),
+				Status:       c.Response().Status,
+				TeamID:       GetTeamID(c),
+				UserID:       GetUserID(c),
+				Headers:      sanitizeHeaders(req.Header),
+				RequestBody:  requestBody,
+				ResponseBody: responseBody,
+				Truncated:    reqTruncated || resTruncated || recorder.overflow,
+				DurationMs:   time.Since(start).Milliseconds(),
+				CapturedAt:   time.Now().UTC(),
+			}
+
+			if record.RequestID != "" {
+				if saveErr := store.Save(context.Background(), record); saveErr != nil {
+					requestLog(c).Error("Failed to store debug capture", saveErr)
+				}
+			}
+
+			return err
+		}
+	}
+}
+
+func shouldCapture(c echo.Context, teams map[string]bool) bool {
+	if teams[GetTeamID(c)] {
+		return true
+	}
+	return c.Request().Header.Get(DebugCaptureHeader) != "" && GetUserRole(c) == string(models.UserRoleSuperAdmin)
+}
+
+// sanitizeBody redacts a body before it is stored and truncates it to the configured size.
+// Only JSON bodies are kept, anything else could carry secrets that cannot be redacted reliably.
+func sanitizeBody(body []byte, contentType string, cfg DebugCaptureConfig) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+
+	if !strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		return fmt.Sprintf("[%d bytes of %s omitted]", len(body), contentType), false
+	}
+
+	redacted, err := RedactJSON(body, cfg.RedactPaths)
+	if err != nil {
+		return fmt.Sprintf("[%d bytes of unparseable JSON omitted]", len(body)), false
+	}
+
+	if cfg.MaxBodySize > 0 && len(redacted) > cfg.MaxBodySize {
+		return string(redacted[:cfg.MaxBodySize]), true
+	}
+	return string(redacted), false
+}
+
+// RedactJSON replaces the values at the given paths with [REDACTED]
+func RedactJSON(body []byte, paths []string) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		if len(segments) == 1 {
+			doc = redactKeyEverywhere(doc, segments[0])
+		} else {
+			doc = redactPath(doc, segments)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+func redactKeyEverywhere(node interface{}, key string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if strings.EqualFold(k, key) {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactKeyEverywhere(child, key)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactKeyEverywhere(child, key)
+		}
+	}
+	return node
+}
+
+func redactPath(node interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return redactedValue
+	}
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if segments[0] == "*" || strings.EqualFold(k, segments[0]) {
+				v[k] = redactPath(child, segments[1:])
+			}
+		}
+	case []interface{}:
+		if segments[0] == "*" {
+			for i, child := range v {
+				v[i] = redactPath(child, segments[1:])
+			}
+		}
+	}
+	return node
+}
+
+// redactQuery encodes the query string with values of redacted keys replaced
+func redactQuery(values url.Values, paths []string) string {
+	for key := range values {
+		for _, path := range paths {
+			if strings.EqualFold(key, path) {
+				values.Set(key, redactedValue)
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// sanitizeHeaders drops credentials from the captured request headers
+func sanitizeHeaders(header http.Header) map[string]string {
+	sanitized := make(map[string]string, len(header))
+	for k := range header {
+		switch http.CanonicalHeaderKey(k) {
+		case echo.HeaderAuthorization, "Cookie", "X-Api-Key":
+			sanitized[k] = redactedValue
+		default:
+			sanitized[k] = header.Get(k)
+		}
+	}
+	return sanitized
+}
+
+// bodyRecorder tees the response body into a bounded buffer
+type bodyRecorder struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if remaining := r.limit - r.body.Len(); remaining > 0 {
+		if len(b) > remaining {
+			r.body.Write(b[:remaining])
+			r.overflow = true
+		} else {
+			r.body.Write(b)
+		}
+	} else if len(b) > 0 {
+		r.overflow = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *bodyRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
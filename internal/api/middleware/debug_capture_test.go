@@ -0,0 +1,58 @@
+package middleware
+
+import "testing"
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		body  string
+		want  string
+	}{
+		{"key at any depth", []string{"password"}, `{"password":"a","user":{"Password":"b","name":"Ada"}}`, `{"password":"[REDACTED]","user":{"Password":"[REDACTED]","name":"Ada"}}`},
+		{"keys inside arrays", []string{"token"}, `{"sessions":[{"token":"a"},{"token":"b"}]}`, `{"sessions":[{"token":"[REDACTED]"},{"token":"[REDACTED]"}]}`},
+		{"whole object under a key", []string{"secret"}, `{"secret":{"key":"a"}}`, `{"secret":"[REDACTED]"}`},
+		{"dotted path from the root", []string{"credentials.key"}, `{"credentials":{"key":"a"},"key":"kept"}`, `{"credentials":{"key":"[REDACTED]"},"key":"kept"}`},
+		{"wildcard over array items", []string{"keys.*.value"}, `{"keys":[{"value":"a","id":1},{"value":"b","id":2}]}`, `{"keys":[{"id":1,"value":"[REDACTED]"},{"id":2,"value":"[REDACTED]"}]}`},
+		{"nothing to redact", []string{"password"}, `{"name":"Ada"}`, `{"name":"Ada"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RedactJSON([]byte(tt.body), tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := RedactJSON([]byte(`{"password":`), []string{"password"}); err == nil {
+		t.Error("broken JSON was redacted")
+	}
+}
+
+func TestSanitizeBodyOmitsWhatItCannotRedact(t *testing.T) {
+	cfg := DebugCaptureConfig{RedactPaths: []string{"password"}, MaxBodySize: 32}
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        string
+		truncated   bool
+	}{
+		{"form body", "password=hunter2", "application/x-www-form-urlencoded", "[16 bytes of application/x-www-form-urlencoded omitted]", false},
+		{"broken JSON", `{"password":"hunter2"`, "application/json", "[21 bytes of unparseable JSON omitted]", false},
+		{"redacted then truncated", `{"password":"hunter2","name":"Ada Lovelace"}`, "application/json; charset=UTF-8", `{"name":"Ada Lovelace","password`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := sanitizeBody([]byte(tt.body), tt.contentType, cfg)
+			if got != tt.want || truncated != tt.truncated {
+				t.Errorf("got %q truncated %v, want %q truncated %v", got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
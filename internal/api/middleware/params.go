@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultUUIDParams are the path parameters that always hold UUIDs
+var DefaultUUIDParams = []string{"id", "userId", "teamId"}
+
+// uuidLength is the length of the canonical textual UUID form
+const uuidLength = 36
+
+// IsValidUUID reports whether s is a UUID in its canonical 36 character form
+func IsValidUUID(s string) bool {
+	if len(s) != uuidLength {
+		return false
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+// ValidateUUIDParams rejects requests whose UUID path parameters are malformed with a 400,
+// before they reach the handlers and the database. Defaults to DefaultUUIDParams.
+func ValidateUUIDParams(params ...string) echo.MiddlewareFunc {
+	if len(params) == 0 {
+		params = DefaultUUIDParams
+	}
+
+	checked := make(map[string]bool, len(params))
+	for _, p := range params {
+		checked[p] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for i, name := range c.ParamNames() {
+				if !checked[name] {
+					continue
+				}
+
+				var value string
+				if values := c.ParamValues(); i < len(values) {
+					value = values[i]
+				}
+
+				if !IsValidUUID(value) {
+					return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be a valid UUID", name))
+				}
+			}
+			return next(c)
+		}
+	}
+}
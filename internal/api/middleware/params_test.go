@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestValidateUUIDParams(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/files/:id", ok, ValidateUUIDParams())
+	e.GET("/teams/:teamId/users/:userId", ok, ValidateUUIDParams())
+	e.GET("/devices/:deviceId", ok, ValidateUUIDParams())
+	e.GET("/avatars/:userId", ok, ValidateUUIDParams("userId"))
+
+	const id = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"valid id", "/files/" + id, http.StatusOK},
+		{"uppercase id", "/files/" + strings.ToUpper(id), http.StatusOK},
+		{"malformed id", "/files/not-a-uuid", http.StatusBadRequest},
+		{"overly long id", "/files/" + id + strings.Repeat("a", 4096), http.StatusBadRequest},
+		{"id without dashes", "/files/" + strings.ReplaceAll(id, "-", ""), http.StatusBadRequest},
+		{"braced id", "/files/{" + id + "}", http.StatusBadRequest},
+		{"sql in id", "/files/" + id + "'%20OR%20'1'='1", http.StatusBadRequest},
+		{"every param is checked", "/teams/" + id + "/users/nope", http.StatusBadRequest},
+		{"valid params", "/teams/" + id + "/users/" + id, http.StatusOK},
+		{"unlisted params are not checked", "/devices/laptop", http.StatusOK},
+		{"explicit params", "/avatars/nope", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("GET %s: got %d %s, want %d", tt.path, rec.Code, rec.Body.String(), tt.status)
+			}
+		})
+	}
+}
+
+func TestValidateUUIDParamsRejectsEmptyValues(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/files/", nil), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("")
+
+	called := false
+	err := ValidateUUIDParams()(func(c echo.Context) error {
+		called = true
+		return nil
+	})(c)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	if !ok || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("got %v, want a 400", err)
+	}
+	if called {
+		t.Error("the handler ran with an empty id")
+	}
+}
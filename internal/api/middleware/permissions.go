@@ -1,79 +1,292 @@
 package middleware
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"be0/internal/models"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
-// Permission scopes
+// Actions of "resource:action" scopes a request method needs
 const (
-	ScopeAdmin = "admin"
-	ScopeRead  = "read"
-	ScopeWrite = "create"
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionWrite in a required permission stands for the action of the request method
+	ActionWrite = "write"
+	// scopeWildcard matches any resource or action
+	scopeWildcard = "*"
 )
 
-// ValidateMethodPermission validates if a given scope allows a specific HTTP method
-func ValidateMethodPermission(method string, scope string) bool {
-	switch scope {
-	case ScopeAdmin:
-		return true
-	case ScopeWrite:
-		return method == http.MethodPost || method == http.MethodPut ||
-			method == http.MethodDelete || method == http.MethodPatch
-	case ScopeRead:
-		return method == http.MethodGet
-	default:
+// ValidateMethodPermission validates if a "resource:action" scope, such as teams:read or
+// teams:*, allows a specific HTTP method on a resource
+func ValidateMethodPermission(method, resource, scope string) bool {
+	action := GetRequiredPermissionForMethod(method)
+	return action != "" && ScopeAllows(scope, resource, action)
+}
+
+// ScopeAllows reports whether a "resource:action" scope grants an action on a resource
+func ScopeAllows(scope, resource, action string) bool {
+	scopeResource, scopeAction, ok := strings.Cut(scope, ":")
+	if !ok {
 		return false
 	}
+	return (scopeResource == resource || scopeResource == scopeWildcard) &&
+		(scopeAction == action || scopeAction == scopeWildcard)
 }
 
-// GetRequiredPermissionForMethod returns the required permission scope for a given HTTP method
+// GetRequiredPermissionForMethod returns the action a given HTTP method needs on a resource
 func GetRequiredPermissionForMethod(method string) string {
 	switch method {
 	case http.MethodGet:
-		return ScopeRead
-	case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
-		return ScopeWrite
+		return ActionRead
+	case http.MethodPost:
+		return ActionCreate
+	case http.MethodPut, http.MethodPatch:
+		return ActionUpdate
+	case http.MethodDelete:
+		return ActionDelete
 	default:
 		return ""
 	}
 }
 
-// RequirePermissions middleware checks if the user/API key has the required permissions
+// RequirePermissions middleware checks if the user/API key has the required permissions.
+// Permissions are "resource:action" scopes, a write action is resolved from the request
+// method, so teams:write needs teams:create on POST and teams:delete on DELETE. Users are
+// checked against the scopes of their permissions in db, cached for a short while, so a
+// revoked permission applies without signing in again. API keys are checked against the
+// scopes of their permissions, see APIKeyScopes.
 func RequirePermissions(db *gorm.DB, requiredPermissions ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Check if user has admin access first
-			if hasAdmin, ok := c.Get("hasAdminAccess").(bool); ok && hasAdmin {
-				return next(c)
+			if err := checkPermissions(c, db, requiredPermissions...); err != nil {
+				return err
 			}
+			return next(c)
+		}
+	}
+}
 
-			method := c.Request().Method
+// Steps of resolving a permission, see PermissionStep
+const (
+	StepRoute  = "route"
+	StepRole   = "role"
+	StepAPIKey = "api_key"
+	StepGrants = "grants"
+)
 
-			// For JWT auth, check role-based permissions
-			role := c.Get("role").(string)
-			scopes := c.Get("scopes").([]string)
+// PermissionStep is one step of resolving whether a caller holds the permissions a request needs
+type PermissionStep struct {
+	Step   string `json:"step"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
 
-			// Admin role has all permissions
-			if role == "admin" {
-				return next(c)
-			}
+// PermissionDecision is the outcome of resolving the permissions a request needs, step by step
+type PermissionDecision struct {
+	Allowed        bool     `json:"allowed"`
+	RequiredScopes []string `json:"requiredScopes"`
+	// MissingScope is the first required scope the caller lacks
+	MissingScope string           `json:"missingScope,omitempty"`
+	YourScopes   []string         `json:"yourScopes"`
+	Steps        []PermissionStep `json:"steps"`
+}
+
+// permissionDenial is the record logged when a request is denied, it holds IDs and scopes only
+type permissionDenial struct {
+	UserID        string   `json:"userId,omitempty"`
+	APIKeyID      string   `json:"apiKeyId,omitempty"`
+	TeamID        string   `json:"teamId,omitempty"`
+	Method        string   `json:"method"`
+	Path          string   `json:"path"`
+	RequiredScope string   `json:"requiredScope"`
+	YourScopes    []string `json:"yourScopes"`
+}
+
+// checkPermissions returns a 403 error naming the missing scope unless the caller holds every
+// required permission
+func checkPermissions(c echo.Context, db *gorm.DB, requiredPermissions ...string) error {
+	decision, err := resolvePermissions(c, db, c.Request().Method, requiredPermissions...)
+	if err != nil {
+		requestLog(c).Error("Failed to load permissions: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load permissions")
+	}
+	if decision.Allowed {
+		return nil
+	}
+
+	apiKeyID, _ := c.Get("apiKeyID").(string)
+	return denyPermission(c, permissionDenial{
+		UserID:        GetUserID(c),
+		APIKeyID:      apiKeyID,
+		TeamID:        GetTeamID(c),
+		RequiredScope: decision.MissingScope,
+		YourScopes:    decision.YourScopes,
+	})
+}
+
+// denyPermission logs a denied request and returns the 403 error naming the missing scope
+func denyPermission(c echo.Context, denial permissionDenial) error {
+	// 📝 Denials are logged for support, GET /auth/why-denied explains them to the caller
+	denial.Method = c.Request().Method
+	denial.Path = c.Request().URL.Path
+	record, _ := json.Marshal(denial)
+	requestLog(c).Warn("Permission denied %s", record)
+
+	return echo.NewHTTPError(http.StatusForbidden, map[string]interface{}{
+		"code":           "insufficient_permissions",
+		"message":        "insufficient permissions",
+		"required_scope": denial.RequiredScope,
+		"your_scopes":    denial.YourScopes,
+	})
+}
+
+// resolvePermissions decides whether the caller holds the permissions a request with method
+// needs: admins hold every permission, other users need a granted permission and API keys a
+// permission of the key for each of them
+func resolvePermissions(c echo.Context, db *gorm.DB, method string, requiredPermissions ...string) (*PermissionDecision, error) {
+	decision := &PermissionDecision{RequiredScopes: make([]string, 0, len(requiredPermissions)), YourScopes: []string{}}
+	for _, required := range requiredPermissions {
+		resource, action, _ := strings.Cut(required, ":")
+		if action == ActionWrite {
+			action = GetRequiredPermissionForMethod(method)
+		}
+		decision.RequiredScopes = append(decision.RequiredScopes, resource+":"+action)
+	}
+	if len(decision.RequiredScopes) == 0 {
+		decision.Allowed = true
+		decision.Steps = append(decision.Steps, PermissionStep{StepRoute, true, "No resource permission is needed"})
+		return decision, nil
+	}
+
+	// Check if user has admin access first
+	isAPIKey := IsAPIKey(c)
+	role := GetUserRole(c)
+	if hasAdmin, ok := c.Get("hasAdminAccess").(bool); ok && hasAdmin {
+		decision.Allowed = true
+		decision.Steps = append(decision.Steps, PermissionStep{StepRole, true, "Admin access holds every permission"})
+		return decision, nil
+	}
+
+	// Admin role has all permissions, API keys have no role
+	if !isAPIKey {
+		if role == string(models.UserRoleAdmin) {
+			decision.Allowed = true
+			decision.Steps = append(decision.Steps, PermissionStep{StepRole, true, "The ADMIN role holds every permission"})
+			return decision, nil
+		}
+		decision.Steps = append(decision.Steps, PermissionStep{StepRole, false,
+			fmt.Sprintf("The %s role holds the permissions granted to the user only", role)})
+	}
 
-			// Check if user has any of the required permissions
-			hasPermission := false
-			for _, scope := range scopes {
-				if ValidateMethodPermission(method, scope) {
-					hasPermission = true
-					break
-				}
+	scopes, err := callerScopes(c, db)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != nil {
+		decision.YourScopes = scopes
+	}
+	if isAPIKey {
+		permissions, _ := c.Get("permissions").([]string)
+		decision.Steps = append(decision.Steps, PermissionStep{StepAPIKey, len(scopes) > 0,
+			fmt.Sprintf("The API key's permissions %v grant %v on every resource of its team", permissions, scopes)})
+	}
+
+	grantRequiredScopes(decision, scopes)
+	return decision, nil
+}
+
+// grantRequiredScopes allows decision once every required scope is granted by one of the
+// caller's scopes, or records the first missing one
+func grantRequiredScopes(decision *PermissionDecision, scopes []string) {
+	for _, required := range decision.RequiredScopes {
+		resource, action, _ := strings.Cut(required, ":")
+
+		grantedBy := ""
+		for _, scope := range scopes {
+			if ScopeAllows(scope, resource, action) {
+				grantedBy = scope
+				break
 			}
+		}
+
+		if grantedBy == "" {
+			decision.MissingScope = required
+			decision.Steps = append(decision.Steps, PermissionStep{StepGrants, false, required + " is not granted"})
+			return
+		}
+		decision.Steps = append(decision.Steps, PermissionStep{StepGrants, true, required + " is granted by " + grantedBy})
+	}
+
+	decision.Allowed = true
+}
+
+// ResolveUserPermission decides whether a user holds a "resource:action" scope the way an
+// authenticated request of theirs is decided: deactivated users hold none, admins and super
+// admins hold every scope and other users need a granted scope, wildcards included, read
+// through the same cache as RequirePermissions
+func ResolveUserPermission(db *gorm.DB, user *models.User, scope string) (*PermissionDecision, error) {
+	decision := &PermissionDecision{RequiredScopes: []string{scope}, YourScopes: []string{}}
+	if !user.IsActive {
+		decision.MissingScope = scope
+		decision.Steps = append(decision.Steps, PermissionStep{StepRole, false, "The account is deactivated"})
+		return decision, nil
+	}
+	if user.Role == models.UserRoleAdmin || user.Role == models.UserRoleSuperAdmin {
+		decision.Allowed = true
+		decision.Steps = append(decision.Steps, PermissionStep{StepRole, true, "Admin access holds every permission"})
+		return decision, nil
+	}
+	decision.Steps = append(decision.Steps, PermissionStep{StepRole, false,
+		fmt.Sprintf("The %s role holds the permissions granted to the user only", user.Role)})
 
-			if !hasPermission {
-				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	scopes, err := lookupUserScopes(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != nil {
+		decision.YourScopes = scopes
+	}
+	grantRequiredScopes(decision, scopes)
+	return decision, nil
+}
+
+// callerScopes returns the scopes of the API key, or of the user's permissions in db.
+// Without a database the scopes the access token was issued with are used.
+func callerScopes(c echo.Context, db *gorm.DB) ([]string, error) {
+	userID := GetUserID(c)
+	if IsAPIKey(c) || db == nil || userID == "" {
+		return GetScopes(c), nil
+	}
+	return lookupUserScopes(db, userID)
+}
+
+// RejectAPIKeys only lets users through, for routes an API key must not reach such as managing API keys
+func RejectAPIKeys() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if IsAPIKey(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "API keys cannot access this route")
 			}
+			return next(c)
+		}
+	}
+}
 
+// RequireSuperAdmin only lets super admins through
+func RequireSuperAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if GetUserRole(c) != string(models.UserRoleSuperAdmin) {
+				return echo.NewHTTPError(http.StatusForbidden, "super admin access required")
+			}
 			return next(c)
 		}
 	}
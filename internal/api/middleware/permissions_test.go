@@ -0,0 +1,358 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// caller sets the context the auth middleware leaves for a user or an API key
+type caller struct {
+	role        models.UserRole
+	scopes      []string
+	permissions []string // API key permissions, a user when nil
+}
+
+func (cl caller) set(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if cl.permissions != nil {
+			c.Set("isAPIKey", true)
+			c.Set("permissions", cl.permissions)
+			c.Set("scopes", APIKeyScopes(cl.permissions))
+			return next(c)
+		}
+		c.Set("isAPIKey", false)
+		c.Set("userID", "5574fee5-3ce4-49e5-af2e-21361fc433e4")
+		c.Set("role", string(cl.role))
+		c.Set("scopes", cl.scopes)
+		return next(c)
+	}
+}
+
+func TestRequirePermissions(t *testing.T) {
+	member := caller{role: models.UserRoleMember, scopes: []string{"teams:read", "users:read"}}
+	tests := []struct {
+		name     string
+		caller   caller
+		method   string
+		required string
+		status   int
+	}{
+		{"member with the scope", member, http.MethodGet, "teams:read", http.StatusOK},
+		{"member without the scope", member, http.MethodGet, "files:read", http.StatusForbidden},
+		{"member writing", member, http.MethodPost, "teams:write", http.StatusForbidden},
+		{"admin", caller{role: models.UserRoleAdmin}, http.MethodDelete, "teams:write", http.StatusOK},
+		{"read key reading", caller{permissions: []string{models.APIKeyPermissionRead}}, http.MethodGet, "teams:read", http.StatusOK},
+		{"read key creating", caller{permissions: []string{models.APIKeyPermissionRead}}, http.MethodPost, "team_invites:create", http.StatusForbidden},
+		{"write key creating", caller{permissions: []string{models.APIKeyPermissionWrite}}, http.MethodPost, "teams:write", http.StatusOK},
+		{"write key needing delete", caller{permissions: []string{models.APIKeyPermissionWrite}}, http.MethodPost, "users:delete", http.StatusForbidden},
+		{"delete key deleting", caller{permissions: []string{models.APIKeyPermissionDelete}}, http.MethodDelete, "users:delete", http.StatusOK},
+		{"admin key", caller{permissions: []string{models.APIKeyPermissionAdmin}}, http.MethodPost, "users:delete", http.StatusOK},
+		{"key without permissions", caller{permissions: []string{}}, http.MethodGet, "teams:read", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Any("/resource", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+				tt.caller.set, RequirePermissions(nil, tt.required))
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, "/resource", nil))
+			if rec.Code != tt.status {
+				t.Errorf("%s needing %s: got %d, want %d", tt.method, tt.required, rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRejectAPIKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller caller
+		status int
+	}{
+		{"user", caller{role: models.UserRoleMember}, http.StatusOK},
+		{"admin key", caller{permissions: []string{models.APIKeyPermissionAdmin}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/users/me", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+				tt.caller.set, RejectAPIKeys())
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/me", nil))
+			if rec.Code != tt.status {
+				t.Errorf("got %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+// grantOnly removes the user's permissions but those with the given scopes
+func grantOnly(t *testing.T, gdb *gorm.DB, user *models.User, scopes ...string) {
+	t.Helper()
+	err := gdb.Where("user_id = ? AND resource_permission_id NOT IN (?)", user.ID,
+		gdb.Model(&models.ResourcePermission{}).Select("id").Where("scope IN ?", scopes)).
+		Delete(&models.UserPermission{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	InvalidateUserScopes(user.ID)
+}
+
+// signIn returns an access token of a live session of the user, issued without scopes
+func signIn(t *testing.T, gdb *gorm.DB, user *models.User) string {
+	t.Helper()
+	token, tokenID, err := utils.GenerateJWT(*user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := models.AuthTransaction{UserID: user.ID, TeamID: user.TeamID, TokenID: tokenID, ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if err := gdb.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestDefaultPermission(t *testing.T) {
+	m := NewAuthMiddleware(testutil.JWTSecret)
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/files", "files:read"},
+		{http.MethodPost, "/api/v1/files", "files:create"},
+		{http.MethodPut, "/api/v1/files/:id", "files:update"},
+		{http.MethodPatch, "/api/v1/teams/:id", "teams:update"},
+		{http.MethodDelete, "/api/v1/teams/:id", "teams:delete"},
+		{http.MethodPost, "/api/v1/api-keys", "api_keys:create"},
+		// Other routes of a resource read it and check their own action
+		{http.MethodPost, "/api/v1/files/upload", "files:read"},
+		{http.MethodPost, "/api/v1/files/:id/share", "files:read"},
+		{http.MethodPost, "/api/v1/templates/:id/render", "templates:read"},
+		{http.MethodDelete, "/api/v1/users/:id/sessions", "users:read"},
+		// The caller's own account and routes of no seeded resource
+		{http.MethodPut, "/api/v1/users/me/profile-picture", ""},
+		{http.MethodGet, "/api/v1/users/me", ""},
+		{http.MethodGet, "/api/v1/jobs/:id", ""},
+		{http.MethodPost, "/api/v1/organizations", ""},
+		{http.MethodGet, "/api/v1/*", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(tt.method, "/", nil), httptest.NewRecorder())
+			c.SetPath(tt.path)
+			if got := m.defaultPermission(c); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareChecksResourcePermissions(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	grantOnly(t, gdb, member, "files:read")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	e := echo.New()
+	api := e.Group("/api/v1", NewAuthMiddleware(testutil.JWTSecret).Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	api.GET("/files", ok)
+	api.GET("/teams", ok)
+	api.GET("/users/me", ok)
+
+	tests := []struct {
+		name   string
+		user   *models.User
+		method string
+		target string
+		status int
+	}{
+		{"member reading files", member, http.MethodGet, "/api/v1/files", http.StatusOK},
+		{"member creating a file", member, http.MethodPost, "/api/v1/files", http.StatusForbidden},
+		{"member reading teams without teams:read", member, http.MethodGet, "/api/v1/teams", http.StatusForbidden},
+		{"member reading their account", member, http.MethodGet, "/api/v1/users/me", http.StatusOK},
+		{"member deleting a file", member, http.MethodDelete, "/api/v1/files/2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f", http.StatusForbidden},
+		{"admin creating a file", admin, http.MethodPost, "/api/v1/files", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer "+signIn(t, gdb, tt.user))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("got %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRequirePermissionsReadsPermissionsFromTheDatabase(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	set := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("isAPIKey", false)
+			c.Set("userID", member.ID)
+			c.Set("role", string(member.Role))
+			// Scopes of the token are ignored in favour of the granted permissions
+			c.Set("scopes", []string{"files:create"})
+			return next(c)
+		}
+	}
+	e.GET("/files", ok, set, RequirePermissions(gdb, "files:read"))
+	e.POST("/files", ok, set, RequirePermissions(gdb, "files:write"))
+
+	request := func(method string) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, "/files", nil))
+		return rec.Code
+	}
+	if status := request(http.MethodGet); status != http.StatusOK {
+		t.Errorf("reading with files:read: got %d, want 200", status)
+	}
+	if status := request(http.MethodPost); status != http.StatusForbidden {
+		t.Errorf("creating without files:create: got %d, want 403", status)
+	}
+
+	// 🔄 A revoked permission applies once the cached scopes expire or are invalidated
+	grantOnly(t, gdb, member, "teams:read")
+	if status := request(http.MethodGet); status != http.StatusForbidden {
+		t.Errorf("reading after files:read was revoked: got %d, want 403", status)
+	}
+}
+
+func TestExplainPermissionMatchesEnforcement(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	grantOnly(t, gdb, member, "files:read", "teams:read")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	// A key used just now is not touched in the background, after the test's database is gone
+	const readKey = "be0_explain_read_key"
+	usedAt := time.Now().UTC()
+	key := models.APIKey{TeamID: team.ID, Name: "read", Prefix: readKey[:8], KeyHash: models.HashAPIKey(readKey),
+		Permissions: []string{models.APIKeyPermissionRead}, LastUsedAt: &usedAt}
+	if err := gdb.Create(&key).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewAuthMiddleware(testutil.JWTSecret)
+	e := echo.New()
+	api := e.Group("/api/v1", m.Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	api.GET("/files", ok)
+	api.GET("/files/:id", ok)
+	api.DELETE("/files/:id", ok)
+	api.GET("/teams", ok)
+	api.GET("/jobs", ok)
+	api.GET("/explain", func(c echo.Context) error {
+		decision, err := m.ExplainPermission(c, gdb, c.QueryParam("method"), c.QueryParam("path"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, decision)
+	})
+
+	authorize := func(req *http.Request, user *models.User) {
+		if user == nil {
+			req.Header.Set(APIKeyHeader, readKey)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+signIn(t, gdb, user))
+	}
+
+	const file = "/api/v1/files/2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f"
+	tests := []struct {
+		name    string
+		user    *models.User // the read API key when nil
+		method  string
+		path    string
+		allowed bool
+		missing string
+		steps   []string
+	}{
+		{"member reading files", member, http.MethodGet, "/api/v1/files", true, "", []string{StepRoute, StepRole, StepGrants}},
+		{"member creating a file", member, http.MethodPost, "/api/v1/files", false, "files:create", []string{StepRoute, StepRole, StepGrants}},
+		{"member deleting a file", member, http.MethodDelete, file, false, "files:delete", []string{StepRoute, StepRole, StepGrants}},
+		{"member on a route of no resource", member, http.MethodGet, "/api/v1/jobs", true, "", []string{StepRoute}},
+		{"admin deleting a file", admin, http.MethodDelete, file, true, "", []string{StepRoute, StepRole}},
+		{"read key reading teams", nil, http.MethodGet, "/api/v1/teams", true, "", []string{StepAPIKey, StepRoute, StepAPIKey, StepGrants}},
+		{"read key reading jobs", nil, http.MethodGet, "/api/v1/jobs", true, "", []string{StepAPIKey, StepRoute}},
+		{"read key deleting a file", nil, http.MethodDelete, file, false, "*:delete", []string{StepAPIKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 🔍 The explanation
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/explain?method="+tt.method+"&path="+tt.path, nil)
+			authorize(req, tt.user)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("explaining: got %d: %s", rec.Code, rec.Body)
+			}
+			var decision PermissionDecision
+			if err := json.Unmarshal(rec.Body.Bytes(), &decision); err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed != tt.allowed || decision.MissingScope != tt.missing {
+				t.Errorf("explained allowed %v missing %q, want %v %q", decision.Allowed, decision.MissingScope, tt.allowed, tt.missing)
+			}
+			var steps []string
+			for _, step := range decision.Steps {
+				steps = append(steps, step.Step)
+			}
+			if !slices.Equal(steps, tt.steps) {
+				t.Errorf("got steps %v, want %v", decision.Steps, tt.steps)
+			}
+
+			// 🚦 The enforcement of the request itself
+			req = httptest.NewRequest(tt.method, tt.path, nil)
+			authorize(req, tt.user)
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if denied := rec.Code == http.StatusForbidden; denied == decision.Allowed {
+				t.Fatalf("explained allowed %v, the request answered %d", decision.Allowed, rec.Code)
+			}
+			if decision.Allowed {
+				return
+			}
+			// Echo's error handler answers the message of the error
+			var body struct {
+				Code          string   `json:"code"`
+				RequiredScope string   `json:"required_scope"`
+				YourScopes    []string `json:"your_scopes"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != "insufficient_permissions" || body.RequiredScope != decision.MissingScope ||
+				!slices.Equal(slices.Sorted(slices.Values(body.YourScopes)), slices.Sorted(slices.Values(decision.YourScopes))) {
+				t.Errorf("denied with %+v, explained %q and %v", body, decision.MissingScope, decision.YourScopes)
+			}
+		})
+	}
+}
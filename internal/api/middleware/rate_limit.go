@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"be0/internal/tasks/rate"
+	"be0/internal/utils"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitConfig sets the requests allowed per caller within the limiter's window
+type RateLimitConfig struct {
+	User   int // per signed-in user
+	APIKey int // per API key
+	IP     int // per client IP, for callers that are neither
+}
+
+// rateLimitExempt lists the path prefixes that are never rate limited
+var rateLimitExempt = []string{"/health", "/swagger"}
+
+// RateLimiter limits requests per caller with a sliding window kept in Redis, so the
+// limit holds across instances. It runs before the routes authenticate, so it
+// identifies callers itself: a known API key, else the user of a valid access
+// token, else the client IP.
+type RateLimiter struct {
+	cfg     RateLimitConfig
+	auth    *AuthMiddleware
+	limiter *rate.SlidingWindow
+}
+
+// NewRateLimiter creates a rate limiter identifying callers with auth
+func NewRateLimiter(limiter *rate.SlidingWindow, auth *AuthMiddleware, cfg RateLimitConfig) *RateLimiter {
+	return &RateLimiter{cfg: cfg, auth: auth, limiter: limiter}
+}
+
+func (r *RateLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			for _, prefix := range rateLimitExempt {
+				if strings.HasPrefix(path, prefix) {
+					return next(c)
+				}
+			}
+
+			key, limit := r.caller(c)
+			result, err := r.limiter.Allow(c.Request().Context(), key, limit)
+			if err != nil {
+				// 🚦 An unreachable Redis must not take the API down with it
+				requestLog(c).Warn("Rate limiter unavailable, allowing request: %v", err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(result.RetryAfter.Seconds())))))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Rate limit exceeded"})
+			}
+			return next(c)
+		}
+	}
+}
+
+// caller returns the rate limit key of the request and its limit
+func (r *RateLimiter) caller(c echo.Context) (string, int) {
+	req := c.Request()
+	if key := req.Header.Get(APIKeyHeader); key != "" {
+		if info, ok := r.auth.ResolveAPIKey(key); ok {
+			return "api_key:" + info.ID, r.cfg.APIKey
+		}
+	}
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		if userID := r.auth.accessTokenUser(token); userID != "" {
+			return "user:" + userID, r.cfg.User
+		}
+	}
+	return "ip:" + c.RealIP(), r.cfg.IP
+}
+
+// accessTokenUser returns the user of a valid, unexpired access token. Sessions are not
+// looked up, a revoked token still counts against its user until the auth middleware rejects it.
+func (m *AuthMiddleware) accessTokenUser(tokenString string) string {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, utils.AccessTokenKey(m.jwtSecret))
+	if err != nil || !token.Valid {
+		return ""
+	}
+	if err := utils.ValidateAccessClaims(&claims.RegisteredClaims); err != nil {
+		return ""
+	}
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
+		return ""
+	}
+	return claims.UserID
+}
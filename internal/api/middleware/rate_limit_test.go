@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/tasks/rate"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRateLimiter(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	client, server := testutil.NewRedis(t)
+	limiter := NewRateLimiter(
+		rate.NewSlidingWindow(client, "api_rate_limit", time.Minute),
+		NewAuthMiddleware(testutil.JWTSecret),
+		RateLimitConfig{User: 3, APIKey: 3, IP: 2},
+	)
+	e := echo.New()
+	e.Use(limiter.Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/health", ok)
+	e.GET("/api/v1/files", ok)
+
+	token, _, err := utils.GenerateJWT(models.User{Base: models.Base{ID: "user-1"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, ip, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get("/api/v1/files", "10.0.0.1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("anonymous request %d got %d", i+1, rec.Code)
+		}
+	}
+	rec := get("/api/v1/files", "10.0.0.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third anonymous request got %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != "0" ||
+		rec.Header().Get("Retry-After") == "" {
+		t.Errorf("429 headers: %v", rec.Header())
+	}
+
+	// 🩺 Health checks are never limited
+	if rec := get("/health", "10.0.0.1", ""); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("health check got %d with headers %v", rec.Code, rec.Header())
+	}
+
+	// 👤 A signed-in user has their own limit wherever they call from
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		rec := get("/api/v1/files", ip, token)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "3" {
+			t.Fatalf("user request %d got %d with headers %v", i+1, rec.Code, rec.Header())
+		}
+	}
+	if rec := get("/api/v1/files", "10.0.0.4", token); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("fourth user request got %d, want 429", rec.Code)
+	}
+
+	// An invalid token counts against the IP
+	if rec := get("/api/v1/files", "10.0.0.1", "not-a-token"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("invalid token got %d, want the IP's 429", rec.Code)
+	}
+
+	// 🚦 Requests are let through while Redis is down
+	server.Close()
+	if rec := get("/api/v1/files", "10.0.0.1", ""); rec.Code != http.StatusOK {
+		t.Errorf("request without Redis got %d", rec.Code)
+	}
+}
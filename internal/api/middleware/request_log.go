@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLogFields attaches the request ID to the request context, so loggers built with
+// logger.FromContext tag every line of the request with it. Must run after the RequestID
+// middleware, the auth middleware adds the caller once it is known.
+func RequestLogFields() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			if requestID == "" {
+				requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+			}
+			if requestID != "" {
+				addLogFields(c, logger.Fields{"request_id": requestID})
+			}
+			return next(c)
+		}
+	}
+}
+
+// addLogFields attaches fields to the request context for the rest of the request
+func addLogFields(c echo.Context, fields logger.Fields) {
+	req := c.Request()
+	c.SetRequest(req.WithContext(logger.NewContext(req.Context(), fields)))
+}
+
+// requestLog is the middleware logger tagged with the request and its caller
+func requestLog(c echo.Context) *logger.Logger {
+	return log.FromContext(c.Request().Context())
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"be0/internal/utils/logger"
+
+	"github.com/fatih/color"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// lockedBuffer is a buffer concurrent log lines can be written to
+type lockedBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func TestConcurrentRequestsLogDistinguishableLines(t *testing.T) {
+	var out lockedBuffer
+	output, noColor := color.Output, color.NoColor
+	color.Output, color.NoColor = &out, true
+	t.Cleanup(func() { color.Output, color.NoColor = output, noColor })
+
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(RequestLogFields())
+	// Stands in for the auth middleware identifying the caller
+	signedIn := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			addLogFields(c, logger.Fields{"user_id": c.Param("user"), "team_id": "team"})
+			return next(c)
+		}
+	}
+	// 🚧 Both requests are inside the handler before either logs
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	e.GET("/users/:user", func(c echo.Context) error {
+		arrived.Done()
+		arrived.Wait()
+		requestLog(c).Info("handling request")
+		return c.NoContent(http.StatusOK)
+	}, signedIn)
+
+	requestIDs := make([]string, 2)
+	var done sync.WaitGroup
+	for i, user := range []string{"alice", "bob"} {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/"+user, nil))
+			requestIDs[i] = rec.Header().Get(echo.HeaderXRequestID)
+		}()
+	}
+	done.Wait()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %q, want a line per request", lines)
+	}
+	for i, user := range []string{"alice", "bob"} {
+		want := "request_id=" + requestIDs[i] + " team_id=team user_id=" + user
+		found := 0
+		for _, line := range lines {
+			if strings.HasSuffix(line, "handling request "+want) {
+				found++
+			}
+		}
+		if requestIDs[i] == "" || found != 1 {
+			t.Errorf("lines %q, want one ending with %q", lines, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SkipSanitize honours ?skip_sanitize=true on template writes. Only super admins may
+// store a template as submitted, everyone else gets a 403 instead of a silent fallback.
+func SkipSanitize() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.QueryParam("skip_sanitize") != "true" {
+				return next(c)
+			}
+			if GetUserRole(c) != string(models.UserRoleSuperAdmin) {
+				return echo.NewHTTPError(http.StatusForbidden, "skip_sanitize requires super admin access")
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(models.WithSkipSanitize(req.Context())))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSkipSanitizeIsForSuperAdmins(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		role   models.UserRole
+		status int
+		skip   bool
+	}{
+		{"not asked", "", models.UserRoleMember, http.StatusOK, false},
+		{"asked by an admin", "?skip_sanitize=true", models.UserRoleAdmin, http.StatusForbidden, false},
+		{"asked by a super admin", "?skip_sanitize=true", models.UserRoleSuperAdmin, http.StatusOK, true},
+		{"anything but true", "?skip_sanitize=1", models.UserRoleSuperAdmin, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/api/v1/templates"+tt.query, nil), httptest.NewRecorder())
+			c.Set("role", string(tt.role))
+
+			skipped := false
+			err := SkipSanitize()(func(c echo.Context) error {
+				skipped = models.SkipsSanitize(c.Request().Context())
+				return nil
+			})(c)
+
+			status := http.StatusOK
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				status = httpErr.Code
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.status || skipped != tt.skip {
+				t.Errorf("got %d skipping %v, want %d skipping %v", status, skipped, tt.status, tt.skip)
+			}
+		})
+	}
+}
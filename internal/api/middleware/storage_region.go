@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"strings"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StorageRegionHeader lets clients pick the storage region signed URLs point at
+const StorageRegionHeader = "X-Storage-Region"
+
+// countryHeaders are set by the CDN/edge in front of the API from the requester's IP
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"}
+
+// StorageRegion marks requests that should get signed URLs from the storage replica.
+// An explicit X-Storage-Region header wins, otherwise the edge's GeoIP country is matched
+// against the countries served by the replica.
+func StorageRegion(replicaRegion string, replicaCountries []string) echo.MiddlewareFunc {
+	countries := make(map[string]bool, len(replicaCountries))
+	for _, country := range replicaCountries {
+		countries[strings.ToUpper(country)] = true
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if preferReplica(c, replicaRegion, countries) {
+				req := c.Request()
+				c.SetRequest(req.WithContext(models.WithPreferReplica(req.Context())))
+			}
+			return next(c)
+		}
+	}
+}
+
+func preferReplica(c echo.Context, replicaRegion string, countries map[string]bool) bool {
+	if region := c.Request().Header.Get(StorageRegionHeader); region != "" {
+		return strings.EqualFold(region, replicaRegion)
+	}
+
+	if country := EdgeCountry(c); country != "" {
+		return countries[country]
+	}
+	return false
+}
+
+// EdgeCountry returns the requester's country as located by the CDN/edge, empty when no edge header is set
+func EdgeCountry(c echo.Context) string {
+	for _, header := range countryHeaders {
+		if country := c.Request().Header.Get(header); country != "" {
+			return strings.ToUpper(country)
+		}
+	}
+	return ""
+}
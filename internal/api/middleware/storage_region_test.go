@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestStorageRegionPrefersTheReplica(t *testing.T) {
+	mw := StorageRegion("eu-west-1", []string{"de", "FR"})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no headers", nil, false},
+		{"replica region asked for", map[string]string{StorageRegionHeader: "EU-West-1"}, true},
+		{"other region asked for", map[string]string{StorageRegionHeader: "us-east-1", "CF-IPCountry": "DE"}, false},
+		{"served country", map[string]string{"CF-IPCountry": "de"}, true},
+		{"served country behind CloudFront", map[string]string{"CloudFront-Viewer-Country": "FR"}, true},
+		{"other country", map[string]string{"X-Country-Code": "US"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			var got bool
+			err := mw(func(c echo.Context) error {
+				got = models.PrefersReplica(c.Request().Context())
+				return nil
+			})(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("prefers the replica %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderStrictParams turns strict query parameters on (true) or off (false) for one request,
+// overriding the server default
+const HeaderStrictParams = "X-Strict-Params"
+
+var strictParams atomic.Bool
+
+// SetStrictParams sets whether requests without X-Strict-Params reject unknown query parameters
+func SetStrictParams(strict bool) {
+	strictParams.Store(strict)
+}
+
+// StrictParams reports whether the request rejects unknown query parameters
+func StrictParams(c echo.Context) bool {
+	if strict, err := strconv.ParseBool(c.Request().Header.Get(HeaderStrictParams)); err == nil {
+		return strict
+	}
+	return strictParams.Load()
+}
+
+// CheckQueryParams rejects query parameters other than known with a 400 naming them, and the
+// known parameter closest to each typo, e.g. include for inlcude. Lenient requests, see
+// StrictParams, are never rejected.
+func CheckQueryParams(c echo.Context, known ...string) error {
+	if !StrictParams(c) {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(known))
+	for _, name := range known {
+		allowed[name] = true
+	}
+	var unknown []string
+	for key := range c.QueryParams() {
+		if !allowed[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	suggestions := map[string]string{}
+	descriptions := make([]string, len(unknown))
+	for i, key := range unknown {
+		descriptions[i] = strconv.Quote(key)
+		if match := closestMatch(key, known); match != "" {
+			suggestions[key] = match
+			descriptions[i] += " (did you mean " + strconv.Quote(match) + "?)"
+		}
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, map[string]interface{}{
+		"message":     "unknown query parameters " + strings.Join(descriptions, ", "),
+		"unknown":     unknown,
+		"suggestions": suggestions,
+	})
+}
+
+// closestMatch returns the candidate closest to name, ignoring case, or "" when none is close
+// enough to be a typo of it. Swapped letters count as one edit.
+func closestMatch(name string, candidates []string) string {
+	// 📏 Short names allow one edit, longer ones up to three, and some of the name must be
+	// left so x is not taken for q
+	threshold := min(max(len(name)/3, 1), 3, len(name)-1)
+	best, bestDistance := "", threshold+1
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance || distance == bestDistance && candidate < best {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance > threshold {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the optimal string alignment distance between a and b: insertions,
+// deletions, substitutions and transpositions of adjacent bytes
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
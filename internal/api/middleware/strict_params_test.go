@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestClosestMatch(t *testing.T) {
+	params := []string{"page", "limit", "include", "exclude", "sort", "order", "q", "teamId", "status"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"inlcude", "include"}, // swapped letters
+		{"includes", "include"},
+		{"inclde", "include"},
+		{"exlude", "exclude"},
+		{"pgae", "page"},
+		{"limt", "limit"},
+		{"srot", "sort"},
+		{"teamid", "teamId"}, // case
+		{"team_id", "teamId"},
+		{"stauts", "status"},
+		// Too far from every parameter to be a typo
+		{"foo", ""},
+		{"x", ""},
+		{"pagination", ""},
+		{"preload", ""},
+	}
+	for _, tt := range tests {
+		if got := closestMatch(tt.name, params); got != tt.want {
+			t.Errorf("closestMatch(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckQueryParams(t *testing.T) {
+	t.Cleanup(func() { SetStrictParams(false) })
+
+	check := func(target, header string) error {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if header != "" {
+			req.Header.Set(HeaderStrictParams, header)
+		}
+		return CheckQueryParams(echo.New().NewContext(req, httptest.NewRecorder()), "page", "limit", "include")
+	}
+
+	// Lenient by default, strict on request
+	if err := check("/?inlcude=Team", ""); err != nil {
+		t.Errorf("lenient request rejected: %v", err)
+	}
+	err := check("/?inlcude=Team&page=2&zzz=1", "true")
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("got %v, want a 400", err)
+	}
+	body := httpErr.Message.(map[string]interface{})
+	if !reflect.DeepEqual(body["unknown"], []string{"inlcude", "zzz"}) ||
+		!reflect.DeepEqual(body["suggestions"], map[string]string{"inlcude": "include"}) {
+		t.Errorf("got %v", body)
+	}
+	if err := check("/?page=2&include=Team", "true"); err != nil {
+		t.Errorf("known parameters rejected: %v", err)
+	}
+
+	// The header overrides the server default both ways
+	SetStrictParams(true)
+	if err := check("/?inlcude=Team", ""); err == nil {
+		t.Error("strict default accepted an unknown parameter")
+	}
+	if err := check("/?inlcude=Team", "false"); err != nil {
+		t.Errorf("X-Strict-Params: false rejected: %v", err)
+	}
+}
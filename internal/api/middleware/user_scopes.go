@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// userScopeCacheTTL is how long a user's scopes are trusted before they are looked up
+	// again, which bounds how long a revoked permission keeps working on other instances
+	userScopeCacheTTL = 30 * time.Second
+	// userScopeCacheSize caps the cache, it is emptied once full
+	userScopeCacheSize = 4096
+)
+
+type userScopeCacheEntry struct {
+	scopes   []string
+	cachedAt time.Time
+}
+
+var userScopeCache = struct {
+	sync.Mutex
+	entries map[string]userScopeCacheEntry // by user ID
+}{entries: make(map[string]userScopeCacheEntry)}
+
+// lookupUserScopes returns the scopes of the permissions granted to a user through the
+// cache, falling back to the database
+func lookupUserScopes(gdb *gorm.DB, userID string) ([]string, error) {
+	now := time.Now()
+
+	userScopeCache.Lock()
+	entry, ok := userScopeCache.entries[userID]
+	userScopeCache.Unlock()
+	if ok && now.Sub(entry.cachedAt) <= userScopeCacheTTL {
+		return entry.scopes, nil
+	}
+
+	scopes, err := models.UserScopes(gdb, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userScopeCache.Lock()
+	if len(userScopeCache.entries) >= userScopeCacheSize {
+		userScopeCache.entries = make(map[string]userScopeCacheEntry)
+	}
+	userScopeCache.entries[userID] = userScopeCacheEntry{scopes: scopes, cachedAt: now}
+	userScopeCache.Unlock()
+
+	return scopes, nil
+}
+
+// InvalidateUserScopes drops a user's scopes from this instance's cache, e.g. once their
+// permissions were reset
+func InvalidateUserScopes(userID string) {
+	userScopeCache.Lock()
+	defer userScopeCache.Unlock()
+	delete(userScopeCache.entries, userID)
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/utils/crypto"
+
+	"github.com/labstack/echo/v4"
+)
+
+// signCallback signs body at signedAt like an integration would
+func signCallback(req *http.Request, body, secret string, signedAt time.Time) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+crypto.ComputeWebhookSignature([]byte(timestamp+"."+body), secret))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	e := echo.New()
+	var received string
+	e.POST("/hooks/:source/bounces", func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		received = string(body)
+		return c.NoContent(http.StatusNoContent)
+	}, VerifyWebhookSignature(func(c echo.Context) (string, error) {
+		if c.Param("source") != "postmark" {
+			return "", errors.New("unknown source")
+		}
+		return "s3cret", nil
+	}))
+
+	const body = `{"email":"gone@example.com","smtpCode":550}`
+	tests := []struct {
+		name   string
+		source string
+		sign   func(req *http.Request)
+		body   string
+		status int
+	}{
+		{"valid", "postmark", func(req *http.Request) { signCallback(req, body, "s3cret", time.Now()) }, body, http.StatusNoContent},
+		{"tampered body", "postmark", func(req *http.Request) { signCallback(req, body, "s3cret", time.Now()) }, strings.Replace(body, "550", "450", 1), http.StatusUnauthorized},
+		{"wrong secret", "postmark", func(req *http.Request) { signCallback(req, body, "guess", time.Now()) }, body, http.StatusUnauthorized},
+		{"replayed", "postmark", func(req *http.Request) { signCallback(req, body, "s3cret", time.Now().Add(-6*time.Minute)) }, body, http.StatusUnauthorized},
+		{"replayed with a fresh timestamp", "postmark", func(req *http.Request) {
+			signCallback(req, body, "s3cret", time.Now().Add(-6*time.Minute))
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+		}, body, http.StatusUnauthorized},
+		{"from the future", "postmark", func(req *http.Request) { signCallback(req, body, "s3cret", time.Now().Add(time.Hour)) }, body, http.StatusUnauthorized},
+		{"unsigned", "postmark", func(req *http.Request) {}, body, http.StatusUnauthorized},
+		{"unknown source", "mailgun", func(req *http.Request) { signCallback(req, body, "s3cret", time.Now()) }, body, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			req := httptest.NewRequest(http.MethodPost, "/hooks/"+tt.source+"/bounces", strings.NewReader(tt.body))
+			tt.sign(req)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d %s, want %d", rec.Code, rec.Body.String(), tt.status)
+			}
+			// 📦 Handlers read the body the signature was checked against
+			if tt.status == http.StatusNoContent && received != tt.body {
+				t.Errorf("handler read %q, want %q", received, tt.body)
+			}
+			if tt.status != http.StatusNoContent && received != "" {
+				t.Error("handler ran for a rejected callback")
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"be0/internal/api/controllers"
+	"be0/internal/api/registry"
+	"be0/internal/api/validator"
+	"be0/internal/handlers"
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/swaggo/swag"
+)
+
+// validationErrorDefinition is the swagger definition of the error envelope for validation failures
+const validationErrorDefinition = "ValidationErrorResponse"
+
+// requestDTOs are the request bodies documented with example validation errors.
+// Their swagger definition name is the Go type name, e.g. handlers.LoginRequest.
+var requestDTOs = []interface{}{
+	models.Team{},
+	models.TeamInvite{},
+	models.File{},
+	models.Template{},
+	models.Organization{},
+	models.User{},
+	handlers.RegisterRequest{},
+	handlers.LoginRequest{},
+	handlers.RefreshTokenRequest{},
+	handlers.ResetPasswordRequest{},
+	handlers.VerifyResetCodeRequest{},
+	handlers.VerifyEmailRequest{},
+	handlers.ResendVerificationRequest{},
+	handlers.TwoFactorCodeRequest{},
+	handlers.TwoFactorVerifyRequest{},
+	handlers.GoogleAuthRequest{},
+	handlers.InviteUserRequest{},
+	handlers.AcceptInviteRequest{},
+	handlers.CreateWebhookRequest{},
+	handlers.RenderTemplateRequest{},
+	handlers.CreateOrganizationRequest{},
+	handlers.CreateTeamRequest{},
+	handlers.UpdateFileACLRequest{},
+	handlers.UpdateProfilePictureRequest{},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// serveOpenAPI serves the assembled swagger document
+func (s *Server) serveOpenAPI(c echo.Context) error {
+	openAPIOnce.Do(func() {
+		doc, err := OpenAPIDocument()
+		if err != nil {
+			log.Warn("Failed to assemble swagger document: %v", err)
+			return
+		}
+		openAPIDoc = doc
+	})
+
+	if openAPIDoc == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "swagger document not available")
+	}
+	return c.JSONBlob(http.StatusOK, openAPIDoc)
+}
+
+// OpenAPIDocument assembles the swagger document served at /swagger/doc.json: the swag
+// generated document, plus the CRUD routes from the registry and the request and model
+// definitions reflected from Go types when swag has not documented them, plus validation
+// error responses. CRUD routes are only known once registry.RegisterCRUDRoutes has run.
+func OpenAPIDocument() ([]byte, error) {
+	spec := map[string]interface{}{}
+	if doc, err := swag.ReadDoc(); err != nil || json.Unmarshal([]byte(doc), &spec) != nil || spec["swagger"] == nil {
+		// 📄 swag init has not been run, start from an empty document
+		spec = map[string]interface{}{
+			"swagger":  "2.0",
+			"info":     map[string]interface{}{"title": "be0 API", "version": "1.0"},
+			"basePath": "/api/v1",
+		}
+	}
+
+	builder := &schemaBuilder{definitions: map[string]interface{}{}}
+	mergeCRUDRoutes(spec, builder)
+	for _, dto := range requestDTOs {
+		builder.ref(reflect.TypeOf(dto))
+	}
+	mergeDefinitions(spec, builder.definitions)
+
+	doc, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return mergeValidationResponses(doc, requestDTOs)
+}
+
+// mergeCRUDRoutes documents registry CRUD routes that swag has not documented
+func mergeCRUDRoutes(spec map[string]interface{}, builder *schemaBuilder) {
+	paths, _ := spec["paths"].(map[string]interface{})
+	if paths == nil {
+		paths = map[string]interface{}{}
+		spec["paths"] = paths
+	}
+
+	routes := registry.CRUDRoutes()
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Method+routes[i].Path < routes[j].Method+routes[j].Path })
+	for _, route := range routes {
+		swaggerPath := echoPathToSwagger(route.Path)
+		item, _ := paths[swaggerPath].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[swaggerPath] = item
+		}
+		method := strings.ToLower(route.Method)
+		if _, documented := item[method]; documented {
+			continue
+		}
+		item[method] = crudOperation(route, builder)
+	}
+}
+
+// crudOperation describes one BaseController operation
+func crudOperation(route registry.CRUDRoute, builder *schemaBuilder) map[string]interface{} {
+	modelType := reflect.TypeOf(route.Model)
+	resource := modelType.Name()
+	item := builder.schema(responseType(route.Model))
+
+	var parameters []interface{}
+	if strings.Contains(route.Path, ":id") {
+		parameters = append(parameters, map[string]interface{}{
+			"name": "id", "in": "path", "required": true, "type": "string", "format": "uuid",
+		})
+	}
+
+	responses := map[string]interface{}{}
+	switch route.Operation {
+	case "list":
+		resource = pluralize(resource)
+		for _, name := range []string{"page", "limit"} {
+			parameters = append(parameters, map[string]interface{}{"name": name, "in": "query", "type": "integer"})
+		}
+		for _, name := range []string{"sort", "order", "q", "include", "exclude", "fields", "ids"} {
+			parameters = append(parameters, map[string]interface{}{"name": name, "in": "query", "type": "string"})
+		}
+		responses["200"] = map[string]interface{}{
+			"description": "OK",
+			"schema": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"data", "total", "page", "limit"},
+				"properties": map[string]interface{}{
+					"data":  map[string]interface{}{"type": "array", "items": item},
+					"total": map[string]interface{}{"type": "integer"},
+					"page":  map[string]interface{}{"type": "integer"},
+					"limit": map[string]interface{}{"type": "integer"},
+				},
+			},
+		}
+	case "create", "update":
+		parameters = append(parameters, map[string]interface{}{
+			"name": "body", "in": "body", "required": true, "schema": builder.ref(modelType),
+		})
+		status := "200"
+		if route.Operation == "create" {
+			status = "201"
+		}
+		responses[status] = map[string]interface{}{"description": "OK", "schema": item}
+	case "patch":
+		// 🩹 Any subset of the fields, null clears one
+		body := builder.ref(modelType)
+		body["x-partial"] = true
+		parameters = append(parameters, map[string]interface{}{
+			"name": "body", "in": "body", "required": true, "schema": body,
+		})
+		responses["200"] = map[string]interface{}{"description": "OK", "schema": item}
+	case "get":
+		for _, name := range []string{"include", "fields"} {
+			parameters = append(parameters, map[string]interface{}{"name": name, "in": "query", "type": "string"})
+		}
+		responses["200"] = map[string]interface{}{"description": "OK", "schema": item}
+	case "bulkDelete":
+		resource = pluralize(resource)
+		parameters = append(parameters, map[string]interface{}{
+			"name": "body", "in": "body", "required": true, "schema": builder.ref(reflect.TypeOf(controllers.BulkDeleteRequest{})),
+		})
+		responses["200"] = map[string]interface{}{"description": "OK", "schema": builder.ref(reflect.TypeOf(controllers.BulkDeleteResponse{}))}
+	case "delete":
+		responses["204"] = map[string]interface{}{"description": "No content"}
+	default:
+		responses["200"] = map[string]interface{}{"description": "OK", "schema": item}
+	}
+
+	operation := map[string]interface{}{
+		"operationId": route.Operation + resource,
+		"produces":    []string{"application/json"},
+		"responses":   responses,
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+// mergeDefinitions adds reflected definitions that swag has not generated
+func mergeDefinitions(spec map[string]interface{}, reflected map[string]interface{}) {
+	definitions, _ := spec["definitions"].(map[string]interface{})
+	if definitions == nil {
+		definitions = map[string]interface{}{}
+		spec["definitions"] = definitions
+	}
+	for name, definition := range reflected {
+		if _, ok := definitions[name]; !ok {
+			definitions[name] = definition
+		}
+	}
+}
+
+// echoPathToSwagger converts /teams/:id to /teams/{id}
+func echoPathToSwagger(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pluralize names list operations, e.g. listTeams
+func pluralize(name string) string {
+	if strings.HasSuffix(name, "s") {
+		return name + "es"
+	}
+	return name + "s"
+}
+
+// mergeValidationResponses adds a 400 response with per-field example messages to every
+// mutating operation whose body is one of the given DTOs
+func mergeValidationResponses(doc []byte, dtos []interface{}) ([]byte, error) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, err
+	}
+
+	examples := make(map[string]map[string]string, len(dtos))
+	for _, dto := range dtos {
+		examples[reflect.TypeOf(dto).String()] = validator.ExampleErrors(dto)
+	}
+
+	definitions, _ := spec["definitions"].(map[string]interface{})
+	if definitions == nil {
+		definitions = map[string]interface{}{}
+		spec["definitions"] = definitions
+	}
+	definitions[validationErrorDefinition] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"code": map[string]interface{}{"type": "integer", "example": http.StatusBadRequest},
+			"time": map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	}
+
+	paths, _ := spec["paths"].(map[string]interface{})
+	for _, item := range paths {
+		operations, _ := item.(map[string]interface{})
+		for method, op := range operations {
+			if method != "post" && method != "put" && method != "patch" {
+				continue
+			}
+			operation, _ := op.(map[string]interface{})
+			name := bodyDefinition(operation)
+			fieldErrors, ok := examples[name]
+			if !ok || len(fieldErrors) == 0 {
+				continue
+			}
+
+			responses, _ := operation["responses"].(map[string]interface{})
+			if responses == nil {
+				responses = map[string]interface{}{}
+				operation["responses"] = responses
+			}
+			responses["400"] = map[string]interface{}{
+				"description": "Validation error",
+				"schema":      map[string]interface{}{"$ref": "#/definitions/" + validationErrorDefinition},
+				"examples": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"error": fieldErrors,
+						"code":  http.StatusBadRequest,
+						"time":  "2025-01-01T12:00:00Z",
+					},
+				},
+			}
+		}
+	}
+
+	return json.Marshal(spec)
+}
+
+// bodyDefinition returns the definition name referenced by an operation's body parameter
+func bodyDefinition(operation map[string]interface{}) string {
+	params, _ := operation["parameters"].([]interface{})
+	for _, p := range params {
+		param, _ := p.(map[string]interface{})
+		if param["in"] != "body" {
+			continue
+		}
+		schema, _ := param["schema"].(map[string]interface{})
+		ref, _ := schema["$ref"].(string)
+		return strings.TrimPrefix(ref, "#/definitions/")
+	}
+	return ""
+}
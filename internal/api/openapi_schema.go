@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/redact"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	timestampType   = reflect.TypeOf(models.Timestamp{})
+	jsonMarshalType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaBuilder reflects Go types into swagger 2.0 schemas, adding a definition for every
+// named struct it meets. Definitions are named like swag names them, e.g. models.Team.
+type schemaBuilder struct {
+	definitions map[string]interface{}
+}
+
+// definitionName returns the swag-style definition name of a named type
+func definitionName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	return path.Base(t.PkgPath()) + "." + name
+}
+
+// ref returns a $ref to the definition of a struct, adding the definition when missing
+func (b *schemaBuilder) ref(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := definitionName(t)
+	if _, ok := b.definitions[name]; !ok {
+		// Reserve the name first so self-referencing types terminate
+		b.definitions[name] = map[string]interface{}{"type": "object"}
+		b.definitions[name] = b.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/definitions/" + name}
+}
+
+// schema returns the schema of any type
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType || t == timestampType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalType) || reflect.PointerTo(t).Implements(jsonMarshalType):
+		// Custom encodings such as datatypes.JSON can hold anything
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.ref(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object returns the schema of a struct from its json and validate tags.
+// Like swag, only fields validated as required are listed as required.
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	b.collect(t, properties, &required)
+
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+func (b *schemaBuilder) collect(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.collect(embedded, properties, required)
+				continue
+			}
+		}
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schema(field.Type)
+		if redact.Nullable(field) {
+			// Callers without the field's scope get null
+			property["x-nullable"] = true
+		}
+		properties[name] = property
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				*required = append(*required, name)
+				break
+			}
+		}
+	}
+}
+
+// responseType returns the type a model is serialised as, its ResponseMapper shape when it has one
+func responseType(model interface{}) reflect.Type {
+	ptr := reflect.New(reflect.TypeOf(model)).Interface()
+	return reflect.TypeOf(models.ResponseOf(ptr))
+}
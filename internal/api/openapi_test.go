@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"be0/internal/handlers"
+)
+
+// signUpDoc documents a single POST /register taking body as its request body
+func signUpDoc(t *testing.T, body string) []byte {
+	t.Helper()
+	doc, err := json.Marshal(map[string]interface{}{
+		"swagger": "2.0",
+		"paths": map[string]interface{}{
+			"/register": map[string]interface{}{
+				"post": map[string]interface{}{
+					"parameters": []interface{}{
+						map[string]interface{}{"in": "body", "name": "body", "schema": map[string]interface{}{"$ref": "#/definitions/" + body}},
+					},
+					"responses": map[string]interface{}{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// validationExample reads the 400 example field errors of POST /register
+func validationExample(t *testing.T, doc []byte) map[string]string {
+	t.Helper()
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Examples map[string]struct {
+					Error map[string]string `json:"error"`
+				} `json:"examples"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec.Paths["/register"]["post"].Responses["400"].Examples["application/json"].Error
+}
+
+type lenientSignUp struct {
+	Password string `json:"password" validate:"omitempty,min=8"`
+}
+
+type strictSignUp struct {
+	Password string `json:"password" validate:"required,min=12"`
+}
+
+func TestValidationExamplesFollowTheValidateTags(t *testing.T) {
+	tests := []struct {
+		dto  interface{}
+		want map[string]string
+	}{
+		{lenientSignUp{}, map[string]string{"password": "password must be at least 8"}},
+		{strictSignUp{}, map[string]string{"password": "password is required"}},
+		{handlers.RegisterRequest{}, map[string]string{
+			"email":      "email is required",
+			"password":   "password is required",
+			"first_name": "first_name is required",
+			"last_name":  "last_name is required",
+		}},
+	}
+	for _, tt := range tests {
+		name := reflect.TypeOf(tt.dto).String()
+		t.Run(name, func(t *testing.T) {
+			doc, err := mergeValidationResponses(signUpDoc(t, name), []interface{}{tt.dto})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := validationExample(t, doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("400 example %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+// signIn logs user in through the API and returns the access token
+func signIn(t *testing.T, s *Server, user *models.User) string {
+	t.Helper()
+	status, body := callJSON(t, s, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": user.Email, "password": testutil.Password,
+	})
+	token, _ := body["token"].(string)
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("login: status %d %v", status, body)
+	}
+	return token
+}
+
+func TestMemberCanReadButNotCreateTeams(t *testing.T) {
+	s, admin, _ := flowServer(t)
+	member := testutil.CreateUser(t, s.db, admin.TeamID, models.UserRoleMember)
+	token := signIn(t, s, member)
+
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", token, nil); status != http.StatusOK {
+		t.Errorf("GET /teams: status %d %v, want 200", status, body)
+	}
+	if status, body := callJSON(t, s, http.MethodPost, "/api/v1/teams", token, map[string]string{"name": "Rogue"}); status != http.StatusForbidden {
+		t.Errorf("POST /teams: status %d %v, want 403", status, body)
+	}
+}
+
+func TestInvitesNeedPermissionAndRank(t *testing.T) {
+	s, admin, _ := flowServer(t)
+	member := testutil.CreateUser(t, s.db, admin.TeamID, models.UserRoleMember)
+
+	tests := []struct {
+		name   string
+		caller *models.User
+		role   models.UserRole
+		status int
+	}{
+		{name: "member without team_invites:create", caller: member, role: models.UserRoleMember, status: http.StatusForbidden},
+		{name: "admin inviting an admin", caller: admin, role: models.UserRoleAdmin, status: http.StatusCreated},
+		{name: "admin inviting a super admin", caller: admin, role: models.UserRoleSuperAdmin, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signIn(t, s, tt.caller)
+			status, body := callJSON(t, s, http.MethodPost, "/api/v1/users/invite", token, map[string]string{
+				"email": string(tt.role) + "@example.com", "name": "Invitee", "role": string(tt.role),
+			})
+			if status != tt.status {
+				t.Errorf("status %d %v, want %d", status, body, tt.status)
+			}
+		})
+	}
+}
+
+// Routes that used to rely on the global method check now name the permission they need
+func TestMemberCannotWriteFiles(t *testing.T) {
+	s, admin, _ := flowServer(t)
+	member := testutil.CreateUser(t, s.db, admin.TeamID, models.UserRoleMember)
+	token := signIn(t, s, member)
+
+	const fileID = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/api/v1/files/upload"},
+		{http.MethodPut, "/api/v1/files/" + fileID + "/acl"},
+		{http.MethodPost, "/api/v1/files/" + fileID + "/share"},
+		{http.MethodGet, "/api/v1/sandbox/emails"},
+	}
+	for _, tt := range tests {
+		if status, body := callJSON(t, s, tt.method, tt.path, token, map[string]string{}); status != http.StatusForbidden {
+			t.Errorf("%s %s: status %d %v, want 403", tt.method, tt.path, status, body)
+		}
+	}
+}
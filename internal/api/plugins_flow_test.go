@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/plugins"
+
+	"github.com/labstack/echo/v4"
+)
+
+// greeter is a plugin answering with the caller the auth middleware identified
+type greeter struct {
+	plugins.Base
+}
+
+func (greeter) Name() string { return "greeter" }
+
+func (greeter) Routes(g *echo.Group) {
+	g.GET("/greeter/hello", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"userId": c.Get("userID").(string)})
+	})
+}
+
+func TestPluginRoutesAreBehindTheAuthMiddleware(t *testing.T) {
+	plugins.Register(greeter{})
+	t.Cleanup(func() { plugins.Unregister("greeter") })
+	s, user, _ := flowServer(t)
+
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/greeter/hello", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d %v, want 401", status, body)
+	}
+
+	status, body := callJSON(t, s, http.MethodGet, "/api/v1/greeter/hello", signIn(t, s, user), nil)
+	if status != http.StatusOK || body["userId"] != user.ID {
+		t.Errorf("signed in: status %d %v, want 200 with the user", status, body)
+	}
+}
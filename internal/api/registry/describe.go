@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CRUDRoute describes a generated CRUD route so it can be documented without swag annotations
+type CRUDRoute struct {
+	Method string
+	// Path is the echo route path, e.g. /api/v1/teams/:id
+	Path string
+	// Operation is list, get, create, update, patch, delete, bulkDelete or restore
+	Operation string
+	Model     interface{}
+}
+
+var (
+	crudRoutes   = map[string]CRUDRoute{}
+	crudRoutesMu sync.RWMutex
+)
+
+// describe records the model and operation behind a CRUD route
+func describe(route *echo.Route, model interface{}, operation string) {
+	crudRoutesMu.Lock()
+	defer crudRoutesMu.Unlock()
+	crudRoutes[route.Method+" "+route.Path] = CRUDRoute{
+		Method:    route.Method,
+		Path:      route.Path,
+		Operation: operation,
+		Model:     model,
+	}
+}
+
+// CRUDRoutes returns the routes registered by RegisterCRUDRoutes
+func CRUDRoutes() []CRUDRoute {
+	crudRoutesMu.RLock()
+	defer crudRoutesMu.RUnlock()
+
+	routes := make([]CRUDRoute, 0, len(crudRoutes))
+	for _, route := range crudRoutes {
+		routes = append(routes, route)
+	}
+	return routes
+}
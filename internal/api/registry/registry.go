@@ -17,9 +17,12 @@ import (
 // @Accept json
 // @Produce json
 func RegisterCRUDRoutes(g *echo.Group, db *gorm.DB) {
+	// Reject malformed :id params before they reach the database
+	g.Use(middleware.ValidateUUIDParams())
+
 	// Teams
-	teamService := services.NewBaseService(db, models.Team{})
-	teamController := controllers.NewBaseController(teamService)
+	teamService := services.NewCoalescingService(services.NewBaseService(db, models.Team{}), "teams")
+	teamController := controllers.NewBaseController(teamService, controllers.WithIncludes("Users"))
 	teamGroup := g.Group("/teams")
 	teamGroup.Use(middleware.RequirePermissions(db, "teams:read"))
 
@@ -32,19 +35,21 @@ func RegisterCRUDRoutes(g *echo.Group, db *gorm.DB) {
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/teams [get]
-	teamGroup.GET("", teamController.List)
+	describe(teamGroup.GET("", teamController.List), models.Team{}, "list")
 	// @Summary Get team
 	// @Description Get a team by ID
 	// @Accept json
 	// @Produce json
 	// @Param id path string true "Team ID"
+	// @Param If-None-Match header string false "ETag of the version the caller holds"
 	// @Success 200 {object} models.Team
+	// @Success 304 "Not modified"
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 404 {object} map[string]string "Not found"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/teams/{id} [get]
-	teamGroup.GET("/:id", teamController.Get)
+	describe(teamGroup.GET("/:id", teamController.Get), models.Team{}, "get")
 
 	// Protected team routes
 	teamWriteGroup := teamGroup.Group("")
@@ -60,37 +65,72 @@ func RegisterCRUDRoutes(g *echo.Group, db *gorm.DB) {
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/teams [post]
-	teamWriteGroup.POST("", teamController.Create)
+	describe(teamWriteGroup.POST("", teamController.Create), models.Team{}, "create")
 	// @Summary Update team
 	// @Description Update an existing team
 	// @Accept json
 	// @Produce json
 	// @Param id path string true "Team ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
 	// @Param team body models.Team true "Team object"
 	// @Success 200 {object} models.Team
 	// @Failure 400 {object} map[string]string "Bad request"
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Based on a stale version"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/teams/{id} [put]
-	teamWriteGroup.PUT("/:id", teamController.Update)
+	describe(teamWriteGroup.PUT("/:id", teamController.Update), models.Team{}, "update")
+	// @Summary Patch team
+	// @Description Change only the fields in the body. A null value clears a field, false and 0 are stored as sent.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Team ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
+	// @Param team body object true "Fields to change"
+	// @Success 200 {object} models.Team
+	// @Failure 400 {object} map[string]string "Unknown or read-only field"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Based on a stale version, or the auth policy would lock out every admin"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/teams/{id} [patch]
+	describe(teamWriteGroup.PATCH("/:id", teamController.Patch), models.Team{}, "patch")
 	// @Summary Delete team
-	// @Description Delete a team
+	// @Description Delete a team. Its users are deactivated, pending invites expire, file shares are deleted and webhooks stop, all in one transaction. Files are kept but cannot be reached until a super admin restores the team.
 	// @Accept json
 	// @Produce json
 	// @Param id path string true "Team ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
 	// @Success 204 "No content"
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/teams/{id} [delete]
-	teamWriteGroup.DELETE("/:id", teamController.Delete)
+	describe(teamWriteGroup.DELETE("/:id", teamController.Delete), models.Team{}, "delete")
+	// @Summary Restore team
+	// @Description Undo the deletion of a team and reverse its cascade. Super admins only, as teams don't belong to a team.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Team ID"
+	// @Success 200 {object} models.Team
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "A live team took its slug"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/teams/{id}/restore [post]
+	describe(teamGroup.POST("/:id/restore", teamController.Restore, middleware.RequireSuperAdmin(), middleware.RequirePermissions(db, "teams:restore")), models.Team{}, "restore")
 
 	// Team Invitations with team-specific permissions
 	invitationService := services.NewBaseService(db, models.TeamInvite{})
-	invitationController := controllers.NewBaseController(invitationService)
+	invitationController := controllers.NewBaseController(invitationService, controllers.WithIncludes("Team", "Inviter"))
 	invitationGroup := g.Group("/team-invitations")
 	invitationGroup.Use(middleware.RequirePermissions(db, "team_invites:read"))
 	// @Summary List team invitations
@@ -102,7 +142,7 @@ func RegisterCRUDRoutes(g *echo.Group, db *gorm.DB) {
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/team-invitations [get]
-	invitationGroup.GET("", invitationController.List)
+	describe(invitationGroup.GET("", invitationController.List), models.TeamInvite{}, "list")
 
 	// Protected invitation routes
 	invitationWriteGroup := invitationGroup.Group("")
@@ -112,39 +152,207 @@ func RegisterCRUDRoutes(g *echo.Group, db *gorm.DB) {
 	// @Accept json
 	// @Produce json
 	// @Param id path string true "Invitation ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
 	// @Success 204 "No content"
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/team-invitations/{id} [delete]
-	invitationWriteGroup.DELETE("/:id", invitationController.Delete)
+	describe(invitationWriteGroup.DELETE("/:id", invitationController.Delete), models.TeamInvite{}, "delete")
+	// @Summary Bulk delete team invitations
+	// @Description Delete up to 500 team invitations of the caller's team at once. IDs that are missing, already deleted or of another team are skipped.
+	// @Accept json
+	// @Produce json
+	// @Param body body controllers.BulkDeleteRequest true "Invitation IDs"
+	// @Success 200 {object} controllers.BulkDeleteResponse
+	// @Failure 400 {object} map[string]string "Bad request"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/team-invitations/bulk-delete [post]
+	describe(invitationGroup.POST("/bulk-delete", invitationController.BulkDelete, middleware.RequirePermissions(db, "team_invites:delete")), models.TeamInvite{}, "bulkDelete")
+	// @Summary Restore team invitation
+	// @Description Undo the deletion of a team invitation of the caller's team. Admins only.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Invitation ID"
+	// @Success 200 {object} models.TeamInvite
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "A pending invitation to the same email exists"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/team-invitations/{id}/restore [post]
+	describe(invitationGroup.POST("/:id/restore", invitationController.Restore, middleware.RequirePermissions(db, "team_invites:restore")), models.TeamInvite{}, "restore")
 
 	// file routes
-	fileService := services.NewBaseService(db, models.File{})
-	fileController := controllers.NewBaseController(fileService)
+	fileService := services.NewCoalescingService(services.NewBaseService(db, models.File{}), "files")
+	fileController := controllers.NewBaseController(fileService, controllers.WithIncludes("Team", "User"))
 	fileGroup := g.Group("/files")
 	fileGroup.Use(middleware.RequirePermissions(db, "files:read"))
 	// @Summary List files
-	// @Description Get a list of all files
+	// @Description Get a list of all files. q searches names and the text of extracted documents, and sets matchedIn on results.
 	// @Accept json
 	// @Produce json
+	// @Param q query string false "Search names and document content"
 	// @Success 200 {array} models.File
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/files [get]
-	fileGroup.GET("", fileController.List)
+	describe(fileGroup.GET("", fileController.List), models.File{}, "list")
 	// @Summary Get file
 	// @Description Get a file by ID
 	// @Accept json
 	// @Produce json
 	// @Param id path string true "File ID"
+	// @Param If-None-Match header string false "ETag of the version the caller holds"
 	// @Success 200 {object} models.File
+	// @Success 304 "Not modified"
 	// @Failure 401 {object} map[string]string "Unauthorized"
 	// @Failure 403 {object} map[string]string "Forbidden"
 	// @Failure 404 {object} map[string]string "Not found"
 	// @Failure 500 {object} map[string]string "Internal server error"
 	// @Router /api/v1/files/{id} [get]
-	fileGroup.GET("/:id", fileController.Get)
+	describe(fileGroup.GET("/:id", fileController.Get), models.File{}, "get")
+	// @Summary Restore file
+	// @Description Undo the deletion of a file of the caller's team. Admins only.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "File ID"
+	// @Success 200 {object} models.File
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Conflict"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/files/{id}/restore [post]
+	describe(fileGroup.POST("/:id/restore", fileController.Restore, middleware.RequirePermissions(db, "files:restore")), models.File{}, "restore")
+
+	// template routes
+	templateService := services.NewBaseService(db, models.Template{})
+	templateController := controllers.NewBaseController(templateService, controllers.WithIncludes("Team"))
+	templateGroup := g.Group("/templates")
+	templateGroup.Use(middleware.RequirePermissions(db, "templates:read"))
+	// @Summary List templates
+	// @Description Get a list of all templates
+	// @Accept json
+	// @Produce json
+	// @Success 200 {array} models.Template
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates [get]
+	describe(templateGroup.GET("", templateController.List), models.Template{}, "list")
+	// @Summary Get template
+	// @Description Get a template by ID
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Template ID"
+	// @Param If-None-Match header string false "ETag of the version the caller holds"
+	// @Success 200 {object} models.Template
+	// @Success 304 "Not modified"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/{id} [get]
+	describe(templateGroup.GET("/:id", templateController.Get), models.Template{}, "get")
+
+	// Template writes are sanitized, skip_sanitize is reserved for super admins
+	templateWriteGroup := templateGroup.Group("")
+	templateWriteGroup.Use(middleware.RequirePermissions(db, "templates:write"), middleware.SkipSanitize())
+	// @Summary Create template
+	// @Description Create a template. The HTML is sanitized and linted, findings are returned as warnings and hard errors reject the template with 422.
+	// @Accept json
+	// @Produce json
+	// @Param skip_sanitize query bool false "Store the HTML as submitted, super admins only"
+	// @Param template body models.Template true "Template object"
+	// @Success 201 {object} models.Template
+	// @Failure 400 {object} map[string]string "Bad request"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 422 {object} models.TemplateRejection "Template rejected"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates [post]
+	describe(templateWriteGroup.POST("", templateController.Create), models.Template{}, "create")
+	// @Summary Update template
+	// @Description Update a template. The HTML is sanitized and linted like on create.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Template ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
+	// @Param skip_sanitize query bool false "Store the HTML as submitted, super admins only"
+	// @Param template body models.Template true "Template object"
+	// @Success 200 {object} models.Template
+	// @Failure 400 {object} map[string]string "Bad request"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Based on a stale version"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
+	// @Failure 422 {object} models.TemplateRejection "Template rejected"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/{id} [put]
+	describe(templateWriteGroup.PUT("/:id", templateController.Update), models.Template{}, "update")
+	// @Summary Patch template
+	// @Description Change only the fields in the body. The resulting HTML is sanitized and linted like on update.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Template ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
+	// @Param skip_sanitize query bool false "Store the HTML as submitted, super admins only"
+	// @Param template body object true "Fields to change"
+	// @Success 200 {object} models.Template
+	// @Failure 400 {object} map[string]string "Unknown or read-only field"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Based on a stale version"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
+	// @Failure 422 {object} models.TemplateRejection "Template rejected"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/{id} [patch]
+	describe(templateWriteGroup.PATCH("/:id", templateController.Patch), models.Template{}, "patch")
+	// @Summary Delete template
+	// @Description Delete a template
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Template ID"
+	// @Param If-Match header string false "ETag of the version the change is based on"
+	// @Success 204 "No content"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 412 {object} map[string]string "Changed since the If-Match version"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/{id} [delete]
+	describe(templateWriteGroup.DELETE("/:id", templateController.Delete), models.Template{}, "delete")
+	// @Summary Bulk delete templates
+	// @Description Delete up to 500 templates of the caller's team at once. IDs that are missing, already deleted or of another team are skipped.
+	// @Accept json
+	// @Produce json
+	// @Param body body controllers.BulkDeleteRequest true "Template IDs"
+	// @Success 200 {object} controllers.BulkDeleteResponse
+	// @Failure 400 {object} map[string]string "Bad request"
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/bulk-delete [post]
+	describe(templateGroup.POST("/bulk-delete", templateController.BulkDelete, middleware.RequirePermissions(db, "templates:delete")), models.Template{}, "bulkDelete")
+	// @Summary Restore template
+	// @Description Undo the deletion of a template of the caller's team. Admins only.
+	// @Accept json
+	// @Produce json
+	// @Param id path string true "Template ID"
+	// @Success 200 {object} models.Template
+	// @Failure 401 {object} map[string]string "Unauthorized"
+	// @Failure 403 {object} map[string]string "Forbidden"
+	// @Failure 404 {object} map[string]string "Not found"
+	// @Failure 409 {object} map[string]string "Conflict"
+	// @Failure 500 {object} map[string]string "Internal server error"
+	// @Router /api/v1/templates/{id}/restore [post]
+	describe(templateGroup.POST("/:id/restore", templateController.Restore, middleware.RequirePermissions(db, "templates:restore")), models.Template{}, "restore")
 }
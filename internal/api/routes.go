@@ -1,10 +1,17 @@
 package api
 
 import (
-	"be0/internal/api/middleware"
+	"be0/internal/api/controllers"
+	apimiddleware "be0/internal/api/middleware"
 	"be0/internal/api/registry"
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/metrics"
+	"be0/internal/plugins"
 	"be0/internal/routes"
 	"net/http"
+	"sync"
+	"time"
 
 	_ "be0/docs/swagger"
 
@@ -12,6 +19,20 @@ import (
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
+var followQueryConfig sync.Once
+
+// applyQueryConfig sets the list query limits and strict parameter default of every controller
+func applyQueryConfig(query config.QueryConfig) {
+	controllers.SetQueryLimits(controllers.QueryLimits{
+		MaxQueryLength: query.MaxLength,
+		MaxFilters:     query.MaxFilters,
+		MaxIncludes:    query.MaxIncludes,
+		MaxSortFields:  query.MaxSortFields,
+		MaxExcludes:    query.MaxExcludes,
+	})
+	apimiddleware.SetStrictParams(query.StrictParams)
+}
+
 func (s *Server) registerRoutes() {
 	s.echo.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello, World!")
@@ -24,17 +45,64 @@ func (s *Server) registerRoutes() {
 	// @Success 200 {object} map[string]string "OK"
 	// @Router /health [get]
 	s.echo.GET("/health", s.healthCheck)
+	// Readiness check
+	// @Summary Readiness check
+	// @Description Returns 503 until the database and storage warm-up has finished
+	// @Produce json
+	// @Success 200 {object} map[string]string "Ready"
+	// @Failure 503 {object} map[string]string "Warming up"
+	// @Router /ready [get]
+	s.echo.GET("/ready", s.readinessCheck)
+	s.echo.GET("/swagger/doc.json", s.serveOpenAPI)
 	s.echo.GET("/swagger/*", echoSwagger.WrapHandler)
+	// 📈 Metrics are for scrapers, served on the internal METRICS_PORT or here with the metrics token
+	if metricsCfg := s.config.Metrics; metricsCfg.Port == 0 {
+		if metricsCfg.Token != "" {
+			s.echo.GET("/metrics", echo.WrapHandler(metrics.Handler()), metrics.RequireToken(metricsCfg.Token))
+		} else {
+			log.Warn("/metrics is not served, set METRICS_TOKEN or METRICS_PORT to scrape it")
+		}
+	}
+	s.echo.GET("/.well-known/jwks.json", s.serveJWKS)
 
 	// API v1 group
 	api := s.echo.Group("/api/v1")
-	auth := middleware.NewAuthMiddleware(s.config.JWT.Secret)
-	api.Use(auth.Middleware())
+	api.Use(s.auth.Middleware())
+	if admission := s.config.Server.Admission; admission.Enabled && admission.MaxInFlight > 0 {
+		api.Use(apimiddleware.NewAdmission(apimiddleware.AdmissionConfig{
+			MaxInFlight: admission.MaxInFlight,
+			QueueSize:   admission.QueueSize,
+			ReadWait:    time.Duration(admission.ReadWait) * time.Millisecond,
+			WriteWait:   time.Duration(admission.WriteWait) * time.Millisecond,
+		}).Middleware())
+	}
+	api.Use(apimiddleware.DebugCapture(s.debug))
 
 	// Register CRUD routes for all models
 	// @Summary Register CRUD routes for all models
 	// @Description Register CRUD routes for all models
+	applyQueryConfig(s.config.Server.Query)
+	// 🔁 Reloads change the limits of running servers too
+	followQueryConfig.Do(func() {
+		events.OnNamed(config.EventReloaded, "api.applyQueryConfig", func(data interface{}) {
+			applyQueryConfig(data.(*config.Config).Server.Query)
+		})
+	})
 	registry.RegisterCRUDRoutes(api, s.db)
 
-	routes.SetupUploadRoutes(api, s.config)
+	routes.SetupUploadRoutes(api, s.db, s.config)
+	routes.SetupAdminRoutes(api, s.db, s.config, s.debug)
+	routes.SetupWebhookRoutes(api, s.db)
+	routes.SetupTemplateRoutes(api, s.db)
+	routes.SetupOrganizationRoutes(api, s.db)
+	routes.SetupAPIKeyRoutes(api, s.db)
+	routes.SetupAuditLogRoutes(api, s.db)
+	routes.SetupEmailRoutes(api, s.db)
+	routes.SetupSandboxRoutes(api, s.db)
+	routes.SetupShareRoutes(api, s.echo, s.db)
+	routes.SetupImageRoutes(api, s.echo, s.db, s.redis, s.config)
+	routes.SetupJobRoutes(api, s.db, s.redis)
+
+	// 🧩 Routes of compiled-in plugins, behind the same middleware
+	plugins.RegisterRoutes(api)
 }
@@ -4,22 +4,27 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-advanced-admin/admin"
 	admingorm "github.com/go-advanced-admin/orm-gorm"
 	adminecho "github.com/go-advanced-admin/web-echo"
-	"golang.org/x/time/rate"
 
+	apimiddleware "be0/internal/api/middleware"
 	"be0/internal/api/validator"
 	"be0/internal/config"
+	"be0/internal/metrics"
 	"be0/internal/models"
 	"be0/internal/routes"
+	ratelimit "be0/internal/tasks/rate"
 
 	console "be0/internal/utils/logger"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -27,8 +32,18 @@ type Server struct {
 	echo   *echo.Echo
 	config *config.Config
 	db     *gorm.DB
+	redis  *redis.Client
+	auth   *apimiddleware.AuthMiddleware
+	debug  *apimiddleware.DebugCaptureStore
+	audit  *apimiddleware.AuditLogger
+	// requests records API key requests for their analytics, nil when disabled
+	requests *apimiddleware.APIRequestLogger
+	ready    atomic.Bool
 }
 
+// auditLogBufferSize is how many audit logs wait for the database before new ones are dropped
+const auditLogBufferSize = 1024
+
 var log = console.New("API-Server")
 
 // NewServer @title Kori API
@@ -36,29 +51,43 @@ var log = console.New("API-Server")
 // @description This is the API documentation for the Kori project.
 // @host localhost:8080
 // @BasePath /api/v1
-func NewServer(cfg *config.Config, db *gorm.DB) *Server {
+func NewServer(cfg *config.Config, db *gorm.DB, redisClient *redis.Client) *Server {
 	e := echo.New()
 
 	// Create custom validator
 	e.Validator = validator.NewValidator()
 
 	// Configure middleware
+	e.Use(metrics.HTTPMiddleware())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodOptions},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderContentLength},
+		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete, http.MethodOptions},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderContentLength, apimiddleware.StorageRegionHeader, apimiddleware.DeviceIDHeader, apimiddleware.HeaderStrictParams, "If-Match", "If-None-Match"},
+		// 🏷️ Browser scripts can only read the ETag they send back when it is exposed
+		ExposeHeaders: []string{"ETag"},
 	}))
 	e.Use(middleware.RequestID())
+	// 🔖 Log lines of a request carry its ID, and its caller once authenticated
+	e.Use(apimiddleware.RequestLogFields())
 	e.Use(middleware.Secure())
 	e.Use(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+		// Long-poll routes bound their own wait
+		Skipper: func(c echo.Context) bool {
+			return strings.HasSuffix(c.Path(), "/wait")
+		},
 		Timeout: 30 * time.Second,
 	}))
-	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
-		Level: 5,
+	e.Use(apimiddleware.Compression(apimiddleware.CompressionConfig{
+		Level:      cfg.Server.GzipLevel,
+		MinLength:  cfg.Server.GzipMinLength,
+		SkipAccept: cfg.Server.GzipSkipAccept,
 	}))
-	e.Use(middleware.BodyLimit("10M"))
+	e.Use(middleware.BodyLimit(cfg.Server.BodyLimit))
+	if cfg.Storage.S3.ReplicaBucket != "" {
+		e.Use(apimiddleware.StorageRegion(cfg.Storage.S3.ReplicaRegion, cfg.Storage.S3.ReplicaCountries))
+	}
 
 	// Custom error handler
 	e.HTTPErrorHandler = customHTTPErrorHandler
@@ -68,13 +97,27 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 		echo:   e,
 		config: cfg,
 		db:     db,
+		redis:  redisClient,
+		auth:   apimiddleware.NewAuthMiddleware(cfg.JWT.Secret),
+		debug: apimiddleware.NewDebugCaptureStore(
+			redisClient,
+			apimiddleware.DebugCaptureConfig{
+				Enabled:     cfg.Debug.CaptureEnabled,
+				Teams:       cfg.Debug.CaptureTeams,
+				RedactPaths: cfg.Debug.RedactPaths,
+				MaxBodySize: cfg.Debug.MaxBodySize,
+				TTL:         time.Duration(cfg.Debug.TTL) * time.Minute,
+				BufferSize:  int64(cfg.Debug.BufferSize),
+			},
+		),
+		audit: apimiddleware.NewAuditLogger(db, auditLogBufferSize),
 	}
 
-	// Seed permissions
-	if err := models.SeedPermissions(db); err != nil {
-		log.Warn("Warning: Failed to seed permissions: %v", err)
-	} else {
-		log.Success("Successfully seeded permissions")
+	// 📜 Mutating requests are audited once the routes' auth middleware identified the caller
+	e.Use(apimiddleware.AuditLog(s.audit))
+	if cfg.APIAnalytics.Enabled {
+		s.requests = apimiddleware.NewAPIRequestLogger(db, cfg.APIAnalytics.BufferSize, cfg.APIAnalytics.SampleRate)
+		e.Use(apimiddleware.APIAnalytics(s.requests))
 	}
 
 	if err := models.CreateSuperAdminFromEnv(db, cfg); err != nil {
@@ -83,7 +126,20 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 		log.Success("Successfully created super admin")
 	}
 
-	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(20))))
+	// 🚦 Callers are rate limited per user, API key or IP across every instance
+	if cfg.RateLimit.Enabled {
+		window := time.Duration(cfg.RateLimit.Window) * time.Second
+		limiter := apimiddleware.NewRateLimiter(
+			ratelimit.NewSlidingWindow(redisClient, "api_rate_limit", window),
+			s.auth,
+			apimiddleware.RateLimitConfig{
+				User:   cfg.RateLimit.User,
+				APIKey: cfg.RateLimit.APIKey,
+				IP:     cfg.RateLimit.IP,
+			},
+		)
+		e.Use(limiter.Middleware())
+	}
 
 	// Create a new GORM integrator
 	gormIntegrator := admingorm.NewIntegrator(db)
@@ -122,7 +178,9 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 		}
 	}
 
-	routes.SetupAuthRoutes(s.echo, s.db, s.config)
+	routes.SetupAuthRoutes(s.echo, s.db, s.redis, s.config)
+	// 🪝 Signed callbacks of integrations, outside the authenticated API group
+	routes.SetupHookRoutes(s.echo, s.db, s.config.Hooks)
 
 	// Register routes
 	s.registerRoutes()
@@ -133,16 +191,45 @@ func (s *Server) Start() error {
 	return s.echo.Start(fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port))
 }
 
+// Auth returns the auth middleware guarding the API routes
+func (s *Server) Auth() *apimiddleware.AuthMiddleware {
+	return s.auth
+}
+
+// Shutdown stops the server and writes the audit and API request logs still queued
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.echo.Shutdown(ctx)
+	err := s.echo.Shutdown(ctx)
+	s.audit.Close()
+	if s.requests != nil {
+		s.requests.Close()
+	}
+	return err
 }
 
 // Health check endpoint
+// MarkReady flips the readiness check to ready once the warm-up has finished
+func (s *Server) MarkReady() {
+	s.ready.Store(true)
+}
+
+func (s *Server) readinessCheck(c echo.Context) error {
+	if !s.ready.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "warming up",
+			"time":   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "ready",
+		"time":   time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
 func (s *Server) healthCheck(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "healthy",
 		"version": "1.0.0",
-		"time":    time.Now().Format(time.RFC3339),
+		"time":    time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
@@ -176,7 +263,7 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 			err = c.JSON(code, map[string]interface{}{
 				"error": message,
 				"code":  code,
-				"time":  time.Now().Format(time.RFC3339),
+				"time":  time.Now().UTC().Format(time.RFC3339),
 			})
 		}
 		if err != nil {
@@ -189,42 +276,7 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 func formatValidationErrors(errors validator.ValidationErrors) map[string]string {
 	errMap := make(map[string]string)
 	for _, err := range errors {
-		field := err.Field()
-		tag := err.Tag()
-		param := err.Param()
-
-		switch tag {
-		case "required":
-			errMap[field] = fmt.Sprintf("%s is required", field)
-		case "email":
-			errMap[field] = fmt.Sprintf("%s must be a valid email", field)
-		case "min":
-			errMap[field] = fmt.Sprintf("%s must be at least %s", field, param)
-		case "max":
-			errMap[field] = fmt.Sprintf("%s must be at most %s", field, param)
-		case "url":
-			errMap[field] = fmt.Sprintf("%s must be a valid URL", field)
-		case "uuid":
-			errMap[field] = fmt.Sprintf("%s must be a valid UUID", field)
-		case "oneof":
-			errMap[field] = fmt.Sprintf("%s must be one of [%s]", field, param)
-		case "hostname":
-			errMap[field] = fmt.Sprintf("%s must be a valid hostname", field)
-		case "fqdn":
-			errMap[field] = fmt.Sprintf("%s must be a valid domain name", field)
-		case "gt":
-			errMap[field] = fmt.Sprintf("%s must be greater than %s", field, param)
-		case "required_if":
-			errMap[field] = fmt.Sprintf("%s is required when %s", field, param)
-		case "json":
-			errMap[field] = fmt.Sprintf("%s must be valid JSON", field)
-		case "user_role":
-			errMap[field] = fmt.Sprintf("%s must be either 'admin' or 'member'", field)
-		case "campaign_status":
-			errMap[field] = fmt.Sprintf("%s must be one of: DRAFT, SCHEDULED, RUNNING, COMPLETED, FAILED", field)
-		default:
-			errMap[field] = fmt.Sprintf("%s failed validation: %s", field, tag)
-		}
+		errMap[err.Field()] = validator.Message(err.Field(), err.Tag(), err.Param())
 	}
 	return errMap
 }
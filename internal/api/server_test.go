@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// assertUTCTime checks that the response has a time field in RFC3339 UTC
+func assertUTCTime(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	var body struct {
+		Time string `json:"time"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := time.Parse(time.RFC3339, body.Time)
+	if err != nil {
+		t.Fatalf("time %q is not RFC3339: %v", body.Time, err)
+	}
+	if _, offset := parsed.Zone(); offset != 0 || body.Time[len(body.Time)-1] != 'Z' {
+		t.Errorf("time %q is not UTC", body.Time)
+	}
+}
+
+func TestHealthCheckTimeIsUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/health", nil), rec)
+	if err := (&Server{}).healthCheck(c); err != nil {
+		t.Fatal(err)
+	}
+	assertUTCTime(t, rec)
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/ready", nil), rec)
+	if err := (&Server{}).readinessCheck(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d before MarkReady, want 503", rec.Code)
+	}
+	assertUTCTime(t, rec)
+
+	server := &Server{}
+	server.MarkReady()
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodGet, "/ready", nil), rec)
+	if err := server.readinessCheck(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status %d after MarkReady, want 200", rec.Code)
+	}
+}
+
+func TestErrorHandlerTimeIsUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/missing", nil), rec)
+	customHTTPErrorHandler(echo.NewHTTPError(http.StatusNotFound, "not found"), c)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+	assertUTCTime(t, rec)
+}
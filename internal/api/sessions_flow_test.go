@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+)
+
+func TestExpiredAndRevokedSessionsAreRefused(t *testing.T) {
+	s, admin, _ := flowServer(t)
+	if s.config.JWT.MaxSessions <= 0 {
+		t.Skip("AUTH_MAX_SESSIONS disables the session cap")
+	}
+	first := signIn(t, s, admin)
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", first, nil); status != http.StatusOK {
+		t.Fatalf("fresh session: status %d %v", status, body)
+	}
+
+	// 🎫 Signing in beyond the cap revokes the oldest session
+	var latest string
+	for range s.config.JWT.MaxSessions {
+		latest = signIn(t, s, admin)
+	}
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", first, nil); status != http.StatusUnauthorized {
+		t.Errorf("session beyond the cap: status %d %v, want 401", status, body)
+	}
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", latest, nil); status != http.StatusOK {
+		t.Fatalf("latest session: status %d %v", status, body)
+	}
+
+	// ⏳ A session past its expiry is refused even though its access token is still valid
+	if err := s.db.Model(&models.AuthTransaction{}).Where("user_id = ?", admin.ID).
+		Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", latest, nil); status != http.StatusUnauthorized {
+		t.Errorf("expired session: status %d %v, want 401", status, body)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestDeletedTeamLocksOutItsMembersUntilRestored(t *testing.T) {
+	s, admin, _ := flowServer(t)
+	ops := testutil.CreateTeam(t, s.db, "Ops")
+	superAdmin := testutil.CreateUser(t, s.db, ops.ID, models.UserRoleSuperAdmin)
+	memberToken := signIn(t, s, admin)
+	superToken := signIn(t, s, superAdmin)
+
+	if status, body := callJSON(t, s, http.MethodDelete, "/api/v1/teams/"+admin.TeamID, superToken, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE /teams: status %d %v", status, body)
+	}
+
+	// 🚪 Existing tokens and new sign-ins are refused
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", memberToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("token of the deleted team: status %d %v, want 401", status, body)
+	}
+	status, body := callJSON(t, s, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": admin.Email, "password": testutil.Password,
+	})
+	if status != http.StatusUnauthorized {
+		t.Errorf("login to the deleted team: status %d %v, want 401", status, body)
+	}
+
+	// ♻️ Restoring the team lets its members back in
+	if status, body := callJSON(t, s, http.MethodPost, "/api/v1/admin/teams/"+admin.TeamID+"/restore", superToken, nil); status != http.StatusOK {
+		t.Fatalf("restore: status %d %v", status, body)
+	}
+	if status, body := callJSON(t, s, http.MethodGet, "/api/v1/teams", signIn(t, s, admin), nil); status != http.StatusOK {
+		t.Errorf("after the restore: status %d %v, want 200", status, body)
+	}
+	if status, body := callJSON(t, s, http.MethodPost, "/api/v1/admin/teams/"+admin.TeamID+"/restore", superToken, nil); status != http.StatusNotFound {
+		t.Errorf("restoring twice: status %d %v, want 404", status, body)
+	}
+}
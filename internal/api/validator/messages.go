@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Message returns the client-facing message for a failed validation tag
+func Message(field, tag, param string) string {
+	switch tag {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "uuid":
+		return fmt.Sprintf("%s must be a valid UUID", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, param)
+	case "hostname":
+		return fmt.Sprintf("%s must be a valid hostname", field)
+	case "fqdn":
+		return fmt.Sprintf("%s must be a valid domain name", field)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "required_if":
+		return fmt.Sprintf("%s is required when %s", field, param)
+	case "json":
+		return fmt.Sprintf("%s must be valid JSON", field)
+	case "user_role":
+		return fmt.Sprintf("%s must be either 'admin' or 'member'", field)
+	case "campaign_status":
+		return fmt.Sprintf("%s must be one of: DRAFT, SCHEDULED, RUNNING, COMPLETED, FAILED", field)
+	default:
+		return fmt.Sprintf("%s failed validation: %s", field, tag)
+	}
+}
+
+// ExampleErrors builds the validation error map a client would get for v, with one
+// message per validated field derived from the first rule of its validate tag
+func ExampleErrors(v interface{}) map[string]string {
+	examples := make(map[string]string)
+	collectExamples(reflect.TypeOf(v), examples)
+	return examples
+}
+
+func collectExamples(t reflect.Type, examples map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			collectExamples(field.Type, examples)
+			continue
+		}
+
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "" || rule == "omitempty" || rule == "dive" || rule == "-" {
+				continue
+			}
+			tag, param, _ := strings.Cut(rule, "=")
+			examples[name] = Message(name, tag, param)
+			break
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Audit struct {
+	Reason string `json:"reason" validate:"max=200"`
+}
+
+type signUp struct {
+	Audit
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"omitempty,min=8"`
+	Role     string   `json:"role" validate:"oneof=admin member"`
+	Tags     []string `json:"tags" validate:"dive,uuid"`
+	Name     string   `validate:"required"`
+	Note     string   `json:"note"`
+	Secret   string   `json:"-" validate:"required"`
+	internal string   `validate:"required"`
+}
+
+// signUpStricter differs from signUp only in its validate tags
+type signUpStricter struct {
+	Audit
+	Email    string   `json:"email" validate:"email,required"`
+	Password string   `json:"password" validate:"required,min=12"`
+	Role     string   `json:"role" validate:"user_role"`
+	Tags     []string `json:"tags" validate:"dive,uuid"`
+	Name     string   `validate:"required"`
+	Note     string   `json:"note" validate:"max=500"`
+	Secret   string   `json:"-" validate:"required"`
+	internal string   `validate:"required"`
+}
+
+func TestExampleErrorsFollowTheValidateTags(t *testing.T) {
+	tests := []struct {
+		name string
+		dto  interface{}
+		want map[string]string
+	}{
+		{"signUp", &signUp{}, map[string]string{
+			"reason":   "reason must be at most 200",
+			"email":    "email is required",
+			"password": "password must be at least 8",
+			"role":     "role must be one of [admin member]",
+			"tags":     "tags must be a valid UUID",
+			"Name":     "Name is required",
+		}},
+		{"signUpStricter", signUpStricter{}, map[string]string{
+			"reason":   "reason must be at most 200",
+			"email":    "email must be a valid email",
+			"password": "password is required",
+			"role":     "role must be either 'admin' or 'member'",
+			"tags":     "tags must be a valid UUID",
+			"Name":     "Name is required",
+			"note":     "note must be at most 500",
+		}},
+		{"not a struct", "x", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExampleErrors(tt.dto); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -61,7 +61,7 @@ func validateUserRole(fl playgroundvalidator.FieldLevel) bool {
 
 func validateInviteStatus(fl playgroundvalidator.FieldLevel) bool {
 	status := fl.Field().String()
-	return status == "PENDING" || status == "ACCEPTED" || status == "REJECTED"
+	return status == "PENDING" || status == "ACCEPTED" || status == "REJECTED" || status == "EXPIRED"
 }
 
 func validateEmailTrackingEvent(fl playgroundvalidator.FieldLevel) bool {
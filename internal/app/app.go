@@ -0,0 +1,168 @@
+// Package app holds the startup shared by every run mode of the be0 binary
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/db"
+	"be0/internal/handlers"
+	"be0/internal/imaging"
+	"be0/internal/jobs"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/tasks"
+	"be0/internal/utils"
+	"be0/internal/utils/crypto"
+	"be0/internal/utils/logger"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// App is the initialized process state the API and the worker are built from
+type App struct {
+	Config  *config.Config
+	DB      *gorm.DB
+	Storage *services.S3Service
+	// Redis is the client shared by every component that talks to Redis directly
+	Redis  *redis.Client
+	Logger *logger.Logger
+}
+
+// Bootstrap loads the configuration, initializes the keys, connects to the database
+// and registers the storage backend. Close releases what it opened.
+func Bootstrap(log *logger.Logger) (*App, error) {
+	// check if .env file exists
+	if _, err := os.Stat(".env"); os.IsNotExist(err) {
+		log.Info("No .env file found, skipping environment variable loading")
+	} else {
+		log.Info("Loading environment variables from .env file")
+		if err := config.LoadEnvFile(".env"); err != nil {
+			return nil, fmt.Errorf("failed to load environment variables: %w", err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := crypto.InitializeKeys(cfg.Crypto.PrivateKey, cfg.Crypto.PreviousKeys()...); err != nil {
+		return nil, fmt.Errorf("failed to initialize keys: %w", err)
+	}
+	if err := utils.ConfigureAccessTokens(utils.AccessTokenConfig{
+		Algorithm: cfg.JWT.Algorithm,
+		Issuer:    cfg.JWT.Issuer,
+		Audience:  cfg.JWT.Audience,
+		MaxBytes:  cfg.JWT.MaxTokenBytes,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to configure access tokens: %w", err)
+	}
+
+	if err := db.Connect(cfg); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	services.ConfigureSlowQueryExplain(
+		time.Duration(cfg.Database.SlowQueryThreshold)*time.Millisecond,
+		cfg.Database.ExplainSampleRate,
+	)
+
+	storage, err := newStorage(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Register the URL generator
+	models.RegisterFileURLGenerator(storage)
+	models.RegisterFileDeleter(storage)
+	models.RegisterFileDownloader(storage)
+	models.RegisterFileWriter(storage)
+	handlers.RegisterStorageHandler(storage)
+
+	// One Redis client for the process, injected into everything using Redis directly
+	redisClient := tasks.NewRedisClient(cfg.Redis)
+
+	// Wake clients long-polling a job, whichever replica finishes it
+	models.RegisterJobNotifier(jobs.NewNotifier(redisClient))
+
+	// Access tokens name the team's plan for services verifying them
+	utils.RegisterClaimEnricher(models.PlanClaims(db.GetDB()))
+
+	// Users carry a signed avatar URL that works without authentication
+	models.RegisterAvatarURLSigner(imaging.NewAvatarSigner(cfg.Server.PublicURL, cfg.Image.AvatarSigningKey))
+
+	// Emails go out over SMTP when a server is configured, see tasks.deliverEmail
+	if cfg.SMTP.Host != "" {
+		mailer.RegisterSender(mailer.NewSMTPSender(mailer.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		}))
+	}
+
+	return &App{
+		Config:  cfg,
+		DB:      db.GetDB(),
+		Storage: storage,
+		Redis:   redisClient,
+		Logger:  log,
+	}, nil
+}
+
+// Close closes the Redis client and the database connection
+func (a *App) Close() error {
+	if a.Redis != nil {
+		if err := a.Redis.Close(); err != nil {
+			a.Logger.Warn("Failed to close Redis client: %v", err)
+		}
+	}
+	return db.Close()
+}
+
+// newStorage creates the S3 service with its optional CDN and replica
+func newStorage(cfg *config.Config) (*services.S3Service, error) {
+	s3Service, err := services.NewS3Service(
+		cfg.Storage.S3.BucketName,
+		cfg.Storage.S3.Endpoint,
+		cfg.Storage.S3.Region,
+		cfg.Storage.S3.AccessKey,
+		cfg.Storage.S3.SecretKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize S3 service: %w", err)
+	}
+
+	// Serve file URLs from the CDN when one fronts the bucket
+	if cfg.Storage.CDN.BaseURL != "" {
+		cdn, err := services.NewCDN(cfg.Storage.CDN.BaseURL, cfg.Storage.CDN.Provider, cfg.Storage.CDN.KeyPairID, cfg.Storage.CDN.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CDN: %w", err)
+		}
+		s3Service.SetCDN(cdn)
+	}
+
+	// Serve signed reads from the secondary region replica when configured
+	if cfg.Storage.S3.ReplicaBucket != "" {
+		replica, err := services.NewReplica(
+			cfg.Storage.S3.ReplicaBucket,
+			cfg.Storage.S3.ReplicaEndpoint,
+			cfg.Storage.S3.ReplicaRegion,
+			cfg.Storage.S3.AccessKey,
+			cfg.Storage.S3.SecretKey,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 replica: %w", err)
+		}
+		s3Service.SetReplica(replica)
+	}
+
+	return s3Service, nil
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"be0/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthServer serves /health, /ready and /metrics for processes without the API,
+// so worker replicas can be probed and scraped like API ones
+type HealthServer struct {
+	echo  *echo.Echo
+	addr  string
+	ready atomic.Bool
+}
+
+// NewHealthServer creates a health server listening on host:port
+func NewHealthServer(host string, port int) *HealthServer {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	s := &HealthServer{echo: e, addr: fmt.Sprintf("%s:%d", host, port)}
+	e.GET("/health", s.healthCheck)
+	e.GET("/ready", s.readinessCheck)
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	return s
+}
+
+func (s *HealthServer) Start() error {
+	return s.echo.Start(s.addr)
+}
+
+func (s *HealthServer) Shutdown(ctx context.Context) error {
+	return s.echo.Shutdown(ctx)
+}
+
+// MarkReady flips the readiness check to ready once the worker is processing tasks
+func (s *HealthServer) MarkReady() {
+	s.ready.Store(true)
+}
+
+func (s *HealthServer) readinessCheck(c echo.Context) error {
+	if !s.ready.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "starting",
+			"time":   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "ready",
+		"time":   time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func (s *HealthServer) healthCheck(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "healthy",
+		"version": "1.0.0",
+		"time":    time.Now().UTC().Format(time.RFC3339),
+	})
+}
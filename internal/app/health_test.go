@@ -0,0 +1,55 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthServerServesProbesAndMetrics(t *testing.T) {
+	s := NewHealthServer("localhost", 0)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/health"); rec.Code != http.StatusOK {
+		t.Errorf("GET /health = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := get("/metrics"); rec.Code != http.StatusOK {
+		t.Errorf("GET /metrics = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Not ready until the worker is processing tasks
+	rec := get("/ready")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /ready before MarkReady = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	s.MarkReady()
+	rec = get("/ready")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /ready after MarkReady = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "ready" {
+		t.Errorf("status = %q, want ready", body["status"])
+	}
+}
+
+func TestHealthServerDoesNotServeTheAPI(t *testing.T) {
+	s := NewHealthServer("localhost", 0)
+	for _, path := range []string{"/api/v1/auth/login", "/api/v1/users"} {
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package captcha verifies challenge tokens from hCaptcha or Cloudflare Turnstile on public endpoints
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"be0/internal/utils/logger"
+)
+
+var log = logger.New("captcha")
+
+// Providers
+const (
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderTurnstile = "turnstile"
+)
+
+// Endpoints a captcha can be required on
+const (
+	EndpointRegister      = "register"
+	EndpointPasswordReset = "password-reset"
+)
+
+var (
+	// ErrMissing is returned when an endpoint requires a captcha and no token was sent
+	ErrMissing = errors.New("captcha_token is required")
+	// ErrRejected is returned when the provider did not accept the token
+	ErrRejected = errors.New("captcha verification failed")
+	// ErrUnavailable is returned when the provider could not be reached and the guard fails closed
+	ErrUnavailable = errors.New("captcha verification is unavailable, try again later")
+)
+
+// ChallengeVerifier checks a token solved by the client with the provider
+type ChallengeVerifier interface {
+	// Verify returns nil for accepted tokens, an error wrapping ErrRejected for rejected
+	// ones and any other error when the provider could not give an answer
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// siteVerifier speaks the siteverify protocol hCaptcha and Turnstile share
+type siteVerifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHCaptcha verifies tokens with hCaptcha, giving up after timeout
+func NewHCaptcha(secret string, timeout time.Duration) ChallengeVerifier {
+	return &siteVerifier{url: "https://api.hcaptcha.com/siteverify", secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+// NewTurnstile verifies tokens with Cloudflare Turnstile, giving up after timeout
+func NewTurnstile(secret string, timeout time.Duration) ChallengeVerifier {
+	return &siteVerifier{url: "https://challenges.cloudflare.com/turnstile/v0/siteverify", secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+// New returns the verifier of a provider
+func New(provider, secret string, timeout time.Duration) (ChallengeVerifier, error) {
+	switch provider {
+	case ProviderHCaptcha:
+		return NewHCaptcha(secret, timeout), nil
+	case ProviderTurnstile:
+		return NewTurnstile(secret, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", provider)
+	}
+}
+
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", v.url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", v.url, response.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed decoding %s response: %w", v.url, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrRejected, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// Guard requires a solved captcha on some endpoints. A nil Guard requires none.
+type Guard struct {
+	verifier  ChallengeVerifier
+	endpoints map[string]bool
+	failOpen  bool
+}
+
+// NewGuard requires captchas on the given endpoints. With failOpen, requests are let
+// through when the provider cannot be reached instead of being rejected.
+func NewGuard(verifier ChallengeVerifier, endpoints []string, failOpen bool) *Guard {
+	guard := &Guard{verifier: verifier, endpoints: make(map[string]bool, len(endpoints)), failOpen: failOpen}
+	for _, endpoint := range endpoints {
+		guard.endpoints[endpoint] = true
+	}
+	return guard
+}
+
+// Check verifies the token sent to an endpoint. It returns ErrMissing, ErrRejected or
+// ErrUnavailable when the request must be refused.
+func (g *Guard) Check(ctx context.Context, endpoint, token, remoteIP string) error {
+	if g == nil || !g.endpoints[endpoint] {
+		return nil
+	}
+	if token == "" {
+		return ErrMissing
+	}
+
+	err := g.verifier.Verify(ctx, token, remoteIP)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrRejected):
+		log.Info("Captcha rejected on %s: %v", endpoint, err)
+		return ErrRejected
+	case g.failOpen:
+		log.Warn("Captcha unavailable on %s, letting the request through: %v", endpoint, err)
+		return nil
+	default:
+		log.Error("Captcha unavailable on %s: %v", err, endpoint)
+		return ErrUnavailable
+	}
+}
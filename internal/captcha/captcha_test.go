@@ -0,0 +1,88 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeVerifier accepts one token, rejects the others and fails with down
+type fakeVerifier struct {
+	token string
+	down  bool
+}
+
+func (v *fakeVerifier) Verify(_ context.Context, token, _ string) error {
+	switch {
+	case v.down:
+		return errors.New("connection refused")
+	case token != v.token:
+		return fmt.Errorf("%w: invalid-input-response", ErrRejected)
+	}
+	return nil
+}
+
+func TestGuardCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		token    string
+		down     bool
+		failOpen bool
+		want     error
+	}{
+		{"solved", EndpointRegister, "solved", false, false, nil},
+		{"missing token", EndpointRegister, "", false, false, ErrMissing},
+		{"rejected token", EndpointRegister, "forged", false, false, ErrRejected},
+		{"unprotected endpoint", "login", "", false, false, nil},
+		{"provider down, failing closed", EndpointRegister, "solved", true, false, ErrUnavailable},
+		{"provider down, failing open", EndpointRegister, "solved", true, true, nil},
+		{"rejected token, failing open", EndpointRegister, "forged", false, true, ErrRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewGuard(&fakeVerifier{token: "solved", down: tt.down}, []string{EndpointRegister}, tt.failOpen)
+			if err := guard.Check(context.Background(), tt.endpoint, tt.token, "203.0.113.7"); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	var none *Guard
+	if err := none.Check(context.Background(), EndpointRegister, "", ""); err != nil {
+		t.Errorf("a nil guard got %v, want no captcha required", err)
+	}
+}
+
+func TestSiteVerifierSpeaksSiteverify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") != "s3cret" || r.FormValue("remoteip") != "203.0.113.7" {
+			t.Errorf("got form %v", r.Form)
+		}
+		switch r.FormValue("response") {
+		case "solved":
+			fmt.Fprint(w, `{"success": true}`)
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, `{"success": false, "error-codes": ["invalid-input-response"]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	verifier := &siteVerifier{url: server.URL, secret: "s3cret", client: &http.Client{Timeout: time.Second}}
+
+	if err := verifier.Verify(context.Background(), "solved", "203.0.113.7"); err != nil {
+		t.Errorf("solved token: %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "forged", "203.0.113.7"); !errors.Is(err, ErrRejected) {
+		t.Errorf("forged token: got %v, want ErrRejected", err)
+	}
+	// A provider error is no answer, the guard decides whether to fail open
+	if err := verifier.Verify(context.Background(), "broken", "203.0.113.7"); err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("provider error: got %v, want an error other than ErrRejected", err)
+	}
+}
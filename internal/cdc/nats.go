@@ -0,0 +1,53 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSPublisher publishes events to NATS JetStream
+type NATSPublisher struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// NewNATSPublisher connects to NATS and opens a JetStream context
+func NewNATSPublisher(url string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	return &NATSPublisher{conn: conn, js: js}, nil
+}
+
+// Publish sends a message and waits for the JetStream ack.
+// The event ID is used as Nats-Msg-Id so that redeliveries are de-duplicated by the stream.
+func (p *NATSPublisher) Publish(ctx context.Context, msg Message) error {
+	m := nats.NewMsg(msg.Subject)
+	m.Data = msg.Payload
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+
+	var opts []jetstream.PublishOpt
+	if id := msg.Headers[HeaderEventID]; id != "" {
+		opts = append(opts, jetstream.WithMsgID(id))
+	}
+
+	_, err := p.js.PublishMsg(ctx, m, opts...)
+	return err
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,46 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+
+	"be0/internal/config"
+)
+
+// Message is a single change event forwarded to the broker
+type Message struct {
+	Subject string
+	Payload []byte
+	Headers map[string]string
+}
+
+// Publisher forwards change events to an external broker
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// NewPublisher creates the publisher selected by CDC_BROKER: nats, kafka or none
+func NewPublisher(cfg config.CDCConfig) (Publisher, error) {
+	switch cfg.Broker {
+	case "", "none":
+		return NoopPublisher{}, nil
+	case "nats":
+		return NewNATSPublisher(cfg.URL)
+	case "kafka":
+		return NewKafkaPublisher(cfg.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown CDC broker: %s", cfg.Broker)
+	}
+}
+
+// NoopPublisher drops every event, for installs without a broker
+type NoopPublisher struct{}
+
+func (NoopPublisher) Publish(ctx context.Context, msg Message) error {
+	return nil
+}
+
+func (NoopPublisher) Close() error {
+	return nil
+}
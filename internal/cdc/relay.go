@@ -0,0 +1,130 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Message headers attached to every published event
+const (
+	HeaderEventID   = "Event-Id"
+	HeaderEvent     = "Event"
+	HeaderTeamID    = "Team-Id"
+	HeaderActorID   = "Actor-Id"
+	HeaderRequestID = "Request-Id"
+)
+
+// Relay forwards committed outbox events to a publisher.
+// Events are only marked published after the broker accepts them, so delivery is at-least-once.
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	prefix    string
+	interval  time.Duration
+	batchSize int
+	logger    *logger.Logger
+}
+
+// NewRelay creates a relay publishing to "<prefix><table>" subjects
+func NewRelay(db *gorm.DB, publisher Publisher, prefix string, interval time.Duration, batchSize int) *Relay {
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		prefix:    prefix,
+		interval:  interval,
+		batchSize: batchSize,
+		logger:    logger.New("cdc_relay"),
+	}
+}
+
+// Start polls the outbox until the context is cancelled
+func (r *Relay) Start(ctx context.Context) {
+	r.logger.Info("starting CDC relay every %s", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("CDC relay stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.PublishPending(ctx); err != nil {
+				r.logger.Error("Failed to publish outbox events", err)
+			}
+		}
+	}
+}
+
+// PublishPending publishes one batch of unpublished events and returns how many were sent.
+// Rows are locked with SKIP LOCKED so several replicas can run the relay concurrently.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	published := 0
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("created_at ASC").
+			Limit(r.batchSize).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+
+		for _, event := range pending {
+			if err := r.publisher.Publish(ctx, r.message(event)); err != nil {
+				return fmt.Errorf("failed to publish event %s: %w", event.ID, err)
+			}
+
+			now := time.Now()
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Update("published_at", &now).Error; err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+
+	return published, err
+}
+
+// Replay republishes every event created at or after from, whether or not it was published before
+func (r *Relay) Replay(ctx context.Context, from time.Time) (int, error) {
+	var events []models.OutboxEvent
+	if err := r.db.WithContext(ctx).
+		Where("created_at >= ?", from).
+		Order("created_at ASC").
+		Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		if err := r.publisher.Publish(ctx, r.message(event)); err != nil {
+			return i, fmt.Errorf("failed to replay event %s: %w", event.ID, err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *Relay) message(event models.OutboxEvent) Message {
+	return Message{
+		Subject: r.prefix + event.Table,
+		Payload: event.Payload,
+		Headers: map[string]string{
+			HeaderEventID:   event.ID,
+			HeaderEvent:     event.Event,
+			HeaderTeamID:    event.TeamID,
+			HeaderActorID:   event.ActorID,
+			HeaderRequestID: event.RequestID,
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/testutil"
+)
+
+// recordingPublisher keeps published messages, failing every publish while err is set
+type recordingPublisher struct {
+	messages []Message
+	err      error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, msg Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+func TestRelayPublishesCommittedChangesAtLeastOnce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	actor := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	ctx := services.WithActor(context.Background(), actor.ID, team.ID, "req-1")
+
+	users := services.NewBaseService(gdb, models.User{})
+	user := &models.User{Email: "grace@example.com", Password: "bcrypt-hash", FirstName: "Grace", TeamID: team.ID, Role: models.UserRoleMember}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &recordingPublisher{err: errors.New("broker down")}
+	relay := NewRelay(gdb, publisher, "be0.", time.Minute, 10)
+
+	// 📮 A refused publish leaves the events in the outbox for the next poll
+	if published, err := relay.PublishPending(ctx); err == nil || published != 0 {
+		t.Fatalf("published %d with the broker down, err %v", published, err)
+	}
+	var pending int64
+	gdb.Model(&models.OutboxEvent{}).Where("published_at IS NULL").Count(&pending)
+	if pending != 2 {
+		t.Fatalf("%d events pending after the failed publish, want 2", pending)
+	}
+
+	publisher.err = nil
+	if published, err := relay.PublishPending(ctx); err != nil || published != 2 {
+		t.Fatalf("published %d, err %v, want 2", published, err)
+	}
+	if published, err := relay.PublishPending(ctx); err != nil || published != 0 {
+		t.Errorf("second poll published %d, err %v, want nothing", published, err)
+	}
+
+	if len(publisher.messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(publisher.messages))
+	}
+	created, deleted := publisher.messages[0], publisher.messages[1]
+	for _, msg := range publisher.messages {
+		if msg.Subject != "be0.users" || msg.Headers[HeaderTeamID] != team.ID || msg.Headers[HeaderActorID] != actor.ID || msg.Headers[HeaderRequestID] != "req-1" || msg.Headers[HeaderEventID] == "" {
+			t.Errorf("message %s with headers %v", msg.Subject, msg.Headers)
+		}
+	}
+	if created.Headers[HeaderEvent] != "users.created" || deleted.Headers[HeaderEvent] != "users.deleted" {
+		t.Errorf("events %q and %q, want users.created then users.deleted", created.Headers[HeaderEvent], deleted.Headers[HeaderEvent])
+	}
+
+	// 🙈 The payload is the API shape, never the raw columns
+	var payload map[string]interface{}
+	if err := json.Unmarshal(created.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["id"] != user.ID || payload["email"] != "grace@example.com" {
+		t.Errorf("payload %v, want the created user", payload)
+	}
+	for _, column := range []string{"password", "Password", "providerData", "twoFactorSecret"} {
+		if _, ok := payload[column]; ok {
+			t.Errorf("payload carries %s", column)
+		}
+	}
+}
+
+func TestReplayRepublishesFromATime(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	old := models.OutboxEvent{Event: "teams.created", Table: "teams", Payload: []byte(`{}`)}
+	old.CreatedAt = models.NewTimestamp(time.Now().Add(-2 * time.Hour))
+	if err := gdb.Create(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	if err := services.NewBaseService(gdb, models.Team{}).Update(context.Background(), team.ID, &models.Team{Name: "Acme Corp"}); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &recordingPublisher{}
+	relay := NewRelay(gdb, publisher, "", time.Minute, 10)
+	if _, err := relay.PublishPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	publisher.messages = nil
+
+	// 🔁 Published events go out again, those before the start are left alone
+	replayed, err := relay.Replay(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil || replayed != 1 {
+		t.Fatalf("replayed %d, err %v, want 1", replayed, err)
+	}
+	if len(publisher.messages) != 1 || publisher.messages[0].Headers[HeaderEvent] != "teams.updated" {
+		t.Errorf("replayed %+v, want the team update", publisher.messages)
+	}
+}
@@ -0,0 +1,104 @@
+package clientgen
+
+// runtimeTS is the hand-written part of the client: fetch, auth and pagination
+const runtimeTS = `export interface ClientOptions {
+  /** API origin, e.g. https://api.example.com */
+  baseUrl: string;
+  /** Bearer token, or a function returning the current one */
+  token?: string | (() => string | undefined | Promise<string | undefined>);
+  fetch?: typeof fetch;
+  headers?: Record<string, string>;
+}
+
+/** The envelope of list endpoints */
+export interface Paginated<T> {
+  data: T[];
+  total: number;
+  page: number;
+  limit: number;
+  /** Pages of limit items holding total, returned by the CRUD lists */
+  totalPages?: number;
+}
+
+export class ApiError extends Error {
+  constructor(
+    public readonly status: number,
+    public readonly body: unknown,
+  ) {
+    super(typeof body === 'object' && body !== null && 'error' in body ? String((body as { error: unknown }).error) : ` + "`HTTP ${status}`" + `);
+  }
+}
+
+type QueryValue = string | number | boolean | undefined | null | Array<string | number | boolean>;
+
+export class BaseClient {
+  constructor(protected readonly options: ClientOptions) {}
+
+  protected async request<T>(
+    method: string,
+    path: string,
+    init: { query?: Record<string, QueryValue>; body?: unknown } = {},
+  ): Promise<T> {
+    const url = new URL(path, this.options.baseUrl);
+    for (const [key, value] of Object.entries(init.query ?? {})) {
+      if (value === undefined || value === null) continue;
+      url.searchParams.set(key, Array.isArray(value) ? value.join(',') : String(value));
+    }
+
+    const headers: Record<string, string> = { Accept: 'application/json', ...this.options.headers };
+    const token = typeof this.options.token === 'function' ? await this.options.token() : this.options.token;
+    if (token) headers.Authorization = ` + "`Bearer ${token}`" + `;
+    if (init.body !== undefined) headers['Content-Type'] = 'application/json';
+
+    const res = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: init.body === undefined ? undefined : JSON.stringify(init.body),
+    });
+
+    const text = await res.text();
+    const data = text ? JSON.parse(text) : undefined;
+    if (!res.ok) throw new ApiError(res.status, data);
+    return data as T;
+  }
+}
+
+/** Iterates every item of a list endpoint, e.g. paginate((page) => client.listTeams({ page })) */
+export async function* paginate<T>(fetchPage: (page: number) => Promise<Paginated<T>>): AsyncGenerator<T> {
+  for (let page = 1; ; page++) {
+    const res = await fetchPage(page);
+    yield* res.data;
+    if (res.data.length === 0 || page * res.limit >= res.total) return;
+  }
+}
+`
+
+const packageJSON = `{
+  "name": "@be0/client",
+  "version": "0.0.0",
+  "private": true,
+  "description": "Generated by go run ./cmd/genclient, do not edit",
+  "main": "dist/index.js",
+  "types": "dist/index.d.ts",
+  "scripts": {
+    "build": "tsc -p ."
+  },
+  "devDependencies": {
+    "typescript": "^5.4.0"
+  }
+}
+`
+
+const tsconfigJSON = `{
+  "compilerOptions": {
+    "target": "ES2020",
+    "module": "ES2020",
+    "moduleResolution": "node",
+    "lib": ["ES2020", "DOM"],
+    "declaration": true,
+    "strict": true,
+    "outDir": "dist"
+  },
+  "include": ["src"]
+}
+`
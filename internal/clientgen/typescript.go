@@ -0,0 +1,408 @@
+// Package clientgen generates API clients from the assembled swagger document
+package clientgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// header starts every generated file
+const header = "// Code generated by go run ./cmd/genclient. DO NOT EDIT.\n\n"
+
+// TypeScript generates a TypeScript client package from a swagger 2.0 document.
+// It returns file contents keyed by path relative to the package root.
+func TypeScript(doc []byte) (map[string][]byte, error) {
+	var spec swaggerSpec
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("parse swagger document: %w", err)
+	}
+
+	g := &generator{spec: spec, names: typeNames(spec.Definitions)}
+
+	files := map[string][]byte{
+		"package.json":   []byte(packageJSON),
+		"tsconfig.json":  []byte(tsconfigJSON),
+		"src/runtime.ts": []byte(header + runtimeTS),
+		"src/types.ts":   g.types(),
+		"src/client.ts":  g.client(),
+		"src/index.ts":   []byte(header + "export * from './runtime';\nexport * from './types';\nexport * from './client';\n"),
+	}
+	return files, nil
+}
+
+type swaggerSpec struct {
+	BasePath    string                                `json:"basePath"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*schema                    `json:"definitions"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []interface{}      `json:"enum"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Description          string             `json:"description"`
+	Nullable             bool               `json:"x-nullable"`
+	// Partial marks a body of which any subset of the properties is sent, e.g. a PATCH
+	Partial bool `json:"x-partial"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Type     string  `json:"type"`
+	Items    *schema `json:"items"`
+	Schema   *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []parameter          `json:"parameters"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type response struct {
+	Schema *schema `json:"schema"`
+}
+
+type generator struct {
+	spec  swaggerSpec
+	names map[string]string
+	// used collects the types referenced while rendering, for the client's imports
+	used map[string]bool
+}
+
+// typeNames maps definition names to TypeScript names, models.Team becomes Team
+// unless another package defines a Team too
+func typeNames(definitions map[string]*schema) map[string]string {
+	short := map[string][]string{}
+	for name := range definitions {
+		s := pascal(name[strings.LastIndex(name, ".")+1:])
+		short[s] = append(short[s], name)
+	}
+
+	names := map[string]string{}
+	for s, defs := range short {
+		for _, name := range defs {
+			if len(defs) == 1 {
+				names[name] = s
+			} else {
+				names[name] = pascal(name)
+			}
+		}
+	}
+	return names
+}
+
+// types renders an interface per definition
+func (g *generator) types() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+
+	for _, name := range sortedKeys(g.spec.Definitions) {
+		def := g.spec.Definitions[name]
+		if def.Description != "" {
+			fmt.Fprintf(&buf, "/** %s */\n", def.Description)
+		}
+		if len(def.Properties) == 0 || def.Type != "object" && def.Type != "" {
+			fmt.Fprintf(&buf, "export type %s = %s;\n\n", g.names[name], g.tsType(def))
+			continue
+		}
+		fmt.Fprintf(&buf, "export interface %s %s\n\n", g.names[name], g.objectType(def, ""))
+	}
+	return buf.Bytes()
+}
+
+// objectType renders the properties of an object schema
+func (g *generator) objectType(s *schema, indent string) string {
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	var buf strings.Builder
+	buf.WriteString("{\n")
+	for _, name := range sortedKeys(s.Properties) {
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		fmt.Fprintf(&buf, "%s  %s%s: %s;\n", indent, propertyName(name), optional, g.typeAt(s.Properties[name], indent+"  "))
+	}
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+func (g *generator) tsType(s *schema) string {
+	return g.typeAt(s, "")
+}
+
+func (g *generator) typeAt(s *schema, indent string) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Nullable {
+		nullable := *s
+		nullable.Nullable = false
+		return g.typeAt(&nullable, indent) + " | null"
+	}
+	if s.Partial {
+		partial := *s
+		partial.Partial = false
+		return "Partial<" + g.typeAt(&partial, indent) + ">"
+	}
+	if s.Ref != "" {
+		if name, ok := g.names[strings.TrimPrefix(s.Ref, "#/definitions/")]; ok {
+			if g.used != nil {
+				g.used[name] = true
+			}
+			return name
+		}
+		return "unknown"
+	}
+	if len(s.Enum) > 0 {
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			encoded, _ := json.Marshal(v)
+			values[i] = string(encoded)
+		}
+		return strings.Join(values, " | ")
+	}
+
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := g.typeAt(s.Items, indent)
+		if strings.ContainsAny(item, " |") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if len(s.Properties) > 0 {
+			return g.objectType(s, indent)
+		}
+		var additional schema
+		if len(s.AdditionalProperties) > 0 && json.Unmarshal(s.AdditionalProperties, &additional) == nil {
+			return "Record<string, " + g.typeAt(&additional, indent) + ">"
+		}
+		return "Record<string, unknown>"
+	default:
+		return "unknown"
+	}
+}
+
+// client renders a method per operation on the Be0Client class
+func (g *generator) client() []byte {
+	g.used = map[string]bool{}
+	defer func() { g.used = nil }()
+
+	var methods bytes.Buffer
+	seen := map[string]int{}
+	for _, path := range sortedKeys(g.spec.Paths) {
+		for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+			raw, ok := g.spec.Paths[path][method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				continue
+			}
+			g.method(&methods, seen, g.fullPath(path), method, &op)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("import { BaseClient } from './runtime';\n")
+	if bytes.Contains(methods.Bytes(), []byte("Paginated<")) {
+		buf.WriteString("import type { Paginated } from './runtime';\n")
+	}
+	if len(g.used) > 0 {
+		buf.WriteString("import type {\n")
+		for _, name := range sortedKeys(g.used) {
+			fmt.Fprintf(&buf, "  %s,\n", name)
+		}
+		buf.WriteString("} from './types';\n")
+	}
+
+	buf.WriteString("\nexport class Be0Client extends BaseClient {")
+	buf.Write(methods.Bytes())
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// fullPath prefixes the base path unless the route was documented with it
+func (g *generator) fullPath(path string) string {
+	base := strings.TrimSuffix(g.spec.BasePath, "/")
+	if base == "" || strings.HasPrefix(path, base+"/") || path == base {
+		return path
+	}
+	return base + path
+}
+
+func (g *generator) method(buf *bytes.Buffer, seen map[string]int, path, method string, op *operation) {
+	name := camel(op.OperationID)
+	if name == "" {
+		name = operationName(method, path)
+	}
+	if seen[name]++; seen[name] > 1 {
+		name = fmt.Sprintf("%s%d", name, seen[name])
+	}
+
+	var args, query []string
+	var body string
+	urlPath := path
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			args = append(args, fmt.Sprintf("%s: string", identifier(p.Name)))
+			urlPath = strings.ReplaceAll(urlPath, "{"+p.Name+"}", "${encodeURIComponent("+identifier(p.Name)+")}")
+		case "query":
+			optional := "?"
+			if p.Required {
+				optional = ""
+			}
+			typ := g.tsType(&schema{Type: p.Type, Items: p.Items})
+			query = append(query, fmt.Sprintf("%s%s: %s", propertyName(p.Name), optional, typ))
+		case "body":
+			body = g.tsType(p.Schema)
+			args = append(args, "body: "+body)
+		}
+	}
+	if len(query) > 0 {
+		args = append(args, "query: { "+strings.Join(query, "; ")+" } = {}")
+	}
+
+	result := g.resultType(op)
+
+	if op.Summary != "" {
+		fmt.Fprintf(buf, "\n  /** %s */\n", op.Summary)
+	} else {
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(buf, "  %s(%s): Promise<%s> {\n", name, strings.Join(args, ", "), result)
+
+	options := []string{}
+	if len(query) > 0 {
+		options = append(options, "query")
+	}
+	if body != "" {
+		options = append(options, "body")
+	}
+	optionArg := ""
+	if len(options) > 0 {
+		optionArg = ", { " + strings.Join(options, ", ") + " }"
+	}
+	fmt.Fprintf(buf, "    return this.request<%s>('%s', `%s`%s);\n  }\n", result, strings.ToUpper(method), urlPath, optionArg)
+}
+
+// resultType is the type of the first 2xx response, with list envelopes typed as Paginated
+func (g *generator) resultType(op *operation) string {
+	for _, status := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		res := op.Responses[status]
+		if res == nil || res.Schema == nil {
+			return "void"
+		}
+		if item, ok := paginatedItem(res.Schema); ok {
+			return "Paginated<" + g.tsType(item) + ">"
+		}
+		return g.tsType(res.Schema)
+	}
+	return "void"
+}
+
+// paginatedItem recognises the BaseController list envelope {data, total, page, limit}
+func paginatedItem(s *schema) (*schema, bool) {
+	if s.Type != "object" || len(s.Properties) != 4 {
+		return nil, false
+	}
+	data := s.Properties["data"]
+	if data == nil || data.Type != "array" || s.Properties["total"] == nil || s.Properties["page"] == nil || s.Properties["limit"] == nil {
+		return nil, false
+	}
+	return data.Items, true
+}
+
+// operationName derives a method name from an undocumented operation, e.g. POST /auth/login becomes postAuthLogin
+func operationName(method, path string) string {
+	name := method
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case segment == "" || segment == "api" || segment == "v1":
+		case strings.HasPrefix(segment, "{"):
+			name += "By" + pascal(strings.Trim(segment, "{}"))
+		default:
+			name += pascal(segment)
+		}
+	}
+	return name
+}
+
+// pascal turns team-invites, team_invites or models.Team into TeamInvites or ModelsTeam
+func pascal(s string) string {
+	var buf strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func camel(s string) string {
+	p := pascal(s)
+	if p == "" {
+		return ""
+	}
+	return strings.ToLower(p[:1]) + p[1:]
+}
+
+// identifier makes a parameter name usable as a TypeScript variable
+func identifier(s string) string {
+	return camel(s)
+}
+
+// propertyName quotes property names that are not valid identifiers
+func propertyName(s string) string {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || i > 0 && unicode.IsDigit(r)) {
+			return fmt.Sprintf("%q", s)
+		}
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
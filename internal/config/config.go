@@ -4,30 +4,188 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
 )
 
 // Config holds all configuration for the application
 type Config struct {
+	Mode     RunMode
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Auth     AuthConfig
 	Storage  StorageConfig
 	Worker   WorkerConfig
 	Redis    RedisConfig
 	S3       S3Config
 	Crypto   CryptoConfig
+	GRPC     GRPCConfig
+	CDC      CDCConfig
+	Debug    DebugConfig
+	Image    ImageConfig
+	// RateLimit limits API requests per caller, see RateLimitConfig
+	RateLimit RateLimitConfig
+	Metrics   MetricsConfig
+	// APIAnalytics records requests made with API keys, see APIAnalyticsConfig
+	APIAnalytics APIAnalyticsConfig
+	SMTP         SMTPConfig
+	// Hooks verifies the callbacks of integrations under /api/v1/hooks, see HooksConfig
+	Hooks HooksConfig
+}
+
+// HooksConfig holds the secrets inbound callbacks are signed with, by the source in their
+// path, e.g. /api/v1/hooks/postmark/... for HOOK_SECRETS=postmark:s3cret. Callbacks of
+// sources without a secret are rejected.
+type HooksConfig struct {
+	Secrets map[string]string
+}
+
+// SMTPConfig is the SMTP server emails are sent through, emails are dropped without a Host.
+// The email tasks send at most RateLimit emails per RateWindow through one server and
+// account, shared by every worker.
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	From       string
+	RateLimit  int // emails per window, 0 for no limit
+	RateWindow int // seconds
+}
+
+// APIAnalyticsConfig bounds the request logs kept for API key analytics. Failed requests are
+// always recorded, successful ones at SampleRate.
+type APIAnalyticsConfig struct {
+	Enabled       bool
+	SampleRate    float64 // fraction of successful requests recorded, 0 to 1
+	RetentionDays int
+	BufferSize    int // logs waiting for the database before new ones are dropped
+}
+
+// MetricsConfig guards the Prometheus endpoint. With Port set, /metrics is only served on
+// that internal port; otherwise the API serves it to scrapers presenting Token as a bearer
+// token, and not at all without one.
+type MetricsConfig struct {
+	Port              int
+	Token             string
+	QueuePollInterval int // seconds
+}
+
+// RateLimitConfig limits requests per caller within a sliding window shared through Redis.
+// Signed-in users are limited by user ID, API keys by key and anonymous callers by IP.
+type RateLimitConfig struct {
+	Enabled bool
+	Window  int // seconds
+	User    int // requests per window
+	APIKey  int // requests per window
+	IP      int // requests per window
+}
+
+// ImageConfig limits the image transform endpoint
+type ImageConfig struct {
+	MaxDimension  int // pixels, applies to width and height
+	MaxConcurrent int // transforms rendered at once
+	MaxSourceSize int // bytes
+	CacheTTL      int // hours
+
+	// Public avatars are served without authentication, so URLs are signed and requests rate limited
+	AvatarSigningKey string  // defaults to the JWT secret
+	AvatarRateLimit  float64 // requests per second per IP
+	AvatarBurst      int
+	AvatarCacheTTL   int // minutes
+}
+
+// DebugConfig configures request/response capture for debugging.
+// Capture is off unless Enabled is set, and then only applies to the listed teams
+// or to requests from super admins carrying the X-Debug-Capture header.
+type DebugConfig struct {
+	CaptureEnabled bool
+	CaptureTeams   []string
+	RedactPaths    []string
+	MaxBodySize    int // bytes
+	TTL            int // minutes
+	BufferSize     int
+}
+
+// CDCConfig configures forwarding of outbox events to an external broker
+type CDCConfig struct {
+	Broker        string // none, nats or kafka
+	URL           string // NATS URL or comma separated Kafka brokers
+	SubjectPrefix string
+	PollInterval  int // seconds
+	BatchSize     int
+}
+
+// GRPCConfig configures the optional internal gRPC server.
+// Callers authenticate with ServiceToken, mTLS (ClientCAFile), or both.
+type GRPCConfig struct {
+	Enabled      bool
+	Port         int
+	ServiceToken string
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
 }
 
 type CryptoConfig struct {
 	PrivateKey string
+	// PreviousPrivateKeys are the comma separated keys PRIVATE_KEY replaced, they still open
+	// sealed tokens and decrypt two-factor secrets from before the rotation
+	PreviousPrivateKeys string
+}
+
+// PreviousKeys returns PreviousPrivateKeys as a list
+func (c CryptoConfig) PreviousKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(c.PreviousPrivateKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
 }
 
 type ServerConfig struct {
 	Host      string
 	Port      int
 	PublicURL string
+	// Environment is e.g. development or production, development tooling refuses to touch production
+	Environment string
+	// WaitForWarmup delays accepting traffic until the warm-up has finished
+	WaitForWarmup bool
+	// BodyLimit is the largest request body accepted, e.g. 10M
+	BodyLimit string
+	// GzipLevel is the response compression level, 0 disables compression
+	GzipLevel int
+	// GzipMinLength is the smallest response, in bytes, that is compressed
+	GzipMinLength int
+	// GzipSkipAccept lists Accept prefixes whose responses are never compressed
+	GzipSkipAccept []string
+	Admission      AdmissionConfig
+	Query          QueryConfig
+}
+
+// QueryConfig caps CRUD list and get query strings, larger requests get 400
+type QueryConfig struct {
+	MaxLength     int // bytes
+	MaxFilters    int
+	MaxIncludes   int
+	MaxSortFields int
+	MaxExcludes   int
+	// StrictParams rejects unknown query parameters of requests without X-Strict-Params
+	StrictParams bool
+}
+
+// AdmissionConfig configures queueing of API requests under overload.
+// Disabled unless Enabled is set.
+type AdmissionConfig struct {
+	Enabled     bool
+	MaxInFlight int
+	QueueSize   int // waiting requests per team
+	ReadWait    int // milliseconds
+	WriteWait   int // milliseconds
 }
 
 type DatabaseConfig struct {
@@ -37,16 +195,78 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// MinIdleConns connections are opened eagerly during warm-up
+	MinIdleConns  int
+	WarmupTimeout int // seconds
+	// Reads slower than SlowQueryThreshold get their EXPLAIN plan logged for an ExplainSampleRate fraction of calls
+	SlowQueryThreshold int // milliseconds, 0 disables
+	ExplainSampleRate  float64
+	// MigrateOnStart runs migrations in Connect, replicas take turns through an advisory lock.
+	// Turn it off when migrations are run with `be0ctl db migrate` before deploying.
+	MigrateOnStart       bool
+	MigrationLockTimeout int // seconds to wait for another instance's migrations
+	// ProductionHosts are database hosts holding production data, be0ctl anonymize refuses to rewrite them
+	ProductionHosts []string
 }
 
 type JWTConfig struct {
 	Secret string
+	// Algorithm signs access tokens, HS256 with Secret or RS256 with the PRIVATE_KEY pair whose
+	// public key is served at /.well-known/jwks.json
+	Algorithm string
+	// Issuer and Audience are the iss and aud of access tokens, tokens with others are rejected
+	Issuer   string
+	Audience string
+	// MaxTokenBytes rejects access tokens that enriched claims grow beyond it, 0 = unlimited
+	MaxTokenBytes int
+	// MaxSessions is how many sessions a user may hold at once, the oldest is revoked beyond it (0 = unlimited)
+	MaxSessions int
+	// RequireEmailVerification rejects password sign-ins until the user has verified their email
+	RequireEmailVerification bool
 }
 
+// AuthConfig limits password sign-in attempts and reset requests. Login failures are
+// counted per email and IP, once MaxLoginAttempts is reached logins are rejected until
+// the window ends.
+type AuthConfig struct {
+	MaxLoginAttempts  int // 0 disables the lockout
+	LockoutWindow     int // minutes
+	MaxPasswordResets int // reset requests per email per hour, 0 = unlimited
+	// MicrosoftTenants are the Azure AD tenant IDs allowed to sign in with Microsoft, empty allows all
+	MicrosoftTenants []string
+	// MaxTeamMembers caps a team's members plus pending invites, 0 = unlimited
+	MaxTeamMembers int
+
+	// Captchas protect public endpoints from bots, they are off unless CaptchaProvider is set
+	CaptchaProvider  string   // hcaptcha or turnstile
+	CaptchaSecretKey string   // provider secret used for server-side verification
+	CaptchaEndpoints []string // register, password-reset
+	CaptchaTimeout   int      // milliseconds to wait for the provider
+	CaptchaFailOpen  bool     // let requests through when the provider cannot be reached
+}
+
+// StorageACLs are the canned ACLs files may be stored with, public-read-write is left out
+// as anyone could overwrite the file
+var StorageACLs = []string{"private", "public-read", "authenticated-read"}
+
 type StorageConfig struct {
-	Provider string // local, s3, etc.
-	BasePath string
-	S3       S3Config
+	Provider   string // local, s3, etc.
+	BasePath   string
+	DefaultACL string // canned ACL applied to uploads, e.g. private, authenticated-read, public-read
+	Warmup     bool   // pre-sign a URL and open a connection to the bucket at startup
+	// ExtractMaxSize is the largest document, in bytes, whose text is extracted for search
+	ExtractMaxSize int
+	S3             S3Config
+	CDN            CDNConfig
+}
+
+// CDNConfig configures a CDN fronting the bucket. File URLs use BaseURL, and signed URLs
+// stay presigned on the origin unless SigningKey is set.
+type CDNConfig struct {
+	BaseURL    string
+	Provider   string // cloudfront or cloudflare
+	KeyPairID  string // CloudFront public key ID
+	SigningKey string // CloudFront PEM private key or Cloudflare token secret
 }
 
 type S3Config struct {
@@ -55,11 +275,78 @@ type S3Config struct {
 	Region     string `env:"S3_REGION" required:"true"`
 	AccessKey  string `env:"S3_ACCESS_KEY" required:"true"`
 	SecretKey  string `env:"S3_SECRET_KEY" required:"true"`
+	// Optional read replica in a secondary region, used for signed URLs only
+	ReplicaBucket    string   `env:"S3_REPLICA_BUCKET"`
+	ReplicaEndpoint  string   `env:"S3_REPLICA_ENDPOINT"`
+	ReplicaRegion    string   `env:"S3_REPLICA_REGION"`
+	ReplicaCountries []string `env:"S3_REPLICA_COUNTRIES"` // ISO country codes served from the replica
 }
 
 type WorkerConfig struct {
 	Concurrency int
 	QueueSize   int
+	// QueueWeights are the asynq queue priorities, set with QUEUE_WEIGHTS=critical:6,default:3,low:1
+	QueueWeights map[string]int
+	// StrictPriority always drains higher weighted queues first instead of picking queues in proportion to their weight
+	StrictPriority  bool
+	ShutdownTimeout int // seconds in-flight tasks get to finish on shutdown
+	HealthPort      int // port of the health and metrics endpoints in worker mode
+}
+
+// defaultQueueWeights are used when QUEUE_WEIGHTS is not set
+const defaultQueueWeights = "critical:6,default:3,low:1"
+
+// parseHookSecrets reads a comma separated list of source:secret pairs
+func parseHookSecrets(value string) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		source, secret, found := strings.Cut(pair, ":")
+		source, secret = strings.TrimSpace(source), strings.TrimSpace(secret)
+		if !found || source == "" || secret == "" {
+			// The pair is not quoted, it holds a secret
+			return nil, fmt.Errorf("entry of source %q is not of the form source:secret", source)
+		}
+		if _, duplicate := secrets[source]; duplicate {
+			return nil, fmt.Errorf("source %s is listed twice", source)
+		}
+		secrets[source] = secret
+	}
+	return secrets, nil
+}
+
+// parseQueueWeights reads a comma separated list of queue:weight pairs
+func parseQueueWeights(value string) (map[string]int, error) {
+	weights := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, weight, found := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("%q is not of the form queue:weight", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("weight of queue %s must be a positive integer, got %q", name, weight)
+		}
+		if _, duplicate := weights[name]; duplicate {
+			return nil, fmt.Errorf("queue %s is listed twice", name)
+		}
+		weights[name] = n
+	}
+
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("at least one queue is required")
+	}
+	return weights, nil
 }
 
 type RedisConfig struct {
@@ -69,53 +356,122 @@ type RedisConfig struct {
 	DB       int
 }
 
-var (
-	config *Config
-	once   sync.Once
-)
+// read builds a Config from the environment
+func read() (*Config, error) {
+	queueWeights, err := parseQueueWeights(getEnv("QUEUE_WEIGHTS", defaultQueueWeights))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUEUE_WEIGHTS: %w", err)
+	}
 
-// GetConfig returns the singleton config instance
-func GetConfig() *Config {
-	once.Do(func() {
-		config = &Config{}
-		config.JWT.Secret = os.Getenv("JWT_SECRET")
-		config.Server.PublicURL = os.Getenv("PUBLIC_URL")
-	})
-	return config
-}
+	hookSecrets, err := parseHookSecrets(getEnv("HOOK_SECRETS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOOK_SECRETS: %w", err)
+	}
+
+	mode, err := ParseRunMode(getEnv("RUN_MODE", string(RunModeAll)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RUN_MODE: %w", err)
+	}
 
-func Load() (*Config, error) {
 	cfg := &Config{
+		Mode: mode,
 		Server: ServerConfig{
-			Host:      getEnv("SERVER_HOST", "localhost"),
-			Port:      getEnvAsInt("SERVER_PORT", 8080),
-			PublicURL: getEnv("PUBLIC_URL", "http://localhost:8080"),
+			Host:          getEnv("SERVER_HOST", "localhost"),
+			Port:          getEnvAsInt("SERVER_PORT", 8080),
+			PublicURL:     getEnv("PUBLIC_URL", "http://localhost:8080"),
+			Environment:   getEnv("APP_ENV", "development"),
+			WaitForWarmup: getEnvAsBool("SERVER_WAIT_FOR_WARMUP", false),
+			BodyLimit:     getEnv("SERVER_BODY_LIMIT", "10M"),
+			GzipLevel:     getEnvAsInt("SERVER_GZIP_LEVEL", 5),
+			GzipMinLength: getEnvAsInt("SERVER_GZIP_MIN_LENGTH", 1024),
+			GzipSkipAccept: getEnvAsList("SERVER_GZIP_SKIP_ACCEPT", []string{
+				"text/event-stream", "image/", "video/", "audio/",
+			}),
+			Admission: AdmissionConfig{
+				Enabled:     getEnvAsBool("ADMISSION_ENABLED", false),
+				MaxInFlight: getEnvAsInt("ADMISSION_MAX_IN_FLIGHT", 256),
+				QueueSize:   getEnvAsInt("ADMISSION_QUEUE_SIZE", 32),
+				ReadWait:    getEnvAsInt("ADMISSION_READ_WAIT", 200),
+				WriteWait:   getEnvAsInt("ADMISSION_WRITE_WAIT", 100),
+			},
+			Query: QueryConfig{
+				MaxLength:     getEnvAsInt("QUERY_MAX_LENGTH", 4096),
+				MaxFilters:    getEnvAsInt("QUERY_MAX_FILTERS", 20),
+				MaxIncludes:   getEnvAsInt("QUERY_MAX_INCLUDES", 10),
+				MaxSortFields: getEnvAsInt("QUERY_MAX_SORT_FIELDS", 5),
+				MaxExcludes:   getEnvAsInt("QUERY_MAX_EXCLUDES", 50),
+				StrictParams:  getEnvAsBool("QUERY_STRICT_PARAMS", false),
+			},
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("POSTGRES_HOST", "localhost"),
-			Port:     getEnvAsInt("POSTGRES_PORT", 5432),
-			User:     getEnv("POSTGRES_USER", "postgres"),
-			Password: getEnv("POSTGRES_PASSWORD", ""),
-			Name:     getEnv("POSTGRES_DB", "kori"),
-			SSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
+			Host:               getEnv("POSTGRES_HOST", "localhost"),
+			Port:               getEnvAsInt("POSTGRES_PORT", 5432),
+			User:               getEnv("POSTGRES_USER", "postgres"),
+			Password:           getEnv("POSTGRES_PASSWORD", ""),
+			Name:               getEnv("POSTGRES_DB", "kori"),
+			SSLMode:            getEnv("POSTGRES_SSLMODE", "disable"),
+			MinIdleConns:       getEnvAsInt("DB_MIN_IDLE_CONNS", 10),
+			WarmupTimeout:      getEnvAsInt("DB_WARMUP_TIMEOUT", 10),
+			SlowQueryThreshold: getEnvAsInt("DB_SLOW_QUERY_THRESHOLD", 0),
+			ExplainSampleRate:  getEnvAsFloat("DB_EXPLAIN_SAMPLE_RATE", 0.1),
+
+			MigrateOnStart:       getEnvAsBool("MIGRATE_ON_START", true),
+			MigrationLockTimeout: getEnvAsInt("DB_MIGRATION_LOCK_TIMEOUT", 300),
+			ProductionHosts:      getEnvAsList("DB_PRODUCTION_HOSTS", nil),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key"),
+			Secret:                   getEnv("JWT_SECRET", "your-secret-key"),
+			Algorithm:                strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256")),
+			Issuer:                   getEnv("JWT_ISSUER", "be0"),
+			Audience:                 getEnv("JWT_AUDIENCE", "be0-api"),
+			MaxTokenBytes:            getEnvAsInt("JWT_MAX_TOKEN_BYTES", 4096),
+			MaxSessions:              getEnvAsInt("AUTH_MAX_SESSIONS", 10),
+			RequireEmailVerification: getEnvAsBool("AUTH_REQUIRE_EMAIL_VERIFICATION", false),
+		},
+		Auth: AuthConfig{
+			MaxLoginAttempts:  getEnvAsInt("AUTH_MAX_LOGIN_ATTEMPTS", 5),
+			LockoutWindow:     getEnvAsInt("AUTH_LOCKOUT_WINDOW", 15),
+			MaxPasswordResets: getEnvAsInt("AUTH_MAX_PASSWORD_RESETS", 3),
+			MicrosoftTenants:  getEnvAsList("AUTH_MICROSOFT_TENANTS", nil),
+			MaxTeamMembers:    getEnvAsInt("AUTH_MAX_TEAM_MEMBERS", 0),
+
+			CaptchaProvider:  getEnv("CAPTCHA_PROVIDER", ""),
+			CaptchaSecretKey: getEnv("CAPTCHA_SECRET_KEY", ""),
+			CaptchaEndpoints: getEnvAsList("CAPTCHA_ENDPOINTS", []string{"register", "password-reset"}),
+			CaptchaTimeout:   getEnvAsInt("CAPTCHA_TIMEOUT", 3000),
+			CaptchaFailOpen:  getEnvAsBool("CAPTCHA_FAIL_OPEN", false),
 		},
 		Storage: StorageConfig{
-			Provider: getEnv("STORAGE_PROVIDER", "local"),
-			BasePath: getEnv("STORAGE_BASE_PATH", "./storage"),
+			Provider:       getEnv("STORAGE_PROVIDER", "local"),
+			BasePath:       getEnv("STORAGE_BASE_PATH", "./storage"),
+			DefaultACL:     getEnv("STORAGE_DEFAULT_ACL", "authenticated-read"),
+			Warmup:         getEnvAsBool("STORAGE_WARMUP", false),
+			ExtractMaxSize: getEnvAsInt("STORAGE_EXTRACT_MAX_SIZE", 25*1024*1024),
 			S3: S3Config{
-				BucketName: getEnv("S3_BUCKET_NAME", ""),
-				Endpoint:   getEnv("S3_ENDPOINT", ""),
-				Region:     getEnv("S3_REGION", ""),
-				AccessKey:  getEnv("S3_ACCESS_KEY", ""),
-				SecretKey:  getEnv("S3_SECRET_KEY", ""),
+				BucketName:       getEnv("S3_BUCKET_NAME", ""),
+				Endpoint:         getEnv("S3_ENDPOINT", ""),
+				Region:           getEnv("S3_REGION", ""),
+				AccessKey:        getEnv("S3_ACCESS_KEY", ""),
+				SecretKey:        getEnv("S3_SECRET_KEY", ""),
+				ReplicaBucket:    getEnv("S3_REPLICA_BUCKET", ""),
+				ReplicaEndpoint:  getEnv("S3_REPLICA_ENDPOINT", ""),
+				ReplicaRegion:    getEnv("S3_REPLICA_REGION", ""),
+				ReplicaCountries: getEnvAsList("S3_REPLICA_COUNTRIES", nil),
+			},
+			CDN: CDNConfig{
+				BaseURL:    getEnv("CDN_BASE_URL", ""),
+				Provider:   getEnv("CDN_PROVIDER", "cloudfront"),
+				KeyPairID:  getEnv("CDN_KEY_PAIR_ID", ""),
+				SigningKey: getEnv("CDN_SIGNING_KEY", ""),
 			},
 		},
 		Worker: WorkerConfig{
-			Concurrency: getEnvAsInt("WORKER_CONCURRENCY", 5),
-			QueueSize:   getEnvAsInt("WORKER_QUEUE_SIZE", 100),
+			Concurrency:     getEnvAsInt("WORKER_CONCURRENCY", 5),
+			QueueSize:       getEnvAsInt("WORKER_QUEUE_SIZE", 100),
+			QueueWeights:    queueWeights,
+			StrictPriority:  getEnvAsBool("WORKER_STRICT_PRIORITY", true),
+			ShutdownTimeout: getEnvAsInt("WORKER_SHUTDOWN_TIMEOUT", 8),
+			HealthPort:      getEnvAsInt("WORKER_HEALTH_PORT", 8081),
 		},
 		Redis: RedisConfig{
 			Addr:     fmt.Sprintf("%s:%d", getEnv("REDIS_HOST", "localhost"), getEnvAsInt("REDIS_PORT", 6379)),
@@ -124,8 +480,106 @@ func Load() (*Config, error) {
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
 		Crypto: CryptoConfig{
-			PrivateKey: getEnv("PRIVATE_KEY", ""),
+			PrivateKey:          getEnv("PRIVATE_KEY", ""),
+			PreviousPrivateKeys: getEnv("PREVIOUS_PRIVATE_KEYS", ""),
+		},
+		GRPC: GRPCConfig{
+			Enabled:      getEnvAsBool("GRPC_ENABLED", false),
+			Port:         getEnvAsInt("GRPC_PORT", 9090),
+			ServiceToken: getEnv("GRPC_SERVICE_TOKEN", ""),
+			CertFile:     getEnv("GRPC_TLS_CERT", ""),
+			KeyFile:      getEnv("GRPC_TLS_KEY", ""),
+			ClientCAFile: getEnv("GRPC_TLS_CLIENT_CA", ""),
 		},
+		CDC: CDCConfig{
+			Broker:        getEnv("CDC_BROKER", "none"),
+			URL:           getEnv("CDC_URL", ""),
+			SubjectPrefix: getEnv("CDC_SUBJECT_PREFIX", "be0."),
+			PollInterval:  getEnvAsInt("CDC_POLL_INTERVAL", 5),
+			BatchSize:     getEnvAsInt("CDC_BATCH_SIZE", 100),
+		},
+		Debug: DebugConfig{
+			CaptureEnabled: getEnvAsBool("DEBUG_CAPTURE_ENABLED", false),
+			CaptureTeams:   getEnvAsList("DEBUG_CAPTURE_TEAMS", nil),
+			RedactPaths: getEnvAsList("DEBUG_CAPTURE_REDACT_PATHS", []string{
+				"password", "newPassword", "token", "accessToken", "refreshToken", "secret", "apiKey", "code",
+			}),
+			MaxBodySize: getEnvAsInt("DEBUG_CAPTURE_MAX_BODY_SIZE", 64*1024),
+			TTL:         getEnvAsInt("DEBUG_CAPTURE_TTL", 15),
+			BufferSize:  getEnvAsInt("DEBUG_CAPTURE_BUFFER_SIZE", 500),
+		},
+		Image: ImageConfig{
+			MaxDimension:  getEnvAsInt("IMAGE_MAX_DIMENSION", 2048),
+			MaxConcurrent: getEnvAsInt("IMAGE_MAX_CONCURRENT", 4),
+			MaxSourceSize: getEnvAsInt("IMAGE_MAX_SOURCE_SIZE", 20*1024*1024),
+			CacheTTL:      getEnvAsInt("IMAGE_CACHE_TTL", 24*7),
+
+			AvatarSigningKey: getEnv("AVATAR_SIGNING_KEY", ""),
+			AvatarRateLimit:  getEnvAsFloat("AVATAR_RATE_LIMIT", 5),
+			AvatarBurst:      getEnvAsInt("AVATAR_BURST", 20),
+			AvatarCacheTTL:   getEnvAsInt("AVATAR_CACHE_TTL", 10),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			Window:  getEnvAsInt("RATE_LIMIT_WINDOW", 60),
+			User:    getEnvAsInt("RATE_LIMIT_USER", 1200),
+			APIKey:  getEnvAsInt("RATE_LIMIT_API_KEY", 1200),
+			IP:      getEnvAsInt("RATE_LIMIT_IP", 300),
+		},
+		Metrics: MetricsConfig{
+			Port:              getEnvAsInt("METRICS_PORT", 0),
+			Token:             getEnv("METRICS_TOKEN", ""),
+			QueuePollInterval: getEnvAsInt("METRICS_QUEUE_POLL_INTERVAL", 15),
+		},
+		APIAnalytics: APIAnalyticsConfig{
+			Enabled:       getEnvAsBool("API_ANALYTICS_ENABLED", true),
+			SampleRate:    getEnvAsFloat("API_ANALYTICS_SAMPLE_RATE", 1),
+			RetentionDays: getEnvAsInt("API_ANALYTICS_RETENTION_DAYS", 30),
+			BufferSize:    getEnvAsInt("API_ANALYTICS_BUFFER_SIZE", 1024),
+		},
+		SMTP: SMTPConfig{
+			Host:       getEnv("SMTP_HOST", ""),
+			Port:       getEnvAsInt("SMTP_PORT", 587),
+			Username:   getEnv("SMTP_USERNAME", ""),
+			Password:   getEnv("SMTP_PASSWORD", ""),
+			From:       getEnv("SMTP_FROM", ""),
+			RateLimit:  getEnvAsInt("SMTP_RATE_LIMIT", 60),
+			RateWindow: getEnvAsInt("SMTP_RATE_WINDOW", 60),
+		},
+		Hooks: HooksConfig{Secrets: hookSecrets},
+	}
+
+	if cfg.Image.AvatarSigningKey == "" {
+		cfg.Image.AvatarSigningKey = cfg.JWT.Secret
+	}
+
+	if rate := cfg.APIAnalytics.SampleRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid API_ANALYTICS_SAMPLE_RATE: %v is not between 0 and 1", rate)
+	}
+	if cfg.APIAnalytics.RetentionDays < 1 {
+		return nil, fmt.Errorf("invalid API_ANALYTICS_RETENTION_DAYS: %d is less than 1", cfg.APIAnalytics.RetentionDays)
+	}
+
+	if !slices.Contains(StorageACLs, cfg.Storage.DefaultACL) {
+		return nil, fmt.Errorf("invalid STORAGE_DEFAULT_ACL: %q is not one of %s", cfg.Storage.DefaultACL, strings.Join(StorageACLs, ", "))
+	}
+
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+
+	if cfg.JWT.Algorithm != "HS256" && cfg.JWT.Algorithm != "RS256" {
+		return nil, fmt.Errorf("invalid JWT_ALGORITHM: %q is not HS256 or RS256", cfg.JWT.Algorithm)
+	}
+
+	switch cfg.Auth.CaptchaProvider {
+	case "":
+	case "hcaptcha", "turnstile":
+		if cfg.Auth.CaptchaSecretKey == "" {
+			return nil, fmt.Errorf("CAPTCHA_SECRET_KEY is required when CAPTCHA_PROVIDER is set")
+		}
+	default:
+		return nil, fmt.Errorf("invalid CAPTCHA_PROVIDER: %q is not hcaptcha or turnstile", cfg.Auth.CaptchaProvider)
 	}
 
 	return cfg, nil
@@ -147,6 +601,39 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func (c *Config) Save(path string) error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"be0/internal/events"
+
+	"github.com/joho/godotenv"
+)
+
+// EventReloaded is emitted with the new *Config after Reload swapped it in, so components
+// holding settings of the previous one can refresh them
+const EventReloaded = "config.reloaded"
+
+var (
+	current atomic.Pointer[Config]
+	once    sync.Once
+	loadErr error
+)
+
+// Load reads the configuration from the environment on the first call. Later calls return
+// the same Config, or the one of the last Reload, which GetConfig returns too.
+func Load() (*Config, error) {
+	once.Do(func() {
+		var cfg *Config
+		if cfg, loadErr = read(); loadErr == nil {
+			current.Store(cfg)
+		}
+	})
+	if cfg := current.Load(); cfg != nil {
+		return cfg, nil
+	}
+	return nil, loadErr
+}
+
+// GetConfig returns the loaded configuration. It panics when called before Load, a config
+// with empty sections would only fail later and further away.
+func GetConfig() *Config {
+	cfg := current.Load()
+	if cfg == nil {
+		panic("config.GetConfig called before config.Load")
+	}
+	return cfg
+}
+
+// Reload re-reads the env file and the environment and swaps the new configuration in, then
+// emits EventReloaded. Readers holding the previous Config keep a consistent one. An invalid
+// configuration is returned as an error and the current one stays.
+func Reload() (*Config, error) {
+	if path := envFilePath(); path != "" {
+		if err := LoadEnvFile(path); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	// 🔁 A reload before any Load counts as the load
+	once.Do(func() {})
+	current.Store(cfg)
+	events.Emit(EventReloaded, cfg)
+	return cfg, nil
+}
+
+var envFile struct {
+	sync.Mutex
+	path string
+	// keys are the variables set from the file, variables of the process environment win
+	// over the file and are never touched
+	keys map[string]bool
+}
+
+func envFilePath() string {
+	envFile.Lock()
+	defer envFile.Unlock()
+	return envFile.path
+}
+
+// LoadEnvFile sets the variables of the env file at path that the process environment does
+// not set. Loading it again, as Reload does, applies the file's changes, including variables
+// removed from it.
+func LoadEnvFile(path string) error {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	envFile.Lock()
+	defer envFile.Unlock()
+	if envFile.path != path {
+		envFile.path, envFile.keys = path, map[string]bool{}
+	}
+	for key := range envFile.keys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(envFile.keys, key)
+		}
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !envFile.keys[key] {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+		envFile.keys[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/events"
+)
+
+// resetLoad forgets the loaded configuration, as in a process that has not called Load yet
+func resetLoad(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		once = sync.Once{}
+		loadErr = nil
+		current.Store(nil)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestGetConfigPanicsBeforeLoad(t *testing.T) {
+	resetLoad(t)
+	defer func() {
+		if p := recover(); p == nil || !strings.Contains(p.(string), "before config.Load") {
+			t.Errorf("got panic %v, want one naming config.Load", p)
+		}
+	}()
+	GetConfig()
+}
+
+func TestLoadPopulatesGetConfig(t *testing.T) {
+	resetLoad(t)
+	t.Setenv("POSTGRES_HOST", "db.internal")
+	t.Setenv("S3_BUCKET", "uploads")
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 📦 Every section is there, not only the ones GetConfig used to fill in
+	if got := GetConfig(); got != loaded || got.Database.Host != "db.internal" {
+		t.Errorf("GetConfig returned %p with database host %q, want Load's %p", got, got.Database.Host, loaded)
+	}
+
+	t.Setenv("POSTGRES_HOST", "other.internal")
+	again, err := Load()
+	if err != nil || again != loaded {
+		t.Errorf("second Load returned %p and %v, want the first config", again, err)
+	}
+}
+
+func TestLoadErrorIsKept(t *testing.T) {
+	resetLoad(t)
+	t.Setenv("RUN_MODE", "everything")
+	if _, err := Load(); err == nil {
+		t.Fatal("loaded an invalid RUN_MODE")
+	}
+	t.Setenv("RUN_MODE", "api")
+	if _, err := Load(); err == nil {
+		t.Error("second Load read the environment again")
+	}
+
+	// Reload is how a fixed environment is picked up
+	if _, err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := Load(); err != nil || cfg.Mode != RunModeAPI {
+		t.Errorf("Load after Reload returned %v", err)
+	}
+}
+
+func TestReloadSwapsConfigAndEmitsEvent(t *testing.T) {
+	resetLoad(t)
+	t.Setenv("SERVER_PORT", "8080")
+	before, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan *Config, 1)
+	events.OnNamed(EventReloaded, "config_test", func(data interface{}) {
+		select {
+		case reloaded <- data.(*Config):
+		default:
+		}
+	})
+
+	t.Setenv("SERVER_PORT", "9090")
+	after, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetConfig() != after || after.Server.Port != 9090 {
+		t.Errorf("GetConfig port %d, want the reloaded 9090", GetConfig().Server.Port)
+	}
+	if before.Server.Port != 8080 {
+		t.Errorf("the previous config changed to port %d", before.Server.Port)
+	}
+	select {
+	case cfg := <-reloaded:
+		if cfg != after {
+			t.Error("the event carried another config")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no config.reloaded event")
+	}
+
+	// ❌ An invalid configuration leaves the current one in place
+	t.Setenv("RUN_MODE", "everything")
+	if _, err := Reload(); err == nil {
+		t.Fatal("reloaded an invalid RUN_MODE")
+	}
+	if GetConfig() != after {
+		t.Error("a failed reload replaced the config")
+	}
+}
+
+func TestConcurrentReadersDuringReload(t *testing.T) {
+	resetLoad(t)
+	t.Setenv("SERVER_PORT", "1000")
+	t.Setenv("SERVER_HOST", "host-1000")
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 8 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Each reader sees one whole config, never the host of one and port of another
+				cfg := GetConfig()
+				if cfg.Server.Host != "host-"+strconv.Itoa(cfg.Server.Port) {
+					t.Errorf("torn read: host %s port %d", cfg.Server.Host, cfg.Server.Port)
+					return
+				}
+			}
+		}()
+	}
+
+	for port := 1001; port <= 1050; port++ {
+		t.Setenv("SERVER_PORT", strconv.Itoa(port))
+		t.Setenv("SERVER_HOST", "host-"+strconv.Itoa(port))
+		if _, err := Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	readers.Wait()
+
+	if port := GetConfig().Server.Port; port != 1050 {
+		t.Errorf("port %d after the last reload, want 1050", port)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("BE0_TEST_PROCESS", "from process")
+	for _, key := range []string{"BE0_TEST_FILE", "BE0_TEST_REMOVED"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Cleanup(func() { envFile.path, envFile.keys = "", nil })
+
+	write("BE0_TEST_FILE=one\nBE0_TEST_REMOVED=gone soon\nBE0_TEST_PROCESS=from file\n")
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("BE0_TEST_FILE"); got != "one" {
+		t.Errorf("BE0_TEST_FILE %q, want one", got)
+	}
+	// 🔒 The process environment wins over the file
+	if got := os.Getenv("BE0_TEST_PROCESS"); got != "from process" {
+		t.Errorf("BE0_TEST_PROCESS %q, want from process", got)
+	}
+
+	write("BE0_TEST_FILE=two\nBE0_TEST_PROCESS=from file\n")
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("BE0_TEST_FILE"); got != "two" {
+		t.Errorf("BE0_TEST_FILE %q after editing the file, want two", got)
+	}
+	if _, set := os.LookupEnv("BE0_TEST_REMOVED"); set {
+		t.Error("a variable removed from the file is still set")
+	}
+	if got := os.Getenv("BE0_TEST_PROCESS"); got != "from process" {
+		t.Errorf("BE0_TEST_PROCESS %q after reloading, want from process", got)
+	}
+}
+
+func TestLoadChecksTheDefaultStorageACL(t *testing.T) {
+	tests := []struct {
+		acl   string
+		valid bool
+	}{
+		{"private", true},
+		{"public-read", true},
+		{"authenticated-read", true},
+		{"public-read-write", false},
+		{"Private", false},
+	}
+	for _, tt := range tests {
+		resetLoad(t)
+		t.Setenv("STORAGE_DEFAULT_ACL", tt.acl)
+		cfg, err := Load()
+		if tt.valid && (err != nil || cfg.Storage.DefaultACL != tt.acl) {
+			t.Errorf("%s: got %v", tt.acl, err)
+		}
+		if !tt.valid && (err == nil || !strings.Contains(err.Error(), "STORAGE_DEFAULT_ACL")) {
+			t.Errorf("%s: got %v, want an invalid STORAGE_DEFAULT_ACL error", tt.acl, err)
+		}
+	}
+}
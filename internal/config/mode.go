@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+// RunMode selects which parts of the process are started
+type RunMode string
+
+const (
+	// RunModeAPI serves HTTP and gRPC and only enqueues tasks
+	RunModeAPI RunMode = "api"
+	// RunModeWorker processes tasks and runs the scheduler, with health and metrics endpoints only
+	RunModeWorker RunMode = "worker"
+	// RunModeAll runs the API and the worker in one process
+	RunModeAll RunMode = "all"
+)
+
+// ParseRunMode validates a --mode flag or RUN_MODE value
+func ParseRunMode(value string) (RunMode, error) {
+	switch mode := RunMode(value); mode {
+	case RunModeAPI, RunModeWorker, RunModeAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown run mode %q, expected api, worker or all", value)
+	}
+}
+
+// ServesAPI reports whether the mode starts the HTTP and gRPC servers
+func (m RunMode) ServesAPI() bool {
+	return m == RunModeAPI || m == RunModeAll
+}
+
+// RunsWorker reports whether the mode starts the task server and scheduler
+func (m RunMode) RunsWorker() bool {
+	return m == RunModeWorker || m == RunModeAll
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseRunMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RunMode
+		wantErr bool
+	}{
+		{value: "api", want: RunModeAPI},
+		{value: "worker", want: RunModeWorker},
+		{value: "all", want: RunModeAll},
+		{value: "", wantErr: true},
+		{value: "API", wantErr: true},
+		{value: "scheduler", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRunMode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRunMode(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRunMode(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunModeStartsItsParts(t *testing.T) {
+	tests := []struct {
+		mode       RunMode
+		servesAPI  bool
+		runsWorker bool
+	}{
+		{mode: RunModeAPI, servesAPI: true},
+		{mode: RunModeWorker, runsWorker: true},
+		{mode: RunModeAll, servesAPI: true, runsWorker: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			if got := tt.mode.ServesAPI(); got != tt.servesAPI {
+				t.Errorf("ServesAPI() = %v, want %v", got, tt.servesAPI)
+			}
+			if got := tt.mode.RunsWorker(); got != tt.runsWorker {
+				t.Errorf("RunsWorker() = %v, want %v", got, tt.runsWorker)
+			}
+		})
+	}
+}
+
+func TestLoadReadsRunMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    RunMode
+		wantErr bool
+	}{
+		{name: "unset keeps today's behavior", want: RunModeAll},
+		{name: "api", env: "api", want: RunModeAPI},
+		{name: "worker", env: "worker", want: RunModeWorker},
+		{name: "unknown", env: "cron", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetLoad(t)
+			t.Setenv("RUN_MODE", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("RUN_MODE")
+			}
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Mode != tt.want {
+				t.Errorf("Mode = %q, want %q", cfg.Mode, tt.want)
+			}
+		})
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 
 	"be0/internal/config"
+	"be0/internal/metrics"
 	"be0/internal/models"
 	console "be0/internal/utils/logger"
 )
@@ -16,8 +18,10 @@ import (
 var DB *gorm.DB
 var log = console.New("DB")
 
-func Connect(cfg *config.Config) error {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+// DSN returns the connection string of the configured database
+func DSN(cfg *config.Config) string {
+	// Pin the session to UTC so now() and timestamp columns agree with Go
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
 		cfg.Database.Host,
 		cfg.Database.User,
 		cfg.Database.Password,
@@ -25,6 +29,10 @@ func Connect(cfg *config.Config) error {
 		cfg.Database.Port,
 		cfg.Database.SSLMode,
 	)
+}
+
+func Connect(cfg *config.Config) error {
+	dsn := DSN(cfg)
 
 	log.Info("Connecting to database...")
 	maxRetries := 5
@@ -35,11 +43,18 @@ func Connect(cfg *config.Config) error {
 			DisableForeignKeyConstraintWhenMigrating: true,
 			PrepareStmt:                              true,
 			AllowGlobalUpdate:                        false,
+			TranslateError:                           true,
+			NowFunc:                                  func() time.Time { return time.Now().UTC() },
 		})
 		if err == nil {
 			log.Info("DSN: %s", dsn)
 			log.Success("Connected to database")
 
+			// 📈 Query latency is exported on /metrics
+			if err := DB.Use(metrics.GormPlugin()); err != nil {
+				return log.Error("Failed to install the metrics plugin", err)
+			}
+
 			// Configure connection pool
 			sqlDB, err := DB.DB()
 			if err != nil {
@@ -47,13 +62,18 @@ func Connect(cfg *config.Config) error {
 			}
 
 			// Set connection pool settings
-			sqlDB.SetMaxOpenConns(100)                 // Maximum number of open connections to the database
-			sqlDB.SetMaxIdleConns(10)                  // Maximum number of idle connections in the pool
-			sqlDB.SetConnMaxLifetime(time.Hour)        // Maximum amount of time a connection may be reused
-			sqlDB.SetConnMaxIdleTime(time.Minute * 30) // Maximum amount of time a connection may be idle
+			sqlDB.SetMaxOpenConns(100)                                // Maximum number of open connections to the database
+			sqlDB.SetMaxIdleConns(max(10, cfg.Database.MinIdleConns)) // Maximum number of idle connections in the pool
+			sqlDB.SetConnMaxLifetime(time.Hour)                       // Maximum amount of time a connection may be reused
+			sqlDB.SetConnMaxIdleTime(time.Minute * 30)                // Maximum amount of time a connection may be idle
+
+			if !cfg.Database.MigrateOnStart {
+				log.Info("Skipping migrations, MIGRATE_ON_START is off")
+				return nil
+			}
 
 			// Run migrations
-			if err := runMigrations(); err != nil {
+			if err := Migrate(DB, time.Duration(cfg.Database.MigrationLockTimeout)*time.Second); err != nil {
 				return log.Error("Failed to run migrations", err)
 			}
 
@@ -67,10 +87,138 @@ func Connect(cfg *config.Config) error {
 	return log.Error("failed to connect to database after %d attempts", fmt.Errorf("failed to connect to database after %d attempts", maxRetries))
 }
 
-func runMigrations() error {
+// migrationModels are auto-migrated in order, models with foreign keys after the ones they reference
+var migrationModels = []interface{}{
+	// Base models without foreign keys
+	&models.User{},
+	&models.Team{},
+	&models.Resource{},
+
+	// Models with single foreign key dependencies
+	&models.PasswordReset{},
+	&models.EmailVerification{},
+	&models.TwoFactorRecoveryCode{},
+	&models.TeamInvite{},
+	&models.AuthTransaction{},
+	&models.APIKey{},
+	&models.RecoveryRequest{},
+	&models.AuditEntry{},
+	&models.AuditLog{},
+	&models.CascadeRecord{},
+	&models.APIRequestLog{},
+	&models.AuditExport{},
+	&models.FileShare{},
+	&models.FileContent{},
+	&models.Template{},
+	// Permission models
+	&models.UserPermission{},
+	&models.ResourcePermission{},
+
+	// Change data capture
+	&models.OutboxEvent{},
+
+	// Webhooks
+	&models.WebhookSubscription{},
+
+	// Sandbox mode
+	&models.SandboxEmail{},
+	&models.EmailLog{},
+	&models.EmailSuppression{},
+	&models.SandboxWebhook{},
+
+	// Background jobs
+	&models.Job{},
+
+	// Organizations, after the teams they group
+	&models.Organization{},
+	&models.OrganizationAdmin{},
+}
+
+// Models returns the auto-migrated models in migration order
+func Models() []interface{} {
+	return append([]interface{}(nil), migrationModels...)
+}
+
+// rawMigrations run after auto-migration, each must be idempotent
+func rawMigrations() []string {
+	return []string{
+		// Sessions created before expiries were recorded
+		models.SessionExpiryBackfill,
+		// Full-text index over extracted document text
+		models.FileContentSearchIndex,
+		// Users created before email verification
+		models.EmailVerificationBackfill,
+		// Reset codes stored in plaintext before they were hashed
+		models.PasswordResetCodeDrop,
+		// Verification codes stored in plaintext before they were hashed
+		models.EmailVerificationCodeDrop,
+		// Sessions that stored their whole access token before tokens had a jti
+		models.SessionTokenIDBackfill,
+		// Teams created before slugs
+		models.TeamSlugBackfill,
+	}
+}
+
+// backfillVersions starts the rows written before versions were counted at version 1. Adding
+// the column fills in its default, this catches rows left at NULL or 0 by writes around the
+// models.
+func backfillVersions(tx *gorm.DB) error {
+	// Auto-migration creates the tables of related models too, files with their users
+	seen := map[string]bool{}
+	var backfill func(s *schema.Schema) error
+	backfill = func(s *schema.Schema) error {
+		if seen[s.Table] {
+			return nil
+		}
+		seen[s.Table] = true
+		if s.LookUpField("version") != nil {
+			// 🔢 By table, so the update hooks don't move the version on
+			if err := tx.Table(s.Table).Where("version IS NULL OR version < 1").UpdateColumn("version", 1).Error; err != nil {
+				return err
+			}
+		}
+		for _, relation := range s.Relationships.Relations {
+			if err := backfill(relation.FieldSchema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, model := range migrationModels {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if err := backfill(stmt.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationLockKey is the advisory lock replicas take turns on while migrating
+const migrationLockKey = 7_245_110_931
+
+// DefaultMigrationLockTimeout is how long an instance waits for another one's migrations
+// when no positive timeout is configured
+const DefaultMigrationLockTimeout = 300 * time.Second
+
+// lockTimeout returns the timeout to wait for the migration lock. Postgres reads a
+// lock_timeout of 0 as no timeout, so 0 and less wait for the default instead of forever.
+func lockTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultMigrationLockTimeout
+	}
+	return timeout
+}
+
+// Migrate auto-migrates the models and applies the raw migrations in one transaction,
+// taking turns with other instances through the migration lock
+func Migrate(gdb *gorm.DB, lockTimeout time.Duration) error {
 	log.Info("Running migrations...")
 	// Begin transaction for migrations
-	tx := DB.Begin()
+	tx := gdb.Begin()
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -82,25 +230,61 @@ func runMigrations() error {
 		}
 	}()
 
-	if err := tx.AutoMigrate(
-		// Base models without foreign keys
-		&models.User{},
-		&models.Team{},
-		&models.Resource{},
-
-		// Models with single foreign key dependencies
-		&models.PasswordReset{},
-		&models.TeamInvite{},
-		&models.AuthTransaction{},
-		// Permission models
-		&models.UserPermission{},
-		&models.ResourcePermission{},
-	); err != nil {
+	if err := lockMigrations(tx, lockTimeout); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.AutoMigrate(migrationModels...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, sql := range rawMigrations() {
+		if err := tx.Exec(sql).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := backfillVersions(tx); err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	migratedAt = time.Now().UTC()
+	return nil
+}
+
+// lockMigrations takes the migration advisory lock for the rest of the transaction,
+// waiting up to timeout while another instance migrates
+func lockMigrations(tx *gorm.DB, timeout time.Duration) error {
+	timeout = lockTimeout(timeout)
+
+	var acquired bool
+	if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", migrationLockKey).Scan(&acquired).Error; err != nil {
+		return err
+	}
+	if acquired {
+		return nil
+	}
+
+	log.Warn("Another instance is running migrations, waiting up to %s", timeout)
+	started := time.Now()
+	if err := tx.Exec(fmt.Sprintf("SET LOCAL lock_timeout = %d", timeout.Milliseconds())).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+		return fmt.Errorf("timed out after %s waiting for another instance's migrations: %w", timeout, err)
+	}
+	log.Info("Migration lock acquired after %s", time.Since(started).Round(time.Millisecond))
+
+	// The timeout was only for the lock, DDL waits as usual
+	return tx.Exec("SET LOCAL lock_timeout TO DEFAULT").Error
 }
 
 func Close() error {
@@ -0,0 +1,200 @@
+package db_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/db"
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/testutil/containers"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(containers.Run(m))
+}
+
+func TestMigrateCreatesEveryTable(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Postgres(t)
+
+	for _, model := range db.Models() {
+		if !gdb.Migrator().HasTable(model) {
+			t.Errorf("table of %T was not created", model)
+		}
+	}
+}
+
+func TestMigrateAppliesRawMigrations(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Postgres(t)
+
+	// Codes are only stored hashed
+	if gdb.Migrator().HasColumn(&models.EmailVerification{}, "code") {
+		t.Error("email_verifications.code was not dropped")
+	}
+	if gdb.Migrator().HasColumn(&models.PasswordReset{}, "code") {
+		t.Error("password_resets.code was not dropped")
+	}
+	if !gdb.Migrator().HasColumn(&models.EmailVerification{}, "code_hash") {
+		t.Error("email_verifications.code_hash is missing")
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Postgres(t)
+
+	team := &models.Team{Name: "Acme"}
+	if err := gdb.Create(team).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Every replica migrates on start, migrating a migrated schema keeps its data
+	for i := 0; i < 2; i++ {
+		if err := db.Migrate(gdb, time.Minute); err != nil {
+			t.Fatalf("migration %d: %v", i+2, err)
+		}
+	}
+
+	var count int64
+	if err := gdb.Model(&models.Team{}).Where("id = ?", team.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("team count %d after migrating again, want 1", count)
+	}
+}
+
+func TestMigrateBackfillsVersions(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Postgres(t)
+
+	team := &models.Team{Name: "Acme"}
+	if err := gdb.Create(team).Error; err != nil {
+		t.Fatal(err)
+	}
+	if team.Version != 1 {
+		t.Errorf("created team at version %d, want 1", team.Version)
+	}
+	// A row written around the model, before versions were counted
+	if err := gdb.Exec("UPDATE teams SET version = 0 WHERE id = ?", team.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(gdb, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err := gdb.Model(&models.Team{}).Where("id = ?", team.ID).Pluck("version", &version).Error; err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("version %d after migrating, want 1", version)
+	}
+}
+
+func TestMigrateReplacesStoredAccessTokensByTheirID(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Postgres(t)
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	session := &models.AuthTransaction{UserID: user.ID, TeamID: team.ID, ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if err := gdb.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	// A session of the schema that stored the whole access token
+	if err := gdb.Exec("ALTER TABLE auth_transactions ADD COLUMN token text NOT NULL DEFAULT ''").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Exec("UPDATE auth_transactions SET token = 'legacy.access.token', token_id = '' WHERE id = ?", session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(gdb, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if gdb.Migrator().HasColumn(&models.AuthTransaction{}, "token") {
+		t.Error("auth_transactions.token was not dropped")
+	}
+	var tokenID string
+	if err := gdb.Model(&models.AuthTransaction{}).Where("id = ?", session.ID).Pluck("token_id", &tokenID).Error; err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("legacy.access.token"))
+	if tokenID != hex.EncodeToString(sum[:]) {
+		t.Errorf("token_id %q, want the SHA-256 of the stored token", tokenID)
+	}
+}
+
+func TestConcurrentMigrationsTakeTurns(t *testing.T) {
+	t.Parallel()
+	gdb := containers.Schema(t)
+
+	// Replicas started together migrate the same empty schema, without the lock their
+	// CREATE TABLEs collide
+	const replicas = 4
+	errs := make(chan error, replicas)
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.Migrate(gdb, time.Minute)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent migration failed: %v", err)
+		}
+	}
+	for _, model := range db.Models() {
+		if !gdb.Migrator().HasTable(model) {
+			t.Errorf("table of %T was not created", model)
+		}
+	}
+}
+
+func TestMigrationGivesUpAfterLockTimeout(t *testing.T) {
+	gdb := containers.Postgres(t)
+
+	// Another instance is migrating and holds the lock until its transaction ends
+	holder := gdb.Begin()
+	if err := holder.Exec("SELECT pg_advisory_xact_lock(?)", db.MigrationLockKey).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Rollback()
+
+	started := time.Now()
+	if err := db.Migrate(gdb, time.Second); err == nil {
+		t.Fatal("migrated while another instance held the lock")
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("gave up after %s, want about the 1s timeout", elapsed)
+	}
+}
+
+func TestLockTimeoutNeverWaitsForever(t *testing.T) {
+	tests := []struct {
+		configured time.Duration
+		want       time.Duration
+	}{
+		// Postgres reads lock_timeout 0 as no timeout
+		{configured: 0, want: db.DefaultMigrationLockTimeout},
+		{configured: -time.Second, want: db.DefaultMigrationLockTimeout},
+		{configured: 5 * time.Second, want: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := db.LockTimeout(tt.configured); got != tt.want {
+			t.Errorf("LockTimeout(%s) = %s, want %s", tt.configured, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package db
+
+// MigrationLockKey lets tests hold the migration lock like another instance would
+const MigrationLockKey = migrationLockKey
+
+// LockTimeout exposes lockTimeout to tests
+var LockTimeout = lockTimeout
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migratedAt is when Migrate last completed, zero when this process skipped migrations
+var migratedAt time.Time
+
+// SchemaInfo describes the schema this process migrated to.
+// Migrations are gorm auto-migrations, so instead of a sequence number the version is a
+// fingerprint of the migrated tables and columns plus the raw SQL migrations.
+type SchemaInfo struct {
+	Version    string    `json:"version"`
+	Tables     []string  `json:"tables"`
+	MigratedAt time.Time `json:"migratedAt"`
+}
+
+// Schema returns the fingerprint of the migrated models
+func Schema() (SchemaInfo, error) {
+	info := SchemaInfo{MigratedAt: migratedAt}
+
+	hash := sha256.New()
+	for _, model := range migrationModels {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			return info, err
+		}
+
+		info.Tables = append(info.Tables, stmt.Schema.Table)
+		hash.Write([]byte(stmt.Schema.Table + ":"))
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				hash.Write([]byte(field.DBName + "," + string(field.DataType) + ";"))
+			}
+		}
+	}
+	for _, sql := range rawMigrations() {
+		hash.Write([]byte(strings.TrimSpace(sql)))
+	}
+
+	info.Version = hex.EncodeToString(hash.Sum(nil))[:12]
+	return info, nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// warmupID never matches a row, it only gets the statements prepared
+const warmupID = "00000000-0000-0000-0000-000000000000"
+
+// Warmup opens minIdleConns connections eagerly and primes the prepared statement cache
+// with the queries every authenticated request runs. It stops when ctx is done.
+func Warmup(ctx context.Context, minIdleConns int) error {
+	start := time.Now()
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return log.Error("Failed to get underlying *sql.DB instance", err)
+	}
+
+	// 🔌 Hold minIdleConns connections at once so the pool has to open them,
+	// releasing them afterwards leaves them idle in the pool
+	conns := make([]*sql.Conn, 0, minIdleConns)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < minIdleConns; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return log.Error("Failed to open warm-up connection", err)
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return log.Error("Failed to ping warm-up connection", err)
+		}
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	conns = nil
+	log.Info("Opened %d idle connections in %s", minIdleConns, time.Since(start))
+
+	// 🔥 Prime the statements used by the auth middleware and the CRUD lookups,
+	// the SQL must match the real queries for the prepared statements to be reused
+	queries := map[string]func(tx *gorm.DB) error{
+		"auth transaction by token": func(tx *gorm.DB) error {
+			return tx.Where("user_id = ? AND team_id = ? AND token_id = ? AND expires_at > ? AND is_deleted = false",
+				warmupID, warmupID, "", time.Now().UTC()).First(&models.AuthTransaction{}).Error
+		},
+		"user by id": func(tx *gorm.DB) error {
+			return tx.Where("id = ? AND is_deleted = false", warmupID).First(&models.User{}).Error
+		},
+		"team by id": func(tx *gorm.DB) error {
+			_, err := models.GetTeamByID(warmupID, tx)
+			return err
+		},
+		"organization admin": func(tx *gorm.DB) error {
+			_, err := models.IsOrganizationAdmin(tx, warmupID, warmupID)
+			return err
+		},
+	}
+
+	for name, query := range queries {
+		queryStart := time.Now()
+		if err := query(DB.WithContext(ctx)); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return log.Error("Warm-up query failed: "+name, err)
+		}
+		log.Info("Primed %s in %s", name, time.Since(queryStart))
+	}
+
+	log.Success("Database warm-up completed in %s", time.Since(start))
+	return nil
+}
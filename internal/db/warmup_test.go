@@ -0,0 +1,37 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"be0/internal/db"
+	"be0/internal/testutil"
+)
+
+func TestWarmupLeavesIdleConnectionsInThePool(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxIdleConns(10)
+
+	// 🔥 The primed lookups find no rows, which is not a failure
+	if err := db.Warmup(context.Background(), 4); err != nil {
+		t.Fatal(err)
+	}
+	if stats := sqlDB.Stats(); stats.Idle < 4 || stats.InUse != 0 {
+		t.Errorf("%d idle and %d in use connections after the warm-up, want at least 4 idle and none in use", stats.Idle, stats.InUse)
+	}
+}
+
+func TestWarmupStopsWithItsContext(t *testing.T) {
+	testutil.UseDB(t, testutil.NewDB(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.Warmup(ctx, 2); err == nil {
+		t.Error("warm-up with a cancelled context succeeded")
+	}
+}
@@ -0,0 +1,102 @@
+package events
+
+import "time"
+
+// Auth and security events delivered to team webhooks
+const (
+	EventTeamMemberRemoved = "team.member_removed"
+	EventTeamInviteSent    = "team.invite_sent"
+	EventSecurityAlert     = "security.alert"
+	EventLoginFailed       = "auth.login_failed"
+	EventNewDeviceLogin    = "security.new_device_login"
+	// EventVerificationRequested carries the *models.EmailVerification, code included, for the mailer
+	EventVerificationRequested = "users.verification_requested"
+	// EventTeamInviteCreated and EventTeamInviteResent carry the *models.TeamInvite, code included
+	// and with Team and Inviter preloaded, for the invite email
+	EventTeamInviteCreated = "team_invites.created"
+	EventTeamInviteResent  = "team_invites.resent"
+)
+
+// Security alert kinds
+const (
+	AlertNewDevice         = "new_device"
+	AlertRecoveryRequested = "account_recovery_requested"
+	AlertAccountRecovered  = "account_recovered"
+	AlertTwoFactorDisabled = "two_factor_disabled"
+	// AlertRefreshTokenReused means a rotated refresh token came back, the session was revoked
+	AlertRefreshTokenReused = "refresh_token_reused"
+)
+
+// TeamScoped is implemented by event payloads that belong to a single team
+type TeamScoped interface {
+	EventTeamID() string
+}
+
+// MemberRemoved is emitted when a user is removed from their team
+type MemberRemoved struct {
+	UserID     string    `json:"userId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	RemovedBy  string    `json:"removedBy"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e MemberRemoved) EventTeamID() string { return e.TeamID }
+
+// InviteSent is emitted when an invitation is sent or resent. The invite code only goes to the invitee.
+type InviteSent struct {
+	InviteID   string    `json:"inviteId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	Role       string    `json:"role"`
+	InviterID  string    `json:"inviterId"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Resent     bool      `json:"resent"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e InviteSent) EventTeamID() string { return e.TeamID }
+
+// LoginFailed is emitted when a known user fails to sign in
+type LoginFailed struct {
+	UserID     string    `json:"userId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	Reason     string    `json:"reason"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e LoginFailed) EventTeamID() string { return e.TeamID }
+
+// SecurityAlert is emitted on suspicious account activity, e.g. a login from a new device
+type SecurityAlert struct {
+	Alert      string    `json:"alert"`
+	UserID     string    `json:"userId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e SecurityAlert) EventTeamID() string { return e.TeamID }
+
+// NewDeviceLogin is emitted when a user who signed in before starts a session on a device
+// they never used. Location fields are empty when the IP address could not be located.
+type NewDeviceLogin struct {
+	UserID     string    `json:"userId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	DeviceID   string    `json:"deviceId"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	Country    string    `json:"country,omitempty"`
+	Region     string    `json:"region,omitempty"`
+	City       string    `json:"city,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e NewDeviceLogin) EventTeamID() string { return e.TeamID }
@@ -1,9 +1,16 @@
 package events
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"be0/internal/metrics"
 	console "be0/internal/utils/logger"
 )
 
@@ -11,8 +18,23 @@ var log = console.New("EVENTS")
 
 type EventHandler func(interface{})
 
+// registration is a handler with the counters shown by the admin introspection endpoint
+type registration struct {
+	name        string
+	handler     EventHandler
+	invocations atomic.Int64
+	panics      atomic.Int64
+	totalNanos  atomic.Int64
+	lastPanic   atomic.Pointer[panicRecord]
+}
+
+type panicRecord struct {
+	message string
+	at      time.Time
+}
+
 type EventBus struct {
-	handlers map[string][]EventHandler
+	handlers map[string][]*registration
 	mu       sync.RWMutex
 }
 
@@ -20,21 +42,29 @@ var defaultBus = NewEventBus()
 
 func NewEventBus() *EventBus {
 	return &EventBus{
-		handlers: make(map[string][]EventHandler),
+		handlers: make(map[string][]*registration),
 	}
 }
 
-// On registers a handler for an event
+// On registers a handler for an event, named after the handler function
 func (bus *EventBus) On(event string, handler EventHandler) {
+	bus.OnNamed(event, handlerName(handler), handler)
+}
+
+// OnNamed registers a handler for an event under an explicit name. Closures get
+// generated names like pkg.Start.func1, so name handlers that should be recognisable.
+func (bus *EventBus) OnNamed(event, name string, handler EventHandler) {
 	bus.mu.Lock()
 	defer bus.mu.Unlock()
 
-	bus.handlers[event] = append(bus.handlers[event], handler)
-	log.Info("Registered handler for event: %s", event)
+	bus.handlers[event] = append(bus.handlers[event], &registration{name: name, handler: handler})
+	log.Info("Registered handler %s for event: %s", name, event)
 }
 
 // Emit triggers an event with the given data
 func (bus *EventBus) Emit(event string, data interface{}) {
+	metrics.CountEvent(event)
+
 	bus.mu.RLock()
 	handlers, exists := bus.handlers[event]
 	bus.mu.RUnlock()
@@ -45,19 +75,148 @@ func (bus *EventBus) Emit(event string, data interface{}) {
 
 	log.Info("Emitting event: %s", event)
 
-	for _, handler := range handlers {
-		go func(h EventHandler) {
-			defer func() {
-				if r := recover(); r != nil {
-					err := log.Error("Panic in event handler: %v", fmt.Errorf("panic: %v", r))
-					if err != nil {
-						return
-					}
-				}
-			}()
-			h(data)
-		}(handler)
+	for _, reg := range handlers {
+		go reg.invoke(data)
+	}
+}
+
+// invoke runs the handler, recording its latency and recovering panics
+func (r *registration) invoke(data interface{}) (err error) {
+	started := time.Now()
+	defer func() {
+		r.invocations.Add(1)
+		r.totalNanos.Add(int64(time.Since(started)))
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			r.panics.Add(1)
+			r.lastPanic.Store(&panicRecord{message: err.Error(), at: time.Now().UTC()})
+			log.Error("Panic in event handler %s: %v", err, r.name)
+		}
+	}()
+	r.handler(data)
+	return nil
+}
+
+// HandlerStats describes a registered handler and how it has behaved since startup
+type HandlerStats struct {
+	Name         string     `json:"name"`
+	Invocations  int64      `json:"invocations"`
+	Panics       int64      `json:"panics"`
+	AvgLatencyMs float64    `json:"avgLatencyMs"`
+	LastPanic    string     `json:"lastPanic,omitempty"`
+	LastPanicAt  *time.Time `json:"lastPanicAt,omitempty"`
+}
+
+// Subscription lists the handlers of one event
+type Subscription struct {
+	Event    string         `json:"event"`
+	Handlers []HandlerStats `json:"handlers"`
+}
+
+// Subscriptions returns every event with registered handlers, sorted by event name
+func (bus *EventBus) Subscriptions() []Subscription {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	subscriptions := make([]Subscription, 0, len(bus.handlers))
+	for event, handlers := range bus.handlers {
+		subscription := Subscription{Event: event, Handlers: make([]HandlerStats, 0, len(handlers))}
+		for _, reg := range handlers {
+			subscription.Handlers = append(subscription.Handlers, reg.stats())
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Event < subscriptions[j].Event })
+	return subscriptions
+}
+
+func (r *registration) stats() HandlerStats {
+	stats := HandlerStats{
+		Name:        r.name,
+		Invocations: r.invocations.Load(),
+		Panics:      r.panics.Load(),
+	}
+	if stats.Invocations > 0 {
+		stats.AvgLatencyMs = float64(r.totalNanos.Load()) / float64(stats.Invocations) / float64(time.Millisecond)
+	}
+	if last := r.lastPanic.Load(); last != nil {
+		stats.LastPanic = last.message
+		stats.LastPanicAt = &last.at
+	}
+	return stats
+}
+
+// ErrHandlerNotFound is returned by Test for an unknown event or handler name
+var ErrHandlerNotFound = errors.New("handler not found")
+
+// DryRun wraps the payload of a test invocation. Handlers with side effects check
+// for it with Unwrap, stop before them and report problems with Fail.
+type DryRun struct {
+	Payload interface{}
+	err     error
+}
+
+// Fail records why the handler could not have processed the payload
+func (d *DryRun) Fail(err error) {
+	d.err = err
+}
+
+// Unwrap returns the payload of an invocation and, for test invocations, the DryRun
+func Unwrap(data interface{}) (interface{}, *DryRun) {
+	if dryRun, ok := data.(*DryRun); ok {
+		return dryRun.Payload, dryRun
 	}
+	return data, nil
+}
+
+// TestResult reports a synchronous dry-run invocation
+type TestResult struct {
+	Handler   string  `json:"handler"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Test invokes one handler of an event synchronously with a DryRun payload.
+// The invocation counts towards the handler's stats like any other.
+func (bus *EventBus) Test(event, handler string, payload interface{}) (*TestResult, error) {
+	bus.mu.RLock()
+	var target *registration
+	for _, reg := range bus.handlers[event] {
+		if reg.name == handler {
+			target = reg
+			break
+		}
+	}
+	bus.mu.RUnlock()
+
+	if target == nil {
+		return nil, ErrHandlerNotFound
+	}
+
+	dryRun := &DryRun{Payload: payload}
+	started := time.Now()
+	err := target.invoke(dryRun)
+	if err == nil {
+		err = dryRun.err
+	}
+	result := &TestResult{Handler: target.name, LatencyMs: float64(time.Since(started)) / float64(time.Millisecond)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// handlerName is the runtime name of a handler function
+func handlerName(handler EventHandler) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
+
+// Default returns the event bus the package functions use
+func Default() *EventBus {
+	return defaultBus
 }
 
 // On Global event functions that use the default event bus
@@ -65,6 +224,21 @@ func On(event string, handler EventHandler) {
 	defaultBus.On(event, handler)
 }
 
+// OnNamed registers a named handler on the default event bus
+func OnNamed(event, name string, handler EventHandler) {
+	defaultBus.OnNamed(event, name, handler)
+}
+
 func Emit(event string, data interface{}) {
 	defaultBus.Emit(event, data)
 }
+
+// Subscriptions lists the handlers registered on the default event bus
+func Subscriptions() []Subscription {
+	return defaultBus.Subscriptions()
+}
+
+// Test invokes a handler of the default event bus with a dry-run payload
+func Test(event, handler string, payload interface{}) (*TestResult, error) {
+	return defaultBus.Test(event, handler, payload)
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// namedHandler is registered without a name, the bus names it after the function
+func namedHandler(interface{}) {}
+
+func TestBusRecordsHandlerStats(t *testing.T) {
+	bus := NewEventBus()
+	done := make(chan struct{}, 2)
+	bus.OnNamed("b.event", "steady", func(interface{}) { done <- struct{}{} })
+	bus.OnNamed("b.event", "flaky", func(interface{}) {
+		defer func() { done <- struct{}{} }()
+		panic("boom")
+	})
+	bus.On("a.event", namedHandler)
+
+	bus.Emit("b.event", "payload")
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the handlers did not run")
+		}
+	}
+
+	// ⏳ The counters are updated once a handler returns or panics
+	var subscriptions []Subscription
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		subscriptions = bus.Subscriptions()
+		if total := subscriptions[1].Handlers[0].Invocations + subscriptions[1].Handlers[1].Invocations; total == 2 || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	if len(subscriptions) != 2 || subscriptions[0].Event != "a.event" || subscriptions[1].Event != "b.event" {
+		t.Fatalf("subscriptions %+v, want a.event then b.event", subscriptions)
+	}
+	if name := subscriptions[0].Handlers[0].Name; name != "be0/internal/events.namedHandler" {
+		t.Errorf("unnamed handler registered as %q", name)
+	}
+	steady, flaky := subscriptions[1].Handlers[0], subscriptions[1].Handlers[1]
+	if steady.Invocations != 1 || steady.Panics != 0 || steady.LastPanic != "" {
+		t.Errorf("steady handler %+v", steady)
+	}
+	if flaky.Invocations != 1 || flaky.Panics != 1 || flaky.LastPanic != "panic: boom" || flaky.LastPanicAt == nil {
+		t.Errorf("flaky handler %+v", flaky)
+	}
+}
+
+func TestBusTestInvokesOneHandlerAsADryRun(t *testing.T) {
+	bus := NewEventBus()
+	var got interface{}
+	bus.OnNamed("users.created", "validator", func(data interface{}) {
+		payload, dryRun := Unwrap(data)
+		got = payload
+		if dryRun == nil {
+			t.Error("the test invocation is not a dry run")
+			return
+		}
+		if _, ok := payload.(map[string]interface{}); !ok {
+			dryRun.Fail(errors.New("payload is not an object"))
+		}
+	})
+	bus.OnNamed("users.created", "other", func(interface{}) { t.Error("another handler ran") })
+
+	result, err := bus.Test("users.created", "validator", map[string]interface{}{"id": "1"})
+	if err != nil || result.Handler != "validator" || result.Error != "" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	if payload, ok := got.(map[string]interface{}); !ok || payload["id"] != "1" {
+		t.Errorf("the handler got %v", got)
+	}
+
+	if result, _ := bus.Test("users.created", "validator", "text"); result.Error != "payload is not an object" {
+		t.Errorf("a failed dry run reported %q", result.Error)
+	}
+	if _, err := bus.Test("users.created", "missing", nil); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("an unknown handler got %v, want ErrHandlerNotFound", err)
+	}
+	if stats := bus.Subscriptions()[0].Handlers[0]; stats.Invocations != 2 {
+		t.Errorf("test invocations counted %d times, want 2", stats.Invocations)
+	}
+
+	// Payloads outside a test invocation pass through Unwrap unchanged
+	if payload, dryRun := Unwrap("live"); payload != "live" || dryRun != nil {
+		t.Errorf("Unwrap of a live payload got %v, %v", payload, dryRun)
+	}
+}
@@ -0,0 +1,83 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOCX extracts the body text of Word documents
+type DOCX struct{}
+
+func (DOCX) Supports(contentType string) bool {
+	return contentType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+}
+
+func (DOCX) Extract(ctx context.Context, content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("invalid docx: %w", err)
+	}
+
+	for _, f := range archive.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return "", fmt.Errorf("failed to open document body: %w", err)
+		}
+		defer r.Close()
+		return documentText(ctx, r)
+	}
+	return "", fmt.Errorf("invalid docx: word/document.xml not found")
+}
+
+// documentText collects the text runs of a WordprocessingML body, one line per paragraph
+func documentText(ctx context.Context, r io.Reader) (string, error) {
+	var text strings.Builder
+	decoder := xml.NewDecoder(r)
+	inText := false
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid document body: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "br":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,66 @@
+// Package extract pulls plain text out of uploaded documents so they can be searched
+package extract
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned when no extractor handles a content type
+var ErrUnsupported = errors.New("unsupported content type")
+
+// Extractor turns a document into plain text
+type Extractor interface {
+	// Supports reports whether the extractor handles a MIME content type
+	Supports(contentType string) bool
+	Extract(ctx context.Context, content []byte) (string, error)
+}
+
+var (
+	extractors   []Extractor
+	extractorsMu sync.RWMutex
+)
+
+// Register adds an extractor. Later registrations take precedence, so a deployment
+// can swap the default PDF extractor for its own implementation.
+func Register(e Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = append([]Extractor{e}, extractors...)
+}
+
+// For returns the extractor for a content type, if any
+func For(contentType string) (Extractor, bool) {
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	for _, e := range extractors {
+		if e.Supports(contentType) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Supported reports whether text can be extracted from a content type
+func Supported(contentType string) bool {
+	_, ok := For(contentType)
+	return ok
+}
+
+// Text extracts text from content using the registered extractor for its type
+func Text(ctx context.Context, contentType string, content []byte) (string, error) {
+	e, ok := For(contentType)
+	if !ok {
+		return "", ErrUnsupported
+	}
+	return e.Extract(ctx, content)
+}
+
+func init() {
+	Register(PDF{})
+	Register(DOCX{})
+}
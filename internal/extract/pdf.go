@@ -0,0 +1,83 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF extracts the text of PDF documents in Go, so it works in the static runtime image
+type PDF struct{}
+
+func (PDF) Supports(contentType string) bool {
+	return contentType == "application/pdf"
+}
+
+func (PDF) Extract(ctx context.Context, content []byte) (text string, err error) {
+	// The parser panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("invalid pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("invalid pdf: %w", err)
+	}
+
+	var out strings.Builder
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= reader.NumPage(); i++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		// Fonts are shared between pages, so each one is only decoded once
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return "", fmt.Errorf("failed to read page %d: %w", i, err)
+		}
+		out.WriteString(pageText)
+		out.WriteByte('\n')
+	}
+	return out.String(), nil
+}
+
+// PDFToText extracts PDF text with the poppler pdftotext binary, which must be on PATH.
+// It handles more layouts than PDF, deployments shipping poppler can register it instead.
+type PDFToText struct{}
+
+func (PDFToText) Supports(contentType string) bool {
+	return contentType == "application/pdf"
+}
+
+func (PDFToText) Extract(ctx context.Context, content []byte) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pdftotext", "-q", "-enc", "UTF-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("pdftotext failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("pdftotext failed: %w", err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,89 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// minimalPDF builds a one page PDF per text, each showing the text in Helvetica
+func minimalPDF(texts ...string) []byte {
+	var objects []string
+	pageRefs := make([]string, len(texts))
+	// 1 is the catalog, 2 the page tree, 3 the font, then a page and its content per text
+	for i, text := range texts {
+		page, contents := 4+2*i, 5+2*i
+		pageRefs[i] = fmt.Sprintf("%d 0 R", page)
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", contents),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageRefs, " "), len(texts)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}, objects...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPDFExtractsTextOfEveryPage(t *testing.T) {
+	text, err := Text(context.Background(), "application/pdf", minimalPDF("Quarterly report", "Revenue grew"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Quarterly report", "Revenue grew"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("extracted %q, missing %q", text, want)
+		}
+	}
+}
+
+func TestPDFRejectsMalformedDocuments(t *testing.T) {
+	valid := minimalPDF("Hello")
+	for name, content := range map[string][]byte{
+		"empty":     nil,
+		"not a pdf": []byte("hello world"),
+		"truncated": valid[:len(valid)/2],
+		"garbage":   append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte{0xff, 0x00}, 512)...),
+	} {
+		if _, err := (PDF{}).Extract(context.Background(), content); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPDFStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (PDF{}).Extract(ctx, minimalPDF("Hello")); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestDefaultPDFExtractorNeedsNoBinary(t *testing.T) {
+	e, ok := For("application/pdf; charset=binary")
+	if !ok {
+		t.Fatal("no extractor for PDFs")
+	}
+	if _, ok := e.(PDF); !ok {
+		t.Errorf("default PDF extractor is %T, want the pure Go PDF", e)
+	}
+}
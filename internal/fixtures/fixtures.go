@@ -0,0 +1,240 @@
+// Package fixtures seeds deterministic data for local development
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var log = logger.New("fixtures")
+
+// Uploader stores fixture files, usually the configured S3 service
+type Uploader interface {
+	UploadFile(ctx context.Context, file []byte, filename string, acl types.ObjectCannedACL, contentType string) (string, error)
+}
+
+// Profiles lists the available seed profiles
+var Profiles = map[string]func(ctx context.Context, db *gorm.DB, storage Uploader) (*Result, error){
+	"demo": seedDemo,
+}
+
+// Result summarizes a seed run
+type Result struct {
+	TeamID      string
+	Credentials map[string]string // email to password
+	Upserted    map[string]int    // table to rows created or updated
+}
+
+// namespace derives the stable IDs of fixture rows, so re-running updates instead of duplicating
+var namespace = uuid.MustParse("0c6c1a52-8f0e-4d7a-9b3e-2f5d4be0f1a7")
+
+// ID returns the stable ID of a fixture row
+func ID(profile, name string) string {
+	return uuid.NewSHA1(namespace, []byte(profile+"/"+name)).String()
+}
+
+// Seed creates or updates the rows of a profile
+func Seed(ctx context.Context, db *gorm.DB, storage Uploader, profile string) (*Result, error) {
+	seed, ok := Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+
+	// 🔑 Users get their role's permissions, which must exist first
+	if err := models.SeedPermissions(db); err != nil {
+		return nil, fmt.Errorf("failed to seed permissions: %w", err)
+	}
+
+	return seed(ctx, db.WithContext(ctx), storage)
+}
+
+// Demo credentials, for local development only
+const (
+	DemoAdminEmail   = "admin@demo.local"
+	DemoMemberEmail  = "member@demo.local"
+	DemoPassword     = "demo-password"
+	DemoInviteEmail  = "invitee@demo.local"
+	demoInviteCode   = "DEMO-INVITE"
+	demoInviteExpiry = 30 * 24 * time.Hour
+)
+
+func seedDemo(ctx context.Context, db *gorm.DB, storage Uploader) (*Result, error) {
+	result := &Result{
+		TeamID:      ID("demo", "team"),
+		Credentials: map[string]string{DemoAdminEmail: DemoPassword, DemoMemberEmail: DemoPassword},
+		Upserted:    map[string]int{},
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(DemoPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		team := models.Team{Base: models.Base{ID: result.TeamID}, Name: "Demo Team", StoragePolicy: models.StoragePolicyPublicAllowed}
+		if err := upsert(tx, &team, "name"); err != nil {
+			return err
+		}
+		result.Upserted["teams"]++
+
+		now := time.Now().UTC()
+		users := []models.User{
+			{Base: models.Base{ID: ID("demo", "admin")}, Email: DemoAdminEmail, FirstName: "Ada", LastName: "Admin", Role: models.UserRoleAdmin},
+			{Base: models.Base{ID: ID("demo", "member")}, Email: DemoMemberEmail, FirstName: "Max", LastName: "Member", Role: models.UserRoleMember},
+		}
+		for i := range users {
+			user := &users[i]
+			user.TeamID = team.ID
+			user.Password = string(hashedPassword)
+			user.EmailVerified = true
+			user.EmailVerifiedAt = &now
+			if err := upsert(tx, user, "email", "first_name", "last_name", "role", "team_id", "password", "email_verified", "email_verified_at"); err != nil {
+				return err
+			}
+			if err := assignPermissions(tx, user); err != nil {
+				return err
+			}
+			result.Upserted["users"]++
+		}
+
+		invite := models.TeamInvite{
+			Base:      models.Base{ID: ID("demo", "invite")},
+			Email:     DemoInviteEmail,
+			Name:      "Ivy Invitee",
+			TeamID:    team.ID,
+			InviterID: users[0].ID,
+			Role:      models.UserRoleMember,
+			Code:      demoInviteCode,
+			Status:    models.InviteStatusPending,
+			ExpiresAt: now.Add(demoInviteExpiry),
+		}
+		if err := upsert(tx, &invite, "email", "name", "team_id", "inviter_id", "role", "code", "status", "expires_at"); err != nil {
+			return err
+		}
+		result.Upserted["team_invites"]++
+
+		template := models.Template{
+			Base:      models.Base{ID: ID("demo", "template/welcome")},
+			TeamID:    team.ID,
+			Name:      "Welcome",
+			Subject:   "Welcome to {{team}}, {{firstName}}",
+			HTML:      `<html><body><h1>Hi {{firstName}}</h1><p>Welcome to {{team}}.</p></body></html>`,
+			Variables: []string{"firstName", "team"},
+		}
+		if err := upsert(tx, &template, "team_id", "name", "subject", "html", "variables"); err != nil {
+			return err
+		}
+		result.Upserted["templates"]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 📁 Uploaded outside the transaction, objects are only stored once per fixture
+	avatar, err := avatarPNG()
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		name, contentType string
+		owner             string
+		content           []byte
+	}{
+		{"avatar.png", "image/png", ID("demo", "admin"), avatar},
+		{"welcome.txt", "text/plain", ID("demo", "admin"), []byte("Welcome to the demo team.\n")},
+		{"notes.txt", "text/plain", ID("demo", "member"), []byte("Things to try: upload a file, invite a teammate.\n")},
+	}
+	for _, f := range files {
+		file := models.File{
+			Base:   models.Base{ID: ID("demo", "file/"+f.name)},
+			TeamID: result.TeamID,
+			UserID: &f.owner,
+			Name:   f.name,
+			Size:   int64(len(f.content)),
+			Type:   f.contentType,
+			ACL:    string(types.ObjectCannedACLAuthenticatedRead),
+		}
+		if err := seedFile(ctx, db, storage, &file, f.content); err != nil {
+			return nil, err
+		}
+		result.Upserted["files"]++
+	}
+
+	if err := db.Model(&models.User{}).Where("id = ?", ID("demo", "admin")).
+		Update("profile_picture_id", ID("demo", "file/avatar.png")).Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// upsert inserts a row with a stable ID or updates the given columns, reviving it if it was soft deleted
+func upsert(tx *gorm.DB, value interface{}, columns ...string) error {
+	columns = append(columns, "updated_at", "is_deleted", "deleted_at")
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(value).Error
+}
+
+// assignPermissions gives a user their role's default permissions unless they already have some
+func assignPermissions(tx *gorm.DB, user *models.User) error {
+	var count int64
+	if err := tx.Model(&models.UserPermission{}).Where("user_id = ? AND is_deleted = false", user.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return models.AssignDefaultPermissions(tx, user)
+}
+
+// seedFile uploads a fixture unless an identical one is already stored
+func seedFile(ctx context.Context, db *gorm.DB, storage Uploader, file *models.File, content []byte) error {
+	var existing models.File
+	err := db.WithContext(models.WithExcludedFields(ctx, "signedUrl")).Select("id", "path", "size").Where("id = ? AND is_deleted = false", file.ID).Take(&existing).Error
+	if err == nil && existing.Size == file.Size {
+		file.Path = existing.Path
+	} else {
+		url, err := storage.UploadFile(ctx, content, file.Name, types.ObjectCannedACL(file.ACL), file.Type)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", file.Name, err)
+		}
+		file.Path = url[strings.LastIndex(url, "/")+1:]
+		log.Info("Uploaded %s", file.Name)
+	}
+
+	return upsert(db, file, "team_id", "user_id", "path", "name", "size", "type", "acl")
+}
+
+// avatarPNG renders a small solid image, enough to exercise image variants
+func avatarPNG() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: 52, G: 152, B: 219, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recordingUploader keeps the names of the files it was asked to store
+type recordingUploader struct {
+	uploaded []string
+}
+
+func (u *recordingUploader) UploadFile(_ context.Context, _ []byte, filename string, _ types.ObjectCannedACL, _ string) (string, error) {
+	u.uploaded = append(u.uploaded, filename)
+	return "https://storage.example.com/fixtures-" + filename, nil
+}
+
+func TestSeedDemoIsIdempotent(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	storage := &recordingUploader{}
+	ctx := context.Background()
+
+	first, err := Seed(ctx, gdb, storage, "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 🗑️ A soft deleted fixture is revived by the next run
+	if err := gdb.Model(&models.User{}).Where("id = ?", ID("demo", "member")).
+		Updates(map[string]interface{}{"is_deleted": true, "first_name": "Renamed"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	second, err := Seed(ctx, gdb, storage, "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.TeamID != second.TeamID || first.TeamID != ID("demo", "team") {
+		t.Errorf("seeded teams %s and %s, want the stable ID", first.TeamID, second.TeamID)
+	}
+
+	counts := map[string]int64{}
+	for table, model := range map[string]interface{}{
+		"teams": &models.Team{}, "users": &models.User{}, "team_invites": &models.TeamInvite{},
+		"templates": &models.Template{}, "files": &models.File{},
+	} {
+		var count int64
+		gdb.Model(model).Where("is_deleted = false").Count(&count)
+		counts[table] = count
+	}
+	want := map[string]int64{"teams": 1, "users": 2, "team_invites": 1, "templates": 1, "files": 3}
+	for table, n := range want {
+		if counts[table] != n {
+			t.Errorf("%d %s after two runs, want %d", counts[table], table, n)
+		}
+	}
+	if len(storage.uploaded) != 3 {
+		t.Errorf("uploaded %v, want each file once", storage.uploaded)
+	}
+
+	var member models.User
+	if err := gdb.First(&member, "id = ?", ID("demo", "member")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if member.FirstName != "Max" || bcrypt.CompareHashAndPassword([]byte(member.Password), []byte(DemoPassword)) != nil {
+		t.Errorf("the member was not reset: %s", member.FirstName)
+	}
+	var admin models.User
+	if err := gdb.First(&admin, "email = ?", DemoAdminEmail).Error; err != nil {
+		t.Fatal(err)
+	}
+	if admin.ProfilePictureID != ID("demo", "file/avatar.png") {
+		t.Errorf("the admin's picture is %q", admin.ProfilePictureID)
+	}
+
+	// 🔑 Permissions are assigned once, not again on every run
+	var granted int64
+	gdb.Model(&models.UserPermission{}).Where("user_id = ?", member.ID).Count(&granted)
+	permissions, err := models.PermissionsForRole(gdb, models.UserRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if granted != int64(len(permissions)) {
+		t.Errorf("the member holds %d permissions, want %d", granted, len(permissions))
+	}
+}
+
+func TestSeedRejectsUnknownProfiles(t *testing.T) {
+	if _, err := Seed(context.Background(), testutil.NewDB(t), &recordingUploader{}, "production"); err == nil {
+		t.Error("an unknown profile was seeded")
+	}
+}
+
+func TestWipeRefusesWhatLooksLikeProduction(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	if err := Wipe(gdb, []string{"users"}, true); !errors.Is(err, ErrLooksLikeProduction) {
+		t.Errorf("APP_ENV production got %v, want ErrLooksLikeProduction", err)
+	}
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	for range maxWipeTeams {
+		testutil.CreateTeam(t, gdb, "More")
+	}
+	if err := Wipe(gdb, []string{"users"}, false); !errors.Is(err, ErrLooksLikeProduction) {
+		t.Errorf("%d teams got %v, want ErrLooksLikeProduction", maxWipeTeams+1, err)
+	}
+	var kept int64
+	gdb.Model(&models.Team{}).Where("id = ?", team.ID).Count(&kept)
+	if kept != 1 {
+		t.Error("a refused wipe removed rows")
+	}
+}
@@ -0,0 +1,57 @@
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// systemTables keep their rows on a wipe, the seeded permission catalogue
+var systemTables = []string{"resources", "resource_permissions"}
+
+// Databases with more rows than these look like they hold real data and are never wiped
+const (
+	maxWipeUsers = 50
+	maxWipeTeams = 10
+	maxWipeFiles = 1000
+)
+
+// ErrLooksLikeProduction is returned when a wipe is refused
+var ErrLooksLikeProduction = errors.New("database looks like production, refusing to wipe")
+
+// Wipe truncates every table except the system tables. It refuses when production is
+// true or when the row counts suggest real data.
+func Wipe(db *gorm.DB, tables []string, production bool) error {
+	if production {
+		return fmt.Errorf("%w: APP_ENV is production", ErrLooksLikeProduction)
+	}
+
+	for table, limit := range map[string]int64{"users": maxWipeUsers, "teams": maxWipeTeams, "files": maxWipeFiles} {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		var count int64
+		if err := db.Table(table).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > limit {
+			return fmt.Errorf("%w: %s has %d rows, more than %d", ErrLooksLikeProduction, table, count, limit)
+		}
+	}
+
+	var truncate []string
+	for _, table := range tables {
+		if !slices.Contains(systemTables, table) && db.Migrator().HasTable(table) {
+			truncate = append(truncate, `"`+table+`"`)
+		}
+	}
+	if len(truncate) == 0 {
+		return nil
+	}
+
+	log.Warn("Truncating %d tables", len(truncate))
+	return db.Exec("TRUNCATE " + strings.Join(truncate, ", ") + " CASCADE").Error
+}
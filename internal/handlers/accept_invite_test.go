@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// createInvite stores a pending invite from inviter to the team with role
+func createInvite(t *testing.T, gdb *gorm.DB, inviter *models.User, email string, role models.UserRole) *models.TeamInvite {
+	t.Helper()
+	invite := &models.TeamInvite{
+		TeamID:    inviter.TeamID,
+		Email:     email,
+		Name:      "Invitee",
+		Role:      role,
+		Code:      "invite-" + email,
+		Status:    models.InviteStatusPending,
+		InviterID: inviter.ID,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+	if err := gdb.Create(invite).Error; err != nil {
+		t.Fatal(err)
+	}
+	return invite
+}
+
+func acceptInviteCall(t *testing.T, h *AuthHandler, code, password string) (int, map[string]interface{}) {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/auth/accept/"+code, AcceptInviteRequest{Password: password})
+	c.SetParamNames("code")
+	c.SetParamValues(code)
+	if err := h.AcceptInvite(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, decode(t, rec)
+}
+
+func TestAcceptInviteRoleNeverExceedsTheInviter(t *testing.T) {
+	tests := []struct {
+		name        string
+		inviterRole models.UserRole
+		inviteRole  models.UserRole
+		userRole    models.UserRole // empty for a new account
+		want        models.UserRole
+	}{
+		{name: "new user", inviterRole: models.UserRoleAdmin, inviteRole: models.UserRoleAdmin, want: models.UserRoleAdmin},
+		{name: "new user above the inviter", inviterRole: models.UserRoleMember, inviteRole: models.UserRoleAdmin, want: models.UserRoleMember},
+		{name: "existing user", inviterRole: models.UserRoleAdmin, inviteRole: models.UserRoleAdmin, userRole: models.UserRoleMember, want: models.UserRoleAdmin},
+		{name: "existing user above the inviter", inviterRole: models.UserRoleMember, inviteRole: models.UserRoleAdmin, userRole: models.UserRoleMember, want: models.UserRoleMember},
+		{name: "existing user joining with a lower role", inviterRole: models.UserRoleAdmin, inviteRole: models.UserRoleMember, userRole: models.UserRoleAdmin, want: models.UserRoleMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.UseJWTSecret(t)
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			inviter := testutil.CreateUser(t, gdb, team.ID, tt.inviterRole)
+
+			email, password := "invitee@example.com", "invitee-password"
+			if tt.userRole != "" {
+				other := testutil.CreateTeam(t, gdb, "Other")
+				user := testutil.CreateUser(t, gdb, other.ID, tt.userRole)
+				email, password = user.Email, "correct-horse-battery"
+			}
+			invite := createInvite(t, gdb, inviter, email, tt.inviteRole)
+
+			if status, body := acceptInviteCall(t, h, invite.Code, password); status != http.StatusOK {
+				t.Fatalf("status %d %v, want 200", status, body)
+			}
+			var user models.User
+			if err := gdb.First(&user, "email = ?", email).Error; err != nil {
+				t.Fatal(err)
+			}
+			if user.TeamID != team.ID || user.Role != tt.want {
+				t.Errorf("team %s role %s, want team %s role %s", user.TeamID, user.Role, team.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptInviteDemotedInviterCanNoLongerRaise(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	invite := createInvite(t, gdb, inviter, member.Email, models.UserRoleAdmin)
+
+	// The inviter lost the admin role after sending the invite
+	gdb.Model(inviter).Update("role", models.UserRoleMember)
+
+	if status, body := acceptInviteCall(t, h, invite.Code, "correct-horse-battery"); status != http.StatusOK {
+		t.Fatalf("status %d %v, want 200", status, body)
+	}
+	if role := reloadUser(t, gdb, member.ID).Role; role != models.UserRoleMember {
+		t.Errorf("role %s, want the member to stay a member", role)
+	}
+}
+
+func TestAcceptInviteRefusesDeletedAccounts(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	other := testutil.CreateTeam(t, gdb, "Other")
+	deleted := testutil.CreateUser(t, gdb, other.ID, models.UserRoleMember)
+	deletedAt := time.Now().UTC()
+	gdb.Model(deleted).Updates(map[string]interface{}{"is_deleted": true, "deleted_at": deletedAt})
+	invite := createInvite(t, gdb, inviter, deleted.Email, models.UserRoleMember)
+
+	if status, body := acceptInviteCall(t, h, invite.Code, "a-brand-new-password"); status != http.StatusForbidden {
+		t.Fatalf("status %d %v, want 403", status, body)
+	}
+
+	reloaded := reloadUser(t, gdb, deleted.ID)
+	if !reloaded.IsDeleted || reloaded.TeamID != other.ID {
+		t.Errorf("deleted %v team %s, want the account left deleted in its team", reloaded.IsDeleted, reloaded.TeamID)
+	}
+	var pending models.TeamInvite
+	gdb.First(&pending, "id = ?", invite.ID)
+	if pending.Status != models.InviteStatusPending {
+		t.Errorf("invite status %s, want it still pending", pending.Status)
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// apiKeyLength is the number of random characters after models.APIKeyPrefix
+const apiKeyLength = 40
+
+// defaultAnalyticsRange is the period of the analytics when from is not given
+const defaultAnalyticsRange = 7 * 24 * time.Hour
+
+type APIKeyHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewAPIKeyHandler(db *gorm.DB) *APIKeyHandler {
+	return &APIKeyHandler{db: db, log: logger.New("APIKeyHandler")}
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" validate:"required,max=100"`
+	Permissions []string   `json:"permissions" validate:"required,min=1,dive,oneof=READ WRITE DELETE ADMIN"`
+	ExpiresAt   *time.Time `json:"expiresAt" validate:"omitempty,gt"`
+}
+
+type UpdateAPIKeyRequest struct {
+	Name        *string  `json:"name" validate:"omitempty,min=1,max=100"`
+	Permissions []string `json:"permissions" validate:"omitempty,min=1,dive,oneof=READ WRITE DELETE ADMIN"`
+}
+
+// CreateAPIKey creates an API key for the current team
+// @Summary Create API key
+// @Description Create an API key for the current team. The raw key is only returned once, send it in the X-API-KEY header.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param request body CreateAPIKeyRequest true "Name, permissions and optional expiry"
+// @Success 201 {object} map[string]interface{} "API key and raw key"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	random, err := utils.GenerateRandomString(apiKeyLength)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate API key"})
+	}
+	key := models.APIKeyPrefix + random
+
+	apiKey := &models.APIKey{
+		TeamID:      teamID,
+		CreatedByID: userID,
+		Name:        req.Name,
+		Prefix:      key[:len(models.APIKeyPrefix)+6],
+		KeyHash:     models.HashAPIKey(key),
+		Permissions: req.Permissions,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		apiKey.ExpiresAt = &expiresAt
+	}
+	if err := h.db.Create(apiKey).Error; err != nil {
+		h.log.Error("Failed to create API key: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create API key"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"apiKey": apiKey,
+		"key":    key,
+	})
+}
+
+// ListAPIKeys lists the current team's API keys
+// @Summary List API keys
+// @Description List the current team's API keys, newest first. Raw keys are never returned.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var apiKeys []models.APIKey
+	if err := h.db.Where("team_id = ? AND is_deleted = false", teamID).
+		Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list API keys"})
+	}
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+// GetAPIKey returns one of the current team's API keys
+// @Summary Get API key
+// @Description Get an API key of the current team, without the raw key
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} map[string]string "API key not found"
+// @Router /api-keys/{id} [get]
+func (h *APIKeyHandler) GetAPIKey(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var apiKey models.APIKey
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).
+		First(&apiKey).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+	return c.JSON(http.StatusOK, apiKey)
+}
+
+// UpdateAPIKey renames an API key or changes its permissions
+// @Summary Update API key
+// @Description Rename an API key of the current team or change its permissions. Other instances pick up new permissions within 30 seconds.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param request body UpdateAPIKeyRequest true "Fields to change"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api-keys/{id} [put]
+func (h *APIKeyHandler) UpdateAPIKey(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var apiKey models.APIKey
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).
+		First(&apiKey).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+
+	if req.Name != nil {
+		apiKey.Name = *req.Name
+	}
+	if req.Permissions != nil {
+		apiKey.Permissions = req.Permissions
+	}
+	if err := h.db.Model(&apiKey).Select("name", "permissions").Updates(&apiKey).Error; err != nil {
+		h.log.Error("Failed to update API key: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update API key"})
+	}
+	middleware.InvalidateAPIKey(apiKey.ID)
+
+	return c.JSON(http.StatusOK, apiKey)
+}
+
+// DeleteAPIKey revokes one of the current team's API keys
+// @Summary Delete API key
+// @Description Revoke an API key of the current team. Other instances reject it within 30 seconds.
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} map[string]string "API key deleted"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) DeleteAPIKey(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	id := c.Param("id")
+	result := h.db.Model(&models.APIKey{}).
+		Where("id = ? AND team_id = ? AND is_deleted = false", id, teamID).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete API key"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+	middleware.InvalidateAPIKey(id)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "API key deleted"})
+}
+
+// APIKeyAnalytics aggregates the requests made with an API key
+type APIKeyAnalytics struct {
+	APIKeyID  string                   `json:"apiKeyId"`
+	From      time.Time                `json:"from"`
+	To        time.Time                `json:"to"`
+	Requests  int64                    `json:"requests"`
+	Errors    int64                    `json:"errors"`
+	ByDay     []APIKeyAnalyticsDay     `json:"byDay"`
+	ByRoute   []APIKeyAnalyticsRoute   `json:"byRoute"`
+	ByStatus  []APIKeyAnalyticsStatus  `json:"byStatus"`
+	ByLatency []APIKeyAnalyticsLatency `json:"byLatency"`
+}
+
+// APIKeyAnalyticsDay counts the requests of a UTC day
+type APIKeyAnalyticsDay struct {
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// APIKeyAnalyticsRoute counts the requests of a route pattern
+type APIKeyAnalyticsRoute struct {
+	Method   string `json:"method"`
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// APIKeyAnalyticsStatus counts the requests answered with a status code
+type APIKeyAnalyticsStatus struct {
+	StatusCode int   `json:"statusCode"`
+	Requests   int64 `json:"requests"`
+}
+
+// APIKeyAnalyticsLatency counts the requests of a latency bucket
+type APIKeyAnalyticsLatency struct {
+	Bucket   string `json:"bucket"`
+	Requests int64  `json:"requests"`
+}
+
+// errorsColumn counts the failed requests of a group
+const errorsColumn = "SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END) AS errors"
+
+// GetAPIKeyAnalytics aggregates the requests made with one of the current team's API keys
+// @Summary Get API key analytics
+// @Description Count the requests made with an API key of the current team by UTC day, route pattern, status code and latency bucket. Revoked keys keep their analytics until the retention period ends. Failed requests are always counted, successful ones as sampled with API_ANALYTICS_SAMPLE_RATE. Bodies are never recorded.
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Param from query string false "Requests made at or after, RFC 3339, 7 days before to by default"
+// @Param to query string false "Requests made before, RFC 3339, now by default"
+// @Param status query string false "Status code, e.g. 404, or class, e.g. 5xx"
+// @Success 200 {object} APIKeyAnalytics
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api-keys/{id}/analytics [get]
+func (h *APIKeyHandler) GetAPIKeyAnalytics(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "from", "to", "status"); err != nil {
+		return err
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var apiKey models.APIKey
+	if err := h.db.Where("id = ? AND team_id = ?", c.Param("id"), teamID).
+		First(&apiKey).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+
+	to := time.Now().UTC()
+	if value := c.QueryParam("to"); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid to, use RFC 3339"})
+		}
+		to = at.UTC()
+	}
+	from := to.Add(-defaultAnalyticsRange)
+	if value := c.QueryParam("from"); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from, use RFC 3339"})
+		}
+		from = at.UTC()
+	}
+	if !from.Before(to) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must be before to"})
+	}
+
+	logs := func() *gorm.DB {
+		return h.db.Model(&models.APIRequestLog{}).
+			Where("api_key_id = ? AND requested_at >= ? AND requested_at < ?", apiKey.ID, from, to)
+	}
+	low, high, ok := parseStatusFilter(c.QueryParam("status"))
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid status, use a code such as 404 or a class such as 5xx"})
+	}
+	if low > 0 {
+		base := logs
+		logs = func() *gorm.DB { return base().Where("status_code BETWEEN ? AND ?", low, high) }
+	}
+
+	analytics := APIKeyAnalytics{
+		APIKeyID:  apiKey.ID,
+		From:      from,
+		To:        to,
+		ByDay:     []APIKeyAnalyticsDay{},
+		ByRoute:   []APIKeyAnalyticsRoute{},
+		ByStatus:  []APIKeyAnalyticsStatus{},
+		ByLatency: []APIKeyAnalyticsLatency{},
+	}
+	var latencies []APIKeyAnalyticsLatency
+	queries := []*gorm.DB{
+		logs().Select("day, COUNT(*) AS requests, " + errorsColumn).Group("day").Order("day").Scan(&analytics.ByDay),
+		logs().Select("method, route, COUNT(*) AS requests, " + errorsColumn).
+			Group("method, route").Order("requests DESC").Order("route").Scan(&analytics.ByRoute),
+		logs().Select("status_code, COUNT(*) AS requests").Group("status_code").Order("status_code").Scan(&analytics.ByStatus),
+		logs().Select("latency_bucket AS bucket, COUNT(*) AS requests").Group("latency_bucket").Scan(&latencies),
+	}
+	for _, query := range queries {
+		if query.Error != nil {
+			h.log.Error("Failed to aggregate API key analytics: %v", query.Error)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch API key analytics"})
+		}
+	}
+
+	for _, day := range analytics.ByDay {
+		analytics.Requests += day.Requests
+		analytics.Errors += day.Errors
+	}
+	// ⏱️ Buckets are listed fastest first, including the empty ones
+	for _, bucket := range models.APIRequestLatencyBuckets {
+		entry := APIKeyAnalyticsLatency{Bucket: bucket.Name}
+		for _, latency := range latencies {
+			if latency.Bucket == bucket.Name {
+				entry.Requests = latency.Requests
+			}
+		}
+		analytics.ByLatency = append(analytics.ByLatency, entry)
+	}
+
+	return c.JSON(http.StatusOK, analytics)
+}
+
+// parseStatusFilter reads a status code such as 404, or a class such as 5xx, as the codes it
+// matches. Both are zero without a filter.
+func parseStatusFilter(value string) (int, int, bool) {
+	if value == "" {
+		return 0, 0, true
+	}
+	if class, ok := strings.CutSuffix(strings.ToLower(value), "xx"); ok {
+		if n, err := strconv.Atoi(class); err == nil && n >= 1 && n <= 5 {
+			return n * 100, n*100 + 99, true
+		}
+	} else if code, err := strconv.Atoi(value); err == nil && code >= 100 && code <= 599 {
+		return code, code, true
+	}
+	return 0, 0, false
+}
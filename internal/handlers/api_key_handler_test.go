@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func createAPIKey(t *testing.T, gdb *gorm.DB, teamID, name string) *models.APIKey {
+	t.Helper()
+	key := &models.APIKey{TeamID: teamID, Name: name, Prefix: "be0_" + name, KeyHash: models.HashAPIKey(name), Permissions: []string{"READ"}}
+	if err := gdb.Create(key).Error; err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestGetAPIKeyAnalytics(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Other")
+	key := createAPIKey(t, gdb, team.ID, "ci")
+	otherKey := createAPIKey(t, gdb, other.ID, "theirs")
+
+	monday := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+	logs := []models.APIRequestLog{
+		{APIKeyID: key.ID, Method: "GET", Route: "/api/v1/files/:id", StatusCode: 200, LatencyBucket: "<50ms", RequestedAt: monday},
+		{APIKeyID: key.ID, Method: "GET", Route: "/api/v1/files/:id", StatusCode: 404, LatencyBucket: "<50ms", RequestedAt: tuesday},
+		{APIKeyID: key.ID, Method: "POST", Route: "/api/v1/files", StatusCode: 500, LatencyBucket: ">=5s", RequestedAt: tuesday},
+		{APIKeyID: key.ID, Method: "POST", Route: "/api/v1/files", StatusCode: 201, LatencyBucket: "<1s", RequestedAt: tuesday.Add(time.Hour)},
+		// Outside the range and of another key
+		{APIKeyID: key.ID, Method: "GET", Route: "/api/v1/files", StatusCode: 500, LatencyBucket: "<50ms", RequestedAt: monday.Add(-48 * time.Hour)},
+		{APIKeyID: otherKey.ID, Method: "GET", Route: "/api/v1/files", StatusCode: 500, LatencyBucket: "<50ms", RequestedAt: tuesday},
+	}
+	for i := range logs {
+		logs[i].TeamID = team.ID
+		logs[i].Day = logs[i].RequestedAt.Format(time.DateOnly)
+	}
+	if err := gdb.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAPIKeyHandler(gdb)
+	get := func(id, query string) (APIKeyAnalytics, int) {
+		c, rec := newContext(t, http.MethodGet, "/api/v1/api-keys/"+id+"/analytics?"+query, nil)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		c.Set("teamID", team.ID)
+		if err := h.GetAPIKeyAnalytics(c); err != nil {
+			t.Fatal(err)
+		}
+		var analytics APIKeyAnalytics
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &analytics); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return analytics, rec.Code
+	}
+
+	analytics, status := get(key.ID, "from=2026-03-01T00:00:00Z&to=2026-03-04T00:00:00Z")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	if analytics.Requests != 4 || analytics.Errors != 2 {
+		t.Errorf("%d requests and %d errors, want 4 and 2", analytics.Requests, analytics.Errors)
+	}
+	wantDays := []APIKeyAnalyticsDay{{"2026-03-02", 1, 0}, {"2026-03-03", 3, 2}}
+	if len(analytics.ByDay) != len(wantDays) || analytics.ByDay[0] != wantDays[0] || analytics.ByDay[1] != wantDays[1] {
+		t.Errorf("by day %+v, want %+v", analytics.ByDay, wantDays)
+	}
+	if len(analytics.ByRoute) != 2 || analytics.ByRoute[0].Requests != 2 || analytics.ByRoute[0].Errors != 1 {
+		t.Errorf("by route %+v, want two routes with 2 requests and 1 error each", analytics.ByRoute)
+	}
+	if len(analytics.ByLatency) != len(models.APIRequestLatencyBuckets) || analytics.ByLatency[0] != (APIKeyAnalyticsLatency{"<50ms", 2}) {
+		t.Errorf("by latency %+v, want every bucket with 2 under 50ms first", analytics.ByLatency)
+	}
+
+	// 🔎 Only the failed calls of the day
+	analytics, _ = get(key.ID, "from=2026-03-03T00:00:00Z&to=2026-03-04T00:00:00Z&status=5xx")
+	if analytics.Requests != 1 || len(analytics.ByStatus) != 1 || analytics.ByStatus[0].StatusCode != 500 {
+		t.Errorf("5xx analytics %+v, want the one 500", analytics)
+	}
+
+	if _, status := get(key.ID, "status=teapot"); status != http.StatusBadRequest {
+		t.Errorf("invalid status answered %d, want 400", status)
+	}
+	if _, status := get(key.ID, "from=2026-03-04T00:00:00Z&to=2026-03-01T00:00:00Z"); status != http.StatusBadRequest {
+		t.Errorf("reversed range answered %d, want 400", status)
+	}
+	if _, status := get(otherKey.ID, ""); status != http.StatusNotFound {
+		t.Errorf("another team's key answered %d, want 404", status)
+	}
+}
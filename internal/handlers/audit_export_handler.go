@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type AuditExportHandler struct {
+	db         *gorm.DB
+	taskClient *tasks.TaskClient
+	log        *logger.Logger
+}
+
+func NewAuditExportHandler(db *gorm.DB, taskClient *tasks.TaskClient) *AuditExportHandler {
+	return &AuditExportHandler{db: db, taskClient: taskClient, log: logger.New("AuditExportHandler")}
+}
+
+// CreateAuditExportRequest names the SIEM endpoint and the team to export, every team without one
+type CreateAuditExportRequest struct {
+	TeamID      string `json:"teamId" validate:"omitempty,uuid"`
+	Target      string `json:"target" validate:"required,oneof=s3 https syslog"`
+	Destination string `json:"destination" validate:"required"`
+}
+
+// CreateAuditExport configures an audit log export
+// @Summary Create audit log export
+// @Description Push the audit logs of a team, or of every team without teamId, to a SIEM every 5 minutes as NDJSON. s3 writes objects under the destination prefix of the storage bucket, https POSTs to the destination URL signed like webhooks with the secret returned once, syslog sends RFC 5424 messages over TLS to the destination host:port. Only logs recorded from now on are exported. Super admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body CreateAuditExportRequest true "Target and destination"
+// @Success 201 {object} map[string]interface{} "Export and, for https, the signing secret"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 404 {object} map[string]string "Team not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/audit-exports [post]
+func (h *AuditExportHandler) CreateAuditExport(c echo.Context) error {
+	var req CreateAuditExportRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// ⏱️ The checkpoint starts now, a new export does not replay the history
+	now := time.Now().UTC()
+	export := &models.AuditExport{
+		Target:           models.AuditExportTarget(req.Target),
+		Destination:      req.Destination,
+		Active:           true,
+		LastLogCreatedAt: &now,
+	}
+	if err := export.ValidateDestination(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.TeamID != "" {
+		if _, err := models.GetTeamByID(req.TeamID, h.db); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+		}
+		export.TeamID = &req.TeamID
+	}
+
+	response := map[string]interface{}{"export": export}
+	if export.Target == models.AuditExportTargetHTTPS {
+		secret, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate secret"})
+		}
+		export.Secret = secret
+		response["secret"] = secret
+	}
+
+	if err := h.db.Create(export).Error; err != nil {
+		h.log.Error("Failed to create audit export", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create audit export"})
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+// ListAuditExports lists the audit log exports with their checkpoints and last errors
+// @Summary List audit log exports
+// @Description List the audit log exports, newest first, with the last delivered log, the last run and its error. Super admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.AuditExport
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/audit-exports [get]
+func (h *AuditExportHandler) ListAuditExports(c echo.Context) error {
+	exports := []models.AuditExport{}
+	if err := h.db.Where("is_deleted = false").Order("created_at DESC").Find(&exports).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list audit exports"})
+	}
+	return c.JSON(http.StatusOK, exports)
+}
+
+// DeleteAuditExport stops an audit log export
+// @Summary Delete audit log export
+// @Description Stop pushing audit logs to an endpoint. Super admin only.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} map[string]string "Export deleted"
+// @Failure 404 {object} map[string]string "Export not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/audit-exports/{id} [delete]
+func (h *AuditExportHandler) DeleteAuditExport(c echo.Context) error {
+	result := h.db.Model(&models.AuditExport{}).
+		Where("id = ? AND is_deleted = false", c.Param("id")).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC(), "active": false})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete audit export"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Export not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Export deleted"})
+}
+
+// RunAuditExports pushes new audit logs now instead of waiting for the schedule
+// @Summary Run audit log exports
+// @Description Queue a run of every active audit log export. A run already queued or in progress is not queued twice. Super admin only.
+// @Tags admin
+// @Produce json
+// @Success 202 {object} map[string]string "Run queued"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/audit-export/run [post]
+func (h *AuditExportHandler) RunAuditExports(c echo.Context) error {
+	if err := h.taskClient.EnqueueAuditExport(c.Request().Context()); err != nil {
+		h.log.Error("Failed to queue audit export", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to queue audit export"})
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Audit export queued"})
+}
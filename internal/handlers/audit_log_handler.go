@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxAuditLogsPageSize caps the audit logs of one page
+const maxAuditLogsPageSize = 100
+
+// auditLogFilters maps the query parameters filtering audit logs to their columns
+var auditLogFilters = map[string]string{
+	"userId":     "user_id",
+	"apiKeyId":   "api_key_id",
+	"resource":   "resource",
+	"resourceId": "resource_id",
+	"method":     "method",
+	"statusCode": "status_code",
+}
+
+// auditLogParams are the other query parameters of ListAuditLogs
+var auditLogParams = map[string]bool{"page": true, "limit": true, "order": true, "from": true, "to": true, "teamId": true}
+
+type AuditLogHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewAuditLogHandler(db *gorm.DB) *AuditLogHandler {
+	return &AuditLogHandler{db: db, log: logger.New("AuditLogHandler")}
+}
+
+// ListAuditLogs lists the mutating requests made in the caller's team
+// @Summary List audit logs
+// @Description List the POST, PUT, PATCH and DELETE requests made in the caller's team, newest first, in the data/total/page/limit envelope of the CRUD lists with at most 100 logs per page. Filter on userId, apiKeyId, resource, resourceId, method and statusCode, and on the time of the request with from and to. Passwords, tokens and secrets are redacted from stored bodies. Admins only, super admins may pass teamId to list another team.
+// @Tags audit
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size, at most 100"
+// @Param order query string false "asc or desc, desc by default"
+// @Param from query string false "Requests made at or after, RFC 3339"
+// @Param to query string false "Requests made before, RFC 3339"
+// @Param userId query string false "User who made the requests"
+// @Param resource query string false "Resource, e.g. files"
+// @Param teamId query string false "Team to list, super admins only"
+// @Success 200 {object} map[string]interface{} "data, total, page and limit"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 403 {object} map[string]string "Admin access required"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/audit-logs [get]
+func (h *AuditLogHandler) ListAuditLogs(c echo.Context) error {
+	if middleware.StrictParams(c) {
+		known := make([]string, 0, len(auditLogFilters)+len(auditLogParams))
+		for param := range auditLogFilters {
+			known = append(known, param)
+		}
+		for param := range auditLogParams {
+			known = append(known, param)
+		}
+		if err := middleware.CheckQueryParams(c, known...); err != nil {
+			return err
+		}
+	}
+
+	// 🔒 Admins see their team's logs
+	role := models.UserRole(middleware.GetUserRole(c))
+	if role.Rank() < models.UserRoleAdmin.Rank() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Admin access required"})
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > maxAuditLogsPageSize {
+		limit = maxAuditLogsPageSize
+	}
+
+	// 🏢 Only super admins may list another team
+	teamID := middleware.GetTeamID(c)
+	if requested := c.QueryParam("teamId"); requested != "" && requested != teamID {
+		if role != models.UserRoleSuperAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Cannot list audit logs of another team"})
+		}
+		if _, err := uuid.Parse(requested); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid teamId"})
+		}
+		teamID = requested
+	}
+
+	query := h.db.Model(&models.AuditLog{}).Where("team_id = ? AND is_deleted = false", teamID)
+
+	// 🔎 Field filters use the JSON names of the logs
+	for key, values := range c.QueryParams() {
+		if auditLogParams[key] || len(values) == 0 {
+			continue
+		}
+		column, ok := auditLogFilters[key]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown filter " + key})
+		}
+		if (key == "userId" || key == "apiKeyId") && uuid.Validate(values[0]) != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + key})
+		}
+		query = query.Where(column+" = ?", values[0])
+	}
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + param + ", use RFC 3339"})
+		}
+		query = query.Where(condition, at.UTC())
+	}
+
+	order := "DESC"
+	switch c.QueryParam("order") {
+	case "", "desc":
+	case "asc":
+		order = "ASC"
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "order must be asc or desc"})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.log.Error("Failed to count audit logs: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch audit logs"})
+	}
+
+	logs := []models.AuditLog{}
+	if err := query.Order("created_at " + order).Order("id " + order).
+		Offset((page - 1) * limit).Limit(limit).Find(&logs).Error; err != nil {
+		h.log.Error("Failed to list audit logs: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch audit logs"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  logs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -1,36 +1,59 @@
 package handlers
 
 import (
-	"encoding/base64"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"be0/internal/api/middleware"
+	"be0/internal/captcha"
+	"be0/internal/config"
 	"be0/internal/events"
+	"be0/internal/lockout"
+	"be0/internal/mailer"
 	"be0/internal/models"
+	"be0/internal/redact"
+	"be0/internal/services"
 	"be0/internal/utils"
 	"be0/internal/utils/logger"
 
-	"crypto/rand"
-
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthHandler struct {
-	db  *gorm.DB
-	log *logger.Logger
+	db                  *gorm.DB
+	log                 *logger.Logger
+	maxSessions         int
+	requireVerification bool
+	limiter             *lockout.Limiter
+	resetLimiter        *lockout.Limiter
+	microsoftTenants    []string
+	captcha             *captcha.Guard
+	maxTeamMembers      int
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{db: db, log: logger.New("AuthHandler")}
+func NewAuthHandler(db *gorm.DB, cfg config.JWTConfig, authCfg config.AuthConfig, limiter, resetLimiter *lockout.Limiter, captchaGuard *captcha.Guard) *AuthHandler {
+	return &AuthHandler{
+		db:                  db,
+		log:                 logger.New("AuthHandler"),
+		maxSessions:         cfg.MaxSessions,
+		requireVerification: cfg.RequireEmailVerification,
+		limiter:             limiter,
+		resetLimiter:        resetLimiter,
+		microsoftTenants:    authCfg.MicrosoftTenants,
+		captcha:             captchaGuard,
+		maxTeamMembers:      authCfg.MaxTeamMembers,
+	}
 }
 
 type RegisterRequest struct {
@@ -38,6 +61,12 @@ type RegisterRequest struct {
 	Password  string `json:"password" validate:"required,min=8"`
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
+	// CaptchaToken is the solved challenge, required when captchas are enabled for registration
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 type LoginRequest struct {
@@ -47,6 +76,8 @@ type LoginRequest struct {
 
 type ResetPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
+	// CaptchaToken is the solved challenge, required when captchas are enabled for password resets
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type VerifyResetCodeRequest struct {
@@ -54,6 +85,14 @@ type VerifyResetCodeRequest struct {
 	Password string `json:"new_password" validate:"required,min=8"`
 }
 
+type VerifyEmailRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type GoogleAuthRequest struct {
 	AccessToken string `json:"access_token" validate:"required"`
 }
@@ -79,22 +118,31 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// 🤖 Bots are turned away before any lookups
+	if err := h.captcha.Check(c.Request().Context(), captcha.EndpointRegister, req.CaptchaToken, c.RealIP()); err != nil {
+		return captchaFailed(c, err)
+	}
+
 	var createTeam bool = true
 	var team models.Team
 	var user models.User
 
 	// check if user already exists
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	err := h.db.Where("email = ?", req.Email).First(&user).Error
+	switch {
+	case err == nil:
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "User already exists"})
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check existing user"})
 	}
 
-	// check if user is already invited
-	var invite models.TeamInvite
-	if err := h.db.Where("email = ? AND status = ? AND expires_at > ?", req.Email, models.InviteStatusPending, time.Now()).First(&invite).Error; err != nil {
-		// accept invite
-		invite.Status = models.InviteStatusAccepted
-		h.db.Save(&invite)
+	// check if user is already invited, the most recent invite wins
+	invite, err := models.GetLatestPendingInvite(req.Email, h.db)
+	switch {
+	case err == nil:
 		createTeam = false
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check pending invites"})
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -109,16 +157,12 @@ func (h *AuthHandler) Register(c echo.Context) error {
 	}
 
 	if createTeam {
-		// create a team
-		team = models.Team{
-			Name: req.FirstName + "'s Team", // Example team name based on the user's first name
-		}
-
-		if err = tx.Create(&team).Error; err != nil {
+		created, err := services.CreateTeamForUser(tx, req.FirstName)
+		if err != nil {
 			tx.Rollback()
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create team"})
 		}
-
+		team = *created
 	}
 
 	user = models.User{
@@ -130,7 +174,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		TeamID:    team.ID,
 	}
 
-	if invite.Status == models.InviteStatusAccepted {
+	if invite != nil {
 		user.Role = invite.Role
 		user.TeamID = invite.TeamID
 	}
@@ -140,18 +184,33 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email already exists"})
 	}
 
+	if invite != nil {
+		if err := acceptInvite(tx, invite); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update invitation"})
+		}
+	}
+
 	// Assign default permissions based on role
 	if err := models.AssignDefaultPermissions(tx, &user); err != nil {
 		tx.Rollback()
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign permissions"})
 	}
 
+	// ✉️ Ask the user to prove they own the email address
+	verification, err := createEmailVerification(tx, &user)
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create verification code"})
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
 	}
 
 	events.Emit("users.created", &user)
+	events.Emit(events.EventVerificationRequested, verification)
 
 	return c.JSON(http.StatusCreated, map[string]string{"message": "User registered successfully"})
 }
@@ -163,9 +222,11 @@ func (h *AuthHandler) Register(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login credentials"
-// @Success 200 {object} map[string]string "JWT token"
+// @Success 200 {object} map[string]string "JWT token, or a challenge for POST /auth/2fa/verify when two-factor authentication is enabled"
 // @Failure 400 {object} map[string]string "Validation error"
 // @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Account deactivated, email not verified or sign-in method not allowed"
+// @Failure 429 {object} map[string]string "Too many failed attempts, retry after the Retry-After header"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c echo.Context) error {
@@ -178,38 +239,295 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// 🚧 Locked out email and IP pairs are rejected before the password is checked
+	ip := utils.GetIPAddress(c.Request())
+	if retryAfter, err := h.limiter.Locked(c.Request().Context(), req.Email, ip); err != nil {
+		h.log.FromContext(c.Request().Context()).Warn("Failed to check login lockout: %v", err)
+	} else if retryAfter > 0 {
+		return tooManyLoginAttempts(c, retryAfter)
+	}
+
 	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
+	if err := h.db.Where("email = ? AND is_deleted = false", req.Email).First(&user).Error; err != nil {
+		// ⏱️ Spend the same bcrypt work as for a known email
+		checkPassword("", req.Password)
+		return h.loginFailed(c, req.Email, ip, nil)
+	}
+
+	if err := checkPassword(user.Password, req.Password); err != nil {
+		events.Emit(events.EventLoginFailed, events.LoginFailed{
+			UserID:     user.ID,
+			TeamID:     user.TeamID,
+			Email:      user.Email,
+			Reason:     "invalid_password",
+			IPAddress:  ip,
+			UserAgent:  c.Request().UserAgent(),
+			OccurredAt: time.Now().UTC(),
+		})
+		return h.loginFailed(c, req.Email, ip, &user)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	// 🔓 The right password clears earlier failures
+	if err := h.limiter.Reset(c.Request().Context(), req.Email, ip); err != nil {
+		h.log.FromContext(c.Request().Context()).Warn("Failed to reset login failures: %v", err)
+	}
+
+	// 🏢 Members of a deleted team can no longer sign in, their accounts are deactivated with it
+	team, err := models.GetTeamByID(user.TeamID, h.db)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
 	}
 
-	token, err := utils.GenerateJWT(user)
+	// ⛔ Deactivated accounts are refused before the second factor is asked for
+	if !user.IsActive {
+		return accountDeactivated(c)
+	}
+
+	// 🔐 The team may require another sign-in method
+	if !team.AuthPolicy.Allows(models.AuthMethodPassword) {
+		return authMethodNotAllowed(c, team)
+	}
+
+	// ✉️ Unverified addresses cannot sign in when verification is required
+	if h.requireVerification && !user.EmailVerified {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Please verify your email address before signing in",
+			"code":  "email_not_verified",
+		})
+	}
+
+	// 🔢 The token pair is only issued once the second factor is checked
+	return h.signIn(c, user)
+}
+
+// errTwoFactorEnrollmentRequired refuses a session to a user without 2FA in a team requiring it
+var errTwoFactorEnrollmentRequired = errors.New("two-factor authentication required")
+
+// errAccountDeactivated refuses a session to a user a super admin deactivated
+var errAccountDeactivated = errors.New("account deactivated")
+
+// issueSession signs the token pair for a fully authenticated user and records the session
+func (h *AuthHandler) issueSession(c echo.Context, user models.User) error {
+	tokens, err := h.newSession(c, user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return h.sessionError(c, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// sessionError answers a failure of newSession
+func (h *AuthHandler) sessionError(c echo.Context, err error) error {
+	if errors.Is(err, errTwoFactorEnrollmentRequired) {
+		return twoFactorEnrollmentRequired(c)
+	}
+	if errors.Is(err, errAccountDeactivated) {
+		return accountDeactivated(c)
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return c.JSON(httpErr.Code, map[string]string{"error": httpErr.Message.(string)})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+}
+
+// newSession signs the token pair for a fully authenticated user and records the session.
+// Callers check the second factor of users with 2FA, users without it are refused when their team requires it.
+func (h *AuthHandler) newSession(c echo.Context, user models.User) (map[string]string, error) {
+	errToken := echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+
+	// 🏢 Whichever way the user signed in, members of a deleted team get no session
+	if _, err := models.GetTeamByID(user.TeamID, h.db); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Team not found")
+		}
+		h.log.FromContext(c.Request().Context()).Error("Failed to load team: %v", err)
+		return nil, errToken
 	}
-	refreshToken, err := utils.GenerateRefreshToken(user)
+
+	// ⛔ Whichever way the user signed in, deactivated accounts get no session
+	if !user.IsActive {
+		return nil, errAccountDeactivated
+	}
+
+	// 🔢 Teams requiring 2FA only get sessions for members who have it
+	if !user.TwoFactorEnabled {
+		required, err := models.TeamRequiresTwoFactor(h.db, user.TeamID)
+		if err != nil {
+			h.log.FromContext(c.Request().Context()).Error("Failed to load team auth policy: %v", err)
+			return nil, errToken
+		}
+		if required {
+			return nil, errTwoFactorEnrollmentRequired
+		}
+	}
+
+	// 🔑 The access token carries the user's scopes
+	if err := models.LoadScopes(h.db, &user); err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to load permissions: %v", err)
+		return nil, errToken
+	}
+
+	// 🏢 Teams of an organization name it in the token
+	orgID, err := models.TeamOrganizationID(h.db, user.TeamID)
+	if err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to load team organization: %v", err)
+		return nil, errToken
+	}
+
+	token, tokenID, err := utils.GenerateJWT(user, orgID)
+	if err != nil {
+		h.log.FromContext(c.Request().Context()).WithFields(logger.Fields{"user_id": user.ID}).Error("Failed to generate access token: %v", err)
+		return nil, errToken
+	}
+	// 🔁 The refresh token names its session, which only keeps the token's hash
+	sessionID := uuid.New().String()
+	refreshToken, err := utils.GenerateRefreshToken(user, sessionID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+		return nil, errToken
 	}
 
 	authtransaction := &models.AuthTransaction{
-		UserID: user.ID,
-		TeamID: user.TeamID,
-		Token:  token,
+		Base:      models.Base{ID: sessionID},
+		UserID:    user.ID,
+		TeamID:    user.TeamID,
+		TokenID:   tokenID,
+		Refresh:   models.HashRefreshToken(refreshToken),
+		IPAddress: c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		DeviceID:  models.DeviceID(c.Request().Header.Get(middleware.DeviceIDHeader), c.Request().UserAgent(), c.RealIP()),
 	}
 
-	if err := h.db.Create(authtransaction).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create auth transaction"})
+	// 🔔 Alert the team when a known user signs in from a device we haven't seen
+	h.checkNewDevice(c, &user, authtransaction)
+
+	// 🎫 Older sessions beyond the per-user cap are revoked
+	if err := models.CreateSession(h.db, authtransaction, h.maxSessions); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create auth transaction")
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"token": token, "refresh_token": refreshToken})
+	return map[string]string{"token": token, "refresh_token": refreshToken}, nil
+}
+
+// authMethodNotAllowed tells the client which sign-in methods the team accepts instead
+func authMethodNotAllowed(c echo.Context, team *models.Team) error {
+	return c.JSON(http.StatusForbidden, map[string]interface{}{
+		"error":          "This sign-in method is not allowed for your team",
+		"code":           "auth_method_not_allowed",
+		"allowedMethods": team.AuthPolicy.AllowedMethods,
+	})
+}
+
+// userRequired answers requests without a signed-in user, such as API key requests, on routes
+// that act for a user
+func userRequired(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"error": "This route needs a signed-in user",
+		"code":  "user_required",
+	})
+}
+
+// teamRequired answers requests that reached a team route without a signed-in user or API key
+func teamRequired(c echo.Context) error {
+	return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Sign in or use an API key"})
+}
+
+// accountDeactivated refuses a user whose account a super admin deactivated
+func accountDeactivated(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"error": "This account has been deactivated",
+		"code":  "account_deactivated",
+	})
+}
+
+// captchaFailed rejects a request whose captcha was missing, rejected or could not be verified
+func captchaFailed(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]string{
+		"error": err.Error(),
+		"code":  "captcha_failed",
+	})
+}
+
+// checkNewDevice emits a security alert and emails the user when they have signed in before but
+// never on this device. Sessions from before device IDs were recorded match on their user agent.
+func (h *AuthHandler) checkNewDevice(c echo.Context, user *models.User, transaction *models.AuthTransaction) {
+	var previous, sameDevice int64
+	if err := h.db.Model(&models.AuthTransaction{}).Where("user_id = ?", user.ID).Count(&previous).Error; err != nil || previous == 0 {
+		return
+	}
+	if err := h.db.Model(&models.AuthTransaction{}).
+		Where("user_id = ? AND (device_id = ? OR (device_id = '' AND user_agent = ?))", user.ID, transaction.DeviceID, transaction.UserAgent).
+		Count(&sameDevice).Error; err != nil || sameDevice > 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	events.Emit(events.EventSecurityAlert, events.SecurityAlert{
+		Alert:      events.AlertNewDevice,
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		IPAddress:  transaction.IPAddress,
+		UserAgent:  transaction.UserAgent,
+		OccurredAt: now,
+	})
+
+	login := events.NewDeviceLogin{
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		DeviceID:   transaction.DeviceID,
+		IPAddress:  transaction.IPAddress,
+		UserAgent:  transaction.UserAgent,
+		OccurredAt: now,
+	}
+	// 🌍 The GeoIP lookup wins, the edge's country header fills in when it has no answer
+	if geo, err := utils.GetGeolocationData(transaction.IPAddress); err == nil {
+		login.Country, login.Region, login.City = knownPlace(geo.Country), knownPlace(geo.Region), knownPlace(geo.City)
+	}
+	if login.Country == "" {
+		login.Country = middleware.EdgeCountry(c)
+	}
+	events.Emit(events.EventNewDeviceLogin, login)
+
+	// ✉️ Sign-in must not wait on the mail server
+	go h.emailNewDevice(h.log.FromContext(c.Request().Context()), user.Email, login)
+}
+
+// knownPlace drops the placeholder the geolocation lookup returns for unknown places
+func knownPlace(name string) string {
+	if name == "Unknown" {
+		return ""
+	}
+	return name
+}
+
+// emailNewDevice tells the user about a sign-in on a new device
+func (h *AuthHandler) emailNewDevice(log *logger.Logger, email string, login events.NewDeviceLogin) {
+	var place []string
+	for _, part := range []string{login.City, login.Region, login.Country} {
+		if part != "" {
+			place = append(place, part)
+		}
+	}
+	location := "an unknown location"
+	if len(place) > 0 {
+		location = strings.Join(place, ", ")
+	}
+
+	text := fmt.Sprintf("Your account signed in on a new device at %s UTC.\n\nDevice: %s\nIP address: %s\nLocation: %s\n\n"+
+		"If this was not you, sign the device out under your sessions and change your password.",
+		login.OccurredAt.Format("2006-01-02 15:04"), login.UserAgent, login.IPAddress, location)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := mailer.Send(ctx, h.db, mailer.Message{TeamID: login.TeamID, To: email, Subject: "New sign-in to your account", Text: text})
+	if err != nil && !errors.Is(err, mailer.ErrNoSender) {
+		log.Warn("Failed to email %s about a new device: %v", email, err)
+	}
 }
 
 // RequestPasswordReset handles the request to reset a user's password by generating a reset code, storing it, and sending an email.
+// A new code supersedes earlier ones, and requests per email are capped per hour whether or not the account exists.
 // @Summary Request password reset
 // @Description Request a password reset code to be sent via email
 // @Tags auth
@@ -218,61 +536,73 @@ func (h *AuthHandler) Login(c echo.Context) error {
 // @Param request body ResetPasswordRequest true "Email for password reset"
 // @Success 200 {object} map[string]string "Reset code sent if email exists"
 // @Failure 400 {object} map[string]string "Validation error"
+// @Failure 429 {object} map[string]string "Too many reset requests for this email"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/password-reset [post]
 func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
-	tx := h.db.Begin()
-	if tx.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
-	}
-
 	var req ResetPasswordRequest
 	if err := c.Bind(&req); err != nil {
-		tx.Rollback()
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	if err := c.Validate(req); err != nil {
-		tx.Rollback()
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// 🤖 Checked before the limiter, so bots cannot use up a user's resets
+	if err := h.captcha.Check(c.Request().Context(), captcha.EndpointPasswordReset, req.CaptchaToken, c.RealIP()); err != nil {
+		return captchaFailed(c, err)
+	}
+
+	response := map[string]string{"message": "If the email exists, a reset code will be sent"}
+
+	// ⏳ Counted per email before the lookup, so unknown addresses hit the same cap
+	if retryAfter, err := h.resetLimiter.Allow(c.Request().Context(), req.Email, ""); err != nil {
+		h.log.FromContext(c.Request().Context()).Warn("Failed to count password reset request: %v", err)
+	} else if retryAfter > 0 {
+		setRetryAfter(c, retryAfter)
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many password reset requests, try again later"})
+	}
+
+	// ⏱️ Known and unknown emails spend the same bcrypt work, so the response time does not leak account existence
+	checkPassword("", req.Email)
+
 	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusOK, map[string]string{"message": "If the email exists, a reset code will be sent"})
+	if err := h.db.Where("email = ? AND is_deleted = false", req.Email).First(&user).Error; err != nil {
+		_, _ = generateResetCode(resetCodeLength)
+		return c.JSON(http.StatusOK, response)
 	}
 
-	code, err := generateResetCode(10)
+	code, err := generateResetCode(resetCodeLength)
 	if err != nil {
-		tx.Rollback()
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate reset code"})
 	}
 
 	reset := models.PasswordReset{
 		UserID:    user.ID,
 		Code:      code,
-		ExpiresAt: time.Now().Add(15 * time.Minute),
+		CodeHash:  models.HashResetCode(code),
+		ExpiresAt: time.Now().UTC().Add(models.PasswordResetLifetime),
+		IPAddress: utils.GetIPAddress(c.Request()),
+		UserAgent: c.Request().UserAgent(),
 	}
 
-	if err := tx.Create(&reset).Error; err != nil {
-		tx.Rollback()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return models.IssuePasswordReset(tx, &reset)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create reset code"})
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
-	}
-
 	reset.User = &user
 
 	events.Emit("password.reset", &reset)
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "If the email exists, a reset code will be sent"})
+	return c.JSON(http.StatusOK, response)
 }
 
 // VerifyResetCode handles the verification of a reset code, updating the user's password, and marking the reset code as used.
+// Codes are looked up by hash, and all of the user's sessions are revoked once the password changes.
 // @Summary Verify reset code and set new password
 // @Description Verify password reset code and update password
 // @Tags auth
@@ -293,132 +623,392 @@ func (h *AuthHandler) VerifyResetCode(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	var reset models.PasswordReset
-	if err := h.db.Where("code = ? AND used = ? AND expires_at > ?",
-		req.Code, false, time.Now()).First(&reset).Error; err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired reset code"})
-	}
-
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
 	}
 
-	var user models.User
-	if err := h.db.Where("id = ?", reset.UserID).First(&user).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get user"})
-	}
+	errInvalidCode := echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset code")
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordReset
+		if err := tx.Where("code_hash = ? AND used = ? AND superseded = ? AND expires_at > ? AND is_deleted = false",
+			models.HashResetCode(req.Code), false, false, time.Now().UTC()).First(&reset).Error; err != nil {
+			return errInvalidCode
+		}
 
-	h.db.Model(&user).Update("password", string(hashedPassword))
-	h.db.Model(&reset).Update("used", true)
+		// 🎟️ Single use, a concurrent request with the same code loses here
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used = ?", reset.ID, false).
+			Update("used", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidCode
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND is_deleted = false", reset.UserID).
+			Update("password", string(hashedPassword))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidCode
+		}
+
+		// 🚪 Whoever knew the old password is signed out everywhere
+		_, err := models.RevokeUserSessions(tx, reset.UserID)
+		return err
+	})
+	if err != nil {
+		if err == errInvalidCode {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired reset code"})
+		}
+		h.log.FromContext(c.Request().Context()).Error("Failed to reset password: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Password reset successfully"})
 }
 
-// GenerateResetCode generates a cryptographically secure random code
-// without special characters, using crypto/rand
-func generateResetCode(length int) (string, error) {
-	// Generate random bytes (we need more than length because
-	// of the base64 encoding and replacement of special chars)
-	buffer := make([]byte, length*2)
-	_, err := rand.Read(buffer)
+// VerifyEmail marks the user's email address as verified
+// @Summary Verify email address
+// @Description Verify an email address with the code sent after registration
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "Verification code"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} map[string]string "Invalid or expired verification code"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	errInvalidCode := echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification code")
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var verification models.EmailVerification
+		codeHash := models.HashResetCode(req.Code)
+		if err := tx.Where("code_hash = ? AND used = ? AND expires_at > ? AND is_deleted = false",
+			codeHash, false, now).First(&verification).Error; err != nil {
+			return errInvalidCode
+		}
+		// 🔐 The database match is not constant time, so the hash is compared again
+		if subtle.ConstantTimeCompare([]byte(verification.CodeHash), []byte(codeHash)) != 1 {
+			return errInvalidCode
+		}
+
+		// 🎟️ Single use, a concurrent request with the same code loses here
+		result := tx.Model(&models.EmailVerification{}).
+			Where("id = ? AND used = ?", verification.ID, false).
+			Update("used", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidCode
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND is_deleted = false", verification.UserID).
+			Updates(map[string]interface{}{"email_verified": true, "email_verified_at": now}).Error
+	})
 	if err != nil {
-		return "", err
+		if err == errInvalidCode {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired verification code"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Email verified successfully"})
+}
+
+const (
+	// verificationResendInterval is the minimum time between two verification codes for a user
+	verificationResendInterval = time.Minute
+	// verificationResendLimit is how many verification codes a user can request per hour
+	verificationResendLimit = 5
+)
+
+// ResendVerification sends a new verification code, at most once a minute and five times an hour
+// @Summary Resend email verification code
+// @Description Send a new verification code. Older codes stop working. The response is the same whether or not the email exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Email to verify"
+// @Success 200 {object} map[string]string "Verification code sent if needed"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 429 {object} map[string]string "Too many verification requests"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification(c echo.Context) error {
+	var req ResendVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Convert to base64 string
-	encoded := base64.StdEncoding.EncodeToString(buffer)
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	response := map[string]string{"message": "If the email needs verifying, a new code will be sent"}
 
-	// Remove non-alphanumeric characters
-	result := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			return r
+	var user models.User
+	if err := h.db.Where("email = ? AND is_deleted = false", req.Email).First(&user).Error; err != nil || user.EmailVerified {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	// ⏳ Rate limit per user
+	now := time.Now().UTC()
+	var recent []models.EmailVerification
+	if err := h.db.Where("user_id = ? AND created_at > ?", user.ID, now.Add(-time.Hour)).
+		Order("created_at DESC").Find(&recent).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check verification codes"})
+	}
+	if len(recent) >= verificationResendLimit ||
+		(len(recent) > 0 && now.Sub(recent[0].CreatedAt.Time) < verificationResendInterval) {
+		c.Response().Header().Set("Retry-After", fmt.Sprint(int(verificationResendInterval.Seconds())))
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many verification requests, try again later"})
+	}
+
+	var verification *models.EmailVerification
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Only the newest code works
+		if err := tx.Model(&models.EmailVerification{}).
+			Where("user_id = ? AND used = ?", user.ID, false).
+			Update("used", true).Error; err != nil {
+			return err
 		}
-		return -1 // Will be removed
-	}, encoded)
 
-	// Trim to desired length
-	if len(result) > length {
-		result = result[:length]
+		var err error
+		verification, err = createEmailVerification(tx, &user)
+		return err
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create verification code"})
 	}
 
-	return result, nil
+	events.Emit(events.EventVerificationRequested, verification)
+
+	return c.JSON(http.StatusOK, response)
 }
 
-// ListUsers returns a list of all users (admin only)
-// @Summary List all users
-// @Description Get a list of all users (requires admin permissions)
+// createEmailVerification stores the hash of a new verification code for user, the plaintext is only kept on the returned value for the mailer
+func createEmailVerification(tx *gorm.DB, user *models.User) (*models.EmailVerification, error) {
+	code, err := generateResetCode(resetCodeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &models.EmailVerification{
+		UserID:    user.ID,
+		Code:      code,
+		CodeHash:  models.HashResetCode(code),
+		ExpiresAt: time.Now().UTC().Add(models.EmailVerificationLifetime),
+	}
+	if err := tx.Create(verification).Error; err != nil {
+		return nil, err
+	}
+
+	verification.User = user
+	return verification, nil
+}
+
+// dummyPasswordHash is compared against when there is no real hash to check,
+// so that unknown emails and password-less accounts cost the same bcrypt work as known ones
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("be0-dummy-password"), bcrypt.DefaultCost)
+
+// comparePassword is the bcrypt comparison used by checkPassword, kept as a variable so it can be swapped out
+var comparePassword = bcrypt.CompareHashAndPassword
+
+// checkPassword compares a password against a bcrypt hash in roughly constant time.
+// An empty hash always fails, but only after a comparison against dummyPasswordHash.
+func checkPassword(hash, password string) error {
+	if hash == "" {
+		_ = comparePassword(dummyPasswordHash, []byte(password))
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return comparePassword([]byte(hash), []byte(password))
+}
+
+// resetCodeLength is the length of password reset codes
+const resetCodeLength = models.PasswordResetCodeLength
+
+// generateResetCode generates a cryptographically secure alphanumeric code of exactly
+// the requested length, without special characters
+func generateResetCode(length int) (string, error) {
+	return utils.GenerateRandomString(length)
+}
+
+// maxUsersPageSize caps the limit of ListUsers
+const maxUsersPageSize = 100
+
+// ListUsers returns a page of the current team's users
+// @Summary List users
+// @Description Get a page of the current team's users, requires users:read. search matches email, first and last name. Super admins may pass teamId to list another team.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.User
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size, at most 100"
+// @Param search query string false "Search email, first and last name"
+// @Param teamId query string false "Team to list, super admins only"
+// @Success 200 {object} map[string]interface{} "data, total, page and limit"
+// @Failure 400 {object} map[string]string "Invalid teamId"
 // @Failure 403 {object} map[string]string "Forbidden"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/users [get]
+// @Router /users [get]
 func (h *AuthHandler) ListUsers(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "page", "limit", "search", "teamId"); err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > maxUsersPageSize {
+		limit = maxUsersPageSize
+	}
+
+	// 🏢 Only super admins may list another team
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+	if requested := c.QueryParam("teamId"); requested != "" && requested != teamID {
+		if middleware.GetUserRole(c) != string(models.UserRoleSuperAdmin) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Cannot list users of another team"})
+		}
+		if _, err := uuid.Parse(requested); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid teamId"})
+		}
+		teamID = requested
+	}
+
+	query := h.db.Model(&models.User{}).Where("users.team_id = ? AND users.is_deleted = false", teamID)
+	if search := strings.TrimSpace(c.QueryParam("search")); search != "" {
+		query = query.Scopes(models.User{}.SearchScope(search))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to count users: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch users"})
+	}
+
 	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
+	if err := query.Preload("ProfilePicture").Order("users.created_at ASC").
+		Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to list users: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch users"})
+	}
+
+	data, err := redact.Value(models.UserResponses(users), redact.ForCaller(c))
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch users"})
 	}
-	return c.JSON(http.StatusOK, users)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  data,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// findManagedUser loads the user named by the id path parameter. Callers other than super
+// admins only find members of their own team.
+func (h *AuthHandler) findManagedUser(c echo.Context, query *gorm.DB) (*models.User, error) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	query = query.Where("id = ? AND is_deleted = false", id)
+	if middleware.GetUserRole(c) != string(models.UserRoleSuperAdmin) {
+		teamID := middleware.GetTeamID(c)
+		if teamID == "" {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Sign in or use an API key")
+		}
+		query = query.Where("team_id = ?", teamID)
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		h.log.FromContext(c.Request().Context()).Error("Failed to fetch user: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch user")
+	}
+	return &user, nil
 }
 
-// GetUser returns details of a specific user (admin only)
+// GetUser returns details of a specific user
 // @Summary Get user details
-// @Description Get details of a specific user (requires admin permissions)
+// @Description Get a user of the current team, requires users:read. Super admins can get users of any team.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID"
-// @Success 200 {object} models.User
+// @Success 200 {object} models.UserResponse
+// @Failure 400 {object} map[string]string "Invalid user ID"
 // @Failure 403 {object} map[string]string "Forbidden"
 // @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/users/{id} [get]
+// @Router /users/{id} [get]
 func (h *AuthHandler) GetUser(c echo.Context) error {
-	id := c.Param("id")
-	var user models.User
-	if err := h.db.First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	user, err := h.findManagedUser(c, h.db.Preload("ProfilePicture"))
+	if err != nil {
+		return err
 	}
-	return c.JSON(http.StatusOK, user)
+	return redact.JSON(c, http.StatusOK, user.Response())
 }
 
-// UpdateUser updates a user's details (admin only)
+// UpdateUserRequest changes a user's name and role
+type UpdateUserRequest struct {
+	FirstName string          `json:"first_name"`
+	LastName  string          `json:"last_name"`
+	Role      models.UserRole `json:"role"`
+}
+
+// UpdateUser updates a user's details
 // @Summary Update user details
-// @Description Update details of a specific user (requires admin permissions)
+// @Description Update a user of the current team, requires users:update. Callers cannot change their own role, grant a role above their own or edit users who outrank them.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID"
-// @Param user body models.User true "Updated user details"
-// @Success 200 {object} models.User
+// @Param user body UpdateUserRequest true "Updated user details"
+// @Success 200 {object} models.UserResponse
 // @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 403 {object} map[string]string "Forbidden"
 // @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/users/{id} [put]
+// @Router /users/{id} [put]
 func (h *AuthHandler) UpdateUser(c echo.Context) error {
-	id := c.Param("id")
-	var user models.User
-	if err := h.db.First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
-	}
-
-	// Only update allowed fields
-	var updateData struct {
-		FirstName        string          `json:"first_name"`
-		LastName         string          `json:"last_name"`
-		Role             models.UserRole `json:"role"`
-		ProfilePictureID string          `json:"profilePictureId"`
+	user, err := h.findManagedUser(c, h.db)
+	if err != nil {
+		return err
 	}
 
+	// Only update allowed fields, profile pictures change through UpdateProfilePicture
+	var updateData UpdateUserRequest
 	if err := c.Bind(&updateData); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
@@ -428,105 +1018,182 @@ func (h *AuthHandler) UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid role"})
 	}
 
+	// 🛡️ Roles only move within the caller's own privilege
+	callerRole := models.UserRole(middleware.GetUserRole(c))
+	switch {
+	case user.ID == middleware.GetUserID(c) && updateData.Role != user.Role:
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You cannot change your own role"})
+	case user.Role.Rank() > callerRole.Rank():
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You cannot edit a user with a higher role than yours"})
+	case updateData.Role.Rank() > callerRole.Rank():
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You cannot grant a role higher than your own"})
+	}
+
+	roleChanged := updateData.Role != user.Role
 	user.FirstName = updateData.FirstName
 	user.LastName = updateData.LastName
 	user.Role = updateData.Role
 
-	if updateData.ProfilePictureID != "" {
-		user.ProfilePictureID = updateData.ProfilePictureID
-	}
-
-	if err := h.db.Save(&user).Error; err != nil {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("first_name", "last_name", "role").Updates(user).Error; err != nil {
+			return err
+		}
+		// 🔑 The user's permissions follow their new role
+		if roleChanged {
+			return models.ResetPermissions(tx, user)
+		}
+		return nil
+	}); err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to update user: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update user"})
 	}
+	if roleChanged {
+		middleware.InvalidateUserScopes(user.ID)
+	}
 
-	return c.JSON(http.StatusOK, user)
+	return redact.JSON(c, http.StatusOK, user.Response())
 }
 
-// DeleteUser deletes a user (admin only)
+// DeleteUser deletes a user
 // @Summary Delete user
-// @Description Delete a specific user (requires admin permissions)
+// @Description Delete a user of the current team, requires users:delete. Callers cannot delete themselves or users who outrank them.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID"
 // @Success 200 {object} map[string]string "User deleted successfully"
+// @Failure 400 {object} map[string]string "Invalid user ID"
 // @Failure 403 {object} map[string]string "Forbidden"
 // @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/users/{id} [delete]
+// @Router /users/{id} [delete]
 func (h *AuthHandler) DeleteUser(c echo.Context) error {
-	id := c.Param("id")
-	var user models.User
-	if err := h.db.First(&user, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	user, err := h.findManagedUser(c, h.db)
+	if err != nil {
+		return err
 	}
 
-	if err := h.db.Delete(&user).Error; err != nil {
+	removedBy, _ := c.Get("userID").(string)
+	if user.ID == removedBy {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You cannot delete yourself"})
+	}
+	if user.Role.Rank() > models.UserRole(middleware.GetUserRole(c)).Rank() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You cannot delete a user with a higher role than yours"})
+	}
+
+	if err := h.db.Model(user).Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()}).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete user"})
 	}
 
+	events.Emit(events.EventTeamMemberRemoved, events.MemberRemoved{
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		RemovedBy:  removedBy,
+		OccurredAt: time.Now().UTC(),
+	})
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "User deleted successfully"})
 }
 
-// RefreshToken refreshes a user's access token using their refresh token
+// RefreshToken rotates a session's refresh token and issues a new token pair. Presenting
+// a refresh token that was already rotated revokes the session, as it may have been stolen.
 // @Summary Refresh access token
-// @Description Get a new access token using a valid refresh token
+// @Description Exchange a refresh token for a new access and refresh token. Each refresh token works once.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param refresh_token body string true "Refresh token"
-// @Success 200 {object} map[string]string "New access token"
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} map[string]string "New token pair"
 // @Failure 400 {object} map[string]string "Invalid input"
-// @Failure 401 {object} map[string]string "Invalid refresh token"
+// @Failure 401 {object} map[string]string "Invalid, expired or reused refresh token"
+// @Failure 403 {object} map[string]string "Account deactivated, or the team requires two-factor authentication, which the user has not set up"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
-	var input struct {
-		RefreshToken string `json:"refresh_token"`
-	}
-
-	if err := c.Bind(&input); err != nil {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
 
-	// get refresh token from request
-	refreshToken := input.RefreshToken
-
-	// validate refresh token
-	_, err := utils.ValidateRefreshToken(refreshToken, os.Getenv("JWT_SECRET"))
-	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// check in db if refresh token is valid
-	var authTransaction models.AuthTransaction
-	if err := h.db.Where("token = ? AND expires_at > ?", refreshToken, time.Now()).First(&authTransaction).Error; err != nil {
+	// 🔏 Signature, expiry and audience are verified before the database is touched
+	claims, err := utils.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
 	}
 
-	// get user from claims
 	var user models.User
-	if err := h.db.First(&user, authTransaction.UserID).Error; err != nil {
+	if err := h.db.Where("id = ? AND is_deleted = false", claims.UserID).
+		Preload("Permissions", "is_deleted = false").Preload("Permissions.ResourcePermission").
+		First(&user).Error; err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "User not found"})
 	}
+	if !user.IsActive {
+		return accountDeactivated(c)
+	}
 
-	// generate new access token
-	accessToken, err := utils.GenerateJWT(user)
+	// 🏢 A session stays in the team it was issued for, which after a team switch is not the
+	// user's own. Sessions of a deleted team, or one the user no longer has access to, cannot be refreshed.
+	var session models.AuthTransaction
+	if err := h.db.Select("team_id").Where("id = ? AND user_id = ? AND is_deleted = false", claims.SessionID, user.ID).
+		First(&session).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
+	}
+	team, _, err := models.TeamAccess(h.db, &user, session.TeamID)
 	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Team not found"})
+	}
+	// 🔢 Once the team requires 2FA, members without it sign in again and set it up
+	if team.AuthPolicy.RequireTwoFactor && !user.TwoFactorEnabled {
+		return twoFactorEnrollmentRequired(c)
+	}
+	orgID := ""
+	if team.OrganizationID != nil {
+		orgID = *team.OrganizationID
+	}
+	user.TeamID = team.ID
+
+	accessToken, tokenID, err := utils.GenerateJWT(user, orgID)
+	if err != nil {
+		h.log.FromContext(c.Request().Context()).WithFields(logger.Fields{"user_id": user.ID}).Error("Failed to generate access token: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate access token"})
 	}
+	refreshToken, err := utils.GenerateRefreshToken(user, claims.SessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate refresh token"})
+	}
 
-	// save new access token to db
-	authTransaction.Token = accessToken
-	if err := h.db.Save(&authTransaction).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save access token"})
+	// 🔁 The presented token is swapped for the new one, a second use of it revokes the session
+	_, err = models.RotateSession(h.db, claims.SessionID, user.ID,
+		models.HashRefreshToken(req.RefreshToken), tokenID, models.HashRefreshToken(refreshToken),
+		time.Now().UTC().Add(models.SessionLifetime))
+	switch {
+	case errors.Is(err, models.ErrRefreshTokenReused):
+		h.log.FromContext(c.Request().Context()).Warn("Refresh token reuse for user %s, session %s revoked", user.ID, claims.SessionID)
+		events.Emit(events.EventSecurityAlert, events.SecurityAlert{
+			Alert:      events.AlertRefreshTokenReused,
+			UserID:     user.ID,
+			TeamID:     user.TeamID,
+			Email:      user.Email,
+			IPAddress:  c.RealIP(),
+			UserAgent:  c.Request().UserAgent(),
+			OccurredAt: time.Now().UTC(),
+		})
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Refresh token was already used, the session has been revoked",
+			"code":  "refresh_token_reused",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid refresh token"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"token": accessToken, "exp": "15m"})
+	return c.JSON(http.StatusOK, map[string]string{"token": accessToken, "refresh_token": refreshToken})
 }
 
 // GetMe returns the current user
@@ -535,16 +1202,19 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {object} models.User
+// @Success 200 {object} models.UserResponse
 // @Router /auth/me [get]
 func (h *AuthHandler) GetMe(c echo.Context) error {
-	userId := c.Get("userID").(string)
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
 
 	var user models.User
-	if err := h.db.Where("id = ?", userId).Preload("Team").First(&user).Error; err != nil {
+	if err := h.db.Where("id = ? AND is_deleted = false", userID).Preload("Team").Preload("ProfilePicture").First(&user).Error; err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
-	return c.JSON(http.StatusOK, user)
+	return c.JSON(http.StatusOK, user.Response())
 }
 
 // InviteUserRequest is the request body for inviting a user to a team
@@ -557,21 +1227,30 @@ type InviteUserRequest struct {
 
 // InviteUser handles sending invitations to new team members
 // @Summary Invite a user to join a team
-// @Description Send an invitation email to a user to join a team
+// @Description Send an invitation email to a user to join a team. Requires team_invites:create, and the invited role can't be above the caller's.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body InviteUserRequest true "Invitation details"
 // @Success 201 {object} map[string]string "Invitation sent successfully"
 // @Failure 400 {object} map[string]string "Validation error"
+// @Failure 403 {object} map[string]string "Invited role is above the caller's"
+// @Failure 409 {object} map[string]string "Pending invitation already exists, or the email belongs to a member of the team"
+// @Failure 422 {object} map[string]interface{} "Member quota exceeded"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/invite [post]
 func (h *AuthHandler) InviteUser(c echo.Context) error {
 	// 🔒 Get current user ID from context
-	userID := c.Get("userID").(string)
-	teamID := c.Get("teamID").(string)
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
 
-	h.log.Info("Inviting user %s to team %s", userID, teamID)
+	h.log.FromContext(c.Request().Context()).Info("Inviting user %s to team %s", userID, teamID)
 
 	var request InviteUserRequest
 	if err := c.Bind(&request); err != nil {
@@ -583,16 +1262,62 @@ func (h *AuthHandler) InviteUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// 🛡️ Nobody can invite someone above their own role
+	if models.UserRole(request.Role).Rank() > models.UserRole(middleware.GetUserRole(c)).Rank() {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "You cannot invite someone with a higher role than your own",
+			"code":  "role_above_inviter",
+		})
+	}
+
 	// Generate invite code
 	code, err := utils.GenerateRandomString(32)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate invite code"})
 	}
 
+	// ⏳ Expired invites no longer block a new one
+	if err := models.ExpireStaleInvites(request.Email, teamID, h.db); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitation"})
+	}
+
+	// 🔍 Only one pending invite per email and team
+	var existing int64
+	if err := h.db.Model(&models.TeamInvite{}).
+		Where("email = ? AND team_id = ? AND status = ? AND is_deleted = false", request.Email, teamID, models.InviteStatusPending).
+		Count(&existing).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitation"})
+	}
+	if existing > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A pending invitation already exists for this email"})
+	}
+
+	// 👤 Members of the team have nothing to accept
+	var members int64
+	if err := h.db.Model(&models.User{}).
+		Where("email = ? AND team_id = ? AND is_deleted = false", request.Email, teamID).
+		Count(&members).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitation"})
+	}
+	if members > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This email already belongs to a member of the team"})
+	}
+
+	// 👥 Members and pending invites count towards the team's quota
+	if h.maxTeamMembers > 0 {
+		seats, err := models.CountTeamSeats(teamID, h.db)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitation"})
+		}
+		if seats+1 > int64(h.maxTeamMembers) {
+			return memberQuotaExceeded(c, h.maxTeamMembers, seats, 1)
+		}
+	}
+
 	// 💾 Save invitation
 	invite := models.TeamInvite{
 		Code:      code,
-		ExpiresAt: time.Now().Add(24 * 7 * time.Hour),
+		ExpiresAt: time.Now().UTC().Add(models.InviteLifetime),
 		InviterID: userID,
 		TeamID:    teamID,
 		Status:    models.InviteStatusPending,
@@ -603,26 +1328,39 @@ func (h *AuthHandler) InviteUser(c echo.Context) error {
 
 	// 💾 Save invitation
 	if err := h.db.Create(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "A pending invitation already exists for this email"})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitation"})
 	}
+
+	// ✉️ Email the invitee and notify webhooks
+	h.sendInvites(c, []models.TeamInvite{invite}, false)
+
 	return c.JSON(http.StatusCreated, map[string]string{"message": "Invitation sent successfully"})
 }
 
+type AcceptInviteRequest struct {
+	Password string `json:"password" validate:"required,min=8"`
+}
+
 // AcceptInvite handles accepting team invitations
+// An existing account with the invited email joins the team after confirming its password,
+// otherwise a new user is created. Either way the user is signed in. The invite never grants
+// more than its inviter's current role, and a deleted account can't be revived through it.
 // @Summary Accept a team invitation
-// @Description Accept an invitation to join a team
+// @Description Accept an invitation to join a team and sign in. When an account with the invited email exists, password must be its current password and the account moves to the team. The granted role never exceeds the inviter's current role, and deleted accounts are refused.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param code path string true "Invitation code"
-// @Success 200 {object} map[string]string "Invitation accepted successfully"
-// @Failure 400 {object} map[string]string "Invalid invitation"
+// @Param request body AcceptInviteRequest true "Password for the new account, or of the existing one"
+// @Success 200 {object} map[string]string "JWT token pair, or a two-factor challenge"
+// @Failure 400 {object} map[string]string "Invalid or expired invitation"
+// @Failure 401 {object} map[string]string "Incorrect password for the existing account"
+// @Failure 403 {object} map[string]string "The account with the invited email was deleted"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/invite/accept/{code} [post]
-type AcceptInviteRequest struct {
-	Password string `json:"password" validate:"required,min=8"`
-}
-
+// @Router /auth/accept/{code} [post]
 func (h *AuthHandler) AcceptInvite(c echo.Context) error {
 	code := c.Param("code")
 
@@ -643,81 +1381,172 @@ func (h *AuthHandler) AcceptInvite(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
 	}
 
-	// 🔍 Find invitation
-	var invite models.TeamInvite
-	if err := h.db.Where("code = ? AND status = ? AND expires_at > ?",
-		code, "pending", time.Now()).First(&invite).Error; err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired invitation"})
-	}
+	errInvalidInvite := echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired invitation")
+	errWrongPassword := echo.NewHTTPError(http.StatusUnauthorized, "Incorrect password for the existing account")
+	errDeletedAccount := echo.NewHTTPError(http.StatusForbidden, "The account with this email was deleted, ask an administrator to restore it")
 
-	// Start transaction
-	tx := h.db.Begin()
+	var user models.User
+	var team *models.Team
+	created := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// 🔍 Find invitation
+		var invite models.TeamInvite
+		if err := tx.Where("code = ? AND status = ? AND expires_at > ? AND is_deleted = false",
+			code, models.InviteStatusPending, time.Now().UTC()).First(&invite).Error; err != nil {
+			return errInvalidInvite
+		}
 
-	// 👤 Create new user
-	newUser := models.User{
-		Email:     invite.Email,
-		FirstName: invite.Name,
-		LastName:  "",
-		Password:  string(hashedPassword),
-		TeamID:    invite.TeamID,
-		Role:      invite.Role, // Default role for invited users
-	}
+		// 🏢 Invites to a deleted team can no longer be accepted
+		var err error
+		if team, err = models.GetTeamByID(invite.TeamID, tx); err != nil {
+			return errInvalidInvite
+		}
 
-	if err := h.db.Create(&newUser).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
-	}
+		// 🎟️ Single use, a concurrent accept of the same invite loses here
+		result := tx.Model(&models.TeamInvite{}).
+			Where("id = ? AND status = ?", invite.ID, models.InviteStatusPending).
+			Update("status", models.InviteStatusAccepted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidInvite
+		}
+		if err := models.ExpireOtherPendingInvites(invite.Email, invite.ID, tx); err != nil {
+			return err
+		}
 
-	// ✅ Update invitation status
-	invite.Status = "accepted"
-	if err := tx.Save(&invite).Error; err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update invitation"})
+		// The invite code was sent to the address, so it is verified
+		verifiedAt := time.Now().UTC()
+
+		// 👤 Emails are unique across soft-deleted users too, so look at every row
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", invite.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			role, err := models.InviteRole(&invite, "", tx)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				Email:           invite.Email,
+				EmailVerified:   true,
+				EmailVerifiedAt: &verifiedAt,
+				FirstName:       invite.Name,
+				LastName:        "",
+				Password:        string(hashedPassword),
+				TeamID:          invite.TeamID,
+				Role:            role,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+			return models.AssignDefaultPermissions(tx, &user)
+		case err != nil:
+			return err
+		}
+
+		// 🚫 Deleted accounts are restored by an administrator, never by whoever holds an invite
+		if user.IsDeleted {
+			return errDeletedAccount
+		}
+		if checkPassword(user.Password, req.Password) != nil {
+			return errWrongPassword
+		}
+
+		// 🛡️ The invite can't raise the user above what its inviter may grant
+		role, err := models.InviteRole(&invite, user.Role, tx)
+		if err != nil {
+			return err
+		}
+
+		// 🔁 Sessions of the previous team must not keep working
+		if user.TeamID != invite.TeamID {
+			if _, err := models.RevokeUserSessions(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		user.TeamID = invite.TeamID
+		user.Role = role
+		user.EmailVerified = true
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &verifiedAt
+		}
+		if err := tx.Model(&user).Select("team_id", "role", "email_verified", "email_verified_at").
+			Updates(&user).Error; err != nil {
+			return err
+		}
+		return models.ResetPermissions(tx, &user)
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return c.JSON(httpErr.Code, map[string]string{"error": httpErr.Message.(string)})
+		}
+		h.log.FromContext(c.Request().Context()).Error("Failed to accept invitation: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to accept invitation"})
 	}
+	middleware.InvalidateUserScopes(user.ID)
 
-	// Assign default permissions based on role
-	if err := models.AssignDefaultPermissions(tx, &newUser); err != nil {
-		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign permissions"})
+	if created {
+		events.Emit("users.invite_accepted", &user)
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	// 🔐 The team may require another sign-in method, the user signs in with that instead
+	if !team.AuthPolicy.Allows(models.AuthMethodPassword) {
+		return authMethodNotAllowed(c, team)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation accepted successfully"})
+	// 🔢 The token pair is only issued once the second factor is checked
+	return h.signIn(c, user)
 }
 
 // DeleteInvite handles deleting team invitations
 // @Summary Delete a team invitation
-// @Description Delete a pending team invitation
+// @Description Delete an invitation of the current team. Requires team_invites:delete.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param id path string true "Invitation ID"
 // @Success 200 {object} map[string]string "Invitation deleted successfully"
-// @Failure 400 {object} map[string]string "Invalid invitation"
+// @Failure 404 {object} map[string]string "Invitation not found"
 // @Failure 500 {object} map[string]string "Internal server error"
-// @Router /auth/invite/{id} [delete]
+// @Router /users/invite/{id} [delete]
 func (h *AuthHandler) DeleteInvite(c echo.Context) error {
-	// 🔒 Get current user ID from context
-	userID := c.Get("userID").(string)
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
 	inviteID := c.Param("id")
 
-	// 🔍 Find and validate invitation
+	// 🔍 Only invitations of the caller's team can be deleted
 	var invite models.TeamInvite
-	if err := h.db.Where("id = ? AND (inviter_id = ? OR email = ?)",
-		inviteID, userID, userID).First(&invite).Error; err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invitation not found"})
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", inviteID, teamID).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Invitation not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete invitation"})
 	}
 
 	// ❌ Delete invitation
-	if err := h.db.Delete(&invite).Error; err != nil {
+	if err := h.db.Model(&invite).Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()}).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete invitation"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation deleted successfully"})
 }
 
+// acceptInvite marks an invite as accepted and expires the invitee's other pending invites,
+// since a user belongs to exactly one team.
+func acceptInvite(tx *gorm.DB, invite *models.TeamInvite) error {
+	invite.Status = models.InviteStatusAccepted
+	if err := tx.Save(invite).Error; err != nil {
+		return err
+	}
+	return models.ExpireOtherPendingInvites(invite.Email, invite.ID, tx)
+}
+
 // GoogleAuth handles authentication with Google OAuth
 // @Summary Authenticate with Google
 // @Description Authenticate user using Google OAuth ID token
@@ -732,14 +1561,12 @@ func (h *AuthHandler) DeleteInvite(c echo.Context) error {
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/google/callback [get]
 func (h *AuthHandler) GoogleAuthCallback(c echo.Context) error {
-	accessToken := c.Request().Header.Get("Authorization")
+	accessToken := bearerToken(c)
 
 	if accessToken == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No access token provided"})
 	}
 
-	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
-
 	// get user data from google
 	userDataBytes, err := utils.GetUserDataFromGoogle(accessToken)
 	if err != nil {
@@ -747,189 +1574,25 @@ func (h *AuthHandler) GoogleAuthCallback(c echo.Context) error {
 	}
 
 	// parse user data
-	var userData map[string]interface{}
+	var userData struct {
+		ID            string `json:"id"`
+		Email         string `json:"email"`
+		VerifiedEmail bool   `json:"verified_email"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+		PhotoURL      string `json:"photoUrl"`
+	}
 	if err := json.Unmarshal(userDataBytes, &userData); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to parse user data from Google"})
 	}
 
-	// Start a transaction
-	tx := h.db.Begin()
-	if tx.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
-	}
-
-	// Check if user exists with either email or provider ID
-	var user models.User
-	err = tx.Where("email = ? OR (provider = ? AND provider_id = ?)",
-		userData["email"], "google", userData["id"]).First(&user).Error
-
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Check for pending team invitation first
-			var invite models.TeamInvite
-			inviteErr := tx.Where("email = ? AND status = ? AND expires_at > ?",
-				userData["email"], "pending", time.Now()).First(&invite).Error
-
-			var teamID string
-			var userRole models.UserRole
-
-			if inviteErr == nil {
-				// Use the invited team and role
-				teamID = invite.TeamID
-				userRole = invite.Role
-
-				// Mark invitation as accepted
-				invite.Status = "accepted"
-				if err := tx.Save(&invite).Error; err != nil {
-					tx.Rollback()
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update invitation"})
-				}
-			} else {
-				// No invitation found, create new team
-				team := models.Team{
-					Name: userData["given_name"].(string) + "'s Team",
-				}
-
-				if err = tx.Create(&team).Error; err != nil {
-					tx.Rollback()
-					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create team"})
-				}
-
-				teamID = team.ID
-				userRole = models.UserRoleAdmin
-			}
-			var fileModel *models.File
-			// download the profile picture
-			if photoURL, ok := userData["photoUrl"].(string); ok {
-				profilePicture, err := http.Get(photoURL)
-				if err != nil {
-					// Log the error but do not affect account creation
-					h.log.Error("Failed to download profile picture", err)
-				} else {
-					defer profilePicture.Body.Close()
-					// read the profile picture
-					profilePictureBytes, err := io.ReadAll(profilePicture.Body)
-					if err != nil {
-						h.log.Error("Failed to read profile picture", err)
-					} else {
-						// Get storage handler
-						storage := GetStorageHandler()
-						if storage != nil {
-							// Create a temporary user ID since we don't have the real one yet
-							tempUserID := uuid.New().String()
-							// upload the profile picture to s3
-							profilePictureURL, err := storage.UploadFile(c.Request().Context(), profilePictureBytes, tempUserID, "public-read", "image/jpeg")
-							if err != nil {
-								h.log.Error("Failed to upload profile picture", err)
-							} else {
-								fileModel = &models.File{
-									TeamID: teamID,
-									Path:   profilePictureURL[strings.LastIndex(profilePictureURL, "/")+1:],
-									Name:   "profile_picture.jpg",
-									Size:   int64(len(profilePictureBytes)),
-									Type:   "image/jpeg",
-								}
-								if err := tx.Create(fileModel).Error; err != nil {
-									h.log.Error("Failed to create profile picture", err)
-									fileModel = nil
-								}
-							}
-						} else {
-							h.log.Error("Storage handler not configured", nil)
-						}
-					}
-				}
-			}
-
-			// Create user with both google and local auth capabilities
-			user = models.User{
-				Email:      userData["email"].(string),
-				FirstName:  userData["given_name"].(string),
-				LastName:   userData["family_name"].(string),
-				Role:       userRole,
-				TeamID:     teamID,
-				Provider:   "google",
-				ProviderID: userData["id"].(string),
-				Password:   "", // Empty password for google users
-				// skip provider data for now
-				ProviderData: datatypes.JSON{},
-			}
-
-			// Only set ProfilePictureID if we successfully created the file
-			if fileModel != nil && fileModel.ID != "" {
-				user.ProfilePictureID = fileModel.ID
-			} else {
-				user.ProfilePictureID = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
-			}
-
-			if err := tx.Create(&user).Error; err != nil {
-				tx.Rollback()
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
-			}
-
-			// Assign default permissions
-			if err := models.AssignDefaultPermissions(tx, &user); err != nil {
-				tx.Rollback()
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign permissions"})
-			}
-
-			// Emit different events based on invitation status
-			if inviteErr == nil {
-				events.Emit("users.invite_accepted", &user)
-			} else {
-				events.Emit("users.created", &user)
-			}
-		} else {
-			tx.Rollback()
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check user existence"})
-		}
-	} else {
-		// If user exists but hasn't used Google auth before, link the accounts
-		if user.Provider == "local" {
-			user.Provider = "google"
-			user.ProviderID = userData["id"].(string)
-			if user.ProfilePictureID == "" {
-				user.ProfilePictureID = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
-			}
-			if err := tx.Save(&user).Error; err != nil {
-				tx.Rollback()
-				fmt.Println("Failed to update user", err)
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update user"})
-			}
-		}
-	}
-
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
-	}
-
-	// Generate JWT token
-	jwtToken, err := utils.GenerateJWT(user)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
-	}
-
-	refreshToken, err := utils.GenerateRefreshToken(user)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate refresh token"})
-	}
-
-	// Create auth transaction
-	authtransaction := &models.AuthTransaction{
-		UserID: user.ID,
-		TeamID: user.TeamID,
-		Token:  jwtToken,
-	}
-
-	if err := h.db.Create(authtransaction).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create auth transaction"})
-	}
-
-	events.Emit("users.google_auth", &user)
-
-	return c.JSON(http.StatusOK, map[string]string{
-		"token":         jwtToken,
-		"refresh_token": refreshToken,
+	return h.oauthSignIn(c, oauthProfile{
+		Method:        models.AuthMethodGoogle,
+		ID:            userData.ID,
+		Email:         userData.Email,
+		FirstName:     userData.GivenName,
+		LastName:      userData.FamilyName,
+		EmailVerified: userData.VerifiedEmail,
+		PhotoURL:      userData.PhotoURL,
 	})
 }
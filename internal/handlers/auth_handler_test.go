@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/captcha"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func TestResetCodeEntropy(t *testing.T) {
+	if bits := float64(resetCodeLength) * math.Log2(62); bits < 64 {
+		t.Errorf("reset codes carry %.1f bits of entropy, want at least 64", bits)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code, err := generateResetCode(resetCodeLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != resetCodeLength {
+			t.Fatalf("generateResetCode returned %d characters, want %d", len(code), resetCodeLength)
+		}
+		if seen[code] {
+			t.Fatalf("generateResetCode repeated %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+// countComparisons counts bcrypt comparisons until the test ends
+func countComparisons(t *testing.T) *int {
+	t.Helper()
+	count := 0
+	original := comparePassword
+	comparePassword = func(hash, password []byte) error {
+		count++
+		return original(hash, password)
+	}
+	t.Cleanup(func() { comparePassword = original })
+	return &count
+}
+
+func TestLoginComparesPasswordForUnknownEmails(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	testutil.UseJWTSecret(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	h := newTestAuthHandler(gdb)
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{"unknown email", "nobody@example.com"},
+		{"known email", user.Email},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := countComparisons(t)
+			c, rec := newContext(t, http.MethodPost, "/api/v1/auth/login", LoginRequest{Email: tt.email, Password: "wrong-password"})
+
+			expectStatus(t, rec, h.Login(c), http.StatusUnauthorized)
+			if *comparisons != 1 {
+				t.Errorf("got %d bcrypt comparisons, want 1", *comparisons)
+			}
+			if got := decode(t, rec)["error"]; got != "Invalid credentials" {
+				t.Errorf("got error %q, want the same message for every email", got)
+			}
+		})
+	}
+}
+
+func TestPasswordResetComparesPasswordForEveryEmail(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	h := newTestAuthHandler(gdb)
+
+	for _, email := range []string{"nobody@example.com", user.Email} {
+		comparisons := countComparisons(t)
+		c, rec := newContext(t, http.MethodPost, "/api/v1/auth/password-reset", ResetPasswordRequest{Email: email})
+
+		expectStatus(t, rec, h.RequestPasswordReset(c), http.StatusOK)
+		if *comparisons != 1 {
+			t.Errorf("%s: got %d bcrypt comparisons, want 1", email, *comparisons)
+		}
+	}
+}
+
+func registerRequest(email string) RegisterRequest {
+	return RegisterRequest{Email: email, Password: testutil.Password, FirstName: "Grace", LastName: "Hopper"}
+}
+
+func TestRegisterNewEmailCreatesTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/register", registerRequest("grace@example.com"))
+	expectStatus(t, rec, h.Register(c), http.StatusCreated)
+
+	var user models.User
+	if err := gdb.Preload("Team").Where("email = ?", "grace@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.UserRoleAdmin || user.Team == nil || user.Team.Name != "Grace's Team" {
+		t.Errorf("got role %s in team %+v, want the admin of a new team", user.Role, user.Team)
+	}
+	var verifications int64
+	gdb.Model(&models.EmailVerification{}).Where("user_id = ?", user.ID).Count(&verifications)
+	if verifications != 1 {
+		t.Errorf("%d verification codes, want 1", verifications)
+	}
+}
+
+func TestRegisterExistingEmailIsRejected(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	existing := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/register", registerRequest(existing.Email))
+	expectStatus(t, rec, h.Register(c), http.StatusBadRequest)
+	if got := decode(t, rec)["error"]; got != "User already exists" {
+		t.Errorf("error %q, want User already exists", got)
+	}
+
+	var teams int64
+	gdb.Model(&models.Team{}).Count(&teams)
+	if teams != 1 {
+		t.Errorf("%d teams, the rejected registration created one", teams)
+	}
+}
+
+func TestRegisterWithPendingInviteJoinsTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	invite := models.TeamInvite{
+		Email:     "grace@example.com",
+		Name:      "Grace",
+		TeamID:    team.ID,
+		InviterID: inviter.ID,
+		Role:      models.UserRoleMember,
+		Code:      "INVITE-CODE",
+		Status:    models.InviteStatusPending,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+	if err := gdb.Create(&invite).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/register", registerRequest(invite.Email))
+	expectStatus(t, rec, h.Register(c), http.StatusCreated)
+
+	var user models.User
+	if err := gdb.Where("email = ?", invite.Email).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TeamID != team.ID || user.Role != models.UserRoleMember {
+		t.Errorf("joined team %s as %s, want %s as MEMBER", user.TeamID, user.Role, team.ID)
+	}
+	if err := gdb.First(&invite, "id = ?", invite.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if invite.Status != models.InviteStatusAccepted {
+		t.Errorf("invite is %s, want ACCEPTED", invite.Status)
+	}
+	var teams int64
+	gdb.Model(&models.Team{}).Count(&teams)
+	if teams != 1 {
+		t.Errorf("%d teams, an invited user should not get their own", teams)
+	}
+}
+
+func TestRegisterDatabaseErrorIsNotReportedAsDuplicate(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	if err := gdb.Migrator().DropTable(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/register", registerRequest("grace@example.com"))
+	expectStatus(t, rec, h.Register(c), http.StatusInternalServerError)
+}
+
+// verificationCode registers an email and returns the code handed to the mailer
+func verificationCode(t *testing.T, gdb *gorm.DB, email string) string {
+	t.Helper()
+	var user models.User
+	if err := gdb.Where("email = ?", email).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	verification, err := createEmailVerification(gdb, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verification.Code
+}
+
+// solvedCaptcha is a captcha verifier accepting only its own token
+type solvedCaptcha string
+
+func (v solvedCaptcha) Verify(_ context.Context, token, _ string) error {
+	if token != string(v) {
+		return captcha.ErrRejected
+	}
+	return nil
+}
+
+func TestCaptchaGuardsRegistrationAndPasswordReset(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	h := newTestAuthHandler(gdb)
+	h.captcha = captcha.NewGuard(solvedCaptcha("solved"), []string{captcha.EndpointRegister, captcha.EndpointPasswordReset}, false)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"solved", "solved", http.StatusOK},
+		{"forged", "forged", http.StatusBadRequest},
+		{"missing", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "grace-" + tt.name + "@example.com"
+			register := registerRequest(email)
+			register.CaptchaToken = tt.token
+			c, rec := newContext(t, http.MethodPost, "/auth/register", register)
+			want := tt.status
+			if want == http.StatusOK {
+				want = http.StatusCreated
+			}
+			expectStatus(t, rec, h.Register(c), want)
+			var registered int64
+			gdb.Model(&models.User{}).Where("email = ?", email).Count(&registered)
+			if (registered == 1) != (tt.status == http.StatusOK) {
+				t.Errorf("%d accounts for %s", registered, email)
+			}
+
+			c, rec = newContext(t, http.MethodPost, "/auth/password-reset", ResetPasswordRequest{Email: user.Email, CaptchaToken: tt.token})
+			expectStatus(t, rec, h.RequestPasswordReset(c), tt.status)
+			if tt.status != http.StatusOK {
+				if code := decode(t, rec)["code"]; code != "captcha_failed" {
+					t.Errorf("code %v, want captcha_failed", code)
+				}
+			}
+		})
+	}
+
+	var resets int64
+	gdb.Model(&models.PasswordReset{}).Where("user_id = ?", user.ID).Count(&resets)
+	if resets != 1 {
+		t.Errorf("%d reset codes, want only the one with a solved captcha", resets)
+	}
+}
+
+func TestVerificationCodesAreStoredHashed(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	code := verificationCode(t, gdb, user.Email)
+	if len(code) != resetCodeLength {
+		t.Fatalf("code %q has length %d, want %d", code, len(code), resetCodeLength)
+	}
+
+	var stored models.EmailVerification
+	if err := gdb.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Code != "" || stored.CodeHash != models.HashResetCode(code) {
+		t.Errorf("stored code %q and hash %q, want only the hash of %q", stored.Code, stored.CodeHash, code)
+	}
+	if columns, _ := gdb.Migrator().ColumnTypes(&models.EmailVerification{}); len(columns) > 0 {
+		for _, column := range columns {
+			if column.Name() == "code" {
+				t.Error("email_verifications still has a plaintext code column")
+			}
+		}
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	if err := gdb.Model(user).Update("email_verified", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	code := verificationCode(t, gdb, user.Email)
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		c, rec := newContext(t, http.MethodPost, "/auth/verify-email", VerifyEmailRequest{Code: code})
+		if err := h.VerifyEmail(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	var stored models.EmailVerification
+	if err := gdb.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rec := verify(stored.CodeHash); rec.Code != http.StatusBadRequest {
+		t.Errorf("the stored hash was accepted as a code: %d", rec.Code)
+	}
+	if rec := verify(code); rec.Code != http.StatusOK {
+		t.Fatalf("status %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	if err := gdb.First(user, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified || user.EmailVerifiedAt == nil {
+		t.Error("email not marked verified")
+	}
+	if rec := verify(code); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused code got %d, want 400", rec.Code)
+	}
+
+	expired := verificationCode(t, gdb, user.Email)
+	if err := gdb.Model(&models.EmailVerification{}).Where("code_hash = ?", models.HashResetCode(expired)).
+		Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rec := verify(expired); rec.Code != http.StatusBadRequest {
+		t.Errorf("expired code got %d, want 400", rec.Code)
+	}
+}
+
+// refresh exchanges a refresh token and returns the status and new token pair
+func refresh(t *testing.T, h *AuthHandler, token string) (int, map[string]interface{}) {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/auth/refresh", RefreshTokenRequest{RefreshToken: token})
+	if err := h.RefreshToken(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, decode(t, rec)
+}
+
+// signedIn signs a new member in and returns their refresh token
+func signedIn(t *testing.T) (*gorm.DB, *AuthHandler, *models.User, string) {
+	t.Helper()
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+	expectStatus(t, rec, h.Login(c), http.StatusOK)
+	token, _ := decode(t, rec)["refresh_token"].(string)
+	if token == "" {
+		t.Fatal("no refresh token")
+	}
+	return gdb, h, user, token
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	_, h, _, first := signedIn(t)
+
+	status, body := refresh(t, h, first)
+	if status != http.StatusOK {
+		t.Fatalf("status %d %v, want 200", status, body)
+	}
+	second, _ := body["refresh_token"].(string)
+	if body["token"] == nil || second == "" || second == first {
+		t.Fatalf("got %v, want a new token pair", body)
+	}
+
+	if status, body = refresh(t, h, second); status != http.StatusOK {
+		t.Errorf("rotated token got %d %v, want 200", status, body)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	gdb, h, user, first := signedIn(t)
+
+	_, body := refresh(t, h, first)
+	second, _ := body["refresh_token"].(string)
+
+	if status, _ := refresh(t, h, first); status != http.StatusUnauthorized {
+		t.Fatalf("reused token got %d, want 401", status)
+	}
+	var session models.AuthTransaction
+	if err := gdb.Where("user_id = ?", user.ID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !session.IsDeleted {
+		t.Error("session not revoked after the reuse")
+	}
+	if status, _ := refresh(t, h, second); status != http.StatusUnauthorized {
+		t.Errorf("latest token of a revoked session got %d, want 401", status)
+	}
+}
+
+func TestRefreshRejectsExpiredSessions(t *testing.T) {
+	gdb, h, user, token := signedIn(t)
+
+	if err := gdb.Model(&models.AuthTransaction{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := refresh(t, h, token); status != http.StatusUnauthorized {
+		t.Errorf("expired session got %d, want 401", status)
+	}
+}
+
+func TestRefreshRejectsTamperedTokens(t *testing.T) {
+	_, h, _, token := signedIn(t)
+
+	parts := strings.Split(token, ".")
+	signature := []byte(parts[2])
+	signature[0] ^= 1
+	tampered := parts[0] + "." + parts[1] + "." + string(signature)
+	if status, _ := refresh(t, h, tampered); status != http.StatusUnauthorized {
+		t.Errorf("tampered token got %d, want 401", status)
+	}
+
+	// The untouched token still works, a tampered copy does not revoke the session
+	if status, _ := refresh(t, h, token); status != http.StatusOK {
+		t.Errorf("original token got %d, want 200", status)
+	}
+}
+
+func TestInviteUserCannotInviteAboveOwnRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller models.UserRole
+		role   models.UserRole
+		status int
+	}{
+		{name: "admin inviting a member", caller: models.UserRoleAdmin, role: models.UserRoleMember, status: http.StatusCreated},
+		{name: "admin inviting an admin", caller: models.UserRoleAdmin, role: models.UserRoleAdmin, status: http.StatusCreated},
+		{name: "admin inviting a super admin", caller: models.UserRoleAdmin, role: models.UserRoleSuperAdmin, status: http.StatusForbidden},
+		{name: "super admin inviting a super admin", caller: models.UserRoleSuperAdmin, role: models.UserRoleSuperAdmin, status: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			caller := testutil.CreateUser(t, gdb, team.ID, tt.caller)
+
+			c, rec := newContext(t, http.MethodPost, "/users/invite", InviteUserRequest{
+				Email: "invitee@example.com", Name: "Invitee", Role: string(tt.role),
+			})
+			c.Set("userID", caller.ID)
+			c.Set("teamID", team.ID)
+			c.Set("role", string(caller.Role))
+			expectStatus(t, rec, h.InviteUser(c), tt.status)
+
+			var invites int64
+			gdb.Model(&models.TeamInvite{}).Where("team_id = ?", team.ID).Count(&invites)
+			if (invites == 1) != (tt.status == http.StatusCreated) {
+				t.Errorf("%d invites created, want created %v", invites, tt.status == http.StatusCreated)
+			}
+		})
+	}
+}
+
+// invite sends an invite to email as caller and returns the recorded response
+func invite(t *testing.T, h *AuthHandler, caller *models.User, email string) *httptest.ResponseRecorder {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/users/invite", InviteUserRequest{Email: email, Name: "Invitee", Role: string(models.UserRoleMember)})
+	c.Set("userID", caller.ID)
+	c.Set("teamID", caller.TeamID)
+	c.Set("role", string(caller.Role))
+	if err := h.InviteUser(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestInviteUserRejectsRepeatInvites(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	if rec := invite(t, h, admin, member.Email); rec.Code != http.StatusConflict {
+		t.Errorf("inviting a member got %d %s, want 409", rec.Code, rec.Body)
+	}
+
+	if rec := invite(t, h, admin, "invitee@example.com"); rec.Code != http.StatusCreated {
+		t.Fatalf("first invite got %d %s, want 201", rec.Code, rec.Body)
+	}
+	if rec := invite(t, h, admin, "invitee@example.com"); rec.Code != http.StatusConflict {
+		t.Errorf("inviting a pending invitee got %d %s, want 409", rec.Code, rec.Body)
+	}
+
+	var invites int64
+	gdb.Model(&models.TeamInvite{}).Where("team_id = ?", team.ID).Count(&invites)
+	if invites != 1 {
+		t.Errorf("%d invites, want the first one only", invites)
+	}
+}
+
+func TestLatestPendingInviteWins(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	for _, team := range []*models.Team{acme, globex} {
+		if rec := invite(t, h, testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin), "grace@example.com"); rec.Code != http.StatusCreated {
+			t.Fatalf("invite to %s got %d %s, want 201", team.Name, rec.Code, rec.Body)
+		}
+	}
+	// ⏳ Acme's invite is the older one
+	if err := gdb.Model(&models.TeamInvite{}).Where("team_id = ?", acme.ID).
+		Update("created_at", time.Now().UTC().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := models.GetLatestPendingInvite("grace@example.com", gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.TeamID != globex.ID {
+		t.Errorf("latest invite is to team %s, want Globex", latest.TeamID)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/register", registerRequest("grace@example.com"))
+	expectStatus(t, rec, h.Register(c), http.StatusCreated)
+	var user models.User
+	if err := gdb.Where("email = ?", "grace@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TeamID != globex.ID {
+		t.Errorf("registered into team %s, want Globex", user.TeamID)
+	}
+}
+
+// softDelete marks a row deleted the way the services do
+func softDelete(t *testing.T, gdb *gorm.DB, model interface{}) {
+	t.Helper()
+	if err := gdb.Model(model).Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSoftDeletedUserCannotSignIn(t *testing.T) {
+	gdb, h, user, token := signedIn(t)
+	softDelete(t, gdb, user)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+	expectStatus(t, rec, h.Login(c), http.StatusUnauthorized)
+	if status, body := refresh(t, h, token); status != http.StatusUnauthorized {
+		t.Errorf("refresh got %d %v, want 401", status, body)
+	}
+}
+
+func TestDeletedTeamRejectsItsMembers(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+	expectStatus(t, rec, h.Login(c), http.StatusOK)
+	tokens := decode(t, rec)
+	access, _ := tokens["token"].(string)
+	refreshToken, _ := tokens["refresh_token"].(string)
+	if rec := invite(t, h, user, "invitee@example.com"); rec.Code != http.StatusCreated {
+		t.Fatalf("invite got %d %s, want 201", rec.Code, rec.Body)
+	}
+	var pending models.TeamInvite
+	if err := gdb.Where("team_id = ?", team.ID).First(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/users/me", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+		middleware.NewAuthMiddleware(testutil.JWTSecret).Middleware())
+	me := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := me(); rec.Code != http.StatusOK {
+		t.Fatalf("access token of a live team got %d %s, want 200", rec.Code, rec.Body)
+	}
+
+	softDelete(t, gdb, team)
+
+	t.Run("login", func(t *testing.T) {
+		c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+		expectStatus(t, rec, h.Login(c), http.StatusUnauthorized)
+	})
+	t.Run("refresh", func(t *testing.T) {
+		if status, body := refresh(t, h, refreshToken); status != http.StatusUnauthorized {
+			t.Errorf("got %d %v, want 401", status, body)
+		}
+	})
+	t.Run("access token", func(t *testing.T) {
+		if rec := me(); rec.Code != http.StatusUnauthorized {
+			t.Errorf("got %d %s, want 401", rec.Code, rec.Body)
+		}
+	})
+	t.Run("accept invite", func(t *testing.T) {
+		c, rec := newContext(t, http.MethodPost, "/auth/accept/"+pending.Code, AcceptInviteRequest{Password: testutil.Password})
+		c.SetParamNames("code")
+		c.SetParamValues(pending.Code)
+		expectStatus(t, rec, h.AcceptInvite(c), http.StatusBadRequest)
+
+		var users int64
+		gdb.Model(&models.User{}).Where("email = ?", pending.Email).Count(&users)
+		if users != 0 {
+			t.Errorf("%d accounts created through an invite to a deleted team", users)
+		}
+	})
+}
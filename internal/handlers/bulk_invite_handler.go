@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBulkInvites caps the rows of one bulk invite
+const maxBulkInvites = 500
+
+// Row statuses of a bulk invite
+const (
+	BulkInviteInvited = "invited"
+	BulkInviteSkipped = "skipped"
+	BulkInviteInvalid = "invalid"
+)
+
+// BulkInviteRow is one person to invite. Role defaults to MEMBER.
+type BulkInviteRow struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"required,min=2"`
+	Role  string `json:"role" validate:"omitempty,oneof=MEMBER ADMIN"`
+}
+
+// BulkInviteResult reports what happened to one row
+type BulkInviteResult struct {
+	// Row is the line of the CSV file, or the position in the JSON array starting at 1
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Status string `json:"status"` // invited, skipped or invalid
+	Reason string `json:"reason,omitempty"`
+}
+
+// BulkInviteSummary counts the rows per status
+type BulkInviteSummary struct {
+	Total   int `json:"total"`
+	Invited int `json:"invited"`
+	Skipped int `json:"skipped"`
+	Invalid int `json:"invalid"`
+}
+
+// BulkInviteResponse is the per-row report of a bulk invite
+type BulkInviteResponse struct {
+	Results []BulkInviteResult `json:"results"`
+	Summary BulkInviteSummary  `json:"summary"`
+}
+
+// errMemberQuotaExceeded is returned inside the invite transaction when the team would grow past its quota
+var errMemberQuotaExceeded = errors.New("member quota exceeded")
+
+// BulkInviteUsers invites many people to the current team at once
+// @Summary Invite users in bulk
+// @Description Invite up to 500 people to the current team, from a JSON array of {email, name, role} or a CSV file uploaded as "file" with an email,name,role header. Rows with invalid data, roles above the caller's, emails already on the team or with a pending invitation are reported and skipped, the rest are invited in one transaction and emailed. Requires team_invites:create.
+// @Tags auth
+// @Accept json,mpfd
+// @Produce json
+// @Param request body []BulkInviteRow false "People to invite"
+// @Param file formData file false "CSV file with an email,name,role header"
+// @Success 200 {object} BulkInviteResponse "Per-row results and summary"
+// @Failure 400 {object} map[string]string "Invalid body or CSV file"
+// @Failure 422 {object} map[string]interface{} "Member quota exceeded"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/invite/bulk [post]
+func (h *AuthHandler) BulkInviteUsers(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+	teamID := c.Get("teamID").(string)
+
+	rows, lines, err := readBulkInviteRows(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No invitations to send"})
+	}
+	if len(rows) > maxBulkInvites {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("At most %d invitations can be sent at once", maxBulkInvites)})
+	}
+
+	// 🔍 Rows are validated on their own, invalid ones do not stop the rest
+	callerRank := models.UserRole(middleware.GetUserRole(c)).Rank()
+	results := make([]BulkInviteResult, len(rows))
+	seen := make(map[string]bool, len(rows))
+	var emails []string
+	for i := range rows {
+		row := &rows[i]
+		row.Email = strings.TrimSpace(row.Email)
+		row.Name = strings.TrimSpace(row.Name)
+		row.Role = strings.ToUpper(strings.TrimSpace(row.Role))
+		if row.Role == "" {
+			row.Role = string(models.UserRoleMember)
+		}
+
+		results[i] = BulkInviteResult{Row: lines[i], Email: row.Email}
+		if err := c.Validate(row); err != nil {
+			results[i].Status, results[i].Reason = BulkInviteInvalid, err.Error()
+		} else if models.UserRole(row.Role).Rank() > callerRank {
+			results[i].Status, results[i].Reason = BulkInviteInvalid, "role is above your own"
+		} else if seen[row.Email] {
+			results[i].Status, results[i].Reason = BulkInviteSkipped, "duplicate of an earlier row"
+		} else {
+			seen[row.Email] = true
+			emails = append(emails, row.Email)
+		}
+	}
+
+	var invites []models.TeamInvite
+	var seats int64
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// 🔒 Concurrent invites to the same team are serialised so they cannot both slip under the quota
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("id = ? AND is_deleted = false", teamID).First(&models.Team{}).Error; err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+
+		// ⏳ Expired invites no longer block a new one
+		if err := models.ExpireStaleTeamInvites(teamID, emails, tx); err != nil {
+			return err
+		}
+
+		var members, pending []string
+		if err := tx.Model(&models.User{}).Where("team_id = ? AND email IN ? AND is_deleted = false", teamID, emails).
+			Pluck("email", &members).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamInvite{}).
+			Where("team_id = ? AND email IN ? AND status = ? AND is_deleted = false", teamID, emails, models.InviteStatusPending).
+			Pluck("email", &pending).Error; err != nil {
+			return err
+		}
+		skip := make(map[string]string, len(members)+len(pending))
+		for _, email := range pending {
+			skip[email] = "a pending invitation already exists"
+		}
+		for _, email := range members {
+			skip[email] = "already a member of the team"
+		}
+
+		expiresAt := time.Now().UTC().Add(models.InviteLifetime)
+		for i, row := range rows {
+			if results[i].Status != "" {
+				continue
+			}
+			if reason, ok := skip[row.Email]; ok {
+				results[i].Status, results[i].Reason = BulkInviteSkipped, reason
+				continue
+			}
+
+			code, err := utils.GenerateRandomString(32)
+			if err != nil {
+				return err
+			}
+			results[i].Status = BulkInviteInvited
+			invites = append(invites, models.TeamInvite{
+				Code:      code,
+				ExpiresAt: expiresAt,
+				InviterID: userID,
+				TeamID:    teamID,
+				Status:    models.InviteStatusPending,
+				Role:      models.UserRole(row.Role),
+				Email:     row.Email,
+				Name:      row.Name,
+			})
+		}
+		if len(invites) == 0 {
+			return nil
+		}
+
+		// 👥 The quota counts members, pending invites and the new ones together
+		var err error
+		if seats, err = models.CountTeamSeats(teamID, tx); err != nil {
+			return err
+		}
+		if h.maxTeamMembers > 0 && seats+int64(len(invites)) > int64(h.maxTeamMembers) {
+			return errMemberQuotaExceeded
+		}
+
+		return tx.Create(&invites).Error
+	})
+	switch {
+	case errors.Is(err, errMemberQuotaExceeded):
+		return memberQuotaExceeded(c, h.maxTeamMembers, seats, len(invites))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A pending invitation was created concurrently, try again"})
+	case err != nil:
+		h.log.Error("Failed to create bulk invitations: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create invitations"})
+	}
+
+	// ✉️ Emails are queued once the invitations are committed
+	h.sendInvites(c, invites, false)
+
+	response := BulkInviteResponse{Results: results, Summary: BulkInviteSummary{Total: len(results)}}
+	for _, result := range results {
+		switch result.Status {
+		case BulkInviteInvited:
+			response.Summary.Invited++
+		case BulkInviteSkipped:
+			response.Summary.Skipped++
+		case BulkInviteInvalid:
+			response.Summary.Invalid++
+		}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// memberQuotaExceeded rejects invitations that would grow the team past AUTH_MAX_TEAM_MEMBERS
+func memberQuotaExceeded(c echo.Context, limit int, seats int64, requested int) error {
+	return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":     "The team member limit would be exceeded",
+		"code":      "member_quota_exceeded",
+		"limit":     limit,
+		"current":   seats,
+		"requested": requested,
+	})
+}
+
+// readBulkInviteRows reads the rows of a bulk invite from a CSV upload or a JSON array, with the
+// line or position of each row
+func readBulkInviteRows(c echo.Context) ([]BulkInviteRow, []int, error) {
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		var rows []BulkInviteRow
+		if err := (&echo.DefaultBinder{}).BindBody(c, &rows); err != nil {
+			return nil, nil, errors.New("body must be a JSON array of {email, name, role}")
+		}
+		lines := make([]int, len(rows))
+		for i := range rows {
+			lines[i] = i + 1
+		}
+		return rows, lines, nil
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, nil, errors.New("a CSV file is required in the file field")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, errors.New("failed to read the CSV file")
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("the CSV file needs an email,name,role header")
+	}
+	columns := map[string]int{"email": -1, "name": -1, "role": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["email"] < 0 || columns["name"] < 0 {
+		return nil, nil, errors.New("the CSV header needs email and name columns")
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i >= 0 && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []BulkInviteRow
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV file: %v", err)
+		}
+		// More rows than allowed are rejected without reading the rest of the file
+		if len(rows) > maxBulkInvites {
+			break
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, BulkInviteRow{Email: field(record, "email"), Name: field(record, "name"), Role: field(record, "role")})
+		lines = append(lines, line)
+	}
+	return rows, lines, nil
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/api/validator"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func TestBulkInviteSkipsRolesAboveTheCaller(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	// A member granted team_invites:create can only invite members
+	caller := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/invite/bulk", []BulkInviteRow{
+		{Email: "member@example.com", Name: "Member", Role: string(models.UserRoleMember)},
+		{Email: "admin@example.com", Name: "Admin", Role: string(models.UserRoleAdmin)},
+	})
+	c.Set("userID", caller.ID)
+	c.Set("teamID", team.ID)
+	c.Set("role", string(caller.Role))
+	expectStatus(t, rec, h.BulkInviteUsers(c), http.StatusOK)
+
+	results, _ := decode(t, rec)["results"].([]interface{})
+	want := []string{BulkInviteInvited, BulkInviteInvalid}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if status := result.(map[string]interface{})["status"]; status != want[i] {
+			t.Errorf("row %d: status %v, want %s", i+1, status, want[i])
+		}
+	}
+
+	var adminInvites int64
+	gdb.Model(&models.TeamInvite{}).Where("role = ?", models.UserRoleAdmin).Count(&adminInvites)
+	if adminInvites != 0 {
+		t.Errorf("%d admin invites created, want none", adminInvites)
+	}
+}
+
+// bulkInvite sends a bulk invite as the team's admin and returns the status and body. A string
+// body is uploaded as the CSV file, anything else is sent as JSON.
+func bulkInvite(t *testing.T, h *AuthHandler, admin *models.User, body interface{}) (int, BulkInviteResponse, map[string]interface{}) {
+	t.Helper()
+	var c echo.Context
+	var rec *httptest.ResponseRecorder
+	if csvFile, ok := body.(string); ok {
+		var form bytes.Buffer
+		writer := multipart.NewWriter(&form)
+		part, err := writer.CreateFormFile("file", "invites.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(csvFile))
+		writer.Close()
+
+		e := echo.New()
+		e.Validator = validator.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/auth/invite/bulk", &form)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		rec = httptest.NewRecorder()
+		c = e.NewContext(req, rec)
+	} else {
+		c, rec = newContext(t, http.MethodPost, "/auth/invite/bulk", body)
+	}
+	c.Set("userID", admin.ID)
+	c.Set("teamID", admin.TeamID)
+	c.Set("role", string(admin.Role))
+	if err := h.BulkInviteUsers(c); err != nil {
+		t.Fatal(err)
+	}
+
+	var response BulkInviteResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, response, nil
+	}
+	return rec.Code, response, decode(t, rec)
+}
+
+// pendingInvites lists the emails with a pending invite to the team
+func pendingInvites(t *testing.T, gdb *gorm.DB, teamID string) []string {
+	t.Helper()
+	var emails []string
+	if err := gdb.Model(&models.TeamInvite{}).Where("team_id = ? AND status = ?", teamID, models.InviteStatusPending).
+		Order("email").Pluck("email", &emails).Error; err != nil {
+		t.Fatal(err)
+	}
+	return emails
+}
+
+func TestBulkInviteReportsEveryRow(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	for i, invite := range []models.TeamInvite{
+		{Email: "pending@example.com", ExpiresAt: time.Now().UTC().Add(time.Hour)},
+		{Email: "lapsed@example.com", ExpiresAt: time.Now().UTC().Add(-time.Hour)},
+	} {
+		invite.Name, invite.TeamID, invite.InviterID = "Invitee", team.ID, admin.ID
+		invite.Role, invite.Status, invite.Code = models.UserRoleMember, models.InviteStatusPending, fmt.Sprintf("code-%d", i)
+		if err := gdb.Create(&invite).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, response, _ := bulkInvite(t, h, admin, []BulkInviteRow{
+		{Email: " ada@example.com ", Name: "Ada", Role: "admin"},
+		{Email: "not-an-email", Name: "Bob"},
+		{Email: "ada@example.com", Name: "Ada again"},
+		{Email: member.Email, Name: "Member"},
+		{Email: "pending@example.com", Name: "Pending"},
+		{Email: "lapsed@example.com", Name: "Lapsed"},
+		{Email: "owner@example.com", Name: "Owner", Role: string(models.UserRoleSuperAdmin)},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+
+	want := []BulkInviteResult{
+		{Row: 1, Email: "ada@example.com", Status: BulkInviteInvited},
+		{Row: 2, Email: "not-an-email", Status: BulkInviteInvalid},
+		{Row: 3, Email: "ada@example.com", Status: BulkInviteSkipped, Reason: "duplicate of an earlier row"},
+		{Row: 4, Email: member.Email, Status: BulkInviteSkipped, Reason: "already a member of the team"},
+		{Row: 5, Email: "pending@example.com", Status: BulkInviteSkipped, Reason: "a pending invitation already exists"},
+		// ⏳ An expired invite is closed and replaced
+		{Row: 6, Email: "lapsed@example.com", Status: BulkInviteInvited},
+		{Row: 7, Email: "owner@example.com", Status: BulkInviteInvalid},
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(response.Results), len(want))
+	}
+	for i, got := range response.Results {
+		if got.Row != want[i].Row || got.Email != want[i].Email || got.Status != want[i].Status {
+			t.Errorf("row %d: got %+v, want %+v", i+1, got, want[i])
+		}
+		if want[i].Reason != "" && got.Reason != want[i].Reason {
+			t.Errorf("row %d: reason %q, want %q", i+1, got.Reason, want[i].Reason)
+		}
+		if got.Status == BulkInviteInvalid && got.Reason == "" {
+			t.Errorf("row %d is invalid without a reason", i+1)
+		}
+	}
+	if response.Summary != (BulkInviteSummary{Total: 7, Invited: 2, Skipped: 3, Invalid: 2}) {
+		t.Errorf("summary %+v", response.Summary)
+	}
+
+	if got := pendingInvites(t, gdb, team.ID); fmt.Sprint(got) != "[ada@example.com lapsed@example.com pending@example.com]" {
+		t.Errorf("pending invites %v", got)
+	}
+	var ada models.TeamInvite
+	if err := gdb.First(&ada, "email = ?", "ada@example.com").Error; err != nil {
+		t.Fatal(err)
+	}
+	if ada.Role != models.UserRoleAdmin || ada.InviterID != admin.ID || !ada.ExpiresAt.After(time.Now().UTC().Add(models.InviteLifetime-time.Minute)) {
+		t.Errorf("invite stored as %+v", ada)
+	}
+}
+
+func TestBulkInviteReadsACSVFile(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	// 📄 Columns in any order behind a byte order mark, rows are reported by their line
+	status, response, _ := bulkInvite(t, h, admin, "\ufeffName, Role ,EMAIL\n"+
+		"Grace,member,grace@example.com\n"+
+		"\n"+
+		",,\n"+
+		"\"Hopper, Grace\",ADMIN,hopper@example.com\n"+
+		"Linus,,linus@example.com\n")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	lines := map[string]int{}
+	for _, result := range response.Results {
+		if result.Status != BulkInviteInvited {
+			t.Errorf("line %d: %s %s", result.Row, result.Status, result.Reason)
+		}
+		lines[result.Email] = result.Row
+	}
+	if lines["grace@example.com"] != 2 || lines["hopper@example.com"] != 5 || lines["linus@example.com"] != 6 {
+		t.Errorf("rows reported at lines %v", lines)
+	}
+
+	var hopper models.TeamInvite
+	if err := gdb.First(&hopper, "email = ?", "hopper@example.com").Error; err != nil {
+		t.Fatal(err)
+	}
+	if hopper.Name != "Hopper, Grace" || hopper.Role != models.UserRoleAdmin {
+		t.Errorf("hopper invited as %q with role %s", hopper.Name, hopper.Role)
+	}
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"header without a name column", "email,role\nada@example.com,MEMBER\n"},
+		{"header only", "email,name,role\n"},
+		{"empty file", ""},
+		{"JSON object instead of an array", map[string]string{"email": "ada@example.com", "name": "Ada"}},
+		{"too many rows", make([]BulkInviteRow, maxBulkInvites+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _, body := bulkInvite(t, h, admin, tt.body); status != http.StatusBadRequest {
+				t.Errorf("status %d %v, want 400", status, body)
+			}
+		})
+	}
+}
+
+func TestBulkInviteStaysWithinTheMemberQuota(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	h.maxTeamMembers = 3
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	// 👥 The admin holds one seat, three more rows would take the team to four
+	rows := []BulkInviteRow{
+		{Email: "a@example.com", Name: "Ada"},
+		{Email: "b@example.com", Name: "Bob"},
+		{Email: "c@example.com", Name: "Cy"},
+	}
+	status, _, body := bulkInvite(t, h, admin, rows)
+	if status != http.StatusUnprocessableEntity || body["code"] != "member_quota_exceeded" ||
+		body["current"] != float64(1) || body["requested"] != float64(3) {
+		t.Fatalf("status %d %v, want 422 member_quota_exceeded", status, body)
+	}
+	if got := pendingInvites(t, gdb, team.ID); len(got) != 0 {
+		t.Errorf("invites %v created past the quota", got)
+	}
+
+	// Skipped rows do not count towards the quota
+	rows[2].Email = rows[0].Email
+	if status, response, body := bulkInvite(t, h, admin, rows); status != http.StatusOK || response.Summary.Invited != 2 {
+		t.Fatalf("status %d %v %+v, want two invited", status, body, response.Summary)
+	}
+	if status, _, _ := bulkInvite(t, h, admin, []BulkInviteRow{{Email: "d@example.com", Name: "Dee"}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("an invite to a full team got %d, want 422", status)
+	}
+}
+
+func TestBulkInviteToAnotherTeamsInvitees(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	acmeAdmin := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleAdmin)
+	globexAdmin := testutil.CreateUser(t, gdb, globex.ID, models.UserRoleAdmin)
+
+	// 🏢 Members and invites of another team do not block an invite to this one
+	rows := []BulkInviteRow{{Email: "shared@example.com", Name: "Shared"}, {Email: globexAdmin.Email, Name: "Globex admin"}}
+	if status, response, _ := bulkInvite(t, h, globexAdmin, rows[:1]); status != http.StatusOK || response.Summary.Invited != 1 {
+		t.Fatalf("status %d %+v", status, response.Summary)
+	}
+	status, response, _ := bulkInvite(t, h, acmeAdmin, rows)
+	if status != http.StatusOK || response.Summary.Invited != 2 {
+		t.Errorf("status %d %+v, want both rows invited", status, response.Results)
+	}
+	if got := pendingInvites(t, gdb, acme.ID); len(got) != 2 {
+		t.Errorf("acme invites %v", got)
+	}
+}
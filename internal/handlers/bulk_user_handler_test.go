@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newTestBulkUserHandler returns a BulkUserHandler queueing jobs in an in-memory Redis
+func newTestBulkUserHandler(t *testing.T, gdb *gorm.DB) *BulkUserHandler {
+	t.Helper()
+	_, server := testutil.NewRedis(t)
+	client := tasks.NewTaskClient(config.RedisConfig{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewBulkUserHandler(gdb, client)
+}
+
+// signInAt records a session of the user created at the given time
+func signInAt(t *testing.T, gdb *gorm.DB, user *models.User, at time.Time, impersonatedBy *string) {
+	t.Helper()
+	session := models.AuthTransaction{
+		Base:           models.Base{CreatedAt: models.NewTimestamp(at)},
+		UserID:         user.ID,
+		TeamID:         user.TeamID,
+		ExpiresAt:      at.Add(models.SessionLifetime),
+		ImpersonatedBy: impersonatedBy,
+	}
+	if err := gdb.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkUserActionQueuesAJob(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestBulkUserHandler(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	c, rec := newContext(t, http.MethodPost, "/admin/users/bulk", BulkUserActionRequest{
+		Action:  string(models.BulkUserDeactivate),
+		UserIDs: []string{member.ID, member.ID},
+	})
+	c.Set("userID", admin.ID)
+	expectStatus(t, rec, h.BulkUserAction(c), http.StatusAccepted)
+
+	var job models.Job
+	if err := gdb.First(&job, "id = ?", decode(t, rec)["id"]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != models.JobTypeBulkUserAction || job.Status != models.JobStatusQueued || job.Total != 1 ||
+		job.UserID == nil || *job.UserID != admin.ID {
+		t.Errorf("got job %+v", job)
+	}
+	// Nothing happens before the worker runs the job
+	if !reloadUser(t, gdb, member.ID).IsActive {
+		t.Error("member deactivated before the job ran")
+	}
+}
+
+func TestBulkUserActionValidation(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestBulkUserHandler(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+	empty := testutil.CreateTeam(t, gdb, "Globex")
+
+	tests := []struct {
+		name string
+		req  BulkUserActionRequest
+	}{
+		{"unknown action", BulkUserActionRequest{Action: "delete", UserIDs: []string{admin.ID}}},
+		{"no users", BulkUserActionRequest{Action: "deactivate"}},
+		{"empty filter", BulkUserActionRequest{Action: "deactivate", Filter: &BulkUserFilter{}}},
+		{"ids and filter", BulkUserActionRequest{Action: "deactivate", UserIDs: []string{admin.ID}, Filter: &BulkUserFilter{TeamID: team.ID}}},
+		{"malformed id", BulkUserActionRequest{Action: "deactivate", UserIDs: []string{"not-a-uuid"}}},
+		{"filter without matches", BulkUserActionRequest{Action: "deactivate", Filter: &BulkUserFilter{TeamID: empty.ID}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(t, http.MethodPost, "/admin/users/bulk", tt.req)
+			c.Set("userID", admin.ID)
+			expectStatus(t, rec, h.BulkUserAction(c), http.StatusBadRequest)
+		})
+	}
+
+	var jobs int64
+	if err := gdb.Model(&models.Job{}).Count(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if jobs != 0 {
+		t.Errorf("%d jobs created for invalid requests", jobs)
+	}
+}
+
+func TestBulkUserFilter(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := NewBulkUserHandler(gdb, nil)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	admin := testutil.CreateUser(t, gdb, globex.ID, models.UserRoleSuperAdmin)
+
+	cutoff := time.Now().UTC().Add(-30 * 24 * time.Hour)
+	stale := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	signInAt(t, gdb, stale, cutoff.Add(-time.Hour), nil)
+	recent := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	signInAt(t, gdb, recent, cutoff.Add(-time.Hour), nil)
+	signInAt(t, gdb, recent, cutoff.Add(time.Hour), nil)
+	never := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	impersonated := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	signInAt(t, gdb, impersonated, cutoff.Add(time.Hour), &admin.ID)
+	outsider := testutil.CreateUser(t, gdb, globex.ID, models.UserRoleMember)
+	deleted := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	if err := gdb.Model(deleted).Update("is_deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter BulkUserFilter
+		want   []string
+	}{
+		{"team", BulkUserFilter{TeamID: acme.ID}, []string{stale.ID, recent.ID, never.ID, impersonated.ID}},
+		{"last login", BulkUserFilter{LastLoginBefore: &cutoff}, []string{admin.ID, stale.ID, never.ID, impersonated.ID, outsider.ID}},
+		{"team and last login", BulkUserFilter{TeamID: acme.ID, LastLoginBefore: &cutoff}, []string{stale.ID, never.ID, impersonated.ID}},
+		{"unknown team", BulkUserFilter{TeamID: uuid.New().String()}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.filterUsers(&tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDeactivatedUserCannotSignIn(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	tokens := login(t, h, user)
+
+	if err := gdb.Model(user).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+	expectStatus(t, rec, h.Login(c), http.StatusForbidden)
+	if code := decode(t, rec)["code"]; code != "account_deactivated" {
+		t.Errorf("login answered code %v, want account_deactivated", code)
+	}
+
+	c, rec = newContext(t, http.MethodPost, "/auth/refresh", RefreshTokenRequest{RefreshToken: tokens["refresh_token"].(string)})
+	expectStatus(t, rec, h.RefreshToken(c), http.StatusForbidden)
+	if code := decode(t, rec)["code"]; code != "account_deactivated" {
+		t.Errorf("refresh answered code %v, want account_deactivated", code)
+	}
+
+	// 🔑 A wrong password still reads as invalid credentials, deactivation is not revealed
+	c, rec = newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: "wrong-password"})
+	expectStatus(t, rec, h.Login(c), http.StatusUnauthorized)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"be0/internal/config"
+	"be0/internal/db"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ConfigHandler struct {
+	cfg *config.Config
+	log *logger.Logger
+}
+
+func NewConfigHandler(cfg *config.Config) *ConfigHandler {
+	return &ConfigHandler{cfg: cfg, log: logger.New("ConfigHandler")}
+}
+
+// RuntimeConfig is the effective configuration of the running process
+type RuntimeConfig struct {
+	Config    map[string]interface{} `json:"config"`
+	Features  map[string]bool        `json:"features"`
+	Providers map[string]interface{} `json:"providers"`
+	Build     utils.BuildInfo        `json:"build"`
+	Schema    db.SchemaInfo          `json:"schema"`
+}
+
+// GetRuntimeConfig returns the configuration the process is running with
+// @Summary Get runtime configuration
+// @Description Get the effective configuration with secrets masked, feature states, enabled providers, build info and schema version. Super admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} RuntimeConfig
+// @Failure 403 {object} map[string]string "Super admin access required"
+// @Router /api/v1/admin/config [get]
+func (h *ConfigHandler) GetRuntimeConfig(c echo.Context) error {
+	schema, err := db.Schema()
+	if err != nil {
+		h.log.Warn("Failed to compute schema version: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, RuntimeConfig{
+		Config:    h.cfg.Redacted(),
+		Features:  h.cfg.Features(),
+		Providers: Providers(h.cfg),
+		Build:     utils.GetBuildInfo(),
+		Schema:    schema,
+	})
+}
+
+// Providers reports the storage, OAuth and mailer backends registered in this process
+func Providers(cfg *config.Config) map[string]interface{} {
+	storage := "none"
+	if GetStorageHandler() != nil {
+		storage = "s3"
+	}
+
+	cdn := "none"
+	if cfg.Storage.CDN.BaseURL != "" {
+		cdn = cfg.Storage.CDN.Provider
+	}
+
+	return map[string]interface{}{
+		"storage": map[string]interface{}{
+			"backend": storage,
+			"cdn":     cdn,
+			"replica": cfg.Storage.S3.ReplicaBucket != "",
+		},
+		"oauth":  []string{string(models.AuthMethodGoogle), string(models.AuthMethodMicrosoft)},
+		"mailer": mailer.HasSender(),
+		"cdc":    cfg.CDC.Broker,
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"be0/internal/api/middleware"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+type DebugHandler struct {
+	store *middleware.DebugCaptureStore
+	log   *logger.Logger
+}
+
+func NewDebugHandler(store *middleware.DebugCaptureStore) *DebugHandler {
+	return &DebugHandler{store: store, log: logger.New("DebugHandler")}
+}
+
+// GetCapturedRequest returns a captured request/response pair
+// @Summary Get captured request
+// @Description Get the sanitized request and response captured in debug mode. Super admin only.
+// @Tags admin
+// @Produce json
+// @Param requestId path string true "Request ID"
+// @Success 200 {object} middleware.CapturedRequest
+// @Failure 403 {object} map[string]string "Super admin access required"
+// @Failure 404 {object} map[string]string "Capture not found or expired"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/requests/{requestId} [get]
+func (h *DebugHandler) GetCapturedRequest(c echo.Context) error {
+	record, err := h.store.Get(c.Request().Context(), c.Param("requestId"))
+	if errors.Is(err, redis.Nil) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Capture not found or expired"})
+	}
+	if err != nil {
+		h.log.Error("Failed to load debug capture", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load capture"})
+	}
+
+	return c.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+func TestDebugCaptureRedactsSecretsForEveryCaller(t *testing.T) {
+	client, server := testutil.NewRedis(t)
+	store := middleware.NewDebugCaptureStore(client, middleware.DebugCaptureConfig{
+		Enabled:     true,
+		Teams:       []string{"captured-team"},
+		RedactPaths: []string{"password", "token"},
+		MaxBodySize: 64 * 1024,
+		TTL:         time.Minute,
+		BufferSize:  10,
+	})
+
+	e := echo.New()
+	e.Use(echomiddleware.RequestID())
+	// Stands in for the auth middleware identifying the caller
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("role", c.Request().Header.Get("X-Test-Role"))
+			c.Set("teamID", c.Request().Header.Get("X-Test-Team"))
+			return next(c)
+		}
+	})
+	e.Use(middleware.DebugCapture(store))
+	e.POST("/login", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{"token": "tok-s3cret", "user": map[string]string{"email": "ada@example.com"}})
+	})
+	e.GET("/admin/requests/:requestId", NewDebugHandler(store).GetCapturedRequest, middleware.RequireSuperAdmin())
+
+	login := func(role, team string, capture bool) string {
+		req := httptest.NewRequest(http.MethodPost, "/login?token=query-s3cret&next=/home", strings.NewReader(`{"email":"ada@example.com","password":"hunter2"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer header-s3cret")
+		req.Header.Set("X-Test-Role", role)
+		req.Header.Set("X-Test-Team", team)
+		if capture {
+			req.Header.Set(middleware.DebugCaptureHeader, "1")
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("login got %d", rec.Code)
+		}
+		return rec.Header().Get(echo.HeaderXRequestID)
+	}
+	fetch := func(role, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/requests/"+requestID, nil)
+		req.Header.Set("X-Test-Role", role)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	superAdmin, member := string(models.UserRoleSuperAdmin), string(models.UserRoleMember)
+
+	// 🙅 Only a super admin can ask for a capture, a member's header is ignored
+	if rec := fetch(superAdmin, login(member, "other-team", true)); rec.Code != http.StatusNotFound {
+		t.Errorf("a member's request was captured, fetching it got %d", rec.Code)
+	}
+
+	for _, requestID := range []string{login(superAdmin, "other-team", true), login(member, "captured-team", false)} {
+		// 🔒 Secrets never reach Redis, whoever reads the capture later
+		for _, key := range server.Keys() {
+			if server.Type(key) != "string" {
+				continue
+			}
+			stored, _ := server.Get(key)
+			for _, secret := range []string{"hunter2", "tok-s3cret", "query-s3cret", "header-s3cret"} {
+				if strings.Contains(stored, secret) {
+					t.Errorf("%s stores %q", key, secret)
+				}
+			}
+		}
+
+		if rec := fetch(member, requestID); rec.Code != http.StatusForbidden {
+			t.Errorf("a member fetching a capture got %d, want 403", rec.Code)
+		}
+		rec := fetch(superAdmin, requestID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("a super admin fetching a capture got %d", rec.Code)
+		}
+		var record middleware.CapturedRequest
+		if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.RequestBody != `{"email":"ada@example.com","password":"[REDACTED]"}` {
+			t.Errorf("request body %s", record.RequestBody)
+		}
+		if record.ResponseBody != `{"token":"[REDACTED]","user":{"email":"ada@example.com"}}` {
+			t.Errorf("response body %s", record.ResponseBody)
+		}
+		if record.Query != "next=%2Fhome&token=%5BREDACTED%5D" {
+			t.Errorf("query %s", record.Query)
+		}
+		if record.Headers[echo.HeaderAuthorization] != "[REDACTED]" {
+			t.Errorf("Authorization header stored as %q", record.Headers[echo.HeaderAuthorization])
+		}
+	}
+}
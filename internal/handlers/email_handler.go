@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxEmailsPageSize caps the email logs of one page
+const maxEmailsPageSize = 100
+
+// emailStatuses are the statuses ListEmails filters on
+var emailStatuses = map[models.EmailStatus]bool{
+	models.EmailStatusQueued:     true,
+	models.EmailStatusRetrying:   true,
+	models.EmailStatusSent:       true,
+	models.EmailStatusFailed:     true,
+	models.EmailStatusSuppressed: true,
+	models.EmailStatusSandboxed:  true,
+}
+
+type EmailHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewEmailHandler(db *gorm.DB) *EmailHandler {
+	return &EmailHandler{db: db, log: logger.New("EmailHandler")}
+}
+
+// ListEmails lists the delivery logs of the emails sent to the caller's team
+// @Summary List email deliveries
+// @Description List the emails sent for the caller's team with their message ID, status, attempts and the last SMTP error, newest first, in the data/total/page/limit envelope of the CRUD lists with at most 100 emails per page. Admins only.
+// @Tags emails
+// @Produce json
+// @Param status query string false "queued, retrying, sent, failed, suppressed or sandboxed"
+// @Param to query string false "Recipient address"
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size, at most 100"
+// @Success 200 {object} map[string]interface{} "data, total, page and limit"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 403 {object} map[string]string "Admin access required"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/emails [get]
+func (h *EmailHandler) ListEmails(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "status", "to", "page", "limit"); err != nil {
+		return err
+	}
+	// 🔒 Admins see their team's emails
+	if models.UserRole(middleware.GetUserRole(c)).Rank() < models.UserRoleAdmin.Rank() {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Admin access required"})
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > maxEmailsPageSize {
+		limit = maxEmailsPageSize
+	}
+
+	query := h.db.Model(&models.EmailLog{}).Where("team_id = ? AND is_deleted = false", middleware.GetTeamID(c))
+	if status := c.QueryParam("status"); status != "" {
+		if !emailStatuses[models.EmailStatus(status)] {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid status"})
+		}
+		query = query.Where("status = ?", status)
+	}
+	if to := c.QueryParam("to"); to != "" {
+		query = query.Where("\"to\" = ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to count emails: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch emails"})
+	}
+
+	emails := []models.EmailLog{}
+	if err := query.Order("created_at DESC").Order("id DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&emails).Error; err != nil {
+		h.log.FromContext(c.Request().Context()).Error("Failed to list emails: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch emails"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  emails,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
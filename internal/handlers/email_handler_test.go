@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestListEmails(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Other")
+	emails := []models.EmailLog{
+		{MessageID: "a", TeamID: team.ID, To: "jane@example.com", Subject: "Hi", Status: models.EmailStatusSent, Attempts: 1},
+		{MessageID: "b", TeamID: team.ID, To: "gone@example.com", Subject: "Hi", Status: models.EmailStatusFailed, Attempts: 1, SMTPCode: 550},
+		{MessageID: "c", TeamID: other.ID, To: "bob@example.com", Subject: "Hi", Status: models.EmailStatusFailed, Attempts: 3, SMTPCode: 451},
+	}
+	if err := gdb.Create(&emails).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewEmailHandler(gdb)
+	list := func(role models.UserRole, query string) (map[string]interface{}, int) {
+		c, rec := newContext(t, http.MethodGet, "/api/v1/emails?"+query, nil)
+		c.Set("teamID", team.ID)
+		c.Set("role", string(role))
+		if err := h.ListEmails(c); err != nil {
+			t.Fatal(err)
+		}
+		return decode(t, rec), rec.Code
+	}
+
+	body, status := list(models.UserRoleAdmin, "status=failed")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	data := body["data"].([]interface{})
+	if body["total"] != float64(1) || len(data) != 1 {
+		t.Fatalf("got %v, want the team's failed email", body)
+	}
+	if email := data[0].(map[string]interface{}); email["messageId"] != "b" || email["smtpCode"] != float64(550) {
+		t.Errorf("got %v", email)
+	}
+
+	if _, status := list(models.UserRoleAdmin, "status=lost"); status != http.StatusBadRequest {
+		t.Errorf("unknown status answered %d, want 400", status)
+	}
+	if _, status := list(models.UserRoleMember, ""); status != http.StatusForbidden {
+		t.Errorf("member answered %d, want 403", status)
+	}
+}
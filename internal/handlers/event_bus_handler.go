@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"be0/internal/api/middleware"
+	"be0/internal/events"
+	"be0/internal/utils/logger"
+	"be0/internal/webhooks"
+
+	"github.com/labstack/echo/v4"
+)
+
+type EventBusHandler struct {
+	log *logger.Logger
+}
+
+func NewEventBusHandler() *EventBusHandler {
+	return &EventBusHandler{log: logger.New("EventBusHandler")}
+}
+
+// TestEventRequest selects the handler to invoke and, optionally, the payload
+type TestEventRequest struct {
+	Handler string `json:"handler" validate:"required"`
+	// Payload replaces the catalogue sample, handlers receive it as decoded JSON
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ListEvents lists the events with registered handlers
+// @Summary List event bus subscriptions
+// @Description List events, their handlers and per-handler invocations, panics and average latency since startup. Super admin only.
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Events per page" default(50)
+// @Success 200 {object} map[string]interface{} "Subscriptions with total, page and limit"
+// @Failure 403 {object} map[string]string "Super admin access required"
+// @Router /api/v1/admin/events [get]
+func (h *EventBusHandler) ListEvents(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "page", "limit"); err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+
+	subscriptions := events.Subscriptions()
+	start := min((page-1)*limit, len(subscriptions))
+	end := min(start+limit, len(subscriptions))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  subscriptions[start:end],
+		"total": len(subscriptions),
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// TestEvent invokes one handler of an event with a dry-run payload
+// @Summary Test an event handler
+// @Description Invoke a handler synchronously with the event's catalogue sample or the given payload. Handlers see a dry run and skip side effects such as enqueueing webhook deliveries. Super admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param event path string true "Event name, e.g. users.created"
+// @Param request body TestEventRequest true "Handler and optional payload"
+// @Success 200 {object} events.TestResult
+// @Failure 400 {object} map[string]string "Invalid request or no sample payload for the event"
+// @Failure 403 {object} map[string]string "Super admin access required"
+// @Failure 404 {object} map[string]string "Handler not found"
+// @Router /api/v1/admin/events/{event}/test [post]
+func (h *EventBusHandler) TestEvent(c echo.Context) error {
+	var req TestEventRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	event := c.Param("event")
+
+	// 🧪 Typed sample from the event catalogue, unless the caller brought a payload
+	var payload interface{}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "payload must be valid JSON"})
+		}
+	} else if def, ok := webhooks.Lookup(event); ok {
+		payload = def.Sample()
+	} else {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No sample payload for this event, pass one in payload"})
+	}
+
+	result, err := events.Test(event, req.Handler, payload)
+	if errors.Is(err, events.ErrHandlerNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Handler not found"})
+	}
+	if err != nil {
+		h.log.Error("Failed to test event handler: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to test event handler"})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"be0/internal/events"
+)
+
+func TestTestEventInvokesTheNamedHandler(t *testing.T) {
+	// The default bus lives for the whole test binary, the event name keeps it apart
+	const event = "handlers_test.event_bus"
+	var received interface{}
+	events.OnNamed(event, "recorder", func(data interface{}) {
+		payload, dryRun := events.Unwrap(data)
+		received = payload
+		if payload == nil {
+			dryRun.Fail(errors.New("empty payload"))
+		}
+	})
+	h := NewEventBusHandler()
+
+	testEvent := func(req TestEventRequest) (int, map[string]interface{}) {
+		c, rec := newContext(t, http.MethodPost, "/api/v1/admin/events/"+event+"/test", req)
+		c.SetParamNames("event")
+		c.SetParamValues(event)
+		if err := h.TestEvent(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, decode(t, rec)
+	}
+
+	status, body := testEvent(TestEventRequest{Handler: "recorder", Payload: json.RawMessage(`{"teamId":"acme"}`)})
+	if status != http.StatusOK || body["handler"] != "recorder" || body["error"] != nil {
+		t.Fatalf("got %d %v", status, body)
+	}
+	if payload, ok := received.(map[string]interface{}); !ok || payload["teamId"] != "acme" {
+		t.Errorf("the handler got %v", received)
+	}
+	if status, body := testEvent(TestEventRequest{Handler: "recorder", Payload: json.RawMessage(`null`)}); status != http.StatusOK || body["error"] != "empty payload" {
+		t.Errorf("a failing dry run got %d %v", status, body)
+	}
+
+	if status, _ := testEvent(TestEventRequest{Handler: "recorder"}); status != http.StatusBadRequest {
+		t.Errorf("an event without a catalogue sample got %d, want 400", status)
+	}
+	if status, _ := testEvent(TestEventRequest{Handler: "missing", Payload: json.RawMessage(`{}`)}); status != http.StatusNotFound {
+		t.Errorf("an unknown handler got %d, want 404", status)
+	}
+
+	// 📋 The invocations show up in the listing
+	c, rec := newContext(t, http.MethodGet, "/api/v1/admin/events?limit=1000", nil)
+	expectStatus(t, rec, h.ListEvents(c), http.StatusOK)
+	var listing struct {
+		Data  []events.Subscription `json:"data"`
+		Total int                   `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	for _, subscription := range listing.Data {
+		if subscription.Event == event {
+			if stats := subscription.Handlers[0]; stats.Name != "recorder" || stats.Invocations != 2 {
+				t.Errorf("listed %+v, want recorder invoked twice", stats)
+			}
+			return
+		}
+	}
+	t.Errorf("%s is not among the %d listed events", event, listing.Total)
+}
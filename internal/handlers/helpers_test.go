@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/api/validator"
+	"be0/internal/config"
+	"be0/internal/lockout"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// newTestAuthHandler returns an AuthHandler with lockouts disabled and without captchas
+func newTestAuthHandler(gdb *gorm.DB) *AuthHandler {
+	disabled := lockout.NewLimiter(nil, "test", 0, 0)
+	return NewAuthHandler(gdb, config.JWTConfig{MaxSessions: 5}, config.AuthConfig{}, disabled, disabled, nil)
+}
+
+// newContext builds an echo context for a request with a JSON body, nil sends none
+func newContext(t *testing.T, method, target string, body interface{}) (echo.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+// decode unmarshals a JSON response
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// expectStatus fails the test unless the handler answered with status
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, err error, status int) {
+	t.Helper()
+	if err != nil {
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			if httpErr.Code != status {
+				t.Fatalf("got HTTP error %d %v, want %d", httpErr.Code, httpErr.Message, status)
+			}
+			return
+		}
+		t.Fatalf("handler failed: %v", err)
+	}
+	if rec.Code != status {
+		t.Fatalf("got status %d %s, want %d", rec.Code, rec.Body.String(), status)
+	}
+}
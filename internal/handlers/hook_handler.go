@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// EmailBounceRequest is a bounce reported by an email provider
+type EmailBounceRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	// SMTPCode is the reply of the receiving server, 5xx bounces are permanent
+	SMTPCode int    `json:"smtpCode" validate:"required,min=400,max=599"`
+	Reason   string `json:"reason"`
+	// MessageID is the message ID of the bounced email, when the provider knows it
+	MessageID string `json:"messageId"`
+}
+
+// HookHandler serves the signed callbacks of integrations under /api/v1/hooks
+type HookHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewHookHandler(db *gorm.DB) *HookHandler {
+	return &HookHandler{db: db, log: logger.New("HookHandler")}
+}
+
+// EmailBounce records a bounce reported after the email was accepted for delivery
+// @Summary Report an email bounce
+// @Description Callback for email providers, signed with the source's HOOK_SECRETS secret: X-Signature is the hex HMAC-SHA256 of "<X-Timestamp>.<body>" and X-Timestamp the Unix time, at most 5 minutes old. A permanent (5xx) bounce suppresses the address and fails the email with messageId, transient bounces are acknowledged without effect.
+// @Tags hooks
+// @Accept json
+// @Produce json
+// @Param source path string true "Source of the callback, e.g. postmark"
+// @Param X-Signature header string true "HMAC-SHA256 of the timestamp and body"
+// @Param X-Timestamp header string true "Unix time of the signature"
+// @Param bounce body EmailBounceRequest true "Bounce"
+// @Success 200 {object} map[string]bool "suppressed"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Invalid or stale signature"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/hooks/{source}/email-bounces [post]
+func (h *HookHandler) EmailBounce(c echo.Context) error {
+	var req EmailBounceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Soft bounces may still be delivered by the provider's own retries
+	if req.SMTPCode < 500 {
+		return c.JSON(http.StatusOK, map[string]bool{"suppressed": false})
+	}
+
+	ctx := c.Request().Context()
+	log := h.log.FromContext(ctx)
+	reason := req.Reason
+	if reason == "" {
+		reason = "bounce reported by " + c.Param("source")
+	}
+	if err := mailer.Suppress(ctx, h.db, req.Email, req.SMTPCode, reason); err != nil {
+		log.Error("Failed to suppress bounced address: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record bounce"})
+	}
+	if req.MessageID != "" {
+		if err := h.db.WithContext(ctx).Model(&models.EmailLog{}).Where("message_id = ?", req.MessageID).
+			Updates(map[string]interface{}{"status": models.EmailStatusFailed, "smtp_code": req.SMTPCode, "last_error": reason}).Error; err != nil {
+			log.Error("Failed to update email log %s: %v", err, req.MessageID)
+		}
+	}
+
+	log.Info("Suppressed %s after a %d bounce reported by %s", req.Email, req.SMTPCode, c.Param("source"))
+	return c.JSON(http.StatusOK, map[string]bool{"suppressed": true})
+}
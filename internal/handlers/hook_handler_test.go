@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestEmailBounce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	sent := models.EmailLog{MessageID: "m-1", TeamID: team.ID, To: "gone@example.com", Subject: "Hi", Status: models.EmailStatusSent, Attempts: 1}
+	if err := gdb.Create(&sent).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := NewHookHandler(gdb)
+	bounce := func(body EmailBounceRequest) map[string]interface{} {
+		c, rec := newContext(t, http.MethodPost, "/api/v1/hooks/postmark/email-bounces", body)
+		c.SetParamNames("source")
+		c.SetParamValues("postmark")
+		err := h.EmailBounce(c)
+		expectStatus(t, rec, err, http.StatusOK)
+		return decode(t, rec)
+	}
+
+	// Soft bounces leave the address alone
+	if body := bounce(EmailBounceRequest{Email: "busy@example.com", SMTPCode: 452}); body["suppressed"] != false {
+		t.Errorf("got %v for a soft bounce", body)
+	}
+	if body := bounce(EmailBounceRequest{Email: "Gone@Example.com", SMTPCode: 550, MessageID: "m-1"}); body["suppressed"] != true {
+		t.Errorf("got %v for a hard bounce", body)
+	}
+
+	var suppressions []models.EmailSuppression
+	gdb.Find(&suppressions)
+	if len(suppressions) != 1 || suppressions[0].Email != "gone@example.com" || suppressions[0].SMTPCode != 550 {
+		t.Errorf("got suppressions %+v", suppressions)
+	}
+	var entry models.EmailLog
+	gdb.First(&entry, "message_id = ?", "m-1")
+	if entry.Status != models.EmailStatusFailed || entry.SMTPCode != 550 {
+		t.Errorf("got email log %+v", entry)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/api/v1/hooks/postmark/email-bounces", EmailBounceRequest{Email: "nope", SMTPCode: 550})
+	expectStatus(t, rec, h.EmailBounce(c), http.StatusBadRequest)
+}
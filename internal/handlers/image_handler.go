@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/imaging"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+type ImageHandler struct {
+	db    *gorm.DB
+	cache *imaging.Cache
+	// avatarCache keeps public avatars briefly, they are requested without authentication
+	avatarCache *imaging.Cache
+	signer      *imaging.AvatarSigner
+	config      config.ImageConfig
+	// slots caps how many transforms render at once
+	slots  chan struct{}
+	flight singleflight.Group
+	log    *logger.Logger
+}
+
+func NewImageHandler(db *gorm.DB, cache, avatarCache *imaging.Cache, signer *imaging.AvatarSigner, cfg config.ImageConfig) *ImageHandler {
+	return &ImageHandler{
+		db:          db,
+		cache:       cache,
+		avatarCache: avatarCache,
+		signer:      signer,
+		config:      cfg,
+		slots:       make(chan struct{}, max(cfg.MaxConcurrent, 1)),
+		log:         logger.New("ImageHandler"),
+	}
+}
+
+// GetImage renders a resized variant of an image file
+// @Summary Get image variant
+// @Description Resize and re-encode an image file, e.g. for avatars. Variants are cached and the ETag changes when the file is updated.
+// @Tags files
+// @Produce image/jpeg,image/png,image/webp
+// @Param fileId path string true "File ID"
+// @Param w query int false "Width in pixels"
+// @Param h query int false "Height in pixels"
+// @Param fit query string false "cover (default), contain or fill"
+// @Param format query string false "jpeg (default), png or webp"
+// @Param q query int false "Quality 1-100"
+// @Success 200 {file} binary "Image variant"
+// @Success 304 "Not modified"
+// @Failure 400 {object} map[string]string "Invalid transform parameters"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 415 {object} map[string]string "File is not a supported image"
+// @Failure 503 {object} map[string]string "Too many transforms in progress"
+// @Router /api/v1/images/{fileId} [get]
+func (h *ImageHandler) GetImage(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "w", "h", "fit", "format", "q"); err != nil {
+		return err
+	}
+	opts, err := imaging.ParseOptions(c.QueryParams(), h.config.MaxDimension)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	file, err := models.GetFileByID(c.Param("fileId"), h.db.WithContext(models.WithExcludedFields(ctx, "signedUrl")))
+	if err != nil || !h.canView(c, file) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "File not found"})
+	}
+
+	if !strings.HasPrefix(file.Type, "image/") {
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "File is not an image"})
+	}
+
+	// 🏷️ The variant only changes when the file is updated
+	key := fmt.Sprintf("%s:%d:%s", file.ID, file.UpdatedAt.UnixNano(), opts.Key())
+	sum := sha1.Sum([]byte(key))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	cacheControl := "private, max-age=2592000"
+	if models.IsPublicACL(file.ACL) {
+		cacheControl = "public, max-age=2592000"
+	}
+	c.Response().Header().Set("Cache-Control", cacheControl)
+	c.Response().Header().Set("ETag", etag)
+
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if data, ok, err := h.cache.Get(ctx, key); err != nil {
+		h.log.Warn("Image cache read failed for %s: %v", key, err)
+	} else if ok {
+		return c.Blob(http.StatusOK, opts.ContentType(), data)
+	}
+
+	// 🖼️ Render once per variant, however many requests are waiting for it
+	result, err, _ := h.flight.Do(key, func() (interface{}, error) {
+		// Waiters share the result, so one client going away must not cancel it
+		renderCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), imageRenderTimeout)
+		defer cancel()
+		return h.render(renderCtx, h.cache, file, opts, key)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTransformBusy):
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Too many image transforms in progress"})
+		case errors.Is(err, imaging.ErrUnsupportedImage):
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "File is not a supported image"})
+		case errors.Is(err, models.ErrFileTooLarge):
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "Image is too large to transform"})
+		}
+		h.log.Error("Failed to transform image", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to transform image"})
+	}
+
+	return c.Blob(http.StatusOK, opts.ContentType(), result.([]byte))
+}
+
+const (
+	// imageSlotWait is how long a render waits for a free transform slot
+	imageSlotWait = 5 * time.Second
+	// imageRenderTimeout bounds downloading and transforming one variant
+	imageRenderTimeout = 20 * time.Second
+)
+
+var errTransformBusy = errors.New("too many transforms in progress")
+
+// render downloads and transforms the file while holding a transform slot, storing the variant in cache
+func (h *ImageHandler) render(ctx context.Context, cache *imaging.Cache, file *models.File, opts imaging.Options, key string) ([]byte, error) {
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	case <-time.After(imageSlotWait):
+		return nil, errTransformBusy
+	}
+
+	downloader := models.GetFileDownloader()
+	if downloader == nil {
+		return nil, fmt.Errorf("no file downloader registered")
+	}
+
+	src, err := downloader.DownloadFile(ctx, file.Path, int64(h.config.MaxSourceSize))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := imaging.Transform(ctx, src, opts, h.config.MaxDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Set(ctx, key, data); err != nil {
+		h.log.Warn("Image cache write failed for %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// GetAvatar renders a user's avatar for pages and emails without authentication
+// @Summary Get public avatar
+// @Description Serve a user's profile picture resized to a square, or an SVG with their initials when they have none. The URL must carry the signature from a user's avatarUrl, requests are rate limited per IP.
+// @Tags users
+// @Produce image/jpeg,image/svg+xml
+// @Param userId path string true "User ID"
+// @Param s query int false "Size in pixels, 16-512" default(64)
+// @Param sig query string true "Signature from avatarUrl"
+// @Success 200 {file} binary "Avatar"
+// @Success 304 "Not modified"
+// @Failure 403 {object} map[string]string "Invalid signature"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 429 {object} map[string]string "Rate limit exceeded"
+// @Failure 503 {object} map[string]string "Too many transforms in progress"
+// @Router /public/avatars/{userId} [get]
+func (h *ImageHandler) GetAvatar(c echo.Context) error {
+	userID := c.Param("userId")
+	size := imaging.DefaultAvatarSize
+	if value := c.QueryParam("s"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "s must be a number"})
+		}
+		size = parsed
+	}
+
+	// 🔏 Only URLs handed out by the API are served, so IDs cannot be enumerated
+	if !h.signer.Verify(userID, size, c.QueryParam("sig")) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Invalid avatar signature"})
+	}
+
+	ctx := c.Request().Context()
+	var user models.User
+	if err := h.db.WithContext(ctx).Select("id", "first_name", "last_name", "profile_picture_id", "updated_at").
+		Where("id = ? AND is_deleted = false", userID).First(&user).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	var file *models.File
+	if user.ProfilePictureID != "" {
+		picture, err := models.GetFileByID(user.ProfilePictureID, h.db.WithContext(models.WithExcludedFields(ctx, "signedUrl")))
+		if err == nil && strings.HasPrefix(picture.Type, "image/") {
+			file = picture
+		}
+	}
+
+	// 🏷️ The avatar changes when the picture is replaced or updated, or the name changes
+	key := fmt.Sprintf("avatar:%s:%d:%d", user.ID, user.UpdatedAt.UnixNano(), size)
+	if file != nil {
+		key = fmt.Sprintf("avatar:%s:%s:%d:%d", user.ID, file.ID, file.UpdatedAt.UnixNano(), size)
+	}
+	sum := sha1.Sum([]byte(key))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	c.Response().Header().Set("ETag", etag)
+
+	if match := c.Request().Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if file == nil {
+		return c.Blob(http.StatusOK, "image/svg+xml", imaging.InitialsSVG(user.ID, user.FirstName, user.LastName, size))
+	}
+
+	opts := imaging.Options{Width: size, Height: size, Fit: imaging.FitCover, Format: imaging.FormatJPEG, Quality: imaging.DefaultQuality}
+	if data, ok, err := h.avatarCache.Get(ctx, key); err != nil {
+		h.log.Warn("Avatar cache read failed for %s: %v", key, err)
+	} else if ok {
+		return c.Blob(http.StatusOK, opts.ContentType(), data)
+	}
+
+	result, err, _ := h.flight.Do(key, func() (interface{}, error) {
+		renderCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), imageRenderTimeout)
+		defer cancel()
+		return h.render(renderCtx, h.avatarCache, file, opts, key)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTransformBusy):
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Too many image transforms in progress"})
+		case errors.Is(err, imaging.ErrUnsupportedImage), errors.Is(err, models.ErrFileTooLarge):
+			// 🔤 A picture that cannot be transformed still gets an avatar
+			return c.Blob(http.StatusOK, "image/svg+xml", imaging.InitialsSVG(user.ID, user.FirstName, user.LastName, size))
+		}
+		h.log.Error("Failed to render avatar", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to render avatar"})
+	}
+
+	return c.Blob(http.StatusOK, opts.ContentType(), result.([]byte))
+}
+
+// canView allows files of the requester's team, public files and the shared default avatar
+func (h *ImageHandler) canView(c echo.Context, file *models.File) bool {
+	teamID, _ := c.Get("teamID").(string)
+	return file.TeamID == teamID || models.IsPublicACL(file.ACL) || file.ID == models.DefaultProfilePictureID
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/imaging"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// countingDownloader serves a 40x20 PNG for every path but broken.png and counts the downloads
+type countingDownloader struct {
+	downloads atomic.Int32
+}
+
+func (d *countingDownloader) DownloadFile(_ context.Context, path string, _ int64) ([]byte, error) {
+	d.downloads.Add(1)
+	if path == "broken.png" {
+		return []byte("not an image"), nil
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)))
+	return buf.Bytes(), err
+}
+
+func newImageHandler(t *testing.T, gdb *gorm.DB) (*ImageHandler, *countingDownloader) {
+	t.Helper()
+	redisClient, _ := testutil.NewRedis(t)
+	cache := imaging.NewCache(redisClient, time.Hour)
+	downloader := &countingDownloader{}
+	models.RegisterFileDownloader(downloader)
+	t.Cleanup(func() { models.RegisterFileDownloader(nil) })
+
+	cfg := config.ImageConfig{MaxDimension: 100, MaxConcurrent: 1, MaxSourceSize: 1 << 20}
+	return NewImageHandler(gdb, cache, cache, imaging.NewAvatarSigner("https://api.example.com", "avatar-key"), cfg), downloader
+}
+
+func getImage(t *testing.T, h *ImageHandler, teamID, fileID, query, ifNoneMatch string) (int, http.Header, []byte) {
+	t.Helper()
+	c, rec := newContext(t, http.MethodGet, "/api/v1/images/"+fileID+"?"+query, nil)
+	c.SetParamNames("fileId")
+	c.SetParamValues(fileID)
+	c.Set("teamID", teamID)
+	if ifNoneMatch != "" {
+		c.Request().Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if err := h.GetImage(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, rec.Header(), rec.Body.Bytes()
+}
+
+func TestGetImageRendersEachVariantOnce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h, downloader := newImageHandler(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	file := &models.File{TeamID: team.ID, Path: "photo.png", Name: "photo.png", Size: 1, Type: "image/png"}
+	if err := gdb.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status, header, body := getImage(t, h, team.ID, file.ID, "w=20&h=20", "")
+	if status != http.StatusOK || header.Get("Content-Type") != "image/jpeg" || header.Get("Cache-Control") != "private, max-age=2592000" {
+		t.Fatalf("got %d %q %q", status, header.Get("Content-Type"), header.Get("Cache-Control"))
+	}
+	variant, err := jpeg.DecodeConfig(bytes.NewReader(body))
+	if err != nil || variant.Width != 20 || variant.Height != 20 {
+		t.Fatalf("variant is %dx%d: %v", variant.Width, variant.Height, err)
+	}
+	etag := header.Get("ETag")
+
+	// 🗄️ The same variant comes from the cache, a revalidation gets a 304
+	if status, _, cached := getImage(t, h, team.ID, file.ID, "w=20&h=20", ""); status != http.StatusOK || !bytes.Equal(cached, body) {
+		t.Errorf("cached request got %d", status)
+	}
+	if status, _, _ := getImage(t, h, team.ID, file.ID, "w=20&h=20", etag); status != http.StatusNotModified {
+		t.Errorf("revalidation got %d, want 304", status)
+	}
+	if n := downloader.downloads.Load(); n != 1 {
+		t.Errorf("downloaded %d times, want once", n)
+	}
+
+	// ✏️ Another size is a new variant, and updating the file changes the ETag
+	if _, other, _ := getImage(t, h, team.ID, file.ID, "w=10", ""); other.Get("ETag") == etag {
+		t.Error("another variant has the same ETag")
+	}
+	if err := gdb.Model(file).Update("name", "renamed.png").Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, updated, _ := getImage(t, h, team.ID, file.ID, "w=20&h=20", etag); status != http.StatusOK || updated.Get("ETag") == etag {
+		t.Errorf("an updated file got %d with ETag %s", status, updated.Get("ETag"))
+	}
+}
+
+func TestGetImageRefusesOtherTeamsAndNonImages(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h, downloader := newImageHandler(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Globex")
+	photo := &models.File{TeamID: team.ID, Path: "photo.png", Name: "photo.png", Size: 1, Type: "image/png"}
+	public := &models.File{TeamID: team.ID, Path: "logo.png", Name: "logo.png", Size: 1, Type: "image/png", ACL: "public-read"}
+	report := &models.File{TeamID: team.ID, Path: "report.pdf", Name: "report.pdf", Size: 1, Type: "application/pdf"}
+	for _, file := range []*models.File{photo, public, report} {
+		if err := gdb.Create(file).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		teamID string
+		fileID string
+		query  string
+		status int
+	}{
+		{"another team's file", other.ID, photo.ID, "w=20", http.StatusNotFound},
+		{"another team's public file", other.ID, public.ID, "w=20", http.StatusOK},
+		{"not an image", team.ID, report.ID, "w=20", http.StatusUnsupportedMediaType},
+		{"too large", team.ID, photo.ID, "w=101", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(t, http.MethodGet, "/api/v1/images/"+tt.fileID+"?"+tt.query, nil)
+			c.SetParamNames("fileId")
+			c.SetParamValues(tt.fileID)
+			c.Set("teamID", tt.teamID)
+			expectStatus(t, rec, h.GetImage(c), tt.status)
+		})
+	}
+	if n := downloader.downloads.Load(); n != 1 {
+		t.Errorf("downloaded %d times, want only the public file", n)
+	}
+}
+
+// getAvatar requests the avatar URL handed out for userID, sig replaces its signature when set
+func getAvatar(t *testing.T, h *ImageHandler, userID string, size int, sig, ifNoneMatch string) (int, http.Header, []byte) {
+	t.Helper()
+	u, err := url.Parse(h.signer.AvatarURL(userID, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != "" {
+		query := u.Query()
+		query.Set("sig", sig)
+		u.RawQuery = query.Encode()
+	}
+	c, rec := newContext(t, http.MethodGet, u.RequestURI(), nil)
+	c.SetParamNames("userId")
+	c.SetParamValues(userID)
+	if ifNoneMatch != "" {
+		c.Request().Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if err := h.GetAvatar(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, rec.Header(), rec.Body.Bytes()
+}
+
+func TestGetAvatar(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h, _ := newImageHandler(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	if status, _, _ := getAvatar(t, h, user.ID, 32, "forged", ""); status != http.StatusForbidden {
+		t.Errorf("forged signature got %d, want 403", status)
+	}
+
+	// 🔤 Without a picture the avatar is the user's initials
+	status, header, body := getAvatar(t, h, user.ID, 32, "", "")
+	if status != http.StatusOK || header.Get("Content-Type") != "image/svg+xml" || !bytes.Contains(body, []byte(">TM</text>")) {
+		t.Fatalf("got %d %q %s", status, header.Get("Content-Type"), body)
+	}
+	if status, _, _ := getAvatar(t, h, user.ID, 32, "", header.Get("ETag")); status != http.StatusNotModified {
+		t.Errorf("revalidation got %d, want 304", status)
+	}
+
+	picture := &models.File{TeamID: team.ID, Path: "me.png", Name: "me.png", Size: 1, Type: "image/png"}
+	if err := gdb.Create(picture).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(user).Update("profile_picture_id", picture.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	status, updated, body := getAvatar(t, h, user.ID, 32, "", header.Get("ETag"))
+	if status != http.StatusOK || updated.Get("Content-Type") != "image/jpeg" || updated.Get("ETag") == header.Get("ETag") {
+		t.Fatalf("with a picture got %d %q, ETag %s", status, updated.Get("Content-Type"), updated.Get("ETag"))
+	}
+	if avatar, err := jpeg.DecodeConfig(bytes.NewReader(body)); err != nil || avatar.Width != 32 || avatar.Height != 32 {
+		t.Errorf("avatar is %dx%d: %v", avatar.Width, avatar.Height, err)
+	}
+
+	// 🖼️ A picture that cannot be decoded falls back to the initials
+	if err := gdb.Model(picture).Update("path", "broken.png").Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, header, _ := getAvatar(t, h, user.ID, 32, "", ""); status != http.StatusOK || header.Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("broken picture got %d %q, want the initials", status, header.Get("Content-Type"))
+	}
+}
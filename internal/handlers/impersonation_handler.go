@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ImpersonationHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewImpersonationHandler(db *gorm.DB) *ImpersonationHandler {
+	return &ImpersonationHandler{db: db, log: logger.New("ImpersonationHandler")}
+}
+
+type ImpersonateRequest struct {
+	// Reason is kept in the audit log, e.g. the support ticket being reproduced
+	Reason string `json:"reason"`
+}
+
+// Impersonate signs a super admin in as another user
+// @Summary Impersonate a user
+// @Description Issue a 15 minute access token with the user's claims and an impersonated_by claim, without a refresh token. Every request made with it is written to the audit log. Super admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param request body ImpersonateRequest false "Why the user is impersonated"
+// @Success 200 {object} map[string]interface{} "Token, session ID and expiry"
+// @Failure 403 {object} map[string]string "Super admin access required, or the user is a super admin"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/impersonate/{userId} [post]
+func (h *ImpersonationHandler) Impersonate(c echo.Context) error {
+	var req ImpersonateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", c.Param("userId")).
+		Preload("Permissions", "is_deleted = false").Preload("Permissions.ResourcePermission").
+		First(&user).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	// 🛡️ Impersonation never grants super admin access
+	if user.Role == models.UserRoleSuperAdmin {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Super admins cannot be impersonated"})
+	}
+
+	// 🏢 Members of a deleted team cannot sign in, so they cannot be impersonated either
+	team, err := models.GetTeamByID(user.TeamID, h.db)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	}
+	orgID := ""
+	if team.OrganizationID != nil {
+		orgID = *team.OrganizationID
+	}
+
+	adminID := middleware.GetUserID(c)
+	if adminID == "" {
+		return userRequired(c)
+	}
+	token, tokenID, err := utils.GenerateImpersonationJWT(user, orgID, adminID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+
+	session := &models.AuthTransaction{
+		UserID:         user.ID,
+		TeamID:         user.TeamID,
+		TokenID:        tokenID,
+		IPAddress:      c.RealIP(),
+		UserAgent:      c.Request().UserAgent(),
+		ExpiresAt:      time.Now().UTC().Add(models.ImpersonationLifetime),
+		ImpersonatedBy: &adminID,
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		// 🎫 The user's own sessions are left alone, impersonation does not count towards their cap
+		if err := models.CreateSession(tx, session, 0); err != nil {
+			return err
+		}
+		return h.audit(tx, c, models.AuditImpersonationStarted, session, map[string]interface{}{"reason": req.Reason})
+	}); err != nil {
+		h.log.Error("Failed to start impersonation: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start impersonation"})
+	}
+
+	h.log.Warn("Super admin %s is impersonating user %s", adminID, user.ID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":     token,
+		"sessionId": session.ID,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// EndImpersonation ends the impersonation session the request is made with
+// @Summary End impersonation
+// @Description Revoke the impersonation session of the token the request is made with, before it expires
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]string "Impersonation ended"
+// @Failure 400 {object} map[string]string "The request is not made with an impersonation token"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/impersonate [delete]
+func (h *ImpersonationHandler) EndImpersonation(c echo.Context) error {
+	impersonatorID := middleware.GetImpersonator(c)
+	if impersonatorID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Not an impersonation session"})
+	}
+
+	session := &models.AuthTransaction{
+		Base:           models.Base{ID: middleware.GetSessionID(c)},
+		UserID:         middleware.GetUserID(c),
+		TeamID:         middleware.GetTeamID(c),
+		ImpersonatedBy: &impersonatorID,
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.RevokeSession(tx, session.ID, session.UserID); err != nil {
+			return err
+		}
+		return h.audit(tx, c, models.AuditImpersonationEnded, session, nil)
+	}); err != nil {
+		h.log.Error("Failed to end impersonation: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to end impersonation"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Impersonation ended"})
+}
+
+// audit records an impersonation step under the super admin, targeting the impersonated user
+func (h *ImpersonationHandler) audit(tx *gorm.DB, c echo.Context, action string, session *models.AuthTransaction, metadata map[string]interface{}) error {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["sessionId"] = session.ID
+
+	return models.RecordAudit(tx, models.AuditEntry{
+		ActorID:    *session.ImpersonatedBy,
+		TeamID:     session.TeamID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   session.UserID,
+		IPAddress:  c.RealIP(),
+	}, metadata)
+}
@@ -12,6 +12,8 @@ import (
 type StorageHandler interface {
 	UploadFile(ctx context.Context, file []byte, filename string, acl types.ObjectCannedACL, contentType string) (string, error)
 	GetSignedURL(ctx context.Context, path string, duration time.Duration) (string, error)
+	SetObjectACL(ctx context.Context, path string, acl types.ObjectCannedACL) error
+	EffectiveACL(acl types.ObjectCannedACL) types.ObjectCannedACL
 }
 
 var (
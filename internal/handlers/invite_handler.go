@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/redact"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListPendingInvites lists the current team's pending invitations
+// @Summary List pending invitations
+// @Description List the current team's invitations that can still be accepted, newest first. Invite codes are only sent to the invitee.
+// @Tags team-invitations
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Invitations per page" default(10)
+// @Success 200 {object} map[string]interface{} "Invitations with total, page and limit"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /team-invitations/pending [get]
+func (h *AuthHandler) ListPendingInvites(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "page", "limit"); err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	query := h.db.Model(&models.TeamInvite{}).
+		Where("team_id = ? AND status = ? AND expires_at > ? AND is_deleted = false",
+			c.Get("teamID").(string), models.InviteStatusPending, time.Now().UTC())
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.log.Error("Failed to count invitations: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list invitations"})
+	}
+
+	var invites []models.TeamInvite
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&invites).Error; err != nil {
+		h.log.Error("Failed to list invitations: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list invitations"})
+	}
+	for i := range invites {
+		invites[i].Code = ""
+	}
+
+	data, err := redact.Value(invites, redact.ForCaller(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list invitations"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  data,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// ResendInvite sends an invitation again with a new code
+// @Summary Resend invitation
+// @Description Resend a pending or expired invitation of the current team. The code is regenerated, so earlier emails stop working, and the invitation is valid for another 7 days.
+// @Tags team-invitations
+// @Produce json
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} map[string]string "Invitation resent"
+// @Failure 404 {object} map[string]string "Invitation not found"
+// @Failure 409 {object} map[string]string "A newer pending invitation exists for this email"
+// @Failure 422 {object} map[string]interface{} "Member quota exceeded"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /team-invitations/{id}/resend [post]
+func (h *AuthHandler) ResendInvite(c echo.Context) error {
+	teamID := c.Get("teamID").(string)
+
+	var invite models.TeamInvite
+	var seats int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// 🔒 Serialised with other invites to the team, like the quota check of new ones
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("id = ? AND is_deleted = false", teamID).First(&models.Team{}).Error; err != nil {
+			return err
+		}
+
+		// Accepted and rejected invitations are final
+		if err := tx.Where("id = ? AND team_id = ? AND status IN ? AND is_deleted = false",
+			c.Param("id"), teamID, []models.InviteStatus{models.InviteStatusPending, models.InviteStatusExpired}).
+			First(&invite).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		// 👥 An invitation that no longer counted towards the quota takes a seat again
+		if (invite.Status == models.InviteStatusExpired || !invite.ExpiresAt.After(now)) && h.maxTeamMembers > 0 {
+			var err error
+			if seats, err = models.CountTeamSeats(teamID, tx); err != nil {
+				return err
+			}
+			if seats+1 > int64(h.maxTeamMembers) {
+				return errMemberQuotaExceeded
+			}
+		}
+
+		code, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return err
+		}
+		invite.Code = code
+		invite.Status = models.InviteStatusPending
+		invite.ExpiresAt = now.Add(models.InviteLifetime)
+		return tx.Model(&invite).Select("code", "status", "expires_at").Updates(&invite).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Invitation not found"})
+	case errors.Is(err, errMemberQuotaExceeded):
+		return memberQuotaExceeded(c, h.maxTeamMembers, seats, 1)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A pending invitation already exists for this email"})
+	case err != nil:
+		h.log.Error("Failed to resend invitation: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to resend invitation"})
+	}
+
+	h.sendInvites(c, []models.TeamInvite{invite}, true)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Invitation resent"})
+}
+
+// sendInvites emits team.invite_sent for webhooks and team_invites.created or team_invites.resent
+// with the full invite, which the notifications package turns into the invitation email
+func (h *AuthHandler) sendInvites(c echo.Context, invites []models.TeamInvite, resent bool) {
+	if len(invites) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(invites))
+	for _, invite := range invites {
+		ids = append(ids, invite.ID)
+	}
+	var loaded []models.TeamInvite
+	if err := h.db.WithContext(c.Request().Context()).Preload("Team").Preload("Inviter").
+		Where("id IN ?", ids).Find(&loaded).Error; err != nil {
+		h.log.Error("Failed to load invitations for their emails: %v", err)
+		return
+	}
+
+	event := events.EventTeamInviteCreated
+	if resent {
+		event = events.EventTeamInviteResent
+	}
+	now := time.Now().UTC()
+	for i := range loaded {
+		invite := &loaded[i]
+		events.Emit(events.EventTeamInviteSent, events.InviteSent{
+			InviteID:   invite.ID,
+			TeamID:     invite.TeamID,
+			Email:      invite.Email,
+			Name:       invite.Name,
+			Role:       string(invite.Role),
+			InviterID:  invite.InviterID,
+			ExpiresAt:  invite.ExpiresAt,
+			Resent:     resent,
+			OccurredAt: now,
+		})
+		events.Emit(event, invite)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/jobs"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultJobWait is how long WaitForJob blocks without a timeout parameter
+	DefaultJobWait = 30 * time.Second
+	// MaxJobWait caps the timeout parameter, the route is exempt from the server's request timeout
+	MaxJobWait = 60 * time.Second
+	// jobRecheckInterval re-reads the job while waiting in case a notification was lost
+	jobRecheckInterval = 5 * time.Second
+)
+
+type JobHandler struct {
+	db       *gorm.DB
+	notifier *jobs.Notifier
+	log      *logger.Logger
+}
+
+func NewJobHandler(db *gorm.DB, notifier *jobs.Notifier) *JobHandler {
+	return &JobHandler{db: db, notifier: notifier, log: logger.New("JobHandler")}
+}
+
+// GetJob returns a background job
+// @Summary Get job
+// @Description Get the status, progress and result of a background job. Users see the jobs they started, super admins every job.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 404 {object} map[string]string "Job not found"
+// @Router /jobs/{id} [get]
+func (h *JobHandler) GetJob(c echo.Context) error {
+	job, err := h.findJob(c, c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// WaitForJob blocks until a job finishes or the timeout elapses
+// @Summary Wait for job
+// @Description Long-poll a background job the caller can see, like GetJob. Returns the job once it is completed, failed or cancelled, or 202 with its current state when the timeout elapses first.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param timeout query string false "How long to wait, e.g. 30s, at most 60s" default(30s)
+// @Success 200 {object} models.Job "Finished job"
+// @Success 202 {object} models.Job "Job still running"
+// @Failure 400 {object} map[string]string "Invalid timeout"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Router /jobs/{id}/wait [get]
+func (h *JobHandler) WaitForJob(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "timeout"); err != nil {
+		return err
+	}
+	timeout := DefaultJobWait
+	if value := c.QueryParam("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "timeout must be a positive duration, e.g. 30s"})
+		}
+		timeout = min(parsed, MaxJobWait)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+	defer cancel()
+
+	// 📡 Subscribe before reading the job so a completion in between is not missed
+	var finished <-chan *redis.Message
+	sub, err := h.notifier.Subscribe(ctx, c.Param("id"))
+	if err != nil {
+		h.log.Warn("Failed to subscribe to job %s, falling back to re-checks: %v", c.Param("id"), err)
+	} else {
+		defer sub.Close()
+		finished = sub.Channel()
+	}
+
+	job, err := h.findJob(c, c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+
+	ticker := time.NewTicker(jobRecheckInterval)
+	defer ticker.Stop()
+
+	for !job.Status.Terminal() {
+		select {
+		case <-finished:
+		case <-ticker.C:
+		case <-ctx.Done():
+			// ⏳ Report the latest state, the client can wait again
+			if latest, err := h.findJob(c, job.ID); err == nil {
+				job = latest
+			}
+			if job.Status.Terminal() {
+				return c.JSON(http.StatusOK, job)
+			}
+			return c.JSON(http.StatusAccepted, job)
+		}
+
+		if job, err = h.findJob(c, job.ID); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// findJob loads a job the caller can see, other jobs are not found
+func (h *JobHandler) findJob(c echo.Context, id string) (*models.Job, error) {
+	query := h.db.Where("id = ? AND is_deleted = false", id)
+	if middleware.GetUserRole(c) != string(models.UserRoleSuperAdmin) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+
+	var job models.Job
+	if err := query.First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
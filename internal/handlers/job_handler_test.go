@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/jobs"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// jobSetup returns a job handler on an in-memory database and Redis, with UpdateJob
+// notifying its waiters
+func jobSetup(t *testing.T) (*gorm.DB, *JobHandler) {
+	t.Helper()
+	gdb := testutil.NewDB(t)
+	client, _ := testutil.NewRedis(t)
+	notifier := jobs.NewNotifier(client)
+	models.RegisterJobNotifier(notifier)
+	t.Cleanup(func() { models.RegisterJobNotifier(nil) })
+	return gdb, NewJobHandler(gdb, notifier)
+}
+
+func createJob(t *testing.T, gdb *gorm.DB, owner *models.User, status models.JobStatus) *models.Job {
+	t.Helper()
+	job := &models.Job{Type: "export", Status: status}
+	if owner != nil {
+		job.UserID = &owner.ID
+	}
+	if err := gdb.Create(job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// jobRequest calls a job endpoint as user
+func jobRequest(t *testing.T, handler echo.HandlerFunc, user *models.User, jobID, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	c, rec := newContext(t, http.MethodGet, "/jobs/"+jobID+query, nil)
+	c.SetParamNames("id")
+	c.SetParamValues(jobID)
+	c.Set("userID", user.ID)
+	c.Set("role", string(user.Role))
+	if err := handler(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestJobsAreVisibleToTheirOwner(t *testing.T) {
+	gdb, h := jobSetup(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	owner := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	teammate := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	superAdmin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+
+	owned := createJob(t, gdb, owner, models.JobStatusCompleted)
+	system := createJob(t, gdb, nil, models.JobStatusCompleted)
+
+	tests := []struct {
+		name   string
+		caller *models.User
+		job    *models.Job
+		status int
+	}{
+		{name: "owner", caller: owner, job: owned, status: http.StatusOK},
+		{name: "teammate", caller: teammate, job: owned, status: http.StatusNotFound},
+		{name: "super admin", caller: superAdmin, job: owned, status: http.StatusOK},
+		{name: "system job for a user", caller: owner, job: system, status: http.StatusNotFound},
+		{name: "system job for a super admin", caller: superAdmin, job: system, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := jobRequest(t, h.GetJob, tt.caller, tt.job.ID, ""); rec.Code != tt.status {
+				t.Errorf("GetJob: status %d, want %d", rec.Code, tt.status)
+			}
+			if rec := jobRequest(t, h.WaitForJob, tt.caller, tt.job.ID, "?timeout=50ms"); rec.Code != tt.status {
+				t.Errorf("WaitForJob: status %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestWaitForJob(t *testing.T) {
+	gdb, h := jobSetup(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	owner := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	t.Run("already finished", func(t *testing.T) {
+		job := createJob(t, gdb, owner, models.JobStatusFailed)
+		rec := jobRequest(t, h.WaitForJob, owner, job.ID, "")
+		if rec.Code != http.StatusOK || decode(t, rec)["status"] != string(models.JobStatusFailed) {
+			t.Errorf("status %d %s, want the failed job", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("finishes during the wait", func(t *testing.T) {
+		job := createJob(t, gdb, owner, models.JobStatusProcessing)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			models.UpdateJob(gdb, job, map[string]interface{}{"status": models.JobStatusCompleted})
+		}()
+
+		started := time.Now()
+		rec := jobRequest(t, h.WaitForJob, owner, job.ID, "?timeout=10s")
+		if rec.Code != http.StatusOK || decode(t, rec)["status"] != string(models.JobStatusCompleted) {
+			t.Fatalf("status %d %s, want the completed job", rec.Code, rec.Body.String())
+		}
+		// Woken by the notification, not the periodic re-check
+		if elapsed := time.Since(started); elapsed >= jobRecheckInterval {
+			t.Errorf("returned after %s, want before the %s re-check", elapsed, jobRecheckInterval)
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		job := createJob(t, gdb, owner, models.JobStatusProcessing)
+		rec := jobRequest(t, h.WaitForJob, owner, job.ID, "?timeout=50ms")
+		if rec.Code != http.StatusAccepted || decode(t, rec)["status"] != string(models.JobStatusProcessing) {
+			t.Errorf("status %d %s, want 202 with the running job", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		job := createJob(t, gdb, owner, models.JobStatusProcessing)
+		if rec := jobRequest(t, h.WaitForJob, owner, job.ID, "?timeout=soon"); rec.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// loginFailed counts a failed password sign-in and answers with 429 once the email and IP
+// pair reached the lockout threshold, 401 otherwise. user is nil for unknown emails.
+func (h *AuthHandler) loginFailed(c echo.Context, email, ip string, user *models.User) error {
+	retryAfter, err := h.limiter.Fail(c.Request().Context(), email, ip)
+	if err != nil {
+		// Redis being down must not lock everyone out
+		h.log.Warn("Failed to count failed login: %v", err)
+	}
+
+	// 📝 Failures on known accounts are audited with the caller's IP for team admins
+	if user != nil {
+		if err := models.RecordAudit(h.db, models.AuditEntry{
+			ActorID:    user.ID,
+			TeamID:     user.TeamID,
+			Action:     models.AuditLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID,
+			IPAddress:  ip,
+		}, map[string]interface{}{
+			"userAgent": c.Request().UserAgent(),
+			"lockedOut": retryAfter > 0,
+		}); err != nil {
+			h.log.Error("Failed to audit failed login: %v", err)
+		}
+	}
+
+	if retryAfter > 0 {
+		return tooManyLoginAttempts(c, retryAfter)
+	}
+	return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
+}
+
+// tooManyLoginAttempts rejects a locked out sign-in and says when to retry
+func tooManyLoginAttempts(c echo.Context, retryAfter time.Duration) error {
+	setRetryAfter(c, retryAfter)
+	return c.JSON(http.StatusTooManyRequests, map[string]string{
+		"error": "Too many failed login attempts, please try again later",
+		"code":  "login_locked",
+	})
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up
+func setRetryAfter(c echo.Context, retryAfter time.Duration) {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/lockout"
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestConcurrentFailedLoginsLockOut(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	client, server := testutil.NewRedis(t)
+	const maxAttempts = 5
+	window := 15 * time.Minute
+	limiter := lockout.NewLimiter(client, "login", maxAttempts, window)
+	h := NewAuthHandler(gdb, config.JWTConfig{MaxSessions: 5}, config.AuthConfig{}, limiter, lockout.NewLimiter(nil, "reset", 0, 0), nil)
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	attempt := func(password string) (int, string) {
+		c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: password})
+		if err := h.Login(c); err != nil {
+			t.Error(err)
+		}
+		return rec.Code, rec.Header().Get("Retry-After")
+	}
+
+	const attempts = 12
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := attempt("wrong-password")
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusUnauthorized]+counts[http.StatusTooManyRequests] != attempts {
+		t.Fatalf("statuses %v, want only 401 and 429", counts)
+	}
+	if counts[http.StatusUnauthorized] > maxAttempts-1 || counts[http.StatusTooManyRequests] == 0 {
+		t.Errorf("statuses %v, want at most %d failures before the lockout", counts, maxAttempts-1)
+	}
+
+	// 📝 Every failure that reached the password check is audited with the caller's IP
+	var audits []models.AuditEntry
+	if err := gdb.Where("action = ?", models.AuditLoginFailed).Find(&audits).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) < maxAttempts {
+		t.Errorf("%d failures audited, want at least %d", len(audits), maxAttempts)
+	}
+	for _, audit := range audits {
+		if audit.IPAddress == "" || audit.TargetID != user.ID {
+			t.Errorf("audit %+v misses the IP or user", audit)
+		}
+	}
+
+	// 🚧 The right password does not lift the lockout
+	status, retryAfter := attempt(testutil.Password)
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("correct password got %d while locked out, want 429", status)
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err != nil || seconds <= 0 || seconds > int(window.Seconds()) {
+		t.Errorf("Retry-After %q, want seconds within the window", retryAfter)
+	}
+
+	server.FastForward(window)
+	if status, _ := attempt(testutil.Password); status != http.StatusOK {
+		t.Fatalf("correct password got %d after the window, want 200", status)
+	}
+	if keys := client.Keys(context.Background(), "lockout:*").Val(); len(keys) != 0 {
+		t.Errorf("counters %v left after a successful login", keys)
+	}
+}
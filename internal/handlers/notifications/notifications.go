@@ -0,0 +1,115 @@
+// Package notifications turns bus events into the emails the app sends on its own behalf
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"be0/internal/events"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
+)
+
+var log = logger.New("notifications")
+
+// KindTeamInvite is the notification kind of invite emails
+const KindTeamInvite = "team_invite"
+
+const (
+	inviteSubject = `You're invited to join {{team}}`
+	inviteHTML    = `<p>Hi {{name}},</p>
+<p>{{inviter | default "A teammate"}} invited you to join {{team}} as {{role | lower}}.</p>
+<p><a href="{{acceptUrl}}">Accept the invitation</a></p>
+<p>Or use the code {{code}}. The invitation expires on {{expiresAt | date "Jan 2, 2006 15:04"}} UTC.</p>`
+	inviteText = "Hi %s,\n\n%s invited you to join %s as %s.\n\n" +
+		"Accept the invitation at %s\nor with the code %s before %s UTC."
+)
+
+// Start subscribes the notification emails to the event bus
+func Start(client *tasks.TaskClient, publicURL string) {
+	for _, event := range []string{events.EventTeamInviteCreated, events.EventTeamInviteResent} {
+		events.OnNamed(event, "notifications.invite_email", func(data interface{}) {
+			data, dryRun := events.Unwrap(data)
+			invite, ok := data.(*models.TeamInvite)
+			if !ok || invite.Team == nil {
+				if dryRun != nil {
+					dryRun.Fail(fmt.Errorf("expected a *models.TeamInvite with its team, got %T", data))
+					return
+				}
+				log.Warn("Unexpected payload %T for invite email", data)
+				return
+			}
+
+			notification, err := InviteEmail(invite, publicURL)
+			if err != nil {
+				if dryRun != nil {
+					dryRun.Fail(err)
+					return
+				}
+				log.Error("Failed to render invite email for invite %s: %v", err, invite.ID)
+				return
+			}
+			if dryRun != nil {
+				log.Info("Dry run of the invite email to %s, nothing enqueued", invite.Email)
+				return
+			}
+
+			if err := client.EnqueueNotificationEmail(context.Background(), notification); err != nil {
+				log.Error("Failed to queue invite email for invite %s: %v", err, invite.ID)
+			}
+		})
+	}
+}
+
+// InviteEmail renders the email inviting the invitee to the team
+func InviteEmail(invite *models.TeamInvite, publicURL string) (tasks.NotificationEmail, error) {
+	acceptURL := AcceptInviteURL(publicURL, invite.Code)
+	vars := map[string]interface{}{
+		"name":      invite.Name,
+		"team":      invite.Team.Name,
+		"role":      string(invite.Role),
+		"code":      invite.Code,
+		"acceptUrl": acceptURL,
+		"expiresAt": invite.ExpiresAt.UTC(),
+	}
+	inviter := "A teammate"
+	if invite.Inviter != nil {
+		if name := strings.TrimSpace(invite.Inviter.FirstName + " " + invite.Inviter.LastName); name != "" {
+			vars["inviter"], inviter = name, name
+		}
+	}
+
+	renderer := services.NewTemplateRenderer(services.TemplateRendererConfig{Strict: true})
+	subject, err := renderer.RenderSubject(inviteSubject, vars)
+	if err != nil {
+		return tasks.NotificationEmail{}, err
+	}
+	body, err := renderer.RenderHTML(inviteHTML, vars)
+	if err != nil {
+		return tasks.NotificationEmail{}, err
+	}
+
+	return tasks.NotificationEmail{
+		Kind: KindTeamInvite,
+		Message: mailer.Message{
+			TeamID:  invite.TeamID,
+			To:      invite.Email,
+			Subject: subject.Subject,
+			HTML:    body.HTML,
+			Text: fmt.Sprintf(inviteText, invite.Name, inviter, invite.Team.Name, strings.ToLower(string(invite.Role)),
+				acceptURL, invite.Code, invite.ExpiresAt.UTC().Format("2006-01-02 15:04")),
+		},
+		ActionURL: acceptURL,
+	}, nil
+}
+
+// AcceptInviteURL is the page of the app at publicURL where an invite is accepted.
+// It posts the code to /api/v1/auth/accept/:code.
+func AcceptInviteURL(publicURL, code string) string {
+	return strings.TrimSuffix(publicURL, "/") + "/accept-invite?code=" + url.QueryEscape(code)
+}
@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/testutil"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestInviteEmailReachesTheInvitee(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	_, redisServer := testutil.NewRedis(t)
+	smtpServer := testutil.NewSMTPServer(t)
+	mailer.RegisterSender(mailer.NewSMTPSender(mailer.SMTPConfig{Host: smtpServer.Host, Port: smtpServer.Port, From: "noreply@be0.test"}))
+	t.Cleanup(func() { mailer.RegisterSender(nil) })
+
+	redisConfig := config.RedisConfig{Addr: redisServer.Addr()}
+	client := tasks.NewTaskClient(redisConfig)
+	t.Cleanup(func() { client.Close() })
+	Start(client, "https://app.example.com/")
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	inviter.FirstName, inviter.LastName = "Ada", "Lovelace"
+	invite := &models.TeamInvite{
+		TeamID:    team.ID,
+		Team:      team,
+		Email:     "invitee@example.com",
+		Name:      "Grace",
+		Role:      models.UserRoleMember,
+		Code:      "ABC123",
+		Status:    models.InviteStatusPending,
+		InviterID: inviter.ID,
+		Inviter:   inviter,
+		ExpiresAt: time.Date(2030, 1, 2, 15, 4, 0, 0, time.UTC),
+	}
+	events.Emit(events.EventTeamInviteCreated, invite)
+
+	// 📬 The event queues a notification email, the worker's part is handing it to the mailer
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisServer.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+	var queued []*asynq.TaskInfo
+	for deadline := time.Now().Add(2 * time.Second); len(queued) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no notification email was queued")
+		}
+		queued, _ = inspector.ListPendingTasks(tasks.QueueCritical)
+	}
+	if len(queued) != 1 || queued[0].Type != tasks.TaskTypeNotificationEmail {
+		t.Fatalf("queued %+v, want one %s task", queued, tasks.TaskTypeNotificationEmail)
+	}
+	var notification tasks.NotificationEmail
+	if err := json.Unmarshal(queued[0].Payload, &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Kind != KindTeamInvite || notification.ActionURL != "https://app.example.com/accept-invite?code=ABC123" {
+		t.Errorf("got kind %q and action URL %q", notification.Kind, notification.ActionURL)
+	}
+	if err := mailer.Send(context.Background(), gdb, notification.Message); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := smtpServer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("the SMTP server got %d messages, want 1", len(messages))
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(messages[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"To: <invitee@example.com>",
+		"Subject: You're invited to join Acme",
+		"Ada Lovelace invited you to join Acme as member.",
+		// html/template escapes the = of the query in attributes, browsers decode it
+		`<a href="https://app.example.com/accept-invite?code&#61;ABC123">`,
+		"Or use the code ABC123. The invitation expires on Jan 2, 2030 15:04 UTC.",
+		"Accept the invitation at https://app.example.com/accept-invite?code=ABC123",
+	} {
+		if !strings.Contains(string(decoded), want) {
+			t.Errorf("the message is missing %q:\n%s", want, decoded)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/utils"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// oauthProfile is the identity an OAuth provider vouched for
+type oauthProfile struct {
+	Method        models.AuthMethod
+	ID            string
+	Email         string
+	FirstName     string
+	LastName      string
+	EmailVerified bool
+	PhotoURL      string
+}
+
+// oauthSignIn signs in the user behind an OAuth profile. Unknown users join the team of a
+// pending invite or get a team of their own, local accounts with the same email are linked.
+// Invites and accounts are only matched by email when the provider verified the address.
+func (h *AuthHandler) oauthSignIn(c echo.Context, profile oauthProfile) error {
+	provider := string(profile.Method)
+
+	// Start a transaction
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+
+	// Check if user exists with the provider ID, then with the email
+	var user models.User
+	err := tx.Where("provider = ? AND provider_id = ?", provider, profile.ID).First(&user).Error
+	linked := err == nil
+	if err == gorm.ErrRecordNotFound {
+		err = tx.Where("email = ?", profile.Email).First(&user).Error
+	}
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Check for pending team invitation first
+			invite, inviteErr := models.GetLatestPendingInvite(profile.Email, tx)
+
+			// 📧 Anyone can put an unverified address on a provider account, it can't claim an invite
+			if inviteErr == nil && !profile.EmailVerified {
+				tx.Rollback()
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Your email address is not verified by the provider, accept the invitation with a password instead",
+					"code":  "email_not_verified",
+				})
+			}
+
+			var team *models.Team
+			var teamID string
+			var userRole models.UserRole
+
+			if inviteErr == nil {
+				// Use the invited team and role, capped at the inviter's role
+				teamID = invite.TeamID
+				if team, err = models.GetTeamByID(teamID, tx); err != nil {
+					tx.Rollback()
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to accept invitation"})
+				}
+				if userRole, err = models.InviteRole(invite, "", tx); err != nil {
+					tx.Rollback()
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to accept invitation"})
+				}
+
+				// Mark invitation as accepted
+				if err := acceptInvite(tx, invite); err != nil {
+					tx.Rollback()
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update invitation"})
+				}
+			} else {
+				// No invitation found, create new team
+				team, err = services.CreateTeamForUser(tx, profile.FirstName)
+				if err != nil {
+					tx.Rollback()
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create team"})
+				}
+
+				teamID = team.ID
+				userRole = models.UserRoleAdmin
+			}
+
+			// 🔐 The team may require another sign-in method, no account is created for this one
+			if !team.AuthPolicy.Allows(profile.Method) {
+				tx.Rollback()
+				return authMethodNotAllowed(c, team)
+			}
+
+			var fileModel *models.File
+			// download the profile picture
+			if profile.PhotoURL != "" {
+				profilePicture, err := http.Get(profile.PhotoURL)
+				if err != nil {
+					// Log the error but do not affect account creation
+					h.log.Error("Failed to download profile picture", err)
+				} else {
+					defer profilePicture.Body.Close()
+					// read the profile picture
+					profilePictureBytes, err := io.ReadAll(profilePicture.Body)
+					if err != nil {
+						h.log.Error("Failed to read profile picture", err)
+					} else {
+						// Get storage handler
+						storage := GetStorageHandler()
+						if storage != nil {
+							// Create a temporary user ID since we don't have the real one yet
+							tempUserID := uuid.New().String()
+							acl := storage.EffectiveACL(types.ObjectCannedACL(config.GetConfig().Storage.DefaultACL))
+							var profilePictureURL string
+							err := team.CheckACL(string(acl))
+							if err == nil {
+								// upload the profile picture to s3
+								profilePictureURL, err = storage.UploadFile(c.Request().Context(), profilePictureBytes, tempUserID, acl, "image/jpeg")
+							}
+							if err != nil {
+								h.log.Error("Failed to upload profile picture", err)
+							} else {
+								fileModel = &models.File{
+									TeamID:    teamID,
+									Path:      profilePictureURL[strings.LastIndex(profilePictureURL, "/")+1:],
+									Name:      "profile_picture.jpg",
+									Size:      int64(len(profilePictureBytes)),
+									Type:      "image/jpeg",
+									Extension: "jpg",
+									ACL:       string(acl),
+								}
+								if err := tx.Create(fileModel).Error; err != nil {
+									h.log.Error("Failed to create profile picture", err)
+									fileModel = nil
+								}
+							}
+						} else {
+							h.log.Error("Storage handler not configured", nil)
+						}
+					}
+				}
+			}
+
+			// Create user with both provider and local auth capabilities
+			user = models.User{
+				Email:      profile.Email,
+				FirstName:  profile.FirstName,
+				LastName:   profile.LastName,
+				Role:       userRole,
+				TeamID:     teamID,
+				Provider:   provider,
+				ProviderID: profile.ID,
+				Password:   "", // Empty password for OAuth users
+				// skip provider data for now
+				ProviderData: datatypes.JSON{},
+			}
+
+			// The provider has verified the address already
+			if profile.EmailVerified {
+				verifiedAt := time.Now().UTC()
+				user.EmailVerified = true
+				user.EmailVerifiedAt = &verifiedAt
+			}
+
+			// Only set ProfilePictureID if we successfully created the file
+			if fileModel != nil && fileModel.ID != "" {
+				user.ProfilePictureID = fileModel.ID
+			} else {
+				user.ProfilePictureID = models.DefaultProfilePictureID
+			}
+
+			if err := tx.Create(&user).Error; err != nil {
+				tx.Rollback()
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
+			}
+
+			// Assign default permissions
+			if err := models.AssignDefaultPermissions(tx, &user); err != nil {
+				tx.Rollback()
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign permissions"})
+			}
+
+			// Emit different events based on invitation status
+			if inviteErr == nil {
+				events.Emit("users.invite_accepted", &user)
+			} else {
+				events.Emit("users.created", &user)
+			}
+		} else {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check user existence"})
+		}
+	} else {
+		// 📧 Only the provider identity linked to the account, or a verified address, signs in
+		if !linked && !profile.EmailVerified {
+			tx.Rollback()
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "An account with this email already exists, sign in with your password to link it",
+				"code":  "local_login_required",
+			})
+		}
+
+		// 🚫 Soft-deleted and deactivated accounts and members of deleted teams cannot sign in
+		team, teamErr := models.GetTeamByID(user.TeamID, tx)
+		if user.IsDeleted || !user.IsActive || teamErr != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Account is disabled"})
+		}
+
+		// 🔐 The team may require another sign-in method
+		if !team.AuthPolicy.Allows(profile.Method) {
+			tx.Rollback()
+			return authMethodNotAllowed(c, team)
+		}
+
+		// If user exists but hasn't used this provider before, link the accounts
+		if user.Provider == "local" {
+			user.Provider = provider
+			user.ProviderID = profile.ID
+			if user.ProfilePictureID == "" {
+				user.ProfilePictureID = models.DefaultProfilePictureID
+			}
+			if err := tx.Save(&user).Error; err != nil {
+				tx.Rollback()
+				h.log.Error("Failed to update user", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update user"})
+			}
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	// 🔢 The token pair is only issued once the second factor is checked
+	if user.TwoFactorEnabled {
+		return twoFactorChallenge(c, &user)
+	}
+
+	events.Emit("users."+provider+"_auth", &user)
+
+	return h.signIn(c, user)
+}
+
+// bearerToken reads the provider access token from the Authorization header
+func bearerToken(c echo.Context) string {
+	return strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+}
+
+// MicrosoftAuthCallback handles authentication with Microsoft / Azure AD
+// @Summary Authenticate with Microsoft
+// @Description Authenticate user using a Microsoft Graph access token passed as a bearer token
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string "JWT token"
+// @Failure 400 {object} map[string]string "No access token provided"
+// @Failure 401 {object} map[string]string "Failed to get user data from Microsoft"
+// @Failure 403 {object} map[string]string "Tenant not allowed"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/microsoft/callback [get]
+func (h *AuthHandler) MicrosoftAuthCallback(c echo.Context) error {
+	accessToken := bearerToken(c)
+	if accessToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No access token provided"})
+	}
+
+	// get user data from microsoft graph, which also validates the token
+	userData, err := utils.GetUserDataFromMicrosoft(accessToken)
+	if err != nil {
+		h.log.Warn("Failed to get user data from Microsoft: %v", err)
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Failed to get user data from Microsoft"})
+	}
+
+	// 🏢 Only users of allowed Azure tenants may sign in when an allowlist is set. The
+	// allowed tenants manage their users' addresses, so those count as verified.
+	emailVerified := false
+	if len(h.microsoftTenants) > 0 {
+		tenantID, err := utils.GetMicrosoftTenantID(accessToken)
+		if err != nil {
+			h.log.Warn("Failed to get Microsoft tenant: %v", err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Failed to get user data from Microsoft"})
+		}
+		if !slices.Contains(h.microsoftTenants, tenantID) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "Your Microsoft organization is not allowed to sign in",
+				"code":  "tenant_not_allowed",
+			})
+		}
+		emailVerified = true
+	}
+
+	// ✅ Otherwise the token says whether the tenant verified the domain of the address
+	if !emailVerified {
+		emailVerified = utils.MicrosoftEmailVerified(accessToken)
+	}
+
+	return h.oauthSignIn(c, oauthProfile{
+		Method:        models.AuthMethodMicrosoft,
+		ID:            userData.ID,
+		Email:         strings.ToLower(userData.Email()),
+		FirstName:     userData.GivenName,
+		LastName:      userData.Surname,
+		EmailVerified: emailVerified,
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// oauthCall signs in with an OAuth profile
+func oauthCall(t *testing.T, h *AuthHandler, profile oauthProfile) (int, map[string]interface{}) {
+	t.Helper()
+	c, rec := newContext(t, http.MethodGet, "/auth/microsoft/callback", nil)
+	if err := h.oauthSignIn(c, profile); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, decode(t, rec)
+}
+
+func microsoftProfile(email string, verified bool) oauthProfile {
+	return oauthProfile{
+		Method:        models.AuthMethodMicrosoft,
+		ID:            "ms-" + email,
+		Email:         email,
+		FirstName:     "Mallory",
+		EmailVerified: verified,
+	}
+}
+
+func reloadUser(t *testing.T, gdb *gorm.DB, id string) *models.User {
+	t.Helper()
+	var user models.User
+	if err := gdb.First(&user, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestOAuthLinksExistingAccountsOnlyWithVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		status   int
+		code     string
+		provider string
+	}{
+		{name: "verified", verified: true, status: http.StatusOK, provider: string(models.AuthMethodMicrosoft)},
+		{name: "unverified", verified: false, status: http.StatusConflict, code: "local_login_required", provider: "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.UseJWTSecret(t)
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+			gdb.Model(user).Update("provider", "local")
+
+			status, body := oauthCall(t, h, microsoftProfile(user.Email, tt.verified))
+			if status != tt.status {
+				t.Fatalf("status %d %v, want %d", status, body, tt.status)
+			}
+			if tt.code != "" && body["code"] != tt.code {
+				t.Errorf("code %v, want %s", body["code"], tt.code)
+			}
+			if tt.status == http.StatusOK && body["token"] == nil {
+				t.Errorf("got %v, want a token", body)
+			}
+			if got := reloadUser(t, gdb, user.ID).Provider; got != tt.provider {
+				t.Errorf("provider %q, want %q", got, tt.provider)
+			}
+		})
+	}
+}
+
+func TestOAuthLinkedIdentitySignsInWithoutVerifiedEmail(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	profile := microsoftProfile(user.Email, false)
+	gdb.Model(user).Updates(map[string]interface{}{"provider": string(profile.Method), "provider_id": profile.ID})
+
+	if status, body := oauthCall(t, h, profile); status != http.StatusOK || body["token"] == nil {
+		t.Fatalf("status %d %v, want a session for the linked identity", status, body)
+	}
+}
+
+func TestOAuthAcceptsInvitesOnlyWithVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		status   int
+		accepted bool
+	}{
+		{name: "verified", verified: true, status: http.StatusOK, accepted: true},
+		{name: "unverified", verified: false, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.UseJWTSecret(t)
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+			invite := &models.TeamInvite{
+				TeamID:    team.ID,
+				Email:     "invitee@example.com",
+				Name:      "Invitee",
+				Role:      models.UserRoleMember,
+				Code:      "invite-code",
+				Status:    models.InviteStatusPending,
+				InviterID: inviter.ID,
+				ExpiresAt: time.Now().UTC().Add(time.Hour),
+			}
+			if err := gdb.Create(invite).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			status, body := oauthCall(t, h, microsoftProfile(invite.Email, tt.verified))
+			if status != tt.status {
+				t.Fatalf("status %d %v, want %d", status, body, tt.status)
+			}
+
+			var joined int64
+			gdb.Model(&models.User{}).Where("email = ? AND team_id = ?", invite.Email, team.ID).Count(&joined)
+			if (joined == 1) != tt.accepted {
+				t.Errorf("%d invitee accounts in the team, want accepted %v", joined, tt.accepted)
+			}
+			var reloaded models.TeamInvite
+			gdb.First(&reloaded, "id = ?", invite.ID)
+			if (reloaded.Status == models.InviteStatusAccepted) != tt.accepted {
+				t.Errorf("invite status %s, want accepted %v", reloaded.Status, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestOAuthInviteIntoATeamThatRequiresPasswords(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	if rec := invite(t, h, admin, "invitee@example.com"); rec.Code != http.StatusCreated {
+		t.Fatalf("invite got %d %s", rec.Code, rec.Body)
+	}
+	team.AuthPolicy.AllowedMethods = []string{string(models.AuthMethodPassword)}
+	if err := gdb.Save(team).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := oauthCall(t, h, microsoftProfile("invitee@example.com", true))
+	if status != http.StatusForbidden || body["code"] != "auth_method_not_allowed" {
+		t.Fatalf("status %d %v, want 403 auth_method_not_allowed", status, body)
+	}
+
+	// 📨 No account is created and the invite can still be accepted with a password
+	var accounts int64
+	gdb.Model(&models.User{}).Where("email = ?", "invitee@example.com").Count(&accounts)
+	if accounts != 0 {
+		t.Errorf("%d invitee accounts, want none", accounts)
+	}
+	if _, err := models.GetLatestPendingInvite("invitee@example.com", gdb); err != nil {
+		t.Errorf("the invite is no longer pending: %v", err)
+	}
+}
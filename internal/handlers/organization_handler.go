@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrganizationHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewOrganizationHandler(db *gorm.DB) *OrganizationHandler {
+	return &OrganizationHandler{db: db, log: logger.New("OrganizationHandler")}
+}
+
+// CreateOrganizationRequest names a new organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=2"`
+}
+
+// CreateTeamRequest names a new team of an organization
+type CreateTeamRequest struct {
+	Name string `json:"name" validate:"required,min=2"`
+}
+
+// CreateOrganization creates an organization around the current team
+// @Summary Create organization
+// @Description Create an organization with the current team as its first team and the caller as its admin. Organization admins act as admins in every team of the organization.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body CreateOrganizationRequest true "Organization name"
+// @Success 201 {object} models.Organization
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 409 {object} map[string]string "The team already belongs to an organization"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /organizations [post]
+func (h *OrganizationHandler) CreateOrganization(c echo.Context) error {
+	var req CreateOrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	teamID := middleware.GetTeamID(c)
+	organization := models.Organization{Name: req.Name}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// 🔒 Concurrent requests cannot put the team in two organizations
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_deleted = false", teamID).First(&team).Error; err != nil {
+			return err
+		}
+		if team.OrganizationID != nil {
+			return models.ErrConflict
+		}
+
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		if err := models.SetTeamOrganization(tx, teamID, organization.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationAdmin{OrganizationID: organization.ID, UserID: middleware.GetUserID(c)}).Error
+	})
+	switch {
+	case errors.Is(err, models.ErrConflict):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The team already belongs to an organization"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	case err != nil:
+		h.log.Error("Failed to create organization: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create organization"})
+	}
+
+	if err := h.db.Preload("Teams", "is_deleted = false").First(&organization, "id = ?", organization.ID).Error; err != nil {
+		h.log.Error("Failed to load organization: %v", err)
+	}
+	return c.JSON(http.StatusCreated, organization)
+}
+
+// GetOrganization returns an organization with its teams
+// @Summary Get organization
+// @Description Get an organization the caller administers, with its teams
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.Organization
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /organizations/{id} [get]
+func (h *OrganizationHandler) GetOrganization(c echo.Context) error {
+	organization, err := h.findAdministered(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	if err := h.db.Where("organization_id = ? AND is_deleted = false", organization.ID).
+		Order("name").Find(&organization.Teams).Error; err != nil {
+		h.log.Error("Failed to load organization teams: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load organization"})
+	}
+	return c.JSON(http.StatusOK, organization)
+}
+
+// CreateTeam creates a team inside an organization
+// @Summary Create organization team
+// @Description Create a team inside an organization the caller administers. The team starts without members, organization admins can switch to it and invite people.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param request body CreateTeamRequest true "Team name"
+// @Success 201 {object} models.Team
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /organizations/{id}/teams [post]
+func (h *OrganizationHandler) CreateTeam(c echo.Context) error {
+	organization, err := h.findAdministered(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	var req CreateTeamRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var team *models.Team
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if team, err = services.CreateTeam(tx, req.Name); err != nil {
+			return err
+		}
+		return models.SetTeamOrganization(tx, team.ID, organization.ID)
+	}); err != nil {
+		h.log.Error("Failed to create organization team: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create team"})
+	}
+	team.OrganizationID = &organization.ID
+
+	return c.JSON(http.StatusCreated, team)
+}
+
+// AttachTeam moves an existing team into an organization
+// @Summary Attach team to organization
+// @Description Attach a standalone team to an organization the caller administers. The caller must also be an admin of the team, super admins may attach any team.
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param teamId path string true "Team ID"
+// @Success 200 {object} map[string]string "Team attached"
+// @Failure 403 {object} map[string]string "Not an admin of the team"
+// @Failure 404 {object} map[string]string "Organization or team not found"
+// @Failure 409 {object} map[string]string "The team already belongs to an organization"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /organizations/{id}/teams/{teamId} [put]
+func (h *OrganizationHandler) AttachTeam(c echo.Context) error {
+	organization, err := h.findAdministered(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	// 🛡️ A team only joins with the consent of one of its admins
+	var caller models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", middleware.GetUserID(c)).First(&caller).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	teamID := c.Param("teamId")
+	if caller.Role != models.UserRoleSuperAdmin && (caller.TeamID != teamID || caller.Role != models.UserRoleAdmin) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only an admin of the team can attach it"})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_deleted = false", teamID).First(&team).Error; err != nil {
+			return err
+		}
+		if team.OrganizationID != nil {
+			return models.ErrConflict
+		}
+		return models.SetTeamOrganization(tx, teamID, organization.ID)
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	case errors.Is(err, models.ErrConflict):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The team already belongs to an organization"})
+	case err != nil:
+		h.log.Error("Failed to attach team: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to attach team"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Team attached"})
+}
+
+// DetachTeam makes a team of an organization standalone again
+// @Summary Detach team from organization
+// @Description Detach a team from an organization the caller administers. Organization admins lose access to it, its own members keep theirs.
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param teamId path string true "Team ID"
+// @Success 200 {object} map[string]string "Team detached"
+// @Failure 404 {object} map[string]string "Organization or team not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /organizations/{id}/teams/{teamId} [delete]
+func (h *OrganizationHandler) DetachTeam(c echo.Context) error {
+	organization, err := h.findAdministered(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Team{}).
+		Where("id = ? AND organization_id = ? AND is_deleted = false", c.Param("teamId"), organization.ID).
+		Count(&count).Error; err != nil {
+		h.log.Error("Failed to load team: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to detach team"})
+	}
+	if count == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	}
+
+	if err := models.SetTeamOrganization(h.db, c.Param("teamId"), ""); err != nil {
+		h.log.Error("Failed to detach team: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to detach team"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Team detached"})
+}
+
+// GetUsage adds up the usage of an organization's teams
+// @Summary Get organization usage
+// @Description Members, pending invites, files and stored bytes per team of an organization the caller administers, with totals
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.OrganizationUsage
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /organizations/{id}/usage [get]
+func (h *OrganizationHandler) GetUsage(c echo.Context) error {
+	organization, err := h.findAdministered(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	usage, err := models.GetOrganizationUsage(h.db, organization.ID)
+	if err != nil {
+		h.log.Error("Failed to load organization usage: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load usage"})
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+// findAdministered loads an organization the caller administers, super admins administer all.
+// Organizations of others are reported as not found.
+func (h *OrganizationHandler) findAdministered(c echo.Context, id string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := h.db.Where("id = ? AND is_deleted = false", id).First(&organization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Organization not found")
+		}
+		h.log.Error("Failed to load organization: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+
+	if middleware.GetUserRole(c) == string(models.UserRoleSuperAdmin) {
+		return &organization, nil
+	}
+	isAdmin, err := models.IsOrganizationAdmin(h.db, organization.ID, middleware.GetUserID(c))
+	if err != nil {
+		h.log.Error("Failed to check organization admin: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+	if !isAdmin {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Organization not found")
+	}
+	return &organization, nil
+}
+
+// SwitchTeam issues a session for another team the caller may act in
+// @Summary Switch team
+// @Description Sign in to another team with a new token pair. Users can switch to their own team, organization admins to any team of their organization. The current session stays valid.
+// @Tags auth
+// @Produce json
+// @Param teamId path string true "Team ID"
+// @Success 200 {object} map[string]string "JWT token pair for the team"
+// @Failure 403 {object} map[string]string "Impersonation sessions cannot switch teams"
+// @Failure 404 {object} map[string]string "Team not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/switch-team/{teamId} [post]
+func (h *AuthHandler) SwitchTeam(c echo.Context) error {
+	// 🕵️ A switch would turn an audited impersonation into a regular session
+	if middleware.GetImpersonator(c) != "" {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Impersonation sessions cannot switch teams"})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", middleware.GetUserID(c)).First(&user).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "User not found"})
+	}
+
+	team, _, err := models.TeamAccess(h.db, &user, c.Param("teamId"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, models.ErrNoTeamAccess):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Team not found"})
+	case err != nil:
+		h.log.Error("Failed to check team access: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to switch team"})
+	}
+
+	// The session is issued for the team, the user stays a member of their own
+	user.TeamID = team.ID
+	return h.issueSession(c, user)
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/lockout"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// issueResetCode stores a reset code for the user like RequestPasswordReset does
+func issueResetCode(t *testing.T, gdb *gorm.DB, userID, code string, expiresAt time.Time) *models.PasswordReset {
+	t.Helper()
+	reset := &models.PasswordReset{UserID: userID, CodeHash: models.HashResetCode(code), ExpiresAt: expiresAt}
+	if err := gdb.Create(reset).Error; err != nil {
+		t.Fatal(err)
+	}
+	return reset
+}
+
+// httpResult is the status and Retry-After header of a response
+type httpResult struct {
+	status     int
+	retryAfter string
+}
+
+func requestReset(t *testing.T, h *AuthHandler, email string) *httpResult {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/auth/password-reset", ResetPasswordRequest{Email: email})
+	if err := h.RequestPasswordReset(c); err != nil {
+		t.Fatal(err)
+	}
+	return &httpResult{status: rec.Code, retryAfter: rec.Header().Get("Retry-After")}
+}
+
+func verifyReset(t *testing.T, h *AuthHandler, code, password string) int {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/auth/password-reset/verify", VerifyResetCodeRequest{Code: code, Password: password})
+	if err := h.VerifyResetCode(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code
+}
+
+func liveSessions(t *testing.T, gdb *gorm.DB, userID string) int64 {
+	t.Helper()
+	var count int64
+	if err := gdb.Model(&models.AuthTransaction{}).Where("user_id = ? AND is_deleted = false", userID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRequestPasswordResetStoresOnlyTheNewestHashedCode(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	for i := 0; i < 2; i++ {
+		if result := requestReset(t, h, user.Email); result.status != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, result.status)
+		}
+	}
+
+	var resets []models.PasswordReset
+	if err := gdb.Where("user_id = ?", user.ID).Order("created_at").Find(&resets).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(resets) != 2 {
+		t.Fatalf("%d reset rows, want 2", len(resets))
+	}
+	if !resets[0].Superseded || resets[1].Superseded {
+		t.Errorf("superseded %v and %v, want only the first code superseded", resets[0].Superseded, resets[1].Superseded)
+	}
+	for _, reset := range resets {
+		if len(reset.CodeHash) != 64 {
+			t.Errorf("code hash %q is not a SHA-256 hex digest", reset.CodeHash)
+		}
+	}
+}
+
+func TestRequestPasswordResetIsThrottledPerEmail(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	client, _ := testutil.NewRedis(t)
+	const maxResets = 3
+	h := NewAuthHandler(gdb, config.JWTConfig{MaxSessions: 5}, config.AuthConfig{},
+		lockout.NewLimiter(nil, "login", 0, 0), lockout.NewLimiter(client, "reset", maxResets, time.Hour), nil)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{name: "known email", email: user.Email},
+		// Unknown addresses hit the same cap, so the 429 doesn't reveal accounts
+		{name: "unknown email", email: "nobody@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < maxResets; i++ {
+				if result := requestReset(t, h, tt.email); result.status != http.StatusOK {
+					t.Fatalf("request %d: status %d, want 200", i+1, result.status)
+				}
+			}
+			result := requestReset(t, h, tt.email)
+			if result.status != http.StatusTooManyRequests {
+				t.Fatalf("request %d: status %d, want 429", maxResets+1, result.status)
+			}
+			if seconds, err := strconv.Atoi(result.retryAfter); err != nil || seconds <= 0 {
+				t.Errorf("Retry-After %q, want a positive number of seconds", result.retryAfter)
+			}
+		})
+	}
+
+	// Throttled requests don't issue codes
+	var count int64
+	gdb.Model(&models.PasswordReset{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != maxResets {
+		t.Errorf("%d codes issued, want %d", count, maxResets)
+	}
+}
+
+func TestPasswordResetCapEndsWithTheHour(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	client, clock := testutil.NewRedis(t)
+	h := NewAuthHandler(gdb, config.JWTConfig{MaxSessions: 5}, config.AuthConfig{},
+		lockout.NewLimiter(nil, "login", 0, 0), lockout.NewLimiter(client, "reset", 2, time.Hour), nil)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		status  int
+	}{
+		{"first", 0, http.StatusOK},
+		{"second", 10 * time.Minute, http.StatusOK},
+		{"over the cap", 10 * time.Minute, http.StatusTooManyRequests},
+		// ⏳ The window started with the first request, a minute before it ends is still capped
+		{"a minute before the hour", 39 * time.Minute, http.StatusTooManyRequests},
+		{"after the hour", time.Minute, http.StatusOK},
+	}
+	for _, step := range steps {
+		clock.FastForward(step.advance)
+		result := requestReset(t, h, user.Email)
+		if result.status != step.status {
+			t.Fatalf("%s: status %d, want %d", step.name, result.status, step.status)
+		}
+		if step.status == http.StatusTooManyRequests {
+			if seconds, err := strconv.Atoi(result.retryAfter); err != nil || seconds <= 0 || seconds > 3600 {
+				t.Errorf("%s: Retry-After %q, want the rest of the hour", step.name, result.retryAfter)
+			}
+		}
+	}
+}
+
+func TestNewResetRequestSupersedesEarlierCodes(t *testing.T) {
+	const code = "earlier-code"
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	earlier := issueResetCode(t, gdb, user.ID, code, time.Now().UTC().Add(time.Hour))
+
+	if result := requestReset(t, h, user.Email); result.status != http.StatusOK {
+		t.Fatalf("status %d", result.status)
+	}
+
+	// 🔁 The earlier code was still valid, the new request retired it
+	if status := verifyReset(t, h, code, "a-new-password"); status != http.StatusBadRequest {
+		t.Errorf("the superseded code got %d, want 400", status)
+	}
+	var reloaded models.PasswordReset
+	if err := gdb.First(&reloaded, "id = ?", earlier.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Superseded || reloaded.Used {
+		t.Errorf("earlier code superseded %v used %v, want superseded and unused", reloaded.Superseded, reloaded.Used)
+	}
+}
+
+func TestVerifyResetCode(t *testing.T) {
+	const code, password = "123456", "a-new-password"
+
+	tests := []struct {
+		name   string
+		issue  func(t *testing.T, gdb *gorm.DB, user *models.User)
+		status int
+	}{
+		{
+			name: "valid",
+			issue: func(t *testing.T, gdb *gorm.DB, user *models.User) {
+				issueResetCode(t, gdb, user.ID, code, time.Now().UTC().Add(time.Hour))
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "expired",
+			issue: func(t *testing.T, gdb *gorm.DB, user *models.User) {
+				issueResetCode(t, gdb, user.ID, code, time.Now().UTC().Add(-time.Minute))
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "superseded",
+			issue: func(t *testing.T, gdb *gorm.DB, user *models.User) {
+				reset := issueResetCode(t, gdb, user.ID, code, time.Now().UTC().Add(time.Hour))
+				gdb.Model(reset).Update("superseded", true)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown",
+			issue:  func(*testing.T, *gorm.DB, *models.User) {},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.UseJWTSecret(t)
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+			login(t, h, user)
+			tt.issue(t, gdb, user)
+
+			if status := verifyReset(t, h, code, password); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+
+			reloaded := reloadUser(t, gdb, user.ID)
+			changed := bcrypt.CompareHashAndPassword([]byte(reloaded.Password), []byte(password)) == nil
+			if changed != (tt.status == http.StatusOK) {
+				t.Errorf("password changed %v, want %v", changed, tt.status == http.StatusOK)
+			}
+			// 🚪 A reset signs the user out everywhere, a rejected code changes nothing
+			sessions := liveSessions(t, gdb, user.ID)
+			if tt.status == http.StatusOK && sessions != 0 {
+				t.Errorf("%d sessions left after the reset, want 0", sessions)
+			}
+			if tt.status != http.StatusOK && sessions != 1 {
+				t.Errorf("%d sessions after a rejected code, want 1", sessions)
+			}
+		})
+	}
+}
+
+func TestVerifyResetCodeIsSingleUse(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	issueResetCode(t, gdb, user.ID, "654321", time.Now().UTC().Add(time.Hour))
+
+	if status := verifyReset(t, h, "654321", "first-new-password"); status != http.StatusOK {
+		t.Fatalf("first use: status %d, want 200", status)
+	}
+	if status := verifyReset(t, h, "654321", "second-new-password"); status != http.StatusBadRequest {
+		t.Fatalf("reuse: status %d, want 400", status)
+	}
+
+	reloaded := reloadUser(t, gdb, user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(reloaded.Password), []byte("first-new-password")) != nil {
+		t.Error("the reused code changed the password")
+	}
+}
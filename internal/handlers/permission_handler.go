@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"be0/internal/api/middleware"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type PermissionHandler struct {
+	db   *gorm.DB
+	auth *middleware.AuthMiddleware
+	log  *logger.Logger
+}
+
+func NewPermissionHandler(db *gorm.DB, auth *middleware.AuthMiddleware) *PermissionHandler {
+	return &PermissionHandler{db: db, auth: auth, log: logger.New("PermissionHandler")}
+}
+
+// WhyDeniedRequest names the request to explain
+type WhyDeniedRequest struct {
+	Method string `validate:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path   string `validate:"required,startswith=/api/v1/,max=2048"`
+}
+
+// WhyDeniedResponse explains whether the caller may make a request
+type WhyDeniedResponse struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	*middleware.PermissionDecision
+}
+
+// WhyDenied explains the permission check of a request without making it
+// @Summary Explain a permission check
+// @Description Dry-run the resource permission check of a request for the caller and explain the outcome step by step: the scope the route needs, the caller's role, the API key's permissions and the caller's grants. A denied request answers 403 with the same required_scope and your_scopes. Routes may declare further permissions.
+// @Tags auth
+// @Produce json
+// @Param method query string true "Request method" Enums(GET, POST, PUT, PATCH, DELETE)
+// @Param path query string true "Request path, e.g. /api/v1/files"
+// @Success 200 {object} WhyDeniedResponse
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/auth/why-denied [get]
+func (h *PermissionHandler) WhyDenied(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "method", "path"); err != nil {
+		return err
+	}
+	req := WhyDeniedRequest{Method: strings.ToUpper(c.QueryParam("method")), Path: c.QueryParam("path")}
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// 🔍 The same resolution as the request itself, without making it
+	decision, err := h.auth.ExplainPermission(c, h.db, req.Method, req.Path)
+	if err != nil {
+		h.log.Error("Failed to explain permissions: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to explain permissions"})
+	}
+
+	return c.JSON(http.StatusOK, WhyDeniedResponse{Method: req.Method, Path: req.Path, PermissionDecision: decision})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestWhyDenied(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := NewPermissionHandler(gdb, middleware.NewAuthMiddleware(testutil.JWTSecret))
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	explain := func(method, path string) (int, map[string]interface{}) {
+		t.Helper()
+		query := url.Values{"method": {method}, "path": {path}}
+		c, rec := newContext(t, http.MethodGet, "/auth/why-denied?"+query.Encode(), nil)
+		c.Set("userID", member.ID)
+		c.Set("role", string(member.Role))
+		c.Set("isAPIKey", false)
+		if err := h.WhyDenied(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		return rec.Code, decode(t, rec)
+	}
+
+	// 🔍 Members read files but do not create them
+	status, body := explain("get", "/api/v1/files")
+	if status != http.StatusOK || body["allowed"] != true || body["method"] != http.MethodGet {
+		t.Errorf("reading files: %d %v", status, body)
+	}
+	status, body = explain(http.MethodPost, "/api/v1/files")
+	if status != http.StatusOK || body["allowed"] != false || body["missingScope"] != "files:create" {
+		t.Errorf("creating a file: %d %v", status, body)
+	}
+	if steps, _ := body["steps"].([]interface{}); len(steps) != 3 {
+		t.Errorf("got steps %v, want the route, the role and the grants", body["steps"])
+	}
+
+	for _, tt := range []struct{ method, path string }{
+		{"", "/api/v1/files"},
+		{"TRACE", "/api/v1/files"},
+		{http.MethodGet, ""},
+		{http.MethodGet, "/swagger/doc.json"},
+	} {
+		if status, _ := explain(tt.method, tt.path); status != http.StatusBadRequest {
+			t.Errorf("%q %q: got %d, want 400", tt.method, tt.path, status)
+		}
+	}
+}
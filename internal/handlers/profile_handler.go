@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProfileHandler struct {
+	db         *gorm.DB
+	taskClient *tasks.TaskClient
+	log        *logger.Logger
+}
+
+func NewProfileHandler(db *gorm.DB, taskClient *tasks.TaskClient) *ProfileHandler {
+	return &ProfileHandler{db: db, taskClient: taskClient, log: logger.New("ProfileHandler")}
+}
+
+type UpdateProfilePictureRequest struct {
+	FileID string `json:"fileId" validate:"required,uuid"`
+}
+
+// UpdateProfilePicture replaces the current user's profile picture
+// @Summary Update profile picture
+// @Description Set an uploaded image as the current user's profile picture. The previous picture is cleaned up in the background.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body UpdateProfilePictureRequest true "Uploaded image file ID"
+// @Success 200 {object} models.UserResponse
+// @Failure 400 {object} map[string]string "Validation error or file is not an image"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/me/profile-picture [put]
+func (h *ProfileHandler) UpdateProfilePicture(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+	teamID := middleware.GetTeamID(c)
+
+	var req UpdateProfilePictureRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// 🔍 The new picture must be an image uploaded by this user within their team, files
+	// uploaded with an API key belong to nobody and are refused too
+	file, err := models.GetFileByID(req.FileID, h.db)
+	if err != nil || file.TeamID != teamID || file.UserID == nil || *file.UserID != userID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "File not found"})
+	}
+
+	if !strings.HasPrefix(file.Type, "image/") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Profile picture must be an image"})
+	}
+
+	// 🔒 Lock the user row so concurrent replacements are applied one after the other
+	var user models.User
+	var previousID string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_deleted = false", userID).
+			First(&user).Error; err != nil {
+			return err
+		}
+
+		previousID = user.ProfilePictureID
+		user.ProfilePictureID = file.ID
+		return tx.Model(&user).Update("profile_picture_id", file.ID).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update profile picture"})
+	}
+
+	// 🧹 Remove the previous picture once nothing else references it
+	if previousID != "" && previousID != file.ID {
+		if err := h.taskClient.EnqueueFileCleanup(c.Request().Context(), previousID); err != nil {
+			h.log.Error("Failed to enqueue profile picture cleanup", err)
+		}
+	}
+
+	user.ProfilePicture = *file
+	return c.JSON(http.StatusOK, user.Response())
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"be0/internal/config"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/testutil"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// uploadedBy stores a file of the user's team uploaded by them
+func uploadedBy(t *testing.T, gdb *gorm.DB, user *models.User, contentType string) *models.File {
+	t.Helper()
+	file := &models.File{TeamID: user.TeamID, UserID: &user.ID, Path: "uploads/" + contentType, Name: "picture", Size: 1, Type: contentType}
+	if err := gdb.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestUpdateProfilePictureCleansUpThePreviousOne(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	_, server := testutil.NewRedis(t)
+	client := tasks.NewTaskClient(config.RedisConfig{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+	h := NewProfileHandler(gdb, client)
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	teammate := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	first, second := uploadedBy(t, gdb, user, "image/png"), uploadedBy(t, gdb, user, "image/jpeg")
+
+	update := func(fileID string) (int, map[string]interface{}) {
+		c, rec := newContext(t, http.MethodPut, "/api/v1/users/me/profile-picture", UpdateProfilePictureRequest{FileID: fileID})
+		c.Set("userID", user.ID)
+		c.Set("teamID", team.ID)
+		if err := h.UpdateProfilePicture(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, decode(t, rec)
+	}
+	cleanups := func() []string {
+		queued, _ := inspector.ListPendingTasks(tasks.QueueLow)
+		var fileIDs []string
+		for _, task := range queued {
+			var payload tasks.FileCleanupPayload
+			if err := json.Unmarshal(task.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			fileIDs = append(fileIDs, payload.FileID)
+		}
+		return fileIDs
+	}
+
+	tests := []struct {
+		name   string
+		fileID string
+		status int
+	}{
+		{"a teammate's picture", uploadedBy(t, gdb, teammate, "image/png").ID, http.StatusNotFound},
+		{"not an image", uploadedBy(t, gdb, user, "application/pdf").ID, http.StatusBadRequest},
+		{"an unknown file", "2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status, body := update(tt.fileID); status != tt.status {
+			t.Errorf("%s: status %d %v, want %d", tt.name, status, body, tt.status)
+		}
+	}
+
+	if status, body := update(first.ID); status != http.StatusOK || body["profilePictureId"] != first.ID {
+		t.Fatalf("first picture: status %d %v", status, body)
+	}
+	// 🧹 The first picture had nothing to replace, setting it again replaces nothing either
+	if status, _ := update(first.ID); status != http.StatusOK || len(cleanups()) != 0 {
+		t.Fatalf("status %d, cleanups %v, want none", status, cleanups())
+	}
+
+	if status, body := update(second.ID); status != http.StatusOK || body["profilePictureId"] != second.ID {
+		t.Fatalf("second picture: status %d %v", status, body)
+	}
+	if queued := cleanups(); len(queued) != 1 || queued[0] != first.ID {
+		t.Errorf("queued cleanups %v, want the first picture", queued)
+	}
+	if stored := reloadUser(t, gdb, user.ID); stored.ProfilePictureID != second.ID {
+		t.Errorf("stored picture %s, want %s", stored.ProfilePictureID, second.ID)
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/events"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type RecoveryHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewRecoveryHandler(db *gorm.DB) *RecoveryHandler {
+	return &RecoveryHandler{db: db, log: logger.New("RecoveryHandler")}
+}
+
+type CreateRecoveryRequest struct {
+	Reason string `json:"reason" validate:"required,min=10"`
+}
+
+type RedeemRecoveryRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// CreateRecovery opens a recovery request for a user who lost access to their account
+// @Summary Create account recovery request
+// @Description Generate a one-time recovery token for a user. The token must be delivered out-of-band and only works after a second super admin approves the request.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body CreateRecoveryRequest true "Why the recovery is needed"
+// @Success 201 {object} map[string]interface{} "Recovery request and one-time token"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/users/{id}/recovery [post]
+func (h *RecoveryHandler) CreateRecovery(c echo.Context) error {
+	var req CreateRecoveryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", c.Param("id")).First(&user).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	token, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery token"})
+	}
+
+	adminID := middleware.GetUserID(c)
+	if adminID == "" {
+		return userRequired(c)
+	}
+	recovery := &models.RecoveryRequest{
+		UserID:        user.ID,
+		TeamID:        user.TeamID,
+		RequestedByID: adminID,
+		Reason:        req.Reason,
+		TokenHash:     models.HashRecoveryToken(token),
+		Status:        models.RecoveryStatusPending,
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		// 🧹 A new request replaces any open one for the same user
+		if err := tx.Model(&models.RecoveryRequest{}).
+			Where("user_id = ? AND status IN ? AND is_deleted = false", user.ID,
+				[]models.RecoveryStatus{models.RecoveryStatusPending, models.RecoveryStatusApproved}).
+			Update("status", models.RecoveryStatusCancelled).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(recovery).Error; err != nil {
+			return err
+		}
+		return h.audit(tx, c, models.AuditRecoveryRequested, recovery, map[string]interface{}{"reason": req.Reason})
+	}); err != nil {
+		h.log.Error("Failed to create recovery request", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create recovery request"})
+	}
+
+	h.notify(c.Request().Context(), &user, events.AlertRecoveryRequested, c,
+		"Account recovery requested",
+		"An administrator started a recovery of your account. If you did not ask for this, contact your administrator immediately.")
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"request": recovery,
+		"token":   token,
+	})
+}
+
+// ListRecoveries lists recovery requests, optionally filtered by status
+// @Summary List account recovery requests
+// @Description List recovery requests, newest first
+// @Tags admin
+// @Produce json
+// @Param status query string false "PENDING, APPROVED, REDEEMED or CANCELLED"
+// @Success 200 {array} models.RecoveryRequest
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/recovery [get]
+func (h *RecoveryHandler) ListRecoveries(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "status"); err != nil {
+		return err
+	}
+	query := h.db.Where("is_deleted = false")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.RecoveryRequest
+	if err := query.Order("created_at DESC").Limit(100).Find(&requests).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list recovery requests"})
+	}
+	return c.JSON(http.StatusOK, requests)
+}
+
+// ApproveRecovery activates a recovery token. The approver must not be the requester.
+// @Summary Approve account recovery request
+// @Description Second super admin approval, after which the recovery token can be redeemed for 24 hours
+// @Tags admin
+// @Produce json
+// @Param requestId path string true "Recovery request ID"
+// @Success 200 {object} models.RecoveryRequest
+// @Failure 403 {object} map[string]string "Requester cannot approve their own request"
+// @Failure 404 {object} map[string]string "Recovery request not found"
+// @Failure 409 {object} map[string]string "Request is not pending"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/recovery/{requestId}/approve [post]
+func (h *RecoveryHandler) ApproveRecovery(c echo.Context) error {
+	adminID := middleware.GetUserID(c)
+	if adminID == "" {
+		return userRequired(c)
+	}
+
+	var recovery models.RecoveryRequest
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_deleted = false", c.Param("requestId")).First(&recovery).Error; err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Recovery request not found")
+		}
+
+		// 👥 Two-person rule
+		if recovery.RequestedByID == adminID {
+			return echo.NewHTTPError(http.StatusForbidden, "A recovery request must be approved by a different super admin")
+		}
+
+		now := time.Now().UTC()
+		expiresAt := now.Add(models.RecoveryTokenLifetime)
+		result := tx.Model(&models.RecoveryRequest{}).
+			Where("id = ? AND status = ?", recovery.ID, models.RecoveryStatusPending).
+			Updates(map[string]interface{}{
+				"status":         models.RecoveryStatusApproved,
+				"approved_by_id": adminID,
+				"approved_at":    now,
+				"expires_at":     expiresAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return echo.NewHTTPError(http.StatusConflict, "Recovery request is not pending")
+		}
+
+		recovery.Status = models.RecoveryStatusApproved
+		recovery.ApprovedByID = &adminID
+		recovery.ApprovedAt = &now
+		recovery.ExpiresAt = &expiresAt
+		return h.audit(tx, c, models.AuditRecoveryApproved, &recovery, nil)
+	})
+	if err != nil {
+		return h.transactionError(c, err, "Failed to approve recovery request")
+	}
+
+	return c.JSON(http.StatusOK, recovery)
+}
+
+// CancelRecovery cancels a pending or approved recovery request
+// @Summary Cancel account recovery request
+// @Description Cancel a recovery request so its token can no longer be redeemed
+// @Tags admin
+// @Produce json
+// @Param requestId path string true "Recovery request ID"
+// @Success 200 {object} map[string]string "Recovery request cancelled"
+// @Failure 404 {object} map[string]string "Recovery request not found or already closed"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/recovery/{requestId}/cancel [post]
+func (h *RecoveryHandler) CancelRecovery(c echo.Context) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var recovery models.RecoveryRequest
+		if err := tx.Where("id = ? AND status IN ? AND is_deleted = false", c.Param("requestId"),
+			[]models.RecoveryStatus{models.RecoveryStatusPending, models.RecoveryStatusApproved}).
+			First(&recovery).Error; err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Recovery request not found")
+		}
+		if err := tx.Model(&recovery).Update("status", models.RecoveryStatusCancelled).Error; err != nil {
+			return err
+		}
+		return h.audit(tx, c, models.AuditRecoveryCancelled, &recovery, nil)
+	})
+	if err != nil {
+		return h.transactionError(c, err, "Failed to cancel recovery request")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Recovery request cancelled"})
+}
+
+// RedeemRecovery lets the user set a new password with an approved recovery token
+// @Summary Redeem account recovery token
+// @Description Set a new password with an approved recovery token. All existing sessions are signed out and two-factor authentication is reset. When the user had 2FA or the team requires it the response asks the client to re-enroll.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RedeemRecoveryRequest true "Recovery token and new password"
+// @Success 200 {object} map[string]interface{} "Account recovered"
+// @Failure 400 {object} map[string]string "Invalid or expired recovery token"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/auth/recovery/redeem [post]
+func (h *RecoveryHandler) RedeemRecovery(c echo.Context) error {
+	var req RedeemRecoveryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
+	}
+
+	var recovery models.RecoveryRequest
+	var user models.User
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Where("token_hash = ? AND status = ? AND expires_at > ? AND is_deleted = false",
+			models.HashRecoveryToken(req.Token), models.RecoveryStatusApproved, now).First(&recovery).Error; err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired recovery token")
+		}
+
+		if err := tx.Where("id = ? AND is_deleted = false", recovery.UserID).First(&user).Error; err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired recovery token")
+		}
+
+		// 🎟️ Single use, a concurrent redemption loses here
+		result := tx.Model(&models.RecoveryRequest{}).
+			Where("id = ? AND status = ?", recovery.ID, models.RecoveryStatusApproved).
+			Updates(map[string]interface{}{"status": models.RecoveryStatusRedeemed, "redeemed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired recovery token")
+		}
+
+		if err := tx.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+
+		// 🔢 The lost second factor is removed, the user enrolls again after signing in
+		if err := models.ResetTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+
+		// 🚪 Sign out every existing session
+		if err := tx.Model(&models.AuthTransaction{}).
+			Where("user_id = ? AND is_deleted = false", user.ID).
+			Updates(map[string]interface{}{"is_deleted": true, "deleted_at": now}).Error; err != nil {
+			return err
+		}
+
+		return models.RecordAudit(tx, models.AuditEntry{
+			ActorID:    user.ID,
+			TeamID:     user.TeamID,
+			Action:     models.AuditRecoveryRedeemed,
+			TargetType: "recovery_request",
+			TargetID:   recovery.ID,
+			IPAddress:  c.RealIP(),
+		}, map[string]interface{}{"userAgent": c.Request().UserAgent()})
+	})
+	if err != nil {
+		return h.transactionError(c, err, "Failed to recover account")
+	}
+
+	h.notify(c.Request().Context(), &user, events.AlertAccountRecovered, c,
+		"Your account was recovered",
+		"The password of your account was reset through an administrator-assisted recovery and all sessions were signed out.")
+
+	// Re-enrolment is needed when 2FA was on before the recovery or the team requires it
+	twoFactorRequired := user.TwoFactorEnabled
+	if team, err := models.GetTeamByID(user.TeamID, h.db); err == nil && team.AuthPolicy.RequireTwoFactor {
+		twoFactorRequired = true
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":                     "Account recovered, sign in with your new password",
+		"twoFactorEnrollmentRequired": twoFactorRequired,
+	})
+}
+
+// audit records a recovery step performed by the current admin
+func (h *RecoveryHandler) audit(tx *gorm.DB, c echo.Context, action string, recovery *models.RecoveryRequest, metadata map[string]interface{}) error {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["userId"] = recovery.UserID
+
+	// Recovery steps are only taken by signed-in admins, an entry without an actor is refused
+	actorID := middleware.GetUserID(c)
+	if actorID == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "This route needs a signed-in user")
+	}
+	return models.RecordAudit(tx, models.AuditEntry{
+		ActorID:    actorID,
+		TeamID:     recovery.TeamID,
+		Action:     action,
+		TargetType: "recovery_request",
+		TargetID:   recovery.ID,
+		IPAddress:  c.RealIP(),
+	}, metadata)
+}
+
+// notify tells the user about a recovery step through a security alert and an email
+func (h *RecoveryHandler) notify(ctx context.Context, user *models.User, alert string, c echo.Context, subject, text string) {
+	events.Emit(events.EventSecurityAlert, events.SecurityAlert{
+		Alert:      alert,
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		IPAddress:  c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		OccurredAt: time.Now().UTC(),
+	})
+
+	err := mailer.Send(ctx, h.db, mailer.Message{TeamID: user.TeamID, To: user.Email, Subject: subject, Text: text})
+	if err != nil && !errors.Is(err, mailer.ErrNoSender) {
+		h.log.Warn("Failed to email %s about %s: %v", user.Email, alert, err)
+	}
+}
+
+// transactionError renders HTTP errors raised inside a transaction and hides the rest
+func (h *RecoveryHandler) transactionError(c echo.Context, err error, message string) error {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return c.JSON(httpErr.Code, map[string]string{"error": fmt.Sprint(httpErr.Message)})
+	}
+	h.log.Error(message, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryFixture is a member who lost access and two super admins of the operator team
+type recoveryFixture struct {
+	gdb           *gorm.DB
+	h             *RecoveryHandler
+	user          *models.User
+	first, second *models.User
+}
+
+func newRecoveryFixture(t *testing.T) *recoveryFixture {
+	t.Helper()
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	ops := testutil.CreateTeam(t, gdb, "Ops")
+	return &recoveryFixture{
+		gdb:    gdb,
+		h:      NewRecoveryHandler(gdb),
+		user:   testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember),
+		first:  testutil.CreateUser(t, gdb, ops.ID, models.UserRoleSuperAdmin),
+		second: testutil.CreateUser(t, gdb, ops.ID, models.UserRoleSuperAdmin),
+	}
+}
+
+// step runs a recovery route as admin, nil for the unauthenticated redeem
+func (f *recoveryFixture) step(t *testing.T, route func(echo.Context) error, admin *models.User, id string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/api/v1/recovery", body)
+	c.SetParamNames("id", "requestId")
+	c.SetParamValues(id, id)
+	if admin != nil {
+		c.Set("userID", admin.ID)
+	}
+	if err := route(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, decode(t, rec)
+}
+
+// open creates a recovery request of the user as the first admin and returns its ID and token
+func (f *recoveryFixture) open(t *testing.T) (string, string) {
+	t.Helper()
+	status, body := f.step(t, f.h.CreateRecovery, f.first, f.user.ID, CreateRecoveryRequest{Reason: "Lost access to their mailbox"})
+	request, _ := body["request"].(map[string]interface{})
+	token, _ := body["token"].(string)
+	if status != http.StatusCreated || request == nil || token == "" {
+		t.Fatalf("create: status %d %v", status, body)
+	}
+	return request["id"].(string), token
+}
+
+func TestRecoveryNeedsASecondAdmin(t *testing.T) {
+	f := newRecoveryFixture(t)
+	if err := models.CreateSession(f.gdb, &models.AuthTransaction{UserID: f.user.ID, TeamID: f.user.TeamID}, 0); err != nil {
+		t.Fatal(err)
+	}
+	id, token := f.open(t)
+	redeem := RedeemRecoveryRequest{Token: token, Password: "a new passphrase"}
+
+	if status, _ := f.step(t, f.h.RedeemRecovery, nil, "", redeem); status != http.StatusBadRequest {
+		t.Errorf("redeem before the approval got %d, want 400", status)
+	}
+	if status, _ := f.step(t, f.h.ApproveRecovery, f.first, id, nil); status != http.StatusForbidden {
+		t.Errorf("the requester approving got %d, want 403", status)
+	}
+	if status, body := f.step(t, f.h.ApproveRecovery, f.second, id, nil); status != http.StatusOK {
+		t.Fatalf("the second admin approving got %d %v", status, body)
+	}
+	if status, _ := f.step(t, f.h.ApproveRecovery, f.second, id, nil); status != http.StatusConflict {
+		t.Errorf("approving twice got %d, want 409", status)
+	}
+
+	if status, body := f.step(t, f.h.RedeemRecovery, nil, "", redeem); status != http.StatusOK {
+		t.Fatalf("redeem got %d %v", status, body)
+	}
+	if status, _ := f.step(t, f.h.RedeemRecovery, nil, "", redeem); status != http.StatusBadRequest {
+		t.Errorf("redeeming twice got %d, want 400", status)
+	}
+
+	// 🔑 The new password works and every old session is signed out
+	var user models.User
+	if err := f.gdb.First(&user, "id = ?", f.user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(redeem.Password)) != nil {
+		t.Error("the password was not replaced")
+	}
+	var sessions int64
+	f.gdb.Model(&models.AuthTransaction{}).Where("user_id = ? AND is_deleted = false", f.user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions are still signed in", sessions)
+	}
+
+	var actions []string
+	f.gdb.Model(&models.AuditEntry{}).Where("target_id = ?", id).Order("created_at").Pluck("action", &actions)
+	want := []string{models.AuditRecoveryRequested, models.AuditRecoveryApproved, models.AuditRecoveryRedeemed}
+	if len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] || actions[2] != want[2] {
+		t.Errorf("audited %v, want %v", actions, want)
+	}
+}
+
+func TestClosedRecoveriesCannotBeRedeemed(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(t *testing.T, f *recoveryFixture, id string)
+	}{
+		{"cancelled", func(t *testing.T, f *recoveryFixture, id string) {
+			if status, body := f.step(t, f.h.CancelRecovery, f.second, id, nil); status != http.StatusOK {
+				t.Fatalf("cancel got %d %v", status, body)
+			}
+		}},
+		{"replaced by a new request", func(t *testing.T, f *recoveryFixture, _ string) {
+			f.open(t)
+		}},
+		{"expired", func(t *testing.T, f *recoveryFixture, id string) {
+			if err := f.gdb.Model(&models.RecoveryRequest{}).Where("id = ?", id).
+				Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRecoveryFixture(t)
+			id, token := f.open(t)
+			if status, body := f.step(t, f.h.ApproveRecovery, f.second, id, nil); status != http.StatusOK {
+				t.Fatalf("approve got %d %v", status, body)
+			}
+			tt.close(t, f, id)
+
+			if status, _ := f.step(t, f.h.RedeemRecovery, nil, "", RedeemRecoveryRequest{Token: token, Password: "a new passphrase"}); status != http.StatusBadRequest {
+				t.Errorf("redeem got %d, want 400", status)
+			}
+		})
+	}
+}
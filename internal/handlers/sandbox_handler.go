@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// sandboxListLimit caps the records returned by the sandbox endpoints
+const sandboxListLimit = 100
+
+type SandboxHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewSandboxHandler(db *gorm.DB) *SandboxHandler {
+	return &SandboxHandler{db: db, log: logger.New("SandboxHandler")}
+}
+
+// ListEmails lists emails recorded while the team was in sandbox mode
+// @Summary List sandbox emails
+// @Description List the latest emails the current team would have sent in sandbox mode, kept for 7 days
+// @Tags sandbox
+// @Produce json
+// @Success 200 {array} models.SandboxEmail
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /sandbox/emails [get]
+func (h *SandboxHandler) ListEmails(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var emails []models.SandboxEmail
+	if err := h.db.Where("team_id = ?", teamID).
+		Order("created_at DESC").Limit(sandboxListLimit).Find(&emails).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sandbox emails"})
+	}
+	return c.JSON(http.StatusOK, emails)
+}
+
+// ListWebhooks lists webhook deliveries recorded while the team was in sandbox mode
+// @Summary List sandbox webhooks
+// @Description List the latest webhook deliveries the current team would have sent in sandbox mode, kept for 7 days
+// @Tags sandbox
+// @Produce json
+// @Success 200 {array} models.SandboxWebhook
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /sandbox/webhooks [get]
+func (h *SandboxHandler) ListWebhooks(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var deliveries []models.SandboxWebhook
+	if err := h.db.Where("team_id = ?", teamID).
+		Order("created_at DESC").Limit(sandboxListLimit).Find(&deliveries).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sandbox webhooks"})
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestSandboxListsOnlyTheCallersTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := NewSandboxHandler(gdb)
+	acme, globex := testutil.CreateTeam(t, gdb, "Acme"), testutil.CreateTeam(t, gdb, "Globex")
+	for _, record := range []interface{}{
+		&models.SandboxEmail{TeamID: acme.ID, To: "ada@example.com", Subject: "Welcome"},
+		&models.SandboxEmail{TeamID: globex.ID, To: "hank@example.com", Subject: "Welcome"},
+		&models.SandboxWebhook{TeamID: globex.ID, SubscriptionID: globex.ID, URL: "https://hooks.example.com", Event: "users.created", Payload: []byte(`{}`)},
+	} {
+		if err := gdb.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, rec := newContext(t, http.MethodGet, "/api/v1/sandbox/emails", nil)
+	c.Set("teamID", acme.ID)
+	expectStatus(t, rec, h.ListEmails(c), http.StatusOK)
+	var emails []models.SandboxEmail
+	if err := json.Unmarshal(rec.Body.Bytes(), &emails); err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].To != "ada@example.com" {
+		t.Errorf("Acme sees %+v, want its own email only", emails)
+	}
+
+	c, rec = newContext(t, http.MethodGet, "/api/v1/sandbox/webhooks", nil)
+	c.Set("teamID", acme.ID)
+	expectStatus(t, rec, h.ListWebhooks(c), http.StatusOK)
+	if rec.Body.String() != "[]\n" {
+		t.Errorf("Acme sees webhooks %s, want none", rec.Body)
+	}
+
+	c, rec = newContext(t, http.MethodGet, "/api/v1/sandbox/emails", nil)
+	expectStatus(t, rec, h.ListEmails(c), http.StatusUnauthorized)
+}
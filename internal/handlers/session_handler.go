@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SessionHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewSessionHandler(db *gorm.DB) *SessionHandler {
+	return &SessionHandler{db: db, log: logger.New("SessionHandler")}
+}
+
+// SessionResponse describes an active session without its tokens
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	DeviceID  string    `json:"deviceId"`
+	Current   bool      `json:"current"` // the session this request was made with
+}
+
+// ListSessions lists the current user's active sessions
+// @Summary List sessions
+// @Description List the current user's active sessions, newest first
+// @Tags users
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Sessions per page" default(10)
+// @Success 200 {object} map[string]interface{} "Sessions with total, page and limit"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/me/sessions [get]
+func (h *SessionHandler) ListSessions(c echo.Context) error {
+	if err := middleware.CheckQueryParams(c, "page", "limit"); err != nil {
+		return err
+	}
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	sessions, total, err := models.ListSessions(h.db, userID, page, limit)
+	if err != nil {
+		h.log.Error("Failed to list sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list sessions"})
+	}
+
+	current := middleware.GetSessionID(c)
+	data := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, SessionResponse{
+			ID:        session.ID,
+			CreatedAt: session.CreatedAt.Time,
+			ExpiresAt: session.ExpiresAt,
+			IPAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+			DeviceID:  session.DeviceID,
+			Current:   session.ID == current,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data":  data,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// RevokeSession signs the current user out of one session
+// @Summary Revoke session
+// @Description Revoke one of the current user's sessions, its access token stops working on the next request
+// @Tags users
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]string "Session revoked"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/me/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+
+	revoked, err := models.RevokeSession(h.db, c.Param("id"), userID)
+	if err != nil {
+		h.log.Error("Failed to revoke session: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+	}
+	if !revoked {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Session not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeDevice signs the current user out of every session on a device
+// @Summary Revoke device
+// @Description Revoke all of the current user's sessions on a device, identified by the deviceId listed with the sessions
+// @Tags auth
+// @Produce json
+// @Param deviceId path string true "Device ID"
+// @Success 200 {object} map[string]interface{} "Number of revoked sessions"
+// @Failure 404 {object} map[string]string "No active session on the device"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/devices/{deviceId} [delete]
+func (h *SessionHandler) RevokeDevice(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+
+	revoked, err := models.RevokeDeviceSessions(h.db, userID, c.Param("deviceId"))
+	if err != nil {
+		h.log.Error("Failed to revoke device sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke device"})
+	}
+	if revoked == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No active session on this device"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Device signed out",
+		"revoked": revoked,
+	})
+}
+
+// RevokeUserSessions signs a team member out of all sessions
+// @Summary Revoke user sessions
+// @Description Revoke all sessions of a user on the current team. Requires users:update.
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "Number of revoked sessions"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/{id}/sessions [delete]
+func (h *SessionHandler) RevokeUserSessions(c echo.Context) error {
+	// 🔒 The revocation is audited as the signed-in admin, checked before anything is revoked
+	adminID := middleware.GetUserID(c)
+	if adminID == "" {
+		return userRequired(c)
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	// 🏢 Admins only manage users of their own team
+	var user models.User
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).
+		First(&user).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	revoked, err := models.RevokeUserSessions(h.db, user.ID)
+	if err != nil {
+		h.log.Error("Failed to revoke sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+	}
+
+	if err := models.RecordAudit(h.db, models.AuditEntry{
+		ActorID:    adminID,
+		TeamID:     teamID,
+		Action:     models.AuditSessionsRevoked,
+		TargetType: "user",
+		TargetID:   user.ID,
+		IPAddress:  utils.GetIPAddress(c.Request()),
+	}, map[string]interface{}{"revoked": revoked}); err != nil {
+		h.log.Error("Failed to audit session revocation: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Sessions revoked",
+		"revoked": revoked,
+	})
+}
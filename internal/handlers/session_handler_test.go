@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/events"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// API keys act for a team without a user, routes acting for a user answer them with 403
+func TestUserRoutesRefuseRequestsWithoutAUser(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	auth := newTestAuthHandler(gdb)
+	sessions := NewSessionHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	login(t, auth, member)
+
+	tests := []struct {
+		name    string
+		method  string
+		handler echo.HandlerFunc
+	}{
+		{name: "list sessions", method: http.MethodGet, handler: sessions.ListSessions},
+		{name: "revoke session", method: http.MethodDelete, handler: sessions.RevokeSession},
+		{name: "revoke device", method: http.MethodDelete, handler: sessions.RevokeDevice},
+		{name: "revoke user sessions", method: http.MethodDelete, handler: sessions.RevokeUserSessions},
+		{name: "get me", method: http.MethodGet, handler: auth.GetMe},
+		{name: "invite", method: http.MethodPost, handler: auth.InviteUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newContext(t, tt.method, "/", nil)
+			c.SetParamNames("id", "deviceId")
+			c.SetParamValues(member.ID, "laptop")
+			c.Set("teamID", team.ID)
+			c.Set("isAPIKey", true)
+
+			expectStatus(t, rec, tt.handler(c), http.StatusForbidden)
+			if code := decode(t, rec)["code"]; code != "user_required" {
+				t.Errorf("code %v, want user_required", code)
+			}
+		})
+	}
+
+	// The member's sessions were not revoked before the refusal
+	if live := liveSessions(t, gdb, member.ID); live != 1 {
+		t.Errorf("%d live sessions, want 1", live)
+	}
+}
+
+func TestNewDeviceSignInsAlertAndCanBeRevoked(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	auth := newTestAuthHandler(gdb)
+	sessions := NewSessionHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	server := testutil.NewSMTPServer(t)
+	mailer.RegisterSender(mailer.NewSMTPSender(mailer.SMTPConfig{Host: server.Host, Port: server.Port, From: "noreply@be0.test"}))
+	t.Cleanup(func() { mailer.RegisterSender(nil) })
+	alerts := make(chan events.NewDeviceLogin, 4)
+	events.OnNamed(events.EventNewDeviceLogin, t.Name(), func(data interface{}) {
+		if login := data.(events.NewDeviceLogin); login.UserID == member.ID {
+			alerts <- login
+		}
+	})
+	signInOn := func(device string) {
+		t.Helper()
+		c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: member.Email, Password: testutil.Password})
+		c.Request().Header.Set(middleware.DeviceIDHeader, device)
+		expectStatus(t, rec, auth.Login(c), http.StatusOK)
+	}
+	noAlert := func(when string) {
+		t.Helper()
+		select {
+		case login := <-alerts:
+			t.Errorf("%s alerted on %s", when, login.DeviceID)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// 🆕 The first sign-in has no device to compare with, signing in again on it is known
+	signInOn("laptop-1")
+	noAlert("the first sign-in")
+	signInOn("laptop-1")
+	noAlert("a known device")
+
+	signInOn("phone-1")
+	select {
+	case login := <-alerts:
+		if login.DeviceID != "phone-1" || login.TeamID != team.ID {
+			t.Errorf("got %+v, want phone-1 of the team", login)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert for the new device")
+	}
+	for deadline := time.Now().Add(time.Second); len(server.Messages()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no email about the new device")
+		}
+	}
+	if message := server.Messages()[0]; !strings.Contains(message, "Subject: New sign-in to your account") ||
+		!strings.Contains(message, "<"+member.Email+">") {
+		t.Errorf("unexpected email:\n%s", message)
+	}
+
+	// 📵 Revoking the device signs out its sessions only
+	revoke := func(device string) *httptest.ResponseRecorder {
+		c, rec := newContext(t, http.MethodDelete, "/auth/devices/"+device, nil)
+		c.SetParamNames("deviceId")
+		c.SetParamValues(device)
+		c.Set("userID", member.ID)
+		c.Set("teamID", team.ID)
+		if err := sessions.RevokeDevice(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+	if rec := revoke("phone-1"); rec.Code != http.StatusOK || decode(t, rec)["revoked"] != float64(1) {
+		t.Fatalf("revoke got %d %s, want 1 revoked", rec.Code, rec.Body)
+	}
+	if live := liveSessions(t, gdb, member.ID); live != 2 {
+		t.Errorf("%d live sessions, want the 2 on the laptop", live)
+	}
+	if rec := revoke("phone-1"); rec.Code != http.StatusNotFound {
+		t.Errorf("revoking the device again got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// shareUnlockCookie holds the unlock token of a password-protected share
+	shareUnlockCookie = "be0_share_unlock"
+	// shareLinkTTL is how long the signed URL a share redirects to stays valid
+	shareLinkTTL = 5 * time.Minute
+)
+
+type ShareHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewShareHandler(db *gorm.DB) *ShareHandler {
+	return &ShareHandler{db: db, log: logger.New("ShareHandler")}
+}
+
+type CreateShareRequest struct {
+	Password     string     `json:"password" validate:"omitempty,min=6,max=72"`
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxDownloads *int       `json:"maxDownloads" validate:"omitempty,min=1"`
+}
+
+type UnlockShareRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// CreateShare creates a public link to one of the team's files
+// @Summary Create file share link
+// @Description Create a public link to a file, optionally protected by a password, an expiry and a download limit
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path string true "File ID"
+// @Param request body CreateShareRequest true "Share constraints"
+// @Success 201 {object} models.FileShare
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{id}/share [post]
+func (h *ShareHandler) CreateShare(c echo.Context) error {
+	var req CreateShareRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "expiresAt must be in the future"})
+		}
+		expiresAt := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expiresAt
+	}
+
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return userRequired(c)
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var file models.File
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).First(&file).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "File not found"})
+	}
+
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate share token"})
+	}
+
+	share := &models.FileShare{
+		FileID:       file.ID,
+		TeamID:       teamID,
+		CreatedByID:  userID,
+		Token:        token,
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+	}
+
+	// 🔒 Hash the optional password
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to hash password"})
+		}
+		share.PasswordHash = string(hash)
+		share.HasPassword = true
+	}
+
+	if err := h.db.Create(share).Error; err != nil {
+		h.log.Error("Failed to create file share", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create share"})
+	}
+
+	return c.JSON(http.StatusCreated, share)
+}
+
+// ListShares lists the share links of one of the team's files
+// @Summary List file share links
+// @Description List the active share links of a file with their download counts
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Success 200 {array} models.FileShare
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{id}/shares [get]
+func (h *ShareHandler) ListShares(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var shares []models.FileShare
+	if err := h.db.Where("file_id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).
+		Order("created_at DESC").Find(&shares).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list shares"})
+	}
+	return c.JSON(http.StatusOK, shares)
+}
+
+// RevokeShare revokes a share link
+// @Summary Revoke file share link
+// @Description Revoke a share link so it can no longer be downloaded
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Param shareId path string true "Share ID"
+// @Success 200 {object} map[string]string "Share revoked"
+// @Failure 404 {object} map[string]string "Share not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{id}/shares/{shareId} [delete]
+func (h *ShareHandler) RevokeShare(c echo.Context) error {
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	result := h.db.Model(&models.FileShare{}).
+		Where("id = ? AND file_id = ? AND team_id = ? AND is_deleted = false", c.Param("shareId"), c.Param("id"), teamID).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke share"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Share not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Share revoked"})
+}
+
+// UnlockShare exchanges the password of a protected share for a short-lived access token
+// @Summary Unlock file share link
+// @Description Check the password of a protected share. The access token is set as a cookie and also returned for API clients, who pass it as the access_token query parameter.
+// @Tags share
+// @Accept json
+// @Produce json
+// @Param token path string true "Share token"
+// @Param request body UnlockShareRequest true "Share password"
+// @Success 200 {object} map[string]interface{} "Access token and expiry"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 401 {object} map[string]string "Wrong password"
+// @Failure 404 {object} map[string]string "Share not found or no longer available"
+// @Router /share/{token}/unlock [post]
+func (h *ShareHandler) UnlockShare(c echo.Context) error {
+	var req UnlockShareRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	share, err := h.liveShare(c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Share not found"})
+	}
+
+	if share.PasswordHash == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Share is not password protected"})
+	}
+
+	if err := checkPassword(share.PasswordHash, req.Password); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid password"})
+	}
+
+	accessToken, err := utils.GenerateShareUnlockToken(share.Token)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unlock share"})
+	}
+
+	expiresAt := time.Now().Add(utils.ShareUnlockTTL)
+	c.SetCookie(&http.Cookie{
+		Name:     shareUnlockCookie,
+		Value:    accessToken,
+		Path:     "/share/" + share.Token,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"accessToken": accessToken,
+		"expiresAt":   expiresAt,
+	})
+}
+
+// DownloadShare redirects to a short-lived signed URL of a shared file
+// @Summary Download shared file
+// @Description Validate a share link, count the download and redirect to a signed URL of the file
+// @Tags share
+// @Param token path string true "Share token"
+// @Param access_token query string false "Unlock token for password-protected shares"
+// @Success 302 "Redirect to the file"
+// @Failure 401 {object} map[string]string "Password required"
+// @Failure 404 {object} map[string]string "Share not found or no longer available"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /share/{token} [get]
+func (h *ShareHandler) DownloadShare(c echo.Context) error {
+	share, err := h.liveShare(c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Share not found"})
+	}
+
+	// 🔑 Password-protected shares need an unlock token
+	if share.PasswordHash != "" {
+		accessToken := c.QueryParam("access_token")
+		if cookie, err := c.Cookie(shareUnlockCookie); err == nil && accessToken == "" {
+			accessToken = cookie.Value
+		}
+		if accessToken == "" || utils.ValidateShareUnlockToken(accessToken, share.Token) != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "Password required",
+				"code":  "share_password_required",
+			})
+		}
+	}
+
+	file, err := models.GetFileByID(share.FileID, h.db)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Share not found"})
+	}
+
+	storage := GetStorageHandler()
+	if storage == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Storage handler not configured"})
+	}
+
+	// 🔢 Count the download, losing the race to another download means the limit was reached
+	if err := models.ConsumeFileShareDownload(h.db, share.ID); err != nil {
+		if errors.Is(err, models.ErrShareUnavailable) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Share not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record download"})
+	}
+
+	url, err := storage.GetSignedURL(c.Request().Context(), file.Path, shareLinkTTL)
+	if err != nil {
+		h.log.Error("Failed to sign shared file URL", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate download URL"})
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Redirect(http.StatusFound, url)
+}
+
+// liveShare loads a share by token and checks it has not expired or run out of downloads
+func (h *ShareHandler) liveShare(token string) (*models.FileShare, error) {
+	share, err := models.GetFileShareByToken(token, h.db)
+	if err != nil {
+		return nil, err
+	}
+	if !share.Available(time.Now()) {
+		return nil, models.ErrShareUnavailable
+	}
+	return share, nil
+}
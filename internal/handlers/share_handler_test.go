@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// signingStorage signs every path, shares only need GetSignedURL
+type signingStorage struct {
+	StorageHandler
+}
+
+func (signingStorage) GetSignedURL(_ context.Context, path string, _ time.Duration) (string, error) {
+	return "https://storage.example.com/" + path + "?signature=test", nil
+}
+
+// sharedFile creates a file of a new team and a share of it, change edits the share before
+// it is stored
+func sharedFile(t *testing.T, gdb *gorm.DB, change func(share *models.FileShare)) *models.FileShare {
+	t.Helper()
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	owner := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	file := &models.File{TeamID: team.ID, UserID: &owner.ID, Path: "report.pdf", Name: "report.pdf", Size: 1, Type: "application/pdf"}
+	if err := gdb.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	share := &models.FileShare{FileID: file.ID, TeamID: team.ID, CreatedByID: owner.ID, Token: "share-" + file.ID}
+	if change != nil {
+		change(share)
+	}
+	if err := gdb.Create(share).Error; err != nil {
+		t.Fatal(err)
+	}
+	return share
+}
+
+// download requests a share, accessToken "" sends none
+func download(t *testing.T, h *ShareHandler, token, accessToken string) (int, string) {
+	t.Helper()
+	target := "/share/" + token
+	if accessToken != "" {
+		target += "?access_token=" + accessToken
+	}
+	c, rec := newContext(t, http.MethodGet, target, nil)
+	c.SetParamNames("token")
+	c.SetParamValues(token)
+	if err := h.DownloadShare(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, rec.Header().Get("Location")
+}
+
+func useSigningStorage(t *testing.T) {
+	previous := GetStorageHandler()
+	RegisterStorageHandler(signingStorage{})
+	t.Cleanup(func() { RegisterStorageHandler(previous) })
+}
+
+func TestDownloadShareChecksTheShareIsLive(t *testing.T) {
+	useSigningStorage(t)
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	one := 1
+
+	tests := []struct {
+		name   string
+		change func(share *models.FileShare)
+		status int
+	}{
+		{"live", nil, http.StatusFound},
+		{"expired", func(share *models.FileShare) { share.ExpiresAt = &yesterday }, http.StatusNotFound},
+		{"revoked", func(share *models.FileShare) { share.IsDeleted = true }, http.StatusNotFound},
+		{"out of downloads", func(share *models.FileShare) { share.MaxDownloads, share.DownloadCount = &one, 1 }, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := testutil.NewDB(t)
+			h := NewShareHandler(gdb)
+			share := sharedFile(t, gdb, tt.change)
+
+			status, location := download(t, h, share.Token, "")
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusFound && location != "https://storage.example.com/report.pdf?signature=test" {
+				t.Errorf("redirected to %q", location)
+			}
+		})
+	}
+}
+
+func TestRevokedShareStopsDownloading(t *testing.T) {
+	useSigningStorage(t)
+	gdb := testutil.NewDB(t)
+	h := NewShareHandler(gdb)
+	share := sharedFile(t, gdb, nil)
+	if status, _ := download(t, h, share.Token, ""); status != http.StatusFound {
+		t.Fatalf("download before the revoke got %d", status)
+	}
+
+	c, rec := newContext(t, http.MethodDelete, "/api/v1/files/"+share.FileID+"/shares/"+share.ID, nil)
+	c.SetParamNames("id", "shareId")
+	c.SetParamValues(share.FileID, share.ID)
+	c.Set("teamID", share.TeamID)
+	expectStatus(t, rec, h.RevokeShare(c), http.StatusOK)
+
+	if status, _ := download(t, h, share.Token, ""); status != http.StatusNotFound {
+		t.Errorf("download after the revoke got %d, want 404", status)
+	}
+}
+
+func TestPasswordProtectedShares(t *testing.T) {
+	testutil.UseKeys(t)
+	useSigningStorage(t)
+	gdb := testutil.NewDB(t)
+	h := NewShareHandler(gdb)
+	share := sharedFile(t, gdb, func(share *models.FileShare) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("open sesame"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		share.PasswordHash = string(hash)
+	})
+
+	unlock := func(password string) (int, map[string]interface{}) {
+		c, rec := newContext(t, http.MethodPost, "/share/"+share.Token+"/unlock", UnlockShareRequest{Password: password})
+		c.SetParamNames("token")
+		c.SetParamValues(share.Token)
+		if err := h.UnlockShare(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, decode(t, rec)
+	}
+
+	if status, _ := unlock("open sesame!"); status != http.StatusUnauthorized {
+		t.Errorf("wrong password got %d, want 401", status)
+	}
+	if status, _ := download(t, h, share.Token, ""); status != http.StatusUnauthorized {
+		t.Errorf("download without unlocking got %d, want 401", status)
+	}
+	if status, _ := download(t, h, share.Token, "forged"); status != http.StatusUnauthorized {
+		t.Errorf("download with a forged token got %d, want 401", status)
+	}
+
+	status, body := unlock("open sesame")
+	if status != http.StatusOK {
+		t.Fatalf("right password got %d %v", status, body)
+	}
+	accessToken, _ := body["accessToken"].(string)
+	if status, _ := download(t, h, share.Token, accessToken); status != http.StatusFound {
+		t.Errorf("download with the unlock token got %d, want 302", status)
+	}
+}
+
+func TestConsumeFileShareDownloadRace(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	limit := 3
+	share := sharedFile(t, gdb, func(share *models.FileShare) { share.MaxDownloads = &limit })
+
+	// 🏁 Every download passed the liveness check, only the limit may win the count
+	const downloads = 12
+	var wg sync.WaitGroup
+	results := make(chan error, downloads)
+	for range downloads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- models.ConsumeFileShareDownload(gdb, share.ID)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var counted, refused int
+	for err := range results {
+		switch {
+		case err == nil:
+			counted++
+		case errors.Is(err, models.ErrShareUnavailable):
+			refused++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if counted != limit || refused != downloads-limit {
+		t.Errorf("%d downloads counted and %d refused, want %d and %d", counted, refused, limit, downloads-limit)
+	}
+	var stored models.FileShare
+	if err := gdb.First(&stored, "id = ?", share.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.DownloadCount != limit {
+		t.Errorf("download count %d, want %d", stored.DownloadCount, limit)
+	}
+}
+
+func TestShareRoutesNeedATeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := NewShareHandler(gdb)
+
+	c, rec := newContext(t, http.MethodPost, "/api/v1/files/x/share", CreateShareRequest{})
+	c.Set("userID", "2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f")
+	expectStatus(t, rec, h.CreateShare(c), http.StatusUnauthorized)
+
+	c, rec = newContext(t, http.MethodGet, "/api/v1/files/x/shares", nil)
+	expectStatus(t, rec, h.ListShares(c), http.StatusUnauthorized)
+
+	c, rec = newContext(t, http.MethodDelete, "/api/v1/files/x/shares/y", nil)
+	expectStatus(t, rec, h.RevokeShare(c), http.StatusUnauthorized)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TeamHandler struct {
+	teams services.BaseService[models.Team]
+	log   *logger.Logger
+}
+
+func NewTeamHandler(db *gorm.DB) *TeamHandler {
+	return &TeamHandler{teams: services.NewBaseService(db, models.Team{}), log: logger.New("TeamHandler")}
+}
+
+// RestoreTeam undoes the deletion of a team
+// @Summary Restore team
+// @Description Restore a deleted team and reverse what deleting it did: its users are reactivated, its pending invites and file shares come back and its webhooks resume. Only rows the deletion changed are touched, invites accepted meanwhile stay accepted. Super admin only.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Team ID"
+// @Success 200 {object} map[string]string "Team restored"
+// @Failure 404 {object} map[string]string "No deleted team with this ID"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/admin/teams/{id}/restore [post]
+func (h *TeamHandler) RestoreTeam(c echo.Context) error {
+	if err := h.teams.Restore(c.Request().Context(), c.Param("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Deleted team not found"})
+		}
+		h.log.Error("Failed to restore team", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to restore team"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Team restored"})
+}
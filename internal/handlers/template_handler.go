@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TemplateHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewTemplateHandler(db *gorm.DB) *TemplateHandler {
+	return &TemplateHandler{db: db, log: logger.New("TemplateHandler")}
+}
+
+// RenderTemplateRequest is the variable payload of a render
+type RenderTemplateRequest struct {
+	Variables map[string]interface{} `json:"variables"`
+	// Strict fails on undefined variables and unsafe values instead of leaving them out
+	Strict bool `json:"strict"`
+}
+
+// RenderTemplate renders a template with a variable payload
+// @Summary Render template
+// @Description Render the subject and HTML of one of the current team's templates. Values are HTML-escaped for where they appear and javascript: URLs are refused. Lenient mode leaves undefined placeholders as written and lists them in missing, strict mode fails with 422 instead.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID"
+// @Param request body RenderTemplateRequest true "Variables and mode"
+// @Success 200 {object} services.RenderedTemplate
+// @Failure 400 {object} map[string]string "Invalid body"
+// @Failure 404 {object} map[string]string "Template not found"
+// @Failure 422 {object} map[string]string "Template could not be rendered"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /templates/{id}/render [post]
+func (h *TemplateHandler) RenderTemplate(c echo.Context) error {
+	var req RenderTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return teamRequired(c)
+	}
+
+	var template models.Template
+	if err := h.db.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).
+		First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Template not found"})
+		}
+		h.log.Error("Failed to load template: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to render template"})
+	}
+
+	rendered, err := services.NewTemplateRenderer(services.TemplateRendererConfig{Strict: req.Strict}).Render(&template, req.Variables)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error(), "code": "template_render_failed"})
+	}
+	return c.JSON(http.StatusOK, rendered)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestRenderTemplateEscapesTheVariables(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := NewTemplateHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Globex")
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Welcome {{name}}", HTML: `<p>Hi {{name}}</p><a href="{{link}}">Start</a>`, Variables: []string{"name", "link"}}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(teamID string, req RenderTemplateRequest) (int, map[string]interface{}) {
+		t.Helper()
+		c, rec := newContext(t, http.MethodPost, "/templates/"+template.ID+"/render", req)
+		c.SetParamNames("id")
+		c.SetParamValues(template.ID)
+		if teamID != "" {
+			c.Set("teamID", teamID)
+		}
+		if err := h.RenderTemplate(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, decode(t, rec)
+	}
+
+	tests := []struct {
+		name    string
+		teamID  string
+		request RenderTemplateRequest
+		status  int
+		subject string
+		html    string
+		code    string
+	}{
+		{
+			name:    "safe values",
+			teamID:  team.ID,
+			request: RenderTemplateRequest{Variables: map[string]interface{}{"name": "Ada", "link": "https://acme.test/start"}},
+			status:  http.StatusOK,
+			subject: "Welcome Ada",
+			html:    `<p>Hi Ada</p><a href="https://acme.test/start">Start</a>`,
+		},
+		{
+			name:    "markup and header injection",
+			teamID:  team.ID,
+			request: RenderTemplateRequest{Variables: map[string]interface{}{"name": "<img src=x onerror=alert(1)>\r\nBcc: all@acme.test", "link": "/start"}},
+			status:  http.StatusOK,
+			subject: "Welcome <img src=x onerror=alert(1)>  Bcc: all@acme.test",
+			html:    `<p>Hi &lt;img src=x onerror=alert(1)&gt;` + "\r\n" + `Bcc: all@acme.test</p><a href="/start">Start</a>`,
+		},
+		{
+			name:    "javascript URL left out",
+			teamID:  team.ID,
+			request: RenderTemplateRequest{Variables: map[string]interface{}{"name": "Ada", "link": "javascript:alert(document.cookie)"}},
+			status:  http.StatusOK,
+			subject: "Welcome Ada",
+			html:    `<p>Hi Ada</p><a href="">Start</a>`,
+		},
+		{
+			name:    "javascript URL in strict mode",
+			teamID:  team.ID,
+			request: RenderTemplateRequest{Variables: map[string]interface{}{"name": "Ada", "link": "javascript:alert(document.cookie)"}, Strict: true},
+			status:  http.StatusUnprocessableEntity,
+			code:    "template_render_failed",
+		},
+		{name: "template of another team", teamID: other.ID, status: http.StatusNotFound},
+		{name: "no team", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := render(tt.teamID, tt.request)
+			if status != tt.status {
+				t.Fatalf("status %d %v, want %d", status, body, tt.status)
+			}
+			if tt.code != "" && body["code"] != tt.code {
+				t.Errorf("code %v, want %s", body["code"], tt.code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if body["subject"] != tt.subject || body["html"] != tt.html {
+				t.Errorf("rendered %q / %q, want %q / %q", body["subject"], body["html"], tt.subject, tt.html)
+			}
+		})
+	}
+}
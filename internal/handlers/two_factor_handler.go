@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/utils"
+	"be0/internal/utils/crypto"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// twoFactorIssuer is the account label shown in authenticator apps
+const twoFactorIssuer = "be0"
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollRequest struct {
+	Challenge string `json:"challenge" validate:"required"`
+}
+
+type TwoFactorVerifyRequest struct {
+	Challenge string `json:"challenge" validate:"required"`
+	// Code is a TOTP code or one of the recovery codes
+	Code string `json:"code" validate:"required"`
+}
+
+// twoFactorChallenge answers a correct password with a challenge for the second factor
+func twoFactorChallenge(c echo.Context, user *models.User) error {
+	challenge, err := utils.GenerateTwoFactorChallenge(user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate challenge"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"twoFactorRequired": true,
+		"challenge":         challenge,
+	})
+}
+
+// twoFactorEnrollment answers a sign-in to a team requiring 2FA, by a user without it, with a
+// challenge for setting it up through POST /auth/2fa/enroll
+func twoFactorEnrollment(c echo.Context, user *models.User) error {
+	challenge, err := utils.GenerateTwoFactorEnrollment(user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate challenge"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"twoFactorEnrollmentRequired": true,
+		"challenge":                   challenge,
+	})
+}
+
+// twoFactorEnrollmentRequired refuses a session to a user without 2FA in a team requiring it
+func twoFactorEnrollmentRequired(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"error": "Your team requires two-factor authentication, set it up first",
+		"code":  "two_factor_enrollment_required",
+	})
+}
+
+// signIn finishes a sign-in whose first factor was checked. Users with 2FA get a challenge, users
+// without it in a team requiring it set it up first, everyone else gets the token pair.
+func (h *AuthHandler) signIn(c echo.Context, user models.User) error {
+	if user.TwoFactorEnabled {
+		return twoFactorChallenge(c, &user)
+	}
+
+	required, err := models.TeamRequiresTwoFactor(h.db, user.TeamID)
+	if err != nil {
+		h.log.Error("Failed to load team auth policy: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	if required {
+		return twoFactorEnrollment(c, &user)
+	}
+
+	return h.issueSession(c, user)
+}
+
+// SetupTwoFactor creates a TOTP secret for the current user
+// @Summary Set up two-factor authentication
+// @Description Create a TOTP secret and otpauth URL for an authenticator app. 2FA is only turned on by POST /auth/2fa/enable.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string "TOTP secret and otpauth URL"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	return h.setUpTwoFactor(c, user)
+}
+
+// setUpTwoFactor stores a pending TOTP secret for user and answers with it
+func (h *AuthHandler) setUpTwoFactor(c echo.Context, user *models.User) error {
+	if user.TwoFactorEnabled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is already enabled"})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate secret"})
+	}
+
+	encrypted, err := crypto.Encrypt(secret)
+	if err != nil {
+		h.log.Error("Failed to encrypt TOTP secret", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store secret"})
+	}
+
+	if err := h.db.Model(user).Update("two_factor_pending_secret", encrypted).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store secret"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"secret":     secret,
+		"otpauthUrl": utils.TOTPURL(twoFactorIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor confirms the secret from setup with a code and turns 2FA on
+// @Summary Enable two-factor authentication
+// @Description Verify a code from the authenticator app and enable 2FA. The response holds 10 one-time recovery codes, shown only once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string]interface{} "Recovery codes"
+// @Failure 400 {object} map[string]string "Invalid code or setup missing"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/enable [post]
+func (h *AuthHandler) EnableTwoFactor(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	codes, status, message := h.enableTwoFactor(user, req.Code)
+	if status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// enableTwoFactor checks a code against the pending secret of user and turns 2FA on. It returns
+// the new recovery codes and 200, or the status and message to answer with.
+func (h *AuthHandler) enableTwoFactor(user *models.User, code string) ([]string, int, string) {
+	if user.TwoFactorEnabled {
+		return nil, http.StatusConflict, "Two-factor authentication is already enabled"
+	}
+	if user.TwoFactorPendingSecret == "" {
+		return nil, http.StatusBadRequest, "Set up two-factor authentication first"
+	}
+
+	secret, err := crypto.Decrypt(user.TwoFactorPendingSecret)
+	if err != nil {
+		h.log.Error("Failed to decrypt TOTP secret", err)
+		return nil, http.StatusInternalServerError, "Failed to read secret"
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now().UTC())
+	if !ok {
+		return nil, http.StatusBadRequest, "Invalid code"
+	}
+
+	codes := make([]string, models.RecoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateResetCode(resetCodeLength); err != nil {
+			return nil, http.StatusInternalServerError, "Failed to generate recovery codes"
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled":        true,
+			"two_factor_secret":         user.TwoFactorPendingSecret,
+			"two_factor_pending_secret": "",
+			"two_factor_last_step":      step,
+			"two_factor_failures":       0,
+		}).Error; err != nil {
+			return err
+		}
+		return models.ReplaceRecoveryCodes(tx, user.ID, codes)
+	})
+	if err != nil {
+		h.log.Error("Failed to enable two-factor authentication", err)
+		return nil, http.StatusInternalServerError, "Failed to enable two-factor authentication"
+	}
+
+	return codes, http.StatusOK, ""
+}
+
+// EnrollTwoFactor sets up 2FA during a sign-in to a team that requires it
+// @Summary Set up two-factor authentication to sign in
+// @Description Exchange the enrollment challenge returned by a sign-in to a team requiring 2FA for a TOTP secret and otpauth URL. Confirm it with POST /auth/2fa/enroll/confirm.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorEnrollRequest true "Enrollment challenge"
+// @Success 200 {object} map[string]string "TOTP secret and otpauth URL"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 401 {object} map[string]string "Invalid or expired challenge"
+// @Failure 409 {object} map[string]string "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactor(c echo.Context) error {
+	var req TwoFactorEnrollRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	user, err := h.enrollingUser(req.Challenge)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge"})
+	}
+
+	return h.setUpTwoFactor(c, user)
+}
+
+// ConfirmTwoFactorEnrollment turns on 2FA set up during a sign-in and completes the sign-in
+// @Summary Confirm two-factor authentication to sign in
+// @Description Verify a code for the secret from POST /auth/2fa/enroll, enable 2FA and sign in. The response holds the token pair and 10 one-time recovery codes, shown only once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorVerifyRequest true "Enrollment challenge and TOTP code"
+// @Success 200 {object} map[string]interface{} "JWT token pair and recovery codes"
+// @Failure 400 {object} map[string]string "Invalid code or setup missing"
+// @Failure 401 {object} map[string]string "Invalid or expired challenge"
+// @Failure 409 {object} map[string]string "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/enroll/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactorEnrollment(c echo.Context) error {
+	var req TwoFactorVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	user, err := h.enrollingUser(req.Challenge)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge"})
+	}
+
+	codes, status, message := h.enableTwoFactor(user, req.Code)
+	if status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	// 🔢 The code just checked is the second factor of this sign-in
+	user.TwoFactorEnabled = true
+	tokens, err := h.newSession(c, *user)
+	if err != nil {
+		return h.sessionError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":         tokens["token"],
+		"refresh_token": tokens["refresh_token"],
+		"recoveryCodes": codes,
+	})
+}
+
+// enrollingUser loads the user an enrollment challenge was issued to
+func (h *AuthHandler) enrollingUser(challenge string) (*models.User, error) {
+	userID, err := utils.ValidateTwoFactorEnrollment(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code
+// @Summary Disable two-factor authentication
+// @Description Disable 2FA with a TOTP code or a recovery code. The secret and recovery codes are removed.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]string "Invalid code or 2FA not enabled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 429 {object} map[string]string "Too many invalid codes"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c echo.Context) error {
+	var req TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	if !user.TwoFactorEnabled {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Two-factor authentication is not enabled"})
+	}
+
+	if status, message := h.checkSecondFactor(c, user, req.Code); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		return models.ResetTwoFactor(tx, user.ID)
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+	}
+
+	events.Emit(events.EventSecurityAlert, events.SecurityAlert{
+		Alert:      events.AlertTwoFactorDisabled,
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		IPAddress:  c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		OccurredAt: time.Now().UTC(),
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// VerifyTwoFactor exchanges a login challenge and a second factor for the token pair
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge returned by login and a TOTP or recovery code for the token pair. Codes from the previous and next 30 second period are accepted.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorVerifyRequest true "Challenge and code"
+// @Success 200 {object} map[string]string "JWT token"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 401 {object} map[string]string "Invalid or expired challenge, or invalid code"
+// @Failure 429 {object} map[string]string "Too many invalid codes"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/2fa/verify [post]
+func (h *AuthHandler) VerifyTwoFactor(c echo.Context) error {
+	var req TwoFactorVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	userID, err := utils.ValidateTwoFactorChallenge(req.Challenge)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge"})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", userID).First(&user).Error; err != nil || !user.TwoFactorEnabled {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired challenge"})
+	}
+
+	if status, message := h.checkSecondFactor(c, &user, req.Code); status != http.StatusOK {
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		return c.JSON(status, map[string]string{"error": message})
+	}
+
+	return h.issueSession(c, user)
+}
+
+// checkSecondFactor accepts an unused TOTP code or recovery code. Wrong codes count
+// towards a temporary lockout. It returns 200 or the status and message to answer with.
+func (h *AuthHandler) checkSecondFactor(c echo.Context, user *models.User, code string) (int, string) {
+	now := time.Now().UTC()
+	if user.TwoFactorLockedUntil != nil && user.TwoFactorLockedUntil.After(now) {
+		return http.StatusTooManyRequests, "Too many invalid codes, try again later"
+	}
+
+	secret, err := crypto.Decrypt(user.TwoFactorSecret)
+	if err != nil {
+		h.log.Error("Failed to decrypt TOTP secret", err)
+		return http.StatusInternalServerError, "Failed to read secret"
+	}
+
+	if step, ok := utils.ValidateTOTP(secret, code, now); ok {
+		// 🎟️ A code is accepted once, the update loses when a newer or equal step was used
+		result := h.db.Model(&models.User{}).
+			Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+			Updates(map[string]interface{}{"two_factor_last_step": step, "two_factor_failures": 0, "two_factor_locked_until": nil})
+		if result.Error != nil {
+			return http.StatusInternalServerError, "Failed to verify code"
+		}
+		if result.RowsAffected == 1 {
+			return http.StatusOK, ""
+		}
+	} else if len(code) != 6 {
+		consumed, err := models.ConsumeRecoveryCode(h.db, user.ID, code)
+		if err != nil {
+			return http.StatusInternalServerError, "Failed to verify code"
+		}
+		if consumed {
+			h.db.Model(&models.User{}).Where("id = ?", user.ID).
+				Updates(map[string]interface{}{"two_factor_failures": 0, "two_factor_locked_until": nil})
+			return http.StatusOK, ""
+		}
+	}
+
+	// ⛔ Count the failure and lock the second factor after too many
+	failures := user.TwoFactorFailures + 1
+	updates := map[string]interface{}{"two_factor_failures": gorm.Expr("two_factor_failures + 1")}
+	if failures >= models.TwoFactorMaxFailures {
+		updates["two_factor_failures"] = 0
+		updates["two_factor_locked_until"] = now.Add(models.TwoFactorLockout)
+	}
+	h.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates)
+
+	events.Emit(events.EventLoginFailed, events.LoginFailed{
+		UserID:     user.ID,
+		TeamID:     user.TeamID,
+		Email:      user.Email,
+		Reason:     "invalid_second_factor",
+		IPAddress:  c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		OccurredAt: now,
+	})
+	return http.StatusBadRequest, "Invalid code"
+}
+
+// currentUser loads the signed-in user
+func (h *AuthHandler) currentUser(c echo.Context) (*models.User, error) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_deleted = false", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
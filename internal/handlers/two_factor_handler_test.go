@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+	"be0/internal/utils/crypto"
+
+	"gorm.io/gorm"
+)
+
+// twoFactorSetup returns a database with a team and a member, keys and a JWT secret
+func twoFactorSetup(t *testing.T, requireTwoFactor bool) (*gorm.DB, *AuthHandler, *models.User) {
+	t.Helper()
+	testutil.UseJWTSecret(t)
+	testutil.UseKeys(t)
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	if err := gdb.Model(team).Update("auth_require_two_factor", requireTwoFactor).Error; err != nil {
+		t.Fatal(err)
+	}
+	return gdb, newTestAuthHandler(gdb), testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+}
+
+// turnOnTwoFactor enables 2FA for user and returns the TOTP secret and recovery codes
+func turnOnTwoFactor(t *testing.T, gdb *gorm.DB, user *models.User) (string, []string) {
+	t.Helper()
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := crypto.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Model(user).Updates(map[string]interface{}{"two_factor_enabled": true, "two_factor_secret": encrypted}).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes := []string{"RECOVERY-ONE", "RECOVERY-TWO"}
+	if err := models.ReplaceRecoveryCodes(gdb, user.ID, codes); err != nil {
+		t.Fatal(err)
+	}
+	user.TwoFactorEnabled = true
+	return secret, codes
+}
+
+func login(t *testing.T, h *AuthHandler, user *models.User) map[string]interface{} {
+	t.Helper()
+	c, rec := newContext(t, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: testutil.Password})
+	expectStatus(t, rec, h.Login(c), http.StatusOK)
+	return decode(t, rec)
+}
+
+func countSessions(t *testing.T, gdb *gorm.DB, userID string) int64 {
+	t.Helper()
+	var count int64
+	if err := gdb.Model(&models.AuthTransaction{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestLoginWithoutTwoFactorPolicyIssuesSession(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, false)
+
+	body := login(t, h, user)
+	if body["token"] == nil || body["refresh_token"] == nil {
+		t.Fatalf("got %v, want a token pair", body)
+	}
+	if countSessions(t, gdb, user.ID) != 1 {
+		t.Error("no session recorded")
+	}
+}
+
+func TestTeamRequiringTwoFactorEnrollsMembersBeforeSignIn(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, true)
+
+	body := login(t, h, user)
+	challenge, _ := body["challenge"].(string)
+	if body["twoFactorEnrollmentRequired"] != true || challenge == "" || body["token"] != nil {
+		t.Fatalf("got %v, want an enrollment challenge and no tokens", body)
+	}
+	if countSessions(t, gdb, user.ID) != 0 {
+		t.Fatal("a session was recorded before the second factor")
+	}
+
+	// A login challenge cannot be used to enroll, nor an enrollment challenge to verify
+	loginChallenge, err := utils.GenerateTwoFactorChallenge(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, rec := newContext(t, http.MethodPost, "/auth/2fa/enroll", TwoFactorEnrollRequest{Challenge: loginChallenge})
+	expectStatus(t, rec, h.EnrollTwoFactor(c), http.StatusUnauthorized)
+	c, rec = newContext(t, http.MethodPost, "/auth/2fa/verify", TwoFactorVerifyRequest{Challenge: challenge, Code: "123456"})
+	expectStatus(t, rec, h.VerifyTwoFactor(c), http.StatusUnauthorized)
+
+	c, rec = newContext(t, http.MethodPost, "/auth/2fa/enroll", TwoFactorEnrollRequest{Challenge: challenge})
+	expectStatus(t, rec, h.EnrollTwoFactor(c), http.StatusOK)
+	secret, _ := decode(t, rec)["secret"].(string)
+	if secret == "" {
+		t.Fatal("no secret returned")
+	}
+
+	c, rec = newContext(t, http.MethodPost, "/auth/2fa/enroll/confirm", TwoFactorVerifyRequest{Challenge: challenge, Code: "000000"})
+	expectStatus(t, rec, h.ConfirmTwoFactorEnrollment(c), http.StatusBadRequest)
+
+	code := testutil.TOTP(t, secret, time.Now())
+	c, rec = newContext(t, http.MethodPost, "/auth/2fa/enroll/confirm", TwoFactorVerifyRequest{Challenge: challenge, Code: code})
+	expectStatus(t, rec, h.ConfirmTwoFactorEnrollment(c), http.StatusOK)
+	body = decode(t, rec)
+	if body["token"] == nil || body["refresh_token"] == nil {
+		t.Errorf("got %v, want a token pair", body)
+	}
+	if codes, _ := body["recoveryCodes"].([]interface{}); len(codes) != models.RecoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), models.RecoveryCodeCount)
+	}
+	if countSessions(t, gdb, user.ID) != 1 {
+		t.Error("no session recorded after enrollment")
+	}
+
+	// Enrolled members get the regular challenge from now on
+	if err := gdb.First(user, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !user.TwoFactorEnabled {
+		t.Fatal("2FA not enabled")
+	}
+	if body := login(t, h, user); body["twoFactorRequired"] != true {
+		t.Errorf("got %v, want a two-factor challenge", body)
+	}
+	c, rec = newContext(t, http.MethodPost, "/auth/2fa/enroll/confirm", TwoFactorVerifyRequest{Challenge: challenge, Code: code})
+	expectStatus(t, rec, h.ConfirmTwoFactorEnrollment(c), http.StatusConflict)
+}
+
+func TestTeamRequiringTwoFactorChallengesEnrolledMembers(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, true)
+	secret, _ := turnOnTwoFactor(t, gdb, user)
+
+	body := login(t, h, user)
+	challenge, _ := body["challenge"].(string)
+	if body["twoFactorRequired"] != true || challenge == "" {
+		t.Fatalf("got %v, want a two-factor challenge", body)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/2fa/verify", TwoFactorVerifyRequest{Challenge: challenge, Code: testutil.TOTP(t, secret, time.Now())})
+	expectStatus(t, rec, h.VerifyTwoFactor(c), http.StatusOK)
+	if countSessions(t, gdb, user.ID) != 1 {
+		t.Error("no session recorded")
+	}
+}
+
+func TestIssueSessionRefusesMembersWithoutTwoFactor(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, true)
+
+	c, rec := newContext(t, http.MethodPost, "/auth/switch-team", nil)
+	expectStatus(t, rec, h.issueSession(c, *user), http.StatusForbidden)
+	if got := decode(t, rec)["code"]; got != "two_factor_enrollment_required" {
+		t.Errorf("code %v, want two_factor_enrollment_required", got)
+	}
+	if countSessions(t, gdb, user.ID) != 0 {
+		t.Error("a session was recorded")
+	}
+}
+
+func TestRefreshStopsOnceTeamRequiresTwoFactor(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, false)
+	refreshToken, _ := login(t, h, user)["refresh_token"].(string)
+
+	if err := gdb.Model(&models.Team{}).Where("id = ?", user.TeamID).Update("auth_require_two_factor", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c, rec := newContext(t, http.MethodPost, "/auth/refresh", RefreshTokenRequest{RefreshToken: refreshToken})
+	expectStatus(t, rec, h.RefreshToken(c), http.StatusForbidden)
+	if got := decode(t, rec)["code"]; got != "two_factor_enrollment_required" {
+		t.Errorf("code %v, want two_factor_enrollment_required", got)
+	}
+}
+
+func TestVerifyTwoFactorCodesWorkOnce(t *testing.T) {
+	gdb, h, user := twoFactorSetup(t, false)
+	secret, recoveryCodes := turnOnTwoFactor(t, gdb, user)
+
+	verify := func(code string) int {
+		challenge, err := utils.GenerateTwoFactorChallenge(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, rec := newContext(t, http.MethodPost, "/auth/2fa/verify", TwoFactorVerifyRequest{Challenge: challenge, Code: code})
+		if err := h.VerifyTwoFactor(c); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want int
+	}{
+		{"code of the previous period", testutil.TOTP(t, secret, time.Now().Add(-utils.TOTPPeriod)), http.StatusOK},
+		{"code of the current period", testutil.TOTP(t, secret, time.Now()), http.StatusOK},
+		{"reused code", testutil.TOTP(t, secret, time.Now()), http.StatusUnauthorized},
+		{"older code after a newer one", testutil.TOTP(t, secret, time.Now().Add(-utils.TOTPPeriod)), http.StatusUnauthorized},
+		{"recovery code", recoveryCodes[0], http.StatusOK},
+		{"reused recovery code", recoveryCodes[0], http.StatusUnauthorized},
+		{"other recovery code", recoveryCodes[1], http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := verify(tt.code); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
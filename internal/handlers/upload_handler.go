@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"be0/internal/api/middleware"
+	"be0/internal/config"
 	"be0/internal/db"
+	"be0/internal/extract"
+	"be0/internal/metrics"
 	"be0/internal/models"
+	"be0/internal/tasks"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -14,18 +20,24 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// uploadedBytes counts the bytes stored by uploads, by whether a user or an API key uploaded them
+var uploadedBytes = metrics.NewCounter("be0_upload_bytes_total",
+	"Bytes uploaded to storage by uploader kind.", "uploader")
+
 type UploadHandler struct {
-	log *logger.Logger
-	acl types.ObjectCannedACL
+	log        *logger.Logger
+	acl        types.ObjectCannedACL
+	taskClient *tasks.TaskClient
 }
 
-func NewUploadHandler(acl types.ObjectCannedACL) *UploadHandler {
+func NewUploadHandler(acl types.ObjectCannedACL, taskClient *tasks.TaskClient) *UploadHandler {
 	if acl == "" {
-		acl = types.ObjectCannedACLPublicRead
+		acl = types.ObjectCannedACLAuthenticatedRead
 	}
 	return &UploadHandler{
-		log: logger.New("upload_handler"),
-		acl: acl,
+		log:        logger.New("upload_handler"),
+		acl:        acl,
+		taskClient: taskClient,
 	}
 }
 
@@ -35,11 +47,18 @@ func NewUploadHandler(acl types.ObjectCannedACL) *UploadHandler {
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
+// @Param acl formData string false "Canned ACL: private, public-read or authenticated-read (defaults to the deployment default)"
 // @Success 200 {object} map[string]string "File uploaded successfully"
-// @Failure 400 {object} map[string]string "Validation error or file not found"
+// @Failure 400 {object} map[string]string "Validation error, unknown ACL or file not found"
+// @Failure 401 {object} map[string]string "Not signed in"
+// @Failure 403 {object} map[string]string "ACL not allowed by team storage policy, or no user or API key to upload as"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/files/upload [post]
 func (h *UploadHandler) UploadFile(c echo.Context) error {
+	teamID, userID, err := uploader(c)
+	if err != nil {
+		return err
+	}
 
 	contentType := c.Request().Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "multipart/form-data") {
@@ -55,10 +74,27 @@ func (h *UploadHandler) UploadFile(c echo.Context) error {
 		})
 	}
 
+	acl := h.acl
+	if requested := c.FormValue("acl"); requested != "" {
+		// 🛡️ Only the ACLs files may have reach the storage provider
+		if !slices.Contains(fileACLs, requested) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "acl must be one of " + strings.Join(fileACLs, ", "),
+			})
+		}
+		acl = types.ObjectCannedACL(requested)
+	}
+
+	// Check the ACL the provider will actually apply against the team policy
+	acl = storage.EffectiveACL(acl)
+	if err := h.checkTeamPolicy(c, teamID, acl); err != nil {
+		return err
+	}
+
 	// Get file from request
 	file, err := c.FormFile("file")
 	if err != nil {
-		h.log.Error("Failed to get file from request", err)
+		h.log.FromContext(c.Request().Context()).Error("Failed to get file from request", err)
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "No file provided",
 		})
@@ -80,22 +116,30 @@ func (h *UploadHandler) UploadFile(c echo.Context) error {
 	}
 
 	// Upload file to S3
-	url, err := storage.UploadFile(c.Request().Context(), content, file.Filename, h.acl, file.Header.Get("Content-Type"))
+	url, err := storage.UploadFile(c.Request().Context(), content, file.Filename, acl, file.Header.Get("Content-Type"))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": "Failed to upload file",
 		})
 	}
 
-	h.log.Success("File uploaded successfully: %s", url)
+	h.log.FromContext(c.Request().Context()).Success("File uploaded successfully: %s", url)
+
+	uploaderKind := "user"
+	if userID == nil {
+		uploaderKind = "api_key"
+	}
+	uploadedBytes.WithLabelValues(uploaderKind).Add(float64(len(content)))
 
 	fileModel := &models.File{
-		TeamID: c.Get("teamID").(string),
-		UserID: c.Get("userID").(string),
-		Path:   url[strings.LastIndex(url, "/")+1:],
-		Name:   file.Filename,
-		Size:   file.Size,
-		Type:   file.Header.Get("Content-Type"),
+		TeamID:    teamID,
+		UserID:    userID,
+		Path:      url[strings.LastIndex(url, "/")+1:],
+		Name:      file.Filename,
+		Size:      file.Size,
+		Type:      file.Header.Get("Content-Type"),
+		Extension: models.FileExtension(file.Filename),
+		ACL:       string(acl),
 	}
 
 	getDb := db.GetDB()
@@ -104,7 +148,7 @@ func (h *UploadHandler) UploadFile(c echo.Context) error {
 	err = getDb.Create(fileModel).Error
 
 	if err != nil {
-		err := h.log.Error("Failed to insert file into database", err)
+		err := h.log.FromContext(c.Request().Context()).Error("Failed to insert file into database", err)
 		if err != nil {
 			return err
 		}
@@ -113,8 +157,112 @@ func (h *UploadHandler) UploadFile(c echo.Context) error {
 		})
 	}
 
+	// 📄 Make documents searchable by their content
+	if extract.Supported(fileModel.Type) {
+		if err := h.taskClient.EnqueueFileExtract(c.Request().Context(), fileModel.ID); err != nil {
+			h.log.FromContext(c.Request().Context()).Warn("Failed to enqueue text extraction for file %s: %v", fileModel.ID, err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "File uploaded successfully",
 		"file":    fileModel.ID,
 	})
 }
+
+// uploader returns the team and user an upload is made by. API keys upload for their team
+// without a user, other requests without a user are rejected.
+func uploader(c echo.Context) (teamID string, userID *string, err error) {
+	teamID = middleware.GetTeamID(c)
+	if teamID == "" {
+		return "", nil, echo.NewHTTPError(http.StatusUnauthorized, "Sign in or use an API key to upload files")
+	}
+	if middleware.IsAPIKey(c) {
+		return teamID, nil, nil
+	}
+
+	id := middleware.GetUserID(c)
+	if id == "" {
+		return "", nil, echo.NewHTTPError(http.StatusForbidden, "Uploads need a signed-in user or an API key")
+	}
+	return teamID, &id, nil
+}
+
+// checkTeamPolicy rejects ACLs that the team storage policy does not allow
+func (h *UploadHandler) checkTeamPolicy(c echo.Context, teamID string, acl types.ObjectCannedACL) error {
+	team, err := models.GetTeamByID(teamID, db.GetDB())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load team storage policy")
+	}
+
+	if err := team.CheckACL(string(acl)); err != nil {
+		h.log.FromContext(c.Request().Context()).Warn("Rejected ACL %s for team %s: %v", acl, teamID, err)
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	return nil
+}
+
+// fileACLs are the canned ACLs uploads and UpdateFileACL accept
+var fileACLs = config.StorageACLs
+
+type UpdateFileACLRequest struct {
+	ACL string `json:"acl" validate:"required,oneof=private public-read authenticated-read"`
+}
+
+// UpdateFileACL changes the ACL of an uploaded file
+// @Summary Update file ACL
+// @Description Change the canned ACL of an uploaded file, subject to the team storage policy
+// @Accept json
+// @Produce json
+// @Param id path string true "File ID"
+// @Param request body UpdateFileACLRequest true "New ACL"
+// @Success 200 {object} map[string]string "ACL updated successfully"
+// @Failure 400 {object} map[string]string "Validation error"
+// @Failure 401 {object} map[string]string "Not signed in"
+// @Failure 403 {object} map[string]string "ACL not allowed by team storage policy"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/files/{id}/acl [put]
+func (h *UploadHandler) UpdateFileACL(c echo.Context) error {
+	var req UpdateFileACLRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	storage := GetStorageHandler()
+	if storage == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Storage handler not configured",
+		})
+	}
+
+	teamID := middleware.GetTeamID(c)
+	if teamID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Sign in or use an API key to change file ACLs"})
+	}
+
+	getDb := db.GetDB()
+	var file models.File
+	if err := getDb.Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), teamID).First(&file).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "File not found"})
+	}
+
+	acl := storage.EffectiveACL(types.ObjectCannedACL(req.ACL))
+	if err := h.checkTeamPolicy(c, teamID, acl); err != nil {
+		return err
+	}
+
+	if err := storage.SetObjectACL(c.Request().Context(), file.Path, acl); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update file ACL"})
+	}
+
+	if err := getDb.Model(&file).Update("acl", string(acl)).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update file ACL"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "ACL updated successfully", "acl": string(acl)})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/services"
+	"be0/internal/testutil"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// recordingStorage records the ACLs of uploads, S3Service decides the effective ACL of the
+// provider
+type recordingStorage struct {
+	services.S3Service
+	uploaded []types.ObjectCannedACL
+}
+
+func (s *recordingStorage) UploadFile(_ context.Context, _ []byte, filename string, acl types.ObjectCannedACL, _ string) (string, error) {
+	s.uploaded = append(s.uploaded, acl)
+	return "https://storage.example.com/" + filename, nil
+}
+
+func (s *recordingStorage) SetObjectACL(context.Context, string, types.ObjectCannedACL) error {
+	return nil
+}
+
+// uploadRequest uploads a PNG as a member of team, acl "" sends none
+func uploadRequest(t *testing.T, h *UploadHandler, teamID, userID, acl string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if acl != "" {
+		form.WriteField("acl", acl)
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="logo.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("\x89PNG"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("teamID", teamID)
+	c.Set("userID", userID)
+	if err := h.UploadFile(c); err != nil {
+		httpErr, ok := err.(*echo.HTTPError)
+		if !ok {
+			t.Fatal(err)
+		}
+		rec.Code = httpErr.Code
+	}
+	return rec
+}
+
+func TestUploadACLs(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	open := testutil.CreateTeam(t, gdb, "Acme")
+	private := testutil.CreateTeam(t, gdb, "Globex")
+	if err := gdb.Model(private).Update("storage_policy", models.StoragePolicyPrivateOnly).Error; err != nil {
+		t.Fatal(err)
+	}
+	openUser := testutil.CreateUser(t, gdb, open.ID, models.UserRoleMember)
+	privateUser := testutil.CreateUser(t, gdb, private.ID, models.UserRoleMember)
+
+	storage := &recordingStorage{}
+	previous := GetStorageHandler()
+	RegisterStorageHandler(storage)
+	t.Cleanup(func() { RegisterStorageHandler(previous) })
+
+	cases := []struct {
+		name       string
+		provider   string
+		defaultACL types.ObjectCannedACL
+		private    bool
+		acl        string
+		status     int
+		stored     types.ObjectCannedACL
+	}{
+		{name: "built-in default", provider: "s3", status: http.StatusOK, stored: types.ObjectCannedACLAuthenticatedRead},
+		{name: "deployment default", provider: "s3", defaultACL: types.ObjectCannedACLPrivate, status: http.StatusOK, stored: types.ObjectCannedACLPrivate},
+		{name: "requested on S3", provider: "s3", defaultACL: types.ObjectCannedACLPrivate, acl: "public-read", status: http.StatusOK, stored: types.ObjectCannedACLPublicRead},
+		{name: "public on S3 for a private team", provider: "s3", private: true, acl: "public-read", status: http.StatusForbidden},
+		{name: "private on S3 for a private team", provider: "s3", private: true, acl: "private", status: http.StatusOK, stored: types.ObjectCannedACLPrivate},
+		// ☁️ R2 has no per-object ACLs, every object is public
+		{name: "private on R2", provider: "r2", acl: "private", status: http.StatusOK, stored: types.ObjectCannedACLPublicRead},
+		{name: "default on R2 for a private team", provider: "r2", private: true, status: http.StatusForbidden},
+		// 🛡️ Made-up and world-writable ACLs never reach the provider
+		{name: "world-writable", provider: "s3", acl: "public-read-write", status: http.StatusBadRequest},
+		{name: "made-up", provider: "s3", acl: "everyone", status: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("STORAGE_PROVIDER", tc.provider)
+			storage.uploaded = nil
+			teamID, userID := open.ID, openUser.ID
+			if tc.private {
+				teamID, userID = private.ID, privateUser.ID
+			}
+
+			rec := uploadRequest(t, NewUploadHandler(tc.defaultACL, nil), teamID, userID, tc.acl)
+			if rec.Code != tc.status {
+				t.Fatalf("status %d %s, want %d", rec.Code, rec.Body, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				if len(storage.uploaded) != 0 {
+					t.Errorf("uploaded with %v, want no upload", storage.uploaded)
+				}
+				return
+			}
+			if len(storage.uploaded) != 1 || storage.uploaded[0] != tc.stored {
+				t.Errorf("uploaded with %v, want %s", storage.uploaded, tc.stored)
+			}
+			var file models.File
+			if err := gdb.First(&file, "id = ?", decode(t, rec)["file"]).Error; err != nil {
+				t.Fatal(err)
+			}
+			if file.ACL != string(tc.stored) {
+				t.Errorf("file stored with ACL %s, want %s", file.ACL, tc.stored)
+			}
+		})
+	}
+}
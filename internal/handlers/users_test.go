@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// manageUser calls a user management handler as caller on the user with id, returning the status
+func manageUser(t *testing.T, handler echo.HandlerFunc, method string, caller *models.User, id string, body interface{}) int {
+	t.Helper()
+	c, rec := newContext(t, method, "/users/", body)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("userID", caller.ID)
+	c.Set("teamID", caller.TeamID)
+	c.Set("role", string(caller.Role))
+
+	err := handler(c)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code
+}
+
+func TestManagedUserLookup(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := newTestAuthHandler(gdb)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Other")
+	admin := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleAdmin)
+	superAdmin := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleSuperAdmin)
+	teammate := testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	outsider := testutil.CreateUser(t, gdb, other.ID, models.UserRoleMember)
+
+	tests := []struct {
+		name   string
+		caller *models.User
+		id     string
+		status int
+	}{
+		{name: "teammate", caller: admin, id: teammate.ID, status: http.StatusOK},
+		{name: "other team", caller: admin, id: outsider.ID, status: http.StatusNotFound},
+		{name: "other team as super admin", caller: superAdmin, id: outsider.ID, status: http.StatusOK},
+		{name: "unknown id", caller: admin, id: "5574fee5-3ce4-49e5-af2e-21361fc433e4", status: http.StatusNotFound},
+		{name: "integer id", caller: admin, id: "42", status: http.StatusBadRequest},
+		{name: "malformed id", caller: admin, id: "not-a-uuid", status: http.StatusBadRequest},
+		{name: "sql in id", caller: admin, id: teammate.ID + "' OR '1'='1", status: http.StatusBadRequest},
+		{name: "empty id", caller: admin, id: "", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := manageUser(t, h.GetUser, http.MethodGet, tt.caller, tt.id, nil); status != tt.status {
+				t.Errorf("GetUser: status %d, want %d", status, tt.status)
+			}
+			// Updates and deletes find users the same way, failed lookups change nothing
+			if tt.status != http.StatusOK {
+				update := UpdateUserRequest{FirstName: "Changed", Role: models.UserRoleMember}
+				if status := manageUser(t, h.UpdateUser, http.MethodPut, tt.caller, tt.id, update); status != tt.status {
+					t.Errorf("UpdateUser: status %d, want %d", status, tt.status)
+				}
+				if status := manageUser(t, h.DeleteUser, http.MethodDelete, tt.caller, tt.id, nil); status != tt.status {
+					t.Errorf("DeleteUser: status %d, want %d", status, tt.status)
+				}
+			}
+		})
+	}
+
+	if reloaded := reloadUser(t, gdb, outsider.ID); reloaded.IsDeleted || reloaded.FirstName == "Changed" {
+		t.Error("the user of another team was changed")
+	}
+}
+
+func TestUpdateUserRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		target models.UserRole // empty to update the caller themselves
+		role   models.UserRole
+		status int
+	}{
+		{name: "promote a member", target: models.UserRoleMember, role: models.UserRoleAdmin, status: http.StatusOK},
+		{name: "demote an admin", target: models.UserRoleAdmin, role: models.UserRoleMember, status: http.StatusOK},
+		{name: "self-demotion", role: models.UserRoleMember, status: http.StatusForbidden},
+		{name: "keep own role", role: models.UserRoleAdmin, status: http.StatusOK},
+		{name: "grant a role above the caller's", target: models.UserRoleMember, role: models.UserRoleSuperAdmin, status: http.StatusForbidden},
+		{name: "edit a super admin", target: models.UserRoleSuperAdmin, role: models.UserRoleMember, status: http.StatusForbidden},
+		{name: "unknown role", target: models.UserRoleMember, role: "OWNER", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+			target := admin
+			if tt.target != "" {
+				target = testutil.CreateUser(t, gdb, team.ID, tt.target)
+			}
+			before := target.Role
+
+			update := UpdateUserRequest{FirstName: "Renamed", Role: tt.role}
+			if status := manageUser(t, h.UpdateUser, http.MethodPut, admin, target.ID, update); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+
+			want := before
+			if tt.status == http.StatusOK {
+				want = tt.role
+			}
+			if role := reloadUser(t, gdb, target.ID).Role; role != want {
+				t.Errorf("role %s, want %s", role, want)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		target models.UserRole // empty to delete the caller themselves
+		status int
+	}{
+		{name: "member", target: models.UserRoleMember, status: http.StatusOK},
+		{name: "fellow admin", target: models.UserRoleAdmin, status: http.StatusOK},
+		{name: "self", status: http.StatusForbidden},
+		{name: "super admin", target: models.UserRoleSuperAdmin, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gdb := testutil.NewDB(t)
+			h := newTestAuthHandler(gdb)
+			team := testutil.CreateTeam(t, gdb, "Acme")
+			admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+			target := admin
+			if tt.target != "" {
+				target = testutil.CreateUser(t, gdb, team.ID, tt.target)
+			}
+
+			if status := manageUser(t, h.DeleteUser, http.MethodDelete, admin, target.ID, nil); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if deleted := reloadUser(t, gdb, target.ID).IsDeleted; deleted != (tt.status == http.StatusOK) {
+				t.Errorf("deleted %v, want %v", deleted, tt.status == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestListUsersRedactsByCallerScope(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	h := newTestAuthHandler(gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	if err := gdb.Model(member).Updates(map[string]interface{}{"provider": "google", "provider_id": "g-123"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/users", h.ListUsers, middleware.NewAuthMiddleware(testutil.JWTSecret).Middleware())
+	list := func(caller *models.User) map[string]map[string]interface{} {
+		t.Helper()
+		token, tokenID, err := utils.GenerateJWT(*caller, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session := models.AuthTransaction{UserID: caller.ID, TeamID: caller.TeamID, TokenID: tokenID, ExpiresAt: time.Now().UTC().Add(time.Hour)}
+		if err := gdb.Create(&session).Error; err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s listing got %d %s", caller.Role, rec.Code, rec.Body)
+		}
+		users := map[string]map[string]interface{}{}
+		for _, user := range decode(t, rec)["data"].([]interface{}) {
+			user := user.(map[string]interface{})
+			users[user["id"].(string)] = user
+		}
+		return users
+	}
+
+	// 🔓 Admins hold users:read_pii through their wildcard
+	asAdmin := list(admin)[member.ID]
+	if asAdmin["email"] != member.Email || asAdmin["provider"] != "google" {
+		t.Errorf("admin sees %v / %v, want %s / google", asAdmin["email"], asAdmin["provider"], member.Email)
+	}
+
+	// 🙈 Members see the team without its PII, the rest of the shape is unchanged
+	asMember := list(member)
+	if len(asMember) != 2 {
+		t.Fatalf("member sees %d users, want 2", len(asMember))
+	}
+	for id, user := range asMember {
+		email, _ := user["email"].(string)
+		if !strings.HasSuffix(email, "***@example.com") || user["provider"] != nil {
+			t.Errorf("member sees %s as %q / %v, want a masked email and no provider", id, email, user["provider"])
+		}
+		if user["firstName"] != "Test" || user["role"] == nil {
+			t.Errorf("member sees %s without its unredacted fields: %v", id, user)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/redact"
+	"be0/internal/utils"
+	"be0/internal/utils/logger"
+	"be0/internal/webhooks"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db, log: logger.New("WebhookHandler")}
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,startswith=https://"`
+	Events []string `json:"events" validate:"required,min=1"`
+}
+
+// WebhookEventDoc documents a subscribable event with an example delivery
+type WebhookEventDoc struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Example     webhooks.Envelope `json:"example"`
+}
+
+// ListEvents lists the events teams can subscribe to
+// @Summary List webhook events
+// @Description List subscribable event names with example delivery payloads
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} WebhookEventDoc
+// @Router /webhooks/events [get]
+func (h *WebhookHandler) ListEvents(c echo.Context) error {
+	catalog := webhooks.Catalog()
+	docs := make([]WebhookEventDoc, 0, len(catalog))
+	for _, def := range catalog {
+		docs = append(docs, WebhookEventDoc{
+			Name:        def.Name,
+			Description: def.Description,
+			Example: webhooks.Envelope{
+				ID:        "3c2b1a09-8f7e-4d6c-9b5a-4e3d2c1b0a9f",
+				Event:     def.Name,
+				TeamID:    def.Example.EventTeamID(),
+				CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+				Data:      def.Example,
+			},
+		})
+	}
+	return c.JSON(http.StatusOK, docs)
+}
+
+// CreateSubscription subscribes the current team to events
+// @Summary Create webhook subscription
+// @Description Subscribe the current team to events. The signing secret is only returned once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Endpoint and events"
+// @Success 201 {object} map[string]interface{} "Subscription and signing secret"
+// @Failure 400 {object} map[string]string "Validation error or unknown event"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateSubscription(c echo.Context) error {
+	var req CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	for _, event := range req.Events {
+		if _, ok := webhooks.Lookup(event); !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown event: " + event})
+		}
+	}
+
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate secret"})
+	}
+
+	subscription := &models.WebhookSubscription{
+		TeamID: c.Get("teamID").(string),
+		URL:    req.URL,
+		Secret: secret,
+		Events: req.Events,
+		Active: true,
+	}
+	if err := h.db.Create(subscription).Error; err != nil {
+		h.log.Error("Failed to create webhook subscription", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create subscription"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"subscription": subscription,
+		"secret":       secret,
+	})
+}
+
+// ListSubscriptions lists the current team's subscriptions
+// @Summary List webhook subscriptions
+// @Description List the current team's webhook subscriptions
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookSubscription
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListSubscriptions(c echo.Context) error {
+	var subscriptions []models.WebhookSubscription
+	if err := h.db.Where("team_id = ? AND is_deleted = false", c.Get("teamID").(string)).
+		Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list subscriptions"})
+	}
+	return redact.JSON(c, http.StatusOK, subscriptions)
+}
+
+// DeleteSubscription removes one of the current team's subscriptions
+// @Summary Delete webhook subscription
+// @Description Stop delivering events to an endpoint
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} map[string]string "Subscription deleted"
+// @Failure 404 {object} map[string]string "Subscription not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteSubscription(c echo.Context) error {
+	result := h.db.Model(&models.WebhookSubscription{}).
+		Where("id = ? AND team_id = ? AND is_deleted = false", c.Param("id"), c.Get("teamID").(string)).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC(), "active": false})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete subscription"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Subscription not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Subscription deleted"})
+}
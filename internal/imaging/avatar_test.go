@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAvatarURLsVerifyForTheirUserAndSize(t *testing.T) {
+	signer := NewAvatarSigner("https://api.example.com/", "avatar-key")
+	raw := signer.AvatarURL("user-1", 128)
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "api.example.com" || u.Path != "/public/avatars/user-1" || u.Query().Get("s") != "128" {
+		t.Fatalf("got %s", raw)
+	}
+	sig := u.Query().Get("sig")
+
+	tests := []struct {
+		name   string
+		signer *AvatarSigner
+		userID string
+		size   int
+		want   bool
+	}{
+		{"as issued", signer, "user-1", 128, true},
+		{"another size", signer, "user-1", 512, false},
+		{"another user", signer, "user-2", 128, false},
+		{"another key", NewAvatarSigner("https://api.example.com", "other-key"), "user-1", 128, false},
+	}
+	for _, tt := range tests {
+		if got := tt.signer.Verify(tt.userID, tt.size, sig); got != tt.want {
+			t.Errorf("%s: verified %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAvatarSizesAreClamped(t *testing.T) {
+	signer := NewAvatarSigner("https://api.example.com", "avatar-key")
+	for size, want := range map[int]int{0: DefaultAvatarSize, 1: MinAvatarSize, 100: 100, 4096: MaxAvatarSize} {
+		u, _ := url.Parse(signer.AvatarURL("user-1", size))
+		if got := u.Query().Get("s"); got != strconv.Itoa(want) {
+			t.Errorf("size %d signed as %s, want %d", size, got, want)
+		}
+	}
+}
+
+func TestInitialsSVG(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        string
+	}{
+		{"ada", "lovelace", ">AL</text>"},
+		{"  Émile", "", ">É</text>"},
+		{"<b>", "&", ">?</text>"},
+		{"", "", ">?</text>"},
+	}
+	for _, tt := range tests {
+		svg := string(InitialsSVG("user-1", tt.first, tt.last, 64))
+		if !strings.Contains(svg, tt.want) || !strings.Contains(svg, `width="64" height="64"`) {
+			t.Errorf("%q %q: got %s", tt.first, tt.last, svg)
+		}
+	}
+
+	// 🎨 The colour depends on the seed only, so it stays when the name changes
+	if string(InitialsSVG("user-1", "A", "", 64)) != strings.Replace(string(InitialsSVG("user-1", "B", "", 64)), ">B<", ">A<", 1) {
+		t.Error("the colour changed with the name")
+	}
+}
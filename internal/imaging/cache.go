@@ -0,0 +1,40 @@
+package imaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const cacheKeyPrefix = "image_variant:"
+
+// Cache stores rendered variants in Redis. Hits refresh the TTL so popular variants
+// stay cached while unused ones expire, with Redis eviction as the memory backstop.
+type Cache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewCache creates a variant cache
+func NewCache(client *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{client: client, ttl: ttl}
+}
+
+// Get returns a cached variant, or ok=false on a miss
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := c.client.GetEx(ctx, cacheKeyPrefix+key, c.ttl).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Set stores a variant
+func (c *Cache) Set(ctx context.Context, key string, data []byte) error {
+	return c.client.Set(ctx, cacheKeyPrefix+key, data, c.ttl).Err()
+}
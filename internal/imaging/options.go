@@ -0,0 +1,105 @@
+// Package imaging renders resized variants of uploaded images
+package imaging
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Fit modes
+const (
+	// FitCover fills the box and crops the overflow around the centre
+	FitCover = "cover"
+	// FitContain fits the whole image inside the box, keeping its aspect ratio
+	FitContain = "contain"
+	// FitFill stretches the image to the box
+	FitFill = "fill"
+)
+
+// Output formats
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// DefaultQuality is the JPEG and WebP quality used when q is not set
+const DefaultQuality = 82
+
+// Options describe a variant. Zero width or height means "derive from the aspect ratio".
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// ParseOptions reads w, h, fit, format and q from query parameters, capping dimensions at maxDimension
+func ParseOptions(query url.Values, maxDimension int) (Options, error) {
+	opts := Options{Fit: FitCover, Format: FormatJPEG, Quality: DefaultQuality}
+
+	var err error
+	if opts.Width, err = dimension(query, "w", maxDimension); err != nil {
+		return Options{}, err
+	}
+	if opts.Height, err = dimension(query, "h", maxDimension); err != nil {
+		return Options{}, err
+	}
+
+	if fit := strings.ToLower(query.Get("fit")); fit != "" {
+		switch fit {
+		case FitCover, FitContain, FitFill:
+			opts.Fit = fit
+		default:
+			return Options{}, fmt.Errorf("fit must be cover, contain or fill")
+		}
+	}
+
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format == "jpg" {
+			format = FormatJPEG
+		}
+		if !SupportsFormat(format) {
+			return Options{}, fmt.Errorf("unsupported format %q", format)
+		}
+		opts.Format = format
+	}
+
+	if q := query.Get("q"); q != "" {
+		quality, err := strconv.Atoi(q)
+		if err != nil || quality < 1 || quality > 100 {
+			return Options{}, fmt.Errorf("q must be between 1 and 100")
+		}
+		opts.Quality = quality
+	}
+
+	return opts, nil
+}
+
+func dimension(query url.Values, name string, maxDimension int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	if value > maxDimension {
+		return 0, fmt.Errorf("%s must be at most %d", name, maxDimension)
+	}
+	return value, nil
+}
+
+// Key is a canonical representation of the options, used in cache keys and ETags
+func (o Options) Key() string {
+	return fmt.Sprintf("w%d-h%d-%s-q%d.%s", o.Width, o.Height, o.Fit, o.Quality, o.Format)
+}
+
+// ContentType is the MIME type of the rendered variant
+func (o Options) ContentType() string {
+	return "image/" + o.Format
+}
@@ -0,0 +1,218 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// MaxSourcePixels rejects images whose decoded size would exhaust memory
+const MaxSourcePixels = 40_000_000
+
+// ErrUnsupportedImage is returned for files that cannot be decoded as an image
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// SupportsFormat reports whether variants can be encoded in a format. WebP needs the
+// cwebp binary on PATH.
+func SupportsFormat(format string) bool {
+	switch format {
+	case FormatJPEG, FormatPNG:
+		return true
+	case FormatWebP:
+		_, err := exec.LookPath("cwebp")
+		return err == nil
+	}
+	return false
+}
+
+// Transform decodes an image, resizes it according to opts and encodes the variant
+func Transform(ctx context.Context, src []byte, opts Options, maxDimension int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > MaxSourcePixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	crop, width, height := layout(img.Bounds(), opts, maxDimension)
+	rgba := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, crop.Min, draw.Src)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return encode(ctx, resize(rgba, width, height), opts)
+}
+
+// layout returns the source rectangle to use and the output size
+func layout(bounds image.Rectangle, opts Options, maxDimension int) (image.Rectangle, int, int) {
+	sw, sh := float64(bounds.Dx()), float64(bounds.Dy())
+	w, h := float64(opts.Width), float64(opts.Height)
+
+	switch {
+	case w == 0 && h == 0:
+		// Re-encode at the original size, capped
+		scale := math.Min(1, float64(maxDimension)/math.Max(sw, sh))
+		return bounds, round(sw * scale), round(sh * scale)
+	case h == 0:
+		return bounds, int(w), min(round(sh*w/sw), maxDimension)
+	case w == 0:
+		return bounds, min(round(sw*h/sh), maxDimension), int(h)
+	}
+
+	switch opts.Fit {
+	case FitFill:
+		return bounds, int(w), int(h)
+	case FitContain:
+		scale := math.Min(w/sw, h/sh)
+		return bounds, round(sw * scale), round(sh * scale)
+	default:
+		// ✂️ Cover: crop the source to the target aspect ratio around the centre
+		scale := math.Max(w/sw, h/sh)
+		cw, ch := round(w/scale), round(h/scale)
+		x := bounds.Min.X + (bounds.Dx()-cw)/2
+		y := bounds.Min.Y + (bounds.Dy()-ch)/2
+		return image.Rect(x, y, x+cw, y+ch), int(w), int(h)
+	}
+}
+
+func round(v float64) int {
+	if r := int(math.Round(v)); r > 0 {
+		return r
+	}
+	return 1
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// contributions maps each output pixel to the source pixels it covers, weighted by overlap
+func contributions(srcLen, dstLen int) [][]contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	result := make([][]contribution, dstLen)
+	for i := range result {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		var total float64
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			weight := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if weight <= 0 {
+				continue
+			}
+			result[i] = append(result[i], contribution{index: j, weight: weight})
+			total += weight
+		}
+		for k := range result[i] {
+			result[i][k].weight /= total
+		}
+	}
+	return result
+}
+
+// resize scales an image with area averaging, which gives clean downscales for avatars
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == width && sh == height {
+		return src
+	}
+
+	// Horizontal pass into a float buffer, then vertical pass into the output
+	cols := contributions(sw, width)
+	tmp := make([]float64, width*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, cs := range cols {
+			var r, g, b, a float64
+			for _, c := range cs {
+				p := row[c.index*4:]
+				r += float64(p[0]) * c.weight
+				g += float64(p[1]) * c.weight
+				b += float64(p[2]) * c.weight
+				a += float64(p[3]) * c.weight
+			}
+			o := (y*width + x) * 4
+			tmp[o], tmp[o+1], tmp[o+2], tmp[o+3] = r, g, b, a
+		}
+	}
+
+	rows := contributions(sh, height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, cs := range rows {
+		for x := 0; x < width; x++ {
+			var px [4]float64
+			for _, c := range cs {
+				o := (c.index*width + x) * 4
+				for k := 0; k < 4; k++ {
+					px[k] += tmp[o+k] * c.weight
+				}
+			}
+			o := y*dst.Stride + x*4
+			for k := 0; k < 4; k++ {
+				dst.Pix[o+k] = uint8(math.Min(255, math.Round(px[k])))
+			}
+		}
+	}
+	return dst
+}
+
+func encode(ctx context.Context, img image.Image, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	switch opts.Format {
+	case FormatPNG:
+		err := png.Encode(&buf, img)
+		return buf.Bytes(), err
+	case FormatWebP:
+		return encodeWebP(ctx, img, opts.Quality)
+	default:
+		// JPEG has no alpha channel, flatten transparent areas onto white
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: opts.Quality})
+		return buf.Bytes(), err
+	}
+}
+
+// encodeWebP shells out to cwebp, the standard library has no WebP encoder
+func encodeWebP(ctx context.Context, img image.Image, quality int) ([]byte, error) {
+	in, err := os.CreateTemp("", "be0-image-*.png")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+
+	if err := png.Encode(in, img); err != nil {
+		in.Close()
+		return nil, err
+	}
+	if err := in.Close(); err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "cwebp", "-quiet", "-q", strconv.Itoa(quality), in.Name(), "-o", "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cwebp failed: %w: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
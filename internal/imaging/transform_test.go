@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+// halves is a 200x100 PNG, red on the left and blue on the right
+func halves(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 100 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Options
+		err   bool
+	}{
+		{"", Options{Fit: FitCover, Format: FormatJPEG, Quality: DefaultQuality}, false},
+		{"w=64&h=32&fit=Contain&format=jpg&q=90", Options{Width: 64, Height: 32, Fit: FitContain, Format: FormatJPEG, Quality: 90}, false},
+		{"format=png", Options{Fit: FitCover, Format: FormatPNG, Quality: DefaultQuality}, false},
+		{"w=0", Options{}, true},
+		{"h=2001", Options{}, true},
+		{"fit=stretch", Options{}, true},
+		{"format=tiff", Options{}, true},
+		{"q=101", Options{}, true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := ParseOptions(query, 2000)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.query, got, err, tt.want)
+		}
+	}
+}
+
+func TestTransformFitsTheBox(t *testing.T) {
+	src := halves(t)
+
+	tests := []struct {
+		name          string
+		opts          Options
+		width, height int
+	}{
+		{"cover", Options{Width: 50, Height: 50, Fit: FitCover}, 50, 50},
+		{"contain", Options{Width: 50, Height: 50, Fit: FitContain}, 50, 25},
+		{"fill", Options{Width: 50, Height: 50, Fit: FitFill}, 50, 50},
+		{"width only", Options{Width: 100}, 100, 50},
+		{"height only", Options{Height: 20}, 40, 20},
+		{"original size capped", Options{}, 80, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Format, tt.opts.Quality = FormatPNG, DefaultQuality
+			out, err := Transform(context.Background(), src, tt.opts, 80)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size.X != tt.width || size.Y != tt.height {
+				t.Errorf("got %dx%d, want %dx%d", size.X, size.Y, tt.width, tt.height)
+			}
+			if tt.opts.Fit != FitCover {
+				return
+			}
+			// ✂️ Cover keeps the centre, so both halves are still there
+			if r, _, b, _ := img.At(2, 25).RGBA(); r>>8 != 255 || b != 0 {
+				t.Errorf("left edge is not red")
+			}
+			if r, _, b, _ := img.At(47, 25).RGBA(); r != 0 || b>>8 != 255 {
+				t.Errorf("right edge is not blue")
+			}
+		})
+	}
+}
+
+func TestTransformRejectsNonImages(t *testing.T) {
+	_, err := Transform(context.Background(), []byte("%PDF-1.7"), Options{Format: FormatJPEG, Quality: DefaultQuality}, 100)
+	if !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("got %v, want ErrUnsupportedImage", err)
+	}
+}
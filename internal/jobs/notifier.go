@@ -0,0 +1,38 @@
+// Package jobs notifies clients waiting on background jobs through Redis pub/sub,
+// so a job finished by any worker wakes waiters on every API replica
+package jobs
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const channelPrefix = "jobs:finished:"
+
+// Notifier publishes and waits for job completion
+type Notifier struct {
+	client *redis.Client
+}
+
+// NewNotifier creates a notifier on the given Redis client
+func NewNotifier(client *redis.Client) *Notifier {
+	return &Notifier{client: client}
+}
+
+// NotifyJobFinished wakes everyone waiting on the job
+func (n *Notifier) NotifyJobFinished(ctx context.Context, jobID string) error {
+	return n.client.Publish(ctx, channelPrefix+jobID, "finished").Err()
+}
+
+// Subscribe starts listening for the job to finish. The subscription is confirmed before
+// it returns, so a job finishing after Subscribe is never missed. Close the returned
+// subscription when done.
+func (n *Notifier) Subscribe(ctx context.Context, jobID string) (*redis.PubSub, error) {
+	sub := n.client.Subscribe(ctx, channelPrefix+jobID)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
@@ -0,0 +1,126 @@
+// Package lockout counts attempts per email and IP in Redis and locks out repeat offenders
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "lockout:"
+
+// hitScript increments the counter and starts the window on the first attempt.
+// Running both in one script keeps concurrent attempts from racing between INCR and PEXPIRE.
+var hitScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// Limiter counts attempts per email and IP in fixed windows. The IP may be
+// empty to count per email only.
+type Limiter struct {
+	client      *redis.Client
+	scope       string
+	maxAttempts int
+	window      time.Duration
+}
+
+// NewLimiter creates a limiter that allows maxAttempts attempts within window.
+// scope separates the counters of different limiters, e.g. login and password_reset.
+// maxAttempts <= 0 disables it.
+func NewLimiter(client *redis.Client, scope string, maxAttempts int, window time.Duration) *Limiter {
+	return &Limiter{client: client, scope: scope, maxAttempts: maxAttempts, window: window}
+}
+
+// Enabled reports whether attempts are counted
+func (l *Limiter) Enabled() bool {
+	return l != nil && l.maxAttempts > 0
+}
+
+// Locked returns how long the email and IP pair is still locked out, 0 when it is not
+func (l *Limiter) Locked(ctx context.Context, email, ip string) (time.Duration, error) {
+	if !l.Enabled() {
+		return 0, nil
+	}
+
+	pipe := l.client.Pipeline()
+	count := pipe.Get(ctx, l.key(email, ip))
+	ttl := pipe.PTTL(ctx, l.key(email, ip))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+
+	failures, err := count.Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if failures < l.maxAttempts {
+		return 0, nil
+	}
+	return remaining(ttl.Val(), l.window), nil
+}
+
+// Fail records a failed attempt and returns the lockout when it reached the limit, 0 otherwise
+func (l *Limiter) Fail(ctx context.Context, email, ip string) (time.Duration, error) {
+	count, ttl, err := l.hit(ctx, email, ip)
+	if err != nil || !l.Enabled() || count < l.maxAttempts {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// Allow records an attempt and returns how long to wait when it exceeds the limit, 0 when
+// the attempt is allowed. Unlike Locked followed by Fail, concurrent callers cannot overshoot.
+func (l *Limiter) Allow(ctx context.Context, email, ip string) (time.Duration, error) {
+	count, ttl, err := l.hit(ctx, email, ip)
+	if err != nil || !l.Enabled() || count <= l.maxAttempts {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// hit increments the counter and returns it with the time left in the window
+func (l *Limiter) hit(ctx context.Context, email, ip string) (int, time.Duration, error) {
+	if !l.Enabled() {
+		return 0, 0, nil
+	}
+
+	result, err := hitScript.Run(ctx, l.client, []string{l.key(email, ip)}, l.window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(result[0]), remaining(time.Duration(result[1])*time.Millisecond, l.window), nil
+}
+
+// Reset clears the failures of the email and IP pair, e.g. after a successful login
+func (l *Limiter) Reset(ctx context.Context, email, ip string) error {
+	if !l.Enabled() {
+		return nil
+	}
+	return l.client.Del(ctx, l.key(email, ip)).Err()
+}
+
+// remaining falls back to the whole window when Redis reports no TTL
+func remaining(ttl, window time.Duration) time.Duration {
+	if ttl <= 0 {
+		return window
+	}
+	return ttl
+}
+
+// key hashes the pair so addresses are not stored in Redis in the clear
+func (l *Limiter) key(email, ip string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email)) + "|" + ip))
+	return keyPrefix + l.scope + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+)
+
+const (
+	maxAttempts = 5
+	window      = 15 * time.Minute
+)
+
+// concurrently runs fn from n goroutines released at the same time
+func concurrently(n int, fn func()) {
+	var ready, done sync.WaitGroup
+	start := make(chan struct{})
+	ready.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			ready.Done()
+			<-start
+			fn()
+		}()
+	}
+	ready.Wait()
+	close(start)
+	done.Wait()
+}
+
+func TestConcurrentFailuresAreAllCounted(t *testing.T) {
+	client, _ := testutil.NewRedis(t)
+	l := NewLimiter(client, "login", maxAttempts, window)
+	ctx := context.Background()
+
+	const attempts = 50
+	var locked, errs atomic.Int64
+	concurrently(attempts, func() {
+		lockout, err := l.Fail(ctx, "user@example.com", "203.0.113.7")
+		if err != nil {
+			errs.Add(1)
+		}
+		if lockout > 0 {
+			locked.Add(1)
+		}
+	})
+
+	if errs.Load() != 0 {
+		t.Fatalf("%d failures errored", errs.Load())
+	}
+	count, err := client.Get(ctx, l.key("user@example.com", "203.0.113.7")).Int()
+	if err != nil || count != attempts {
+		t.Errorf("counter is %d, %v, want %d", count, err, attempts)
+	}
+	if want := int64(attempts - maxAttempts + 1); locked.Load() != want {
+		t.Errorf("%d failures reported a lockout, want %d", locked.Load(), want)
+	}
+
+	ttl, err := client.PTTL(ctx, l.key("user@example.com", "203.0.113.7")).Result()
+	if err != nil || ttl <= 0 || ttl > window {
+		t.Errorf("counter expires in %v, %v, want within the window", ttl, err)
+	}
+	if retryAfter, err := l.Locked(ctx, "user@example.com", "203.0.113.7"); err != nil || retryAfter <= 0 {
+		t.Errorf("Locked = %v, %v, want a lockout", retryAfter, err)
+	}
+}
+
+func TestConcurrentAllowLetsExactlyMaxAttemptsThrough(t *testing.T) {
+	client, _ := testutil.NewRedis(t)
+	l := NewLimiter(client, "password_reset", maxAttempts, window)
+
+	var allowed atomic.Int64
+	concurrently(40, func() {
+		if wait, err := l.Allow(context.Background(), "user@example.com", ""); err == nil && wait == 0 {
+			allowed.Add(1)
+		}
+	})
+	if allowed.Load() != maxAttempts {
+		t.Errorf("%d attempts allowed, want %d", allowed.Load(), maxAttempts)
+	}
+}
+
+func TestLockoutEndsWithTheWindow(t *testing.T) {
+	client, server := testutil.NewRedis(t)
+	l := NewLimiter(client, "login", maxAttempts, window)
+	ctx := context.Background()
+
+	for i := 1; i <= maxAttempts; i++ {
+		lockout, err := l.Fail(ctx, "user@example.com", "203.0.113.7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (lockout > 0) != (i == maxAttempts) {
+			t.Fatalf("failure %d: lockout %v", i, lockout)
+		}
+	}
+
+	server.FastForward(window - time.Minute)
+	if retryAfter, _ := l.Locked(ctx, "user@example.com", "203.0.113.7"); retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("retry after %v, want the minute left in the window", retryAfter)
+	}
+
+	server.FastForward(time.Minute)
+	if retryAfter, err := l.Locked(ctx, "user@example.com", "203.0.113.7"); err != nil || retryAfter != 0 {
+		t.Errorf("still locked for %v, %v after the window", retryAfter, err)
+	}
+}
+
+func TestLockoutKeys(t *testing.T) {
+	client, _ := testutil.NewRedis(t)
+	login := NewLimiter(client, "login", maxAttempts, window)
+	reset := NewLimiter(client, "password_reset", maxAttempts, window)
+	ctx := context.Background()
+
+	for i := 0; i < maxAttempts; i++ {
+		if _, err := login.Fail(ctx, " User@Example.com", "203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		limiter *Limiter
+		email   string
+		ip      string
+		locked  bool
+	}{
+		{"same pair", login, "user@example.com", "203.0.113.7", true},
+		{"email case and spaces", login, "USER@example.com ", "203.0.113.7", true},
+		{"other IP", login, "user@example.com", "198.51.100.1", false},
+		{"other email", login, "other@example.com", "203.0.113.7", false},
+		{"other scope", reset, "user@example.com", "203.0.113.7", false},
+	}
+	for _, tt := range tests {
+		retryAfter, err := tt.limiter.Locked(ctx, tt.email, tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (retryAfter > 0) != tt.locked {
+			t.Errorf("%s: locked for %v, want locked %v", tt.name, retryAfter, tt.locked)
+		}
+	}
+
+	for _, key := range client.Keys(ctx, "*").Val() {
+		if len(key) != len(keyPrefix+"login:")+64 {
+			t.Errorf("key %q does not hash the email and IP", key)
+		}
+	}
+
+	if err := login.Reset(ctx, "user@example.com", "203.0.113.7"); err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter, _ := login.Locked(ctx, "user@example.com", "203.0.113.7"); retryAfter != 0 {
+		t.Errorf("locked for %v after a reset", retryAfter)
+	}
+}
+
+func TestDisabledLimiterCountsNothing(t *testing.T) {
+	ctx := context.Background()
+	for _, l := range []*Limiter{nil, NewLimiter(nil, "login", 0, window)} {
+		if l.Enabled() {
+			t.Error("limiter without attempts is enabled")
+		}
+		for i := 0; i < 10; i++ {
+			if lockout, err := l.Fail(ctx, "user@example.com", ""); lockout != 0 || err != nil {
+				t.Errorf("Fail = %v, %v", lockout, err)
+			}
+			if wait, err := l.Allow(ctx, "user@example.com", ""); wait != 0 || err != nil {
+				t.Errorf("Allow = %v, %v", wait, err)
+			}
+		}
+		if retryAfter, err := l.Locked(ctx, "user@example.com", ""); retryAfter != 0 || err != nil {
+			t.Errorf("Locked = %v, %v", retryAfter, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var log = logger.New("mailer")
+
+// ErrNoSender is returned when no email transport is registered
+var ErrNoSender = errors.New("no email sender registered")
+
+// ErrSuppressed is returned for messages to suppressed addresses, which are not sent
+var ErrSuppressed = errors.New("recipient is suppressed")
+
+// Message is a rendered email sent on behalf of a team
+type Message struct {
+	// MessageID identifies the message in its Message-ID header and email logs, the email
+	// tasks set it when they are enqueued
+	MessageID string `json:"messageId,omitempty"`
+	TeamID    string `json:"teamId"`
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	HTML      string `json:"html,omitempty"`
+}
+
+// Sender delivers rendered emails, e.g. over SMTP
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	sender   Sender
+	senderMu sync.RWMutex
+)
+
+// RegisterSender sets the email transport
+func RegisterSender(s Sender) {
+	senderMu.Lock()
+	defer senderMu.Unlock()
+	sender = s
+}
+
+// HasSender reports whether an email transport is registered
+func HasSender() bool {
+	senderMu.RLock()
+	defer senderMu.RUnlock()
+	return sender != nil
+}
+
+// SenderConfigID identifies the server and account of the registered sender, empty when the
+// sender has none
+func SenderConfigID() string {
+	senderMu.RLock()
+	defer senderMu.RUnlock()
+	if s, ok := sender.(interface{ ConfigID() string }); ok {
+		return s.ConfigID()
+	}
+	return ""
+}
+
+// Send delivers msg, or records it as a SandboxEmail when the team is in sandbox mode
+func Send(ctx context.Context, db *gorm.DB, msg Message) error {
+	_, err := Deliver(ctx, db, msg, nil)
+	return err
+}
+
+// Deliver is Send, reporting whether msg was recorded for a sandboxed team instead of sent.
+// ready runs right before msg is handed to the sender, its error aborts the delivery.
+func Deliver(ctx context.Context, db *gorm.DB, msg Message, ready func(ctx context.Context) error) (sandboxed bool, err error) {
+	team, err := models.GetTeamByID(msg.TeamID, db.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	if team.SandboxMode {
+		log.Info("Team %s is in sandbox mode, recording email to %s", team.ID, msg.To)
+		return true, db.WithContext(ctx).Create(&models.SandboxEmail{
+			TeamID:  team.ID,
+			To:      msg.To,
+			Subject: msg.Subject,
+			Text:    msg.Text,
+			HTML:    msg.HTML,
+		}).Error
+	}
+
+	// 🚫 Addresses refused for good are not tried again
+	var suppressed int64
+	if err := db.WithContext(ctx).Model(&models.EmailSuppression{}).
+		Where("email = ? AND is_deleted = false", strings.ToLower(msg.To)).Count(&suppressed).Error; err != nil {
+		return false, err
+	}
+	if suppressed > 0 {
+		return false, ErrSuppressed
+	}
+
+	senderMu.RLock()
+	s := sender
+	senderMu.RUnlock()
+	if s == nil {
+		return false, ErrNoSender
+	}
+	if ready != nil {
+		if err := ready(ctx); err != nil {
+			return false, err
+		}
+	}
+	return false, s.Send(ctx, msg)
+}
+
+// Suppress stops emails to address, the receiving server refused it for good with code
+func Suppress(ctx context.Context, db *gorm.DB, address string, code int, reason string) error {
+	return db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.EmailSuppression{
+		Email:    strings.ToLower(address),
+		SMTPCode: code,
+		Reason:   reason,
+	}).Error
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestSandboxedTeamsRecordEmailsInsteadOfSending(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	server := testutil.NewSMTPServer(t)
+	RegisterSender(newTestSender(server))
+	t.Cleanup(func() { RegisterSender(nil) })
+
+	live, sandboxed := testutil.CreateTeam(t, gdb, "Acme"), testutil.CreateTeam(t, gdb, "Sandbox")
+	if err := gdb.Model(sandboxed).Update("sandbox_mode", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, team := range []*models.Team{live, sandboxed} {
+		recorded, err := Deliver(context.Background(), gdb, Message{TeamID: team.ID, To: "jane@example.com", Subject: "Hi " + team.Name, Text: "Hi"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded != (team == sandboxed) {
+			t.Errorf("%s: recorded %v", team.Name, recorded)
+		}
+	}
+
+	// 🧪 Only the live team's email reached the server, the sandboxed one is kept for the team to read
+	if messages := server.Messages(); len(messages) != 1 {
+		t.Errorf("the SMTP server got %d messages, want 1", len(messages))
+	}
+	var emails []models.SandboxEmail
+	if err := gdb.Find(&emails).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].TeamID != sandboxed.ID || emails[0].Subject != "Hi Sandbox" || emails[0].To != "jane@example.com" {
+		t.Errorf("recorded %+v, want the sandboxed team's email", emails)
+	}
+}
@@ -0,0 +1,229 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole delivery when the context has no earlier deadline
+const smtpTimeout = 30 * time.Second
+
+// ErrInvalidMessage is returned for messages that can never be sent, e.g. to a malformed address
+var ErrInvalidMessage = errors.New("invalid email message")
+
+// SMTPConfig is an SMTP server and the account emails are sent with
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender delivers emails over SMTP, upgrading to TLS when the server offers STARTTLS
+type SMTPSender struct {
+	cfg SMTPConfig
+	// tls is the STARTTLS config, nil verifies the server against the system roots
+	tls *tls.Config
+}
+
+// NewSMTPSender creates a sender for cfg
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	return &SMTPSender{cfg: cfg}
+}
+
+// ConfigID identifies the server and account, the emails sent through one share its rate limit
+func (s *SMTPSender) ConfigID() string {
+	sum := sha256.Sum256([]byte(s.cfg.Username + "@" + net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RecipientError is the server refusing the recipient address, e.g. 550 no such user
+type RecipientError struct {
+	Address string
+	Err     error
+}
+
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("recipient %s refused: %v", e.Address, e.Err)
+}
+
+func (e *RecipientError) Unwrap() error { return e.Err }
+
+// SMTPCode returns the reply code of the SMTP refusal in err, zero when there is none, e.g.
+// when the server could not be reached
+func SMTPCode(err error) int {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code
+	}
+	return 0
+}
+
+// Permanent reports whether sending again cannot succeed: 5xx replies and invalid messages.
+// Other errors, such as 4xx replies and network errors, may pass later.
+func Permanent(err error) bool {
+	code := SMTPCode(err)
+	return code >= 500 && code < 600 || errors.Is(err, ErrInvalidMessage)
+}
+
+// BadAddress reports whether err refused the recipient for good, such addresses are suppressed
+func BadAddress(err error) bool {
+	var refused *RecipientError
+	return errors.As(err, &refused) && Permanent(err)
+}
+
+// Send delivers msg, each call opens its own connection
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: recipient %q: %v", ErrInvalidMessage, msg.To, err)
+	}
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("%w: sender %q: %v", ErrInvalidMessage, s.cfg.From, err)
+	}
+	body, err := buildMessage(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP greeting failed: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := s.tls
+		if config == nil {
+			config = &tls.Config{ServerName: s.cfg.Host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM refused: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return &RecipientError{Address: to.Address, Err: err}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA refused: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	// Closing the data writer reads the server's verdict on the message
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP message refused: %w", err)
+	}
+	// 📬 The message is accepted, a failed QUIT does not undo that
+	client.Quit()
+	return nil
+}
+
+// buildMessage renders msg with its headers, as multipart/alternative when it has an HTML body
+func buildMessage(from, to *mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+
+	var b bytes.Buffer
+	header := func(key, value string) { fmt.Fprintf(&b, "%s: %s\r\n", key, value) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().UTC().Format(time.RFC1123Z))
+	header("Message-ID", "<"+messageID(msg, from)+">")
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		return b.Bytes(), writeQuotedPrintable(&b, msg.Text)
+	}
+
+	parts := multipart.NewWriter(&b)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID is the Message-ID of msg at the sender's domain, random when msg has none
+func messageID(msg Message, from *mail.Address) string {
+	id := msg.MessageID
+	if id == "" {
+		random := make([]byte, 12)
+		rand.Read(random)
+		id = hex.EncodeToString(random)
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	return id + "@" + domain
+}
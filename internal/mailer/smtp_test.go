@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"be0/internal/testutil"
+)
+
+func newTestSender(server *testutil.SMTPServer) *SMTPSender {
+	return NewSMTPSender(SMTPConfig{Host: server.Host, Port: server.Port, From: "be0 <noreply@be0.test>"})
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	server := testutil.NewSMTPServer(t)
+	msg := Message{MessageID: "abc123", To: "jane@example.com", Subject: "Welcome", Text: "Hi Jane", HTML: "<p>Hi Jane</p>"}
+
+	if err := newTestSender(server).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(messages))
+	}
+	for _, want := range []string{"Message-ID: <abc123@be0.test>", "To: <jane@example.com>", "Subject: Welcome", "multipart/alternative", "<p>Hi Jane</p>"} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("message is missing %q:\n%s", want, messages[0])
+		}
+	}
+}
+
+func TestSMTPSenderClassifiesReplies(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		reply      string
+		code       int
+		permanent  bool
+		badAddress bool
+	}{
+		{"unknown recipient", "RCPT", "550 5.1.1 No such user", 550, true, true},
+		{"mailbox busy", "RCPT", "450 4.2.1 Mailbox busy", 450, false, false},
+		{"sender refused", "MAIL", "553 5.7.1 Sender not allowed", 553, true, false},
+		{"greylisted message", "DATA", "451 4.7.1 Try again later", 451, false, false},
+		{"rejected message", "DATA", "554 5.7.1 Spam", 554, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := testutil.NewSMTPServer(t)
+			server.Script(tt.command, tt.reply)
+
+			err := newTestSender(server).Send(context.Background(), Message{To: "jane@example.com", Subject: "Hi", Text: "Hi"})
+			if err == nil {
+				t.Fatal("Send succeeded")
+			}
+			if SMTPCode(err) != tt.code || Permanent(err) != tt.permanent || BadAddress(err) != tt.badAddress {
+				t.Errorf("got code %d, permanent %v and bad address %v for %v", SMTPCode(err), Permanent(err), BadAddress(err), err)
+			}
+		})
+	}
+}
+
+func TestSMTPSenderRejectsInvalidMessages(t *testing.T) {
+	server := testutil.NewSMTPServer(t)
+	for _, msg := range []Message{
+		{To: "not an address", Subject: "Hi"},
+		{To: "jane@example.com", Subject: "Hi\r\nBcc: everyone@example.com"},
+	} {
+		err := newTestSender(server).Send(context.Background(), msg)
+		if !errors.Is(err, ErrInvalidMessage) || !Permanent(err) {
+			t.Errorf("got %v for %+v, want a permanent ErrInvalidMessage", err, msg)
+		}
+	}
+	if len(server.Messages()) != 0 {
+		t.Error("invalid messages were sent")
+	}
+}
+
+func TestSMTPSenderUnreachableIsTransient(t *testing.T) {
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: 1, From: "noreply@be0.test"})
+	err := sender.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hi"})
+	if err == nil || Permanent(err) || SMTPCode(err) != 0 {
+		t.Errorf("got %v, want a transient error without a code", err)
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	admissionWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "be0_admission_wait_seconds",
+		Help:    "Time requests waited for an admission slot by route class.",
+		Buckets: []float64{0, .005, .01, .025, .05, .1, .2, .3, .5, 1},
+	}, []string{"class"})
+
+	admissionShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "be0_admission_shed_total",
+		Help: "Requests rejected by admission control by route class and reason.",
+	}, []string{"class", "reason"})
+
+	admissionQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "be0_admission_queued",
+		Help: "Requests currently waiting for an admission slot.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(admissionWait, admissionShed, admissionQueued)
+}
+
+// ObserveAdmissionWait records how long a request waited for a slot, admitted or not
+func ObserveAdmissionWait(class string, wait time.Duration) {
+	admissionWait.WithLabelValues(class).Observe(wait.Seconds())
+}
+
+// ObserveAdmissionShed counts a request rejected with 429
+func ObserveAdmissionShed(class, reason string) {
+	admissionShed.WithLabelValues(class, reason).Inc()
+}
+
+// SetAdmissionQueued reports the number of waiting requests
+func SetAdmissionQueued(queued int) {
+	admissionQueued.Set(float64(queued))
+}
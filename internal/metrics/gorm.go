@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var dbQueryDuration = NewHistogram("be0_db_query_duration_seconds",
+	"Database query latency by operation and table.",
+	[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	"operation", "table")
+
+// queryStartKey holds the start of a query on its statement
+const queryStartKey = "be0:metrics_start"
+
+// GormPlugin times every query run through GORM, install it with db.Use
+func GormPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string { return "be0:metrics" }
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("be0:metrics_before_create", startQuery),
+		callbacks.Create().After("gorm:create").Register("be0:metrics_after_create", observeQuery("create")),
+		callbacks.Query().Before("gorm:query").Register("be0:metrics_before_query", startQuery),
+		callbacks.Query().After("gorm:query").Register("be0:metrics_after_query", observeQuery("query")),
+		callbacks.Update().Before("gorm:update").Register("be0:metrics_before_update", startQuery),
+		callbacks.Update().After("gorm:update").Register("be0:metrics_after_update", observeQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("be0:metrics_before_delete", startQuery),
+		callbacks.Delete().After("gorm:delete").Register("be0:metrics_after_delete", observeQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("be0:metrics_before_row", startQuery),
+		callbacks.Row().After("gorm:row").Register("be0:metrics_after_row", observeQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("be0:metrics_before_raw", startQuery),
+		callbacks.Raw().After("gorm:raw").Register("be0:metrics_after_raw", observeQuery("raw")),
+	)
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "raw"
+		}
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	httpRequests = NewCounter("be0_http_requests_total",
+		"HTTP requests by method, route and status code.",
+		"method", "route", "status")
+
+	httpDuration = NewHistogram("be0_http_request_duration_seconds",
+		"HTTP request latency by method and route.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		"method", "route")
+)
+
+// unmatchedRoute labels requests no route matched, so unknown paths cannot grow the label set
+const unmatchedRoute = "unmatched"
+
+// HTTPMiddleware counts and times requests. Routes are labeled by their pattern, e.g.
+// /api/v1/files/:id, so the number of series does not grow with the IDs requested.
+func HTTPMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			route := c.Path()
+			if route == "" || route == "/*" {
+				route = unmatchedRoute
+			}
+			method := methodLabel(c.Request().Method)
+			httpRequests.WithLabelValues(method, route, strconv.Itoa(responseStatus(c, err))).Inc()
+			httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// responseStatus is the status the request is answered with, errors are written by the
+// error handler after the middleware returns
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// RequireToken only lets through requests carrying token as a bearer token
+func RequireToken(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			presented, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid metrics token"})
+			}
+			return next(c)
+		}
+	}
+}
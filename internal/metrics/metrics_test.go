@@ -0,0 +1,164 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/events"
+	"be0/internal/metrics"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// series returns the label sets of a metric family, failing when the family is not exported
+func series(t *testing.T, name string) []map[string]string {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		var sets []map[string]string
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			sets = append(sets, labels)
+		}
+		return sets
+	}
+	t.Fatalf("%s is not exported", name)
+	return nil
+}
+
+func TestHTTPMiddlewareLabelsRoutesByPattern(t *testing.T) {
+	e := echo.New()
+	e.Use(metrics.HTTPMiddleware())
+	e.GET("/metrics-test/files/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	// 🔢 Every ID and unknown path falls into the same few series
+	for _, path := range []string{"/metrics-test/files/1", "/metrics-test/files/2", "/metrics-test/files/3", "/metrics-test/nope/1", "/metrics-test/nope/2"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	found := map[string]string{}
+	for _, labels := range series(t, "be0_http_requests_total") {
+		if len(labels) != 3 || labels["method"] == "" {
+			t.Errorf("labels %v, want method, route and status", labels)
+		}
+		found[labels["route"]] = labels["status"]
+	}
+	if found["/metrics-test/files/:id"] != "200" || found["unmatched"] != "404" {
+		t.Errorf("series by route %v, want the route pattern with 200 and unmatched with 404", found)
+	}
+	for route := range found {
+		if route == "/metrics-test/files/1" || route == "/metrics-test/nope/1" {
+			t.Errorf("route %s is labeled with the raw path", route)
+		}
+	}
+	for _, labels := range series(t, "be0_http_request_duration_seconds") {
+		if len(labels) != 2 {
+			t.Errorf("duration labels %v, want method and route", labels)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	e := echo.New()
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()), metrics.RequireToken("scrape-me"))
+
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "scrape-me": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("token %q: status %d, want %d", token, rec.Code, want)
+		}
+	}
+}
+
+func TestGormPluginTimesQueriesByTable(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	if err := gdb.Use(metrics.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	testutil.CreateTeam(t, gdb, "Acme")
+	var teams []models.Team
+	if err := gdb.Find(&teams).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, labels := range series(t, "be0_db_query_duration_seconds") {
+		if len(labels) != 2 {
+			t.Errorf("labels %v, want operation and table", labels)
+		}
+		seen[labels["operation"]+" "+labels["table"]] = true
+	}
+	if !seen["create teams"] || !seen["query teams"] {
+		t.Errorf("series %v, want the create and query of teams", seen)
+	}
+}
+
+func TestEventsAreCounted(t *testing.T) {
+	bus := events.NewEventBus()
+	bus.Emit("metrics_test.emitted", nil)
+	bus.Emit("metrics_test.emitted", nil)
+
+	for _, labels := range series(t, "be0_events_emitted_total") {
+		if len(labels) != 1 {
+			t.Errorf("labels %v, want the event only", labels)
+		}
+		if labels["event"] == "metrics_test.emitted" {
+			return
+		}
+	}
+	t.Error("emitted event is not counted")
+}
+
+func TestTaskQueueSizes(t *testing.T) {
+	metrics.SetTaskQueueSize("default", "pending", 3)
+	for _, labels := range series(t, "be0_task_queue_size") {
+		if labels["queue"] == "default" && labels["state"] == "pending" && len(labels) == 2 {
+			return
+		}
+	}
+	t.Error("queue size is not exported")
+}
+
+func BenchmarkObserveServiceOperation(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		metrics.ObserveServiceOperation("benchmark_rows", "list", time.Now(), 25, nil)
+	}
+}
+
+func TestServiceInstrumentationOverhead(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("times the instrumentation, run without -short and -race")
+	}
+	// ⏱️ Every BaseService call pays for one observation, keep it within a few microseconds
+	result := testing.Benchmark(BenchmarkObserveServiceOperation)
+	if perCall := time.Duration(result.NsPerOp()); perCall > 5*time.Microsecond {
+		t.Errorf("instrumentation costs %s per call, want under 5µs", perCall)
+	}
+
+	seen := map[string]bool{}
+	for _, labels := range series(t, "be0_service_operations_total") {
+		seen[labels["model"]+" "+labels["operation"]+" "+labels["error"]] = true
+	}
+	if !seen["benchmark_rows list none"] {
+		t.Errorf("series %v, want the benchmarked list", seen)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package metrics_test
+
+// raceEnabled is set under -race, which makes every observation several times slower
+const raceEnabled = true
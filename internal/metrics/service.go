@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var (
+	serviceOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "be0_service_operations_total",
+		Help: "BaseService operations by model, operation and error class.",
+	}, []string{"model", "operation", "error"})
+
+	serviceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "be0_service_operation_duration_seconds",
+		Help:    "BaseService operation latency by model and operation.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"model", "operation"})
+
+	serviceRows = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "be0_service_rows_returned",
+		Help:    "Rows returned by BaseService reads by model and operation.",
+		Buckets: []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	}, []string{"model", "operation"})
+)
+
+func init() {
+	Registry.MustRegister(serviceOperations, serviceDuration, serviceRows)
+}
+
+// ObserveServiceOperation records one BaseService call.
+// rows is ignored for writes, pass a negative value.
+func ObserveServiceOperation(model, operation string, start time.Time, rows int, err error) {
+	serviceOperations.WithLabelValues(model, operation, ErrorClass(err)).Inc()
+	serviceDuration.WithLabelValues(model, operation).Observe(time.Since(start).Seconds())
+	if rows >= 0 {
+		serviceRows.WithLabelValues(model, operation).Observe(float64(rows))
+	}
+}
+
+// ErrorClass maps an error to a low-cardinality label value
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "not_found"
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return "duplicate"
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return "foreign_key"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "other"
+	}
+}
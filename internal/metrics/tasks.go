@@ -0,0 +1,21 @@
+package metrics
+
+var (
+	taskQueueSize = NewGauge("be0_task_queue_size",
+		"Tasks in each queue by state.",
+		"queue", "state")
+
+	eventsEmitted = NewCounter("be0_events_emitted_total",
+		"Events emitted on the event bus by name.",
+		"event")
+)
+
+// SetTaskQueueSize reports the tasks of a queue in a state, e.g. pending or retry
+func SetTaskQueueSize(queue, state string, size int) {
+	taskQueueSize.WithLabelValues(queue, state).Set(float64(size))
+}
+
+// CountEvent counts an event emitted on the event bus
+func CountEvent(event string) {
+	eventsEmitted.WithLabelValues(event).Inc()
+}
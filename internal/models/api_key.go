@@ -0,0 +1,46 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// API key permissions. Keys are limited by HTTP method rather than by resource.
+const (
+	APIKeyPermissionRead   = "READ"   // GET
+	APIKeyPermissionWrite  = "WRITE"  // POST, PUT and PATCH
+	APIKeyPermissionDelete = "DELETE" // DELETE
+	APIKeyPermissionAdmin  = "ADMIN"  // every method
+)
+
+// APIKeyPrefix starts every raw key, so leaked keys are easy to recognise
+const APIKeyPrefix = "be0_"
+
+// APIKey authenticates requests of a team through the X-API-KEY header.
+// Only the key's hash is stored, the raw key is shown once when it is created.
+type APIKey struct {
+	Base
+	TeamID      string                      `gorm:"type:uuid;not null;index" json:"teamId"`
+	Team        *Team                       `json:"team,omitempty"`
+	CreatedByID string                      `gorm:"type:uuid" json:"createdById"`
+	Name        string                      `gorm:"not null" json:"name"`
+	Prefix      string                      `gorm:"not null" json:"prefix"` // first characters of the raw key, to tell keys apart
+	KeyHash     string                      `gorm:"not null;uniqueIndex" json:"-"`
+	Permissions datatypes.JSONSlice[string] `gorm:"type:jsonb;not null" json:"permissions"`
+	ExpiresAt   *time.Time                  `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time                  `json:"lastUsedAt,omitempty"`
+}
+
+// HashAPIKey hashes a raw API key for storage and lookup, keys are random so a fast hash suffices
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired reports whether the key is past its expiry
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now())
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// APIRequestLog records a request made with an API key for its analytics, see
+// middleware.APIAnalytics. Request and response bodies are never stored.
+type APIRequestLog struct {
+	Base
+	APIKeyID string `gorm:"type:uuid;not null;index:idx_api_request_logs_key_time,priority:1" json:"apiKeyId"`
+	TeamID   string `gorm:"type:uuid;not null;index" json:"teamId"`
+	Method   string `gorm:"not null" json:"method"`
+	// Route is the route pattern, e.g. /api/v1/files/:id, never the raw path
+	Route      string `gorm:"not null" json:"route"`
+	StatusCode int    `gorm:"not null" json:"statusCode"`
+	// LatencyBucket is one of APIRequestLatencyBuckets
+	LatencyBucket string    `gorm:"not null" json:"latencyBucket"`
+	RequestedAt   time.Time `gorm:"not null;index:idx_api_request_logs_key_time,priority:2;index" json:"requestedAt"`
+	// Day is the UTC date of RequestedAt, e.g. 2026-01-31, which analytics group by
+	Day string `gorm:"size:10;not null" json:"day"`
+}
+
+// APIRequestLatencyBuckets are the latency buckets of API request logs, fastest first
+var APIRequestLatencyBuckets = []struct {
+	Name string
+	Max  time.Duration // exclusive, zero for the last bucket
+}{
+	{"<50ms", 50 * time.Millisecond},
+	{"<100ms", 100 * time.Millisecond},
+	{"<250ms", 250 * time.Millisecond},
+	{"<500ms", 500 * time.Millisecond},
+	{"<1s", time.Second},
+	{"<2.5s", 2500 * time.Millisecond},
+	{"<5s", 5 * time.Second},
+	{">=5s", 0},
+}
+
+// APIRequestLatencyBucket names the bucket of a latency
+func APIRequestLatencyBucket(latency time.Duration) string {
+	for _, bucket := range APIRequestLatencyBuckets {
+		if bucket.Max == 0 || latency < bucket.Max {
+			return bucket.Name
+		}
+	}
+	return APIRequestLatencyBuckets[len(APIRequestLatencyBuckets)-1].Name
+}
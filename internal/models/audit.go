@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// AuditLoginFailed is recorded for failed password sign-ins on known accounts
+	AuditLoginFailed = "auth.login_failed"
+	// AuditSessionsRevoked is recorded when an admin signs a team member out of all sessions
+	AuditSessionsRevoked = "auth.sessions_revoked"
+)
+
+// AuditEntry records a sensitive action and who performed it
+type AuditEntry struct {
+	Base
+	ActorID    string         `gorm:"type:uuid;index" json:"actorId"`
+	TeamID     string         `gorm:"type:uuid;index" json:"teamId"`
+	Action     string         `gorm:"not null;index" json:"action"`
+	TargetType string         `gorm:"not null" json:"targetType"`
+	TargetID   string         `gorm:"type:uuid;index" json:"targetId"`
+	IPAddress  string         `json:"ipAddress"`
+	Metadata   datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"`
+}
+
+// RecordAudit writes an audit entry. metadata may be nil.
+func RecordAudit(db *gorm.DB, entry AuditEntry, metadata map[string]interface{}) error {
+	if metadata != nil {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		entry.Metadata = datatypes.JSON(encoded)
+	}
+	return db.Create(&entry).Error
+}
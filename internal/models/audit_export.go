@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AuditExportTarget is the kind of SIEM endpoint audit logs are pushed to
+type AuditExportTarget string
+
+const (
+	// AuditExportTargetS3 drops NDJSON objects under a prefix of the storage bucket
+	AuditExportTargetS3 AuditExportTarget = "s3"
+	// AuditExportTargetHTTPS POSTs NDJSON signed with the export's secret
+	AuditExportTargetHTTPS AuditExportTarget = "https"
+	// AuditExportTargetSyslog sends RFC 5424 messages over TLS, framed as in RFC 5425
+	AuditExportTargetSyslog AuditExportTarget = "syslog"
+)
+
+// AuditExport pushes the audit logs of a team, or of every team, to a SIEM. The checkpoint
+// is the last log delivered, exports resume after it.
+type AuditExport struct {
+	Base
+	// TeamID limits the export to one team, nil exports every team of the deployment
+	TeamID *string           `gorm:"type:uuid;index" json:"teamId,omitempty"`
+	Target AuditExportTarget `gorm:"not null" json:"target"`
+	// Destination is the key prefix for s3, the URL for https and host:port for syslog
+	Destination string `gorm:"not null" json:"destination"`
+	// Secret signs https deliveries, it is only shown when the export is created
+	Secret string `json:"-"`
+	Active bool   `gorm:"not null;default:true" json:"active"`
+
+	LastLogID        string     `json:"lastLogId,omitempty"`
+	LastLogCreatedAt *time.Time `json:"lastLogCreatedAt,omitempty"`
+	LastRunAt        *time.Time `json:"lastRunAt,omitempty"`
+	// LastError is the error of the last failed run, cleared by the next successful one
+	LastError string `json:"lastError,omitempty"`
+	// AlertedAt is when super admins were told the export is failing, cleared once it succeeds
+	AlertedAt *time.Time `json:"alertedAt,omitempty"`
+}
+
+// ValidateDestination checks the destination fits the target
+func (e *AuditExport) ValidateDestination() error {
+	switch e.Target {
+	case AuditExportTargetS3:
+		if strings.Trim(e.Destination, "/") == "" {
+			return fmt.Errorf("s3 exports need a key prefix")
+		}
+	case AuditExportTargetHTTPS:
+		u, err := url.Parse(e.Destination)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("https exports need an https:// URL")
+		}
+	case AuditExportTargetSyslog:
+		if _, port, err := net.SplitHostPort(e.Destination); err != nil || port == "" {
+			return fmt.Errorf("syslog exports need a host:port")
+		}
+	default:
+		return fmt.Errorf("target must be s3, https or syslog")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"gorm.io/datatypes"
+)
+
+// AuditLog records a mutating API request and who made it, see middleware.AuditLog
+type AuditLog struct {
+	Base
+	// UserID is the signed-in user, nil for API keys
+	UserID *string `gorm:"type:uuid;index" json:"userId,omitempty"`
+	// APIKeyID is the API key the request was made with, nil for users
+	APIKeyID   *string `gorm:"type:uuid" json:"apiKeyId,omitempty"`
+	TeamID     string  `gorm:"type:uuid;not null;index" json:"teamId"`
+	Method     string  `gorm:"not null" json:"method"`
+	Path       string  `gorm:"not null" json:"path"`
+	Resource   string  `gorm:"not null;index" json:"resource"`
+	ResourceID string  `json:"resourceId,omitempty"`
+	StatusCode int     `gorm:"not null" json:"statusCode"`
+	// BodyHash is the SHA-256 of the request body, empty when there was none
+	BodyHash string `json:"bodyHash,omitempty"`
+	// Body is the JSON request body with passwords, tokens and secrets redacted, other and
+	// larger bodies are only hashed
+	Body      datatypes.JSON `gorm:"type:jsonb" json:"body,omitempty"`
+	IPAddress string         `json:"ipAddress"`
+	UserAgent string         `json:"userAgent"`
+	LatencyMs int64          `json:"latencyMs"`
+}
@@ -1,14 +1,23 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// DefaultProfilePictureID is the shared avatar assigned to users without their own picture
+const DefaultProfilePictureID = "5574fee5-3ce4-49e5-af2e-21361fc433e4"
+
 type User struct {
 	Base
-	Email            string           `gorm:"uniqueIndex;not null" json:"email"`
+	Email            string           `gorm:"uniqueIndex;not null" json:"email" redact:"scope=users:read_pii,mask=email"`
+	EmailVerified    bool             `gorm:"not null;default:false" json:"emailVerified"`
+	EmailVerifiedAt  *time.Time       `json:"emailVerifiedAt,omitempty"`
 	Password         string           `gorm:"not null" json:"-"`
 	FirstName        string           `json:"firstName"`
 	LastName         string           `json:"lastName"`
@@ -20,29 +29,103 @@ type User struct {
 	Files            []File           `gorm:"foreignKey:UserID" json:"files,omitempty"`
 	ProfilePicture   File             `gorm:"foreignKey:ProfilePictureID" json:"profilePicture,omitempty"`
 	ProfilePictureID string           `gorm:"type:uuid;default:NULL" json:"profilePictureId,omitempty"`
-	Provider         string           `gorm:"default:'local'" json:"provider"`          // 'local', 'google', etc.
-	ProviderID       string           `gorm:"index" json:"providerId,omitempty"`        // ID from the OAuth provider
-	ProviderData     datatypes.JSON   `gorm:"type:jsonb" json:"providerData,omitempty"` // Additional data from provider
+	Provider         string           `gorm:"default:'local'" json:"provider" redact:"scope=users:read_pii"`   // 'local', 'google', etc.
+	ProviderID       string           `gorm:"index" json:"providerId,omitempty" redact:"scope=users:read_pii"` // ID from the OAuth provider
+	ProviderData     datatypes.JSON   `gorm:"type:jsonb" json:"-"`                                             // Additional data from provider, never serialized
+	// Two-factor authentication, secrets are encrypted with crypto.Encrypt
+	TwoFactorEnabled       bool       `gorm:"not null;default:false" json:"twoFactorEnabled"`
+	TwoFactorSecret        string     `json:"-"`
+	TwoFactorPendingSecret string     `json:"-"` // set up but not confirmed with a code yet
+	TwoFactorLastStep      int64      `json:"-"` // time step of the last accepted code, codes cannot be reused
+	TwoFactorFailures      int        `gorm:"not null;default:0" json:"-"`
+	TwoFactorLockedUntil   *time.Time `json:"-"`
+	// IsActive is cleared when a super admin deactivates the account, it can no longer sign in
+	IsActive bool `gorm:"not null;default:true" json:"isActive"`
+}
+
+// SearchScope matches users by email, first or last name
+func (User) SearchScope(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		pattern := "%" + escapeLike(q) + "%"
+		return db.Where("users.email ILIKE ? OR users.first_name ILIKE ? OR users.last_name ILIKE ?", pattern, pattern, pattern)
+	}
 }
 
 type PasswordReset struct {
 	Base
-	User      *User     `json:"user,omitempty"`
-	UserID    string    `gorm:"type:uuid;not null" json:"userId"`
-	Code      string    `gorm:"not null" json:"code"`
-	Used      bool      `gorm:"default:false" json:"used"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	User   *User  `json:"user,omitempty"`
+	UserID string `gorm:"type:uuid;not null;index" json:"userId"`
+	// Code is the plaintext for the mailer, only CodeHash is stored
+	Code     string `gorm:"-" json:"-"`
+	CodeHash string `gorm:"index" json:"-"`
+	Used     bool   `gorm:"default:false" json:"used"`
+	// Superseded is set when a newer reset was requested, only the latest code works
+	Superseded bool      `gorm:"not null;default:false" json:"superseded"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
 }
 
+// PasswordResetLifetime is how long a reset code can be used
+const PasswordResetLifetime = 15 * time.Minute
+
+// ForcedPasswordResetLifetime is how long the code of a reset forced by an admin can be used,
+// the user has no password until they use it
+const ForcedPasswordResetLifetime = 72 * time.Hour
+
+// PasswordResetCodeLength is the length of password reset codes.
+// 12 alphanumeric characters give ~71 bits of entropy.
+const PasswordResetCodeLength = 12
+
+// IssuePasswordReset stores a reset code for its user, superseding the user's earlier codes.
+// Run it in a transaction, concurrent resets of the same user are serialized on the user row.
+func IssuePasswordReset(tx *gorm.DB, reset *PasswordReset) error {
+	// 🔒 Concurrent requests for the same user are serialized, so only one code stays live
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&User{}, "id = ?", reset.UserID).Error; err != nil {
+		return err
+	}
+
+	// 🔁 Only the newest code works
+	if err := tx.Model(&PasswordReset{}).
+		Where("user_id = ? AND used = ? AND superseded = ? AND is_deleted = false", reset.UserID, false, false).
+		Update("superseded", true).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(reset).Error
+}
+
+// PasswordResetCodeDrop removes the plaintext codes stored before codes were hashed.
+// Their rows have no hash, so codes issued before the upgrade stop working.
+const PasswordResetCodeDrop = `ALTER TABLE password_resets DROP COLUMN IF EXISTS code`
+
+// HashResetCode hashes a reset code for storage and lookup, codes are random so a fast hash suffices
+func HashResetCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionLifetime is how long a session lasts, matching the refresh token
+const SessionLifetime = 7 * 24 * time.Hour
+
+// SessionRetention is how long expired sessions are kept for device history before they are pruned
+const SessionRetention = 30 * 24 * time.Hour
+
 type AuthTransaction struct {
 	Base
-	UserID    string    `gorm:"type:uuid;not null" json:"userId"`
-	User      *User     `json:"user,omitempty"`
-	TeamID    string    `gorm:"type:uuid;not null" json:"teamId"`
-	Team      *Team     `json:"team,omitempty"`
-	Token     string    `gorm:"not null" json:"token"`
-	Refresh   string    `gorm:"not null" json:"refresh"`
-	IPAddress string    `json:"ipAddress"`
-	UserAgent string    `json:"userAgent"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	UserID string `gorm:"type:uuid;not null;index:idx_auth_transactions_user_expires,priority:1" json:"userId"`
+	User   *User  `json:"user,omitempty"`
+	TeamID string `gorm:"type:uuid;not null" json:"teamId"`
+	Team   *Team  `json:"team,omitempty"`
+	// TokenID is the jti of the session's current access token
+	TokenID   string `gorm:"not null;default:'';index" json:"-"`
+	Refresh   string `gorm:"not null" json:"-"` // SHA-256 of the current refresh token, rotated on every refresh
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+	// DeviceID is the client's X-Device-Id, or a hash of the user agent and IP address without one
+	DeviceID  string    `gorm:"not null;default:'';index" json:"deviceId"`
+	ExpiresAt time.Time `gorm:"index:idx_auth_transactions_user_expires,priority:2" json:"expiresAt"`
+	// ImpersonatedBy is the super admin acting as the user, nil for the user's own sessions
+	ImpersonatedBy *string `gorm:"type:uuid;index" json:"impersonatedBy,omitempty"`
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// AuthMethod is a way of signing in
+type AuthMethod string
+
+const (
+	AuthMethodPassword  AuthMethod = "password"
+	AuthMethodGoogle    AuthMethod = "google"
+	AuthMethodMicrosoft AuthMethod = "microsoft"
+	AuthMethodSAML      AuthMethod = "saml"
+	AuthMethodMagicLink AuthMethod = "magic-link"
+)
+
+// ErrConflict marks changes rejected because of the current state of other records
+var ErrConflict = errors.New("conflict")
+
+// ErrAuthPolicyLocksOutAdmins is returned when a policy change would leave no admin able to sign in
+var ErrAuthPolicyLocksOutAdmins = fmt.Errorf("%w: the auth policy would lock out every team admin", ErrConflict)
+
+// AuthPolicy restricts how members of a team can sign in
+type AuthPolicy struct {
+	// AllowedMethods lists the sign-in methods members may use, empty allows all
+	AllowedMethods datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"allowedMethods,omitempty" validate:"omitempty,dive,oneof=password google microsoft saml magic-link"`
+	// RequireTwoFactor makes members enter a TOTP or recovery code before they get a session,
+	// members without 2FA set it up when they next sign in
+	RequireTwoFactor bool `gorm:"not null;default:false" json:"requireTwoFactor"`
+	// SessionMaxAge limits how long a session is valid in minutes, 0 means no limit
+	SessionMaxAge int `gorm:"not null;default:0" json:"sessionMaxAge" validate:"omitempty,min=0"`
+}
+
+// Allows reports whether members may sign in with method
+func (p AuthPolicy) Allows(method AuthMethod) bool {
+	return len(p.AllowedMethods) == 0 || slices.Contains(p.AllowedMethods, string(method))
+}
+
+// TeamRequiresTwoFactor reports whether the policy of a team requires 2FA
+func TeamRequiresTwoFactor(db *gorm.DB, teamID string) (bool, error) {
+	var required []bool
+	if err := db.Model(&Team{}).Where("id = ?", teamID).Pluck("auth_require_two_factor", &required).Error; err != nil {
+		return false, err
+	}
+	return len(required) > 0 && required[0], nil
+}
+
+// userAuthMethods lists the methods a user has credentials for
+func userAuthMethods(user *User) []AuthMethod {
+	var methods []AuthMethod
+	if user.Password != "" {
+		methods = append(methods, AuthMethodPassword)
+	}
+	if user.Provider == string(AuthMethodGoogle) || user.Provider == string(AuthMethodMicrosoft) {
+		methods = append(methods, AuthMethod(user.Provider))
+	}
+	return methods
+}
+
+// BeforeUpdate moves the version on and rejects auth policies that no team admin could sign
+// in with
+func (t *Team) BeforeUpdate(tx *gorm.DB) error {
+	if err := t.Base.BeforeUpdate(tx); err != nil {
+		return err
+	}
+	if len(t.AuthPolicy.AllowedMethods) == 0 || t.ID == "" {
+		return nil
+	}
+
+	var admins []User
+	if err := tx.Session(&gorm.Session{NewDB: true}).
+		Where("team_id = ? AND role IN ? AND is_deleted = false", t.ID, []UserRole{UserRoleAdmin, UserRoleSuperAdmin}).
+		Find(&admins).Error; err != nil {
+		return err
+	}
+
+	for i := range admins {
+		for _, method := range userAuthMethods(&admins[i]) {
+			if t.AuthPolicy.Allows(method) {
+				return nil
+			}
+		}
+	}
+	return ErrAuthPolicyLocksOutAdmins
+}
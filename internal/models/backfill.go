@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultBackfillBatchSize is how many rows a backfill changes per transaction when its
+// definition sets no batch size
+const DefaultBackfillBatchSize = 500
+
+// Backfill fills a new column of a table too large for a single UPDATE. It walks the table in
+// primary key order, one batch per transaction, so no lock is held for long and an
+// interrupted run resumes after the last batch it committed.
+type Backfill struct {
+	// Name identifies the backfill, its job and checkpoint
+	Name string
+	// Model is the model of the table walked
+	Model interface{}
+	// Where selects the rows still to change, e.g. "extension IS NULL", so rows written by
+	// a dual write are skipped
+	Where string
+	// BatchSize is the number of rows per transaction, DefaultBackfillBatchSize when zero
+	BatchSize int
+	// Throttle is the pause between batches, leaving the database room for live traffic
+	Throttle time.Duration
+	// Transform changes the rows with ids inside the batch's transaction
+	Transform func(tx *gorm.DB, ids []string) error
+}
+
+// BackfillCheckpoint is the progress of a backfill, stored as the result of its job with each
+// batch it commits
+type BackfillCheckpoint struct {
+	Backfill string `json:"backfill"`
+	// LastID is the primary key of the last row of the last committed batch
+	LastID  string `json:"lastId,omitempty"`
+	Batches int    `json:"batches"`
+}
+
+// DualWriter is implemented by models moving data into a new column. BaseService calls
+// DualWrite before every create and update, so rows written while the column's backfill runs
+// are filled too.
+type DualWriter interface {
+	DualWrite()
+}
+
+var (
+	backfillsMu sync.RWMutex
+	backfills   = map[string]Backfill{}
+)
+
+// RegisterBackfill adds a backfill to the ones run at startup
+func RegisterBackfill(backfill Backfill) {
+	backfillsMu.Lock()
+	defer backfillsMu.Unlock()
+	if _, ok := backfills[backfill.Name]; ok {
+		panic(fmt.Sprintf("backfill %q registered twice", backfill.Name))
+	}
+	backfills[backfill.Name] = backfill
+}
+
+// LookupBackfill returns the registered backfill named name
+func LookupBackfill(name string) (Backfill, bool) {
+	backfillsMu.RLock()
+	defer backfillsMu.RUnlock()
+	backfill, ok := backfills[name]
+	return backfill, ok
+}
+
+// Backfills returns the registered backfills sorted by name
+func Backfills() []Backfill {
+	backfillsMu.RLock()
+	defer backfillsMu.RUnlock()
+	list := make([]Backfill, 0, len(backfills))
+	for _, backfill := range backfills {
+		list = append(list, backfill)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// backfillJobNamespace derives the job IDs of backfills
+var backfillJobNamespace = uuid.MustParse("5b0f4a8e-2c1d-4e7a-9f36-8d2b7c1e0a54")
+
+// BackfillJobID is the ID of the one job of a backfill, the same on every replica and restart
+// so they all resume the same checkpoint
+func BackfillJobID(name string) string {
+	return uuid.NewSHA1(backfillJobNamespace, []byte(name)).String()
+}
@@ -1,19 +1,23 @@
 package models
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// Base contains common columns for all tables
+// Base contains common columns for all tables. Timestamps are stored and serialised in UTC.
 type Base struct {
-	ID        string    `gorm:"type:uuid;primary_key" json:"id"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
-	DeletedAt time.Time `gorm:"index;default:NULL" json:"-" validate:"omitempty"`
-	IsDeleted bool      `json:"isDeleted" default:"false"`
+	ID        string     `gorm:"type:uuid;primary_key" json:"id"`
+	CreatedAt Timestamp  `json:"createdAt"`
+	UpdatedAt Timestamp  `json:"updatedAt"`
+	DeletedAt *time.Time `gorm:"index;default:NULL" json:"-" validate:"omitempty"`
+	IsDeleted bool       `json:"isDeleted" default:"false"`
+	// Version counts the updates of the row, starting at 1. Clients send back the version they
+	// read and their update fails once another one moved it on, see BeforeUpdate.
+	Version int `gorm:"not null;default:1" json:"version"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -24,6 +28,64 @@ func (base *Base) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// BeforeUpdate moves the version on. Map updates, and struct updates other than of the model
+// itself such as db.Model(&x).Updates(y), increment the stored version. Saves of the model write
+// the version of the struct, which services.BaseService loads first. Models with their own
+// BeforeUpdate call it.
+func (base *Base) BeforeUpdate(tx *gorm.DB) error {
+	stmt := tx.Statement
+	if dest := reflect.Indirect(reflect.ValueOf(stmt.Dest)); dest.Kind() == reflect.Struct && stmt.Dest != stmt.Model {
+		// 🔢 A struct field can't hold the increment, the columns Updates would write become a map
+		values, err := updatedValues(stmt, dest)
+		if err != nil {
+			return err
+		}
+		stmt.Dest = values
+	}
+	if _, ok := stmt.Dest.(map[string]interface{}); ok {
+		stmt.SetColumn("version", gorm.Expr("version + 1"))
+		// Updates of selected columns move the version on too, unless it is omitted
+		if selected, restricted := stmt.SelectAndOmitColumns(false, true); restricted {
+			if _, ok := selected["version"]; !ok {
+				stmt.Selects = append(stmt.Selects, "version")
+			}
+		}
+		return nil
+	}
+	base.Version++
+	return nil
+}
+
+// updatedValues returns the columns an update with the struct dest writes, like GORM its
+// non-zero fields and the selected ones
+func updatedValues(stmt *gorm.Statement, dest reflect.Value) (map[string]interface{}, error) {
+	destSchema := stmt.Schema
+	if dest.Type() != stmt.Schema.ModelType {
+		parsed := &gorm.Statement{DB: stmt.DB}
+		if err := parsed.Parse(stmt.Dest); err != nil {
+			return nil, err
+		}
+		destSchema = parsed.Schema
+	}
+
+	selected, restricted := stmt.SelectAndOmitColumns(false, true)
+	values := map[string]interface{}{}
+	for _, field := range destSchema.Fields {
+		// Update times are left to GORM, which sets them on map updates too
+		if field.DBName == "" || !field.Updatable || field.AutoUpdateTime > 0 || stmt.Schema.LookUpField(field.DBName) == nil {
+			continue
+		}
+		isSelected, ok := selected[field.DBName]
+		if (ok && !isSelected) || (!ok && restricted) {
+			continue
+		}
+		if value, isZero := field.ValueOf(stmt.Context, dest); ok || !isZero {
+			values[field.DBName] = value
+		}
+	}
+	return values, nil
+}
+
 // Job status constants
 type JobStatus string
 
@@ -43,10 +105,32 @@ const (
 	UserRoleMember     UserRole = "MEMBER"
 )
 
+// Rank orders roles by privilege, unknown roles rank lowest
+func (r UserRole) Rank() int {
+	switch r {
+	case UserRoleSuperAdmin:
+		return 3
+	case UserRoleAdmin:
+		return 2
+	case UserRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
 type InviteStatus string
 
 const (
 	InviteStatusPending  InviteStatus = "PENDING"
 	InviteStatusAccepted InviteStatus = "ACCEPTED"
 	InviteStatusRejected InviteStatus = "REJECTED"
+	InviteStatusExpired  InviteStatus = "EXPIRED"
+)
+
+type StoragePolicy string
+
+const (
+	StoragePolicyPrivateOnly   StoragePolicy = "PRIVATE_ONLY"
+	StoragePolicyPublicAllowed StoragePolicy = "PUBLIC_ALLOWED"
 )
@@ -0,0 +1,44 @@
+package models
+
+// BulkUserAction is what a super admin applies to many accounts at once, e.g. during a
+// credential stuffing incident
+type BulkUserAction string
+
+const (
+	// BulkUserDeactivate clears IsActive and signs the user out everywhere
+	BulkUserDeactivate BulkUserAction = "deactivate"
+	// BulkUserForcePasswordReset removes the password, signs the user out and emails a reset link
+	BulkUserForcePasswordReset BulkUserAction = "force_password_reset"
+	// BulkUserRevokeSessions signs the user out everywhere
+	BulkUserRevokeSessions BulkUserAction = "revoke_sessions"
+)
+
+const (
+	// AuditUserDeactivated is recorded when a super admin deactivates an account
+	AuditUserDeactivated = "users.deactivated"
+	// AuditPasswordResetForced is recorded when a super admin forces a password reset
+	AuditPasswordResetForced = "auth.password_reset_forced"
+)
+
+// Outcomes of a bulk user action for one user
+const (
+	BulkUserDone    = "done"
+	BulkUserSkipped = "skipped"
+	BulkUserFailed  = "failed"
+)
+
+// BulkUserResult reports what happened to one user
+type BulkUserResult struct {
+	UserID string `json:"userId"`
+	Status string `json:"status"` // done, skipped or failed
+	Reason string `json:"reason,omitempty"`
+}
+
+// BulkUserActionResult is the result of a bulk user action job
+type BulkUserActionResult struct {
+	Action  BulkUserAction   `json:"action"`
+	Results []BulkUserResult `json:"results"`
+	Done    int              `json:"done"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// CascadeRecord remembers a row changed when its parent was soft-deleted, so restoring the
+// parent reverses exactly the rows the cascade changed and not those changed on their own
+type CascadeRecord struct {
+	Base
+	ParentTable string `gorm:"not null;index:idx_cascade_records_parent" json:"parentTable"`
+	ParentID    string `gorm:"type:uuid;not null;index:idx_cascade_records_parent" json:"parentId"`
+	ChildTable  string `gorm:"not null" json:"childTable"`
+	ChildID     string `gorm:"type:uuid;not null" json:"childId"`
+	// Action is the services.CascadeAction applied to the row
+	Action string `gorm:"not null" json:"action"`
+	// Flag is the column a deactivation cleared
+	Flag string `json:"flag,omitempty"`
+}
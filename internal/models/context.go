@@ -0,0 +1,82 @@
+package models
+
+import "context"
+
+type excludedFieldsKey struct{}
+
+// WithExcludedFields marks virtual fields the caller excluded, so hooks can skip computing them.
+// Fields excluded earlier on ctx stay excluded.
+func WithExcludedFields(ctx context.Context, jsonNames ...string) context.Context {
+	if len(jsonNames) == 0 {
+		return ctx
+	}
+	excluded := map[string]bool{}
+	previous, _ := ctx.Value(excludedFieldsKey{}).(map[string]bool)
+	for name := range previous {
+		excluded[name] = true
+	}
+	for _, name := range jsonNames {
+		excluded[name] = true
+	}
+	return context.WithValue(ctx, excludedFieldsKey{}, excluded)
+}
+
+// IsFieldExcluded reports whether a virtual field was excluded by the caller
+func IsFieldExcluded(ctx context.Context, jsonName string) bool {
+	if ctx == nil {
+		return false
+	}
+	excluded, _ := ctx.Value(excludedFieldsKey{}).(map[string]bool)
+	return excluded[jsonName]
+}
+
+type preferReplicaKey struct{}
+
+// WithPreferReplica asks for signed URLs on the storage replica closest to the requester
+func WithPreferReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, preferReplicaKey{}, true)
+}
+
+// PrefersReplica reports whether signed URLs should be served from the storage replica
+func PrefersReplica(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	prefer, _ := ctx.Value(preferReplicaKey{}).(bool)
+	return prefer
+}
+
+type searchKey struct{}
+
+// WithSearch attaches the ?q= search term of a list request
+func WithSearch(ctx context.Context, q string) context.Context {
+	if q == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, searchKey{}, q)
+}
+
+// SearchFromContext returns the search term attached with WithSearch
+func SearchFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	q, _ := ctx.Value(searchKey{}).(string)
+	return q
+}
+
+type skipSanitizeKey struct{}
+
+// WithSkipSanitize stores templates as submitted, callers must restrict it to super admins
+func WithSkipSanitize(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipSanitizeKey{}, true)
+}
+
+// SkipsSanitize reports whether template sanitization was skipped with WithSkipSanitize
+func SkipsSanitize(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	skip, _ := ctx.Value(skipSanitizeKey{}).(bool)
+	return skip
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// EmailStatus is where an email is in its delivery
+type EmailStatus string
+
+const (
+	EmailStatusQueued EmailStatus = "queued"
+	// EmailStatusRetrying emails were refused for now, e.g. with a 4xx reply, and will be retried
+	EmailStatusRetrying EmailStatus = "retrying"
+	EmailStatusSent     EmailStatus = "sent"
+	// EmailStatusFailed emails were refused for good, with a 5xx reply or after every retry
+	EmailStatusFailed EmailStatus = "failed"
+	// EmailStatusSuppressed emails were not sent, their recipient is suppressed
+	EmailStatusSuppressed EmailStatus = "suppressed"
+	// EmailStatusSandboxed emails were recorded as a SandboxEmail instead of sent
+	EmailStatusSandboxed EmailStatus = "sandboxed"
+)
+
+// EmailLog records the delivery of an email sent through the email tasks. Bodies are not kept.
+type EmailLog struct {
+	Base
+	// MessageID is the part of the email's Message-ID header before the @
+	MessageID string      `gorm:"not null;uniqueIndex" json:"messageId"`
+	TeamID    string      `gorm:"type:uuid;not null;index" json:"teamId"`
+	To        string      `gorm:"not null" json:"to"`
+	Subject   string      `gorm:"not null" json:"subject"`
+	Status    EmailStatus `gorm:"not null;index" json:"status"`
+	Attempts  int         `gorm:"not null;default:0" json:"attempts"`
+	// SMTPCode is the reply code of the last refusal, zero when there was none or the
+	// server could not be reached
+	SMTPCode  int        `json:"smtpCode,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+}
+
+// EmailSuppression is an address emails are no longer sent to, because the receiving server
+// refused it for good, e.g. with 550 no such user
+type EmailSuppression struct {
+	Base
+	// Email is stored lowercase
+	Email    string `gorm:"not null;uniqueIndex" json:"email"`
+	SMTPCode int    `json:"smtpCode"`
+	Reason   string `json:"reason"`
+}
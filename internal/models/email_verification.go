@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailVerificationLifetime is how long a verification code can be used
+const EmailVerificationLifetime = 24 * time.Hour
+
+// EmailVerification is a one-time code proving a user owns their email address
+type EmailVerification struct {
+	Base
+	User   *User  `json:"user,omitempty"`
+	UserID string `gorm:"type:uuid;not null;index" json:"userId"`
+	// Code is the plaintext for the mailer, only CodeHash is stored, hashed with HashResetCode
+	Code      string    `gorm:"-" json:"-"`
+	CodeHash  string    `gorm:"index" json:"-"`
+	Used      bool      `gorm:"default:false" json:"used"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// EmailVerificationCodeDrop removes the plaintext codes stored before codes were hashed.
+// Their rows have no hash, so codes issued before the upgrade stop working.
+const EmailVerificationCodeDrop = `ALTER TABLE email_verifications DROP COLUMN IF EXISTS code`
+
+// EmailVerificationBackfill marks users that existed before email verification as verified.
+// Every registration records an EmailVerification, so users without one were created
+// before the flow existed, or through Google or an invite which prove the address already.
+// Verification rows are never pruned for this reason.
+const EmailVerificationBackfill = `UPDATE users SET email_verified = true, email_verified_at = created_at
+	WHERE email_verified = false
+	AND NOT EXISTS (SELECT 1 FROM email_verifications v WHERE v.user_id = users.id)`
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrFileTooLarge is returned when a stored file exceeds the size a caller is willing to read
+var ErrFileTooLarge = errors.New("file is too large")
+
+// ExtractionStatus is the state of text extraction for a file
+type ExtractionStatus string
+
+const (
+	ExtractionStatusCompleted ExtractionStatus = "COMPLETED"
+	ExtractionStatusFailed    ExtractionStatus = "FAILED"
+	// ExtractionStatusSkipped marks files over the extraction size cap
+	ExtractionStatusSkipped ExtractionStatus = "SKIPPED"
+)
+
+// FileContentMaxText caps the stored text so a single document cannot bloat the search index
+const FileContentMaxText = 1 << 20
+
+// FileContent is the text extracted from an uploaded document, indexed for full-text search
+type FileContent struct {
+	Base
+	FileID string           `gorm:"type:uuid;not null;uniqueIndex" json:"fileId"`
+	Status ExtractionStatus `gorm:"not null" json:"status"`
+	Text   string           `gorm:"type:text" json:"-"`
+	Error  string           `gorm:"type:text" json:"error,omitempty"`
+}
+
+// FileContentSearchIndex is the expression index backing content search
+const FileContentSearchIndex = `CREATE INDEX IF NOT EXISTS idx_file_contents_search ON file_contents USING gin (to_tsvector('simple', text))`
+
+// Searchable is implemented by models that support the ?q= list search
+type Searchable interface {
+	SearchScope(q string) func(*gorm.DB) *gorm.DB
+}
+
+// SearchScope matches files by name or by their extracted text
+func (File) SearchScope(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"files.name ILIKE ? OR EXISTS (SELECT 1 FROM file_contents WHERE file_contents.file_id = files.id"+
+				" AND file_contents.status = ? AND to_tsvector('simple', file_contents.text) @@ plainto_tsquery('simple', ?))",
+			"%"+escapeLike(q)+"%", ExtractionStatusCompleted, q,
+		)
+	}
+}
+
+// escapeLike escapes LIKE wildcards so a search term matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxFileExtension is the longest extension stored, longer ones are not extensions but names
+// with a dot in them
+const maxFileExtension = 16
+
+// FileExtensionBackfill fills the extension of the files uploaded before it was stored
+const FileExtensionBackfill = "file_extension"
+
+func init() {
+	RegisterBackfill(Backfill{
+		Name:  FileExtensionBackfill,
+		Model: &File{},
+		Where: "extension IS NULL",
+		// 🐢 Files is one of the largest tables, small batches keep row locks short
+		BatchSize: 200,
+		Throttle:  100 * time.Millisecond,
+		Transform: backfillFileExtensions,
+	})
+}
+
+// FileExtension returns the lowercase extension of name without the dot, "" when it has none
+func FileExtension(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if len(ext) > maxFileExtension || strings.ContainsAny(ext, " /\\") {
+		return ""
+	}
+	return ext
+}
+
+// DualWrite keeps the extension in step with the name for files written through BaseService
+func (f *File) DualWrite() {
+	if f.Name != "" {
+		f.Extension = FileExtension(f.Name)
+	}
+}
+
+// backfillFileExtensions derives the extension of the files with ids from their name
+func backfillFileExtensions(tx *gorm.DB, ids []string) error {
+	var files []File
+	if err := tx.Select("id", "name").Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return err
+	}
+	for _, file := range files {
+		// Skip hooks, the backfill only writes the new column
+		if err := tx.Model(&File{}).Where("id = ?", file.ID).UpdateColumn("extension", FileExtension(file.Name)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShareUnavailable is returned when a share link is revoked, expired or out of downloads
+	ErrShareUnavailable = errors.New("share link is no longer available")
+)
+
+// FileShare is a public link to a single file for people outside the team
+type FileShare struct {
+	Base
+	FileID        string     `gorm:"type:uuid;not null;index" json:"fileId"`
+	TeamID        string     `gorm:"type:uuid;not null;index" json:"teamId"`
+	CreatedByID   string     `gorm:"type:uuid;not null" json:"createdById"`
+	Token         string     `gorm:"not null;uniqueIndex" json:"token"`
+	PasswordHash  string     `json:"-"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	MaxDownloads  *int       `json:"maxDownloads,omitempty"`
+	DownloadCount int        `gorm:"not null;default:0" json:"downloadCount"`
+	HasPassword   bool       `gorm:"-" json:"hasPassword"` // Virtual field
+}
+
+func (s *FileShare) AfterFind(tx *gorm.DB) error {
+	s.HasPassword = s.PasswordHash != ""
+	return nil
+}
+
+// Available reports whether the share can still be downloaded at now
+func (s *FileShare) Available(now time.Time) bool {
+	if s.IsDeleted {
+		return false
+	}
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return false
+	}
+	if s.MaxDownloads != nil && s.DownloadCount >= *s.MaxDownloads {
+		return false
+	}
+	return true
+}
+
+// GetFileShareByToken retrieves a live share by its public token
+func GetFileShareByToken(token string, db *gorm.DB) (*FileShare, error) {
+	share := &FileShare{}
+	if err := db.Where("token = ? AND is_deleted = false", token).First(share).Error; err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ConsumeFileShareDownload atomically counts one download against a share. It returns
+// ErrShareUnavailable when the share expired or ran out of downloads in the meantime.
+func ConsumeFileShareDownload(db *gorm.DB, shareID string) error {
+	result := db.Model(&FileShare{}).
+		Where("id = ? AND is_deleted = false", shareID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now().UTC()).
+		Where("max_downloads IS NULL OR download_count < max_downloads").
+		UpdateColumn("download_count", gorm.Expr("download_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareUnavailable
+	}
+	return nil
+}
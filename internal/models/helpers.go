@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -13,6 +16,15 @@ func GetTeamByName(name string, db *gorm.DB) (*Team, error) {
 	return team, nil
 }
 
+// GetTeamByID retrieves a team from the database by its ID
+func GetTeamByID(id string, db *gorm.DB) (*Team, error) {
+	team := &Team{}
+	if err := db.Where("id = ? AND is_deleted = false", id).First(team).Error; err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
 func GetFileByID(id string, db *gorm.DB) (*File, error) {
 	file := &File{}
 	if err := db.Where("id = ? AND is_deleted = false", id).First(file).Error; err != nil {
@@ -20,3 +32,75 @@ func GetFileByID(id string, db *gorm.DB) (*File, error) {
 	}
 	return file, nil
 }
+
+// GetLatestPendingInvite retrieves the most recently created pending invite for an email.
+// When an email has been invited to several teams, the newest invite wins.
+func GetLatestPendingInvite(email string, db *gorm.DB) (*TeamInvite, error) {
+	invite := &TeamInvite{}
+	if err := db.Where("email = ? AND status = ? AND expires_at > ? AND is_deleted = false",
+		email, InviteStatusPending, time.Now().UTC()).
+		Order("created_at DESC").
+		First(invite).Error; err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// InviteRole returns the role accepting an invite grants a user whose role is current, empty
+// for a new user. An invite never raises anyone above its inviter's current role, the inviter
+// may have been demoted or deleted since sending it. A user it can't raise keeps their role,
+// a new user joins as a member.
+func InviteRole(invite *TeamInvite, current UserRole, db *gorm.DB) (UserRole, error) {
+	if invite.Role.Rank() <= current.Rank() {
+		return invite.Role, nil
+	}
+	var inviter User
+	err := db.Select("role").Where("id = ? AND is_deleted = false", invite.InviterID).First(&inviter).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err == nil && inviter.Role.Rank() >= invite.Role.Rank() {
+		return invite.Role, nil
+	}
+	if current != "" {
+		return current, nil
+	}
+	return UserRoleMember, nil
+}
+
+// ExpireOtherPendingInvites marks every other pending invite for an email as expired.
+// A user can only belong to one team, so once an invite is accepted the rest can no longer be claimed.
+func ExpireOtherPendingInvites(email, acceptedID string, db *gorm.DB) error {
+	return db.Model(&TeamInvite{}).
+		Where("email = ? AND status = ? AND id <> ?", email, InviteStatusPending, acceptedID).
+		Update("status", InviteStatusExpired).Error
+}
+
+// ExpireStaleInvites marks pending invites for an email and team whose expiry has passed as expired
+func ExpireStaleInvites(email, teamID string, db *gorm.DB) error {
+	return db.Model(&TeamInvite{}).
+		Where("email = ? AND team_id = ? AND status = ? AND expires_at <= ?", email, teamID, InviteStatusPending, time.Now().UTC()).
+		Update("status", InviteStatusExpired).Error
+}
+
+// ExpireStaleTeamInvites marks the team's pending invites for any of the emails whose expiry has passed as expired
+func ExpireStaleTeamInvites(teamID string, emails []string, db *gorm.DB) error {
+	return db.Model(&TeamInvite{}).
+		Where("email IN ? AND team_id = ? AND status = ? AND expires_at <= ?", emails, teamID, InviteStatusPending, time.Now().UTC()).
+		Update("status", InviteStatusExpired).Error
+}
+
+// CountTeamSeats counts a team's active members and unexpired pending invites, which together
+// count towards the member quota
+func CountTeamSeats(teamID string, db *gorm.DB) (int64, error) {
+	var members, invites int64
+	if err := db.Model(&User{}).Where("team_id = ? AND is_deleted = false", teamID).Count(&members).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&TeamInvite{}).
+		Where("team_id = ? AND status = ? AND expires_at > ? AND is_deleted = false", teamID, InviteStatusPending, time.Now().UTC()).
+		Count(&invites).Error; err != nil {
+		return 0, err
+	}
+	return members + invites, nil
+}
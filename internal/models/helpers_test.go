@@ -0,0 +1,123 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func createInvite(t *testing.T, gdb *gorm.DB, teamID, inviterID, email string, expiresAt time.Time) *models.TeamInvite {
+	t.Helper()
+	invite := &models.TeamInvite{
+		Email:     email,
+		Name:      "Invitee",
+		TeamID:    teamID,
+		InviterID: inviterID,
+		Role:      models.UserRoleMember,
+		Code:      "CODE-" + email,
+		Status:    models.InviteStatusPending,
+		ExpiresAt: expiresAt,
+	}
+	if err := gdb.Create(invite).Error; err != nil {
+		t.Fatal(err)
+	}
+	return invite
+}
+
+// Invites expire at the same instant whatever the server's time zone, an IST server used
+// to see them expire hours off
+func TestInviteExpiryIgnoresServerTimeZone(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+
+	now := time.Now().UTC()
+	createInvite(t, gdb, team.ID, inviter.ID, "soon@example.com", now.Add(30*time.Minute))
+	stale := createInvite(t, gdb, team.ID, inviter.ID, "stale@example.com", now.Add(-30*time.Minute))
+
+	if _, err := models.GetLatestPendingInvite("soon@example.com", gdb); err != nil {
+		t.Errorf("invite expiring in 30 minutes not found: %v", err)
+	}
+	if _, err := models.GetLatestPendingInvite("stale@example.com", gdb); err == nil {
+		t.Error("invite that expired 30 minutes ago was found")
+	}
+
+	if err := models.ExpireStaleInvites("stale@example.com", team.ID, gdb); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.ExpireStaleInvites("soon@example.com", team.ID, gdb); err != nil {
+		t.Fatal(err)
+	}
+	var invites []models.TeamInvite
+	if err := gdb.Order("email").Find(&invites).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, invite := range invites {
+		want := models.InviteStatusPending
+		if invite.ID == stale.ID {
+			want = models.InviteStatusExpired
+		}
+		if invite.Status != want {
+			t.Errorf("%s: status %s, want %s", invite.Email, invite.Status, want)
+		}
+	}
+}
+
+func TestStructUpdatesMoveTheVersionOn(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stored := func() models.Template {
+		t.Helper()
+		var stored models.Template
+		if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	updates := []struct {
+		name   string
+		update func() error
+	}{
+		{"struct", func() error {
+			return gdb.Model(&models.Template{Base: models.Base{ID: template.ID}}).Updates(models.Template{Subject: "Hello"}).Error
+		}},
+		{"selected struct", func() error {
+			return gdb.Model(&models.Template{Base: models.Base{ID: template.ID}}).Select("subject").Updates(&models.Template{}).Error
+		}},
+		{"map", func() error {
+			return gdb.Model(&models.Template{Base: models.Base{ID: template.ID}}).Updates(map[string]interface{}{"name": "Hello"}).Error
+		}},
+		{"column", func() error {
+			return gdb.Model(&models.Template{Base: models.Base{ID: template.ID}}).Update("subject", "").Error
+		}},
+		{"save", func() error {
+			current := stored()
+			current.HTML = "<p>Hello</p>"
+			return gdb.Save(&current).Error
+		}},
+	}
+	for i, u := range updates {
+		before := stored()
+		if err := u.update(); err != nil {
+			t.Fatalf("%s update: %v", u.name, err)
+		}
+		// 🔢 Every update moves the version on, or a stale client could overwrite it
+		after := stored()
+		if after.Version != before.Version+1 || after.Version != i+2 {
+			t.Errorf("%s update: version %d after %d, want %d", u.name, after.Version, before.Version, i+2)
+		}
+	}
+	if final := stored(); final.Subject != "" || final.Name != "Hello" || final.HTML != "<p>Hello</p>" || final.TeamID != team.ID {
+		t.Errorf("stored %+v after the updates", final)
+	}
+}
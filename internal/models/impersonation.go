@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ImpersonationLifetime is how long a super admin can act as another user before signing in again
+const ImpersonationLifetime = 15 * time.Minute
+
+// Audit actions of impersonation sessions, recorded with the super admin as actor
+const (
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonationEnded   = "impersonation.ended"
+	// AuditImpersonatedRequest is recorded for every request made with an impersonation session
+	AuditImpersonatedRequest = "impersonation.request"
+)
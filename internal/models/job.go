@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Job types
+const (
+	JobTypePermissionBackfill = "permission_backfill"
+	JobTypeBulkUserAction     = "bulk_user_action"
+	JobTypeBackfill           = "backfill"
+)
+
+// Job tracks a long-running background operation and its progress
+type Job struct {
+	Base
+	Type string `gorm:"not null;index" json:"type"`
+	// UserID is the user who started the job, nil for system jobs such as permission backfills
+	UserID      *string        `gorm:"type:uuid;index" json:"userId,omitempty"`
+	Status      JobStatus      `gorm:"not null;default:'QUEUED'" json:"status"`
+	Total       int64          `gorm:"not null;default:0" json:"total"`
+	Processed   int64          `gorm:"not null;default:0" json:"processed"`
+	Result      datatypes.JSON `gorm:"type:jsonb" json:"result,omitempty"`
+	Error       string         `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   *time.Time     `json:"startedAt,omitempty"`
+	CompletedAt *time.Time     `json:"completedAt,omitempty"`
+}
+
+// Terminal reports whether a job in this status will not change anymore
+func (s JobStatus) Terminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// JobNotifier tells waiting clients, possibly on other replicas, that a job finished
+type JobNotifier interface {
+	NotifyJobFinished(ctx context.Context, jobID string) error
+}
+
+var jobNotifier JobNotifier
+
+// RegisterJobNotifier sets the notifier UpdateJob uses for finished jobs
+func RegisterJobNotifier(notifier JobNotifier) {
+	jobNotifier = notifier
+}
+
+// UpdateJob applies updates to the job. When they move it to a terminal status, clients
+// waiting on the job are notified after the update is stored.
+func UpdateJob(db *gorm.DB, job *Job, updates map[string]interface{}) error {
+	if err := db.Model(job).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	status, ok := updates["status"].(JobStatus)
+	if !ok || !status.Terminal() || jobNotifier == nil {
+		return nil
+	}
+	// A lost notification only delays waiters until they re-check the job
+	if err := jobNotifier.NotifyJobFinished(db.Statement.Context, job.ID); err != nil {
+		log.Warn("Failed to notify waiters of job %s: %v", job.ID, err)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"be0/internal/events"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,15 +13,64 @@ import (
 
 type Team struct {
 	Base
-	Name    string       `gorm:"not null" json:"name" validate:"required,min=2"`
-	Users   []User       `gorm:"foreignKey:TeamID;references:ID" json:"users,omitempty"`
-	Invites []TeamInvite `gorm:"foreignKey:TeamID;references:ID;constraint:OnDelete:CASCADE" json:"invites,omitempty"`
+	Name string `gorm:"not null" json:"name" validate:"required,min=2"`
+	// Slug identifies the team in URLs. It is derived from the name when the team is created,
+	// with a numeric suffix when taken, and never changes.
+	Slug          string        `gorm:"<-:create;uniqueIndex;size:80" json:"slug"`
+	StoragePolicy StoragePolicy `gorm:"not null;default:'PUBLIC_ALLOWED'" json:"storagePolicy" validate:"omitempty,oneof=PRIVATE_ONLY PUBLIC_ALLOWED"`
+	// SandboxMode records outgoing emails and webhooks instead of sending them
+	SandboxMode bool `gorm:"not null;default:false" json:"sandboxMode"`
+	// Plan is the team's billing plan. It is set by billing, not through the API.
+	Plan string `gorm:"->;not null;default:'free'" json:"plan"`
+	// AuthPolicy restricts how members sign in
+	AuthPolicy AuthPolicy `gorm:"embedded;embeddedPrefix:auth_" json:"authPolicy"`
+	// OrganizationID groups the team under an organization, nil for standalone teams. It only
+	// changes through the organization routes.
+	OrganizationID *string      `gorm:"->;type:uuid;index;default:NULL" json:"organizationId,omitempty"`
+	Users          []User       `gorm:"foreignKey:TeamID;references:ID" json:"users,omitempty"`
+	Invites        []TeamInvite `gorm:"foreignKey:TeamID;references:ID;constraint:OnDelete:CASCADE" json:"invites,omitempty"`
+}
+
+// PlanClaims is a claim enricher adding the plan of the user's team to access tokens as plan
+func PlanClaims(db *gorm.DB) func(user User) map[string]any {
+	return func(user User) map[string]any {
+		var team Team
+		if err := db.Select("plan").Where("id = ? AND is_deleted = false", user.TeamID).First(&team).Error; err != nil {
+			return nil
+		}
+		return map[string]any{"plan": team.Plan}
+	}
+}
+
+// ErrPublicACLNotAllowed is returned when a public ACL is requested for a private-only team
+var ErrPublicACLNotAllowed = errors.New("team storage policy is PRIVATE_ONLY, public files are not allowed")
+
+// IsPublicACL reports whether a canned ACL exposes objects to anonymous readers
+func IsPublicACL(acl string) bool {
+	return acl == "public-read" || acl == "public-read-write"
+}
+
+// CheckACL validates a canned ACL against the team storage policy
+func (t *Team) CheckACL(acl string) error {
+	if t.StoragePolicy == StoragePolicyPrivateOnly && IsPublicACL(acl) {
+		return ErrPublicACLNotAllowed
+	}
+	return nil
 }
 
 func (t *Team) BeforeCreate(tx *gorm.DB) error {
 	if t.ID == "" {
 		t.ID = uuid.New().String()
 	}
+	// 🔗 Teams created without services.CreateTeam take the next free slug, but do not retry
+	// when a concurrent create takes it first
+	if t.Slug == "" {
+		slug, err := NextTeamSlug(tx, t.Name)
+		if err != nil {
+			return err
+		}
+		t.Slug = slug
+	}
 	return nil
 }
 
@@ -29,31 +80,39 @@ func (t *Team) AfterCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// InviteLifetime is how long a team invite can be accepted
+const InviteLifetime = 7 * 24 * time.Hour
+
 type TeamInvite struct {
 	Base
-	Email     string       `gorm:"not null" json:"email" validate:"required,email"`
+	Email     string       `gorm:"not null;index:idx_team_invites_pending,unique,where:status = 'PENDING' AND is_deleted = false" json:"email" validate:"required,email" redact:"scope=users:read_pii,mask=email"`
 	Name      string       `gorm:"not null" json:"name" validate:"required,min=2"`
-	TeamID    string       `gorm:"type:uuid;not null" json:"teamId" validate:"required,uuid"`
+	TeamID    string       `gorm:"type:uuid;not null;index:idx_team_invites_pending,unique" json:"teamId" validate:"required,uuid"`
 	Team      *Team        `json:"team,omitempty"`
 	InviterID string       `gorm:"type:uuid;not null" json:"inviterId" validate:"required,uuid"`
 	Inviter   *User        `json:"inviter,omitempty"`
 	Role      UserRole     `gorm:"not null;default:'MEMBER'" json:"role" validate:"required,oneof=MEMBER ADMIN"`
 	Code      string       `gorm:"not null" json:"code" validate:"required=min=4"`
-	Status    InviteStatus `gorm:"not null;default:'PENDING'" json:"status" validate:"required,oneof=PENDING ACCEPTED REJECTED"`
+	Status    InviteStatus `gorm:"not null;default:'PENDING'" json:"status" validate:"required,oneof=PENDING ACCEPTED REJECTED EXPIRED"`
 	ExpiresAt time.Time    `gorm:"not null" json:"expiresAt" validate:"required,gt=now"`
 }
 
 type File struct {
 	Base
-	TeamID    string `gorm:"type:uuid" json:"teamId" validate:"omitempty,uuid"`
-	Team      *Team  `json:"team,omitempty"`
-	Path      string `gorm:"not null" json:"path" validate:"required"`
-	UserID    string `gorm:"type:uuid;default:NULL" json:"userId" validate:"omitempty,uuid"`
-	User      *User  `json:"user,omitempty"`
-	Name      string `gorm:"not null" json:"name" validate:"required"`
-	Size      int64  `gorm:"not null" json:"size" validate:"required,min=1"`
-	Type      string `gorm:"not null" json:"type" validate:"required"`
+	TeamID string  `gorm:"type:uuid" json:"teamId" validate:"omitempty,uuid"`
+	Team   *Team   `json:"team,omitempty"`
+	Path   string  `gorm:"not null" json:"path" validate:"required" redact:"scope=files:read_paths"`
+	UserID *string `gorm:"type:uuid;default:NULL" json:"userId,omitempty" validate:"omitempty,uuid"` // Uploader, nil for API key uploads
+	User   *User   `json:"user,omitempty"`
+	Name   string  `gorm:"not null" json:"name" validate:"required"`
+	Size   int64   `gorm:"not null" json:"size" validate:"required,min=1"`
+	Type   string  `gorm:"not null" json:"type" validate:"required"`
+	// Extension is the lowercase extension of the name without the dot, NULL for files
+	// uploaded before it was added until the file_extension backfill reaches them
+	Extension string `gorm:"size:16" json:"extension"`
+	ACL       string `gorm:"default:NULL" json:"acl,omitempty"`
 	SignedURL string `gorm:"-" json:"signedUrl,omitempty"` // Virtual field
+	MatchedIn string `gorm:"-" json:"matchedIn,omitempty"` // Virtual field, set on searches: name or content
 }
 
 func (f *File) BeforeCreate(tx *gorm.DB) error {
@@ -64,10 +123,29 @@ func (f *File) BeforeCreate(tx *gorm.DB) error {
 }
 
 func (f *File) AfterFind(tx *gorm.DB) error {
+	// Searches match either the name or the extracted text
+	if q := SearchFromContext(tx.Statement.Context); q != "" {
+		f.MatchedIn = "content"
+		if strings.Contains(strings.ToLower(f.Name), strings.ToLower(q)) {
+			f.MatchedIn = "name"
+		}
+	}
+
+	// Excluding signedUrl skips the presign work entirely
+	if IsFieldExcluded(tx.Statement.Context, "signedUrl") {
+		return nil
+	}
+
 	registryMu.RLock()
 	generator := urlGenerator
 	registryMu.RUnlock()
 
+	// Public files don't need a signature
+	if public, ok := generator.(PublicURLGenerator); ok && IsPublicACL(f.ACL) {
+		f.SignedURL = public.GetPublicURL(f.Path)
+		return nil
+	}
+
 	if generator != nil {
 		// Generate URL with 1-hour expiry
 		url, err := generator.GetSignedURL(tx.Statement.Context, f.Path, time.Hour)
@@ -79,6 +157,14 @@ func (f *File) AfterFind(tx *gorm.DB) error {
 	return nil
 }
 
+// VirtualFieldColumns returns the columns AfterFind computes the virtual fields from
+func (File) VirtualFieldColumns() map[string][]string {
+	return map[string][]string{
+		"signedUrl": {"path", "acl"},
+		"matchedIn": {"name"},
+	}
+}
+
 // IsValidUserRole checks if a given role is valid
 func IsValidUserRole(role UserRole) bool {
 	switch role {
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization groups teams under one umbrella, e.g. an agency and its client teams.
+// Its admins act as admins in every team of the organization.
+type Organization struct {
+	Base
+	Name  string `gorm:"not null" json:"name" validate:"required,min=2"`
+	Teams []Team `gorm:"foreignKey:OrganizationID;references:ID" json:"teams,omitempty"`
+}
+
+// OrganizationAdmin grants a user admin access to every team of an organization
+type OrganizationAdmin struct {
+	Base
+	OrganizationID string `gorm:"type:uuid;not null;uniqueIndex:idx_organization_admins_user,where:is_deleted = false" json:"organizationId"`
+	UserID         string `gorm:"type:uuid;not null;uniqueIndex:idx_organization_admins_user,where:is_deleted = false;index" json:"userId"`
+	User           *User  `json:"user,omitempty"`
+}
+
+// ErrNoTeamAccess is returned when a user may not act in a team
+var ErrNoTeamAccess = errors.New("no access to team")
+
+// IsOrganizationAdmin reports whether a user administers an organization
+func IsOrganizationAdmin(db *gorm.DB, organizationID, userID string) (bool, error) {
+	var count int64
+	err := db.Model(&OrganizationAdmin{}).
+		Where("organization_id = ? AND user_id = ? AND is_deleted = false", organizationID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// TeamAccess loads a team the user may act in: their own team, or for organization admins
+// any team of their organization. orgAdmin reports whether the user administers the team's
+// organization. Unknown and deleted teams return gorm.ErrRecordNotFound, other teams ErrNoTeamAccess.
+func TeamAccess(db *gorm.DB, user *User, teamID string) (team *Team, orgAdmin bool, err error) {
+	if team, err = GetTeamByID(teamID, db); err != nil {
+		return nil, false, err
+	}
+	if team.OrganizationID != nil {
+		if orgAdmin, err = IsOrganizationAdmin(db, *team.OrganizationID, user.ID); err != nil {
+			return nil, false, err
+		}
+	}
+	if team.ID != user.TeamID && !orgAdmin {
+		return nil, false, ErrNoTeamAccess
+	}
+	return team, orgAdmin, nil
+}
+
+// TeamOrganizationID returns the organization of a team, empty for standalone teams
+func TeamOrganizationID(db *gorm.DB, teamID string) (string, error) {
+	team, err := GetTeamByID(teamID, db)
+	if err != nil || team.OrganizationID == nil {
+		return "", err
+	}
+	return *team.OrganizationID, nil
+}
+
+// SetTeamOrganization attaches a team to an organization, or detaches it with an empty organizationID
+func SetTeamOrganization(db *gorm.DB, teamID, organizationID string) error {
+	var value interface{}
+	if organizationID != "" {
+		value = organizationID
+	}
+	// The column is read-only on the model, so CRUD updates of teams cannot move them
+	return db.Table("teams").Where("id = ? AND is_deleted = false", teamID).
+		Updates(map[string]interface{}{"organization_id": value, "updated_at": time.Now().UTC()}).Error
+}
+
+// TeamUsage is what one team of an organization uses
+type TeamUsage struct {
+	TeamID         string `json:"teamId"`
+	Name           string `json:"name"`
+	Members        int64  `json:"members"`
+	PendingInvites int64  `json:"pendingInvites"`
+	Files          int64  `json:"files"`
+	StorageBytes   int64  `json:"storageBytes"`
+}
+
+// OrganizationUsage adds up the usage of an organization's teams
+type OrganizationUsage struct {
+	OrganizationID string      `json:"organizationId"`
+	Teams          []TeamUsage `json:"teams"`
+	Total          TeamUsage   `json:"total"`
+}
+
+// GetOrganizationUsage counts members, pending invites, files and stored bytes per team of an organization
+func GetOrganizationUsage(db *gorm.DB, organizationID string) (*OrganizationUsage, error) {
+	usage := &OrganizationUsage{OrganizationID: organizationID, Teams: []TeamUsage{}}
+	if err := db.Model(&Team{}).Select("id AS team_id, name").
+		Where("organization_id = ? AND is_deleted = false", organizationID).
+		Order("name").Scan(&usage.Teams).Error; err != nil {
+		return nil, err
+	}
+	if len(usage.Teams) == 0 {
+		return usage, nil
+	}
+
+	teamIDs := make([]string, len(usage.Teams))
+	byTeam := make(map[string]*TeamUsage, len(usage.Teams))
+	for i := range usage.Teams {
+		teamIDs[i] = usage.Teams[i].TeamID
+		byTeam[usage.Teams[i].TeamID] = &usage.Teams[i]
+	}
+
+	type teamCount struct {
+		TeamID string
+		Count  int64
+		Bytes  int64
+	}
+	var members, invites, files []teamCount
+	if err := db.Model(&User{}).Select("team_id, COUNT(*) AS count").
+		Where("team_id IN ? AND is_deleted = false", teamIDs).Group("team_id").Scan(&members).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&TeamInvite{}).Select("team_id, COUNT(*) AS count").
+		Where("team_id IN ? AND status = ? AND expires_at > ? AND is_deleted = false", teamIDs, InviteStatusPending, time.Now().UTC()).
+		Group("team_id").Scan(&invites).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&File{}).Select("team_id, COUNT(*) AS count, COALESCE(SUM(size), 0) AS bytes").
+		Where("team_id IN ? AND is_deleted = false", teamIDs).Group("team_id").Scan(&files).Error; err != nil {
+		return nil, err
+	}
+
+	for _, c := range members {
+		byTeam[c.TeamID].Members = c.Count
+	}
+	for _, c := range invites {
+		byTeam[c.TeamID].PendingInvites = c.Count
+	}
+	for _, c := range files {
+		byTeam[c.TeamID].Files, byTeam[c.TeamID].StorageBytes = c.Count, c.Bytes
+	}
+
+	for _, team := range usage.Teams {
+		usage.Total.Members += team.Members
+		usage.Total.PendingInvites += team.PendingInvites
+		usage.Total.Files += team.Files
+		usage.Total.StorageBytes += team.StorageBytes
+	}
+	return usage, nil
+}
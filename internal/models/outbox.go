@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// OutboxEvent is an entity change recorded in the same transaction as the change itself,
+// so it can be forwarded to an external broker at least once after commit
+type OutboxEvent struct {
+	Base
+	Event       string         `gorm:"not null;index" json:"event"` // e.g. "teams.created"
+	Table       string         `gorm:"not null" json:"table"`       // e.g. "teams"
+	Payload     datatypes.JSON `gorm:"type:jsonb;not null" json:"payload"`
+	TeamID      string         `gorm:"type:uuid;default:NULL" json:"teamId,omitempty"`
+	ActorID     string         `gorm:"type:uuid;default:NULL" json:"actorId,omitempty"`
+	RequestID   string         `json:"requestId,omitempty"`
+	PublishedAt *time.Time     `gorm:"index" json:"publishedAt,omitempty"`
+}
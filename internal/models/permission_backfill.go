@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// PermissionBackfillBatchSize is how many users are processed per batch
+const PermissionBackfillBatchSize = 500
+
+// PermissionBackfillResult summarises a backfill per role
+type PermissionBackfillResult struct {
+	// AffectedUsers is the number of users per role missing at least one permission
+	AffectedUsers map[UserRole]int64 `json:"affectedUsers"`
+	// InsertedPermissions is the number of user permissions created per role (zero on dry runs)
+	InsertedPermissions map[UserRole]int64 `json:"insertedPermissions"`
+	DryRun              bool               `json:"dryRun"`
+}
+
+// BackfillPermissions grants the given resource permissions to existing users whose role
+// includes them by default. Users are processed in batches and permissions they already
+// hold are skipped, so the backfill can be re-run safely. progress is called after each
+// batch with the number of users processed so far.
+func BackfillPermissions(ctx context.Context, db *gorm.DB, permissionIDs []string, dryRun bool, progress func(processed int64) error) (*PermissionBackfillResult, error) {
+	db = db.WithContext(ctx)
+
+	result := &PermissionBackfillResult{
+		AffectedUsers:       map[UserRole]int64{},
+		InsertedPermissions: map[UserRole]int64{},
+		DryRun:              dryRun,
+	}
+
+	// 🗺️ Work out which of the permissions each role should receive
+	wanted := make(map[string]bool, len(permissionIDs))
+	for _, id := range permissionIDs {
+		wanted[id] = true
+	}
+
+	rolePerms := map[UserRole][]string{}
+	for _, role := range []UserRole{UserRoleSuperAdmin, UserRoleAdmin, UserRoleMember} {
+		permissions, err := PermissionsForRole(db, role)
+		if err != nil {
+			return nil, err
+		}
+		for _, perm := range permissions {
+			if len(wanted) == 0 || wanted[perm.ID] {
+				rolePerms[role] = append(rolePerms[role], perm.ID)
+			}
+		}
+	}
+
+	var processed int64
+	var users []User
+	batches := db.Select("id", "role").
+		Where("is_deleted = false").
+		Order("id").
+		FindInBatches(&users, PermissionBackfillBatchSize, func(tx *gorm.DB, batch int) error {
+			userIDs := make([]string, 0, len(users))
+			for _, user := range users {
+				userIDs = append(userIDs, user.ID)
+			}
+
+			// 🔍 Load what the batch already holds
+			var existing []UserPermission
+			if err := db.Select("user_id", "resource_permission_id").
+				Where("user_id IN ? AND is_deleted = false", userIDs).
+				Find(&existing).Error; err != nil {
+				return fmt.Errorf("failed to load user permissions: %v", err)
+			}
+			held := make(map[string]bool, len(existing))
+			for _, perm := range existing {
+				held[perm.UserID+":"+perm.ResourcePermissionID] = true
+			}
+
+			// ➕ Compute the missing ones
+			var missing []UserPermission
+			for _, user := range users {
+				added := 0
+				for _, permID := range rolePerms[user.Role] {
+					if held[user.ID+":"+permID] {
+						continue
+					}
+					missing = append(missing, UserPermission{UserID: user.ID, ResourcePermissionID: permID})
+					added++
+				}
+				if added > 0 {
+					result.AffectedUsers[user.Role]++
+					if !dryRun {
+						result.InsertedPermissions[user.Role] += int64(added)
+					}
+				}
+			}
+
+			if !dryRun && len(missing) > 0 {
+				if err := db.CreateInBatches(&missing, 100).Error; err != nil {
+					return fmt.Errorf("failed to create user permissions: %v", err)
+				}
+			}
+
+			processed += int64(len(users))
+			if progress != nil {
+				return progress(processed)
+			}
+			return nil
+		})
+	if batches.Error != nil {
+		return nil, batches.Error
+	}
+
+	return result, nil
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Resource struct {
 	Base
@@ -24,3 +28,21 @@ type UserPermission struct {
 	ResourcePermission   *ResourcePermission `json:"resourcePermission,omitempty"`
 	CreatedAt            time.Time           `json:"createdAt"`
 }
+
+// LoadScopes loads a user's permissions with their resource permission, whose scopes
+// access tokens are issued with
+func LoadScopes(db *gorm.DB, user *User) error {
+	return db.Preload("ResourcePermission").
+		Where("user_id = ? AND is_deleted = false", user.ID).
+		Find(&user.Permissions).Error
+}
+
+// UserScopes returns the scopes of the permissions granted to a user, e.g. "files:read"
+func UserScopes(db *gorm.DB, userID string) ([]string, error) {
+	var scopes []string
+	err := db.Model(&UserPermission{}).
+		Joins("JOIN resource_permissions ON resource_permissions.id = user_permissions.resource_permission_id").
+		Where("user_permissions.user_id = ? AND user_permissions.is_deleted = false AND resource_permissions.is_deleted = false", userID).
+		Pluck("resource_permissions.scope", &scopes).Error
+	return scopes, err
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RecoveryStatus is the state of an account recovery request
+type RecoveryStatus string
+
+const (
+	// RecoveryStatusPending waits for a second super admin to approve it
+	RecoveryStatusPending   RecoveryStatus = "PENDING"
+	RecoveryStatusApproved  RecoveryStatus = "APPROVED"
+	RecoveryStatusRedeemed  RecoveryStatus = "REDEEMED"
+	RecoveryStatusCancelled RecoveryStatus = "CANCELLED"
+)
+
+// RecoveryTokenLifetime is how long an approved recovery token can be redeemed
+const RecoveryTokenLifetime = 24 * time.Hour
+
+// Audit actions of the recovery flow
+const (
+	AuditRecoveryRequested = "account_recovery.requested"
+	AuditRecoveryApproved  = "account_recovery.approved"
+	AuditRecoveryCancelled = "account_recovery.cancelled"
+	AuditRecoveryRedeemed  = "account_recovery.redeemed"
+)
+
+// RecoveryRequest is an admin-assisted account recovery for a user who lost access to
+// their email. The one-time token only works once a second super admin approved it.
+type RecoveryRequest struct {
+	Base
+	UserID        string         `gorm:"type:uuid;not null;index" json:"userId"`
+	User          *User          `json:"user,omitempty"`
+	TeamID        string         `gorm:"type:uuid;not null" json:"teamId"`
+	RequestedByID string         `gorm:"type:uuid;not null" json:"requestedById"`
+	ApprovedByID  *string        `gorm:"type:uuid" json:"approvedById,omitempty"`
+	Reason        string         `gorm:"type:text;not null" json:"reason"`
+	TokenHash     string         `gorm:"not null;uniqueIndex" json:"-"`
+	Status        RecoveryStatus `gorm:"not null;index" json:"status"`
+	ApprovedAt    *time.Time     `json:"approvedAt,omitempty"`
+	ExpiresAt     *time.Time     `json:"expiresAt,omitempty"`
+	RedeemedAt    *time.Time     `json:"redeemedAt,omitempty"`
+}
+
+// HashRecoveryToken hashes a recovery token for storage and lookup
+func HashRecoveryToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -11,9 +11,40 @@ type FileURLGenerator interface {
 	GetSignedURL(ctx context.Context, path string, duration time.Duration) (string, error)
 }
 
+// PublicURLGenerator is implemented by generators that can build unsigned URLs
+// for publicly readable files, e.g. on a CDN host
+type PublicURLGenerator interface {
+	GetPublicURL(path string) string
+}
+
+// FileDeleter interface for removing stored files
+type FileDeleter interface {
+	DeleteFile(ctx context.Context, path string) error
+}
+
+// FileDownloader interface for reading stored files
+type FileDownloader interface {
+	// DownloadFile returns ErrFileTooLarge when the object is larger than maxBytes
+	DownloadFile(ctx context.Context, path string, maxBytes int64) ([]byte, error)
+}
+
+// FileWriter stores files at a path chosen by the caller, writing the same path again replaces it
+type FileWriter interface {
+	PutFile(ctx context.Context, path string, data []byte, contentType string) error
+}
+
+// AvatarURLSigner builds signed public avatar URLs
+type AvatarURLSigner interface {
+	AvatarURL(userID string, size int) string
+}
+
 var (
-	urlGenerator FileURLGenerator
-	registryMu   sync.RWMutex
+	avatarSigner   AvatarURLSigner
+	urlGenerator   FileURLGenerator
+	fileDeleter    FileDeleter
+	fileDownloader FileDownloader
+	fileWriter     FileWriter
+	registryMu     sync.RWMutex
 )
 
 // RegisterFileURLGenerator sets the URL generator for files
@@ -22,3 +53,59 @@ func RegisterFileURLGenerator(generator FileURLGenerator) {
 	defer registryMu.Unlock()
 	urlGenerator = generator
 }
+
+// RegisterFileDeleter sets the storage backend used to remove files
+func RegisterFileDeleter(deleter FileDeleter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	fileDeleter = deleter
+}
+
+// GetFileDeleter returns the registered file deleter
+func GetFileDeleter() FileDeleter {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return fileDeleter
+}
+
+// RegisterFileDownloader sets the storage backend used to read files
+func RegisterFileDownloader(downloader FileDownloader) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	fileDownloader = downloader
+}
+
+// GetFileDownloader returns the registered file downloader
+func GetFileDownloader() FileDownloader {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return fileDownloader
+}
+
+// RegisterFileWriter sets the storage backend files are written to at fixed paths
+func RegisterFileWriter(writer FileWriter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	fileWriter = writer
+}
+
+// GetFileWriter returns the registered file writer
+func GetFileWriter() FileWriter {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return fileWriter
+}
+
+// RegisterAvatarURLSigner sets the signer used for users' public avatar URLs
+func RegisterAvatarURLSigner(signer AvatarURLSigner) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	avatarSigner = signer
+}
+
+// GetAvatarURLSigner returns the registered avatar URL signer
+func GetAvatarURLSigner() AvatarURLSigner {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return avatarSigner
+}
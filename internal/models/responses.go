@@ -0,0 +1,81 @@
+package models
+
+// ResponseMapper is implemented by models that expose a dedicated API shape instead of their raw columns
+type ResponseMapper interface {
+	ToResponse() interface{}
+}
+
+// VirtualFieldSource is implemented by models whose virtual fields are computed from columns,
+// so sparse fieldsets with a virtual field select its columns too
+type VirtualFieldSource interface {
+	// VirtualFieldColumns maps the JSON name of a virtual field to the columns it is computed from
+	VirtualFieldColumns() map[string][]string
+}
+
+// ResponseOf returns the API shape of a model, using its ResponseMapper when it implements one
+func ResponseOf(v interface{}) interface{} {
+	if mapper, ok := v.(ResponseMapper); ok {
+		return mapper.ToResponse()
+	}
+	return v
+}
+
+// UserResponse is the public representation of a user.
+// It never carries the password, provider payloads or permission internals.
+type UserResponse struct {
+	ID                string    `json:"id"`
+	Email             string    `json:"email" redact:"scope=users:read_pii,mask=email"`
+	FirstName         string    `json:"firstName"`
+	LastName          string    `json:"lastName"`
+	Role              UserRole  `json:"role"`
+	TeamID            string    `json:"teamId"`
+	Team              *Team     `json:"team,omitempty"`
+	Provider          string    `json:"provider" redact:"scope=users:read_pii"`
+	TwoFactorEnabled  bool      `json:"twoFactorEnabled"`
+	IsActive          bool      `json:"isActive"`
+	ProfilePictureID  string    `json:"profilePictureId,omitempty"`
+	ProfilePictureURL string    `json:"profilePictureUrl,omitempty"`
+	AvatarURL         string    `json:"avatarUrl,omitempty"` // signed, usable without authentication
+	CreatedAt         Timestamp `json:"createdAt"`
+	UpdatedAt         Timestamp `json:"updatedAt"`
+}
+
+// ToResponse maps a user to its public representation
+func (u *User) ToResponse() interface{} {
+	return u.Response()
+}
+
+// Response maps a user to a UserResponse
+func (u *User) Response() UserResponse {
+	var avatarURL string
+	if signer := GetAvatarURLSigner(); signer != nil {
+		avatarURL = signer.AvatarURL(u.ID, 0)
+	}
+
+	return UserResponse{
+		ID:                u.ID,
+		Email:             u.Email,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		Role:              u.Role,
+		TeamID:            u.TeamID,
+		Team:              u.Team,
+		Provider:          u.Provider,
+		TwoFactorEnabled:  u.TwoFactorEnabled,
+		IsActive:          u.IsActive,
+		ProfilePictureID:  u.ProfilePictureID,
+		ProfilePictureURL: u.ProfilePicture.SignedURL,
+		AvatarURL:         avatarURL,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+	}
+}
+
+// UserResponses maps a list of users to their public representation
+func UserResponses(users []User) []UserResponse {
+	responses := make([]UserResponse, len(users))
+	for i := range users {
+		responses[i] = users[i].Response()
+	}
+	return responses
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// SandboxRetention is how long sandbox records are kept
+const SandboxRetention = 7 * 24 * time.Hour
+
+// SandboxEmail is an email that would have been sent by a team in sandbox mode
+type SandboxEmail struct {
+	Base
+	TeamID  string `gorm:"type:uuid;not null;index" json:"teamId"`
+	To      string `gorm:"not null" json:"to"`
+	Subject string `gorm:"not null" json:"subject"`
+	Text    string `gorm:"type:text" json:"text"`
+	HTML    string `gorm:"type:text" json:"html"`
+}
+
+// SandboxWebhook is a webhook delivery that would have been sent by a team in sandbox mode
+type SandboxWebhook struct {
+	Base
+	TeamID         string         `gorm:"type:uuid;not null;index" json:"teamId"`
+	SubscriptionID string         `gorm:"type:uuid;not null" json:"subscriptionId"`
+	URL            string         `gorm:"not null" json:"url"`
+	Event          string         `gorm:"not null" json:"event"`
+	Payload        datatypes.JSON `gorm:"type:jsonb;not null" json:"payload"`
+}
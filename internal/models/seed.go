@@ -22,12 +22,14 @@ var defaultResources = []Resource{
 	{Name: "teams", Action: "read"},
 	{Name: "teams", Action: "update"},
 	{Name: "teams", Action: "delete"},
+	{Name: "teams", Action: "restore"}, // undoing deletions, super admins only
 
 	// User resources
 	{Name: "users", Action: "create"},
 	{Name: "users", Action: "read"},
 	{Name: "users", Action: "update"},
 	{Name: "users", Action: "delete"},
+	{Name: "users", Action: "read_pii"}, // emails and sign-in providers of other users
 
 	// Permission resources
 	{Name: "permissions", Action: "create"},
@@ -46,23 +48,55 @@ var defaultResources = []Resource{
 	{Name: "team_invites", Action: "read"},
 	{Name: "team_invites", Action: "update"},
 	{Name: "team_invites", Action: "delete"},
+	{Name: "team_invites", Action: "restore"}, // undoing deletions, admins only
 
 	// File resources
 	{Name: "files", Action: "create"},
 	{Name: "files", Action: "read"},
 	{Name: "files", Action: "update"},
 	{Name: "files", Action: "delete"},
+	{Name: "files", Action: "read_paths"}, // storage keys of files
+	{Name: "files", Action: "restore"},    // undoing deletions, admins only
+
+	// Webhook resources
+	{Name: "webhooks", Action: "create"},
+	{Name: "webhooks", Action: "read"},
+	{Name: "webhooks", Action: "update"},
+	{Name: "webhooks", Action: "delete"},
+	{Name: "webhooks", Action: "read_secrets"}, // endpoint URLs, which may embed tokens
+
+	// Template resources
+	{Name: "templates", Action: "create"},
+	{Name: "templates", Action: "read"},
+	{Name: "templates", Action: "update"},
+	{Name: "templates", Action: "delete"},
+	{Name: "templates", Action: "restore"}, // undoing deletions, admins only
+	{Name: "api_keys", Action: "create"},
+	{Name: "api_keys", Action: "read"},
+	{Name: "api_keys", Action: "update"},
+	{Name: "api_keys", Action: "delete"},
+}
+
+// IsDefaultResource reports whether name is one of the seeded resources, e.g. "files"
+func IsDefaultResource(name string) bool {
+	for _, resource := range defaultResources {
+		if resource.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Role-based permission mappings
 var rolePermissions = map[UserRole][]string{
 	UserRoleAdmin: {
 		// Admin has all permissions
-		"teams:*", "users:*", "permissions:*", "roles:*", "team_invites:*", "files:*",
+		"teams:*", "users:*", "permissions:*", "roles:*", "team_invites:*", "files:*", "webhooks:*", "templates:*",
+		"api_keys:*",
 	},
 	UserRoleMember: {
 		// Member has limited permissions
-		"teams:read", "users:read", "permissions:read", "roles:read", "team_invites:read", "files:read",
+		"teams:read", "users:read", "permissions:read", "roles:read", "team_invites:read", "files:read", "webhooks:read", "templates:read",
 	},
 	UserRoleSuperAdmin: {
 		// SuperAdmin has all permissions
@@ -72,13 +106,27 @@ var rolePermissions = map[UserRole][]string{
 
 // SeedPermissions creates default resources and permissions
 func SeedPermissions(db *gorm.DB) error {
+	_, err := SeedPermissionsWithChanges(db)
+	return err
+}
+
+// SeedPermissionsWithChanges creates default resources and permissions and returns
+// the resource permissions that did not exist before, so they can be backfilled to existing users
+func SeedPermissionsWithChanges(db *gorm.DB) ([]ResourcePermission, error) {
+	var created []ResourcePermission
+	track := func(permission *ResourcePermission, isNew bool) {
+		if isNew {
+			created = append(created, *permission)
+		}
+	}
+
 	// Create resources
 	for _, resource := range defaultResources {
 		if err := db.FirstOrCreate(&resource, Resource{
 			Name:   resource.Name,
 			Action: resource.Action,
 		}).Error; err != nil {
-			return fmt.Errorf("failed to create resource %s:%s: %v", resource.Name, resource.Action, err)
+			return nil, fmt.Errorf("failed to create resource %s:%s: %v", resource.Name, resource.Action, err)
 		}
 	}
 
@@ -92,39 +140,43 @@ func SeedPermissions(db *gorm.DB) error {
 				resourceName := strings.TrimSuffix(permScope, ":*") // Remove :*
 				var resources []Resource
 				if err := db.Where("name = ?", resourceName).Find(&resources).Error; err != nil {
-					return fmt.Errorf("failed to find resources for %s: %v", resourceName, err)
+					return nil, fmt.Errorf("failed to find resources for %s: %v", resourceName, err)
 				}
 
 				// Create permissions for all actions of this resource
 				for _, resource := range resources {
-					if err := createResourcePermission(db, resource); err != nil {
-						return err
+					permission, isNew, err := createResourcePermission(db, resource)
+					if err != nil {
+						return nil, err
 					}
+					track(permission, isNew)
 				}
 			} else {
 				// Handle specific permissions
 				parts := strings.Split(permScope, ":")
 				if len(parts) != 2 {
-					return fmt.Errorf("invalid permission scope format: %s", permScope)
+					return nil, fmt.Errorf("invalid permission scope format: %s", permScope)
 				}
 
 				resourceName, action := parts[0], parts[1]
 				var resource Resource
 				if err := db.Where("name = ? AND action = ?", resourceName, action).First(&resource).Error; err != nil {
-					return fmt.Errorf("failed to find resource %s:%s: %v", resourceName, action, err)
+					return nil, fmt.Errorf("failed to find resource %s:%s: %v", resourceName, action, err)
 				}
 
-				if err := createResourcePermission(db, resource); err != nil {
-					return err
+				permission, isNew, err := createResourcePermission(db, resource)
+				if err != nil {
+					return nil, err
 				}
+				track(permission, isNew)
 			}
 		}
 	}
 
-	return nil
+	return created, nil
 }
 
-func createResourcePermission(db *gorm.DB, resource Resource) error {
+func createResourcePermission(db *gorm.DB, resource Resource) (*ResourcePermission, bool, error) {
 	scope := fmt.Sprintf("%s:%s", resource.Name, resource.Action)
 
 	permission := ResourcePermission{
@@ -132,41 +184,42 @@ func createResourcePermission(db *gorm.DB, resource Resource) error {
 		Scope:      scope,
 	}
 
-	if err := db.FirstOrCreate(&permission, ResourcePermission{
+	result := db.FirstOrCreate(&permission, ResourcePermission{
 		ResourceID: resource.ID,
 		Scope:      scope,
-	}).Error; err != nil {
-		return fmt.Errorf("failed to create permission %s: %v", scope, err)
+	})
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to create permission %s: %v", scope, result.Error)
 	}
 
-	return nil
+	return &permission, result.RowsAffected > 0, nil
 }
 
-// AssignDefaultPermissions assigns default permissions to a user based on their role
-func AssignDefaultPermissions(db *gorm.DB, user *User) error {
+// PermissionsForRole returns the resource permissions a role is granted by default
+func PermissionsForRole(db *gorm.DB, role UserRole) ([]ResourcePermission, error) {
 	var permissions []ResourcePermission
 
-	if user.Role == UserRoleAdmin {
+	if role == UserRoleAdmin {
 		// For admin, get all resource permissions
 		if err := db.Find(&permissions).Error; err != nil {
-			return fmt.Errorf("failed to fetch permissions: %v", err)
+			return nil, fmt.Errorf("failed to fetch permissions: %v", err)
 		}
 	} else {
 		// For other roles, get specific permissions based on rolePermissions mapping
-		rolePerm := rolePermissions[user.Role]
+		rolePerm := rolePermissions[role]
 		for _, permScope := range rolePerm {
 			if strings.HasSuffix(permScope, ":*") {
 				// Handle wildcard permissions
 				resourceName := strings.TrimSuffix(permScope, ":*")
 				var resources []Resource
 				if err := db.Where("name = ?", resourceName).Find(&resources).Error; err != nil {
-					return fmt.Errorf("failed to find resources for %s: %v", resourceName, err)
+					return nil, fmt.Errorf("failed to find resources for %s: %v", resourceName, err)
 				}
 
 				for _, resource := range resources {
 					var perm ResourcePermission
 					if err := db.Where("resource_id = ?", resource.ID).First(&perm).Error; err != nil {
-						return fmt.Errorf("failed to find permission for resource %s: %v", resource.Name, err)
+						return nil, fmt.Errorf("failed to find permission for resource %s: %v", resource.Name, err)
 					}
 					permissions = append(permissions, perm)
 				}
@@ -174,24 +227,34 @@ func AssignDefaultPermissions(db *gorm.DB, user *User) error {
 				// Handle specific permissions
 				parts := strings.Split(permScope, ":")
 				if len(parts) != 2 {
-					return fmt.Errorf("invalid permission scope format: %s", permScope)
+					return nil, fmt.Errorf("invalid permission scope format: %s", permScope)
 				}
 
 				resourceName, action := parts[0], parts[1]
 				var resource Resource
 				if err := db.Where("name = ? AND action = ?", resourceName, action).First(&resource).Error; err != nil {
-					return fmt.Errorf("failed to find resource %s:%s: %v", resourceName, action, err)
+					return nil, fmt.Errorf("failed to find resource %s:%s: %v", resourceName, action, err)
 				}
 
 				var perm ResourcePermission
 				if err := db.Where("resource_id = ?", resource.ID).First(&perm).Error; err != nil {
-					return fmt.Errorf("failed to find permission for resource %s: %v", resource.Name, err)
+					return nil, fmt.Errorf("failed to find permission for resource %s: %v", resource.Name, err)
 				}
 				permissions = append(permissions, perm)
 			}
 		}
 	}
 
+	return permissions, nil
+}
+
+// AssignDefaultPermissions assigns default permissions to a user based on their role
+func AssignDefaultPermissions(db *gorm.DB, user *User) error {
+	permissions, err := PermissionsForRole(db, user.Role)
+	if err != nil {
+		return err
+	}
+
 	// Create UserPermission entries in bulk
 	var userPerms []UserPermission
 	for _, perm := range permissions {
@@ -208,6 +271,14 @@ func AssignDefaultPermissions(db *gorm.DB, user *User) error {
 	return nil
 }
 
+// ResetPermissions replaces a user's permissions with the defaults of their current role
+func ResetPermissions(db *gorm.DB, user *User) error {
+	if err := db.Where("user_id = ?", user.ID).Delete(&UserPermission{}).Error; err != nil {
+		return fmt.Errorf("failed to remove user permissions: %v", err)
+	}
+	return AssignDefaultPermissions(db, user)
+}
+
 func CreateSuperAdminFromEnv(db *gorm.DB, cfg *config.Config) error {
 	role := UserRoleSuperAdmin
 
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionExpiryBackfill gives sessions created before expiries were recorded one, counted from their creation
+const SessionExpiryBackfill = `UPDATE auth_transactions SET expires_at = created_at + interval '7 days'
+	WHERE expires_at IS NULL OR expires_at < '1970-01-01'`
+
+// SessionTokenIDBackfill replaces the access tokens stored on sessions by an ID of them. Tokens
+// issued before access tokens carried a jti are named by their SHA-256, they are rejected for lacking
+// iss and aud anyway and their sessions continue with the next refresh.
+const SessionTokenIDBackfill = `DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'auth_transactions' AND column_name = 'token') THEN
+		UPDATE auth_transactions SET token_id = encode(sha256(convert_to(token, 'UTF8')), 'hex') WHERE token_id = '';
+		ALTER TABLE auth_transactions DROP COLUMN token;
+	END IF;
+END $$`
+
+// CreateSession stores a new auth transaction and, when the user now holds more than
+// maxSessions active sessions, revokes the oldest ones. maxSessions <= 0 disables the cap.
+// Impersonation sessions do not count towards the cap.
+func CreateSession(db *gorm.DB, session *AuthTransaction, maxSessions int) error {
+	now := time.Now().UTC()
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = now.Add(SessionLifetime)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 🔒 Serialise logins of the same user so concurrent ones cannot both slip under the cap
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("id = ?", session.UserID).First(&User{}).Error; err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+
+		if maxSessions <= 0 {
+			return nil
+		}
+
+		var revoked []string
+		if err := tx.Model(&AuthTransaction{}).
+			Where("user_id = ? AND expires_at > ? AND impersonated_by IS NULL AND is_deleted = false", session.UserID, now).
+			Order("created_at DESC").
+			Offset(maxSessions).
+			Pluck("id", &revoked).Error; err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(revoked) == 0 {
+			return nil
+		}
+
+		return tx.Model(&AuthTransaction{}).
+			Where("id IN ?", revoked).
+			Updates(map[string]interface{}{"is_deleted": true, "deleted_at": now}).Error
+	})
+}
+
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// HashRefreshToken hashes a refresh token for storage on its session
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RotateSession replaces the access token ID and refresh token hash of a session when presentedHash
+// matches its current refresh token, and extends it to expiresAt. A presented token that does not
+// match was rotated before and may have been stolen, so the session is revoked and
+// ErrRefreshTokenReused returned. Unknown, expired and revoked sessions return gorm.ErrRecordNotFound.
+func RotateSession(db *gorm.DB, sessionID, userID, presentedHash, tokenID, refreshHash string, expiresAt time.Time) (*AuthTransaction, error) {
+	now := time.Now().UTC()
+	var session AuthTransaction
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 🔒 Concurrent refreshes with the same token are serialised, the second one sees it rotated
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND expires_at > ? AND is_deleted = false", sessionID, userID, now).
+			First(&session).Error; err != nil {
+			return err
+		}
+
+		if session.Refresh != presentedHash {
+			// 🚨 The revocation is committed, the reuse is reported after the transaction
+			reused = true
+			return tx.Model(&session).
+				Updates(map[string]interface{}{"is_deleted": true, "deleted_at": now}).Error
+		}
+
+		session.TokenID = tokenID
+		session.Refresh = refreshHash
+		session.ExpiresAt = expiresAt
+		return tx.Model(&session).
+			Updates(map[string]interface{}{"token_id": tokenID, "refresh": refreshHash, "expires_at": expiresAt}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return &session, ErrRefreshTokenReused
+	}
+	return &session, nil
+}
+
+// ListSessions returns a page of the user's active sessions, newest first, with the total count
+func ListSessions(db *gorm.DB, userID string, page, limit int) ([]AuthTransaction, int64, error) {
+	query := db.Model(&AuthTransaction{}).
+		Where("user_id = ? AND expires_at > ? AND is_deleted = false", userID, time.Now().UTC())
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var sessions []AuthTransaction
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&sessions).Error; err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
+
+// RevokeSession revokes one active session of the user. The access token stops working on
+// its next request because the auth middleware no longer finds the session. It reports
+// whether a session was revoked.
+func RevokeSession(db *gorm.DB, sessionID, userID string) (bool, error) {
+	result := db.Model(&AuthTransaction{}).
+		Where("id = ? AND user_id = ? AND expires_at > ? AND is_deleted = false", sessionID, userID, time.Now().UTC()).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// validDeviceID matches device IDs clients may send, e.g. a UUID
+var validDeviceID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// DeviceID identifies the device a session is created on. Clients name it with the
+// X-Device-Id header, without a usable one it is derived from the user agent and IP address.
+func DeviceID(header, userAgent, ipAddress string) string {
+	if validDeviceID.MatchString(header) {
+		return header
+	}
+	sum := sha256.Sum256([]byte(userAgent + "|" + ipAddress))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RevokeDeviceSessions revokes all active sessions of the user on a device and returns how many were revoked
+func RevokeDeviceSessions(db *gorm.DB, userID, deviceID string) (int64, error) {
+	result := db.Model(&AuthTransaction{}).
+		Where("user_id = ? AND device_id = ? AND expires_at > ? AND is_deleted = false", userID, deviceID, time.Now().UTC()).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeUserSessions revokes all active sessions of the user and returns how many were revoked
+func RevokeUserSessions(db *gorm.DB, userID string) (int64, error) {
+	result := db.Model(&AuthTransaction{}).
+		Where("user_id = ? AND expires_at > ? AND is_deleted = false", userID, time.Now().UTC()).
+		Updates(map[string]interface{}{"is_deleted": true, "deleted_at": time.Now().UTC()})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// activeSessions lists the IDs of the user's sessions that are still usable, oldest first
+func activeSessions(t *testing.T, gdb *gorm.DB, userID string) []string {
+	t.Helper()
+	var ids []string
+	if err := gdb.Model(&models.AuthTransaction{}).
+		Where("user_id = ? AND expires_at > ? AND is_deleted = false", userID, time.Now().UTC()).
+		Order("created_at").Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestCreateSessionRevokesTheOldestBeyondTheCap(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+	start := time.Now().UTC().Add(-time.Hour)
+
+	session := func(minutes int, change func(*models.AuthTransaction)) *models.AuthTransaction {
+		s := &models.AuthTransaction{Base: models.Base{CreatedAt: models.NewTimestamp(start.Add(time.Duration(minutes) * time.Minute))}, UserID: user.ID, TeamID: team.ID}
+		if change != nil {
+			change(s)
+		}
+		if err := models.CreateSession(gdb, s, 2); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// ⏳ Expired and impersonation sessions do not take up a slot
+	session(0, func(s *models.AuthTransaction) { s.ExpiresAt = time.Now().UTC().Add(-time.Minute) })
+	impersonation := session(1, func(s *models.AuthTransaction) { s.ImpersonatedBy = &admin.ID })
+	first := session(2, nil)
+	second := session(3, nil)
+	if first.ExpiresAt.Sub(time.Now().UTC().Add(models.SessionLifetime)).Abs() > time.Minute {
+		t.Errorf("session expires at %s, want in %s", first.ExpiresAt, models.SessionLifetime)
+	}
+	if got := activeSessions(t, gdb, user.ID); len(got) != 3 {
+		t.Fatalf("%d active sessions at the cap, want 3", len(got))
+	}
+
+	third := session(4, nil)
+	got := activeSessions(t, gdb, user.ID)
+	want := []string{impersonation.ID, second.ID, third.ID}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("active sessions %v, want %v without the oldest", got, want)
+	}
+}
+
+func TestCreateSessionWithoutACap(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	for range 5 {
+		if err := models.CreateSession(gdb, &models.AuthTransaction{UserID: user.ID, TeamID: team.ID}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := activeSessions(t, gdb, user.ID); len(got) != 5 {
+		t.Errorf("%d active sessions, want all 5", len(got))
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxTeamSlugBase is the longest slug derived from a name, leaving room for a suffix
+const maxTeamSlugBase = 60
+
+// TeamSlugBackfill gives the teams created before slugs one derived from their name. Names
+// sharing a slug get the start of their ID appended, except the oldest team.
+const TeamSlugBackfill = `WITH derived AS (
+	SELECT id, created_at, COALESCE(NULLIF(trim(BOTH '-' FROM left(
+		lower(regexp_replace(regexp_replace(name, '[''’]', '', 'g'), '[^a-zA-Z0-9]+', '-', 'g')), 60)), ''), 'team') AS slug
+	FROM teams WHERE slug IS NULL
+), numbered AS (
+	SELECT id, slug, row_number() OVER (PARTITION BY slug ORDER BY created_at, id) AS n FROM derived
+)
+UPDATE teams SET slug = CASE WHEN numbered.n = 1 THEN numbered.slug ELSE numbered.slug || '-' || left(teams.id::text, 8) END
+FROM numbered WHERE teams.id = numbered.id`
+
+// TeamSlug derives the slug of a team name: lowercase ASCII letters and digits, other runs of
+// characters replaced by a dash. Apostrophes are dropped, so "Sam's Team" becomes sams-team.
+func TeamSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '\'' || r == '’':
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+		if b.Len() >= maxTeamSlugBase {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "team"
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NextTeamSlug returns the slug of name, or the first of its -2, -3... suffixed forms that no
+// team, deleted ones included, holds yet. A concurrent create can still take it first, the
+// unique index then rejects the second one.
+func NextTeamSlug(db *gorm.DB, name string) (string, error) {
+	base := TeamSlug(name)
+	var taken []string
+	if err := db.Session(&gorm.Session{NewDB: true}).Model(&Team{}).
+		Where("slug = ? OR slug LIKE ?", base, base+"-%").Pluck("slug", &taken).Error; err != nil {
+		return "", err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+	if !used[base] {
+		return base, nil
+	}
+	for n := 2; ; n++ {
+		if slug := base + "-" + strconv.Itoa(n); !used[slug] {
+			return slug, nil
+		}
+	}
+}
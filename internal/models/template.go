@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"be0/internal/sanitize"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Template is a team's HTML email template. Its HTML is sanitized and linted on every save.
+type Template struct {
+	Base
+	TeamID     string                      `gorm:"type:uuid;not null;index" json:"teamId" validate:"required,uuid"`
+	Team       *Team                       `json:"team,omitempty"`
+	Name       string                      `gorm:"not null" json:"name" validate:"required"`
+	Subject    string                      `gorm:"not null" json:"subject" validate:"required"`
+	HTML       string                      `gorm:"type:text;not null" json:"html" validate:"required"`
+	DesignJSON datatypes.JSON              `gorm:"type:jsonb" json:"designJson,omitempty"`
+	Variables  datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"variables"`
+	Warnings   []sanitize.Issue            `gorm:"-" json:"warnings,omitempty"` // Virtual field, lint findings of the last save
+}
+
+// ErrTemplateRejected marks templates whose content failed sanitization
+var ErrTemplateRejected = errors.New("template rejected")
+
+// TemplateRejection carries the findings of a rejected template
+type TemplateRejection struct {
+	Errors   []sanitize.Issue `json:"errors"`
+	Warnings []sanitize.Issue `json:"warnings,omitempty"`
+}
+
+func (e *TemplateRejection) Error() string {
+	return fmt.Sprintf("%v: %d error(s)", ErrTemplateRejected, len(e.Errors))
+}
+
+func (e *TemplateRejection) Unwrap() error {
+	return ErrTemplateRejected
+}
+
+func (t *Template) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeSave sanitizes the HTML and rejects templates with hard errors. Updates
+// without HTML leave the stored template untouched and are not checked.
+func (t *Template) BeforeSave(tx *gorm.DB) error {
+	t.Warnings = nil
+	if t.HTML == "" || SkipsSanitize(tx.Statement.Context) {
+		return nil
+	}
+
+	report := sanitize.Template(t.HTML, t.Variables)
+	if !report.OK() {
+		return &TemplateRejection{Errors: report.Errors, Warnings: report.Warnings}
+	}
+	t.HTML = report.HTML
+	t.Warnings = report.Warnings
+	return nil
+}
@@ -0,0 +1,55 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestTemplatesAreSanitizedOnSave(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	unsafe := `<p>Hi {{name}}</p><script>alert(1)</script>`
+
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: unsafe, Variables: []string{"name"}}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	var stored models.Template
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.HTML != `<p>Hi {{name}}</p>` || len(template.Warnings) != 1 || template.Warnings[0].Rule != "removed_element" {
+		t.Errorf("stored %q with warnings %v", stored.HTML, template.Warnings)
+	}
+
+	// 🚫 Hard errors reject the save and leave the stored template alone
+	update := &models.Template{Base: models.Base{ID: template.ID}, HTML: "<p>{{token}}</p>"}
+	err := gdb.Model(update).Updates(update).Error
+	var rejection *models.TemplateRejection
+	if !errors.Is(err, models.ErrTemplateRejected) || !errors.As(err, &rejection) || rejection.Errors[0].Rule != "undeclared_variable" {
+		t.Fatalf("got %v, want an undeclared_variable rejection", err)
+	}
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.HTML != `<p>Hi {{name}}</p>` {
+		t.Errorf("a rejected update changed the HTML to %q", stored.HTML)
+	}
+
+	// 🔓 Skipping sanitization stores the HTML as submitted
+	raw := &models.Template{TeamID: team.ID, Name: "Raw", Subject: "Hi", HTML: unsafe}
+	if err := gdb.WithContext(models.WithSkipSanitize(context.Background())).Create(raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	var storedRaw models.Template
+	if err := gdb.First(&storedRaw, "id = ?", raw.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if storedRaw.HTML != unsafe {
+		t.Errorf("skip sanitize stored %q", storedRaw.HTML)
+	}
+}
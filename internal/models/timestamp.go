@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// Timestamp is a time that is always held in UTC and serialised as RFC3339, whatever
+// the time zone of the server or of the database session
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp converts t to UTC
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t.UTC()}
+}
+
+// GormDataType maps Timestamp to the same column type as time.Time, so GORM keeps
+// filling CreatedAt and UpdatedAt automatically
+func (Timestamp) GormDataType() string {
+	return string(schema.Time)
+}
+
+// Scan implements sql.Scanner
+func (t *Timestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v.UTC()
+	case nil:
+		t.Time = time.Time{}
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC(), nil
+}
+
+// MarshalJSON writes the time as RFC3339 in UTC
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.UTC().Format(time.RFC3339) + `"`), nil
+}
+
+// UnmarshalJSON accepts RFC3339 with any offset and converts it to UTC
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" || string(data) == `""` {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(`"`+time.RFC3339+`"`, string(data))
+	if err != nil {
+		return fmt.Errorf("invalid RFC3339 time: %w", err)
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
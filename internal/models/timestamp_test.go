@@ -0,0 +1,114 @@
+package models_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestTimestampMarshalsRFC3339InUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+
+	local := time.Date(2026, 3, 1, 9, 30, 0, 0, time.Local)
+	team := models.Team{Base: models.Base{CreatedAt: models.NewTimestamp(local), UpdatedAt: models.Timestamp{Time: local}}}
+	data, err := json.Marshal(team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"createdAt":"2026-03-01T04:00:00Z"`, `"updatedAt":"2026-03-01T04:00:00Z"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s missing %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), "deletedAt") {
+		t.Errorf("%s exposes deletedAt", data)
+	}
+}
+
+func TestTimestampJSON(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: `"2026-03-01T09:30:00+05:30"`, want: time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{in: `"2026-03-01T04:00:00Z"`, want: time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)},
+		{in: `null`},
+		{in: `""`},
+		{in: `"2026-03-01 04:00:00"`, wantErr: true},
+		{in: `"yesterday"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var ts models.Timestamp
+		err := json.Unmarshal([]byte(tt.in), &ts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !ts.Equal(tt.want) || ts.Location() != time.UTC {
+			t.Errorf("%s: got %v, want %v in UTC", tt.in, ts.Time, tt.want)
+		}
+	}
+
+	zero, err := json.Marshal(models.Timestamp{})
+	if err != nil || string(zero) != "null" {
+		t.Errorf("zero timestamp marshals to %s, %v", zero, err)
+	}
+}
+
+func TestTimestampScanAndValueUseUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+
+	var ts models.Timestamp
+	local := time.Date(2026, 3, 1, 9, 30, 0, 0, time.Local)
+	if err := ts.Scan(local); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Location() != time.UTC || !ts.Equal(local) {
+		t.Errorf("scanned %v, want %v in UTC", ts.Time, local.UTC())
+	}
+
+	value, err := models.NewTimestamp(local).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := value.(time.Time); !ok || v.Location() != time.UTC {
+		t.Errorf("value %v is not a UTC time", value)
+	}
+
+	if err := ts.Scan(nil); err != nil || !ts.IsZero() {
+		t.Errorf("scanning NULL gave %v, %v", ts.Time, err)
+	}
+	if value, err := (models.Timestamp{}).Value(); err != nil || value != nil {
+		t.Errorf("zero timestamp stored as %v, %v, want NULL", value, err)
+	}
+	if err := ts.Scan("2026-03-01"); err == nil {
+		t.Error("expected an error scanning a string")
+	}
+}
+
+func TestTimestampsAreStoredInUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+	gdb := testutil.NewDB(t)
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	var stored models.Team
+	if err := gdb.First(&stored, "id = ?", team.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.CreatedAt.Location() != time.UTC || stored.UpdatedAt.Location() != time.UTC {
+		t.Errorf("read back %v and %v, want UTC", stored.CreatedAt.Time, stored.UpdatedAt.Time)
+	}
+	if stored.DeletedAt != nil || stored.IsDeleted {
+		t.Errorf("new team is deleted: deletedAt %v, isDeleted %v", stored.DeletedAt, stored.IsDeleted)
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RecoveryCodeCount is how many one-time recovery codes a user gets when enabling 2FA
+	RecoveryCodeCount = 10
+	// TwoFactorMaxFailures wrong codes in a row lock the second factor for TwoFactorLockout
+	TwoFactorMaxFailures = 5
+	TwoFactorLockout     = 15 * time.Minute
+)
+
+// TwoFactorRecoveryCode is a one-time code that replaces a TOTP code, stored hashed
+type TwoFactorRecoveryCode struct {
+	Base
+	UserID   string     `gorm:"type:uuid;not null;index" json:"userId"`
+	CodeHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	UsedAt   *time.Time `json:"usedAt,omitempty"`
+}
+
+// hashRecoveryCode normalises and hashes a code, codes are random so a fast hash suffices
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReplaceRecoveryCodes removes a user's recovery codes and stores the hashes of new ones
+func ReplaceRecoveryCodes(tx *gorm.DB, userID string, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&TwoFactorRecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	rows := make([]TwoFactorRecoveryCode, len(codes))
+	for i, code := range codes {
+		rows[i] = TwoFactorRecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	return tx.Create(&rows).Error
+}
+
+// ConsumeRecoveryCode marks a recovery code as used, it reports false when the code is
+// unknown or was used before
+func ConsumeRecoveryCode(db *gorm.DB, userID, code string) (bool, error) {
+	result := db.Model(&TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL AND is_deleted = false", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ResetTwoFactor turns 2FA off for a user and removes their secret and recovery codes
+func ResetTwoFactor(tx *gorm.DB, userID string) error {
+	if err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":        false,
+		"two_factor_secret":         "",
+		"two_factor_pending_secret": "",
+		"two_factor_last_step":      0,
+		"two_factor_failures":       0,
+		"two_factor_locked_until":   nil,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&TwoFactorRecoveryCode{}).Error
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"slices"
+
+	"gorm.io/datatypes"
+)
+
+// WebhookSubscription delivers a team's events to an HTTPS endpoint.
+// Deliveries are signed with Secret, which is only shown when the subscription is created.
+type WebhookSubscription struct {
+	Base
+	TeamID string                      `gorm:"type:uuid;not null;index" json:"teamId"`
+	Team   *Team                       `json:"team,omitempty"`
+	URL    string                      `gorm:"not null" json:"url" redact:"scope=webhooks:read_secrets,mask=url"`
+	Secret string                      `gorm:"not null" json:"-"`
+	Events datatypes.JSONSlice[string] `gorm:"type:jsonb;not null" json:"events"`
+	Active bool                        `gorm:"not null;default:true" json:"active"`
+}
+
+// Subscribes reports whether the subscription wants the given event
+func (w *WebhookSubscription) Subscribes(event string) bool {
+	return slices.Contains(w.Events, event)
+}
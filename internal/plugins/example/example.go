@@ -0,0 +1,73 @@
+// Package example is a plugin showing the extension hooks: it adds GET /api/v1/example/hello
+// and logs every new user. Copy it next to it to start a plugin, and import the copy from
+// cmd/plugins_enabled.go.
+package example
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/plugins"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Name is the name the plugin registers under
+const Name = "example"
+
+func init() {
+	plugins.Register(&Plugin{})
+}
+
+// Plugin greets signed-in callers and counts the users created since the process started
+type Plugin struct {
+	plugins.Base
+	log     *logger.Logger
+	created atomic.Int64
+}
+
+func (p *Plugin) Name() string { return Name }
+
+func (p *Plugin) Init(*config.Config, *gorm.DB) error {
+	p.log = logger.New("plugin:" + Name)
+	return nil
+}
+
+func (p *Plugin) Routes(g *echo.Group) {
+	g.GET("/example/hello", p.hello)
+}
+
+func (p *Plugin) Events(bus *events.EventBus) {
+	bus.OnNamed("users.created", "example.user_created", func(data interface{}) {
+		data, dryRun := events.Unwrap(data)
+		user, ok := data.(*models.User)
+		if !ok {
+			if dryRun != nil {
+				dryRun.Fail(fmt.Errorf("expected a *models.User, got %T", data))
+			}
+			return
+		}
+		if dryRun != nil {
+			return
+		}
+		p.created.Add(1)
+		p.log.Info("Welcome %s to team %s", user.ID, user.TeamID)
+	})
+}
+
+// hello answers the caller, the auth middleware already identified them
+func (p *Plugin) hello(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":      "Hello from the example plugin",
+		"userId":       middleware.GetUserID(c),
+		"teamId":       middleware.GetTeamID(c),
+		"usersCreated": p.created.Load(),
+	})
+}
@@ -0,0 +1,50 @@
+package example
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestHelloCountsCreatedUsers(t *testing.T) {
+	p := &Plugin{}
+	if err := p.Init(&config.Config{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	bus := events.NewEventBus()
+	p.Events(bus)
+	bus.Emit("users.created", &models.User{Base: models.Base{ID: "5574fee5-3ce4-49e5-af2e-21361fc433e4"}})
+
+	// Handlers run in the background
+	for deadline := time.Now().Add(time.Second); p.created.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	e := echo.New()
+	signedIn := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("userID", "5574fee5-3ce4-49e5-af2e-21361fc433e4")
+			return next(c)
+		}
+	}
+	group := e.Group("/api/v1", signedIn)
+	p.Routes(group)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/example/hello", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || body["usersCreated"] != float64(1) || body["userId"] == "" {
+		t.Errorf("status %d %v, want the caller and one created user", rec.Code, body)
+	}
+}
@@ -0,0 +1,112 @@
+// Package plugins lets a deployment compile in its own routes, event subscribers and
+// tasks without forking. A plugin registers itself from an init function and is linked
+// in by importing its package from cmd/plugins_enabled.go, built with -tags plugins.
+//
+// The lifecycle is Init when the process starts, then Events, then Routes on the API
+// and Tasks on the worker. Embed Base to implement only the hooks a plugin needs.
+package plugins
+
+import (
+	"fmt"
+	"sync"
+
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var log = logger.New("plugins")
+
+// Plugin extends the app with deployment-specific behavior
+type Plugin interface {
+	// Name identifies the plugin in logs, it must be unique
+	Name() string
+	// Init prepares the plugin, an error stops the process from starting
+	Init(cfg *config.Config, db *gorm.DB) error
+	// Routes adds routes to the /api/v1 group, behind the auth middleware like every API route
+	Routes(g *echo.Group)
+	// Events subscribes to the event bus
+	Events(bus *events.EventBus)
+	// Tasks handles task types on the worker
+	Tasks(mux *asynq.ServeMux)
+}
+
+// Base implements every hook of Plugin as a no-op
+type Base struct{}
+
+func (Base) Init(*config.Config, *gorm.DB) error { return nil }
+func (Base) Routes(*echo.Group)                  {}
+func (Base) Events(*events.EventBus)             {}
+func (Base) Tasks(*asynq.ServeMux)               {}
+
+var (
+	registered []Plugin
+	mu         sync.RWMutex
+)
+
+// Register adds a plugin, call it from the init function of the plugin's package.
+// It panics when a plugin of the same name is registered.
+func Register(p Plugin) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, existing := range registered {
+		if existing.Name() == p.Name() {
+			panic(fmt.Sprintf("plugins: %s is registered twice", p.Name()))
+		}
+	}
+	registered = append(registered, p)
+}
+
+// Unregister removes a plugin, for tests
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, p := range registered {
+		if p.Name() == name {
+			registered = append(registered[:i:i], registered[i+1:]...)
+			return
+		}
+	}
+}
+
+// Registered lists the plugins in the order they registered
+func Registered() []Plugin {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Plugin(nil), registered...)
+}
+
+// Start initializes every plugin and subscribes them to the default event bus
+func Start(cfg *config.Config, db *gorm.DB) error {
+	plugins := Registered()
+	for _, p := range plugins {
+		if err := p.Init(cfg, db); err != nil {
+			return fmt.Errorf("failed to initialize plugin %s: %w", p.Name(), err)
+		}
+	}
+	for _, p := range plugins {
+		p.Events(events.Default())
+	}
+	if len(plugins) > 0 {
+		log.Success("Started %d plugins", len(plugins))
+	}
+	return nil
+}
+
+// RegisterRoutes adds the routes of every plugin to the API group
+func RegisterRoutes(g *echo.Group) {
+	for _, p := range Registered() {
+		p.Routes(g)
+	}
+}
+
+// RegisterTasks adds the task handlers of every plugin to the worker
+func RegisterTasks(mux *asynq.ServeMux) {
+	for _, p := range Registered() {
+		p.Tasks(mux)
+	}
+}
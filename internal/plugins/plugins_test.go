@@ -0,0 +1,109 @@
+package plugins
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/config"
+	"be0/internal/events"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// recorder is a plugin recording the hooks it was called with
+type recorder struct {
+	Base
+	name    string
+	initErr error
+	calls   []string
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Init(*config.Config, *gorm.DB) error {
+	r.calls = append(r.calls, "init")
+	return r.initErr
+}
+
+func (r *recorder) Routes(g *echo.Group) {
+	r.calls = append(r.calls, "routes")
+	g.GET("/"+r.name, func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+}
+
+func (r *recorder) Events(*events.EventBus) {
+	r.calls = append(r.calls, "events")
+}
+
+func (r *recorder) Tasks(mux *asynq.ServeMux) {
+	r.calls = append(r.calls, "tasks")
+}
+
+// register registers the plugin for the test
+func register(t *testing.T, p Plugin) {
+	t.Helper()
+	Register(p)
+	t.Cleanup(func() { Unregister(p.Name()) })
+}
+
+func TestLifecycle(t *testing.T) {
+	p := &recorder{name: "recorder"}
+	register(t, p)
+
+	if err := Start(&config.Config{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	RegisterRoutes(e.Group("/api/v1"))
+	RegisterTasks(asynq.NewServeMux())
+
+	want := []string{"init", "events", "routes", "tasks"}
+	if len(p.calls) != len(want) {
+		t.Fatalf("calls %v, want %v", p.calls, want)
+	}
+	for i := range want {
+		if p.calls[i] != want[i] {
+			t.Fatalf("calls %v, want %v", p.calls, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/recorder", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("plugin route answered %d, want 204", rec.Code)
+	}
+}
+
+func TestStartFailsWhenAPluginFailsToInitialize(t *testing.T) {
+	failing := &recorder{name: "failing", initErr: errors.New("missing license key")}
+	register(t, failing)
+
+	if err := Start(&config.Config{}, nil); !errors.Is(err, failing.initErr) {
+		t.Fatalf("Start returned %v, want the init error", err)
+	}
+	if len(failing.calls) != 1 {
+		t.Errorf("calls %v after a failed init, want no events", failing.calls)
+	}
+}
+
+func TestRegisterRejectsDuplicateNames(t *testing.T) {
+	register(t, &recorder{name: "twice"})
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	Register(&recorder{name: "twice"})
+}
+
+func TestUnregister(t *testing.T) {
+	Register(&recorder{name: "first"})
+	register(t, &recorder{name: "second"})
+	Unregister("first")
+	if plugins := Registered(); len(plugins) != 1 || plugins[0].Name() != "second" {
+		t.Errorf("registered %v after unregistering first", plugins)
+	}
+}
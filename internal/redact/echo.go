@@ -0,0 +1,28 @@
+package redact
+
+import (
+	"net/http"
+
+	"be0/internal/api/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ForCaller checks scopes against the caller of a request. Admins see everything.
+func ForCaller(c echo.Context) Allowed {
+	if hasAdmin, ok := c.Get("hasAdminAccess").(bool); ok && hasAdmin {
+		return func(string) bool { return true }
+	}
+	return func(scope string) bool {
+		return middleware.HasPermission(c, scope)
+	}
+}
+
+// JSON sends v as JSON with the fields the caller may not see redacted
+func JSON(c echo.Context, status int, v interface{}) error {
+	redacted, err := Value(v, ForCaller(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encode response")
+	}
+	return c.JSON(status, redacted)
+}
@@ -0,0 +1,261 @@
+// Package redact hides response fields from callers without the scope to see them.
+// Fields opt in with a struct tag such as `redact:"scope=users:read_pii,mask=email"`;
+// without a mask the field is replaced with null.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Masks for redacted fields
+const (
+	MaskNull  = "null"  // null
+	MaskEmail = "email" // j***@example.com
+	MaskURL   = "url"   // https://example.com/***
+)
+
+// Allowed reports whether the caller holds a scope
+type Allowed func(scope string) bool
+
+// rule is a parsed redact tag
+type rule struct {
+	scope string
+	mask  string
+}
+
+func parseTag(tag string) (rule, error) {
+	r := rule{mask: MaskNull}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "scope":
+			r.scope = value
+		case "mask":
+			r.mask = value
+		default:
+			return r, fmt.Errorf("unknown redact option %q", key)
+		}
+	}
+	if r.scope == "" {
+		return r, fmt.Errorf("redact tag %q has no scope", tag)
+	}
+	switch r.mask {
+	case MaskNull, MaskEmail, MaskURL:
+	default:
+		return r, fmt.Errorf("unknown redact mask %q", r.mask)
+	}
+	return r, nil
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// hasRules caches whether values of a type can carry redacted fields
+var hasRules sync.Map // reflect.Type -> bool
+
+// needsRedaction reports whether values of t can contain redact tags, so responses
+// without any skip the JSON round trip
+func needsRedaction(t reflect.Type) bool {
+	if cached, ok := hasRules.Load(t); ok {
+		return cached.(bool)
+	}
+	needs := inspect(t, map[reflect.Type]bool{})
+	hasRules.Store(t, needs)
+	return needs
+}
+
+// inspect walks the fields reachable from t. Types already being inspected count as
+// having no rules, their other fields are checked by the caller further up.
+func inspect(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if cached, ok := hasRules.Load(t); ok {
+		return cached.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		// The dynamic type is only known from the value
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return inspect(t.Elem(), visiting)
+	case reflect.Struct:
+		if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+			return false
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup("redact"); ok || inspect(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Value returns v as it should be serialised for a caller. Values whose types carry no
+// redact tags are returned unchanged, others are converted to their generic JSON form with
+// the fields the caller may not see masked.
+func Value(v interface{}, allowed Allowed) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !needsRedaction(rv.Type()) {
+		return v, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	// Keep numbers exact, int64 IDs and sizes would lose precision as float64
+	decoder.UseNumber()
+	var out interface{}
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	if err := walk(rv, out, allowed); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// walk masks the fields of out, the decoded JSON of rv, that the caller may not see
+func walk(rv reflect.Value, out interface{}, allowed Allowed) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if out == nil || !needsRedaction(rv.Type()) {
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		obj, ok := out.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return walkStruct(rv, obj, allowed)
+	case reflect.Slice, reflect.Array:
+		arr, ok := out.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i := 0; i < rv.Len() && i < len(arr); i++ {
+			if err := walk(rv.Index(i), arr[i], allowed); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, ok := out.(map[string]interface{})
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := walk(iter.Value(), obj[iter.Key().String()], allowed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func walkStruct(rv reflect.Value, obj map[string]interface{}, allowed Allowed) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded structs without a JSON name are flattened into the parent
+		if f.Anonymous && name == "" {
+			if err := walk(rv.Field(i), obj, allowed); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		value, present := obj[name]
+		if !present {
+			continue
+		}
+
+		if tag, ok := f.Tag.Lookup("redact"); ok {
+			r, err := parseTag(tag)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+			if !allowed(r.scope) {
+				obj[name] = mask(value, r.mask)
+				continue
+			}
+		}
+
+		if err := walk(rv.Field(i), value, allowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Nullable reports whether a field is replaced with null for callers without its scope
+func Nullable(field reflect.StructField) bool {
+	tag, ok := field.Tag.Lookup("redact")
+	if !ok {
+		return false
+	}
+	r, err := parseTag(tag)
+	return err == nil && r.mask == MaskNull
+}
+
+// mask hides a value, keeping enough of strings to recognise them
+func mask(value interface{}, kind string) interface{} {
+	s, ok := value.(string)
+	if !ok || kind == MaskNull {
+		return nil
+	}
+	if s == "" {
+		return s
+	}
+
+	switch kind {
+	case MaskEmail:
+		local, domain, found := strings.Cut(s, "@")
+		if !found || local == "" {
+			return "***"
+		}
+		first, _ := utf8.DecodeRuneInString(local)
+		return string(first) + "***@" + domain
+	case MaskURL:
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return "***"
+		}
+		return u.Scheme + "://" + u.Host + "/***"
+	default:
+		return nil
+	}
+}
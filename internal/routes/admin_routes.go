@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/handlers"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupAdminRoutes(api *echo.Group, db *gorm.DB, cfg *config.Config, debugStore *middleware.DebugCaptureStore) {
+	log := logger.New("admin_routes")
+
+	debugHandler := handlers.NewDebugHandler(debugStore)
+	recoveryHandler := handlers.NewRecoveryHandler(db)
+	configHandler := handlers.NewConfigHandler(cfg)
+	eventBusHandler := handlers.NewEventBusHandler()
+	impersonationHandler := handlers.NewImpersonationHandler(db)
+	taskClient := tasks.NewTaskClient(cfg.Redis)
+	bulkUserHandler := handlers.NewBulkUserHandler(db, taskClient)
+	auditExportHandler := handlers.NewAuditExportHandler(db, taskClient)
+	teamHandler := handlers.NewTeamHandler(db)
+
+	adminGroup := api.Group("/admin", middleware.RequireSuperAdmin())
+
+	adminGroup.GET("/requests/:requestId", debugHandler.GetCapturedRequest)
+	adminGroup.GET("/config", configHandler.GetRuntimeConfig)
+
+	// Event bus introspection
+	adminGroup.GET("/events", eventBusHandler.ListEvents)
+	adminGroup.POST("/events/:event/test", eventBusHandler.TestEvent)
+
+	// Impersonation. Ending it is done with the impersonation token, which carries the user's role
+	adminGroup.POST("/impersonate/:userId", impersonationHandler.Impersonate, middleware.ValidateUUIDParams())
+	api.DELETE("/admin/impersonate", impersonationHandler.EndImpersonation)
+
+	// Account recovery, approved by a second super admin
+	adminGroup.POST("/users/:id/recovery", recoveryHandler.CreateRecovery)
+	adminGroup.GET("/recovery", recoveryHandler.ListRecoveries)
+	adminGroup.POST("/recovery/:requestId/approve", recoveryHandler.ApproveRecovery)
+	adminGroup.POST("/recovery/:requestId/cancel", recoveryHandler.CancelRecovery)
+
+	// Bulk actions on users, e.g. during a credential stuffing incident
+	adminGroup.POST("/users/bulk", bulkUserHandler.BulkUserAction)
+
+	// Deleted teams are restored with everything their deletion deactivated
+	adminGroup.POST("/teams/:id/restore", teamHandler.RestoreTeam, middleware.ValidateUUIDParams())
+
+	// Audit log exports to SIEMs
+	adminGroup.GET("/audit-exports", auditExportHandler.ListAuditExports)
+	adminGroup.POST("/audit-exports", auditExportHandler.CreateAuditExport)
+	adminGroup.DELETE("/audit-exports/:id", auditExportHandler.DeleteAuditExport, middleware.ValidateUUIDParams())
+	adminGroup.POST("/audit-export/run", auditExportHandler.RunAuditExports)
+
+	log.Success("Admin routes initialized successfully")
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupAPIKeyRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("api_key_routes")
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+
+	// API keys cannot mint or widen other keys
+	apiKeyGroup := api.Group("/api-keys", middleware.RejectAPIKeys(), middleware.RequirePermissions(db, "api_keys:write"), middleware.ValidateUUIDParams())
+	apiKeyGroup.GET("", apiKeyHandler.ListAPIKeys)
+	apiKeyGroup.POST("", apiKeyHandler.CreateAPIKey)
+	apiKeyGroup.GET("/:id", apiKeyHandler.GetAPIKey)
+	apiKeyGroup.GET("/:id/analytics", apiKeyHandler.GetAPIKeyAnalytics)
+	apiKeyGroup.PUT("/:id", apiKeyHandler.UpdateAPIKey)
+	apiKeyGroup.DELETE("/:id", apiKeyHandler.DeleteAPIKey)
+
+	log.Success("API key routes initialized successfully")
+}
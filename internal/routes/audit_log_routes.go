@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupAuditLogRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("audit_log_routes")
+
+	auditLogHandler := handlers.NewAuditLogHandler(db)
+
+	// Admins only, checked by the handler so super admins can list other teams
+	api.GET("/audit-logs", auditLogHandler.ListAuditLogs)
+
+	log.Success("Audit log routes initialized successfully")
+}
@@ -1,16 +1,31 @@
 package routes
 
 import (
+	"time"
+
 	"be0/internal/api/middleware"
+	"be0/internal/captcha"
 	"be0/internal/config"
 	"be0/internal/handlers"
+	"be0/internal/lockout"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
 
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func SetupAuthRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config) {
-	authHandler := handlers.NewAuthHandler(db)
+func SetupAuthRoutes(e *echo.Echo, db *gorm.DB, redisClient *redis.Client, cfg *config.Config) {
+	taskClient := tasks.NewTaskClient(cfg.Redis)
+	authHandler := handlers.NewAuthHandler(db, cfg.JWT, cfg.Auth,
+		lockout.NewLimiter(redisClient, "login", cfg.Auth.MaxLoginAttempts, time.Duration(cfg.Auth.LockoutWindow)*time.Minute),
+		lockout.NewLimiter(redisClient, "password_reset", cfg.Auth.MaxPasswordResets, time.Hour),
+		newCaptchaGuard(cfg.Auth),
+	)
+	recoveryHandler := handlers.NewRecoveryHandler(db)
+	profileHandler := handlers.NewProfileHandler(db, taskClient)
+	sessionHandler := handlers.NewSessionHandler(db)
 
 	base := e.Group("/api/v1")
 
@@ -22,28 +37,78 @@ func SetupAuthRoutes(e *echo.Echo, db *gorm.DB, cfg *config.Config) {
 	auth.POST("/register", authHandler.Register)
 	auth.POST("/login", authHandler.Login)
 	auth.GET("/google/callback", authHandler.GoogleAuthCallback)
+	auth.GET("/microsoft/callback", authHandler.MicrosoftAuthCallback)
 
 	auth.POST("/accept/:code", authHandler.AcceptInvite)
 	auth.POST("/password-reset", authHandler.RequestPasswordReset)
 	auth.POST("/password-reset/verify", authHandler.VerifyResetCode)
+	auth.POST("/verify-email", authHandler.VerifyEmail)
+	auth.POST("/verify-email/resend", authHandler.ResendVerification)
 	auth.POST("/refresh", authHandler.RefreshToken)
+	auth.POST("/recovery/redeem", recoveryHandler.RedeemRecovery)
+
+	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.Secret)
+
+	// Two-factor authentication, verify and enroll complete a login and are public
+	twoFactor := auth.Group("/2fa")
+	twoFactor.POST("/verify", authHandler.VerifyTwoFactor)
+	twoFactor.POST("/enroll", authHandler.EnrollTwoFactor)
+	twoFactor.POST("/enroll/confirm", authHandler.ConfirmTwoFactorEnrollment)
+	twoFactor.POST("/setup", authHandler.SetupTwoFactor, authMiddleware.Middleware(), middleware.RejectAPIKeys())
+	twoFactor.POST("/enable", authHandler.EnableTwoFactor, authMiddleware.Middleware(), middleware.RejectAPIKeys())
+	twoFactor.POST("/disable", authHandler.DisableTwoFactor, authMiddleware.Middleware(), middleware.RejectAPIKeys())
+
+	// Sign in to another team, organization admins can switch to any team of their organization
+	auth.POST("/switch-team/:teamId", authHandler.SwitchTeam, authMiddleware.Middleware(), middleware.RejectAPIKeys(), middleware.ValidateUUIDParams())
+
+	// Bulk invites, from a JSON array or a CSV upload, are sent by a user
+	auth.POST("/invite/bulk", authHandler.BulkInviteUsers, authMiddleware.Middleware(), middleware.RejectAPIKeys(), middleware.RequirePermissions(db, "team_invites:create"))
+
+	// Pending invitations of the caller's team, resending one regenerates its code
+	invitations := base.Group("/team-invitations", authMiddleware.Middleware(), middleware.ValidateUUIDParams())
+	invitations.GET("/pending", authHandler.ListPendingInvites, middleware.RequirePermissions(db, "team_invites:read"))
+	invitations.POST("/:id/resend", authHandler.ResendInvite, middleware.RequirePermissions(db, "team_invites:update"))
+
+	// Explains the permission check of a request to the caller
+	permissionHandler := handlers.NewPermissionHandler(db, authMiddleware)
+	auth.GET("/why-denied", permissionHandler.WhyDenied, authMiddleware.Middleware())
+
+	// Devices, signing one out revokes every session the user has on it
+	auth.DELETE("/devices/:deviceId", sessionHandler.RevokeDevice, authMiddleware.Middleware(), middleware.RejectAPIKeys())
 
 	// Protected auth routes (require authentication)
 	protectedAuth := users.Group("")
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.Secret)
 	protectedAuth.Use(authMiddleware.Middleware())
+	protectedAuth.Use(middleware.ValidateUUIDParams())
+
+	// Invite user route, invites are sent by a user
+	protectedAuth.POST("/invite", authHandler.InviteUser, middleware.RejectAPIKeys(), middleware.RequirePermissions(db, "team_invites:create"))
+	protectedAuth.DELETE("/invite/:id", authHandler.DeleteInvite, middleware.RequirePermissions(db, "team_invites:delete"))
+
+	// User management, members of the caller's team or any team for super admins
+	protectedAuth.GET("", authHandler.ListUsers, middleware.RequirePermissions(db, "users:read"))
+	protectedAuth.GET("/:id", authHandler.GetUser, middleware.RequirePermissions(db, "users:read"))
+	protectedAuth.PUT("/:id", authHandler.UpdateUser, middleware.RequirePermissions(db, "users:update"))
+	protectedAuth.DELETE("/:id", authHandler.DeleteUser, middleware.RequirePermissions(db, "users:delete"))
+	protectedAuth.GET("/me", authHandler.GetMe, middleware.RejectAPIKeys()) // Get current user - accessible to any authenticated user
+	protectedAuth.PUT("/me/profile-picture", profileHandler.UpdateProfilePicture, middleware.RejectAPIKeys())
+
+	// Sessions, users manage their own and admins can sign out team members
+	protectedAuth.GET("/me/sessions", sessionHandler.ListSessions, middleware.RejectAPIKeys())
+	protectedAuth.DELETE("/me/sessions/:id", sessionHandler.RevokeSession, middleware.RejectAPIKeys())
+	protectedAuth.DELETE("/:id/sessions", sessionHandler.RevokeUserSessions, middleware.RejectAPIKeys(), middleware.RequirePermissions(db, "users:update"))
+}
+
+// newCaptchaGuard builds the captcha guard of the public auth routes, nil when captchas are off
+func newCaptchaGuard(cfg config.AuthConfig) *captcha.Guard {
+	if cfg.CaptchaProvider == "" {
+		return nil
+	}
 
-	// Invite user route (require admin permissions)
-	protectedAuth.POST("/invite", authHandler.InviteUser)
-	protectedAuth.DELETE("/invite/:code", authHandler.DeleteInvite)
-
-	// User management routes (require admin permissions)
-	// userManagement := protectedAuth.Group("/users")
-	// userManagement.Use(middleware.RequirePermissions(db, "manage:users"))
-	// // Add user management routes here when implemented
-	// userManagement.GET("", authHandler.ListUsers)         // List all users
-	// userManagement.GET("/:id", authHandler.GetUser)       // Get user details
-	// userManagement.PUT("/:id", authHandler.UpdateUser)    // Update user
-	// userManagement.DELETE("/:id", authHandler.DeleteUser) // Delete user
-	protectedAuth.GET("/me", authHandler.GetMe) // Get current user - accessible to any authenticated user
+	verifier, err := captcha.New(cfg.CaptchaProvider, cfg.CaptchaSecretKey, time.Duration(cfg.CaptchaTimeout)*time.Millisecond)
+	if err != nil {
+		logger.New("auth_routes").Error("Captchas disabled: %v", err)
+		return nil
+	}
+	return captcha.NewGuard(verifier, cfg.CaptchaEndpoints, cfg.CaptchaFailOpen)
 }
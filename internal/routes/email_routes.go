@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupEmailRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("email_routes")
+
+	emailHandler := handlers.NewEmailHandler(db)
+
+	// Admins only, checked by the handler
+	api.GET("/emails", emailHandler.ListEmails)
+
+	log.Success("Email routes initialized successfully")
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"errors"
+
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// SetupHookRoutes registers the callbacks of integrations under /api/v1/hooks/:source. They
+// are not authenticated, every request must be signed with the secret of its source.
+func SetupHookRoutes(e *echo.Echo, db *gorm.DB, cfg config.HooksConfig) {
+	log := logger.New("hook_routes")
+
+	hookHandler := handlers.NewHookHandler(db)
+
+	hooks := e.Group("/api/v1/hooks/:source", middleware.VerifyWebhookSignature(func(c echo.Context) (string, error) {
+		secret, ok := cfg.Secrets[c.Param("source")]
+		if !ok {
+			return "", errors.New("unknown source " + c.Param("source"))
+		}
+		return secret, nil
+	}))
+	hooks.POST("/email-bounces", hookHandler.EmailBounce)
+
+	log.Success("Hook routes initialized successfully")
+}
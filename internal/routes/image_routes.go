@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/handlers"
+	"be0/internal/imaging"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// SetupImageRoutes registers image variants under the API group and the
+// unauthenticated, signed avatar endpoint under /public
+func SetupImageRoutes(api *echo.Group, e *echo.Echo, db *gorm.DB, redisClient *redis.Client, cfg *config.Config) {
+	log := logger.New("image_routes")
+
+	cache := imaging.NewCache(redisClient, time.Duration(cfg.Image.CacheTTL)*time.Hour)
+	avatarCache := imaging.NewCache(redisClient, time.Duration(cfg.Image.AvatarCacheTTL)*time.Minute)
+	signer := imaging.NewAvatarSigner(cfg.Server.PublicURL, cfg.Image.AvatarSigningKey)
+	imageHandler := handlers.NewImageHandler(db, cache, avatarCache, signer, cfg.Image)
+
+	// Variants are already compressed
+	middleware.NoCompression(api.GET("/images/:fileId", imageHandler.GetImage, middleware.RequirePermissions(db, "files:read")))
+
+	// 🚦 Public avatars get a tighter per-IP limit than the rest of the server
+	publicGroup := e.Group("/public")
+	publicGroup.Use(echomiddleware.RateLimiterWithConfig(echomiddleware.RateLimiterConfig{
+		Store: echomiddleware.NewRateLimiterMemoryStoreWithConfig(echomiddleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(cfg.Image.AvatarRateLimit),
+			Burst:     cfg.Image.AvatarBurst,
+			ExpiresIn: 3 * time.Minute,
+		}),
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Rate limit exceeded"})
+		},
+	}))
+	middleware.NoCompression(publicGroup.GET("/avatars/:userId", imageHandler.GetAvatar, middleware.ValidateUUIDParams("userId")))
+
+	log.Success("Image routes initialized successfully")
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestPublicAvatarsAreRateLimitedPerIP(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	redisClient, _ := testutil.NewRedis(t)
+	cfg := testutil.UseConfig(t)
+	cfg.Image.AvatarRateLimit, cfg.Image.AvatarBurst = 0.001, 2
+
+	e := echo.New()
+	SetupImageRoutes(e.Group("/api/v1"), e, gdb, redisClient, cfg)
+
+	get := func(path, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if status := get("/public/avatars/not-a-uuid?s=64&sig=x", "203.0.113.1"); status != http.StatusBadRequest {
+		t.Errorf("a malformed user ID got %d, want 400", status)
+	}
+	avatar := "/public/avatars/2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f?s=64&sig=forged"
+	if status := get(avatar, "203.0.113.1"); status != http.StatusForbidden {
+		t.Errorf("the last request of the burst got %d, want 403", status)
+	}
+	if status := get(avatar, "203.0.113.1"); status != http.StatusTooManyRequests {
+		t.Errorf("a request beyond the burst got %d, want 429", status)
+	}
+	if status := get(avatar, "198.51.100.7"); status != http.StatusForbidden {
+		t.Errorf("another IP got %d, want its own burst", status)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/jobs"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// SetupJobRoutes registers the job status endpoints. Users see the jobs they started, super
+// admins also see system jobs such as permission backfills.
+func SetupJobRoutes(api *echo.Group, db *gorm.DB, redisClient *redis.Client) {
+	log := logger.New("job_routes")
+
+	jobHandler := handlers.NewJobHandler(db, jobs.NewNotifier(redisClient))
+
+	jobGroup := api.Group("/jobs", middleware.RejectAPIKeys(), middleware.ValidateUUIDParams())
+	jobGroup.GET("/:id", jobHandler.GetJob)
+	jobGroup.GET("/:id/wait", jobHandler.WaitForJob)
+
+	log.Success("Job routes initialized successfully")
+}
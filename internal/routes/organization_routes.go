@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupOrganizationRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("organization_routes")
+
+	organizationHandler := handlers.NewOrganizationHandler(db)
+
+	// Organizations are managed by people, the handlers check organization admin rights
+	organizationGroup := api.Group("/organizations", middleware.RejectAPIKeys(), middleware.ValidateUUIDParams())
+	organizationGroup.POST("", organizationHandler.CreateOrganization, middleware.RequirePermissions(db, "organizations:create"))
+	organizationGroup.GET("/:id", organizationHandler.GetOrganization)
+	organizationGroup.GET("/:id/usage", organizationHandler.GetUsage)
+	organizationGroup.POST("/:id/teams", organizationHandler.CreateTeam)
+	organizationGroup.PUT("/:id/teams/:teamId", organizationHandler.AttachTeam)
+	organizationGroup.DELETE("/:id/teams/:teamId", organizationHandler.DetachTeam)
+
+	log.Success("Organization routes initialized successfully")
+}
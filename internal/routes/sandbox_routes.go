@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupSandboxRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("sandbox_routes")
+
+	sandboxHandler := handlers.NewSandboxHandler(db)
+
+	sandboxGroup := api.Group("/sandbox")
+	// Recorded emails carry other users' invite and reset codes
+	sandboxGroup.GET("/emails", sandboxHandler.ListEmails, middleware.RequirePermissions(db, "users:read_pii"))
+	sandboxGroup.GET("/webhooks", sandboxHandler.ListWebhooks, middleware.RequirePermissions(db, "webhooks:read"))
+
+	log.Success("Sandbox routes initialized successfully")
+}
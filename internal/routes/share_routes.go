@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// SetupShareRoutes registers share link management under the API group and the
+// unauthenticated download endpoints under /share
+func SetupShareRoutes(api *echo.Group, e *echo.Echo, db *gorm.DB) {
+	log := logger.New("share_routes")
+
+	shareHandler := handlers.NewShareHandler(db)
+
+	fileGroup := api.Group("/files")
+	fileGroup.POST("/:id/share", shareHandler.CreateShare, middleware.RejectAPIKeys(), middleware.RequirePermissions(db, "files:update")) // share links are created by a user
+	fileGroup.GET("/:id/shares", shareHandler.ListShares, middleware.RequirePermissions(db, "files:read"))
+	fileGroup.DELETE("/:id/shares/:shareId", shareHandler.RevokeShare, middleware.RequirePermissions(db, "files:update"))
+
+	publicGroup := e.Group("/share")
+	middleware.NoCompression(publicGroup.GET("/:token", shareHandler.DownloadShare))
+	publicGroup.POST("/:token/unlock", shareHandler.UnlockShare)
+
+	log.Success("Share routes initialized successfully")
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupTemplateRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("template_routes")
+
+	templateHandler := handlers.NewTemplateHandler(db)
+
+	// Rendering reads a template, the CRUD routes are registered by the registry
+	templateGroup := api.Group("/templates", middleware.RequirePermissions(db, "templates:read"), middleware.ValidateUUIDParams())
+	templateGroup.POST("/:id/render", templateHandler.RenderTemplate)
+
+	log.Success("Template routes initialized successfully")
+}
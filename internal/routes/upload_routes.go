@@ -1,25 +1,30 @@
 package routes
 
 import (
+	"be0/internal/api/middleware"
 	"be0/internal/config"
 	"be0/internal/handlers"
+	"be0/internal/tasks"
 	"be0/internal/utils/logger"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
-func SetupUploadRoutes(api *echo.Group, cfg *config.Config) {
+func SetupUploadRoutes(api *echo.Group, db *gorm.DB, cfg *config.Config) {
 	log := logger.New("upload_routes")
 
 	// Initialize upload handler
 	uploadHandler := handlers.NewUploadHandler(
-		types.ObjectCannedACLAuthenticatedRead,
+		types.ObjectCannedACL(cfg.Storage.DefaultACL),
+		tasks.NewTaskClient(cfg.Redis),
 	)
 
 	fileGroup := api.Group("/files")
 
-	fileGroup.POST("/upload", uploadHandler.UploadFile)
+	fileGroup.POST("/upload", uploadHandler.UploadFile, middleware.RequirePermissions(db, "files:create"))
+	fileGroup.PUT("/:id/acl", uploadHandler.UpdateFileACL, middleware.RequirePermissions(db, "files:update"))
 
 	log.Success("Upload routes initialized successfully")
 }
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"be0/internal/api/middleware"
+	"be0/internal/handlers"
+	"be0/internal/utils/logger"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func SetupWebhookRoutes(api *echo.Group, db *gorm.DB) {
+	log := logger.New("webhook_routes")
+
+	webhookHandler := handlers.NewWebhookHandler(db)
+
+	webhookGroup := api.Group("/webhooks")
+	webhookGroup.GET("/events", webhookHandler.ListEvents)
+
+	subscriptionGroup := webhookGroup.Group("", middleware.RequirePermissions(db, "webhooks:write"))
+	subscriptionGroup.GET("", webhookHandler.ListSubscriptions)
+	subscriptionGroup.POST("", webhookHandler.CreateSubscription)
+	subscriptionGroup.DELETE("/:id", webhookHandler.DeleteSubscription)
+
+	log.Success("Webhook routes initialized successfully")
+}
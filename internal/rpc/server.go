@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	be0v1 "be0/api/proto/be0/v1"
+	"be0/internal/api/middleware"
+	"be0/internal/config"
+	"be0/internal/utils/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// APIKeyResolver resolves API keys to their team and permissions
+type APIKeyResolver interface {
+	ResolveAPIKey(key string) (middleware.APIKeyInfo, bool)
+}
+
+// Server is the internal gRPC server used for service-to-service calls
+type Server struct {
+	server *grpc.Server
+	config config.GRPCConfig
+	logger *logger.Logger
+}
+
+// NewServer creates the internal gRPC server. Either a service token or mTLS must be configured.
+func NewServer(cfg config.GRPCConfig, db *gorm.DB, keys APIKeyResolver) (*Server, error) {
+	log := logger.New("grpc_server")
+
+	if cfg.ServiceToken == "" && cfg.ClientCAFile == "" {
+		return nil, log.Error("gRPC server requires authentication ❌", fmt.Errorf("set GRPC_SERVICE_TOKEN or GRPC_TLS_CLIENT_CA"))
+	}
+
+	var opts []grpc.ServerOption
+
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		creds, err := loadTLSCredentials(cfg)
+		if err != nil {
+			return nil, log.Error("Failed to load gRPC TLS credentials ❌", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	} else if cfg.ClientCAFile != "" {
+		return nil, log.Error("mTLS requires a server certificate ❌", fmt.Errorf("set GRPC_TLS_CERT and GRPC_TLS_KEY"))
+	}
+
+	if cfg.ServiceToken != "" {
+		opts = append(opts, grpc.UnaryInterceptor(tokenInterceptor(cfg.ServiceToken)))
+	}
+
+	server := grpc.NewServer(opts...)
+	be0v1.RegisterInternalServiceServer(server, newInternalService(db, keys))
+
+	return &Server{
+		server: server,
+		config: cfg,
+		logger: log,
+	}, nil
+}
+
+// Start listens on the configured port and serves until Stop is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port: %w", err)
+	}
+
+	s.logger.Info("starting gRPC server on port %d", s.config.Port)
+	return s.server.Serve(listener)
+}
+
+// Stop gracefully stops the gRPC server
+func (s *Server) Stop() {
+	s.server.GracefulStop()
+	s.logger.Info("gRPC server stopped")
+}
+
+// loadTLSCredentials builds server TLS credentials, requiring client certificates when a client CA is set
+func loadTLSCredentials(cfg config.GRPCConfig) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// tokenInterceptor checks the static service token sent as "authorization: Bearer <token>"
+func tokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "missing metadata")
+		}
+
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing service token")
+		}
+
+		provided := strings.TrimPrefix(values[0], "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid service token")
+		}
+
+		return handler(ctx, req)
+	}
+}
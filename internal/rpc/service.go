@@ -0,0 +1,131 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	be0v1 "be0/api/proto/be0/v1"
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// internalService implements be0v1.InternalServiceServer on top of the same models as the REST API
+type internalService struct {
+	be0v1.UnimplementedInternalServiceServer
+	db   *gorm.DB
+	keys APIKeyResolver
+}
+
+func newInternalService(db *gorm.DB, keys APIKeyResolver) *internalService {
+	return &internalService{db: db, keys: keys}
+}
+
+func (s *internalService) GetUser(ctx context.Context, req *be0v1.GetUserRequest) (*be0v1.User, error) {
+	user, err := s.findUser(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &be0v1.User{
+		Id:               user.ID,
+		Email:            user.Email,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		Role:             string(user.Role),
+		TeamId:           user.TeamID,
+		Provider:         user.Provider,
+		ProfilePictureId: user.ProfilePictureID,
+		CreatedAt:        timestamppb.New(user.CreatedAt.Time),
+		UpdatedAt:        timestamppb.New(user.UpdatedAt.Time),
+	}, nil
+}
+
+func (s *internalService) GetTeam(ctx context.Context, req *be0v1.GetTeamRequest) (*be0v1.Team, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	team, err := models.GetTeamByID(req.GetId(), s.db.WithContext(ctx))
+	if err != nil {
+		return nil, toStatus(err, "team")
+	}
+
+	return &be0v1.Team{
+		Id:            team.ID,
+		Name:          team.Name,
+		StoragePolicy: string(team.StoragePolicy),
+		CreatedAt:     timestamppb.New(team.CreatedAt.Time),
+		UpdatedAt:     timestamppb.New(team.UpdatedAt.Time),
+	}, nil
+}
+
+func (s *internalService) CheckPermission(ctx context.Context, req *be0v1.CheckPermissionRequest) (*be0v1.CheckPermissionResponse, error) {
+	if req.GetScope() == "" {
+		return nil, status.Error(codes.InvalidArgument, "scope is required")
+	}
+
+	user, err := s.findUser(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	// 🔐 Decided like a REST request of the user, see middleware.RequirePermissions
+	decision, err := middleware.ResolveUserPermission(s.db.WithContext(ctx), user, req.GetScope())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check permission")
+	}
+
+	resp := &be0v1.CheckPermissionResponse{Allowed: decision.Allowed}
+	if !decision.Allowed {
+		resp.Reason = "user does not hold scope " + req.GetScope()
+		if !user.IsActive {
+			resp.Reason = "user is deactivated"
+		}
+	}
+	return resp, nil
+}
+
+func (s *internalService) ResolveAPIKey(ctx context.Context, req *be0v1.ResolveAPIKeyRequest) (*be0v1.ResolveAPIKeyResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+
+	info, ok := s.keys.ResolveAPIKey(req.GetKey())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "api key not found")
+	}
+
+	resp := &be0v1.ResolveAPIKeyResponse{
+		TeamId:      info.TeamID,
+		Permissions: info.Permissions,
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp.ExpiresAt = timestamppb.New(info.ExpiresAt)
+	}
+	return resp, nil
+}
+
+func (s *internalService) findUser(ctx context.Context, id string) (*models.User, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND is_deleted = false", id).First(&user).Error; err != nil {
+		return nil, toStatus(err, "user")
+	}
+	return &user, nil
+}
+
+// toStatus maps database errors to gRPC status errors
+func toStatus(err error, entity string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, entity+" not found")
+	}
+	return status.Error(codes.Internal, "failed to fetch "+entity)
+}
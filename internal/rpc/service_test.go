@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	be0v1 "be0/api/proto/be0/v1"
+	"be0/internal/api/middleware"
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// grant replaces the user's permissions with one of scope, deleted grants a soft-deleted one
+func grant(t *testing.T, gdb *gorm.DB, user *models.User, scope string, deleted bool) {
+	t.Helper()
+	resource, action, _ := strings.Cut(scope, ":")
+	res := models.Resource{Name: resource, Action: action}
+	if err := gdb.FirstOrCreate(&res, res).Error; err != nil {
+		t.Fatal(err)
+	}
+	permission := models.ResourcePermission{ResourceID: res.ID, Scope: scope}
+	if err := gdb.FirstOrCreate(&permission, permission).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Where("user_id = ?", user.ID).Delete(&models.UserPermission{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	userPermission := models.UserPermission{UserID: user.ID, ResourcePermissionID: permission.ID}
+	userPermission.IsDeleted = deleted
+	if err := gdb.Create(&userPermission).Error; err != nil {
+		t.Fatal(err)
+	}
+	middleware.InvalidateUserScopes(user.ID)
+}
+
+// restStatus sends an authenticated request of the user through the REST auth middleware
+func restStatus(t *testing.T, gdb *gorm.DB, user *models.User, method, target string) int {
+	t.Helper()
+	token, tokenID, err := utils.GenerateJWT(*user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := models.AuthTransaction{UserID: user.ID, TeamID: user.TeamID, TokenID: tokenID, ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	if err := gdb.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	api := e.Group("/api/v1", middleware.NewAuthMiddleware(testutil.JWTSecret).Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	api.GET("/files", ok)
+	api.DELETE("/files/:id", ok)
+	api.GET("/teams", ok)
+
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestCheckPermissionAgreesWithREST(t *testing.T) {
+	testutil.UseJWTSecret(t)
+	gdb := testutil.NewDB(t)
+	testutil.UseDB(t, gdb)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	svc := newInternalService(gdb, nil)
+
+	tests := []struct {
+		name    string
+		scope   string
+		deleted bool
+		active  bool
+		method  string
+		target  string
+		check   string
+		allowed bool
+	}{
+		{"wildcard grant reading", "files:*", false, true, http.MethodGet, "/api/v1/files", "files:read", true},
+		{"wildcard grant deleting", "files:*", false, true, http.MethodDelete, "/api/v1/files/2f6c1c1e-8d8e-4a43-9f5b-0b3e3c4d5e6f", "files:delete", true},
+		{"wildcard grant of another resource", "files:*", false, true, http.MethodGet, "/api/v1/teams", "teams:read", false},
+		{"deleted grant", "files:read", true, true, http.MethodGet, "/api/v1/files", "files:read", false},
+		{"deactivated user", "files:*", false, false, http.MethodGet, "/api/v1/files", "files:read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+			grant(t, gdb, user, tt.scope, tt.deleted)
+			if !tt.active {
+				if err := gdb.Model(user).Update("is_active", false).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rest := restStatus(t, gdb, user, tt.method, tt.target) == http.StatusOK
+			resp, err := svc.CheckPermission(context.Background(), &be0v1.CheckPermissionRequest{UserId: user.ID, Scope: tt.check})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rest != tt.allowed || resp.GetAllowed() != tt.allowed {
+				t.Errorf("REST allowed %v, gRPC allowed %v (%s), want both %v", rest, resp.GetAllowed(), resp.GetReason(), tt.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package sanitize
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// variablePattern matches a {{name}} placeholder, names may be dotted and piped
+// through functions, e.g. {{name | default "there"}}
+var variablePattern = regexp.MustCompile(`^\{\{\s*([A-Za-z_][A-Za-z0-9_.]*)\s*(?:\|[^{}]*)?\}\}`)
+
+// Variables rejects placeholders that are malformed or not declared, and warns
+// about declared variables the template never uses
+type Variables struct{}
+
+func (Variables) Check(doc *Document, report *Report) {
+	declared := make(map[string]bool, len(doc.Variables))
+	for _, name := range doc.Variables {
+		declared[name] = true
+	}
+
+	used := map[string]bool{}
+	src := doc.Source
+	for offset := 0; ; {
+		i := strings.Index(src[offset:], "{{")
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		match := variablePattern.FindStringSubmatch(src[start:])
+		if match == nil {
+			report.Fail("invalid_variable", lineAt(src, start), "placeholder at %q is not of the form {{name}} or {{name | func}}", excerpt(src[start:]))
+			offset = start + 2
+			continue
+		}
+
+		name := match[1]
+		if !declared[name] && !used[name] {
+			report.Fail("undeclared_variable", lineAt(src, start), "variable %s is used but not declared", name)
+		}
+		used[name] = true
+		offset = start + len(match[0])
+	}
+
+	for _, name := range doc.Variables {
+		if !used[name] {
+			report.Warn("unused_variable", 0, "variable %s is declared but not used", name)
+			// Report duplicates once
+			used[name] = true
+		}
+	}
+}
+
+// MissingAlt warns about images without alt text. An empty alt is allowed,
+// it marks an image as decorative.
+type MissingAlt struct{}
+
+func (MissingAlt) Check(doc *Document, report *Report) {
+	walk(doc.Root, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.Data != "img" {
+			return
+		}
+		for _, attr := range n.Attr {
+			if attr.Key == "alt" {
+				return
+			}
+		}
+		src := "without src"
+		for _, attr := range n.Attr {
+			if attr.Key == "src" {
+				src = attr.Val
+			}
+		}
+		report.Warn("missing_alt", 0, "image %s has no alt text", src)
+	})
+}
+
+// voidElements never have an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndElements may be closed implicitly
+var optionalEndElements = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+	"tr": true, "td": true, "th": true, "thead": true, "tbody": true, "tfoot": true,
+	"option": true, "optgroup": true, "colgroup": true,
+}
+
+// BalancedTags warns about elements that are never closed and end tags that close nothing.
+// It reads the source as submitted, since the parser silently repairs both.
+type BalancedTags struct{}
+
+type openTag struct {
+	name string
+	line int
+}
+
+func (BalancedTags) Check(doc *Document, report *Report) {
+	src := doc.Source
+	z := html.NewTokenizer(strings.NewReader(src))
+	var open []openTag
+	offset := 0
+
+	for {
+		tt := z.Next()
+		line := lineAt(src, offset)
+		offset += len(z.Raw())
+
+		switch tt {
+		case html.ErrorToken:
+			if !errors.Is(z.Err(), io.EOF) {
+				report.Warn("invalid_html", line, "template could not be read to the end: %v", z.Err())
+				return
+			}
+			for _, tag := range open {
+				if !optionalEndElements[tag.name] {
+					report.Warn("unclosed_tag", tag.line, "<%s> is never closed", tag.name)
+				}
+			}
+			return
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if !voidElements[string(name)] {
+				open = append(open, openTag{name: string(name), line: line})
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			i := len(open) - 1
+			for i >= 0 && open[i].name != string(name) {
+				i--
+			}
+			if i < 0 {
+				report.Warn("unexpected_end_tag", line, "</%s> does not close an open element", name)
+				continue
+			}
+			for _, tag := range open[i+1:] {
+				if !optionalEndElements[tag.name] {
+					report.Warn("unclosed_tag", tag.line, "<%s> is never closed", tag.name)
+				}
+			}
+			open = open[:i]
+		}
+	}
+}
+
+// walk calls fn for n and all its descendants
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+// excerpt shortens a source snippet for messages
+func excerpt(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if runes := []rune(s); len(runes) > 20 {
+		return string(runes[:20]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,257 @@
+// Package sanitize cleans and lints user supplied HTML templates before they are stored
+package sanitize
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Issue is a single finding on a template
+type Issue struct {
+	// Rule identifies the check, e.g. removed_element or missing_alt
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Line is 1-based, 0 when the finding has no position in the source
+	Line int `json:"line,omitempty"`
+}
+
+// Report is the outcome of checking a template. Warnings are informational,
+// any Errors mean the template must be rejected.
+type Report struct {
+	// HTML is the sanitized template, the source unchanged when nothing was removed
+	HTML     string  `json:"-"`
+	Warnings []Issue `json:"warnings,omitempty"`
+	Errors   []Issue `json:"errors,omitempty"`
+}
+
+// Warn records a finding that does not block saving the template
+func (r *Report) Warn(rule string, line int, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{Rule: rule, Message: fmt.Sprintf(format, args...), Line: line})
+}
+
+// Fail records a finding that blocks saving the template
+func (r *Report) Fail(rule string, line int, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Issue{Rule: rule, Message: fmt.Sprintf(format, args...), Line: line})
+}
+
+// OK reports whether the template has no hard errors
+func (r *Report) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Document is a template being checked
+type Document struct {
+	// Source is the template as submitted
+	Source string
+	// Root is the parsed template after the policy was applied
+	Root *html.Node
+	// Variables are the variable names the template declares
+	Variables []string
+}
+
+// Rule inspects a template and records its findings on the report
+type Rule interface {
+	Check(doc *Document, report *Report)
+}
+
+// Policy decides what is removed from templates
+type Policy struct {
+	// StripElements are removed together with their content
+	StripElements []string
+	// StripEventHandlers removes on* attributes such as onclick
+	StripEventHandlers bool
+	// URLAttributes are checked against BlockedSchemes
+	URLAttributes []string
+	// BlockedSchemes are URL schemes that are removed, e.g. javascript
+	BlockedSchemes []string
+}
+
+// DefaultPolicy strips active content that has no place in an email template
+func DefaultPolicy() Policy {
+	return Policy{
+		StripElements:      []string{"script", "iframe", "object", "embed", "applet", "frame", "frameset", "base"},
+		StripEventHandlers: true,
+		URLAttributes:      []string{"href", "src", "action", "formaction", "background", "poster", "xlink:href"},
+		BlockedSchemes:     []string{"javascript", "vbscript"},
+	}
+}
+
+var (
+	policy  = DefaultPolicy()
+	rules   []Rule
+	rulesMu sync.RWMutex
+)
+
+// SetPolicy replaces the policy applied by Template
+func SetPolicy(p Policy) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	policy = p
+}
+
+// Register adds a rule run by Template after the policy was applied, so a
+// deployment can add its own checks next to the defaults
+func Register(rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules = append(rules, rule)
+}
+
+// Template applies the policy to src and runs the registered rules on the result
+func Template(src string, variables []string) *Report {
+	rulesMu.RLock()
+	p, registered := policy, append([]Rule(nil), rules...)
+	rulesMu.RUnlock()
+
+	report := &Report{HTML: src}
+	nodes, err := parse(src)
+	if err != nil {
+		report.Fail("invalid_html", 0, "template could not be parsed: %v", err)
+		return report
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	// 🧹 Only re-render when something was removed, so clean templates are stored byte for byte
+	if p.apply(root, report) {
+		var buf bytes.Buffer
+		for n := root.FirstChild; n != nil; n = n.NextSibling {
+			if err := html.Render(&buf, n); err != nil {
+				report.Fail("invalid_html", 0, "template could not be rendered: %v", err)
+				return report
+			}
+		}
+		report.HTML = buf.String()
+	}
+
+	doc := &Document{Source: src, Root: root, Variables: variables}
+	for _, rule := range registered {
+		rule.Check(doc, report)
+	}
+	return report
+}
+
+// parse reads a whole document when src has one, otherwise a body fragment
+func parse(src string) ([]*html.Node, error) {
+	lower := strings.ToLower(src)
+	if strings.Contains(lower, "<html") || strings.Contains(lower, "<!doctype") {
+		doc, err := html.Parse(strings.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		var nodes []*html.Node
+		for n := doc.FirstChild; n != nil; {
+			next := n.NextSibling
+			doc.RemoveChild(n)
+			nodes = append(nodes, n)
+			n = next
+		}
+		return nodes, nil
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	return nodes, err
+}
+
+// apply removes what the policy forbids from the tree and reports whether anything changed
+func (p Policy) apply(n *html.Node, report *Report) bool {
+	changed := false
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && contains(p.StripElements, child.Data) {
+			n.RemoveChild(child)
+			report.Warn("removed_element", 0, "<%s> elements are not allowed and were removed", child.Data)
+			changed = true
+		} else {
+			if child.Type == html.ElementNode && p.applyAttributes(child, report) {
+				changed = true
+			}
+			if p.apply(child, report) {
+				changed = true
+			}
+		}
+		child = next
+	}
+	return changed
+}
+
+// applyAttributes removes event handlers and blocked URLs from an element
+func (p Policy) applyAttributes(n *html.Node, report *Report) bool {
+	kept := n.Attr[:0]
+	for _, attr := range n.Attr {
+		name := strings.ToLower(attr.Key)
+		if attr.Namespace != "" {
+			name = attr.Namespace + ":" + name
+		}
+		switch {
+		case p.StripEventHandlers && strings.HasPrefix(name, "on"):
+			report.Warn("removed_attribute", 0, "event handler %s on <%s> was removed", name, n.Data)
+		case contains(p.URLAttributes, name) && p.blocked(attr.Val):
+			report.Warn("removed_url", 0, "%s on <%s> uses a blocked URL scheme and was removed", name, n.Data)
+		default:
+			kept = append(kept, attr)
+		}
+	}
+	changed := len(kept) != len(n.Attr)
+	n.Attr = kept
+	return changed
+}
+
+// blocked reports whether a URL uses one of the blocked schemes. Browsers ignore
+// whitespace and control characters inside schemes, so they are dropped first.
+func (p Policy) blocked(value string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+
+	for _, scheme := range p.BlockedSchemes {
+		if strings.HasPrefix(cleaned, scheme+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// URLBlocked reports whether value would be removed from the attribute attr by the
+// current policy, e.g. a javascript: URL in href
+func URLBlocked(attr, value string) bool {
+	rulesMu.RLock()
+	p := policy
+	rulesMu.RUnlock()
+	return contains(p.URLAttributes, attr) && p.blocked(value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineAt returns the 1-based line of a byte offset in src
+func lineAt(src string, offset int) int {
+	return strings.Count(src[:offset], "\n") + 1
+}
+
+func init() {
+	Register(Variables{})
+	Register(MissingAlt{})
+	Register(BalancedTags{})
+}
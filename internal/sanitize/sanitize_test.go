@@ -0,0 +1,123 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// rulesOf lists the rules of issues in order
+func rulesOf(issues []Issue) []string {
+	names := make([]string, len(issues))
+	for i, issue := range issues {
+		names[i] = issue.Rule
+	}
+	return names
+}
+
+func TestTemplateStripsActiveContent(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		rule string
+	}{
+		{"script", `<p>Hi</p><script>alert(1)</script>`, `<p>Hi</p>`, "removed_element"},
+		{"iframe in a document", `<!DOCTYPE html><html><body><iframe src="https://evil.example.com"></iframe><p>Hi</p></body></html>`, `<!DOCTYPE html><html><head></head><body><p>Hi</p></body></html>`, "removed_element"},
+		{"event handler", `<a href="https://be0.dev" onClick="steal()">Go</a>`, `<a href="https://be0.dev">Go</a>`, "removed_attribute"},
+		{"javascript URL", `<a href="javascript:alert(1)">Go</a>`, `<a>Go</a>`, "removed_url"},
+		{"scheme split by whitespace", "<a href=\"java\tscript:alert(1)\">Go</a>", `<a>Go</a>`, "removed_url"},
+		{"vbscript background", `<table><tr><td background="VBScript:msgbox">x</td></tr></table>`, `<table><tbody><tr><td>x</td></tr></tbody></table>`, "removed_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Template(tt.src, nil)
+			if report.HTML != tt.want {
+				t.Errorf("got %q, want %q", report.HTML, tt.want)
+			}
+			if !report.OK() || len(report.Warnings) != 1 || report.Warnings[0].Rule != tt.rule {
+				t.Errorf("got errors %v and warnings %v, want one %s warning", report.Errors, report.Warnings, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCleanTemplatesAreKeptByteForByte(t *testing.T) {
+	src := "<p class=x>Hi {{ name }},</p>\n<img src='logo.png' alt=''>\n<a href=https://be0.dev>Open</a>"
+	report := Template(src, []string{"name"})
+	if report.HTML != src || len(report.Warnings) != 0 || !report.OK() {
+		t.Errorf("got %q with warnings %v and errors %v", report.HTML, report.Warnings, report.Errors)
+	}
+}
+
+func TestTemplateRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		variables []string
+		errors    []string
+		warnings  []string
+		line      int // of the first finding
+	}{
+		{"undeclared variable", "<p>Hi</p>\n<p>{{ token }}</p>", []string{"name"}, []string{"undeclared_variable"}, []string{"unused_variable"}, 2},
+		{"malformed placeholder", "<p>{{ 1st }}</p>", nil, []string{"invalid_variable"}, nil, 1},
+		{"piped variable", `<p>Hi {{name | default "there"}}</p>`, []string{"name"}, nil, nil, 0},
+		{"image without alt", `<img src="logo.png">`, nil, nil, []string{"missing_alt"}, 0},
+		{"unclosed tag", "<div>\n<table><tr><td>x</td></tr>\n</div>", nil, nil, []string{"unclosed_tag"}, 2},
+		{"stray end tag", "<p>x</p>\n</span>", nil, nil, []string{"unexpected_end_tag"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Template(tt.src, tt.variables)
+			if got := rulesOf(report.Errors); strings.Join(got, ",") != strings.Join(tt.errors, ",") {
+				t.Errorf("errors %v, want %v", report.Errors, tt.errors)
+			}
+			if got := rulesOf(report.Warnings); strings.Join(got, ",") != strings.Join(tt.warnings, ",") {
+				t.Errorf("warnings %v, want %v", report.Warnings, tt.warnings)
+			}
+			findings := append(report.Errors, report.Warnings...)
+			if tt.line != 0 && findings[0].Line != tt.line {
+				t.Errorf("first finding on line %d, want %d", findings[0].Line, tt.line)
+			}
+		})
+	}
+}
+
+// noTables is a deployment rule, layouts must not use tables
+type noTables struct{}
+
+func (noTables) Check(doc *Document, report *Report) {
+	walk(doc.Root, func(n *html.Node) {
+		if n.Data == "table" {
+			report.Fail("no_tables", 0, "tables are not allowed")
+		}
+	})
+}
+
+func TestRegisteredRulesAndPolicyAreApplied(t *testing.T) {
+	rulesMu.RLock()
+	previousRules, previousPolicy := rules, policy
+	rulesMu.RUnlock()
+	t.Cleanup(func() {
+		rulesMu.Lock()
+		rules, policy = previousRules, previousPolicy
+		rulesMu.Unlock()
+	})
+
+	Register(noTables{})
+	custom := DefaultPolicy()
+	custom.StripElements = append(custom.StripElements, "form")
+	SetPolicy(custom)
+
+	report := Template(`<form><input></form><table><tr><td>x</td></tr></table>`, nil)
+	if strings.Contains(report.HTML, "<form") {
+		t.Errorf("the policy kept the form: %q", report.HTML)
+	}
+	if report.OK() || report.Errors[0].Rule != "no_tables" {
+		t.Errorf("errors %v, want the registered rule to fail the template", report.Errors)
+	}
+	if !URLBlocked("href", " javascript:x") || URLBlocked("title", "javascript:x") {
+		t.Error("URLBlocked does not follow the policy")
+	}
+}
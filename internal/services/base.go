@@ -2,27 +2,49 @@ package services
 
 import (
 	"be0/internal/events"
+	"be0/internal/metrics"
+	"be0/internal/models"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BaseService interface defines common CRUD operations
 type BaseService[T any] interface {
 	Create(ctx context.Context, entity *T, includes ...string) error
 	Get(ctx context.Context, id string, includes ...string) (*T, error)
+	GetMany(ctx context.Context, ids []string, includes ...string) ([]T, error)
 	List(ctx context.Context, page, limit int, filters map[string]interface{}, excludeFields map[string]bool, sortFields []string, order string, includes ...string) ([]T, int64, error)
 	Update(ctx context.Context, id string, entity *T, includes ...string) error
+	Patch(ctx context.Context, id string, changes map[string]interface{}, check func(entity *T) error, includes ...string) (*T, error)
 	Delete(ctx context.Context, id string) error
+	DeleteMany(ctx context.Context, ids []string) ([]string, error)
+	Restore(ctx context.Context, id string) error
+	Fields() (*FieldResolver, error)
+	RegisterHook(point HookPoint, hook Hook[T])
 }
 
 // BaseServiceImpl implements BaseService
 type BaseServiceImpl[T any] struct {
 	db        *gorm.DB
 	modelType T
+	table     string
+
+	fieldsOnce sync.Once
+	fields     *FieldResolver
+	fieldsErr  error
+
+	hooks hookSet[T]
 }
 
 func GormTableName(db *gorm.DB, v any) string {
@@ -32,29 +54,90 @@ func GormTableName(db *gorm.DB, v any) string {
 
 // NewBaseService creates a new base service
 func NewBaseService[T any](db *gorm.DB, modelType T) BaseService[T] {
-	return &BaseServiceImpl[T]{
+	s := &BaseServiceImpl[T]{
 		db:        db,
 		modelType: modelType,
+		table:     GormTableName(db, modelType),
 	}
+	s.registerDefaultHooks()
+	return s
+}
+
+// RegisterHook adds a hook Create, Update, Patch, Delete, DeleteMany and Restore run at
+// point. The hooks of a point run in registration order in the write's transaction: before
+// hooks once the stored entity is loaded and its preconditions checked, after hooks once the
+// entity is written. The first error stops them and rolls the write back. The default after hooks are
+// registered first, so hooks registered later run once the change is in the outbox, and the
+// change's event is emitted after the commit.
+func (s *BaseServiceImpl[T]) RegisterHook(point HookPoint, hook Hook[T]) {
+	s.hooks.add(point, hook)
+}
+
+// registerDefaultHooks records every write in the outbox and emits its <table>.<action>
+// event once it committed: the entity when created, the Change when updated and the ID when
+// deleted or restored
+func (s *BaseServiceImpl[T]) registerDefaultHooks() {
+	s.RegisterHook(HookAfterCreate, func(ctx context.Context, tx *gorm.DB, change Change[T]) error {
+		if err := s.recordChange(ctx, tx, "created", change.New); err != nil {
+			return err
+		}
+		AfterCommit(ctx, func() { events.Emit(fmt.Sprintf("%s.created", s.table), change.New) })
+		return nil
+	})
+	s.RegisterHook(HookAfterUpdate, func(ctx context.Context, tx *gorm.DB, change Change[T]) error {
+		if err := s.recordChange(ctx, tx, "updated", change.New); err != nil {
+			return err
+		}
+		AfterCommit(ctx, func() { events.Emit(fmt.Sprintf("%s.updated", s.table), change) })
+		return nil
+	})
+	s.RegisterHook(HookAfterDelete, func(ctx context.Context, tx *gorm.DB, change Change[T]) error {
+		id := entityID(change.Old)
+		if err := s.recordChange(ctx, tx, "deleted", map[string]string{"id": id}); err != nil {
+			return err
+		}
+		AfterCommit(ctx, func() { events.Emit(fmt.Sprintf("%s.deleted", s.table), id) })
+		return nil
+	})
+	s.RegisterHook(HookAfterRestore, func(ctx context.Context, tx *gorm.DB, change Change[T]) error {
+		id := entityID(change.New)
+		if err := s.recordChange(ctx, tx, "restored", map[string]string{"id": id}); err != nil {
+			return err
+		}
+		AfterCommit(ctx, func() { events.Emit(fmt.Sprintf("%s.restored", s.table), id) })
+		return nil
+	})
+}
+
+// Fields returns the resolver for the model's client-facing field names
+func (s *BaseServiceImpl[T]) Fields() (*FieldResolver, error) {
+	s.fieldsOnce.Do(func() {
+		s.fields, s.fieldsErr = NewFieldResolver(s.db, &s.modelType)
+	})
+	return s.fields, s.fieldsErr
 }
 
-// applyIncludes adds preload statements to the query for each include
+// applyIncludes adds preload statements to the query for each include. Relations whose model
+// carries a TeamID only load the rows of the actor's team, at every level of a nested include.
 func (s *BaseServiceImpl[T]) applyIncludes(query *gorm.DB, includes ...string) *gorm.DB {
+	_, teamID, _ := ActorFromContext(query.Statement.Context)
+	fields, err := s.Fields()
+	preloaded := map[string]bool{}
 	for _, include := range includes {
-		query = query.Preload(include)
-		// Handle nested includes with field selection
-		//parts := strings.Split(include, ".")
-		//if len(parts) > 1 {
-		//	log.Info(
-		//		"parts[0]: %s, parts[1:]: %s", parts[0], parts[1:])
-		//	// For nested preloads like "HtmlFile.name", use closure to specify fields
-		//	query = query.Preload(parts[0], func(db *gorm.DB) *gorm.DB {
-		//		return db.Select(parts[1:])
-		//	})
-		//} else {
-		//	// Regular preload for single relationships
-		//	query = query.Preload(include)
-		//}
+		parts := strings.Split(include, ".")
+		for i := range parts {
+			path := strings.Join(parts[:i+1], ".")
+			if preloaded[path] {
+				continue
+			}
+			preloaded[path] = true
+			// 🔒 Scope each level, a nested preload only applies its conditions to the last one
+			if teamID != "" && err == nil && fields.relationHasTeam(path) {
+				query = query.Preload(path, "team_id = ?", teamID)
+			} else {
+				query = query.Preload(path)
+			}
+		}
 	}
 	return query
 }
@@ -66,87 +149,389 @@ func (s *BaseServiceImpl[T]) applyExcludes(query *gorm.DB, excludes map[string]b
 	return query
 }
 
-func (s *BaseServiceImpl[T]) Create(ctx context.Context, entity *T, includes ...string) error {
-	if err := s.db.WithContext(ctx).Create(entity).Error; err != nil {
+// selection resolves the sparse fieldset of ctx, nil when all fields are wanted, see WithFields
+func (s *BaseServiceImpl[T]) selection(ctx context.Context, includes []string) (*Selection, error) {
+	names := FieldsFromContext(ctx)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	fields, err := s.Fields()
+	if err != nil {
+		return nil, err
+	}
+	return fields.Select(names, includes)
+}
+
+// recordChange writes an outbox event for an entity change using the caller's transaction.
+// The payload is the API JSON shape of the entity, not its raw columns.
+func (s *BaseServiceImpl[T]) recordChange(ctx context.Context, tx *gorm.DB, action string, payload interface{}) error {
+	data, err := json.Marshal(models.ResponseOf(payload))
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	table := GormTableName(s.db, s.modelType)
+	actorID, teamID, requestID := ActorFromContext(ctx)
+
+	return tx.Create(&models.OutboxEvent{
+		Event:     fmt.Sprintf("%s.%s", table, action),
+		Table:     table,
+		Payload:   data,
+		TeamID:    teamID,
+		ActorID:   actorID,
+		RequestID: requestID,
+	}).Error
+}
+
+// entityID returns the ID of an entity
+func entityID(entity any) string {
+	return reflect.Indirect(reflect.ValueOf(entity)).FieldByName("ID").String()
+}
+
+// dualWrite fills the new column of entities whose model is moving data into one, see
+// models.DualWriter
+func dualWrite(entity any) {
+	if writer, ok := entity.(models.DualWriter); ok {
+		writer.DualWrite()
+	}
+}
+
+func (s *BaseServiceImpl[T]) Create(ctx context.Context, entity *T, includes ...string) (err error) {
+	defer func(start time.Time) { metrics.ObserveServiceOperation(s.table, "create", start, -1, err) }(time.Now())
+
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.hooks.run(ctx, tx, HookBeforeCreate, Change[T]{New: entity}); err != nil {
+			return err
+		}
+		dualWrite(entity)
+		if err := tx.Create(entity).Error; err != nil {
+			return err
+		}
+		return s.hooks.run(ctx, tx, HookAfterCreate, Change[T]{New: entity})
+	}); err != nil {
 		return err
 	}
 
+	// ✅ The write committed, so its hooks fire even if the reload below fails
+	committed.run()
+
 	// Reload the entity with includes if any are specified
 	if len(includes) > 0 {
-		if err := s.applyIncludes(s.db.WithContext(ctx), includes...).First(entity, "id = ?", reflect.ValueOf(*entity).FieldByName("ID").String()).Error; err != nil {
+		if err := s.applyIncludes(s.db.WithContext(ctx), includes...).First(entity, "id = ?", entityID(entity)).Error; err != nil {
 			return err
 		}
 	}
 
-	// Get the table name of the gorm model
-	events.Emit(fmt.Sprintf("%s.created", GormTableName(s.db, s.modelType)), entity)
-
 	return nil
 }
 
-func (s *BaseServiceImpl[T]) Get(ctx context.Context, id string, includes ...string) (*T, error) {
+func (s *BaseServiceImpl[T]) Get(ctx context.Context, id string, includes ...string) (_ *T, err error) {
+	start := time.Now()
+	rows := 0
+	defer func() { metrics.ObserveServiceOperation(s.table, "get", start, rows, err) }()
+
+	selection, err := s.selection(ctx, includes)
+	if err != nil {
+		return nil, err
+	}
+	if selection != nil {
+		ctx = models.WithExcludedFields(ctx, selection.Skipped...)
+	}
+
 	var entity T
 	query := s.db.WithContext(ctx)
 	query = s.applyIncludes(query, includes...)
+	if selection != nil {
+		query = query.Select(selection.Columns)
+	}
 
 	// filter deleted entities
 	query = query.Where("is_deleted = ?", false)
 
-	if err := query.First(&entity, "id = ?", id).Error; err != nil {
-		return nil, err
+	result := query.First(&entity, "id = ?", id)
+	explainIfSlow(s.db, result, s.table, "get", time.Since(start))
+	if result.Error != nil {
+		return nil, result.Error
 	}
+	rows = 1
 	return &entity, nil
 }
 
-func (s *BaseServiceImpl[T]) List(ctx context.Context, page, limit int, filters map[string]interface{}, excludes map[string]bool, sortFields []string, order string, includes ...string) ([]T, int64, error) {
+// MaxGetMany is the most IDs GetMany takes in one call
+const MaxGetMany = 200
+
+// ErrTooManyGetIDs is returned by GetMany for more than MaxGetMany IDs
+var ErrTooManyGetIDs = fmt.Errorf("at most %d ids can be fetched at once", MaxGetMany)
+
+// ErrInvalidID is wrapped by GetMany for IDs that are not UUIDs
+var ErrInvalidID = errors.New("invalid id")
+
+// GetMany returns the entities of ids in the order of ids, skipping IDs that are missing,
+// deleted or of another team than the actor's, and repeated ones
+func (s *BaseServiceImpl[T]) GetMany(ctx context.Context, ids []string, includes ...string) (entities []T, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveServiceOperation(s.table, "get_many", start, len(entities), err) }()
+
+	if len(ids) > MaxGetMany {
+		return nil, ErrTooManyGetIDs
+	}
+	// 🛡️ Only UUIDs reach the query, in their canonical spelling
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a UUID", ErrInvalidID, id)
+		}
+		if canonical := parsed.String(); !slices.Contains(unique, canonical) {
+			unique = append(unique, canonical)
+		}
+	}
+	if len(unique) == 0 {
+		return []T{}, nil
+	}
+	fields, err := s.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := s.selection(ctx, includes)
+	if err != nil {
+		return nil, err
+	}
+	if selection != nil {
+		ctx = models.WithExcludedFields(ctx, selection.Skipped...)
+	}
+
+	query := s.applyIncludes(s.db.WithContext(ctx), includes...)
+	if selection != nil {
+		query = query.Select(selection.Columns)
+	}
+	query = query.Where("id IN ? AND is_deleted = ?", unique, false).Scopes(teamScope(ctx, fields))
+
+	var found []T
+	result := query.Find(&found)
+	explainIfSlow(s.db, result, s.table, "get_many", time.Since(start))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	// The database returns rows in any order, answer in the order asked for
+	byID := make(map[string]*T, len(found))
+	for i := range found {
+		byID[entityID(&found[i])] = &found[i]
+	}
+	entities = make([]T, 0, len(found))
+	for _, id := range unique {
+		if entity, ok := byID[id]; ok {
+			entities = append(entities, *entity)
+		}
+	}
+	return entities, nil
+}
+
+// ErrInvalidListQuery is returned by List for filters, sort fields and excludes that are not
+// columns of the model, and for orders other than asc and desc
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// listColumn returns the column of a JSON or column name, so ?teamId= and ?team_id= filter alike
+func listColumn(fields *FieldResolver, name, use string) (string, error) {
+	field, err := fields.Resolve(name)
+	if err != nil || field.Virtual || field.Relation != nil {
+		return "", fmt.Errorf("%w: cannot %s on %q", ErrInvalidListQuery, use, name)
+	}
+	return field.Column, nil
+}
+
+func (s *BaseServiceImpl[T]) List(ctx context.Context, page, limit int, filters map[string]interface{}, excludes map[string]bool, sortFields []string, order string, includes ...string) (_ []T, _ int64, err error) {
 	var entities []T
 	var total int64
 
-	query := s.db.WithContext(ctx).Model(s.modelType)
+	start := time.Now()
+	defer func() { metrics.ObserveServiceOperation(s.table, "list", start, len(entities), err) }()
 
-	// Apply filters
+	fields, err := s.Fields()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 🛡️ Names come from query strings, only columns of the model reach the SQL
+	if order != "" && !strings.EqualFold(order, "asc") && !strings.EqualFold(order, "desc") {
+		return nil, 0, fmt.Errorf("%w: order must be asc or desc", ErrInvalidListQuery)
+	}
+	columnFilters := make(map[string]interface{}, len(filters))
+	relationFilters := map[string]interface{}{}
 	for key, value := range filters {
-		query = query.Where(key+" = ?", value)
+		if field, err := fields.Resolve(key); err == nil && field.Relation != nil {
+			relationFilters[field.Key()] = value
+			continue
+		}
+		column, err := listColumn(fields, key, "filter")
+		if err != nil {
+			return nil, 0, err
+		}
+		columnFilters[column] = value
+	}
+	sortColumns := make([]string, len(sortFields))
+	for i, name := range sortFields {
+		if sortColumns[i], err = listColumn(fields, name, "sort"); err != nil {
+			return nil, 0, err
+		}
+	}
+	excludeColumns := make(map[string]bool, len(excludes))
+	for name := range excludes {
+		column, err := listColumn(fields, name, "exclude")
+		if err != nil {
+			return nil, 0, err
+		}
+		excludeColumns[column] = true
 	}
 
-	// Apply includes
-	query = s.applyIncludes(query, includes...)
+	selection, err := s.selection(ctx, includes)
+	if err != nil {
+		return nil, 0, err
+	}
+	if selection != nil {
+		ctx = models.WithExcludedFields(ctx, selection.Skipped...)
+	}
 
-	// Apply pagination
-	if page > 0 && limit > 0 {
-		offset := (page - 1) * limit
-		query = query.Offset(offset).Limit(limit)
+	query := s.db.WithContext(ctx).Model(s.modelType)
+
+	// Apply filters
+	for column, value := range columnFilters {
+		query = query.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: value})
 	}
 
-	// Apply excludes
-	query = s.applyExcludes(query, excludes)
+	// 🧮 A has-many join returns a row per related record, the ids of the matching records are
+	// selected DISTINCT so each is listed and counted once
+	if len(relationFilters) > 0 {
+		_, teamID, _ := ActorFromContext(ctx)
+		ids := s.db.WithContext(ctx).Model(s.modelType)
+		joined := map[string]bool{}
+		for key, value := range relationFilters {
+			field, _ := fields.Resolve(key)
+			if !joined[field.Relation.Alias] {
+				joined[field.Relation.Alias] = true
+				ids = ids.Joins(field.Relation.Join)
+				// 🔒 Like includes, related records of other teams are never matched
+				if teamID != "" && field.Relation.Team {
+					ids = ids.Where(clause.Eq{Column: clause.Column{Table: field.Relation.Alias, Name: "team_id"}, Value: teamID})
+				}
+			}
+			ids = ids.Where(clause.Eq{Column: clause.Column{Table: field.Relation.Alias, Name: field.Column}, Value: value})
+		}
+		ids = ids.Distinct(s.table + ".id")
+		query = query.Where(clause.Expr{SQL: "? IN (?)", Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "id"}, ids}})
+	}
 
-	// Apply sort
-	if len(sortFields) > 0 {
-		query = query.Order(fmt.Sprintf("%s %s", sortFields[0], order))
+	// Apply search
+	if q := models.SearchFromContext(ctx); q != "" {
+		if searchable, ok := any(s.modelType).(models.Searchable); ok {
+			query = query.Scopes(searchable.SearchScope(q))
+		}
 	}
 
 	// filter deleted entities
-	query = query.Where("is_deleted = ?", false)
+	query = query.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "is_deleted"}, Value: false})
 
-	// Get total count
-	if err := query.Count(&total).Error; err != nil {
+	// Get total count, before pagination as a count with an offset has no rows
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// Apply includes
+	query = s.applyIncludes(query, includes...)
+
+	// Apply the sparse fieldset and excludes
+	if selection != nil {
+		query = query.Select(selection.Columns)
+	}
+	query = s.applyExcludes(query, excludeColumns)
+
+	// Apply sort, newest first by default. The id breaks ties so pages don't shift between requests
+	desc := strings.EqualFold(order, "desc")
+	if len(sortColumns) > 0 {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: sortColumns[0]}, Desc: desc})
+	} else if _, err := fields.Resolve("created_at"); err == nil {
+		desc = true
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "created_at"}, Desc: true})
+	} else {
+		desc = true
+	}
+	if len(sortColumns) == 0 || sortColumns[0] != "id" {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Desc: desc})
+	}
+
+	// Apply pagination
+	if page > 0 && limit > 0 {
+		offset := (page - 1) * limit
+		query = query.Offset(offset).Limit(limit)
+	}
+
 	// Execute query
-	if err := query.Find(&entities).Error; err != nil {
-		return nil, 0, err
+	result := query.Find(&entities)
+	explainIfSlow(s.db, result, s.table, "list", time.Since(start))
+	if result.Error != nil {
+		return nil, 0, result.Error
 	}
 
 	return entities, total, nil
 }
 
-func (s *BaseServiceImpl[T]) Update(ctx context.Context, id string, entity *T, includes ...string) error {
-	if err := s.db.WithContext(ctx).Model(entity).Where("id = ? AND is_deleted = ?", id, false).Omit("id").Omit("teamId").Updates(entity).Error; err != nil {
+// Change is the payload of <table>.updated events, the entity before and after the update
+type Change[T any] struct {
+	Old *T `json:"old"`
+	New *T `json:"new"`
+}
+
+// ErrInvalidPatch is returned by Patch for fields that are unknown or can't be changed
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Update replaces the stored entity with entity. Fields left out are written as their zero
+// value, or their column default, while immutable fields such as the ID, team and creation
+// time keep their stored value. An entity carrying a version is only written over that
+// version, ErrStaleVersion is returned once another update moved it on. It returns
+// gorm.ErrRecordNotFound when there is no entity.
+func (s *BaseServiceImpl[T]) Update(ctx context.Context, id string, entity *T, includes ...string) (err error) {
+	defer func(start time.Time) { metrics.ObserveServiceOperation(s.table, "update", start, -1, err) }(time.Now())
+
+	fields, err := s.Fields()
+	if err != nil {
 		return err
 	}
 
+	var old T
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_deleted = ?", id, false).First(&old).Error; err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, &old); err != nil {
+			return err
+		}
+		sent, _ := rowVersion(entity)
+		if err := checkVersion(sent, &old); err != nil {
+			return err
+		}
+		// 🔒 Hooks can rely on the stored ID, team, timestamps and version being set
+		fields.replace(ctx, entity, &old)
+		if err := s.hooks.run(ctx, tx, HookBeforeUpdate, Change[T]{Old: &old, New: entity}); err != nil {
+			return err
+		}
+		dualWrite(entity)
+
+		result := tx.Model(entity).Where("id = ? AND is_deleted = ?", id, false).Scopes(ifMatchScope(ctx, &old), versionScope(&old)).
+			Select(fields.versioned(fields.UpdatableColumns())).Updates(entity)
+		if err := versionedResult(ctx, result); err != nil {
+			return err
+		}
+		return s.hooks.run(ctx, tx, HookAfterUpdate, Change[T]{Old: &old, New: entity})
+	}); err != nil {
+		return err
+	}
+
+	committed.run()
+
 	// Reload the entity with includes if any are specified
 	if len(includes) > 0 {
 		if err := s.applyIncludes(s.db.WithContext(ctx), includes...).First(entity, "id = ?", id).Error; err != nil {
@@ -154,17 +539,254 @@ func (s *BaseServiceImpl[T]) Update(ctx context.Context, id string, entity *T, i
 		}
 	}
 
-	events.Emit(fmt.Sprintf("%s.updated", GormTableName(s.db, s.modelType)), entity)
+	return nil
+}
+
+// Patch changes only the fields in changes, keyed by JSON or column name. Explicit nulls,
+// false and 0 are written. check runs on the patched entity before it is written, e.g. to
+// validate it. A "version" in changes is the version the patch is based on, as for Update.
+// It returns ErrInvalidPatch for fields that are unknown or immutable and
+// gorm.ErrRecordNotFound when there is no entity.
+func (s *BaseServiceImpl[T]) Patch(ctx context.Context, id string, changes map[string]interface{}, check func(entity *T) error, includes ...string) (_ *T, err error) {
+	defer func(start time.Time) { metrics.ObserveServiceOperation(s.table, "patch", start, -1, err) }(time.Now())
+
+	fields, err := s.Fields()
+	if err != nil {
+		return nil, err
+	}
+	sent, changes, err := takeVersion(changes)
+	if err != nil {
+		return nil, err
+	}
+	patch, columns, err := fields.patch(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	var old, entity T
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_deleted = ?", id, false).First(&old).Error; err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, &old); err != nil {
+			return err
+		}
+		if err := checkVersion(sent, &old); err != nil {
+			return err
+		}
+		// Loaded apart from old, so patching slices and maps leaves old untouched
+		if err := tx.Where("id = ?", id).First(&entity).Error; err != nil {
+			return err
+		}
+		if err := fields.apply(&entity, patch); err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(&entity); err != nil {
+				return err
+			}
+		}
+		if err := s.hooks.run(ctx, tx, HookBeforeUpdate, Change[T]{Old: &old, New: &entity}); err != nil {
+			return err
+		}
+		dualWrite(&entity)
+
+		// ✍️ Columns hooks and dual writes changed besides the patched ones are written with them
+		columns = append(columns, fields.changedColumns(ctx, &old, &entity)...)
+		result := tx.Model(&entity).Where("id = ? AND is_deleted = ?", id, false).Scopes(ifMatchScope(ctx, &old), versionScope(&old)).
+			Select(fields.versioned(columns)).Updates(&entity)
+		if err := versionedResult(ctx, result); err != nil {
+			return err
+		}
+		return s.hooks.run(ctx, tx, HookAfterUpdate, Change[T]{Old: &old, New: &entity})
+	}); err != nil {
+		return nil, err
+	}
+
+	committed.run()
+
+	if len(includes) > 0 {
+		if err := s.applyIncludes(s.db.WithContext(ctx), includes...).First(&entity, "id = ?", id).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &entity, nil
+}
+
+// Delete soft-deletes the entity and its cascade. It returns gorm.ErrRecordNotFound when
+// there is no entity, or another delete took it first.
+func (s *BaseServiceImpl[T]) Delete(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { metrics.ObserveServiceOperation(s.table, "delete", start, -1, err) }(time.Now())
+
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored T
+		if err := tx.Where("id = ? AND is_deleted = ?", id, false).First(&stored).Error; err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, &stored); err != nil {
+			return err
+		}
+		if err := s.hooks.run(ctx, tx, HookBeforeDelete, Change[T]{Old: &stored}); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		result := tx.Model(new(T)).Where("id = ? AND is_deleted = ?", id, false).Scopes(ifMatchScope(ctx, &stored)).
+			Updates(map[string]interface{}{"deleted_at": now, "is_deleted": true})
+		if err := writeResult(ctx, result); err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 🪢 Rows referencing it follow in the same transaction, see CascadeSoftDelete
+		if err := applyCascade(tx, s.modelType, s.table, id, now); err != nil {
+			return err
+		}
+		return s.hooks.run(ctx, tx, HookAfterDelete, Change[T]{Old: &stored})
+	}); err != nil {
+		return err
+	}
+
+	committed.run()
 
 	return nil
 }
 
-func (s *BaseServiceImpl[T]) Delete(ctx context.Context, id string) error {
-	if err := s.db.WithContext(ctx).Model(s.modelType).Where("id = ? AND is_deleted = ?", id, false).Update("deleted_at", time.Now()).Update("is_deleted", true).Error; err != nil {
+// teamScope limits a query to the rows of the actor's team, for models with a team. 🏢 Rows of
+// other teams are out of reach, even when their IDs are known.
+func teamScope(ctx context.Context, fields *FieldResolver) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if _, teamID, _ := ActorFromContext(ctx); teamID != "" {
+			if _, err := fields.Resolve("team_id"); err == nil {
+				return db.Where("team_id = ?", teamID)
+			}
+		}
+		return db
+	}
+}
+
+// MaxBulkDelete is the most IDs DeleteMany takes in one call
+const MaxBulkDelete = 500
+
+// ErrTooManyIDs is returned by DeleteMany for more than MaxBulkDelete IDs
+var ErrTooManyIDs = fmt.Errorf("at most %d ids can be deleted at once", MaxBulkDelete)
+
+// DeleteMany soft-deletes the entities with ids in one statement, with the cascade of each,
+// and returns the IDs it deleted. IDs of entities that are missing, already deleted or, for
+// models with a team, of another team than the actor's are skipped. The delete hooks run for
+// each entity, an error of one rolls back the whole call.
+func (s *BaseServiceImpl[T]) DeleteMany(ctx context.Context, ids []string) (deleted []string, err error) {
+	defer func(start time.Time) {
+		metrics.ObserveServiceOperation(s.table, "bulk_delete", start, len(deleted), err)
+	}(time.Now())
+
+	if len(ids) > MaxBulkDelete {
+		return nil, ErrTooManyIDs
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+	fields, err := s.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(new(T)).Where("id IN ? AND is_deleted = ?", ids, false).Scopes(teamScope(ctx, fields))
+		var entities []T
+		if err := query.Session(&gorm.Session{}).Find(&entities).Error; err != nil {
+			return err
+		}
+		deleted = make([]string, 0, len(entities))
+		if len(entities) == 0 {
+			return nil
+		}
+		for i := range entities {
+			if err := s.hooks.run(ctx, tx, HookBeforeDelete, Change[T]{Old: &entities[i]}); err != nil {
+				return err
+			}
+			deleted = append(deleted, entityID(&entities[i]))
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Model(new(T)).Where("id IN ?", deleted).
+			Updates(map[string]interface{}{"deleted_at": now, "is_deleted": true}).Error; err != nil {
+			return err
+		}
+		for i, id := range deleted {
+			if err := applyCascade(tx, s.modelType, s.table, id, now); err != nil {
+				return err
+			}
+			if err := s.hooks.run(ctx, tx, HookAfterDelete, Change[T]{Old: &entities[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	committed.run()
+	if len(deleted) > 0 {
+		events.Emit(fmt.Sprintf("%s.bulk_deleted", GormTableName(s.db, s.modelType)), deleted)
+	}
+
+	return deleted, nil
+}
+
+// Restore undoes a soft-delete and reverses its cascade. It returns gorm.ErrRecordNotFound
+// when there is no deleted entity with the ID, or, for models with a team, only one of another
+// team than the actor's, and models.ErrConflict when a live entity took one of its unique values
+// meanwhile, e.g. the email of a pending invite. The restore hooks run in its transaction.
+func (s *BaseServiceImpl[T]) Restore(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { metrics.ObserveServiceOperation(s.table, "restore", start, -1, err) }(time.Now())
+
+	fields, err := s.Fields()
+	if err != nil {
+		return err
+	}
+
+	ctx, committed := withCommitQueue(ctx)
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored T
+		if err := tx.Scopes(teamScope(ctx, fields)).Where("id = ? AND is_deleted = ?", id, true).First(&stored).Error; err != nil {
+			return err
+		}
+		if err := s.hooks.run(ctx, tx, HookBeforeRestore, Change[T]{Old: &stored}); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		result := tx.Model(new(T)).Where("id = ? AND is_deleted = ?", id, true).
+			Updates(map[string]interface{}{"deleted_at": nil, "is_deleted": false})
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: a live record has the same unique values", models.ErrConflict)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := revertCascade(tx, s.table, id, now); err != nil {
+			return err
+		}
+
+		var restored T
+		if err := tx.First(&restored, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return s.hooks.run(ctx, tx, HookAfterRestore, Change[T]{Old: &stored, New: &restored})
+	}); err != nil {
 		return err
 	}
 
-	events.Emit(fmt.Sprintf("%s.deleted", GormTableName(s.db, s.modelType)), id)
+	committed.run()
 
 	return nil
 }
@@ -0,0 +1,634 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func TestDeleteSetsDeletedAtInUTC(t *testing.T) {
+	testutil.UseTimeZone(t, testutil.IST)
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+
+	before := time.Now().UTC().Add(-time.Second)
+	if err := NewBaseService(gdb, models.Team{}).Delete(context.Background(), team.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted models.Team
+	if err := gdb.First(&deleted, "id = ?", team.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !deleted.IsDeleted || deleted.DeletedAt == nil {
+		t.Fatalf("isDeleted %v, deletedAt %v", deleted.IsDeleted, deleted.DeletedAt)
+	}
+	if deleted.DeletedAt.Before(before) || deleted.DeletedAt.After(time.Now().UTC().Add(time.Second)) {
+		t.Errorf("deletedAt %v is not now", deleted.DeletedAt)
+	}
+}
+
+func TestListReturnsATeamWithSeveralUsersOnce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	for range 3 {
+		testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	}
+	testutil.CreateTeam(t, gdb, "Globex")
+	testutil.CreateTeam(t, gdb, "Initech")
+
+	service := NewBaseService(gdb, models.Team{})
+	seen := map[string]int{}
+	for page := 1; page <= 4; page++ {
+		teams, total, err := service.List(context.Background(), page, 1, map[string]interface{}{}, map[string]bool{}, nil, "", "Users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 3 {
+			t.Errorf("page %d: total %d, want 3", page, total)
+		}
+		for _, team := range teams {
+			seen[team.ID]++
+			if team.ID == acme.ID && len(team.Users) != 3 {
+				t.Errorf("Acme has %d users, want 3", len(team.Users))
+			}
+		}
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("listed %d teams, want 3", len(seen))
+	}
+	if seen[acme.ID] != 1 {
+		t.Errorf("Acme listed %d times, want once", seen[acme.ID])
+	}
+}
+
+func TestListFiltersOnAHasManyRelationOnce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	testutil.CreateTeam(t, gdb, "Initech")
+	for _, teamID := range []string{acme.ID, acme.ID, globex.ID} {
+		user := testutil.CreateUser(t, gdb, teamID, models.UserRoleMember)
+		if err := gdb.Model(user).Update("first_name", "Ada").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+
+	service := NewBaseService(gdb, models.Team{})
+	for _, filter := range []string{"users.firstName", "users.first_name"} {
+		t.Run(filter, func(t *testing.T) {
+			seen := map[string]int{}
+			for page := 1; page <= 3; page++ {
+				teams, total, err := service.List(context.Background(), page, 1, map[string]interface{}{filter: "Ada"}, map[string]bool{}, nil, "", "Users")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != 2 {
+					t.Errorf("page %d: total %d, want 2", page, total)
+				}
+				for _, team := range teams {
+					seen[team.ID]++
+				}
+			}
+			if len(seen) != 2 || seen[acme.ID] != 1 || seen[globex.ID] != 1 {
+				t.Errorf("listed %v, want Acme and Globex once", seen)
+			}
+		})
+	}
+
+	// 🔒 Related records of other teams never match
+	teams, total, err := service.List(WithActor(context.Background(), "", acme.ID, ""), 1, 10, map[string]interface{}{"users.firstName": "Ada"}, map[string]bool{}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(teams) != 1 || teams[0].ID != acme.ID {
+		t.Errorf("as Acme listed %d teams of %d, want Acme only", len(teams), total)
+	}
+}
+
+func TestIncludesOnlyLoadTheActorsTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	for range 2 {
+		testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	}
+	template := &models.Template{TeamID: acme.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	teams := NewBaseService(gdb, models.Team{})
+	templates := NewBaseService(gdb, models.Template{})
+
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		wanted int
+	}{
+		{"no actor", context.Background(), 2},
+		{"own team", WithActor(context.Background(), "", acme.ID, ""), 2},
+		// 🏢 Users of another team never come along with an include
+		{"other team", WithActor(context.Background(), "", globex.ID, ""), 0},
+	}
+	for _, c := range cases {
+		team, err := teams.Get(c.ctx, acme.ID, "Users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(team.Users) != c.wanted {
+			t.Errorf("%s: Users has %d users, want %d", c.name, len(team.Users), c.wanted)
+		}
+		// Every level of a nested include is scoped
+		loaded, err := templates.Get(c.ctx, template.ID, "Team.Users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Team == nil || len(loaded.Team.Users) != c.wanted {
+			t.Errorf("%s: Team.Users loaded %+v, want %d users", c.name, loaded.Team, c.wanted)
+		}
+	}
+}
+
+func TestListRejectsNamesThatAreNotColumns(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	testutil.CreateTeam(t, gdb, "Globex")
+	service := NewBaseService(gdb, models.Team{})
+
+	list := func(filters map[string]interface{}, excludes map[string]bool, sort []string, order string) ([]models.Team, error) {
+		teams, _, err := service.List(context.Background(), 1, 10, filters, excludes, sort, order)
+		return teams, err
+	}
+	for name, err := range map[string]error{
+		"filter":   func() error { _, err := list(map[string]interface{}{"1=1) OR (1": 1}, nil, nil, ""); return err }(),
+		"sort":     func() error { _, err := list(nil, nil, []string{"name; DROP TABLE teams"}, ""); return err }(),
+		"exclude":  func() error { _, err := list(nil, map[string]bool{"id\" --": true}, nil, ""); return err }(),
+		"order":    func() error { _, err := list(nil, nil, []string{"name"}, "desc; DROP TABLE teams"); return err }(),
+		"relation": func() error { _, err := list(nil, nil, []string{"Users"}, ""); return err }(),
+		"related":  func() error { _, err := list(nil, nil, []string{"users.firstName"}, ""); return err }(),
+	} {
+		if !errors.Is(err, ErrInvalidListQuery) {
+			t.Errorf("%s: got %v, want ErrInvalidListQuery", name, err)
+		}
+	}
+
+	// JSON and column names filter and sort alike
+	if err := gdb.Model(acme).Update("sandbox_mode", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sandboxMode", "sandbox_mode"} {
+		teams, err := list(map[string]interface{}{name: true}, nil, []string{name}, "DESC")
+		if err != nil || len(teams) != 1 || teams[0].ID != acme.ID {
+			t.Errorf("%s: got %d teams and %v", name, len(teams), err)
+		}
+	}
+	var count int64
+	if err := gdb.Model(&models.Team{}).Count(&count).Error; err != nil || count != 2 {
+		t.Errorf("teams table has %d rows and %v", count, err)
+	}
+}
+
+func TestCreateAndUpdateDualWriteNewColumns(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.File{})
+	ctx := context.Background()
+
+	file := models.File{TeamID: team.ID, Path: "a1", Name: "Q3 Report.PDF", Size: 10, Type: "application/pdf"}
+	if err := service.Create(ctx, &file); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := service.Get(ctx, file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Extension != "pdf" {
+		t.Errorf("extension %q after create, want pdf", stored.Extension)
+	}
+
+	if _, err := service.Patch(ctx, file.ID, map[string]interface{}{"name": "notes.tar.gz"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 🔧 Patches that leave the name alone leave the extension alone too
+	if _, err := service.Patch(ctx, file.ID, map[string]interface{}{"size": 20}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = service.Get(ctx, file.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Extension != "gz" {
+		t.Errorf("extension %q after renaming, want gz", stored.Extension)
+	}
+}
+
+func TestUpdateReplacesEntityAndKeepsImmutableFields(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	service := NewBaseService(gdb, models.Team{})
+	ctx := context.Background()
+	team := models.Team{Name: "Acme", StoragePolicy: models.StoragePolicyPrivateOnly, SandboxMode: true}
+	if err := service.Create(ctx, &team); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := make(chan Change[models.Team], 1)
+	events.OnNamed("teams.updated", t.Name(), func(data interface{}) {
+		select {
+		case updated <- data.(Change[models.Team]):
+		default:
+		}
+	})
+
+	// 🔄 The body names another ID and slug, both are ignored
+	if err := service.Update(ctx, team.ID, &models.Team{Base: models.Base{ID: "other"}, Name: "Acme Corp", Slug: "taken"}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := service.Get(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Acme Corp" || stored.Slug != team.Slug || stored.Plan != "free" || !stored.CreatedAt.Equal(team.CreatedAt.Time) {
+		t.Errorf("got name %q slug %q plan %q created %v, want the stored slug, plan and creation time", stored.Name, stored.Slug, stored.Plan, stored.CreatedAt)
+	}
+	// Fields left out are reset, to their column default where there is one
+	if stored.SandboxMode || stored.StoragePolicy != models.StoragePolicyPublicAllowed {
+		t.Errorf("sandbox mode %v storage policy %s, want the defaults", stored.SandboxMode, stored.StoragePolicy)
+	}
+
+	select {
+	case change := <-updated:
+		if change.Old.Name != "Acme" || !change.Old.SandboxMode || change.New.Name != "Acme Corp" {
+			t.Errorf("event old %q new %q", change.Old.Name, change.New.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no teams.updated event")
+	}
+
+	if err := service.Update(ctx, "00000000-0000-0000-0000-000000000000", &models.Team{Name: "Ghost"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("got %v updating a missing team, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestPatchWritesOnlyGivenFields(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	service := NewBaseService(gdb, models.Team{})
+	ctx := context.Background()
+	team := models.Team{Name: "Acme", StoragePolicy: models.StoragePolicyPrivateOnly, SandboxMode: true, AuthPolicy: models.AuthPolicy{SessionMaxAge: 30}}
+	if err := service.Create(ctx, &team); err != nil {
+		t.Fatal(err)
+	}
+
+	// 🩹 false and 0 are written, not skipped as zero values
+	patched, err := service.Patch(ctx, team.ID, map[string]interface{}{
+		"sandbox_mode": false,
+		"authPolicy":   map[string]interface{}{"sessionMaxAge": 0},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := service.Get(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []*models.Team{patched, stored} {
+		if got.SandboxMode || got.AuthPolicy.SessionMaxAge != 0 {
+			t.Errorf("sandbox mode %v session max age %d, want both cleared", got.SandboxMode, got.AuthPolicy.SessionMaxAge)
+		}
+		if got.Name != "Acme" || got.StoragePolicy != models.StoragePolicyPrivateOnly {
+			t.Errorf("name %q storage policy %s changed", got.Name, got.StoragePolicy)
+		}
+	}
+
+	// check sees the patched entity and its error aborts the patch
+	rejected := errors.New("rejected")
+	_, err = service.Patch(ctx, team.ID, map[string]interface{}{"name": "X"}, func(team *models.Team) error {
+		if team.Name != "X" {
+			t.Errorf("check got name %q", team.Name)
+		}
+		return rejected
+	})
+	if !errors.Is(err, rejected) {
+		t.Errorf("got %v, want the check's error", err)
+	}
+	if stored, _ := service.Get(ctx, team.ID); stored.Name != "Acme" {
+		t.Errorf("name %q after a rejected patch", stored.Name)
+	}
+}
+
+func TestPatchRejectsUnknownAndImmutableFields(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Team{})
+	ctx := context.Background()
+
+	for _, changes := range []map[string]interface{}{
+		{},
+		{"colour": "red"},
+		{"id": "00000000-0000-0000-0000-000000000000"},
+		{"createdAt": "2020-01-01T00:00:00Z"},
+		{"slug": "other"},
+		{"plan": "enterprise"},
+		{"sandboxMode": true, "sandbox_mode": false},
+	} {
+		if _, err := service.Patch(ctx, team.ID, changes, nil); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%v: got %v, want ErrInvalidPatch", changes, err)
+		}
+	}
+
+	if _, err := NewBaseService(gdb, models.Template{}).Patch(ctx, team.ID, map[string]interface{}{"teamId": team.ID}, nil); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("got %v patching teamId, want ErrInvalidPatch", err)
+	}
+	if _, err := service.Patch(ctx, "00000000-0000-0000-0000-000000000000", map[string]interface{}{"name": "Ghost"}, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("got %v patching a missing team, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestDeleteManyStaysInTheActorsTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	service := NewBaseService(gdb, models.Template{})
+
+	template := func(teamID, name string) *models.Template {
+		template := &models.Template{TeamID: teamID, Name: name, Subject: name, HTML: "<p>" + name + "</p>"}
+		if err := gdb.Create(template).Error; err != nil {
+			t.Fatal(err)
+		}
+		return template
+	}
+	first, second, kept := template(acme.ID, "First"), template(acme.ID, "Second"), template(acme.ID, "Kept")
+	foreign := template(globex.ID, "Foreign")
+
+	bulkDeleted := make(chan []string, 1)
+	events.OnNamed("templates.bulk_deleted", t.Name(), func(data interface{}) {
+		select {
+		case bulkDeleted <- data.([]string):
+		default:
+		}
+	})
+
+	ctx := WithActor(context.Background(), "", acme.ID, "")
+	deleted, err := service.DeleteMany(ctx, []string{first.ID, second.ID, foreign.ID, "00000000-0000-0000-0000-000000000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 🏢 The other team's template and the unknown ID are skipped
+	if len(deleted) != 2 {
+		t.Errorf("deleted %v, want the two templates of Acme", deleted)
+	}
+	for _, template := range []*models.Template{first, second, kept, foreign} {
+		var stored models.Template
+		if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		want := template == first || template == second
+		if stored.IsDeleted != want || (stored.DeletedAt != nil) != want {
+			t.Errorf("%s: isDeleted %v deletedAt %v, want deleted %v", template.Name, stored.IsDeleted, stored.DeletedAt, want)
+		}
+	}
+	select {
+	case ids := <-bulkDeleted:
+		if len(ids) != 2 {
+			t.Errorf("event carried %v", ids)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no templates.bulk_deleted event")
+	}
+
+	// Deleting again is a no-op
+	if deleted, err := service.DeleteMany(ctx, []string{first.ID}); err != nil || len(deleted) != 0 {
+		t.Errorf("deleted %v and %v, want nothing", deleted, err)
+	}
+	if _, err := service.DeleteMany(ctx, make([]string, MaxBulkDelete+1)); !errors.Is(err, ErrTooManyIDs) {
+		t.Errorf("got %v for %d ids, want ErrTooManyIDs", err, MaxBulkDelete+1)
+	}
+}
+
+func TestGetManyKeepsTheOrderOfTheIDs(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	service := NewBaseService(gdb, models.Template{})
+
+	template := func(teamID, name string) string {
+		template := &models.Template{TeamID: teamID, Name: name, Subject: name, HTML: "<p>" + name + "</p>"}
+		if err := gdb.Create(template).Error; err != nil {
+			t.Fatal(err)
+		}
+		return template.ID
+	}
+	first, second, third := template(acme.ID, "First"), template(acme.ID, "Second"), template(acme.ID, "Third")
+	deleted, foreign := template(acme.ID, "Deleted"), template(globex.ID, "Foreign")
+	if err := service.Delete(context.Background(), deleted); err != nil {
+		t.Fatal(err)
+	}
+	missing := "00000000-0000-0000-0000-000000000000"
+
+	// 🔎 Missing, deleted, foreign and repeated IDs are simply absent
+	ctx := WithActor(context.Background(), "", acme.ID, "")
+	templates, err := service.GetMany(ctx, []string{third, missing, first, deleted, foreign, strings.ToUpper(third), second}, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, template := range templates {
+		names = append(names, template.Name)
+		if template.Team == nil || template.Team.ID != acme.ID {
+			t.Errorf("%s: team %+v, want Acme included", template.Name, template.Team)
+		}
+	}
+	if want := []string{"Third", "First", "Second"}; !slices.Equal(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	if templates, err := service.GetMany(WithFields(ctx, "name"), []string{second, first}); err != nil || len(templates) != 2 || templates[0].Name != "Second" || templates[0].Subject != "" {
+		t.Errorf("sparse GetMany: %+v, %v", templates, err)
+	}
+	if _, err := service.GetMany(ctx, []string{first, "first"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("got %v for a non-UUID, want ErrInvalidID", err)
+	}
+	ids := make([]string, MaxGetMany+1)
+	for i := range ids {
+		ids[i] = missing
+	}
+	if _, err := service.GetMany(ctx, ids); !errors.Is(err, ErrTooManyGetIDs) {
+		t.Errorf("got %v for %d ids, want ErrTooManyGetIDs", err, len(ids))
+	}
+	if templates, err := service.GetMany(ctx, ids[:MaxGetMany]); err != nil || len(templates) != 0 {
+		t.Errorf("got %v and %v for %d missing ids, want none", templates, err, MaxGetMany)
+	}
+}
+
+func TestRestoreConflictsWithLiveUniqueValues(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	inviter := testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	service := NewBaseService(gdb, models.TeamInvite{})
+	ctx := context.Background()
+
+	invite := func() *models.TeamInvite {
+		invite := &models.TeamInvite{
+			Email: "invitee@example.com", Name: "Invitee", TeamID: team.ID, InviterID: inviter.ID, Role: models.UserRoleMember,
+			Code: "1234", Status: models.InviteStatusPending, ExpiresAt: time.Now().UTC().Add(time.Hour),
+		}
+		if err := gdb.Create(invite).Error; err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+	deleted := invite()
+	if err := service.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 📨 The email was invited again meanwhile, two pending invites can't coexist
+	replacement := invite()
+
+	if err := service.Restore(ctx, deleted.ID); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("got %v, want models.ErrConflict", err)
+	}
+	if err := service.Delete(ctx, replacement.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Restore(ctx, deleted.ID); err != nil {
+		t.Fatalf("restore once the email is free: %v", err)
+	}
+	if err := service.Restore(ctx, deleted.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("got %v restoring a live invite, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestIfMatchRejectsStaleVersions(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	service := NewBaseService(gdb, models.Template{})
+	read, err := service.Get(context.Background(), template.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := EntityTag(read)
+
+	// ✏️ Someone else saves first, moving the version on
+	time.Sleep(time.Millisecond)
+	if _, err := service.Patch(WithIfMatch(context.Background(), stale), template.ID, map[string]any{"subject": "Hello"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Patch(WithIfMatch(context.Background(), stale), template.ID, map[string]any{"subject": "Lost"}, nil); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("patch with a stale tag: got %v, want ErrPreconditionFailed", err)
+	}
+	replaced := *read
+	replaced.Subject = "Lost"
+	if err := service.Update(WithIfMatch(context.Background(), stale), template.ID, &replaced); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("update with a stale tag: got %v, want ErrPreconditionFailed", err)
+	}
+	if err := service.Delete(WithIfMatch(context.Background(), stale), template.ID); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("delete with a stale tag: got %v, want ErrPreconditionFailed", err)
+	}
+
+	current, err := service.Get(context.Background(), template.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Subject != "Hello" {
+		t.Errorf("subject %q, want the first save's Hello", current.Subject)
+	}
+	if err := service.Delete(WithIfMatch(context.Background(), `"other", `+EntityTag(current)), template.ID); err != nil {
+		t.Errorf("delete with the current tag: %v", err)
+	}
+}
+
+func TestConcurrentUpdatesOfOneVersion(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Team{})
+	read, err := service.Get(context.Background(), team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Version != 1 {
+		t.Fatalf("created at version %d, want 1", read.Version)
+	}
+
+	// 🏁 Two admins save their edit of the same version at once
+	start := make(chan struct{})
+	errs := make(chan error, 2)
+	var wg sync.WaitGroup
+	for _, name := range []string{"Acme North", "Acme South"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			edit := *read
+			edit.Name = name
+			<-start
+			errs <- service.Update(context.Background(), team.ID, &edit)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	var saved, stale int
+	for err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case errors.Is(err, ErrStaleVersion):
+			stale++
+		default:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if saved != 1 || stale != 1 {
+		t.Fatalf("%d saved and %d stale, want one of each", saved, stale)
+	}
+
+	stored, err := service.Get(context.Background(), team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != 2 {
+		t.Errorf("version %d after one update, want 2", stored.Version)
+	}
+
+	// The loser reloads and patches the version it read now
+	if _, err := service.Patch(context.Background(), team.ID, map[string]any{"name": "Acme East", "version": 1}, nil); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("patch of version 1: got %v, want ErrStaleVersion", err)
+	}
+	patched, err := service.Patch(context.Background(), team.ID, map[string]any{"name": "Acme East", "version": json.Number("2")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Version != 3 || patched.Name != "Acme East" {
+		t.Errorf("patched %q at version %d, want Acme East at 3", patched.Name, patched.Version)
+	}
+	if _, err := service.Patch(context.Background(), team.ID, map[string]any{"name": "Acme", "version": "latest"}, nil); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("patch of version \"latest\": got %v, want ErrInvalidPatch", err)
+	}
+
+	// Deletes count as updates too
+	if err := service.Delete(context.Background(), team.ID); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err := gdb.Model(&models.Team{}).Where("id = ?", team.ID).Pluck("version", &version).Error; err != nil {
+		t.Fatal(err)
+	}
+	if version != 4 {
+		t.Errorf("version %d after deleting, want 4", version)
+	}
+}
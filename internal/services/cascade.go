@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CascadeAction is what soft-deleting a row does to the rows referencing it
+type CascadeAction string
+
+const (
+	// CascadeDelete soft-deletes the rows
+	CascadeDelete CascadeAction = "delete"
+	// CascadeDeactivate clears the rows' active flag, they are kept but can no longer be used
+	CascadeDeactivate CascadeAction = "deactivate"
+	// CascadeExpire marks pending rows expired, e.g. invites
+	CascadeExpire CascadeAction = "expire"
+	// CascadeRetain keeps the rows as they are, access checks on the parent keep them out of reach
+	CascadeRetain CascadeAction = "retain"
+)
+
+// cascadeBatchSize bounds the IDs changed per statement
+const cascadeBatchSize = 500
+
+// CascadeRelation is a model referencing the soft-deleted model through ForeignKey
+type CascadeRelation struct {
+	Model      any
+	ForeignKey string
+	Action     CascadeAction
+	// Flag is the column CascadeDeactivate clears, is_active when empty
+	Flag string
+}
+
+func (r CascadeRelation) flag() string {
+	if r.Flag == "" {
+		return "is_active"
+	}
+	return r.Flag
+}
+
+var cascades = struct {
+	sync.RWMutex
+	relations map[reflect.Type][]CascadeRelation
+}{relations: map[reflect.Type][]CascadeRelation{}}
+
+// CascadeSoftDelete sets what soft-deleting a row of the parent model does to its relations,
+// replacing the relations set before. BaseService.Delete applies them in the transaction
+// deleting the parent and BaseService.Restore reverses them.
+func CascadeSoftDelete(parent any, relations ...CascadeRelation) {
+	cascades.Lock()
+	defer cascades.Unlock()
+	cascades.relations[modelType(parent)] = relations
+}
+
+// cascadeRelations returns the relations of a model set with CascadeSoftDelete
+func cascadeRelations(model any) []CascadeRelation {
+	cascades.RLock()
+	defer cascades.RUnlock()
+	return cascades.relations[modelType(model)]
+}
+
+func modelType(model any) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func init() {
+	// 🏢 Nobody can act in a deleted team, its data is kept for when it is restored
+	CascadeSoftDelete(models.Team{},
+		CascadeRelation{Model: models.User{}, ForeignKey: "team_id", Action: CascadeDeactivate},
+		CascadeRelation{Model: models.TeamInvite{}, ForeignKey: "team_id", Action: CascadeExpire},
+		CascadeRelation{Model: models.File{}, ForeignKey: "team_id", Action: CascadeRetain},
+		CascadeRelation{Model: models.FileShare{}, ForeignKey: "team_id", Action: CascadeDelete},
+		CascadeRelation{Model: models.WebhookSubscription{}, ForeignKey: "team_id", Action: CascadeDeactivate, Flag: "active"},
+	)
+}
+
+// applyCascade changes the relations of a soft-deleted parent and records the rows it changed
+func applyCascade(tx *gorm.DB, parent any, parentTable, parentID string, now time.Time) error {
+	for _, relation := range cascadeRelations(parent) {
+		if relation.Action == CascadeRetain {
+			continue
+		}
+
+		model := reflect.New(modelType(relation.Model)).Interface()
+		query := tx.Model(model).
+			Where(clause.Eq{Column: clause.Column{Name: relation.ForeignKey}, Value: parentID}).
+			Where("is_deleted = ?", false)
+		var updates map[string]interface{}
+		flag := ""
+		switch relation.Action {
+		case CascadeDelete:
+			updates = map[string]interface{}{"is_deleted": true, "deleted_at": now}
+		case CascadeDeactivate:
+			flag = relation.flag()
+			query = query.Where(clause.Eq{Column: clause.Column{Name: flag}, Value: true})
+			updates = map[string]interface{}{flag: false}
+		case CascadeExpire:
+			query = query.Where("status = ?", models.InviteStatusPending)
+			updates = map[string]interface{}{"status": models.InviteStatusExpired}
+		default:
+			return fmt.Errorf("unknown cascade action %q", relation.Action)
+		}
+
+		var ids []string
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		childTable := stmt.Schema.Table
+		for start := 0; start < len(ids); start += cascadeBatchSize {
+			batch := ids[start:min(start+cascadeBatchSize, len(ids))]
+			if err := tx.Model(model).Where("id IN ?", batch).Updates(updates).Error; err != nil {
+				return err
+			}
+			records := make([]models.CascadeRecord, len(batch))
+			for i, id := range batch {
+				records[i] = models.CascadeRecord{
+					ParentTable: parentTable,
+					ParentID:    parentID,
+					ChildTable:  childTable,
+					ChildID:     id,
+					Action:      string(relation.Action),
+					Flag:        flag,
+				}
+			}
+			if err := tx.Create(&records).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revertCascade reverses the changes recorded when the parent was soft-deleted
+func revertCascade(tx *gorm.DB, parentTable, parentID string, now time.Time) error {
+	var records []models.CascadeRecord
+	if err := tx.Where("parent_table = ? AND parent_id = ?", parentTable, parentID).Find(&records).Error; err != nil {
+		return err
+	}
+
+	// 🔁 Rows changed the same way are reverted together
+	type change struct{ table, action, flag string }
+	groups := map[change][]string{}
+	var order []change
+	for _, record := range records {
+		key := change{record.ChildTable, record.Action, record.Flag}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], record.ChildID)
+	}
+
+	for _, key := range order {
+		query := tx.Table(key.table)
+		updates := map[string]interface{}{"updated_at": now}
+		switch CascadeAction(key.action) {
+		case CascadeDelete:
+			query = query.Where("is_deleted = ?", true)
+			updates["is_deleted"] = false
+			updates["deleted_at"] = nil
+		case CascadeDeactivate:
+			updates[key.flag] = true
+		case CascadeExpire:
+			// Invites that were accepted or declined meanwhile are left alone
+			query = query.Where("status = ?", models.InviteStatusExpired)
+			updates["status"] = models.InviteStatusPending
+		default:
+			return fmt.Errorf("unknown cascade action %q", key.action)
+		}
+		ids := groups[key]
+		for start := 0; start < len(ids); start += cascadeBatchSize {
+			batch := ids[start:min(start+cascadeBatchSize, len(ids))]
+			if err := query.Session(&gorm.Session{}).Where("id IN ?", batch).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Where("parent_table = ? AND parent_id = ?", parentTable, parentID).Delete(&models.CascadeRecord{}).Error
+}
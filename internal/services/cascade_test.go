@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// reload reads a row back from the database
+func reload[T any](t *testing.T, gdb *gorm.DB, id string) T {
+	t.Helper()
+	var row T
+	if err := gdb.First(&row, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestDeletingATeamCascadesAndRestoreReversesIt(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Globex")
+	member := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	deactivated := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	outsider := testutil.CreateUser(t, gdb, other.ID, models.UserRoleMember)
+	if err := gdb.Model(deactivated).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	invite := func(teamID, email string, status models.InviteStatus) *models.TeamInvite {
+		invite := &models.TeamInvite{
+			Email: email, Name: "Invitee", TeamID: teamID, InviterID: member.ID, Role: models.UserRoleMember,
+			Code: email, Status: status, ExpiresAt: time.Now().UTC().Add(24 * time.Hour),
+		}
+		if err := gdb.Create(invite).Error; err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+	pending := invite(team.ID, "pending@example.com", models.InviteStatusPending)
+	accepted := invite(team.ID, "accepted@example.com", models.InviteStatusAccepted)
+	otherPending := invite(other.ID, "pending@example.com", models.InviteStatusPending)
+
+	file := &models.File{TeamID: team.ID, Path: "acme/report.pdf", Name: "report.pdf", Type: "application/pdf"}
+	if err := gdb.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	share := &models.FileShare{FileID: file.ID, TeamID: team.ID, CreatedByID: member.ID, Token: "share-token"}
+	webhook := &models.WebhookSubscription{TeamID: team.ID, URL: "https://hooks.example.com", Secret: "shh", Events: []string{"files.created"}, Active: true}
+	for _, row := range []interface{}{share, webhook} {
+		if err := gdb.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service := NewBaseService(gdb, models.Team{})
+	if err := service.Delete(context.Background(), team.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if user := reload[models.User](t, gdb, member.ID); user.IsActive {
+		t.Error("member of the deleted team is still active")
+	}
+	if user := reload[models.User](t, gdb, outsider.ID); !user.IsActive {
+		t.Error("member of another team was deactivated")
+	}
+	if inv := reload[models.TeamInvite](t, gdb, pending.ID); inv.Status != models.InviteStatusExpired {
+		t.Errorf("pending invite is %s, want EXPIRED", inv.Status)
+	}
+	if inv := reload[models.TeamInvite](t, gdb, otherPending.ID); inv.Status != models.InviteStatusPending {
+		t.Errorf("invite to another team is %s", inv.Status)
+	}
+	if share := reload[models.FileShare](t, gdb, share.ID); !share.IsDeleted {
+		t.Error("file share of the deleted team still resolves")
+	}
+	if webhook := reload[models.WebhookSubscription](t, gdb, webhook.ID); webhook.Active {
+		t.Error("webhook of the deleted team is still active")
+	}
+	if file := reload[models.File](t, gdb, file.ID); file.IsDeleted {
+		t.Error("file of the deleted team was deleted, it should be retained")
+	}
+
+	// ✅ Restoring brings back what the cascade changed and nothing else
+	if err := service.Restore(context.Background(), team.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.GetTeamByID(team.ID, gdb); err != nil {
+		t.Fatalf("team is not restored: %v", err)
+	}
+	if user := reload[models.User](t, gdb, member.ID); !user.IsActive {
+		t.Error("member was not reactivated")
+	}
+	if user := reload[models.User](t, gdb, deactivated.ID); user.IsActive {
+		t.Error("user deactivated before the team was deleted got reactivated")
+	}
+	if inv := reload[models.TeamInvite](t, gdb, pending.ID); inv.Status != models.InviteStatusPending {
+		t.Errorf("invite is %s after the restore, want PENDING", inv.Status)
+	}
+	if inv := reload[models.TeamInvite](t, gdb, accepted.ID); inv.Status != models.InviteStatusAccepted {
+		t.Errorf("accepted invite is %s after the restore", inv.Status)
+	}
+	gotShare := reload[models.FileShare](t, gdb, share.ID)
+	gotWebhook := reload[models.WebhookSubscription](t, gdb, webhook.ID)
+	if gotShare.IsDeleted || !gotWebhook.Active {
+		t.Errorf("share deleted %v, webhook active %v after the restore", gotShare.IsDeleted, gotWebhook.Active)
+	}
+
+	var records int64
+	gdb.Model(&models.CascadeRecord{}).Count(&records)
+	if records != 0 {
+		t.Errorf("%d cascade records left after the restore", records)
+	}
+
+	if err := service.Restore(context.Background(), team.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("restoring a team that is not deleted returned %v", err)
+	}
+}
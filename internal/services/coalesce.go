@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"be0/internal/models"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CoalescingService shares one Get between concurrent callers asking for the same
+// entity with the same fields, so they cost one query and one URL presign.
+// Gets with includes are not shared, their relations would be.
+type CoalescingService[T any] struct {
+	BaseService[T]
+	table string
+	group singleflight.Group
+}
+
+// NewCoalescingService wraps a service so identical concurrent Gets are coalesced
+func NewCoalescingService[T any](service BaseService[T], table string) BaseService[T] {
+	return &CoalescingService[T]{BaseService: service, table: table}
+}
+
+func (s *CoalescingService[T]) Get(ctx context.Context, id string, includes ...string) (*T, error) {
+	if len(includes) > 0 {
+		return s.BaseService.Get(ctx, id, includes...)
+	}
+
+	key := s.table + ":" + id
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		// 🧩 A sparse fieldset loads other columns
+		sortedFields := append([]string(nil), fields...)
+		sort.Strings(sortedFields)
+		key += ":fields=" + strings.Join(sortedFields, ",")
+	}
+	if models.PrefersReplica(ctx) {
+		// Signed URLs differ per storage region
+		key += ":replica"
+	}
+
+	// The shared call must not be cut short when the caller that started it goes away
+	shared := context.WithoutCancel(ctx)
+	result, err, _ := s.group.Do(key, func() (interface{}, error) {
+		return s.BaseService.Get(shared, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every waiter gets its own copy of the struct, so setting a field doesn't reach the
+	// others. Pointers, slices and maps in it are shared and must not be modified through.
+	entity := *result.(*T)
+	return &entity, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// countTeamQueries counts queries against the teams table, each one held for delay so
+// concurrent callers overlap
+func countTeamQueries(t *testing.T, gdb *gorm.DB, delay time.Duration) *atomic.Int64 {
+	t.Helper()
+	var count atomic.Int64
+	err := gdb.Callback().Query().Before("gorm:query").Register("test:count_teams", func(tx *gorm.DB) {
+		if tx.Statement.Table == "teams" {
+			count.Add(1)
+			time.Sleep(delay)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &count
+}
+
+// getConcurrently runs n Gets released at the same time and returns their results
+func getConcurrently(t *testing.T, service BaseService[models.Team], n int, id string, includes ...string) []*models.Team {
+	t.Helper()
+	results := make([]*models.Team, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			team, err := service.Get(context.Background(), id, includes...)
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			results[i] = team
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return results
+}
+
+func TestCoalescingServiceSharesConcurrentGets(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	queries := countTeamQueries(t, gdb, 200*time.Millisecond)
+	service := NewCoalescingService(NewBaseService(gdb, models.Team{}), "teams")
+
+	results := getConcurrently(t, service, 20, team.ID)
+
+	if got := queries.Load(); got != 1 {
+		t.Errorf("20 concurrent Gets ran %d queries, want 1", got)
+	}
+	for i, result := range results {
+		if result == nil || result.ID != team.ID {
+			t.Fatalf("waiter %d got %+v, want team %s", i, result, team.ID)
+		}
+	}
+
+	// Waiters get copies, changing one result leaves the others alone
+	results[0].Name = "Changed"
+	if results[1].Name != "Acme" {
+		t.Errorf("waiters share the same entity, got name %q", results[1].Name)
+	}
+}
+
+func TestCoalescingServiceDoesNotShareGetsWithIncludes(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	queries := countTeamQueries(t, gdb, 200*time.Millisecond)
+	service := NewCoalescingService(NewBaseService(gdb, models.Team{}), "teams")
+
+	results := getConcurrently(t, service, 5, team.ID, "Users")
+
+	if got := queries.Load(); got != 5 {
+		t.Errorf("5 concurrent Gets with includes ran %d team queries, want 5", got)
+	}
+	// 🔗 A copy of the team would still share its included users
+	results[0].Users[0].FirstName = "Changed"
+	if results[1].Users[0].FirstName != "Test" {
+		t.Errorf("waiters share included users, got first name %q", results[1].Users[0].FirstName)
+	}
+}
+
+func TestCoalescingServiceKeepsIncludesPerTeam(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	globex := testutil.CreateTeam(t, gdb, "Globex")
+	testutil.CreateUser(t, gdb, acme.ID, models.UserRoleMember)
+	countTeamQueries(t, gdb, 200*time.Millisecond)
+	service := NewCoalescingService(NewBaseService(gdb, models.Team{}), "teams")
+
+	users := map[string]*atomic.Int64{acme.ID: {}, globex.ID: {}}
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for teamID, count := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			team, err := service.Get(WithActor(context.Background(), "", teamID, ""), acme.ID, "Users")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			count.Store(int64(len(team.Users)))
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// 🏢 A concurrent Get of another team doesn't share its included users
+	if acmeUsers, globexUsers := users[acme.ID].Load(), users[globex.ID].Load(); acmeUsers != 1 || globexUsers != 0 {
+		t.Errorf("Acme saw %d users and Globex %d, want 1 and 0", acmeUsers, globexUsers)
+	}
+}
+
+func TestCoalescingServiceDoesNotCacheSequentialGets(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	queries := countTeamQueries(t, gdb, 0)
+	service := NewCoalescingService(NewBaseService(gdb, models.Team{}), "teams")
+
+	for i := 0; i < 3; i++ {
+		if _, err := service.Get(context.Background(), team.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := queries.Load(); got != 3 {
+		t.Errorf("3 sequential Gets ran %d queries, want 3", got)
+	}
+}
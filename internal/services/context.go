@@ -0,0 +1,43 @@
+package services
+
+import "context"
+
+type contextKey string
+
+const (
+	actorIDKey   contextKey = "actorID"
+	teamIDKey    contextKey = "teamID"
+	requestIDKey contextKey = "requestID"
+	fieldsKey    contextKey = "fields"
+)
+
+// WithActor attaches the acting user, their team and the request ID to a context,
+// so service-level side effects such as outbox events can be attributed
+func WithActor(ctx context.Context, actorID, teamID, requestID string) context.Context {
+	ctx = context.WithValue(ctx, actorIDKey, actorID)
+	ctx = context.WithValue(ctx, teamIDKey, teamID)
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// ActorFromContext returns the acting user, team and request ID attached with WithActor
+func ActorFromContext(ctx context.Context) (actorID, teamID, requestID string) {
+	actorID, _ = ctx.Value(actorIDKey).(string)
+	teamID, _ = ctx.Value(teamIDKey).(string)
+	requestID, _ = ctx.Value(requestIDKey).(string)
+	return actorID, teamID, requestID
+}
+
+// WithFields asks Get and List for a sparse fieldset, the JSON or column names of the fields
+// to load, see FieldResolver.Select
+func WithFields(ctx context.Context, names ...string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, fieldsKey, names)
+}
+
+// FieldsFromContext returns the sparse fieldset attached with WithFields, nil for all fields
+func FieldsFromContext(ctx context.Context) []string {
+	names, _ := ctx.Value(fieldsKey).([]string)
+	return names
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"be0/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+var explainLog = logger.New("slow_query")
+
+// slowQueryExplain controls EXPLAIN sampling of slow BaseService reads, disabled while threshold is zero
+var slowQueryExplain struct {
+	threshold  time.Duration
+	sampleRate float64
+}
+
+// ConfigureSlowQueryExplain logs the EXPLAIN plan for a sampleRate fraction of
+// reads slower than threshold. Call it once at startup.
+func ConfigureSlowQueryExplain(threshold time.Duration, sampleRate float64) {
+	slowQueryExplain.threshold = threshold
+	slowQueryExplain.sampleRate = sampleRate
+}
+
+// explainIfSlow logs the plan of an executed query when it was slow and is sampled.
+// The EXPLAIN runs in the background so the caller does not pay for it.
+func explainIfSlow(db *gorm.DB, result *gorm.DB, model, operation string, elapsed time.Duration) {
+	if slowQueryExplain.threshold <= 0 || elapsed < slowQueryExplain.threshold || result == nil || result.Statement == nil {
+		return
+	}
+	if rand.Float64() >= slowQueryExplain.sampleRate {
+		return
+	}
+
+	sql := result.Statement.SQL.String()
+	vars := append([]interface{}(nil), result.Statement.Vars...)
+	if sql == "" {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var plan []string
+		if err := db.WithContext(ctx).Raw("EXPLAIN "+sql, vars...).Scan(&plan).Error; err != nil {
+			explainLog.Warn("Failed to explain slow %s.%s (%s): %v", model, operation, elapsed, err)
+			return
+		}
+		explainLog.Warn("Slow %s.%s took %s\n%s\n%s", model, operation, elapsed, sql, strings.Join(plan, "\n"))
+	}()
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Field is a model field addressable from query parameters
+type Field struct {
+	JSONName string
+	Column   string
+	// Virtual fields are computed after the query, e.g. File.SignedURL, and have no column
+	Virtual bool
+	// Relation is set for fields of a related model, addressed as "relation.field"
+	Relation *Relation
+}
+
+// Relation is a has-one, has-many or belongs-to relation filtered on with "relation.field",
+// e.g. ?users.firstName=Ada on teams
+type Relation struct {
+	// Alias is the JSON name of the relation, the joined table is aliased to it
+	Alias string
+	// Join joins the related rows that are not deleted
+	Join string
+	// Team is set when the related rows belong to a team, only the caller's are matched
+	Team bool
+}
+
+// Key is the filter key of the field, the column qualified with the relation alias for related fields
+func (f Field) Key() string {
+	if f.Relation != nil {
+		return f.Relation.Alias + "." + f.Column
+	}
+	return f.Column
+}
+
+// FieldResolver maps the JSON names clients use (and column names) to model fields,
+// so filters, sort and excludes all accept the same names
+type FieldResolver struct {
+	fields map[string]Field
+	schema *schema.Schema
+	// bodyFields are the top-level keys of the model's JSON, by JSON and column name
+	bodyFields map[string]*bodyField
+}
+
+// immutableColumns are never written from a request body: they are set when a row is created,
+// or like version by the update itself
+var immutableColumns = map[string]bool{
+	"id": true, "team_id": true, "created_at": true, "updated_at": true, "deleted_at": true, "is_deleted": true,
+	"version": true,
+}
+
+// bodyField is a top-level key of the model's JSON and the columns it is stored in, several
+// for embedded structs such as Team.AuthPolicy
+type bodyField struct {
+	jsonName  string
+	index     []int
+	columns   []string
+	updatable bool
+	relation  bool
+}
+
+var schemaCache = &sync.Map{}
+
+// NewFieldResolver builds a resolver from the GORM schema of model
+func NewFieldResolver(db *gorm.DB, model interface{}) (*FieldResolver, error) {
+	s, err := schema.Parse(model, schemaCache, db.NamingStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	resolver := &FieldResolver{fields: map[string]Field{}, schema: s, bodyFields: map[string]*bodyField{}}
+	resolver.addBodyFields(s, s.ModelType, nil)
+	for _, f := range s.Fields {
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			continue
+		}
+		// Relations are loaded with include, they are not fields
+		if _, isRelation := s.Relationships.Relations[f.Name]; isRelation {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+
+		field := Field{JSONName: jsonName, Column: f.DBName, Virtual: f.DBName == ""}
+		resolver.fields[jsonName] = field
+		if f.DBName != "" {
+			resolver.fields[f.DBName] = field
+		}
+	}
+
+	for _, rel := range s.Relationships.Relations {
+		relation := newRelation(s, rel)
+		if relation == nil {
+			continue
+		}
+		for _, f := range rel.FieldSchema.Fields {
+			jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+			// Hidden and redacted fields of related records must not be probed through filters
+			if jsonName == "-" || f.DBName == "" || f.Tag.Get("redact") != "" {
+				continue
+			}
+			if _, isRelation := rel.FieldSchema.Relationships.Relations[f.Name]; isRelation {
+				continue
+			}
+			if jsonName == "" {
+				jsonName = f.Name
+			}
+
+			field := Field{JSONName: relation.Alias + "." + jsonName, Column: f.DBName, Relation: relation}
+			resolver.fields[field.JSONName] = field
+			resolver.fields[field.Key()] = field
+		}
+	}
+	return resolver, nil
+}
+
+// newRelation describes how to join a relation of s, nil for relations that cannot be filtered on
+func newRelation(s *schema.Schema, rel *schema.Relationship) *Relation {
+	alias := strings.Split(rel.Field.Tag.Get("json"), ",")[0]
+	if alias == "-" || alias == "" || strings.Contains(alias, ".") || rel.FieldSchema == nil || rel.Polymorphic != nil {
+		return nil
+	}
+
+	// Aliases such as "user" are reserved words, identifiers are quoted
+	var conditions []string
+	switch rel.Type {
+	case schema.HasOne, schema.HasMany, schema.BelongsTo:
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				conditions = append(conditions, fmt.Sprintf(`"%s"."%s" = "%s"."%s"`, alias, ref.ForeignKey.DBName, s.Table, ref.PrimaryKey.DBName))
+			} else {
+				conditions = append(conditions, fmt.Sprintf(`"%s"."%s" = "%s"."%s"`, alias, ref.PrimaryKey.DBName, s.Table, ref.ForeignKey.DBName))
+			}
+		}
+	default:
+		// Many to many relations would need the join table as well
+		return nil
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+	if _, softDeleted := rel.FieldSchema.FieldsByDBName["is_deleted"]; softDeleted {
+		conditions = append(conditions, fmt.Sprintf(`"%s".is_deleted = false`, alias))
+	}
+
+	return &Relation{
+		Alias: alias,
+		Join:  fmt.Sprintf(`JOIN "%s" "%s" ON %s`, rel.FieldSchema.Table, alias, strings.Join(conditions, " AND ")),
+		Team:  rel.FieldSchema.LookUpField("team_id") != nil,
+	}
+}
+
+// Resolve returns the field for a JSON or column name
+func (r *FieldResolver) Resolve(name string) (Field, error) {
+	field, ok := r.fields[name]
+	if !ok {
+		return Field{}, fmt.Errorf("unknown field %q", name)
+	}
+	return field, nil
+}
+
+// Names returns the JSON and column names the resolver accepts, sorted
+func (r *FieldResolver) Names() []string {
+	names := make([]string, 0, len(r.fields))
+	for name := range r.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addBodyFields adds the top-level JSON keys of t, flattening anonymous structs such as Base
+func (r *FieldResolver) addBodyFields(s *schema.Schema, t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		fieldIndex := append(slices.Clone(index), i)
+		jsonName := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			continue
+		}
+		if structField.Anonymous && jsonName == "" && structField.Type.Kind() == reflect.Struct {
+			r.addBodyFields(s, structField.Type, fieldIndex)
+			continue
+		}
+		if jsonName == "" {
+			jsonName = structField.Name
+		}
+
+		field := &bodyField{jsonName: jsonName, index: fieldIndex, updatable: true}
+		_, field.relation = s.Relationships.Relations[structField.Name]
+		for _, f := range s.Fields {
+			if f.DBName == "" || !slices.Equal(f.StructField.Index[:min(len(fieldIndex), len(f.StructField.Index))], fieldIndex) {
+				continue
+			}
+			field.columns = append(field.columns, f.DBName)
+			field.updatable = field.updatable && f.Updatable && !immutableColumns[f.DBName]
+		}
+		// Relations and virtual fields have no column to write
+		field.updatable = field.updatable && len(field.columns) > 0
+		r.bodyFields[jsonName] = field
+		if len(field.columns) == 1 {
+			r.bodyFields[field.columns[0]] = field
+		}
+	}
+}
+
+// ErrInvalidFields is returned for sparse fieldsets naming fields the model doesn't have
+var ErrInvalidFields = errors.New("invalid fields")
+
+// Selection is a sparse fieldset, see FieldResolver.Select
+type Selection struct {
+	// Columns are the columns queried
+	Columns []string
+	// Keys are the top-level JSON keys of the response
+	Keys []string
+	// Skipped are the virtual fields left out, their hooks don't compute them
+	Skipped []string
+}
+
+// Select resolves the JSON or column names of a sparse fieldset. The id, version and
+// updated_at columns, the ones updates are checked against, are always selected, and so are
+// the columns includes are loaded by and those the selected virtual fields are computed
+// from, see models.VirtualFieldSource.
+func (r *FieldResolver) Select(names, includes []string) (*Selection, error) {
+	var sources map[string][]string
+	if source, ok := reflect.New(r.schema.ModelType).Interface().(models.VirtualFieldSource); ok {
+		sources = source.VirtualFieldColumns()
+	}
+
+	columns, keys := map[string]bool{"id": true}, map[string]bool{"id": true}
+	if r.schema.LookUpField("updated_at") != nil {
+		columns["updated_at"] = true
+	}
+	// 🔢 Clients send the version back with their update
+	if field := r.schema.LookUpField("version"); field != nil {
+		columns["version"], keys[strings.Split(field.Tag.Get("json"), ",")[0]] = true, true
+	}
+	for _, name := range names {
+		field, ok := r.bodyFields[strings.TrimSpace(name)]
+		if !ok || field.relation {
+			return nil, fmt.Errorf("%w: unknown field %q, valid fields are %s", ErrInvalidFields, name, strings.Join(r.selectable(), ", "))
+		}
+		keys[field.jsonName] = true
+		for _, column := range field.columns {
+			columns[column] = true
+		}
+		for _, column := range sources[field.jsonName] {
+			columns[column] = true
+		}
+	}
+
+	// 🔗 Included relations are loaded by keys of this table, which have to be selected too
+	for _, include := range includes {
+		relation, ok := r.schema.Relationships.Relations[strings.Split(include, ".")[0]]
+		if !ok {
+			continue
+		}
+		if jsonName := strings.Split(relation.Field.Tag.Get("json"), ",")[0]; jsonName != "" && jsonName != "-" {
+			keys[jsonName] = true
+		}
+		for _, ref := range relation.References {
+			switch {
+			case ref.OwnPrimaryKey && ref.PrimaryKey.Schema == r.schema:
+				columns[ref.PrimaryKey.DBName] = true
+			case !ref.OwnPrimaryKey && ref.ForeignKey.Schema == r.schema:
+				columns[ref.ForeignKey.DBName] = true
+			}
+		}
+	}
+
+	selection := &Selection{}
+	// Columns in schema order, so the same fieldset always builds the same SQL
+	for _, f := range r.schema.Fields {
+		if f.DBName != "" && columns[f.DBName] {
+			selection.Columns = append(selection.Columns, f.DBName)
+			delete(columns, f.DBName)
+		}
+	}
+	for _, field := range r.bodyFields {
+		if keys[field.jsonName] {
+			continue
+		}
+		if len(field.columns) == 0 && !field.relation && !slices.Contains(selection.Skipped, field.jsonName) {
+			selection.Skipped = append(selection.Skipped, field.jsonName)
+		}
+	}
+	for key := range keys {
+		selection.Keys = append(selection.Keys, key)
+	}
+	sort.Strings(selection.Keys)
+	sort.Strings(selection.Skipped)
+	return selection, nil
+}
+
+// selectable returns the JSON names a sparse fieldset can name, sorted
+func (r *FieldResolver) selectable() []string {
+	var names []string
+	for name, field := range r.bodyFields {
+		if name == field.jsonName && !field.relation {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// relationHasTeam reports whether the model of the relation at path, e.g. "Team.Users", has a team_id column
+func (r *FieldResolver) relationHasTeam(path string) bool {
+	current := r.schema
+	for _, name := range strings.Split(path, ".") {
+		relation, ok := current.Relationships.Relations[name]
+		if !ok || relation.FieldSchema == nil {
+			return false
+		}
+		current = relation.FieldSchema
+	}
+	return current.LookUpField("team_id") != nil
+}
+
+// UpdatableColumns returns the columns an update writes, all but the immutable ones
+func (r *FieldResolver) UpdatableColumns() []string {
+	var columns []string
+	for _, f := range r.schema.Fields {
+		if f.DBName != "" && f.Updatable && !immutableColumns[f.DBName] {
+			columns = append(columns, f.DBName)
+		}
+	}
+	return columns
+}
+
+// versioned adds the version column to the columns of an update, the update moves it on
+func (r *FieldResolver) versioned(columns []string) []string {
+	if r.schema.LookUpField("version") == nil {
+		return columns
+	}
+	return append(columns, "version")
+}
+
+// replace prepares entity to replace stored: immutable fields keep their stored value and
+// updatable ones left at zero take their column default, as they would on create
+func (r *FieldResolver) replace(ctx context.Context, entity, stored any) {
+	entityValue, storedValue := reflect.ValueOf(entity), reflect.ValueOf(stored)
+	for _, f := range r.schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		value := f.ReflectValueOf(ctx, entityValue)
+		if !f.Updatable || immutableColumns[f.DBName] {
+			value.Set(f.ReflectValueOf(ctx, storedValue))
+			continue
+		}
+		if f.DefaultValueInterface == nil || !value.IsZero() {
+			continue
+		}
+		if def := reflect.ValueOf(f.DefaultValueInterface); def.Type().ConvertibleTo(value.Type()) {
+			value.Set(def.Convert(value.Type()))
+		}
+	}
+}
+
+// patch checks the keys of changes and returns them by JSON name, with the columns they are
+// stored in
+func (r *FieldResolver) patch(changes map[string]interface{}) (map[string]interface{}, []string, error) {
+	if len(changes) == 0 {
+		return nil, nil, fmt.Errorf("%w: no fields to change", ErrInvalidPatch)
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	patch := make(map[string]interface{}, len(changes))
+	var columns []string
+	for _, key := range keys {
+		field, ok := r.bodyFields[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unknown field %q", ErrInvalidPatch, key)
+		}
+		if !field.updatable {
+			return nil, nil, fmt.Errorf("%w: %q can't be changed", ErrInvalidPatch, key)
+		}
+		if _, twice := patch[field.jsonName]; twice {
+			return nil, nil, fmt.Errorf("%w: %q is set twice", ErrInvalidPatch, field.jsonName)
+		}
+		patch[field.jsonName] = changes[key]
+		columns = append(columns, field.columns...)
+	}
+	return patch, columns, nil
+}
+
+// apply sets the fields of patch on entity. Nulls set the field to its zero value, objects
+// are merged into embedded structs.
+func (r *FieldResolver) apply(entity any, patch map[string]interface{}) error {
+	values := map[string]interface{}{}
+	for jsonName, value := range patch {
+		if value == nil {
+			field := reflect.ValueOf(entity).Elem().FieldByIndex(r.bodyFields[jsonName].index)
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		values[jsonName] = value
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if err := json.Unmarshal(data, entity); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return nil
+}
+
+// changedColumns returns the updatable columns whose value differs between old and entity
+func (r *FieldResolver) changedColumns(ctx context.Context, old, entity any) []string {
+	oldValue, entityValue := reflect.ValueOf(old), reflect.ValueOf(entity)
+	var columns []string
+	for _, f := range r.schema.Fields {
+		if f.DBName == "" || !f.Updatable || immutableColumns[f.DBName] {
+			continue
+		}
+		if !reflect.DeepEqual(f.ReflectValueOf(ctx, oldValue).Interface(), f.ReflectValueOf(ctx, entityValue).Interface()) {
+			columns = append(columns, f.DBName)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+var selectClause = regexp.MustCompile(`^SELECT (.+?) FROM`)
+
+// selectedColumns records the columns each query against table selects, "*" for all, counts
+// left out
+func selectedColumns(t *testing.T, gdb *gorm.DB, table string) func() [][]string {
+	t.Helper()
+	var mu sync.Mutex
+	var queries [][]string
+	name := "test:select_" + table
+	err := gdb.Callback().Query().After("gorm:query").Register(name, func(tx *gorm.DB) {
+		if tx.Statement.Table != table {
+			return
+		}
+		match := selectClause.FindStringSubmatch(tx.Statement.SQL.String())
+		if match == nil || strings.HasPrefix(strings.ToLower(match[1]), "count(") {
+			return
+		}
+		var columns []string
+		for _, column := range strings.Split(match[1], ",") {
+			column = strings.Trim(strings.TrimSpace(column), "`\"")
+			columns = append(columns, strings.TrimPrefix(column, table+"`.`"))
+		}
+		slices.Sort(columns)
+		mu.Lock()
+		queries = append(queries, columns)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gdb.Callback().Query().Remove(name) })
+	return func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		recorded := queries
+		queries = nil
+		return recorded
+	}
+}
+
+func TestSparseFieldsetsSelectOnlyTheirColumns(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := gdb.Create(template).Error; err != nil {
+		t.Fatal(err)
+	}
+	service := NewBaseService(gdb, models.Template{})
+	queries := selectedColumns(t, gdb, "templates")
+	ctx := WithFields(context.Background(), "name", "subject")
+
+	// 🧩 The id and versions are selected even when not asked for
+	templates, _, err := service.List(ctx, 1, 10, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := queries()
+	if len(got) != 1 || !slices.Equal(got[0], []string{"id", "name", "subject", "updated_at", "version"}) {
+		t.Errorf("list selected %v, want id, name, subject, updated_at and version", got)
+	}
+	if len(templates) != 1 || templates[0].ID != template.ID || templates[0].Name != "Welcome" || templates[0].HTML != "" {
+		t.Errorf("listed %+v", templates)
+	}
+
+	// Column names work too, and includes load as without a fieldset
+	stored, err := service.Get(WithFields(context.Background(), "subject", "team_id"), template.ID, "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := queries(); len(got) != 1 || !slices.Equal(got[0], []string{"id", "subject", "team_id", "updated_at", "version"}) {
+		t.Errorf("get selected %v, want id, subject, team_id, updated_at and version", got)
+	}
+	if stored.Team == nil || stored.Team.Name != "Acme" || stored.Subject != "Hi" {
+		t.Errorf("got subject %q and team %v", stored.Subject, stored.Team)
+	}
+
+	// Without a fieldset every column is loaded
+	if _, err := service.Get(context.Background(), template.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries(); len(got) != 1 || !slices.Equal(got[0], []string{"*"}) {
+		t.Errorf("get without fields selected %v", got)
+	}
+}
+
+func TestSparseFieldsetsOfVirtualFields(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	fields, err := NewFieldResolver(gdb, models.File{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 🔗 signedUrl is computed from the path and ACL
+	selection, err := fields.Select([]string{"signedUrl"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(selection.Columns, []string{"id", "updated_at", "version", "path", "acl"}) || !slices.Equal(selection.Keys, []string{"id", "signedUrl", "version"}) {
+		t.Errorf("columns %v keys %v", selection.Columns, selection.Keys)
+	}
+	if !slices.Equal(selection.Skipped, []string{"matchedIn"}) {
+		t.Errorf("skipped %v, want matchedIn", selection.Skipped)
+	}
+
+	if selection, err = fields.Select([]string{"name"}, []string{"User"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(selection.Columns, []string{"id", "updated_at", "version", "user_id", "name"}) || !slices.Equal(selection.Keys, []string{"id", "name", "user", "version"}) {
+		t.Errorf("columns %v keys %v", selection.Columns, selection.Keys)
+	}
+	if !slices.Equal(selection.Skipped, []string{"matchedIn", "signedUrl"}) {
+		t.Errorf("skipped %v, want both virtual fields", selection.Skipped)
+	}
+
+	for _, names := range [][]string{{"colour"}, {"user"}, {"name", ""}} {
+		_, err := fields.Select(names, nil)
+		if !errors.Is(err, ErrInvalidFields) || !strings.Contains(err.Error(), "valid fields are") {
+			t.Errorf("%q: got %v, want ErrInvalidFields listing the valid fields", names, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// HookPoint is the moment of a write a Hook runs at, see BaseService.RegisterHook
+type HookPoint string
+
+const (
+	HookBeforeCreate HookPoint = "before_create"
+	HookAfterCreate  HookPoint = "after_create"
+	HookBeforeUpdate HookPoint = "before_update"
+	HookAfterUpdate  HookPoint = "after_update"
+	HookBeforeDelete HookPoint = "before_delete"
+	HookAfterDelete  HookPoint = "after_delete"
+	// Restore hooks get the deleted entity as change.Old, after hooks the restored one as change.New
+	HookBeforeRestore HookPoint = "before_restore"
+	HookAfterRestore  HookPoint = "after_restore"
+)
+
+// Hook runs in the transaction of a write, tx. change.New is the entity created or updated,
+// before hooks may still change it, and change.Old the stored entity of updates and deletes.
+// An error rolls the write back, hooks wrap ErrRejected when the input is at fault.
+type Hook[T any] func(ctx context.Context, tx *gorm.DB, change Change[T]) error
+
+// ErrRejected is wrapped by errors of hooks rejecting a write for its input, e.g.
+// fmt.Errorf("%w: names can't be blank", services.ErrRejected). Controllers answer 400,
+// and 500 for other hook errors.
+var ErrRejected = errors.New("rejected")
+
+// hookSet holds the hooks of a service by point, in registration order
+type hookSet[T any] struct {
+	mu      sync.RWMutex
+	byPoint map[HookPoint][]Hook[T]
+}
+
+func (h *hookSet[T]) add(point HookPoint, hook Hook[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.byPoint == nil {
+		h.byPoint = map[HookPoint][]Hook[T]{}
+	}
+	h.byPoint[point] = append(h.byPoint[point], hook)
+}
+
+func (h *hookSet[T]) has(point HookPoint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byPoint[point]) > 0
+}
+
+// run runs the hooks of point in registration order, stopping at the first error
+func (h *hookSet[T]) run(ctx context.Context, tx *gorm.DB, point HookPoint, change Change[T]) error {
+	h.mu.RLock()
+	hooks := h.byPoint[point]
+	h.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const commitQueueKey contextKey = "commitQueue"
+
+// commitQueue collects the functions to run once a write committed, see AfterCommit
+type commitQueue struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// withCommitQueue returns the context of a write, whose AfterCommit functions run with run
+func withCommitQueue(ctx context.Context) (context.Context, *commitQueue) {
+	queue := &commitQueue{}
+	return context.WithValue(ctx, commitQueueKey, queue), queue
+}
+
+// run runs the queued functions in the order they were queued
+func (q *commitQueue) run() {
+	q.mu.Lock()
+	fns := q.fns
+	q.fns = nil
+	q.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// AfterCommit runs fn once the write of ctx committed, and never when it is rolled back.
+// Hooks use it for side effects outside the database, such as events. Outside of a write fn
+// runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	queue, ok := ctx.Value(commitQueueKey).(*commitQueue)
+	if !ok {
+		fn()
+		return
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.fns = append(queue.fns, fn)
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func TestHooksRunInOrderInTheWriteTransaction(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Template{})
+
+	var calls []string
+	for _, name := range []string{"first", "second"} {
+		service.RegisterHook(HookBeforeCreate, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+			calls = append(calls, "before "+name)
+			// ✂️ Before hooks normalize the input
+			change.New.Name = strings.TrimSpace(change.New.Name)
+			return nil
+		})
+	}
+	service.RegisterHook(HookAfterCreate, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		calls = append(calls, "after")
+		// The row and its outbox record are written, in the transaction only
+		var outboxed int64
+		if err := tx.Model(&models.OutboxEvent{}).Where("event = ?", "templates.created").Count(&outboxed).Error; err != nil {
+			return err
+		}
+		if outboxed != 1 || change.New.ID == "" {
+			t.Errorf("after create: %d outbox records, ID %q", outboxed, change.New.ID)
+		}
+		return nil
+	})
+
+	created := make(chan *models.Template, 4)
+	events.OnNamed("templates.created", "TestHooksRunInOrderInTheWriteTransaction", func(data interface{}) {
+		created <- data.(*models.Template)
+	})
+
+	template := &models.Template{TeamID: team.ID, Name: "  Welcome ", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), template); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"before first", "before second", "after"}; !slices.Equal(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+	var stored models.Template
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Welcome" {
+		t.Errorf("stored name %q, want the trimmed Welcome", stored.Name)
+	}
+
+	// 📣 The default after hook emits the event once committed
+	deadline := time.After(time.Second)
+	for seen := false; !seen; {
+		select {
+		case event := <-created:
+			seen = event.ID == template.ID
+		case <-deadline:
+			t.Fatal("no templates.created event")
+		}
+	}
+}
+
+func TestHookErrorsRollTheWriteBack(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Template{})
+	service.RegisterHook(HookAfterCreate, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		if change.New.Subject == "" {
+			return fmt.Errorf("%w: subjects can't be blank", ErrRejected)
+		}
+		return nil
+	})
+
+	created := make(chan string, 4)
+	events.OnNamed("templates.created", "TestHookErrorsRollTheWriteBack", func(data interface{}) {
+		created <- data.(*models.Template).ID
+	})
+
+	rejected := &models.Template{TeamID: team.ID, Name: "Blank", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), rejected); !errors.Is(err, ErrRejected) {
+		t.Fatalf("got %v, want ErrRejected", err)
+	}
+	var rows, outboxed int64
+	gdb.Model(&models.Template{}).Count(&rows)
+	gdb.Model(&models.OutboxEvent{}).Where("event = ?", "templates.created").Count(&outboxed)
+	if rows != 0 || outboxed != 0 {
+		t.Errorf("%d templates and %d outbox records after the rejected create, want none", rows, outboxed)
+	}
+
+	accepted := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), accepted); err != nil {
+		t.Fatal(err)
+	}
+	// ❌ Events only follow committed writes
+	deadline := time.After(time.Second)
+	for seen := false; !seen; {
+		select {
+		case id := <-created:
+			if id == rejected.ID {
+				t.Fatal("the rolled back create emitted its event")
+			}
+			seen = id == accepted.ID
+		case <-deadline:
+			t.Fatal("no event of the accepted create")
+		}
+	}
+	select {
+	case id := <-created:
+		if id == rejected.ID {
+			t.Error("the rolled back create emitted its event")
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUpdateAndDeleteHooksSeeTheStoredEntity(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Template{})
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), template); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []string
+	service.RegisterHook(HookBeforeUpdate, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		// Fields a hook changes are written with the patched ones
+		change.New.Subject = strings.ToUpper(change.New.Subject)
+		return nil
+	})
+	service.RegisterHook(HookAfterUpdate, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		changes = append(changes, change.Old.Name+" -> "+change.New.Name+" at version "+fmt.Sprint(change.New.Version))
+		return nil
+	})
+	service.RegisterHook(HookBeforeDelete, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		if change.Old.Name == "Locked" {
+			return fmt.Errorf("%w: %s is locked", ErrRejected, change.Old.Name)
+		}
+		return nil
+	})
+
+	patched, err := service.Patch(context.Background(), template.ID, map[string]any{"name": "Locked", "subject": "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Subject != "HELLO" {
+		t.Errorf("patched subject %q, want HELLO", patched.Subject)
+	}
+	replacement := *patched
+	replacement.Subject = "bye"
+	if err := service.Update(context.Background(), template.ID, &replacement); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Welcome -> Locked at version 2", "Locked -> Locked at version 3"}; !slices.Equal(changes, want) {
+		t.Errorf("after update hooks saw %v, want %v", changes, want)
+	}
+	var stored models.Template
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Subject != "BYE" {
+		t.Errorf("stored subject %q, want BYE", stored.Subject)
+	}
+
+	// 🔒 A before delete hook vetoes single and bulk deletes
+	if err := service.Delete(context.Background(), template.ID); !errors.Is(err, ErrRejected) {
+		t.Errorf("delete: got %v, want ErrRejected", err)
+	}
+	other := &models.Template{TeamID: team.ID, Name: "Other", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.DeleteMany(context.Background(), []string{other.ID, template.ID}); !errors.Is(err, ErrRejected) {
+		t.Errorf("bulk delete: got %v, want ErrRejected", err)
+	}
+	var live int64
+	gdb.Model(&models.Template{}).Where("is_deleted = ?", false).Count(&live)
+	if live != 2 {
+		t.Errorf("%d live templates after the vetoed deletes, want 2", live)
+	}
+	if deleted, err := service.DeleteMany(context.Background(), []string{other.ID}); err != nil || len(deleted) != 1 {
+		t.Errorf("bulk delete of the unlocked template: %v, %v", deleted, err)
+	}
+}
+
+func TestRestoreRunsItsHooks(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Template{})
+	template := &models.Template{TeamID: team.ID, Name: "Welcome", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), template); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Delete(context.Background(), template.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	veto := true
+	service.RegisterHook(HookBeforeRestore, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		if veto {
+			return fmt.Errorf("%w: %s stays deleted", ErrRejected, change.Old.Name)
+		}
+		return nil
+	})
+	var restored []string
+	service.RegisterHook(HookAfterRestore, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+		restored = append(restored, fmt.Sprint(change.Old.IsDeleted, " -> ", change.New.IsDeleted))
+		return nil
+	})
+	emitted := make(chan string, 4)
+	events.OnNamed("templates.restored", t.Name(), func(data interface{}) {
+		if id := data.(string); id == template.ID {
+			emitted <- id
+		}
+	})
+
+	if err := service.Restore(context.Background(), template.ID); !errors.Is(err, ErrRejected) {
+		t.Fatalf("got %v, want ErrRejected", err)
+	}
+	veto = false
+	if err := service.Restore(context.Background(), template.ID); err != nil {
+		t.Fatal(err)
+	}
+	var outboxed int64
+	gdb.Model(&models.OutboxEvent{}).Where("event = ?", "templates.restored").Count(&outboxed)
+	if outboxed != 1 {
+		t.Errorf("%d outbox records of the restore, want 1", outboxed)
+	}
+	if want := []string{"true -> false"}; !slices.Equal(restored, want) {
+		t.Errorf("after restore hooks saw %v, want %v", restored, want)
+	}
+	// 📣 The event follows the committed restore only
+	select {
+	case <-emitted:
+	case <-time.After(time.Second):
+		t.Fatal("no templates.restored event")
+	}
+	select {
+	case <-emitted:
+		t.Error("a second restored event, the vetoed restore emitted one")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCommittedHooksRunWhenTheReloadFails(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	service := NewBaseService(gdb, models.Template{})
+
+	var committed []string
+	for _, point := range []HookPoint{HookAfterCreate, HookAfterUpdate} {
+		service.RegisterHook(point, func(ctx context.Context, tx *gorm.DB, change Change[models.Template]) error {
+			name := change.New.Name
+			AfterCommit(ctx, func() { committed = append(committed, name) })
+			return nil
+		})
+	}
+
+	// 💥 An include that isn't a relation fails the reload after the write committed
+	template := &models.Template{TeamID: team.ID, Name: "Created", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Create(context.Background(), template, "Nope"); err == nil {
+		t.Fatal("create: the reload of an unknown include succeeded")
+	}
+	replacement := models.Template{TeamID: team.ID, Name: "Updated", Subject: "Hi", HTML: "<p>Hi</p>"}
+	if err := service.Update(context.Background(), template.ID, &replacement, "Nope"); err == nil {
+		t.Fatal("update: the reload of an unknown include succeeded")
+	}
+	if _, err := service.Patch(context.Background(), template.ID, map[string]any{"name": "Patched"}, nil, "Nope"); err == nil {
+		t.Fatal("patch: the reload of an unknown include succeeded")
+	}
+
+	var stored models.Template
+	if err := gdb.First(&stored, "id = ?", template.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Patched" {
+		t.Errorf("stored name %q, want Patched", stored.Name)
+	}
+	if want := []string{"Created", "Updated", "Patched"}; !slices.Equal(committed, want) {
+		t.Errorf("after commit hooks ran for %v, want %v", committed, want)
+	}
+}
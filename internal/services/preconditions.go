@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrPreconditionFailed is returned by Update, Patch and Delete when the stored entity does not
+// match the tags of WithIfMatch, someone else changed it meanwhile
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+const ifMatchKey contextKey = "ifMatch"
+
+// WithIfMatch makes Update, Patch and Delete of ctx fail with ErrPreconditionFailed unless the
+// stored entity's EntityTag is one of tags, as sent in an If-Match header. "*" matches any.
+func WithIfMatch(ctx context.Context, tags string) context.Context {
+	if strings.TrimSpace(tags) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ifMatchKey, tags)
+}
+
+// EntityTag returns the weak ETag of an entity's version, derived from its ID and UpdatedAt,
+// or "" for entities without them
+func EntityTag(entity any) string {
+	id, updatedAt, ok := entityVersion(entity)
+	if !ok {
+		return ""
+	}
+	// 🏷️ Every update moves UpdatedAt and with it the tag
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%d", id, updatedAt.UnixNano())))
+	return `W/"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// TagMatches reports whether tag is listed in an If-Match or If-None-Match header. Tags are
+// compared weakly, W/"x" matches "x".
+func TagMatches(header, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch returns ErrPreconditionFailed when ctx carries If-Match tags the stored entity
+// does not match
+func checkIfMatch(ctx context.Context, stored any) error {
+	if tags, _ := ctx.Value(ifMatchKey).(string); tags == "" || TagMatches(tags, EntityTag(stored)) {
+		return nil
+	}
+	return ErrPreconditionFailed
+}
+
+// ifMatching reports whether ctx carries If-Match tags
+func ifMatching(ctx context.Context) bool {
+	tags, _ := ctx.Value(ifMatchKey).(string)
+	return tags != ""
+}
+
+// ifMatchScope limits the write of an entity checked with checkIfMatch to the version that
+// was checked, so an update committed in between leaves no row to change
+func ifMatchScope(ctx context.Context, stored any) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if !ifMatching(ctx) {
+			return query
+		}
+		if _, updatedAt, ok := entityVersion(stored); ok {
+			return query.Where("updated_at = ?", models.NewTimestamp(updatedAt))
+		}
+		return query
+	}
+}
+
+// writeResult returns the error of a write scoped with ifMatchScope, ErrPreconditionFailed when
+// it found no row left in the checked version
+func writeResult(ctx context.Context, result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 && ifMatching(ctx) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// entityVersion returns the ID and UpdatedAt of an entity
+func entityVersion(entity any) (string, time.Time, bool) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", time.Time{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", time.Time{}, false
+	}
+	id, updated := v.FieldByName("ID"), v.FieldByName("UpdatedAt")
+	if !id.IsValid() || id.Kind() != reflect.String || !updated.IsValid() {
+		return "", time.Time{}, false
+	}
+	switch updatedAt := updated.Interface().(type) {
+	case models.Timestamp:
+		return id.String(), updatedAt.Time, true
+	case time.Time:
+		return id.String(), updatedAt, true
+	}
+	return "", time.Time{}, false
+}
+
+// ErrStaleVersion is returned by Update and Patch when the version sent is not the stored one:
+// another update saved first, the entity has to be read again
+var ErrStaleVersion = errors.New("the entity was changed by another update, reload it and retry")
+
+// rowVersion returns the Version of an entity, false for models without one
+func rowVersion(entity any) (int, bool) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	version := v.FieldByName("Version")
+	if !version.IsValid() || version.Kind() != reflect.Int {
+		return 0, false
+	}
+	return int(version.Int()), true
+}
+
+// checkVersion returns ErrStaleVersion when a version was sent and it is not the stored one.
+// Writes without a version are still scoped to the stored one with versionScope.
+func checkVersion(sent int, stored any) error {
+	if version, ok := rowVersion(stored); ok && sent != 0 && sent != version {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// versionScope limits the write of an entity to the stored version that was checked, so an
+// update committed in between leaves no row to change
+func versionScope(stored any) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if version, ok := rowVersion(stored); ok {
+			return query.Where("version = ?", version)
+		}
+		return query
+	}
+}
+
+// versionedResult returns the error of a write scoped with versionScope, ErrStaleVersion when
+// it found no row left in the checked version
+func versionedResult(ctx context.Context, result *gorm.DB) error {
+	if err := writeResult(ctx, result); err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// takeVersion splits the version a patch was based on off its changes
+func takeVersion(changes map[string]interface{}) (int, map[string]interface{}, error) {
+	value, ok := changes["version"]
+	if !ok {
+		return 0, changes, nil
+	}
+	var version int
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, &version)
+	}
+	if err != nil || version < 1 {
+		return 0, nil, fmt.Errorf("%w: \"version\" must be the version the change is based on", ErrInvalidPatch)
+	}
+	rest := make(map[string]interface{}, len(changes)-1)
+	for key, value := range changes {
+		if key != "version" {
+			rest[key] = value
+		}
+	}
+	return version, rest, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"be0/internal/utils/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// replicationWindow is how long after an upload we check the replica has the object
+	replicationWindow = 15 * time.Minute
+	// replicaCooldown is how long the replica is skipped after it errors
+	replicaCooldown = time.Minute
+	// replicaCheckTimeout bounds the HeadObject existence check
+	replicaCheckTimeout = 2 * time.Second
+)
+
+// Replica serves signed reads from a secondary-region copy of the bucket.
+// Writes always go to the origin.
+type Replica struct {
+	client     *s3.Client
+	bucketName string
+	logger     *logger.Logger
+
+	mu             sync.Mutex
+	recentUploads  map[string]time.Time
+	unhealthyUntil time.Time
+}
+
+// NewReplica creates a read replica client using the same endpoint scheme as the origin
+func NewReplica(bucketName, endpoint, region, accessKey, secretKey string) (*Replica, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithRegion(region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+		config.WithRetryMode(aws.RetryModeStandard),
+		config.WithRetryMaxAttempts(1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load replica SDK config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.%s", region, endpoint))
+		}
+	})
+
+	return &Replica{
+		client:        client,
+		bucketName:    bucketName,
+		logger:        logger.New("s3_replica"),
+		recentUploads: map[string]time.Time{},
+	}, nil
+}
+
+// TrackUpload remembers a fresh upload, it may not have replicated yet
+func (r *Replica) TrackUpload(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, uploadedAt := range r.recentUploads {
+		if now.Sub(uploadedAt) > replicationWindow {
+			delete(r.recentUploads, key)
+		}
+	}
+	r.recentUploads[path] = now
+}
+
+// SignedURL presigns path on the replica. ok is false when the origin should be used instead:
+// the replica is cooling down after an error, or a recent upload hasn't replicated yet.
+func (r *Replica) SignedURL(ctx context.Context, path string, duration time.Duration) (url string, ok bool) {
+	if !r.healthy() {
+		return "", false
+	}
+
+	if r.isRecent(path) {
+		replicated, err := r.exists(ctx, path)
+		if err != nil {
+			r.markUnhealthy(err)
+			return "", false
+		}
+		if !replicated {
+			return "", false
+		}
+		r.forget(path)
+	}
+
+	presigned, err := s3.NewPresignClient(r.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucketName),
+		Key:    aws.String(path),
+	}, s3.WithPresignExpires(duration))
+	if err != nil {
+		r.markUnhealthy(err)
+		return "", false
+	}
+	return presigned.URL, true
+}
+
+func (r *Replica) exists(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	_, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucketName),
+		Key:    aws.String(path),
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (r *Replica) healthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().After(r.unhealthyUntil)
+}
+
+func (r *Replica) markUnhealthy(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unhealthyUntil = time.Now().Add(replicaCooldown)
+	r.logger.Warn("⚠️ Replica failed, serving from origin for %s: %v", replicaCooldown, err)
+}
+
+func (r *Replica) isRecent(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uploadedAt, ok := r.recentUploads[path]
+	return ok && time.Since(uploadedAt) <= replicationWindow
+}
+
+func (r *Replica) forget(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.recentUploads, path)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// replicaServer answers the replica's existence checks with the status status holds
+func replicaServer(t *testing.T, status *atomic.Int32) *Replica {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "eu-west-1",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		// Like NewReplica, a failed check goes straight to the origin
+		RetryMaxAttempts: 1,
+	})
+	return &Replica{client: client, bucketName: "replica", logger: logger.New("test"), recentUploads: map[string]time.Time{}}
+}
+
+func TestReplicaWaitsForRecentUploadsToReplicate(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusNotFound)
+	replica := replicaServer(t, &status)
+	ctx := context.Background()
+
+	if url, ok := replica.SignedURL(ctx, "team/old.pdf", time.Minute); !ok || !strings.Contains(url, "/replica/team/old.pdf?") {
+		t.Fatalf("an older object got %q, %v, want a replica URL", url, ok)
+	}
+
+	replica.TrackUpload("team/new.pdf")
+	if _, ok := replica.SignedURL(ctx, "team/new.pdf", time.Minute); ok {
+		t.Fatal("a fresh upload missing on the replica was served from it")
+	}
+
+	status.Store(http.StatusOK)
+	if _, ok := replica.SignedURL(ctx, "team/new.pdf", time.Minute); !ok {
+		t.Fatal("a replicated upload was not served from the replica")
+	}
+	// 📌 Once seen on the replica the upload is no longer checked
+	status.Store(http.StatusNotFound)
+	if _, ok := replica.SignedURL(ctx, "team/new.pdf", time.Minute); !ok {
+		t.Error("a replicated upload was checked again")
+	}
+}
+
+func TestReplicaFallsBackToTheOriginAfterAnError(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	replica := replicaServer(t, &status)
+
+	replica.TrackUpload("team/new.pdf")
+	if _, ok := replica.SignedURL(context.Background(), "team/new.pdf", time.Minute); ok {
+		t.Fatal("a failed existence check was served from the replica")
+	}
+	// ⏳ Even objects that need no check go to the origin during the cooldown
+	if _, ok := replica.SignedURL(context.Background(), "team/old.pdf", time.Minute); ok {
+		t.Error("the replica was used while cooling down")
+	}
+
+	replica.mu.Lock()
+	replica.unhealthyUntil = time.Now().Add(-time.Second)
+	replica.mu.Unlock()
+	if _, ok := replica.SignedURL(context.Background(), "team/old.pdf", time.Minute); !ok {
+		t.Error("the replica was not used again after the cooldown")
+	}
+}
+
+func TestSignedURLsUseTheReplicaOnlyWhenPreferred(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	origin := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("https://origin.example.com"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+	s := &S3Service{client: origin, bucketName: "origin", logger: logger.New("test")}
+	s.SetReplica(replicaServer(t, &status))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"default", context.Background(), "/origin/report.pdf?"},
+		{"preferred", models.WithPreferReplica(context.Background()), "/replica/report.pdf?"},
+	}
+	for _, tt := range tests {
+		url, err := s.GetSignedURL(tt.ctx, "report.pdf", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(url, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, url, tt.want)
+		}
+	}
+}
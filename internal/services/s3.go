@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -21,8 +22,11 @@ import (
 	"github.com/google/uuid"
 )
 
-// Ensure S3Service implements FileURLGenerator
+// Ensure S3Service implements FileURLGenerator, PublicURLGenerator, FileDeleter and FileWriter
 var _ models.FileURLGenerator = (*S3Service)(nil)
+var _ models.PublicURLGenerator = (*S3Service)(nil)
+var _ models.FileDeleter = (*S3Service)(nil)
+var _ models.FileWriter = (*S3Service)(nil)
 
 type S3Service struct {
 	client     *s3.Client
@@ -32,6 +36,8 @@ type S3Service struct {
 	logger     *logger.Logger
 	accessKey  string
 	secretKey  string
+	cdn        *CDN
+	replica    *Replica
 }
 
 func NewS3Service(bucketName, endpoint, region, accessKey, secretKey string) (*S3Service, error) {
@@ -93,11 +99,9 @@ func (s *S3Service) UploadFile(ctx context.Context, file []byte, filename string
 
 	s.logger.Info("🔄 Processing upload for file: %s", filename)
 
-	is_r2 := os.Getenv("STORAGE_PROVIDER") == "r2"
-
-	ACL := acl
-	if is_r2 {
-		ACL = types.ObjectCannedACLPublicRead
+	ACL := s.EffectiveACL(acl)
+	if ACL != acl {
+		s.logger.Warn("⚠️ Storage provider overrides ACL %s with %s", acl, ACL)
 	}
 
 	// Upload to storage
@@ -112,22 +116,137 @@ func (s *S3Service) UploadFile(ctx context.Context, file []byte, filename string
 		return "", s.logger.Error("Failed to upload file to storage ❌", err)
 	}
 
-	// Generate URL based on endpoint configuration
-	var url string
-	if s.endpoint != "" {
-		// Custom endpoint (e.g., MinIO)
-		url = fmt.Sprintf("https://%s.%s/%s/%s", s.region, s.endpoint, s.bucketName, filename)
-	} else {
-		// AWS S3
-		url = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.region, filename)
+	if s.replica != nil {
+		s.replica.TrackUpload(filename)
 	}
 
+	url := s.GetPublicURL(filename)
+
 	s.logger.Success("✅ File uploaded successfully: %s", url)
 	return url, nil
 }
 
-// GetSignedURL implements FileURLGenerator interface
+// DeleteFile removes an object from storage
+func (s *S3Service) DeleteFile(ctx context.Context, path string) error {
+	s.logger.Info("🗑️ Deleting file: %s", path)
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return s.logger.Error("Failed to delete file from storage ❌", err)
+	}
+
+	s.logger.Success("✅ File deleted successfully: %s", path)
+	return nil
+}
+
+// DownloadFile reads an object, refusing objects larger than maxBytes
+func (s *S3Service) DownloadFile(ctx context.Context, path string, maxBytes int64) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", path, err)
+	}
+	defer out.Body.Close()
+
+	if out.ContentLength != nil && *out.ContentLength > maxBytes {
+		return nil, models.ErrFileTooLarge
+	}
+
+	// 📏 Read one byte past the cap to detect objects without a reliable length
+	content, err := io.ReadAll(io.LimitReader(out.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", path, err)
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, models.ErrFileTooLarge
+	}
+	return content, nil
+}
+
+// PutFile writes an object at path with the bucket's default ACL, replacing an existing one
+func (s *S3Service) PutFile(ctx context.Context, path string, data []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(path),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", path, err)
+	}
+	return nil
+}
+
+// EffectiveACL returns the ACL the storage provider will actually apply for the requested one.
+// R2 does not support per-object ACLs, so objects are always treated as public-read there.
+func (s *S3Service) EffectiveACL(acl types.ObjectCannedACL) types.ObjectCannedACL {
+	if os.Getenv("STORAGE_PROVIDER") == "r2" {
+		return types.ObjectCannedACLPublicRead
+	}
+	return acl
+}
+
+// SetObjectACL changes the canned ACL of an existing object
+func (s *S3Service) SetObjectACL(ctx context.Context, path string, acl types.ObjectCannedACL) error {
+	s.logger.Info("🔄 Updating ACL for path: %s to %s", path, acl)
+
+	_, err := s.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(path),
+		ACL:    acl,
+	})
+	if err != nil {
+		return s.logger.Error("Failed to update object ACL ❌", err)
+	}
+
+	s.logger.Success("✅ Object ACL updated successfully")
+	return nil
+}
+
+// SetCDN serves file URLs from a CDN in front of the bucket
+func (s *S3Service) SetCDN(cdn *CDN) {
+	s.cdn = cdn
+}
+
+// SetReplica serves signed reads from a secondary-region replica when the request prefers it
+func (s *S3Service) SetReplica(replica *Replica) {
+	s.replica = replica
+}
+
+// GetPublicURL returns the unsigned URL of an object, on the CDN host when one is configured
+func (s *S3Service) GetPublicURL(path string) string {
+	if s.cdn != nil {
+		return s.cdn.PublicURL(path)
+	}
+
+	// Generate URL based on endpoint configuration
+	if s.endpoint != "" {
+		// Custom endpoint (e.g., MinIO)
+		return fmt.Sprintf("https://%s.%s/%s/%s", s.region, s.endpoint, s.bucketName, path)
+	}
+	// AWS S3
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.region, path)
+}
+
+// GetSignedURL implements FileURLGenerator interface.
+// URLs are signed by the CDN when it has a signing key, otherwise presigned on the replica
+// when the request prefers it and the object is there, or on the origin.
 func (s *S3Service) GetSignedURL(ctx context.Context, path string, duration time.Duration) (string, error) {
+	if s.cdn != nil && s.cdn.CanSign() {
+		return s.cdn.SignedURL(path, duration)
+	}
+
+	if s.replica != nil && models.PrefersReplica(ctx) {
+		if url, ok := s.replica.SignedURL(ctx, path, duration); ok {
+			return url, nil
+		}
+	}
+
 	presignClient := s3.NewPresignClient(s.client)
 
 	s.logger.Info("🔄 Generating pre-signed URL for path: %s", path)
@@ -144,3 +263,22 @@ func (s *S3Service) GetSignedURL(ctx context.Context, path string, duration time
 	s.logger.Success("✅ Generated pre-signed URL successfully")
 	return presignedURL.URL, nil
 }
+
+// Warmup pre-signs a URL and issues a HeadBucket so credentials are loaded
+// and a TLS session to the storage endpoint is open before the first upload
+func (s *S3Service) Warmup(ctx context.Context) error {
+	start := time.Now()
+
+	if _, err := s.GetSignedURL(ctx, "warmup", time.Minute); err != nil {
+		return err
+	}
+
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucketName),
+	}); err != nil {
+		return s.logger.Error("Failed to reach storage bucket ❌", err)
+	}
+
+	s.logger.Success("✅ Storage warm-up completed in %s", time.Since(start))
+	return nil
+}
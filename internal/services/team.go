@@ -0,0 +1,56 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"be0/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// teamSlugAttempts bounds the inserts CreateTeam tries before giving up
+	teamSlugAttempts = 8
+	// teamSlugSequentialAttempts are the attempts taking the next numbered slug, later ones
+	// add a random suffix so a burst of same-named teams stops racing for the same number
+	teamSlugSequentialAttempts = 3
+)
+
+// CreateTeam creates a team named name with a unique slug. Names are free-form, slugs are
+// derived from them and take the next free -2, -3... suffix, and when a concurrent create
+// takes the slug first the insert is retried. Each insert runs in a savepoint, so tx stays
+// usable when one is rejected.
+func CreateTeam(tx *gorm.DB, name string) (*models.Team, error) {
+	for attempt := 1; ; attempt++ {
+		slug, err := models.NextTeamSlug(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		if attempt > teamSlugSequentialAttempts {
+			suffix := make([]byte, 3)
+			if _, err := rand.Read(suffix); err != nil {
+				return nil, err
+			}
+			slug = models.TeamSlug(name) + "-" + hex.EncodeToString(suffix)
+		}
+
+		team := &models.Team{Name: name, Slug: slug}
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(team).Error
+		})
+		if err == nil {
+			return team, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || attempt == teamSlugAttempts {
+			return nil, err
+		}
+	}
+}
+
+// CreateTeamForUser creates the team of a user signing up without an invite, named after
+// their first name
+func CreateTeamForUser(tx *gorm.DB, firstName string) (*models.Team, error) {
+	return CreateTeam(tx, firstName+"'s Team")
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+func TestTeamSlug(t *testing.T) {
+	for name, want := range map[string]string{
+		"Sam's Team":            "sams-team",
+		"  Acme   Inc.  ":       "acme-inc",
+		"Zoë & Co":              "zo-co",
+		"!!!":                   "team",
+		"R2-D2’s Droids (2026)": "r2-d2s-droids-2026",
+	} {
+		if got := models.TeamSlug(name); got != want {
+			t.Errorf("TeamSlug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCreateTeamConcurrentlyGivesUniqueSlugs(t *testing.T) {
+	gdb := testutil.NewDB(t)
+
+	const teams = 20
+	slugs := make([]string, teams)
+	errs := make([]error, teams)
+	var wg sync.WaitGroup
+	for i := range teams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			team, err := CreateTeamForUser(gdb, "Sam")
+			errs[i] = err
+			if err == nil {
+				slugs[i] = team.Slug
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for i, slug := range slugs {
+		if errs[i] != nil {
+			t.Fatalf("create %d failed: %v", i, errs[i])
+		}
+		if seen[slug] {
+			t.Errorf("slug %s was given twice", slug)
+		}
+		seen[slug] = true
+	}
+	if !seen["sams-team"] {
+		t.Errorf("slugs %v, want one without a suffix", slugs)
+	}
+
+	var names []string
+	gdb.Model(&models.Team{}).Distinct().Pluck("name", &names)
+	if len(names) != 1 || names[0] != "Sam's Team" {
+		t.Errorf("names %v, want every team named Sam's Team", names)
+	}
+}
+
+func TestCreateTeamRetriesInsideTransaction(t *testing.T) {
+	gdb := testutil.NewDB(t)
+
+	// 🔁 The first insert is rejected as a duplicate, its savepoint keeps the transaction usable
+	rejected := 0
+	if err := gdb.Callback().Create().Before("gorm:create").Register("test:reject_team", func(db *gorm.DB) {
+		if _, ok := db.Statement.Dest.(*models.Team); ok && rejected == 0 {
+			rejected++
+			db.AddError(gorm.ErrDuplicatedKey)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gdb.Callback().Create().Remove("test:reject_team") })
+
+	var team *models.Team
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if team, err = CreateTeam(tx, "Acme"); err != nil {
+			return err
+		}
+		return tx.Create(&models.User{Email: "owner@acme.test", Password: "x", FirstName: "Owner", Role: models.UserRoleAdmin, TeamID: team.ID}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected != 1 || team.Slug != "acme" {
+		t.Errorf("rejected %d inserts and got slug %q, want 1 and acme", rejected, team.Slug)
+	}
+	reload[models.Team](t, gdb, team.ID)
+}
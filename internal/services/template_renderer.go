@@ -0,0 +1,639 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"be0/internal/models"
+	"be0/internal/sanitize"
+)
+
+// Errors returned by TemplateRenderer, wrapped with the placeholder and line
+var (
+	ErrInvalidPlaceholder = errors.New("invalid placeholder")
+	ErrUnknownFunction    = errors.New("unknown template function")
+	ErrUndefinedVariable  = errors.New("undefined variable")
+	ErrInvalidValue       = errors.New("invalid value")
+	ErrUnsafeValue        = errors.New("unsafe value")
+	ErrRenderTooLarge     = errors.New("rendered output too large")
+)
+
+const (
+	// DefaultMaxRenderBytes caps rendered HTML when TemplateRendererConfig.MaxBytes is not set
+	DefaultMaxRenderBytes = 1 << 20
+	// maxSubjectBytes is the longest line RFC 5322 allows in a header
+	maxSubjectBytes = 998
+)
+
+// variableNamePattern matches a variable name, dotted names look into nested objects
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// TemplateRendererConfig configures a TemplateRenderer
+type TemplateRendererConfig struct {
+	// Strict fails on undefined variables, malformed placeholders and unsafe values.
+	// Otherwise undefined and malformed placeholders are left in the output as written
+	// and unsafe values are left out.
+	Strict bool
+	// MaxBytes caps the rendered HTML, DefaultMaxRenderBytes when 0
+	MaxBytes int
+}
+
+// TemplateRenderer fills {{name}} placeholders of templates from a variable payload.
+// Placeholders may pipe the value through functions, e.g. {{name | default "there" | upper}}.
+// Only the functions of templateFuncs are available, none of them reach files, the
+// environment or the network. Values are escaped for where they appear in the HTML, so a
+// payload cannot add markup, attributes or javascript: URLs to the template.
+type TemplateRenderer struct {
+	strict   bool
+	maxBytes int
+}
+
+// NewTemplateRenderer creates a template renderer
+func NewTemplateRenderer(cfg TemplateRendererConfig) *TemplateRenderer {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxRenderBytes
+	}
+	return &TemplateRenderer{strict: cfg.Strict, maxBytes: cfg.MaxBytes}
+}
+
+// RenderedTemplate is the outcome of rendering a template
+type RenderedTemplate struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	// Missing lists the undefined variables whose placeholders were left in the output
+	Missing []string `json:"missing,omitempty"`
+	// Dropped lists the variables whose values were left out as unsafe where they appear
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// Render renders the subject and HTML of a template
+func (r *TemplateRenderer) Render(t *models.Template, vars map[string]interface{}) (*RenderedTemplate, error) {
+	rendered := &RenderedTemplate{}
+	var err error
+	if rendered.Subject, err = r.render(t.Subject, vars, false, maxSubjectBytes, rendered); err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	if rendered.HTML, err = r.render(t.HTML, vars, true, r.maxBytes, rendered); err != nil {
+		return nil, fmt.Errorf("html: %w", err)
+	}
+	return rendered, nil
+}
+
+// RenderHTML renders an HTML body, values are escaped for their place in the markup
+func (r *TemplateRenderer) RenderHTML(src string, vars map[string]interface{}) (*RenderedTemplate, error) {
+	rendered := &RenderedTemplate{}
+	var err error
+	if rendered.HTML, err = r.render(src, vars, true, r.maxBytes, rendered); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// RenderSubject renders a subject line, line breaks and other control characters in values become spaces
+func (r *TemplateRenderer) RenderSubject(src string, vars map[string]interface{}) (*RenderedTemplate, error) {
+	rendered := &RenderedTemplate{}
+	var err error
+	if rendered.Subject, err = r.render(src, vars, false, maxSubjectBytes, rendered); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// render fills the placeholders of src, escaping values for HTML when markup is set
+func (r *TemplateRenderer) render(src string, vars map[string]interface{}, markup bool, limit int, rendered *RenderedTemplate) (string, error) {
+	var out strings.Builder
+	var state markupState
+	write := func(s string, track string) error {
+		if out.Len()+len(s) > limit {
+			return fmt.Errorf("%w: more than %d bytes", ErrRenderTooLarge, limit)
+		}
+		out.WriteString(s)
+		if markup {
+			state.advance(track)
+		}
+		return nil
+	}
+
+	for offset := 0; offset < len(src); {
+		i := strings.Index(src[offset:], "{{")
+		if i < 0 {
+			if err := write(src[offset:], src[offset:]); err != nil {
+				return "", err
+			}
+			break
+		}
+		start := offset + i
+		if err := write(src[offset:start], src[offset:start]); err != nil {
+			return "", err
+		}
+
+		var p *placeholder
+		var err error
+		end := strings.Index(src[start:], "}}")
+		if end < 0 {
+			err = fmt.Errorf("%w: %q is never closed", ErrInvalidPlaceholder, excerptOf(src[start:]))
+		} else {
+			body := src[start+2 : start+end]
+			if markup {
+				// The sanitizer may have re-rendered the template with quotes as entities
+				body = html.UnescapeString(body)
+			}
+			p, err = parsePlaceholder(body)
+		}
+		if err != nil {
+			if r.strict || !errors.Is(err, ErrInvalidPlaceholder) {
+				return "", fmt.Errorf("%w (line %d)", err, lineOf(src, start))
+			}
+			// Lenient mode keeps what does not parse as text
+			if err := write("{{", "{{"); err != nil {
+				return "", err
+			}
+			offset = start + 2
+			continue
+		}
+		raw := src[start : start+end+2]
+		offset = start + end + 2
+
+		value, defined, err := p.evaluate(vars)
+		if err != nil {
+			return "", fmt.Errorf("%w (line %d)", err, lineOf(src, start))
+		}
+		if !defined {
+			if r.strict {
+				return "", fmt.Errorf("%w: %s (line %d)", ErrUndefinedVariable, p.name, lineOf(src, start))
+			}
+			rendered.Missing = appendUnique(rendered.Missing, p.name)
+			// The placeholder is template source, it stands for a value in the markup state
+			if err := write(raw, "x"); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		text := stringify(value)
+		if !markup {
+			if err := write(stripControl(text), ""); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		escaped, err := state.escape(text)
+		if err != nil {
+			if r.strict {
+				return "", fmt.Errorf("%w: %s %v (line %d)", ErrUnsafeValue, p.name, err, lineOf(src, start))
+			}
+			rendered.Dropped = appendUnique(rendered.Dropped, p.name)
+			continue
+		}
+		if err := write(escaped, escaped); err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+// placeholder is a parsed {{name | func arg...}}
+type placeholder struct {
+	name  string
+	pipes []pipe
+}
+
+// pipe is one function call of a placeholder
+type pipe struct {
+	fn   string
+	args []string
+}
+
+// placeholderToken is a word of a placeholder, quoted tokens are never separators
+type placeholderToken struct {
+	text   string
+	quoted bool
+}
+
+// parsePlaceholder parses the text between {{ and }}
+func parsePlaceholder(body string) (*placeholder, error) {
+	tokens, err := tokenizePlaceholder(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]placeholderToken
+	current := []placeholderToken{}
+	for _, token := range tokens {
+		if !token.quoted && token.text == "|" {
+			groups = append(groups, current)
+			current = []placeholderToken{}
+			continue
+		}
+		current = append(current, token)
+	}
+	groups = append(groups, current)
+
+	head := groups[0]
+	if len(head) != 1 || head[0].quoted || !variableNamePattern.MatchString(head[0].text) {
+		return nil, fmt.Errorf("%w: {{%s}} does not start with a variable name", ErrInvalidPlaceholder, strings.TrimSpace(body))
+	}
+
+	p := &placeholder{name: head[0].text}
+	for _, group := range groups[1:] {
+		if len(group) == 0 || group[0].quoted {
+			return nil, fmt.Errorf("%w: {{%s}} has an empty function", ErrInvalidPlaceholder, strings.TrimSpace(body))
+		}
+		fn, ok := templateFuncs[group[0].text]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFunction, group[0].text)
+		}
+		args := make([]string, 0, len(group)-1)
+		for _, token := range group[1:] {
+			args = append(args, token.text)
+		}
+		if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+			return nil, fmt.Errorf("%w: %s takes %d to %d arguments, got %d", ErrInvalidPlaceholder, group[0].text, fn.minArgs, fn.maxArgs, len(args))
+		}
+		p.pipes = append(p.pipes, pipe{fn: group[0].text, args: args})
+	}
+	return p, nil
+}
+
+// tokenizePlaceholder splits a placeholder into words, | and "quoted" or 'quoted' strings
+func tokenizePlaceholder(body string) ([]placeholderToken, error) {
+	var tokens []placeholderToken
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '|':
+			tokens = append(tokens, placeholderToken{text: "|"})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(body[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string in {{%s}}", ErrInvalidPlaceholder, strings.TrimSpace(body))
+			}
+			tokens = append(tokens, placeholderToken{text: body[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(body[i:], " \t\n\r|\"'")
+			if end < 0 {
+				end = len(body) - i
+			}
+			tokens = append(tokens, placeholderToken{text: body[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// evaluate looks the variable up and runs it through the pipes
+func (p *placeholder) evaluate(vars map[string]interface{}) (interface{}, bool, error) {
+	value, defined := lookupVariable(vars, p.name)
+	for _, call := range p.pipes {
+		var err error
+		value, defined, err = templateFuncs[call.fn].apply(value, defined, call.args)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", call.fn, err)
+		}
+	}
+	return value, defined, nil
+}
+
+// lookupVariable finds name in vars, a dotted name looks into nested objects
+func lookupVariable(vars map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := vars[name]; ok {
+		return value, true
+	}
+
+	var current interface{} = vars
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// templateFunc is a function placeholders can pipe values through. defined is false for
+// a missing variable, functions other than default pass it on untouched.
+type templateFunc struct {
+	minArgs, maxArgs int
+	apply            func(value interface{}, defined bool, args []string) (interface{}, bool, error)
+}
+
+// templateFuncs are the functions available to templates
+var templateFuncs = map[string]templateFunc{
+	"default": {minArgs: 1, maxArgs: 1, apply: func(value interface{}, defined bool, args []string) (interface{}, bool, error) {
+		if !defined || stringify(value) == "" {
+			return args[0], true, nil
+		}
+		return value, true, nil
+	}},
+	"upper": stringFunc(strings.ToUpper),
+	"lower": stringFunc(strings.ToLower),
+	"title": stringFunc(titleCase),
+	"trim":  stringFunc(strings.TrimSpace),
+	"truncate": {minArgs: 1, maxArgs: 1, apply: func(value interface{}, defined bool, args []string) (interface{}, bool, error) {
+		if !defined {
+			return value, false, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return nil, false, fmt.Errorf("%w: length %q is not a positive number", ErrInvalidPlaceholder, args[0])
+		}
+		runes := []rune(stringify(value))
+		if len(runes) <= n {
+			return string(runes), true, nil
+		}
+		return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…", true, nil
+	}},
+	"date": {minArgs: 0, maxArgs: 1, apply: func(value interface{}, defined bool, args []string) (interface{}, bool, error) {
+		if !defined {
+			return value, false, nil
+		}
+		layout := "2006-01-02"
+		if len(args) > 0 {
+			layout = args[0]
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			return nil, false, err
+		}
+		return t.Format(layout), true, nil
+	}},
+	"number": {minArgs: 0, maxArgs: 1, apply: func(value interface{}, defined bool, args []string) (interface{}, bool, error) {
+		if !defined {
+			return value, false, nil
+		}
+		decimals := 0
+		if len(args) > 0 {
+			var err error
+			if decimals, err = strconv.Atoi(args[0]); err != nil || decimals < 0 || decimals > 10 {
+				return nil, false, fmt.Errorf("%w: decimals %q must be between 0 and 10", ErrInvalidPlaceholder, args[0])
+			}
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(stringify(value)), 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %q is not a number", ErrInvalidValue, stringify(value))
+		}
+		return groupThousands(strconv.FormatFloat(f, 'f', decimals, 64)), true, nil
+	}},
+}
+
+// stringFunc turns a string transformation into a template function without arguments
+func stringFunc(fn func(string) string) templateFunc {
+	return templateFunc{apply: func(value interface{}, defined bool, _ []string) (interface{}, bool, error) {
+		if !defined {
+			return value, false, nil
+		}
+		return fn(stringify(value)), true, nil
+	}}
+}
+
+// titleCase upper-cases the first letter of every word
+func titleCase(s string) string {
+	start := true
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			start = true
+			return r
+		}
+		if start {
+			start = false
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
+
+// parseTime reads RFC 3339 times, plain dates and Unix seconds
+func parseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		return time.Unix(int64(v), 0).UTC(), nil
+	}
+	s := strings.TrimSpace(stringify(value))
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q is not an RFC 3339 time or a date", ErrInvalidValue, s)
+}
+
+// groupThousands adds commas between the thousands of a formatted number
+func groupThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String() + fraction
+}
+
+// stringify formats a JSON value for output
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// stripControl turns line breaks and other control characters into spaces, so values cannot add headers
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// attributeEscaper escapes values inside attributes, quoted or not
+var attributeEscaper = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;", "`", "&#96;", "=", "&#61;",
+	" ", "&#32;", "\t", "&#9;", "\n", "&#10;", "\r", "&#13;", "\f", "&#12;",
+)
+
+// markupState follows the template source far enough to know where a placeholder sits:
+// in text, inside a tag or inside an attribute value
+type markupState struct {
+	inTag       bool
+	readingName bool   // reading the tag name right after <
+	tagName     string // so far, !-- starts a comment
+	inComment   bool
+	dashes      int    // consecutive - inside a comment, --> ends it
+	attr        string // attribute being read, lower-cased
+	attrDone    bool   // whitespace ended the attribute name
+	afterEquals bool   // = was read, the value has not started
+	inValue     bool
+	quote       byte   // quote of the value, 0 when unquoted
+	value       string // attribute value so far, as written
+}
+
+// advance moves the state over literal template text or escaped output
+func (s *markupState) advance(text string) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		space := c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+		switch {
+		case s.inComment:
+			if c == '>' && s.dashes >= 2 {
+				s.inComment = false
+			}
+			if c == '-' {
+				s.dashes++
+			} else {
+				s.dashes = 0
+			}
+		case !s.inTag:
+			// A < before a space or digit is text. At the end of the text the next character
+			// comes from a value, which must not be able to open a tag.
+			if c == '<' && (i+1 == len(text) || startsTag(text[i+1])) {
+				*s = markupState{inTag: true, readingName: true}
+			}
+		case s.readingName:
+			switch {
+			case c == '>':
+				s.inTag = false
+			case space:
+				s.readingName = false
+			default:
+				s.tagName += string(c)
+				if s.tagName == "!--" {
+					*s = markupState{inComment: true}
+				}
+			}
+		case s.inValue && s.quote != 0:
+			if c == s.quote {
+				s.inValue, s.quote = false, 0
+			} else {
+				s.value += string(c)
+			}
+		case s.inValue:
+			switch {
+			case c == '>':
+				s.inTag = false
+			case space:
+				s.inValue = false
+			default:
+				s.value += string(c)
+			}
+		case s.afterEquals:
+			switch {
+			case space:
+			case c == '>':
+				s.inTag = false
+			case c == '"' || c == '\'':
+				s.afterEquals, s.inValue, s.quote, s.value = false, true, c, ""
+			default:
+				s.afterEquals, s.inValue, s.quote, s.value = false, true, 0, string(c)
+			}
+		default:
+			switch {
+			case c == '>':
+				s.inTag = false
+			case space:
+				s.attrDone = s.attr != ""
+			case c == '=':
+				s.afterEquals = true
+			case c == '/':
+				s.attr, s.attrDone = "", false
+			default:
+				if s.attrDone {
+					s.attr, s.attrDone = "", false
+				}
+				s.attr += strings.ToLower(string(c))
+			}
+		}
+	}
+}
+
+// startsTag reports whether c after < opens a tag, comment or doctype
+func startsTag(c byte) bool {
+	return c == '/' || c == '!' || c == '?' || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+// escape escapes a value for the current position, or fails where no value can go safely
+func (s *markupState) escape(text string) (string, error) {
+	switch {
+	case !s.inTag || s.inComment:
+		return html.EscapeString(text), nil
+	case s.inValue || s.afterEquals:
+		if strings.HasPrefix(s.attr, "on") {
+			return "", fmt.Errorf("inside the event handler %s", s.attr)
+		}
+		// The value is checked together with what precedes it, so no scheme can be assembled from parts
+		if sanitize.URLBlocked(s.attr, html.UnescapeString(s.value)+text) {
+			return "", fmt.Errorf("is a blocked URL in %s", s.attr)
+		}
+		return attributeEscaper.Replace(text), nil
+	default:
+		return "", errors.New("inside a tag, outside an attribute value")
+	}
+}
+
+// appendUnique appends name unless it is already listed
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// lineOf returns the 1-based line of a byte offset in src
+func lineOf(src string, offset int) int {
+	return strings.Count(src[:offset], "\n") + 1
+}
+
+// excerptOf shortens a source snippet for errors
+func excerptOf(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if utf8.RuneCountInString(s) > 20 {
+		return string([]rune(s)[:20]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"be0/internal/models"
+)
+
+func TestTemplateRendererEscapesInjectionAttempts(t *testing.T) {
+	tests := []struct {
+		name  string
+		html  string
+		value string
+		want  string // lenient output, "" when the value is dropped as unsafe
+	}{
+		{"script in text", `<p>{{v}}</p>`, `<script>alert(1)</script>`, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+		{"quoted attribute breakout", `<a title="{{v}}">x</a>`, `" onclick="alert(1)`, `<a title="&#34;&#32;onclick&#61;&#34;alert(1)">x</a>`},
+		{"unquoted attribute breakout", `<a title={{v}}>x</a>`, `x onmouseover=alert(1)`, `<a title=x&#32;onmouseover&#61;alert(1)>x</a>`},
+		{"comment breakout", `<!-- {{v}} -->`, `--><script>alert(1)</script>`, `<!-- --&gt;&lt;script&gt;alert(1)&lt;/script&gt; -->`},
+		{"placeholder in a value", `<p>{{v}}</p>`, `{{secret}}`, `<p>{{secret}}</p>`},
+		{"javascript URL", `<a href="{{v}}">x</a>`, `javascript:alert(1)`, ""},
+		{"mixed case javascript URL", `<a href="{{v}}">x</a>`, `JaVaScRiPt:alert(1)`, ""},
+		{"scheme split around the placeholder", `<a href="java{{v}}">x</a>`, `script:alert(1)`, ""},
+		{"scheme split behind an entity", `<a href="&#106;ava{{v}}">x</a>`, `script:alert(1)`, ""},
+		{"event handler", `<img src="a.png" onerror="{{v}}">`, `alert(1)`, ""},
+		{"attribute injection inside a tag", `<img src="a.png" {{v}}>`, `onerror=alert(1)`, ""},
+		{"tag opened by the value", `<p><{{v}}</p>`, `script>alert(1)</script`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]interface{}{"v": tt.value, "secret": "s3cret"}
+
+			lenient, err := NewTemplateRenderer(TemplateRendererConfig{}).RenderHTML(tt.html, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, strictErr := NewTemplateRenderer(TemplateRendererConfig{Strict: true}).RenderHTML(tt.html, vars)
+
+			if tt.want == "" {
+				if len(lenient.Dropped) != 1 || lenient.Dropped[0] != "v" || strings.Contains(lenient.HTML, "alert") {
+					t.Errorf("lenient render %q dropped %v, want v left out", lenient.HTML, lenient.Dropped)
+				}
+				if !errors.Is(strictErr, ErrUnsafeValue) {
+					t.Errorf("strict render got %v, want ErrUnsafeValue", strictErr)
+				}
+				return
+			}
+			if lenient.HTML != tt.want || len(lenient.Dropped) != 0 {
+				t.Errorf("got %q dropping %v, want %q", lenient.HTML, lenient.Dropped, tt.want)
+			}
+			if strictErr != nil {
+				t.Errorf("strict render failed: %v", strictErr)
+			}
+		})
+	}
+}
+
+func TestTemplateRendererKeepsSubjectsOnOneLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"CRLF header injection", "Ada\r\nBcc: all@example.com", "Hi Ada  Bcc: all@example.com"},
+		{"bare LF", "Ada\nX-Priority: 1", "Hi Ada X-Priority: 1"},
+		{"markup is left as written", "<b>Ada</b>", "Hi <b>Ada</b>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := NewTemplateRenderer(TemplateRendererConfig{Strict: true}).
+				Render(&models.Template{Subject: "Hi {{name}}", HTML: "<p>Hi</p>"}, map[string]interface{}{"name": tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rendered.Subject != tt.want {
+				t.Errorf("subject %q, want %q", rendered.Subject, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateRendererRefusesUnknownFunctionsAndOversizedOutput(t *testing.T) {
+	renderer := NewTemplateRenderer(TemplateRendererConfig{MaxBytes: 64})
+
+	for _, src := range []string{`{{v | exec "rm -rf /"}}`, `{{v | env "JWT_SECRET"}}`} {
+		if _, err := renderer.RenderHTML(src, map[string]interface{}{"v": "x"}); !errors.Is(err, ErrUnknownFunction) {
+			t.Errorf("%s: got %v, want ErrUnknownFunction", src, err)
+		}
+	}
+	if _, err := renderer.RenderHTML(`<p>{{v}}</p>`, map[string]interface{}{"v": strings.Repeat("a", 100)}); !errors.Is(err, ErrRenderTooLarge) {
+		t.Errorf("got %v, want ErrRenderTooLarge", err)
+	}
+}
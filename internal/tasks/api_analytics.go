@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/models"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// apiRequestLogCleanupBatch bounds the logs removed per statement, keeping locks short
+const apiRequestLogCleanupBatch = 5000
+
+// HandleAPIRequestLogCleanup removes API request logs older than API_ANALYTICS_RETENTION_DAYS
+func (h *TaskHandler) HandleAPIRequestLogCleanup(ctx context.Context, t *asynq.Task) error {
+	retention := time.Duration(config.GetConfig().APIAnalytics.RetentionDays) * 24 * time.Hour
+	removed, err := CleanupAPIRequestLogs(ctx, h.db, time.Now().UTC().Add(-retention))
+	if err != nil {
+		return err
+	}
+	h.logger.Info("removed %d expired API request logs", removed)
+	return nil
+}
+
+// CleanupAPIRequestLogs removes the API request logs made before cutoff
+func CleanupAPIRequestLogs(ctx context.Context, db *gorm.DB, cutoff time.Time) (int64, error) {
+	db = db.WithContext(ctx)
+	var removed int64
+	for {
+		var ids []string
+		if err := db.Model(&models.APIRequestLog{}).Where("requested_at < ?", cutoff).
+			Limit(apiRequestLogCleanupBatch).Pluck("id", &ids).Error; err != nil {
+			return removed, fmt.Errorf("failed to find expired API request logs: %w", err)
+		}
+		if len(ids) == 0 {
+			return removed, nil
+		}
+		result := db.Where("id IN ?", ids).Delete(&models.APIRequestLog{})
+		if result.Error != nil {
+			return removed, fmt.Errorf("failed to clean up API request logs: %w", result.Error)
+		}
+		removed += result.RowsAffected
+	}
+}
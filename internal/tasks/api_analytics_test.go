@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+)
+
+func TestCleanupAPIRequestLogs(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	now := time.Now().UTC()
+	var logs []models.APIRequestLog
+	for _, age := range []time.Duration{time.Hour, 40 * 24 * time.Hour, 90 * 24 * time.Hour} {
+		at := now.Add(-age)
+		logs = append(logs, models.APIRequestLog{
+			APIKeyID: team.ID, TeamID: team.ID, Method: "GET", Route: "/api/v1/files",
+			StatusCode: 200, LatencyBucket: "<50ms", RequestedAt: at, Day: at.Format(time.DateOnly),
+		})
+	}
+	if err := gdb.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := CleanupAPIRequestLogs(context.Background(), gdb, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left int64
+	gdb.Model(&models.APIRequestLog{}).Count(&left)
+	if removed != 2 || left != 1 {
+		t.Errorf("removed %d and kept %d logs, want 2 and 1", removed, left)
+	}
+}
@@ -0,0 +1,295 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/utils/crypto"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// KindAuditExportFailed is the notification kind of the alerts sent when an audit export keeps failing
+const KindAuditExportFailed = "audit_export_failed"
+
+// AuditExportEvent is the X-Be0-Event of https audit exports
+const AuditExportEvent = "audit_logs.export"
+
+const (
+	// auditExportBatchSize is the most audit logs delivered at once
+	auditExportBatchSize = 500
+	// auditExportMaxBatches caps the batches of an export in one run, the next run continues
+	auditExportMaxBatches = 20
+	// auditExportSettle is how old logs must be to be exported. Replicas write logs in the
+	// background, so a log may be committed after a newer one and would be skipped otherwise.
+	auditExportSettle = time.Minute
+	// syslogPriority is the facility log audit (13) at severity informational (6)
+	syslogPriority = 13*8 + 6
+)
+
+var (
+	auditExportLog    = logger.New("audit_export")
+	auditExportClient = &http.Client{Timeout: 30 * time.Second}
+	// auditSyslogTLS verifies syslog servers, nil uses the system roots
+	auditSyslogTLS *tls.Config
+)
+
+// AuditBatch is a run of audit logs serialized as NDJSON, one log per line
+type AuditBatch struct {
+	// ID is the first log of the batch, a batch retried after a crash is delivered under the same ID
+	ID string
+	// CreatedAt is when the first log was recorded
+	CreatedAt time.Time
+	Logs      []models.AuditLog
+	Body      []byte
+}
+
+// AuditBatchDeliverer delivers a batch to the export's target, e.g. DeliverAuditBatch
+type AuditBatchDeliverer func(ctx context.Context, export *models.AuditExport, batch AuditBatch) error
+
+// auditExportOptions make scheduled and manual runs the same unique task, so runs never overlap
+var auditExportOptions = []asynq.Option{
+	asynq.Queue(QueueLow),
+	asynq.MaxRetry(RetryDefault),
+	asynq.Timeout(TimeoutMedium),
+	asynq.Unique(TimeoutMedium),
+}
+
+// EnqueueAuditExport schedules a run of every active audit export. A run that is
+// already queued or in progress is not queued again.
+func (c *TaskClient) EnqueueAuditExport(ctx context.Context) error {
+	_, err := c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeAuditExport, nil, auditExportOptions...))
+	if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+		return fmt.Errorf("failed to enqueue audit export: %w", err)
+	}
+	return nil
+}
+
+// HandleAuditExport pushes new audit logs to every active export. Failed exports are
+// retried, super admins are alerted when the last retry fails.
+func (h *TaskHandler) HandleAuditExport(ctx context.Context, t *asynq.Task) error {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	return RunAuditExports(ctx, h.db, DeliverAuditBatch, h.taskClient.EnqueueNotificationEmail, retried >= maxRetry)
+}
+
+// RunAuditExports runs every active export. With lastAttempt, exports that fail alert the super
+// admins, once until the export succeeds again.
+func RunAuditExports(ctx context.Context, db *gorm.DB, deliver AuditBatchDeliverer, send EmailSender, lastAttempt bool) error {
+	var exports []models.AuditExport
+	if err := db.WithContext(ctx).Where("active = true AND is_deleted = false").Find(&exports).Error; err != nil {
+		return fmt.Errorf("failed to list audit exports: %w", err)
+	}
+
+	var failed []error
+	for i := range exports {
+		export := &exports[i]
+		now := time.Now().UTC()
+		exported, err := ExportAuditLogs(ctx, db, export, deliver)
+		if err == nil {
+			if exported > 0 {
+				auditExportLog.Info("Exported %d audit logs to %s export %s", exported, export.Target, export.ID)
+			}
+			db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+				"last_run_at": now, "last_error": "", "alerted_at": nil,
+			})
+			continue
+		}
+
+		failed = append(failed, fmt.Errorf("audit export %s: %w", export.ID, err))
+		updates := map[string]interface{}{"last_run_at": now, "last_error": err.Error()}
+		if lastAttempt && export.AlertedAt == nil {
+			if alertErr := alertAuditExportFailure(ctx, db, send, export, err); alertErr != nil {
+				auditExportLog.Error("Failed to alert about audit export %s: %v", alertErr, export.ID)
+			} else {
+				updates["alerted_at"] = now
+			}
+		}
+		db.WithContext(ctx).Model(export).Updates(updates)
+	}
+	return errors.Join(failed...)
+}
+
+// ExportAuditLogs delivers the logs recorded since the export's checkpoint in batches, moving
+// the checkpoint past each delivered batch. It returns how many logs were delivered.
+func ExportAuditLogs(ctx context.Context, db *gorm.DB, export *models.AuditExport, deliver AuditBatchDeliverer) (int, error) {
+	cutoff := time.Now().UTC().Add(-auditExportSettle)
+
+	exported := 0
+	for i := 0; i < auditExportMaxBatches; i++ {
+		query := db.WithContext(ctx).Where("created_at < ?", cutoff)
+		if export.TeamID != nil {
+			query = query.Where("team_id = ?", *export.TeamID)
+		}
+		if export.LastLogCreatedAt != nil {
+			at := *export.LastLogCreatedAt
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", at, at, export.LastLogID)
+		}
+
+		var logs []models.AuditLog
+		if err := query.Order("created_at, id").Limit(auditExportBatchSize).Find(&logs).Error; err != nil {
+			return exported, fmt.Errorf("failed to read audit logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return exported, nil
+		}
+
+		var body bytes.Buffer
+		encoder := json.NewEncoder(&body)
+		for _, entry := range logs {
+			if err := encoder.Encode(entry); err != nil {
+				return exported, fmt.Errorf("failed to encode audit log %s: %w", entry.ID, err)
+			}
+		}
+
+		batch := AuditBatch{ID: logs[0].ID, CreatedAt: logs[0].CreatedAt.Time, Logs: logs, Body: body.Bytes()}
+		if err := deliver(ctx, export, batch); err != nil {
+			return exported, err
+		}
+
+		// ✅ The checkpoint only moves once the batch was delivered
+		last := logs[len(logs)-1]
+		lastAt := last.CreatedAt.Time
+		if err := db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+			"last_log_id": last.ID, "last_log_created_at": lastAt,
+		}).Error; err != nil {
+			return exported, fmt.Errorf("failed to save audit export checkpoint: %w", err)
+		}
+		export.LastLogID, export.LastLogCreatedAt = last.ID, &lastAt
+		exported += len(logs)
+
+		if len(logs) < auditExportBatchSize {
+			return exported, nil
+		}
+	}
+	return exported, nil
+}
+
+// DeliverAuditBatch delivers a batch to the target of the export
+func DeliverAuditBatch(ctx context.Context, export *models.AuditExport, batch AuditBatch) error {
+	switch export.Target {
+	case models.AuditExportTargetS3:
+		return deliverAuditBatchToS3(ctx, export, batch)
+	case models.AuditExportTargetHTTPS:
+		return deliverAuditBatchOverHTTPS(ctx, export, batch)
+	case models.AuditExportTargetSyslog:
+		return deliverAuditBatchOverSyslog(ctx, export, batch)
+	}
+	return fmt.Errorf("unknown audit export target %q", export.Target)
+}
+
+// AuditBatchKey is the object a batch is written to: prefix/yyyy/mm/dd/<batch id>.ndjson
+func AuditBatchKey(export *models.AuditExport, batch AuditBatch) string {
+	return path.Join(strings.Trim(export.Destination, "/"), batch.CreatedAt.UTC().Format("2006/01/02"), batch.ID+".ndjson")
+}
+
+func deliverAuditBatchToS3(ctx context.Context, export *models.AuditExport, batch AuditBatch) error {
+	writer := models.GetFileWriter()
+	if writer == nil {
+		return errors.New("no storage backend registered")
+	}
+	return writer.PutFile(ctx, AuditBatchKey(export, batch), batch.Body, "application/x-ndjson")
+}
+
+// deliverAuditBatchOverHTTPS POSTs the batch signed like webhook deliveries, the delivery header
+// carries the batch ID so receivers can drop a batch delivered twice
+func deliverAuditBatchOverHTTPS(ctx context.Context, export *models.AuditExport, batch AuditBatch) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, export.Destination, bytes.NewReader(batch.Body))
+	if err != nil {
+		return fmt.Errorf("invalid audit export URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(HeaderWebhookEvent, AuditExportEvent)
+	req.Header.Set(HeaderWebhookSignature, "sha256="+crypto.ComputeWebhookSignature(batch.Body, export.Secret))
+	req.Header.Set(HeaderWebhookDelivery, batch.ID)
+
+	resp, err := auditExportClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit export to %s failed: %w", export.Destination, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit export to %s returned %d", export.Destination, resp.StatusCode)
+	}
+	return nil
+}
+
+// deliverAuditBatchOverSyslog sends each log as an RFC 5424 message, octet counted as RFC 5425 frames them
+func deliverAuditBatchOverSyslog(ctx context.Context, export *models.AuditExport, batch AuditBatch) error {
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 10 * time.Second}, Config: auditSyslogTLS}
+	conn, err := dialer.DialContext(ctx, "tcp", export.Destination)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog %s: %w", export.Destination, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(TimeoutShort))
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	var frames bytes.Buffer
+	for _, line := range bytes.Split(bytes.TrimSuffix(batch.Body, []byte("\n")), []byte("\n")) {
+		msg := fmt.Sprintf("<%d>1 %s %s be0 - audit - %s", syslogPriority,
+			time.Now().UTC().Format(time.RFC3339Nano), hostname, line)
+		fmt.Fprintf(&frames, "%d %s", len(msg), msg)
+	}
+	if _, err := conn.Write(frames.Bytes()); err != nil {
+		return fmt.Errorf("failed to write to syslog %s: %w", export.Destination, err)
+	}
+	return nil
+}
+
+// alertAuditExportFailure emails the active super admins that the export is failing
+func alertAuditExportFailure(ctx context.Context, db *gorm.DB, send EmailSender, export *models.AuditExport, cause error) error {
+	var admins []models.User
+	if err := db.WithContext(ctx).
+		Where("role = ? AND is_active = true AND is_deleted = false", models.UserRoleSuperAdmin).
+		Find(&admins).Error; err != nil {
+		return fmt.Errorf("failed to list super admins: %w", err)
+	}
+
+	scope := "every team"
+	teamID := ""
+	if export.TeamID != nil {
+		scope, teamID = "team "+*export.TeamID, *export.TeamID
+	}
+	since := "the beginning"
+	if export.LastLogCreatedAt != nil {
+		since = export.LastLogCreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	var failed []error
+	for _, admin := range admins {
+		if err := send(ctx, NotificationEmail{
+			Kind: KindAuditExportFailed,
+			Message: mailer.Message{
+				TeamID:  teamID,
+				To:      admin.Email,
+				Subject: fmt.Sprintf("Audit log export to %s is failing", export.Target),
+				Text: fmt.Sprintf("The audit log export %s of %s to %s %s failed after %d retries:\n\n%v\n\n"+
+					"Logs recorded after %s have not been delivered, they are delivered once the export succeeds again.",
+					export.ID, scope, export.Target, export.Destination, RetryDefault, cause, since),
+			},
+		}); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
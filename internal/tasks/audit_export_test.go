@@ -0,0 +1,251 @@
+package tasks
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/crypto"
+
+	"gorm.io/gorm"
+)
+
+// recordAuditLogs records n audit logs of the team a second apart from start
+func recordAuditLogs(t *testing.T, gdb *gorm.DB, teamID string, start time.Time, n int) []models.AuditLog {
+	t.Helper()
+	logs := make([]models.AuditLog, n)
+	for i := range logs {
+		logs[i] = models.AuditLog{
+			Base:       models.Base{CreatedAt: models.NewTimestamp(start.Add(time.Duration(i) * time.Second))},
+			TeamID:     teamID,
+			Method:     http.MethodPost,
+			Path:       "/api/v1/files",
+			Resource:   "files",
+			StatusCode: http.StatusCreated,
+		}
+	}
+	if err := gdb.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+// batchRecorder delivers batches to memory, failing while fail is set
+type batchRecorder struct {
+	batches []AuditBatch
+	fail    error
+}
+
+func (r *batchRecorder) deliver(_ context.Context, _ *models.AuditExport, batch AuditBatch) error {
+	if r.fail != nil {
+		return r.fail
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *batchRecorder) logIDs() []string {
+	var ids []string
+	for _, batch := range r.batches {
+		for _, entry := range batch.Logs {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids
+}
+
+func TestExportAuditLogsResumesFromTheCheckpoint(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	other := testutil.CreateTeam(t, gdb, "Globex")
+	hourAgo := time.Now().UTC().Add(-time.Hour)
+	logs := recordAuditLogs(t, gdb, team.ID, hourAgo, 3)
+	recordAuditLogs(t, gdb, other.ID, hourAgo, 2)
+	// Logs younger than the settle delay wait for the next run
+	recordAuditLogs(t, gdb, team.ID, time.Now().UTC(), 1)
+
+	export := models.AuditExport{TeamID: &team.ID, Target: models.AuditExportTargetS3, Destination: "siem", Active: true}
+	if err := gdb.Create(&export).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 💥 A failed delivery leaves the checkpoint where it was
+	recorder := &batchRecorder{fail: errors.New("connection refused")}
+	if err := RunAuditExports(context.Background(), gdb, recorder.deliver, nil, false); err == nil {
+		t.Fatal("failed delivery was not reported")
+	}
+	var failed models.AuditExport
+	gdb.First(&failed, "id = ?", export.ID)
+	if failed.LastLogCreatedAt != nil || !strings.Contains(failed.LastError, "connection refused") {
+		t.Fatalf("checkpoint %v and error %q after a failed delivery", failed.LastLogCreatedAt, failed.LastError)
+	}
+
+	recorder.fail = nil
+	if err := RunAuditExports(context.Background(), gdb, recorder.deliver, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if ids := recorder.logIDs(); len(ids) != 3 || ids[0] != logs[0].ID || ids[2] != logs[2].ID {
+		t.Fatalf("exported %v, want the team's 3 logs in order", ids)
+	}
+	if lines := strings.Count(string(recorder.batches[0].Body), "\n"); lines != 3 {
+		t.Errorf("batch has %d NDJSON lines, want 3", lines)
+	}
+
+	// 🔁 Only logs recorded after the checkpoint are exported by the next run
+	newer := recordAuditLogs(t, gdb, team.ID, hourAgo.Add(time.Minute), 1)
+	if err := RunAuditExports(context.Background(), gdb, recorder.deliver, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if ids := recorder.logIDs(); len(ids) != 4 || ids[3] != newer[0].ID {
+		t.Fatalf("exported %v, want the new log once", ids)
+	}
+
+	var done models.AuditExport
+	gdb.First(&done, "id = ?", export.ID)
+	if done.LastLogID != newer[0].ID || done.LastError != "" || done.LastRunAt == nil {
+		t.Errorf("checkpoint %s, error %q after the runs", done.LastLogID, done.LastError)
+	}
+}
+
+func TestRunAuditExportsAlertsSuperAdminsOnce(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	recordAuditLogs(t, gdb, team.ID, time.Now().UTC().Add(-time.Hour), 1)
+
+	export := models.AuditExport{Target: models.AuditExportTargetHTTPS, Destination: "https://siem.example.com", Active: true}
+	if err := gdb.Create(&export).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []NotificationEmail
+	send := func(_ context.Context, notification NotificationEmail) error {
+		sent = append(sent, notification)
+		return nil
+	}
+	recorder := &batchRecorder{fail: errors.New("503 Service Unavailable")}
+
+	// Retries that may still succeed do not alert
+	RunAuditExports(context.Background(), gdb, recorder.deliver, send, false)
+	if len(sent) != 0 {
+		t.Fatalf("alerted %d times before the last retry", len(sent))
+	}
+	RunAuditExports(context.Background(), gdb, recorder.deliver, send, true)
+	RunAuditExports(context.Background(), gdb, recorder.deliver, send, true)
+	if len(sent) != 1 || sent[0].Message.To != admin.Email || sent[0].Kind != KindAuditExportFailed {
+		t.Fatalf("sent %+v, want one alert to the super admin", sent)
+	}
+
+	// ✅ Succeeding clears the alert, a later outage alerts again
+	recorder.fail = nil
+	RunAuditExports(context.Background(), gdb, recorder.deliver, send, true)
+	recorder.fail = errors.New("timeout")
+	recordAuditLogs(t, gdb, team.ID, time.Now().UTC().Add(-time.Hour), 1)
+	RunAuditExports(context.Background(), gdb, recorder.deliver, send, true)
+	if len(sent) != 2 {
+		t.Errorf("sent %d alerts, want a second one for the new outage", len(sent))
+	}
+}
+
+func TestDeliverAuditBatchOverHTTPSIsSigned(t *testing.T) {
+	var received struct {
+		body      []byte
+		signature string
+		delivery  string
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.body, _ = io.ReadAll(r.Body)
+		received.signature = r.Header.Get(HeaderWebhookSignature)
+		received.delivery = r.Header.Get(HeaderWebhookDelivery)
+	}))
+	defer server.Close()
+	previous := auditExportClient
+	auditExportClient = server.Client()
+	t.Cleanup(func() { auditExportClient = previous })
+
+	export := &models.AuditExport{Target: models.AuditExportTargetHTTPS, Destination: server.URL, Secret: "shh"}
+	batch := AuditBatch{ID: "batch-1", Body: []byte("{\"id\":\"1\"}\n")}
+	if err := DeliverAuditBatch(context.Background(), export, batch); err != nil {
+		t.Fatal(err)
+	}
+	if string(received.body) != string(batch.Body) || received.delivery != "batch-1" ||
+		received.signature != "sha256="+crypto.ComputeWebhookSignature(batch.Body, "shh") {
+		t.Errorf("received %q signed %q as %q", received.body, received.signature, received.delivery)
+	}
+}
+
+func TestDeliverAuditBatchOverSyslogFramesMessages(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	server.Close()
+	cert := server.TLS.Certificates[0]
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	previous := auditSyslogTLS
+	auditSyslogTLS = &tls.Config{RootCAs: roots, ServerName: "example.com"}
+	t.Cleanup(func() { auditSyslogTLS = previous })
+
+	messages := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			messages <- nil
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var got []string
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				break
+			}
+			got = append(got, string(msg))
+		}
+		messages <- got
+	}()
+
+	export := &models.AuditExport{Target: models.AuditExportTargetSyslog, Destination: listener.Addr().String()}
+	batch := AuditBatch{ID: "batch-1", Body: []byte("{\"id\":\"1\"}\n{\"id\":\"2\"}\n")}
+	if err := DeliverAuditBatch(context.Background(), export, batch); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-messages
+	if len(got) != 2 {
+		t.Fatalf("received %d syslog messages, want 2: %q", len(got), got)
+	}
+	for i, msg := range got {
+		if !strings.HasPrefix(msg, "<110>1 ") || !strings.HasSuffix(msg, `{"id":"`+strconv.Itoa(i+1)+`"}`) {
+			t.Errorf("message %d is %q", i, msg)
+		}
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(msg[strings.Index(msg, "{"):]), &entry); err != nil {
+			t.Errorf("message %d does not end in the log: %v", i, err)
+		}
+	}
+}
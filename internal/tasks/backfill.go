@@ -0,0 +1,228 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// BackfillPayload identifies the job row and the registered backfill it runs
+type BackfillPayload struct {
+	JobID    string `json:"jobId"`
+	Backfill string `json:"backfill"`
+}
+
+var (
+	backfillLog = logger.New("backfill")
+	// errBackfillStopped is returned by a batch whose job is no longer processing, e.g. cancelled
+	errBackfillStopped = errors.New("backfill job stopped")
+)
+
+// EnqueueBackfill schedules a run of a backfill. A run already queued for the job is left
+// alone, it resumes from the same checkpoint.
+func (c *TaskClient) EnqueueBackfill(ctx context.Context, payload BackfillPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeBackfill, data),
+		asynq.Queue(QueueLow),
+		// Every attempt resumes where the previous one stopped, timeouts included
+		asynq.MaxRetry(RetryMax),
+		asynq.Timeout(TimeoutLong),
+		asynq.TaskID("backfill:"+payload.JobID),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("failed to enqueue backfill: %w", err)
+	}
+	return nil
+}
+
+// HandleBackfill runs a registered backfill from the checkpoint on its job row
+func (h *TaskHandler) HandleBackfill(ctx context.Context, t *asynq.Task) error {
+	var payload BackfillPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid backfill payload: %v: %w", err, asynq.SkipRetry)
+	}
+	backfill, ok := models.LookupBackfill(payload.Backfill)
+	if !ok {
+		return fmt.Errorf("unknown backfill %q: %w", payload.Backfill, asynq.SkipRetry)
+	}
+
+	var job models.Job
+	if err := h.db.WithContext(ctx).Where("id = ? AND is_deleted = false", payload.JobID).First(&job).Error; err != nil {
+		h.logger.Warn("backfill job %s is gone, skipping", payload.JobID)
+		return nil
+	}
+	if job.Status == models.JobStatusCompleted || job.Status == models.JobStatusCancelled {
+		return nil
+	}
+
+	return RunBackfill(ctx, h.db, &job, backfill)
+}
+
+// RunBackfill runs backfill inline from the checkpoint in the job's result. Each batch is
+// transformed and checkpointed in one transaction, so a run killed at any point resumes
+// after the last batch it committed and never transforms a row twice. It stops without
+// error when the job is cancelled, and returns the context's error, leaving the job
+// processing, when ctx is done.
+func RunBackfill(ctx context.Context, db *gorm.DB, job *models.Job, backfill models.Backfill) error {
+	db = db.WithContext(ctx)
+
+	checkpoint := models.BackfillCheckpoint{Backfill: backfill.Name}
+	if len(job.Result) > 0 {
+		if err := json.Unmarshal(job.Result, &checkpoint); err != nil {
+			return fmt.Errorf("invalid backfill checkpoint: %w", err)
+		}
+		if checkpoint.Backfill != backfill.Name {
+			return fmt.Errorf("job %s checkpoints backfill %q, not %q", job.ID, checkpoint.Backfill, backfill.Name)
+		}
+	}
+	batchSize := backfill.BatchSize
+	if batchSize <= 0 {
+		batchSize = models.DefaultBackfillBatchSize
+	}
+
+	// remaining selects the rows after the checkpoint that still need the change
+	remaining := func(after string) *gorm.DB {
+		query := db.Model(backfill.Model).Where("id > ?", after)
+		if backfill.Where != "" {
+			query = query.Where(backfill.Where)
+		}
+		return query
+	}
+
+	var left int64
+	if err := remaining(checkpoint.LastID).Count(&left).Error; err != nil {
+		return fmt.Errorf("failed to count rows to backfill: %w", err)
+	}
+	updates := map[string]interface{}{
+		"status":       models.JobStatusProcessing,
+		"total":        job.Processed + left,
+		"error":        "",
+		"completed_at": nil,
+	}
+	if job.StartedAt == nil {
+		updates["started_at"] = time.Now()
+	}
+	if err := models.UpdateJob(db, job, updates); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var ids []string
+		if err := remaining(checkpoint.LastID).Order("id").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return failBackfill(db, job, fmt.Errorf("failed to select batch: %w", err))
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		next := checkpoint
+		next.LastID = ids[len(ids)-1]
+		next.Batches++
+		encoded, err := json.Marshal(next)
+		if err != nil {
+			return fmt.Errorf("failed to encode backfill checkpoint: %w", err)
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := backfill.Transform(tx, ids); err != nil {
+				return err
+			}
+			// 📍 The checkpoint commits with the batch it records
+			result := tx.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.JobStatusProcessing).Updates(map[string]interface{}{
+				"result":    datatypes.JSON(encoded),
+				"processed": gorm.Expr("processed + ?", len(ids)),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errBackfillStopped
+			}
+			return nil
+		})
+		if errors.Is(err, errBackfillStopped) {
+			backfillLog.Info("backfill %s stopped, job %s is no longer processing", backfill.Name, job.ID)
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return failBackfill(db, job, fmt.Errorf("batch after %q failed: %w", checkpoint.LastID, err))
+		}
+		checkpoint = next
+		job.Result = datatypes.JSON(encoded)
+		job.Processed += int64(len(ids))
+
+		// 🐢 Leave the database room for live traffic between batches
+		if backfill.Throttle > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backfill.Throttle):
+			}
+		}
+	}
+
+	if err := models.UpdateJob(db, job, map[string]interface{}{
+		"status":       models.JobStatusCompleted,
+		"completed_at": time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to complete job: %w", err)
+	}
+	return nil
+}
+
+// failBackfill records err on the job. The checkpoint is kept, a retry resumes from it.
+func failBackfill(db *gorm.DB, job *models.Job, err error) error {
+	models.UpdateJob(db, job, map[string]interface{}{
+		"status":       models.JobStatusFailed,
+		"error":        err.Error(),
+		"completed_at": time.Now(),
+	})
+	return fmt.Errorf("backfill failed: %w", err)
+}
+
+// StartBackfills enqueues every registered backfill that has not completed. Each backfill has
+// one job, shared by all replicas, so restarts resume it instead of starting over.
+func StartBackfills(ctx context.Context, db *gorm.DB, client *TaskClient) error {
+	for _, backfill := range models.Backfills() {
+		checkpoint, err := json.Marshal(models.BackfillCheckpoint{Backfill: backfill.Name})
+		if err != nil {
+			return err
+		}
+		job := models.Job{
+			Base:   models.Base{ID: models.BackfillJobID(backfill.Name)},
+			Type:   models.JobTypeBackfill,
+			Status: models.JobStatusQueued,
+			Result: datatypes.JSON(checkpoint),
+		}
+		if err := db.WithContext(ctx).Where("id = ?", job.ID).FirstOrCreate(&job).Error; err != nil {
+			return fmt.Errorf("failed to create job of backfill %s: %w", backfill.Name, err)
+		}
+		if job.Status == models.JobStatusCompleted || job.Status == models.JobStatusCancelled {
+			continue
+		}
+		if err := client.EnqueueBackfill(ctx, BackfillPayload{JobID: job.ID, Backfill: backfill.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// legacyFiles creates files uploaded before extensions were stored, their extension NULL
+func legacyFiles(t *testing.T, gdb *gorm.DB, names ...string) []models.File {
+	t.Helper()
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	files := make([]models.File, len(names))
+	for i, name := range names {
+		files[i] = models.File{TeamID: team.ID, Path: "p" + strconv.Itoa(i), Name: name, Size: 1, Type: "text/plain"}
+		if err := gdb.Create(&files[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := gdb.Model(&files[i]).UpdateColumn("extension", gorm.Expr("NULL")).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+// fileExtensionBackfill returns the file extension backfill in batches of two without a
+// pause, transform running before each batch's own transform
+func fileExtensionBackfill(t *testing.T, transform func(tx *gorm.DB, ids []string) error) models.Backfill {
+	t.Helper()
+	backfill, ok := models.LookupBackfill(models.FileExtensionBackfill)
+	if !ok {
+		t.Fatal("file extension backfill is not registered")
+	}
+	backfill.BatchSize = 2
+	backfill.Throttle = 0
+	original := backfill.Transform
+	backfill.Transform = func(tx *gorm.DB, ids []string) error {
+		if err := transform(tx, ids); err != nil {
+			return err
+		}
+		return original(tx, ids)
+	}
+	return backfill
+}
+
+func reloadJob(t *testing.T, gdb *gorm.DB, id string) (*models.Job, models.BackfillCheckpoint) {
+	t.Helper()
+	var job models.Job
+	if err := gdb.First(&job, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint models.BackfillCheckpoint
+	if err := json.Unmarshal(job.Result, &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	return &job, checkpoint
+}
+
+func countMissingExtensions(t *testing.T, gdb *gorm.DB) int64 {
+	t.Helper()
+	var missing int64
+	if err := gdb.Model(&models.File{}).Where("extension IS NULL").Count(&missing).Error; err != nil {
+		t.Fatal(err)
+	}
+	return missing
+}
+
+func TestBackfillResumesAfterBeingKilled(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	files := legacyFiles(t, gdb, "a.PDF", "b.png", "c.tar.gz", "README", "e.docx", "f.csv", "g.JPG")
+	job := models.Job{Base: models.Base{ID: models.BackfillJobID(models.FileExtensionBackfill)}, Type: models.JobTypeBackfill, Status: models.JobStatusQueued}
+	if err := gdb.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 💥 The first run is killed while its third batch is in flight
+	ctx, kill := context.WithCancel(context.Background())
+	transformed := map[string]int{}
+	batches := 0
+	backfill := fileExtensionBackfill(t, func(tx *gorm.DB, ids []string) error {
+		batches++
+		if batches == 3 {
+			kill()
+			return context.Canceled
+		}
+		for _, id := range ids {
+			transformed[id]++
+		}
+		return nil
+	})
+	if err := RunBackfill(ctx, gdb, &job, backfill); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	killed, checkpoint := reloadJob(t, gdb, job.ID)
+	if killed.Status != models.JobStatusProcessing || killed.Processed != 4 || killed.Total != 7 {
+		t.Errorf("killed job: status %s processed %d total %d, want PROCESSING 4 of 7", killed.Status, killed.Processed, killed.Total)
+	}
+	if checkpoint.Batches != 2 || checkpoint.LastID == "" {
+		t.Errorf("checkpoint %+v, want two batches", checkpoint)
+	}
+	if missing := countMissingExtensions(t, gdb); missing != 3 {
+		t.Errorf("%d files missing an extension after the kill, want 3", missing)
+	}
+
+	// 📝 A file uploaded meanwhile is dual written and skipped by the backfill
+	uploaded := models.File{TeamID: files[0].TeamID, Path: "new", Name: "new.txt", Size: 1, Type: "text/plain", Extension: "txt"}
+	if err := gdb.Create(&uploaded).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	backfill = fileExtensionBackfill(t, func(tx *gorm.DB, ids []string) error {
+		for _, id := range ids {
+			transformed[id]++
+		}
+		return nil
+	})
+	if err := RunBackfill(context.Background(), gdb, killed, backfill); err != nil {
+		t.Fatal(err)
+	}
+
+	done, checkpoint := reloadJob(t, gdb, job.ID)
+	if done.Status != models.JobStatusCompleted || done.Processed != 7 || done.Total != 7 || done.CompletedAt == nil {
+		t.Errorf("resumed job: status %s processed %d total %d, want COMPLETED 7 of 7", done.Status, done.Processed, done.Total)
+	}
+	if checkpoint.Batches != 4 {
+		t.Errorf("checkpoint %+v, want four batches", checkpoint)
+	}
+	for _, file := range files {
+		if transformed[file.ID] != 1 {
+			t.Errorf("%s transformed %d times, want once", file.Name, transformed[file.ID])
+		}
+	}
+	if transformed[uploaded.ID] != 0 {
+		t.Error("the dual written file was backfilled")
+	}
+
+	want := map[string]string{"a.PDF": "pdf", "b.png": "png", "c.tar.gz": "gz", "README": "", "e.docx": "docx", "f.csv": "csv", "g.JPG": "jpg"}
+	var stored []models.File
+	if err := gdb.Where("id <> ?", uploaded.ID).Find(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range stored {
+		if file.Extension != want[file.Name] {
+			t.Errorf("%s: extension %q, want %q", file.Name, file.Extension, want[file.Name])
+		}
+	}
+	if missing := countMissingExtensions(t, gdb); missing != 0 {
+		t.Errorf("%d files still missing an extension", missing)
+	}
+}
+
+func TestBackfillFailedBatchRollsBackAndRetries(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	legacyFiles(t, gdb, "a.pdf", "b.pdf", "c.pdf")
+	job := models.Job{Type: models.JobTypeBackfill, Status: models.JobStatusQueued}
+	if err := gdb.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	batches := 0
+	failing := fileExtensionBackfill(t, func(tx *gorm.DB, ids []string) error {
+		batches++
+		if batches == 2 {
+			// The batch writes before failing, none of it may stay
+			if err := tx.Model(&models.File{}).Where("id IN ?", ids).UpdateColumn("extension", "bad").Error; err != nil {
+				return err
+			}
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	if err := RunBackfill(context.Background(), gdb, &job, failing); err == nil {
+		t.Fatal("backfill with a failing batch succeeded")
+	}
+	failed, checkpoint := reloadJob(t, gdb, job.ID)
+	if failed.Status != models.JobStatusFailed || failed.Error == "" || failed.Processed != 2 || checkpoint.Batches != 1 {
+		t.Errorf("failed job: status %s error %q processed %d checkpoint %+v", failed.Status, failed.Error, failed.Processed, checkpoint)
+	}
+	if missing := countMissingExtensions(t, gdb); missing != 1 {
+		t.Errorf("%d files missing an extension, want the one of the failed batch", missing)
+	}
+
+	// 🔁 A retry resumes after the last committed batch
+	if err := RunBackfill(context.Background(), gdb, failed, fileExtensionBackfill(t, func(*gorm.DB, []string) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	if done, _ := reloadJob(t, gdb, job.ID); done.Status != models.JobStatusCompleted || done.Processed != 3 || done.Error != "" {
+		t.Errorf("retried job: status %s processed %d error %q", done.Status, done.Processed, done.Error)
+	}
+	if missing := countMissingExtensions(t, gdb); missing != 0 {
+		t.Errorf("%d files still missing an extension", missing)
+	}
+}
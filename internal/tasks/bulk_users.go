@@ -0,0 +1,239 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/utils"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// KindForcedPasswordReset is the notification kind of the emails sent for forced password resets
+const KindForcedPasswordReset = "forced_password_reset"
+
+// bulkUserProgressInterval is how many users are processed between progress updates of the job
+const bulkUserProgressInterval = 50
+
+// errOwnAccount skips the acting admin, who would otherwise lock themselves out
+var errOwnAccount = errors.New("cannot act on your own account")
+
+// BulkUserActionPayload identifies the job row, the super admin acting and the users to act on
+type BulkUserActionPayload struct {
+	JobID     string                `json:"jobId"`
+	ActorID   string                `json:"actorId"`
+	IPAddress string                `json:"ipAddress"`
+	Action    models.BulkUserAction `json:"action"`
+	UserIDs   []string              `json:"userIds"`
+}
+
+// EmailSender queues a notification email, e.g. TaskClient.EnqueueNotificationEmail
+type EmailSender func(ctx context.Context, notification NotificationEmail) error
+
+// EnqueueBulkUserAction schedules a bulk user action
+func (c *TaskClient) EnqueueBulkUserAction(ctx context.Context, payload BulkUserActionPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode bulk user action payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeBulkUserAction, data),
+		asynq.Queue(QueueDefault),
+		asynq.MaxRetry(RetryDefault),
+		asynq.Timeout(TimeoutLong),
+		asynq.TaskID("bulk-user-action:"+payload.JobID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue bulk user action: %w", err)
+	}
+	return nil
+}
+
+// HandleBulkUserAction applies a bulk user action and reports per-user results on the job row
+func (h *TaskHandler) HandleBulkUserAction(ctx context.Context, t *asynq.Task) error {
+	var payload BulkUserActionPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid bulk user action payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	var job models.Job
+	if err := h.db.WithContext(ctx).Where("id = ? AND is_deleted = false", payload.JobID).First(&job).Error; err != nil {
+		h.logger.Warn("bulk user action job %s is gone, skipping", payload.JobID)
+		return nil
+	}
+	if job.Status.Terminal() {
+		return nil
+	}
+
+	_, err := RunBulkUserAction(ctx, h.db, &job, payload, h.taskClient.EnqueueNotificationEmail, config.GetConfig().Server.PublicURL)
+	return err
+}
+
+// RunBulkUserAction applies the action to each user in turn, recording status, progress and
+// the per-user results on the job row. A user failing does not stop the others. Reset emails
+// link to the app at publicURL and are handed to send once the reset is stored.
+func RunBulkUserAction(ctx context.Context, db *gorm.DB, job *models.Job, payload BulkUserActionPayload, send EmailSender, publicURL string) (*models.BulkUserActionResult, error) {
+	db = db.WithContext(ctx)
+
+	if err := models.UpdateJob(db, job, map[string]interface{}{
+		"status":     models.JobStatusProcessing,
+		"total":      int64(len(payload.UserIDs)),
+		"processed":  0,
+		"started_at": time.Now(),
+		"error":      "",
+	}); err != nil {
+		return nil, fmt.Errorf("failed to start job: %w", err)
+	}
+
+	result := &models.BulkUserActionResult{Action: payload.Action, Results: make([]models.BulkUserResult, 0, len(payload.UserIDs))}
+	for i, userID := range payload.UserIDs {
+		outcome := models.BulkUserResult{UserID: userID, Status: models.BulkUserDone}
+
+		user, reset, err := applyBulkUserAction(db, job.ID, payload, userID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			outcome.Status, outcome.Reason = models.BulkUserSkipped, "user not found"
+		case errors.Is(err, errOwnAccount):
+			outcome.Status, outcome.Reason = models.BulkUserSkipped, err.Error()
+		case err != nil:
+			outcome.Status, outcome.Reason = models.BulkUserFailed, err.Error()
+		case reset != nil:
+			// 📧 The password is gone, the user finds out through the email
+			if err := send(ctx, forcedPasswordResetEmail(user, reset, publicURL)); err != nil {
+				outcome.Status, outcome.Reason = models.BulkUserFailed, "password removed but the reset email could not be queued"
+			}
+		}
+
+		switch outcome.Status {
+		case models.BulkUserDone:
+			result.Done++
+		case models.BulkUserSkipped:
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+		result.Results = append(result.Results, outcome)
+
+		if processed := i + 1; processed%bulkUserProgressInterval == 0 {
+			if err := db.Model(job).Update("processed", processed).Error; err != nil {
+				return nil, fmt.Errorf("failed to record progress: %w", err)
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bulk user action result: %w", err)
+	}
+	if err := models.UpdateJob(db, job, map[string]interface{}{
+		"status":       models.JobStatusCompleted,
+		"processed":    int64(len(payload.UserIDs)),
+		"result":       datatypes.JSON(encoded),
+		"completed_at": time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to complete job: %w", err)
+	}
+
+	return result, nil
+}
+
+// applyBulkUserAction applies the action to one user in a transaction and audits it. For forced
+// resets it returns the new reset, whose code still has to be emailed.
+func applyBulkUserAction(db *gorm.DB, jobID string, payload BulkUserActionPayload, userID string) (*models.User, *models.PasswordReset, error) {
+	if userID == payload.ActorID {
+		return nil, nil, errOwnAccount
+	}
+
+	var user models.User
+	var reset *models.PasswordReset
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_deleted = false", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		action := models.AuditSessionsRevoked
+		switch payload.Action {
+		case models.BulkUserDeactivate:
+			action = models.AuditUserDeactivated
+			if err := tx.Model(&user).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		case models.BulkUserForcePasswordReset:
+			// 🔑 An empty hash never matches, the user signs in again after choosing a new password
+			action = models.AuditPasswordResetForced
+			if err := tx.Model(&user).Update("password", "").Error; err != nil {
+				return err
+			}
+			code, err := utils.GenerateRandomString(models.PasswordResetCodeLength)
+			if err != nil {
+				return err
+			}
+			reset = &models.PasswordReset{
+				UserID:    user.ID,
+				Code:      code,
+				CodeHash:  models.HashResetCode(code),
+				ExpiresAt: time.Now().UTC().Add(models.ForcedPasswordResetLifetime),
+				IPAddress: payload.IPAddress,
+			}
+			if err := models.IssuePasswordReset(tx, reset); err != nil {
+				return err
+			}
+		case models.BulkUserRevokeSessions:
+		default:
+			return fmt.Errorf("unknown action %q", payload.Action)
+		}
+
+		// 🚪 Every action signs the user out everywhere
+		revoked, err := models.RevokeUserSessions(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		return models.RecordAudit(tx, models.AuditEntry{
+			ActorID:    payload.ActorID,
+			TeamID:     user.TeamID,
+			Action:     action,
+			TargetType: "user",
+			TargetID:   user.ID,
+			IPAddress:  payload.IPAddress,
+		}, map[string]interface{}{"jobId": jobID, "revoked": revoked})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, reset, nil
+}
+
+// forcedPasswordResetEmail tells the user their password was reset and links to the page
+// choosing a new one
+func forcedPasswordResetEmail(user *models.User, reset *models.PasswordReset, publicURL string) NotificationEmail {
+	resetURL := ResetPasswordURL(publicURL, reset.Code)
+	return NotificationEmail{
+		Kind: KindForcedPasswordReset,
+		Message: mailer.Message{
+			TeamID:  user.TeamID,
+			To:      user.Email,
+			Subject: "Choose a new password",
+			Text: fmt.Sprintf("Hi %s,\n\nAn administrator reset the password of your account and signed it out of all sessions.\n\n"+
+				"Choose a new password at %s\nor with the code %s before %s UTC.",
+				user.FirstName, resetURL, reset.Code, reset.ExpiresAt.UTC().Format("2006-01-02 15:04")),
+		},
+		ActionURL: resetURL,
+	}
+}
+
+// ResetPasswordURL is the page of the app at publicURL where a new password is chosen.
+// It posts the code to /api/v1/auth/password-reset/verify.
+func ResetPasswordURL(publicURL, code string) string {
+	return strings.TrimSuffix(publicURL, "/") + "/reset-password?code=" + url.QueryEscape(code)
+}
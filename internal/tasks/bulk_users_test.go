@@ -0,0 +1,211 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// bulkUserSetup creates a super admin and two members of a team, each member with a live session
+func bulkUserSetup(t *testing.T) (*gorm.DB, *models.User, []*models.User) {
+	t.Helper()
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	admin := testutil.CreateUser(t, gdb, team.ID, models.UserRoleSuperAdmin)
+	members := []*models.User{
+		testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember),
+		testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember),
+	}
+	for _, member := range members {
+		session := models.AuthTransaction{UserID: member.ID, TeamID: team.ID, ExpiresAt: time.Now().UTC().Add(time.Hour)}
+		if err := gdb.Create(&session).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gdb, admin, members
+}
+
+// runBulkUserAction runs action on the users inline, sending emails with send
+func runBulkUserAction(t *testing.T, gdb *gorm.DB, admin *models.User, action models.BulkUserAction, userIDs []string, send EmailSender) (*models.Job, *models.BulkUserActionResult) {
+	t.Helper()
+	job := models.Job{Type: models.JobTypeBulkUserAction, UserID: &admin.ID, Status: models.JobStatusQueued}
+	if err := gdb.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunBulkUserAction(context.Background(), gdb, &job, BulkUserActionPayload{
+		JobID:     job.ID,
+		ActorID:   admin.ID,
+		IPAddress: "203.0.113.7",
+		Action:    action,
+		UserIDs:   userIDs,
+	}, send, "https://app.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.First(&job, "id = ?", job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &job, result
+}
+
+func reloadUser(t *testing.T, gdb *gorm.DB, id string) *models.User {
+	t.Helper()
+	var user models.User
+	if err := gdb.First(&user, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func liveSessions(t *testing.T, gdb *gorm.DB, userID string) int64 {
+	t.Helper()
+	var count int64
+	if err := gdb.Model(&models.AuthTransaction{}).Where("user_id = ? AND is_deleted = false", userID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// audited returns the audit entries of an action on a user
+func audited(t *testing.T, gdb *gorm.DB, action, userID string) []models.AuditEntry {
+	t.Helper()
+	var entries []models.AuditEntry
+	if err := gdb.Where("action = ? AND target_id = ?", action, userID).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func noEmails(t *testing.T) EmailSender {
+	return func(ctx context.Context, notification NotificationEmail) error {
+		t.Errorf("unexpected email to %s", notification.Message.To)
+		return nil
+	}
+}
+
+func TestBulkDeactivate(t *testing.T) {
+	gdb, admin, members := bulkUserSetup(t)
+	unknown := uuid.New().String()
+
+	job, result := runBulkUserAction(t, gdb, admin, models.BulkUserDeactivate,
+		[]string{members[0].ID, admin.ID, unknown, members[1].ID}, noEmails(t))
+
+	if job.Status != models.JobStatusCompleted || job.Total != 4 || job.Processed != 4 || job.CompletedAt == nil {
+		t.Errorf("job %s, %d of %d processed", job.Status, job.Processed, job.Total)
+	}
+	var stored models.BulkUserActionResult
+	if err := json.Unmarshal(job.Result, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Done != 2 || stored.Skipped != 2 || stored.Failed != 0 || len(stored.Results) != 4 {
+		t.Errorf("got result %+v", stored)
+	}
+	want := map[string]string{
+		members[0].ID: models.BulkUserDone,
+		admin.ID:      models.BulkUserSkipped,
+		unknown:       models.BulkUserSkipped,
+		members[1].ID: models.BulkUserDone,
+	}
+	for _, outcome := range result.Results {
+		if outcome.Status != want[outcome.UserID] {
+			t.Errorf("user %s: %s (%s), want %s", outcome.UserID, outcome.Status, outcome.Reason, want[outcome.UserID])
+		}
+	}
+
+	for _, member := range members {
+		if reloadUser(t, gdb, member.ID).IsActive {
+			t.Errorf("member %s still active", member.ID)
+		}
+		if live := liveSessions(t, gdb, member.ID); live != 0 {
+			t.Errorf("member %s has %d live sessions", member.ID, live)
+		}
+		entries := audited(t, gdb, models.AuditUserDeactivated, member.ID)
+		if len(entries) != 1 || entries[0].ActorID != admin.ID || entries[0].IPAddress != "203.0.113.7" ||
+			!strings.Contains(string(entries[0].Metadata), job.ID) {
+			t.Errorf("member %s audited as %+v", member.ID, entries)
+		}
+	}
+	if !reloadUser(t, gdb, admin.ID).IsActive {
+		t.Error("the acting admin deactivated themselves")
+	}
+}
+
+func TestBulkForcePasswordReset(t *testing.T) {
+	gdb, admin, members := bulkUserSetup(t)
+
+	var sent []NotificationEmail
+	_, result := runBulkUserAction(t, gdb, admin, models.BulkUserForcePasswordReset, []string{members[0].ID},
+		func(ctx context.Context, notification NotificationEmail) error {
+			sent = append(sent, notification)
+			return nil
+		})
+	if result.Done != 1 {
+		t.Fatalf("got result %+v", result)
+	}
+
+	user := reloadUser(t, gdb, members[0].ID)
+	if user.Password != "" || !user.IsActive {
+		t.Errorf("password %q, active %v, want no password on an active account", user.Password, user.IsActive)
+	}
+	if live := liveSessions(t, gdb, user.ID); live != 0 {
+		t.Errorf("%d live sessions", live)
+	}
+	if entries := audited(t, gdb, models.AuditPasswordResetForced, user.ID); len(entries) != 1 || entries[0].ActorID != admin.ID {
+		t.Errorf("audited as %+v", entries)
+	}
+
+	// 📧 The email carries the code of the only live reset
+	if len(sent) != 1 || sent[0].Message.To != user.Email || sent[0].Kind != KindForcedPasswordReset {
+		t.Fatalf("sent %+v", sent)
+	}
+	code := strings.TrimPrefix(sent[0].ActionURL, "https://app.example.com/reset-password?code=")
+	if len(code) != models.PasswordResetCodeLength || !strings.Contains(sent[0].Message.Text, code) {
+		t.Errorf("action URL %s, text %q", sent[0].ActionURL, sent[0].Message.Text)
+	}
+	var reset models.PasswordReset
+	if err := gdb.Where("code_hash = ? AND used = ? AND superseded = ?", models.HashResetCode(code), false, false).First(&reset).Error; err != nil {
+		t.Fatalf("no live reset for the emailed code: %v", err)
+	}
+	if reset.UserID != user.ID || time.Until(reset.ExpiresAt) < models.ForcedPasswordResetLifetime-time.Minute {
+		t.Errorf("reset of %s expires at %v", reset.UserID, reset.ExpiresAt)
+	}
+
+	// A reset email that cannot be queued is reported
+	_, result = runBulkUserAction(t, gdb, admin, models.BulkUserForcePasswordReset, []string{members[1].ID},
+		func(ctx context.Context, notification NotificationEmail) error { return errors.New("redis is down") })
+	if result.Failed != 1 || result.Results[0].Status != models.BulkUserFailed {
+		t.Errorf("got result %+v", result)
+	}
+}
+
+func TestBulkRevokeSessions(t *testing.T) {
+	gdb, admin, members := bulkUserSetup(t)
+
+	_, result := runBulkUserAction(t, gdb, admin, models.BulkUserRevokeSessions, []string{members[0].ID}, noEmails(t))
+	if result.Done != 1 {
+		t.Fatalf("got result %+v", result)
+	}
+
+	user := reloadUser(t, gdb, members[0].ID)
+	if !user.IsActive || user.Password == "" {
+		t.Errorf("active %v, password removed %v, want only the sessions revoked", user.IsActive, user.Password == "")
+	}
+	if live := liveSessions(t, gdb, user.ID); live != 0 {
+		t.Errorf("%d live sessions", live)
+	}
+	if live := liveSessions(t, gdb, members[1].ID); live != 1 {
+		t.Errorf("the other member has %d live sessions, want 1", live)
+	}
+	if entries := audited(t, gdb, models.AuditSessionsRevoked, user.ID); len(entries) != 1 || entries[0].ActorID != admin.ID {
+		t.Errorf("audited as %+v", entries)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"be0/internal/config"
 	"be0/internal/utils/logger"
 
 	"github.com/hibiken/asynq"
@@ -29,36 +30,42 @@ func (c *TaskClient) GetClient() *asynq.Client {
 }
 
 // NewTaskClient creates a new TaskClient with the given Redis configuration
-func NewTaskClient(redisAddr, username, password string, db int) *TaskClient {
-	redisOpt := asynq.RedisClientOpt{
-		Addr:     redisAddr,
-		Username: username,
-		Password: password,
-		DB:       db,
+func NewTaskClient(cfg config.RedisConfig) *TaskClient {
+	return &TaskClient{
+		client:       asynq.NewClient(redisClientOpt(cfg)),
+		redisOptions: redisOptions(cfg),
+		redisClient:  NewRedisClient(cfg),
+		logger:       logger.New("TASKS"),
 	}
+}
 
-	redisClient := redis.NewClient(
-		&redis.Options{
-			Addr:     redisAddr,
-			Username: username,
-			Password: password,
-			DB:       db,
-		},
-	)
+// redisClientOpt is the asynq connection for cfg. Taking the struct instead of
+// positional strings keeps username and password from being swapped.
+func redisClientOpt(cfg config.RedisConfig) asynq.RedisClientOpt {
+	return asynq.RedisClientOpt{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+}
 
-	return &TaskClient{
-		client: asynq.NewClient(redisOpt),
-		redisOptions: &redis.Options{
-			Addr:     redisAddr,
-			Username: username,
-			Password: password,
-			DB:       db,
-		},
-		redisClient: redisClient,
-		logger:      logger.New("TASKS"),
+// redisOptions is the go-redis connection for cfg
+func redisOptions(cfg config.RedisConfig) *redis.Options {
+	return &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	}
 }
 
+// NewRedisClient connects to cfg with go-redis. The process builds one and shares it, the
+// client holds its own connection pool.
+func NewRedisClient(cfg config.RedisConfig) *redis.Client {
+	return redis.NewClient(redisOptions(cfg))
+}
+
 // Close closes the underlying asynq client
 func (c *TaskClient) Close() error {
 	return c.client.Close()
@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+
+	"be0/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewRedisClientUsesConfiguredCredentials(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("be0", "s3cret")
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		ok       bool
+	}{
+		{"configured credentials", "be0", "s3cret", true},
+		{"swapped credentials", "s3cret", "be0", false},
+		{"no credentials", "", "", false},
+	}
+	for _, tt := range tests {
+		client := NewRedisClient(config.RedisConfig{Addr: server.Addr(), Username: tt.username, Password: tt.password})
+		err := client.Ping(context.Background()).Err()
+		client.Close()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ping error %v, want success %v", tt.name, err, tt.ok)
+		}
+	}
+
+	options := redisOptions(config.RedisConfig{Addr: "redis:6379", Username: "be0", Password: "s3cret", DB: 3})
+	if options.Addr != "redis:6379" || options.Username != "be0" || options.Password != "s3cret" || options.DB != 3 {
+		t.Errorf("got options %+v", options)
+	}
+}
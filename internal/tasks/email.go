@@ -0,0 +1,211 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/tasks/rate"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+// EnqueueEmail schedules a rendered email for delivery through the mailer
+func (c *TaskClient) EnqueueEmail(ctx context.Context, msg mailer.Message) error {
+	if msg.MessageID == "" {
+		msg.MessageID = uuid.NewString()
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode email payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeEmailSend, payload),
+		asynq.Queue(QueueCritical),
+		asynq.MaxRetry(RetryMax),
+		asynq.Timeout(TimeoutShort),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+// NotificationEmail is an email the app sends on its own behalf, e.g. a team invite
+type NotificationEmail struct {
+	// Kind names the notification, e.g. team_invite
+	Kind    string         `json:"kind"`
+	Message mailer.Message `json:"message"`
+	// ActionURL is the link the email asks the recipient to follow, e.g. the invite accept URL
+	ActionURL string `json:"actionUrl,omitempty"`
+}
+
+// EnqueueNotificationEmail schedules a notification email for delivery through the mailer
+func (c *TaskClient) EnqueueNotificationEmail(ctx context.Context, notification NotificationEmail) error {
+	if notification.Message.MessageID == "" {
+		notification.Message.MessageID = uuid.NewString()
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeNotificationEmail, payload),
+		asynq.Queue(QueueCritical),
+		asynq.MaxRetry(RetryMax),
+		asynq.Timeout(TimeoutShort),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue notification email: %w", err)
+	}
+	return nil
+}
+
+// HandleEmailSend delivers an email, or records it for sandboxed teams
+func (h *TaskHandler) HandleEmailSend(ctx context.Context, t *asynq.Task) error {
+	var msg mailer.Message
+	if err := json.Unmarshal(t.Payload(), &msg); err != nil {
+		return fmt.Errorf("invalid email payload: %v: %w", err, asynq.SkipRetry)
+	}
+	return h.deliverEmail(ctx, msg)
+}
+
+// HandleNotificationEmail delivers a notification email like HandleEmailSend
+func (h *TaskHandler) HandleNotificationEmail(ctx context.Context, t *asynq.Task) error {
+	var notification NotificationEmail
+	if err := json.Unmarshal(t.Payload(), &notification); err != nil {
+		return fmt.Errorf("invalid notification payload: %v: %w", err, asynq.SkipRetry)
+	}
+	return h.deliverEmail(ctx, notification.Message)
+}
+
+// emailRateLimited defers an email while its SMTP server's send rate is used up. It is not a
+// failure, so it does not use up the task's retries, see isTaskFailure.
+type emailRateLimited struct {
+	retryAfter time.Duration
+}
+
+func (e *emailRateLimited) Error() string {
+	return fmt.Sprintf("email send rate exceeded, retrying in %s", e.retryAfter)
+}
+
+// isTaskFailure reports whether a task error counts against the task's retries
+func isTaskFailure(err error) bool {
+	var limited *emailRateLimited
+	return !errors.As(err, &limited)
+}
+
+// taskRetryDelay retries rate limited emails once the rate allows, other tasks back off
+func taskRetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	var limited *emailRateLimited
+	if errors.As(err, &limited) {
+		return limited.retryAfter
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
+
+// deliverEmail sends msg through the mailer and records the delivery in its EmailLog.
+// Refusals with a 5xx reply fail the email for good, and suppress the recipient when it was
+// the address that was refused. Other errors, such as 4xx replies, are retried with backoff.
+// Emails are dropped when no sender is registered.
+func (h *TaskHandler) deliverEmail(ctx context.Context, msg mailer.Message) error {
+	if msg.MessageID == "" {
+		// Enqueued before emails had message IDs
+		msg.MessageID = uuid.NewString()
+	}
+	db := h.db.WithContext(ctx)
+	entry := models.EmailLog{}
+	if err := db.Where(models.EmailLog{MessageID: msg.MessageID}).
+		Attrs(models.EmailLog{TeamID: msg.TeamID, To: msg.To, Subject: msg.Subject, Status: models.EmailStatusQueued}).
+		FirstOrCreate(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record email %s: %w", msg.MessageID, err)
+	}
+
+	sandboxed, err := mailer.Deliver(ctx, h.db, msg, func(ctx context.Context) error {
+		if err := h.allowEmail(ctx); err != nil {
+			return err
+		}
+		entry.Attempts++
+		return nil
+	})
+
+	var limited *emailRateLimited
+	updates := map[string]interface{}{"attempts": entry.Attempts}
+	switch {
+	case err == nil && sandboxed:
+		updates["status"] = models.EmailStatusSandboxed
+	case err == nil:
+		updates["status"] = models.EmailStatusSent
+		updates["sent_at"] = time.Now().UTC()
+	case errors.As(err, &limited):
+		return err
+	case errors.Is(err, mailer.ErrSuppressed):
+		updates["status"] = models.EmailStatusSuppressed
+	case errors.Is(err, mailer.ErrNoSender):
+		h.logger.Warn("no email sender registered, dropping email to %s", msg.To)
+		updates["status"] = models.EmailStatusFailed
+		updates["last_error"] = err.Error()
+	default:
+		updates["smtp_code"] = mailer.SMTPCode(err)
+		updates["last_error"] = err.Error()
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, inTask := asynq.GetMaxRetry(ctx)
+		permanent := mailer.Permanent(err)
+		if permanent || inTask && retried >= maxRetry {
+			updates["status"] = models.EmailStatusFailed
+		} else {
+			updates["status"] = models.EmailStatusRetrying
+		}
+		if mailer.BadAddress(err) {
+			if suppressErr := mailer.Suppress(ctx, h.db, msg.To, mailer.SMTPCode(err), err.Error()); suppressErr != nil {
+				h.logger.Error("failed to suppress %s: %v", suppressErr, msg.To)
+			}
+		}
+		if permanent {
+			err = fmt.Errorf("failed to send email to %s: %v: %w", msg.To, err, asynq.SkipRetry)
+		} else {
+			err = fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+		}
+	}
+
+	if updateErr := db.Model(&entry).Updates(updates).Error; updateErr != nil {
+		h.logger.Error("failed to update email log %s: %v", updateErr, msg.MessageID)
+	}
+	if errors.Is(err, mailer.ErrSuppressed) || errors.Is(err, mailer.ErrNoSender) {
+		return nil
+	}
+	return err
+}
+
+// allowEmail takes a slot of the send rate of the registered SMTP server, shared by every
+// worker. Redis being unavailable lets the email through.
+func (h *TaskHandler) allowEmail(ctx context.Context) error {
+	configID := mailer.SenderConfigID()
+	smtp := config.GetConfig().SMTP
+	if configID == "" || smtp.RateLimit <= 0 || h.taskClient == nil {
+		return nil
+	}
+
+	limiter := rate.NewQueueRateLimiter(h.taskClient.redisClient, rate.QueueConfig{
+		Name: GetEmailQueueName(configID),
+		RateLimit: rate.RateLimit{
+			Window:  time.Duration(smtp.RateWindow) * time.Second,
+			MaxJobs: smtp.RateLimit,
+		},
+	})
+	result, err := limiter.Check(ctx, configID)
+	if err != nil {
+		h.logger.Warn("email rate limiter unavailable, sending anyway: %v", err)
+		return nil
+	}
+	if !result.Allowed {
+		return &emailRateLimited{retryAfter: max(result.RetryAfter, time.Second)}
+	}
+	return nil
+}
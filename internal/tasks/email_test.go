@@ -0,0 +1,138 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"be0/internal/mailer"
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// newEmailTest returns a handler sending through a fake SMTP server, at most limit emails a minute
+func newEmailTest(t *testing.T, limit int) (*TaskHandler, *testutil.SMTPServer, *gorm.DB, *models.Team) {
+	t.Helper()
+	gdb := testutil.NewDB(t)
+	redisClient, _ := testutil.NewRedis(t)
+	server := testutil.NewSMTPServer(t)
+
+	mailer.RegisterSender(mailer.NewSMTPSender(mailer.SMTPConfig{Host: server.Host, Port: server.Port, From: "noreply@be0.test"}))
+	t.Cleanup(func() { mailer.RegisterSender(nil) })
+	cfg := testutil.UseConfig(t)
+	cfg.SMTP.RateLimit, cfg.SMTP.RateWindow = limit, 60
+
+	h := &TaskHandler{db: gdb, logger: logger.New("test"), taskClient: &TaskClient{redisClient: redisClient}}
+	return h, server, gdb, testutil.CreateTeam(t, gdb, "Acme")
+}
+
+func emailLog(t *testing.T, gdb *gorm.DB, messageID string) models.EmailLog {
+	t.Helper()
+	var entry models.EmailLog
+	if err := gdb.Where("message_id = ?", messageID).First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestDeliverEmailRecordsSentEmails(t *testing.T) {
+	h, server, gdb, team := newEmailTest(t, 60)
+
+	msg := mailer.Message{MessageID: "sent-1", TeamID: team.ID, To: "jane@example.com", Subject: "Hi", Text: "Hi"}
+	if err := h.deliverEmail(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	entry := emailLog(t, gdb, "sent-1")
+	if entry.Status != models.EmailStatusSent || entry.Attempts != 1 || entry.SentAt == nil || len(server.Messages()) != 1 {
+		t.Errorf("got %+v with %d messages sent", entry, len(server.Messages()))
+	}
+}
+
+func TestDeliverEmailSuppressesRefusedAddresses(t *testing.T) {
+	h, server, gdb, team := newEmailTest(t, 60)
+	server.Script("RCPT", "550 5.1.1 No such user")
+
+	msg := mailer.Message{MessageID: "bounce-1", TeamID: team.ID, To: "Gone@Example.com", Subject: "Hi", Text: "Hi"}
+	err := h.deliverEmail(context.Background(), msg)
+	if !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("got %v, want SkipRetry", err)
+	}
+	entry := emailLog(t, gdb, "bounce-1")
+	if entry.Status != models.EmailStatusFailed || entry.SMTPCode != 550 || entry.LastError == "" {
+		t.Errorf("got %+v", entry)
+	}
+	var suppression models.EmailSuppression
+	if err := gdb.Where("email = ?", "gone@example.com").First(&suppression).Error; err != nil || suppression.SMTPCode != 550 {
+		t.Fatalf("got suppression %+v and %v", suppression, err)
+	}
+
+	// 🚫 The next email to the address is not sent
+	msg.MessageID = "bounce-2"
+	if err := h.deliverEmail(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if entry := emailLog(t, gdb, "bounce-2"); entry.Status != models.EmailStatusSuppressed || entry.Attempts != 0 {
+		t.Errorf("got %+v", entry)
+	}
+}
+
+func TestDeliverEmailRetriesTransientRefusals(t *testing.T) {
+	h, server, gdb, team := newEmailTest(t, 60)
+	server.Script("DATA", "451 4.7.1 Greylisted")
+
+	msg := mailer.Message{MessageID: "grey-1", TeamID: team.ID, To: "jane@example.com", Subject: "Hi", Text: "Hi"}
+	err := h.deliverEmail(context.Background(), msg)
+	if err == nil || errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("got %v, want a retried error", err)
+	}
+	if entry := emailLog(t, gdb, "grey-1"); entry.Status != models.EmailStatusRetrying || entry.SMTPCode != 451 {
+		t.Errorf("got %+v", entry)
+	}
+	var suppressed int64
+	gdb.Model(&models.EmailSuppression{}).Count(&suppressed)
+	if suppressed != 0 {
+		t.Error("a transient refusal suppressed the address")
+	}
+
+	// The retry goes through and updates the same log
+	if err := h.deliverEmail(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if entry := emailLog(t, gdb, "grey-1"); entry.Status != models.EmailStatusSent || entry.Attempts != 2 {
+		t.Errorf("got %+v", entry)
+	}
+}
+
+func TestDeliverEmailHonoursSendRate(t *testing.T) {
+	h, server, gdb, team := newEmailTest(t, 2)
+
+	var limited error
+	for i, id := range []string{"rate-1", "rate-2", "rate-3"} {
+		msg := mailer.Message{MessageID: id, TeamID: team.ID, To: "jane@example.com", Subject: "Hi", Text: "Hi"}
+		err := h.deliverEmail(context.Background(), msg)
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			limited = err
+		}
+	}
+
+	var rateLimited *emailRateLimited
+	if !errors.As(limited, &rateLimited) || rateLimited.retryAfter <= 0 {
+		t.Fatalf("got %v, want the email deferred", limited)
+	}
+	if isTaskFailure(limited) || taskRetryDelay(0, limited, nil) != rateLimited.retryAfter {
+		t.Error("a deferred email counts as a failure")
+	}
+	if entry := emailLog(t, gdb, "rate-3"); entry.Status != models.EmailStatusQueued || entry.Attempts != 0 {
+		t.Errorf("got %+v", entry)
+	}
+	if len(server.Messages()) != 2 {
+		t.Errorf("server got %d messages, want 2", len(server.Messages()))
+	}
+}
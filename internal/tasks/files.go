@@ -0,0 +1,198 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/extract"
+	"be0/internal/models"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm/clause"
+)
+
+// FileCleanupPayload identifies a file that may no longer be referenced
+type FileCleanupPayload struct {
+	FileID string `json:"fileId"`
+}
+
+// EnqueueFileCleanup schedules removal of a file once nothing references it anymore
+func (c *TaskClient) EnqueueFileCleanup(ctx context.Context, fileID string) error {
+	payload, err := json.Marshal(FileCleanupPayload{FileID: fileID})
+	if err != nil {
+		return fmt.Errorf("failed to encode file cleanup payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeFileCleanup, payload),
+		asynq.Queue(QueueLow),
+		asynq.MaxRetry(RetryDefault),
+		asynq.Timeout(TimeoutShort),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue file cleanup: %w", err)
+	}
+
+	c.logger.Info("enqueued cleanup for file %s", fileID)
+	return nil
+}
+
+// HandleFileCleanup soft-deletes a file and removes it from storage, unless it is
+// the shared default avatar or still used as a profile picture
+func (h *TaskHandler) HandleFileCleanup(ctx context.Context, t *asynq.Task) error {
+	var payload FileCleanupPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid file cleanup payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	if payload.FileID == models.DefaultProfilePictureID {
+		return nil
+	}
+
+	file, err := models.GetFileByID(payload.FileID, h.db.WithContext(ctx))
+	if err != nil {
+		h.logger.Warn("file %s not found for cleanup, skipping", payload.FileID)
+		return nil
+	}
+
+	var references int64
+	if err := h.db.WithContext(ctx).Model(&models.User{}).
+		Where("profile_picture_id = ? AND is_deleted = false", file.ID).
+		Count(&references).Error; err != nil {
+		return fmt.Errorf("failed to count file references: %w", err)
+	}
+	if references > 0 {
+		h.logger.Info("file %s is still referenced by %d users, skipping cleanup", file.ID, references)
+		return nil
+	}
+
+	if deleter := models.GetFileDeleter(); deleter != nil {
+		if err := deleter.DeleteFile(ctx, file.Path); err != nil {
+			return err
+		}
+	}
+
+	if err := h.db.WithContext(ctx).Model(file).Updates(map[string]interface{}{
+		"is_deleted": true,
+		"deleted_at": time.Now().UTC(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to delete file record: %w", err)
+	}
+
+	h.logger.Info("cleaned up file %s", file.ID)
+	return nil
+}
+
+// HandleFileShareCleanup removes share links that expired or ran out of downloads
+func (h *TaskHandler) HandleFileShareCleanup(ctx context.Context, t *asynq.Task) error {
+	result := h.db.WithContext(ctx).
+		Where("(expires_at IS NOT NULL AND expires_at <= ?) OR (max_downloads IS NOT NULL AND download_count >= max_downloads)", time.Now().UTC()).
+		Delete(&models.FileShare{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to clean up file shares: %w", result.Error)
+	}
+
+	h.logger.Info("removed %d expired file shares", result.RowsAffected)
+	return nil
+}
+
+// FileExtractPayload identifies a document to extract text from
+type FileExtractPayload struct {
+	FileID string `json:"fileId"`
+}
+
+// EnqueueFileExtract schedules text extraction for an uploaded document
+func (c *TaskClient) EnqueueFileExtract(ctx context.Context, fileID string) error {
+	payload, err := json.Marshal(FileExtractPayload{FileID: fileID})
+	if err != nil {
+		return fmt.Errorf("failed to encode file extract payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeFileExtract, payload),
+		asynq.Queue(QueueLow),
+		asynq.MaxRetry(RetryDefault),
+		asynq.Timeout(TimeoutMedium),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue file extract: %w", err)
+	}
+	return nil
+}
+
+// HandleFileExtract extracts the text of a document into a FileContent row for search.
+// Extraction errors and oversized files are recorded on the row instead of retried.
+func (h *TaskHandler) HandleFileExtract(ctx context.Context, t *asynq.Task) error {
+	var payload FileExtractPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid file extract payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	file, err := models.GetFileByID(payload.FileID, h.db.WithContext(models.WithExcludedFields(ctx, "signedUrl")))
+	if err != nil {
+		h.logger.Warn("file %s not found for extraction, skipping", payload.FileID)
+		return nil
+	}
+
+	var existing int64
+	if err := h.db.WithContext(ctx).Model(&models.FileContent{}).Where("file_id = ?", file.ID).Count(&existing).Error; err != nil {
+		return fmt.Errorf("failed to check file content: %w", err)
+	}
+	if existing > 0 || !extract.Supported(file.Type) {
+		return nil
+	}
+
+	maxSize := int64(config.GetConfig().Storage.ExtractMaxSize)
+	if file.Size > maxSize {
+		return h.saveFileContent(ctx, file.ID, models.ExtractionStatusSkipped, "", "file exceeds extraction size limit")
+	}
+
+	downloader := models.GetFileDownloader()
+	if downloader == nil {
+		return fmt.Errorf("no file downloader registered")
+	}
+
+	content, err := downloader.DownloadFile(ctx, file.Path, maxSize)
+	if errors.Is(err, models.ErrFileTooLarge) {
+		return h.saveFileContent(ctx, file.ID, models.ExtractionStatusSkipped, "", "file exceeds extraction size limit")
+	}
+	if err != nil {
+		// 🔁 Storage errors are retried, the last attempt records the failure
+		if retried, _ := asynq.GetRetryCount(ctx); retried < RetryDefault {
+			return err
+		}
+		return h.saveFileContent(ctx, file.ID, models.ExtractionStatusFailed, "", err.Error())
+	}
+
+	text, err := extract.Text(ctx, file.Type, content)
+	if err != nil {
+		h.logger.Warn("text extraction failed for file %s: %v", file.ID, err)
+		return h.saveFileContent(ctx, file.ID, models.ExtractionStatusFailed, "", err.Error())
+	}
+
+	// Postgres text columns reject NUL bytes
+	text = strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", ""), "")
+	if len(text) > models.FileContentMaxText {
+		text = strings.ToValidUTF8(text[:models.FileContentMaxText], "")
+	}
+
+	h.logger.Info("extracted %d bytes of text from file %s", len(text), file.ID)
+	return h.saveFileContent(ctx, file.ID, models.ExtractionStatusCompleted, text, "")
+}
+
+// saveFileContent records the extraction outcome, keeping the first result if two tasks race
+func (h *TaskHandler) saveFileContent(ctx context.Context, fileID string, status models.ExtractionStatus, text, reason string) error {
+	err := h.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.FileContent{
+		FileID: fileID,
+		Status: status,
+		Text:   text,
+		Error:  reason,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to save file content: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+)
+
+// recordingDeleter keeps the paths removed from storage
+type recordingDeleter struct {
+	paths []string
+}
+
+func (d *recordingDeleter) DeleteFile(_ context.Context, path string) error {
+	d.paths = append(d.paths, path)
+	return nil
+}
+
+func TestFileCleanupKeepsReferencedPictures(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	deleter := &recordingDeleter{}
+	models.RegisterFileDeleter(deleter)
+	t.Cleanup(func() { models.RegisterFileDeleter(nil) })
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	picture := func(path string) *models.File {
+		file := &models.File{TeamID: team.ID, UserID: &user.ID, Path: path, Name: path, Size: 1, Type: "image/png"}
+		if err := gdb.Create(file).Error; err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	current, replaced := picture("current.png"), picture("replaced.png")
+	if err := gdb.Model(user).Update("profile_picture_id", current.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := func(fileID string) {
+		payload, err := json.Marshal(FileCleanupPayload{FileID: fileID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.HandleFileCleanup(context.Background(), asynq.NewTask(TaskTypeFileCleanup, payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cleanup(models.DefaultProfilePictureID)
+	cleanup(current.ID)
+	cleanup(replaced.ID)
+
+	// 🖼️ Only the picture nobody uses any more leaves storage
+	if len(deleter.paths) != 1 || deleter.paths[0] != "replaced.png" {
+		t.Errorf("deleted %v, want only replaced.png", deleter.paths)
+	}
+	for file, deleted := range map[*models.File]bool{current: false, replaced: true} {
+		var stored models.File
+		if err := gdb.First(&stored, "id = ?", file.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.IsDeleted != deleted {
+			t.Errorf("%s is_deleted %v, want %v", file.Path, stored.IsDeleted, deleted)
+		}
+	}
+
+	// A retried task finds the file gone and stops there
+	cleanup(replaced.ID)
+	if len(deleter.paths) != 1 {
+		t.Errorf("the retry deleted %v again", deleter.paths[1:])
+	}
+}
@@ -8,10 +8,6 @@ import (
 	"gorm.io/gorm"
 )
 
-var (
-	cfg, _ = config.Load()
-)
-
 // TaskHandler handles task processing with improved error handling and logging
 type TaskHandler struct {
 	db             *gorm.DB
@@ -25,7 +21,7 @@ func NewTaskHandler(db *gorm.DB) *TaskHandler {
 	return &TaskHandler{
 		db:             db,
 		logger:         logger.New("task_handler"),
-		taskClient:     NewTaskClient(cfg.Redis.Addr, cfg.Redis.Username, cfg.Redis.Password, cfg.Redis.DB),
+		taskClient:     NewTaskClient(config.GetConfig().Redis),
 		storageHandler: utils.NewStorageHandler(),
 	}
 }
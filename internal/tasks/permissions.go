@@ -0,0 +1,170 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// PermissionBackfillPayload identifies the job row and the permissions to backfill
+type PermissionBackfillPayload struct {
+	JobID         string   `json:"jobId"`
+	PermissionIDs []string `json:"permissionIds"`
+	DryRun        bool     `json:"dryRun"`
+}
+
+// EnqueuePermissionBackfill schedules a backfill of permissions to existing users
+func (c *TaskClient) EnqueuePermissionBackfill(ctx context.Context, payload PermissionBackfillPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode permission backfill payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypePermissionBackfill, data),
+		asynq.Queue(QueueLow),
+		asynq.MaxRetry(RetryMax),
+		asynq.Timeout(TimeoutLong),
+		asynq.TaskID("permission-backfill:"+payload.JobID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue permission backfill: %w", err)
+	}
+	return nil
+}
+
+// HandlePermissionBackfill grants newly seeded permissions to existing users and
+// reports progress on the job row
+func (h *TaskHandler) HandlePermissionBackfill(ctx context.Context, t *asynq.Task) error {
+	var payload PermissionBackfillPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid permission backfill payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	var job models.Job
+	if err := h.db.WithContext(ctx).Where("id = ? AND is_deleted = false", payload.JobID).First(&job).Error; err != nil {
+		h.logger.Warn("permission backfill job %s is gone, skipping", payload.JobID)
+		return nil
+	}
+	if job.Status == models.JobStatusCompleted || job.Status == models.JobStatusCancelled {
+		return nil
+	}
+
+	_, err := RunPermissionBackfill(ctx, h.db, &job, payload.PermissionIDs, payload.DryRun)
+	return err
+}
+
+// RunPermissionBackfill runs a backfill inline, recording status, progress and the
+// per-role result on the job row
+func RunPermissionBackfill(ctx context.Context, db *gorm.DB, job *models.Job, permissionIDs []string, dryRun bool) (*models.PermissionBackfillResult, error) {
+	db = db.WithContext(ctx)
+
+	var total int64
+	if err := db.Model(&models.User{}).Where("is_deleted = false").Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	now := time.Now()
+	if err := models.UpdateJob(db, job, map[string]interface{}{
+		"status":     models.JobStatusProcessing,
+		"total":      total,
+		"processed":  0,
+		"started_at": now,
+		"error":      "",
+	}); err != nil {
+		return nil, fmt.Errorf("failed to start job: %w", err)
+	}
+
+	result, err := models.BackfillPermissions(ctx, db, permissionIDs, dryRun, func(processed int64) error {
+		return db.Model(job).Update("processed", processed).Error
+	})
+
+	completed := time.Now()
+	if err != nil {
+		models.UpdateJob(db, job, map[string]interface{}{
+			"status":       models.JobStatusFailed,
+			"error":        err.Error(),
+			"completed_at": completed,
+		})
+		return nil, fmt.Errorf("permission backfill failed: %w", err)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode backfill result: %w", err)
+	}
+	if err := models.UpdateJob(db, job, map[string]interface{}{
+		"status":       models.JobStatusCompleted,
+		"result":       datatypes.JSON(encoded),
+		"completed_at": completed,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to complete job: %w", err)
+	}
+
+	return result, nil
+}
+
+// PermissionMigrator seeds default permissions and backfills any new ones to existing users
+type PermissionMigrator struct {
+	db     *gorm.DB
+	client *TaskClient
+	logger *logger.Logger
+}
+
+// NewPermissionMigrator creates a new permission migrator
+func NewPermissionMigrator(db *gorm.DB, client *TaskClient) *PermissionMigrator {
+	return &PermissionMigrator{
+		db:     db,
+		client: client,
+		logger: logger.New("permission_migrator"),
+	}
+}
+
+// Run seeds permissions and, when the seed created new ones, enqueues a backfill job.
+// It returns the job, or nil when there is nothing to backfill.
+func (m *PermissionMigrator) Run(ctx context.Context) (*models.Job, error) {
+	created, err := models.SeedPermissionsWithChanges(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(created) == 0 {
+		return nil, nil
+	}
+
+	permissionIDs := make([]string, 0, len(created))
+	for _, perm := range created {
+		permissionIDs = append(permissionIDs, perm.ID)
+	}
+
+	job := models.Job{Type: models.JobTypePermissionBackfill, Status: models.JobStatusQueued}
+	if err := m.db.WithContext(ctx).Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create permission backfill job: %w", err)
+	}
+
+	if err := m.client.EnqueuePermissionBackfill(ctx, PermissionBackfillPayload{
+		JobID:         job.ID,
+		PermissionIDs: permissionIDs,
+	}); err != nil {
+		models.UpdateJob(m.db.WithContext(ctx), &job, map[string]interface{}{
+			"status": models.JobStatusFailed,
+			"error":  err.Error(),
+		})
+		return nil, err
+	}
+
+	m.logger.Info("seeded %d new permissions, backfill job %s enqueued", len(created), job.ID)
+	return &job, nil
+}
+
+// DryRun reports how many users per role would receive the given permissions without
+// writing anything. An empty list checks every permission.
+func (m *PermissionMigrator) DryRun(ctx context.Context, permissionIDs []string) (*models.PermissionBackfillResult, error) {
+	return models.BackfillPermissions(ctx, m.db, permissionIDs, true, nil)
+}
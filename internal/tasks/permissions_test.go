@@ -0,0 +1,132 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"be0/internal/config"
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// unseed removes a permission members get by default, as if it was added after the users
+// were created, and returns its scope
+func unseed(t *testing.T, gdb *gorm.DB) string {
+	t.Helper()
+	permissions, err := models.PermissionsForRole(gdb, models.UserRoleMember)
+	if err != nil || len(permissions) == 0 {
+		t.Fatalf("members have %d permissions: %v", len(permissions), err)
+	}
+	permission := permissions[0]
+	if err := gdb.Where("resource_permission_id = ?", permission.ID).Delete(&models.UserPermission{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Delete(&permission).Error; err != nil {
+		t.Fatal(err)
+	}
+	return permission.Scope
+}
+
+// holders counts the users holding the permission with scope
+func holders(t *testing.T, gdb *gorm.DB, scope string) int64 {
+	t.Helper()
+	var count int64
+	if err := gdb.Model(&models.UserPermission{}).
+		Joins("JOIN resource_permissions ON resource_permissions.id = user_permissions.resource_permission_id").
+		Where("resource_permissions.scope = ? AND user_permissions.is_deleted = false", scope).
+		Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestNewPermissionsAreBackfilledToExistingUsers(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	_, redisServer := testutil.NewRedis(t)
+	client := NewTaskClient(config.RedisConfig{Addr: redisServer.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleAdmin)
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	scope := unseed(t, gdb)
+
+	migrator := NewPermissionMigrator(gdb, client)
+	job, err := migrator.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatal("a newly seeded permission queued no backfill")
+	}
+	if again, err := migrator.Run(context.Background()); err != nil || again != nil {
+		t.Fatalf("a second run queued %+v, %v, want nothing", again, err)
+	}
+
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisServer.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+	queued, err := inspector.ListPendingTasks(QueueLow)
+	if err != nil || len(queued) != 1 || queued[0].Type != TaskTypePermissionBackfill {
+		t.Fatalf("queued %+v, %v, want one backfill", queued, err)
+	}
+	var payload PermissionBackfillPayload
+	if err := json.Unmarshal(queued[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.JobID != job.ID || len(payload.PermissionIDs) != 1 {
+		t.Fatalf("payload %+v, want job %s and the new permission", payload, job.ID)
+	}
+
+	// 🔁 The worker grants the permission, a retry of the task finds the job done
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+	for range 2 {
+		if err := h.HandlePermissionBackfill(context.Background(), asynq.NewTask(TaskTypePermissionBackfill, queued[0].Payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count := holders(t, gdb, scope); count != 2 {
+		t.Errorf("%d users hold %s, want 2", count, scope)
+	}
+
+	var stored models.Job
+	if err := gdb.First(&stored, "id = ?", job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var result models.PermissionBackfillResult
+	if err := json.Unmarshal(stored.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.JobStatusCompleted || stored.Total != 2 || stored.Processed != 2 {
+		t.Errorf("job %s processed %d of %d, want COMPLETED 2 of 2", stored.Status, stored.Processed, stored.Total)
+	}
+	if result.AffectedUsers[models.UserRoleAdmin] != 1 || result.AffectedUsers[models.UserRoleMember] != 1 {
+		t.Errorf("affected users %v, want one admin and one member", result.AffectedUsers)
+	}
+}
+
+func TestPermissionBackfillDryRunWritesNothing(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+	scope := unseed(t, gdb)
+	if err := models.SeedPermissions(gdb); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewPermissionMigrator(gdb, nil).DryRun(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || result.AffectedUsers[models.UserRoleMember] != 2 || result.InsertedPermissions[models.UserRoleMember] != 0 {
+		t.Errorf("dry run got %+v, want 2 affected members and nothing inserted", result)
+	}
+	if count := holders(t, gdb, scope); count != 0 {
+		t.Errorf("the dry run granted %s to %d users", scope, count)
+	}
+}
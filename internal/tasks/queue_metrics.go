@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/metrics"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+)
+
+// WatchQueueMetrics reports the size of every task queue on /metrics every interval,
+// until ctx is done
+func WatchQueueMetrics(ctx context.Context, cfg config.RedisConfig, interval time.Duration) {
+	log := logger.New("queue_metrics")
+	inspector := asynq.NewInspector(redisClientOpt(cfg))
+	defer inspector.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := reportQueueSizes(inspector); err != nil {
+			log.Warn("Failed to read task queue sizes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportQueueSizes sets the queue size gauges from the inspector
+func reportQueueSizes(inspector *asynq.Inspector) error {
+	queues, err := inspector.Queues()
+	if err != nil {
+		return err
+	}
+	for _, queue := range queues {
+		info, err := inspector.GetQueueInfo(queue)
+		if err != nil {
+			return err
+		}
+		metrics.SetTaskQueueSize(queue, "pending", info.Pending)
+		metrics.SetTaskQueueSize(queue, "active", info.Active)
+		metrics.SetTaskQueueSize(queue, "scheduled", info.Scheduled)
+		metrics.SetTaskQueueSize(queue, "retry", info.Retry)
+		metrics.SetTaskQueueSize(queue, "archived", info.Archived)
+	}
+	return nil
+}
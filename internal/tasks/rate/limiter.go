@@ -2,8 +2,9 @@ package rate
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -19,42 +20,112 @@ type QueueConfig struct {
 	RateLimit RateLimit
 }
 
-type QueueRateLimiter struct {
+// Result is the outcome of a rate limited attempt
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next attempt is allowed, zero when this one was
+	RetryAfter time.Duration
+}
+
+// slidingWindowScript drops the attempts that left the window, then records this
+// attempt if the window has room. Running it as one script keeps concurrent
+// attempts from all seeing the same count.
+// KEYS[1] key, ARGV[1] now (ms), ARGV[2] window (ms), ARGV[3] limit, ARGV[4] member
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, count + 1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local retry = window
+if oldest[2] then
+	retry = tonumber(oldest[2]) + window - now
+end
+return {0, count, retry}
+`)
+
+// SlidingWindow allows a number of attempts per key within any window of time,
+// counting them in Redis so every process shares the limit
+type SlidingWindow struct {
 	redis  *redis.Client
-	config QueueConfig
+	prefix string
+	window time.Duration
+	now    func() time.Time
 }
 
-func NewQueueRateLimiter(redis *redis.Client, config QueueConfig) *QueueRateLimiter {
-	return &QueueRateLimiter{
-		redis:  redis,
-		config: config,
-	}
+// NewSlidingWindow creates a limiter whose keys are stored under prefix
+func NewSlidingWindow(redis *redis.Client, prefix string, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{redis: redis, prefix: prefix, window: window, now: time.Now}
 }
 
-func (qrl *QueueRateLimiter) Allow(ctx context.Context, identifier string) (bool, error) {
-	key := fmt.Sprintf("queue_rate_limit:%s:%s", qrl.config.Name, identifier)
+// WithClock replaces the clock the window is measured with, for tests
+func (s *SlidingWindow) WithClock(now func() time.Time) *SlidingWindow {
+	s.now = now
+	return s
+}
 
-	pipe := qrl.redis.Pipeline()
-	now := time.Now().Unix()
-	windowStart := now - int64(qrl.config.RateLimit.Window.Seconds())
+// Allow records an attempt for key if fewer than limit were made within the window
+func (s *SlidingWindow) Allow(ctx context.Context, key string, limit int) (Result, error) {
+	member, err := attemptID()
+	if err != nil {
+		return Result{}, err
+	}
+	now := s.now().UnixMilli()
+	values, err := slidingWindowScript.Run(ctx, s.redis,
+		[]string{s.prefix + ":" + key},
+		now, s.window.Milliseconds(), limit, fmt.Sprintf("%d-%s", now, member),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("redis rate limit script error: %w", err)
+	}
 
-	// Remove old entries
-	pipe.ZRemRangeByScore(ctx, key, "0", strconv.FormatInt(windowStart, 10))
+	result := Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  max(limit-int(values[1]), 0),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+	return result, nil
+}
 
-	// Count current window
-	pipe.ZCard(ctx, key)
+// attemptID tells apart attempts made in the same millisecond
+func attemptID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
 
-	// Add new entry
-	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now), Member: now})
+type QueueRateLimiter struct {
+	limiter *SlidingWindow
+	config  QueueConfig
+}
 
-	// Set expiration
-	pipe.Expire(ctx, key, qrl.config.RateLimit.Window*2)
+func NewQueueRateLimiter(redis *redis.Client, config QueueConfig) *QueueRateLimiter {
+	return &QueueRateLimiter{
+		limiter: NewSlidingWindow(redis, "queue_rate_limit:"+config.Name, config.RateLimit.Window),
+		config:  config,
+	}
+}
 
-	results, err := pipe.Exec(ctx)
+func (qrl *QueueRateLimiter) Allow(ctx context.Context, identifier string) (bool, error) {
+	result, err := qrl.Check(ctx, identifier)
 	if err != nil {
-		return false, fmt.Errorf("redis pipeline error: %w", err)
+		return false, err
 	}
+	return result.Allowed, nil
+}
 
-	count := results[1].(*redis.IntCmd).Val()
-	return count <= int64(qrl.config.RateLimit.MaxJobs), nil
+// Check is Allow, also reporting how long until the next job is allowed
+func (qrl *QueueRateLimiter) Check(ctx context.Context, identifier string) (Result, error) {
+	return qrl.limiter.Allow(ctx, identifier, qrl.config.RateLimit.MaxJobs)
 }
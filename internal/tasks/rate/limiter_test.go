@@ -0,0 +1,78 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+)
+
+func TestSlidingWindowExpiresAttempts(t *testing.T) {
+	client, _ := testutil.NewRedis(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewSlidingWindow(client, "test", time.Minute).WithClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "user:1", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("attempt %d: %+v", i+1, result)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	// 🚫 The window is full until the first attempt leaves it
+	result, err := limiter.Allow(ctx, "user:1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 30*time.Second {
+		t.Fatalf("fourth attempt: %+v, want denied for 30s", result)
+	}
+	if other, _ := limiter.Allow(ctx, "user:2", 3); !other.Allowed {
+		t.Error("another key shares the window")
+	}
+
+	now = now.Add(30 * time.Second)
+	if result, _ := limiter.Allow(ctx, "user:1", 3); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("attempt after the oldest expired: %+v", result)
+	}
+}
+
+func TestSlidingWindowCountsConcurrentAttempts(t *testing.T) {
+	client, _ := testutil.NewRedis(t)
+	limiter := NewSlidingWindow(client, "test", time.Minute)
+
+	const attempts, limit = 50, 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := limiter.Allow(context.Background(), "ip:127.0.0.1", limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit {
+		t.Errorf("allowed %d concurrent attempts, want %d", allowed, limit)
+	}
+}
@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"be0/internal/models"
+
+	"github.com/hibiken/asynq"
+)
+
+// HandleSandboxCleanup removes sandbox emails and webhooks older than the retention period
+func (h *TaskHandler) HandleSandboxCleanup(ctx context.Context, t *asynq.Task) error {
+	cutoff := time.Now().UTC().Add(-models.SandboxRetention)
+
+	for _, model := range []interface{}{&models.SandboxEmail{}, &models.SandboxWebhook{}} {
+		result := h.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(model)
+		if result.Error != nil {
+			return fmt.Errorf("failed to clean up sandbox records: %w", result.Error)
+		}
+		h.logger.Info("removed %d expired sandbox records (%T)", result.RowsAffected, model)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+)
+
+func TestSandboxedTeamsRecordWebhookDeliveries(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("a sandboxed team's webhook was sent to %s", r.URL)
+	}))
+	t.Cleanup(server.Close)
+
+	team := testutil.CreateTeam(t, gdb, "Sandbox")
+	if err := gdb.Model(team).Update("sandbox_mode", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	subscription := &models.WebhookSubscription{TeamID: team.ID, URL: server.URL, Secret: "whsec", Events: datatypes.JSONSlice[string]{"users.created"}, Active: true}
+	if err := gdb.Create(subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(WebhookDeliveryPayload{SubscriptionID: subscription.ID, Event: "users.created", Body: []byte(`{"event":"users.created"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.HandleWebhookDelivery(context.Background(), asynq.NewTask(TaskTypeWebhookDelivery, payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	var recorded []models.SandboxWebhook
+	if err := gdb.Find(&recorded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || recorded[0].SubscriptionID != subscription.ID || recorded[0].URL != server.URL || string(recorded[0].Payload) != `{"event":"users.created"}` {
+		t.Errorf("recorded %+v, want the delivery", recorded)
+	}
+}
+
+func TestSandboxCleanupKeepsTheRetentionPeriod(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+	team := testutil.CreateTeam(t, gdb, "Sandbox")
+
+	expired := models.NewTimestamp(time.Now().UTC().Add(-models.SandboxRetention - time.Hour))
+	for _, record := range []interface{}{
+		&models.SandboxEmail{Base: models.Base{CreatedAt: expired}, TeamID: team.ID, To: "old@example.com", Subject: "Old"},
+		&models.SandboxEmail{TeamID: team.ID, To: "new@example.com", Subject: "New"},
+		&models.SandboxWebhook{Base: models.Base{CreatedAt: expired}, TeamID: team.ID, SubscriptionID: team.ID, URL: "https://hooks.example.com", Event: "users.created", Payload: []byte(`{}`)},
+	} {
+		if err := gdb.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.HandleSandboxCleanup(context.Background(), asynq.NewTask(TaskTypeSandboxCleanup, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var emails []models.SandboxEmail
+	var webhooks int64
+	gdb.Find(&emails)
+	gdb.Model(&models.SandboxWebhook{}).Count(&webhooks)
+	if len(emails) != 1 || emails[0].Subject != "New" || webhooks != 0 {
+		t.Errorf("kept emails %+v and %d webhooks, want the new email only", emails, webhooks)
+	}
+}
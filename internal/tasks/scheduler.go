@@ -3,6 +3,7 @@ package tasks
 import (
 	"fmt"
 
+	"be0/internal/config"
 	"be0/internal/utils/logger"
 
 	"github.com/hibiken/asynq"
@@ -15,16 +16,8 @@ type Scheduler struct {
 }
 
 // NewScheduler creates a new task scheduler
-func NewScheduler(redisAddr, username, password string, db int, logger *logger.Logger) *Scheduler {
-	scheduler := asynq.NewScheduler(
-		asynq.RedisClientOpt{
-			Addr:     redisAddr,
-			Username: username,
-			Password: password,
-			DB:       db,
-		},
-		&asynq.SchedulerOpts{},
-	)
+func NewScheduler(redisCfg config.RedisConfig, logger *logger.Logger) *Scheduler {
+	scheduler := asynq.NewScheduler(redisClientOpt(redisCfg), &asynq.SchedulerOpts{})
 
 	return &Scheduler{
 		scheduler: scheduler,
@@ -50,6 +43,27 @@ func (s *Scheduler) Stop() {
 
 // registerTasks registers all periodic tasks
 func (s *Scheduler) registerTasks() error {
+	if err := s.RegisterCustomTask("@daily", TaskTypeSandboxCleanup, nil, asynq.Queue(QueueLow)); err != nil {
+		return err
+	}
+
+	if err := s.RegisterCustomTask("@hourly", TaskTypeFileShareCleanup, nil, asynq.Queue(QueueLow)); err != nil {
+		return err
+	}
+
+	if err := s.RegisterCustomTask("@hourly", TaskTypeSessionCleanup, nil, asynq.Queue(QueueLow)); err != nil {
+		return err
+	}
+
+	if err := s.RegisterCustomTask("@daily", TaskTypeAPIRequestLogCleanup, nil, asynq.Queue(QueueLow)); err != nil {
+		return err
+	}
+
+	// 📤 Audit logs are pushed to the configured SIEM exports
+	if err := s.RegisterCustomTask("@every 5m", TaskTypeAuditExport, nil, auditExportOptions...); err != nil {
+		return err
+	}
+
 	s.logger.Info("registered all periodic tasks")
 	return nil
 }
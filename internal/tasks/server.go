@@ -1,9 +1,12 @@
 package tasks
 
 import (
+	"be0/internal/config"
+	"be0/internal/plugins"
 	"be0/internal/utils/logger"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -13,28 +16,32 @@ type Server struct {
 	server  *asynq.Server
 	handler *TaskHandler
 	logger  *logger.Logger
+	config  config.WorkerConfig
 }
 
 // NewServer creates a new task processing server
-func NewServer(redisAddr, username, password string, db int, handler *TaskHandler, logger *logger.Logger) *Server {
+func NewServer(cfg config.WorkerConfig, redisCfg config.RedisConfig, handler *TaskHandler, logger *logger.Logger) *Server {
+	// ⚠️ Tasks enqueued to a queue without a weight are never processed
+	for _, queue := range []string{QueueCritical, QueueDefault, QueueLow} {
+		if _, ok := cfg.QueueWeights[queue]; !ok {
+			logger.Warn("Queue %s has no weight in QUEUE_WEIGHTS, its tasks will not be processed", queue)
+		}
+	}
+
 	server := asynq.NewServer(
-		asynq.RedisClientOpt{
-			Addr:     redisAddr,
-			Username: username,
-			Password: password,
-			DB:       db,
-		},
+		redisClientOpt(redisCfg),
 		asynq.Config{
 			// Specify how many concurrent workers to use
-			Concurrency: 10,
-			// Optionally specify multiple queues with different priorities
-			Queues: map[string]int{
-				QueueCritical: 6, // High priority
-				QueueDefault:  3, // Medium priority
-				QueueLow:      1, // Low priority
-			},
-			// Enable strict priority, meaning higher priority queues are processed first
-			StrictPriority: true,
+			Concurrency: cfg.Concurrency,
+			// Queues with their priorities, QUEUE_WEIGHTS overrides the defaults
+			Queues: cfg.QueueWeights,
+			// With strict priority higher weighted queues are emptied first
+			StrictPriority: cfg.StrictPriority,
+			// How long in-flight tasks get to finish on shutdown before they are requeued
+			ShutdownTimeout: time.Duration(cfg.ShutdownTimeout) * time.Second,
+			// Rate limited emails wait for the rate without using up their retries
+			IsFailure:      isTaskFailure,
+			RetryDelayFunc: taskRetryDelay,
 		},
 	)
 
@@ -42,6 +49,7 @@ func NewServer(redisAddr, username, password string, db int, handler *TaskHandle
 		server:  server,
 		handler: handler,
 		logger:  logger,
+		config:  cfg,
 	}
 }
 
@@ -50,13 +58,23 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := asynq.NewServeMux()
 
 	// Register task handlers
-	// mux.HandleFunc(TASKTYPE, s.handler.HANDLER_NAME)
+	mux.HandleFunc(TaskTypeFileCleanup, s.handler.HandleFileCleanup)
+	mux.HandleFunc(TaskTypeFileShareCleanup, s.handler.HandleFileShareCleanup)
+	mux.HandleFunc(TaskTypeFileExtract, s.handler.HandleFileExtract)
+	mux.HandleFunc(TaskTypeEmailSend, s.handler.HandleEmailSend)
+	mux.HandleFunc(TaskTypeNotificationEmail, s.handler.HandleNotificationEmail)
+	mux.HandleFunc(TaskTypeWebhookDelivery, s.handler.HandleWebhookDelivery)
+	mux.HandleFunc(TaskTypeSandboxCleanup, s.handler.HandleSandboxCleanup)
+	mux.HandleFunc(TaskTypeSessionCleanup, s.handler.HandleSessionCleanup)
+	mux.HandleFunc(TaskTypePermissionBackfill, s.handler.HandlePermissionBackfill)
+	mux.HandleFunc(TaskTypeBackfill, s.handler.HandleBackfill)
+	mux.HandleFunc(TaskTypeBulkUserAction, s.handler.HandleBulkUserAction)
+	mux.HandleFunc(TaskTypeAuditExport, s.handler.HandleAuditExport)
+	mux.HandleFunc(TaskTypeAPIRequestLogCleanup, s.handler.HandleAPIRequestLogCleanup)
+	plugins.RegisterTasks(mux)
 
-	s.logger.Info("starting task processing server concurrency %d queues %v", 10, map[string]int{
-		QueueCritical: 6,
-		QueueDefault:  3,
-		QueueLow:      1,
-	})
+	s.logger.Info("starting task processing server concurrency %d queues %v strict %v",
+		s.config.Concurrency, s.config.QueueWeights, s.config.StrictPriority)
 
 	if err := s.server.Start(mux); err != nil {
 		return fmt.Errorf("failed to start task server: %w", err)
@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"be0/internal/models"
+
+	"github.com/hibiken/asynq"
+)
+
+// HandleSessionCleanup prunes auth transactions that expired more than the retention period ago.
+// Recently expired sessions are kept so new-device detection still knows the user's devices.
+func (h *TaskHandler) HandleSessionCleanup(ctx context.Context, t *asynq.Task) error {
+	cutoff := time.Now().UTC().Add(-models.SessionRetention)
+
+	result := h.db.WithContext(ctx).Where("expires_at < ?", cutoff).Delete(&models.AuthTransaction{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to clean up sessions: %w", result.Error)
+	}
+
+	h.logger.Info("removed %d expired sessions", result.RowsAffected)
+	return nil
+}
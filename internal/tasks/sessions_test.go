@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestSessionCleanupKeepsRecentlyExpiredSessions(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	user := testutil.CreateUser(t, gdb, team.ID, models.UserRoleMember)
+
+	now := time.Now().UTC()
+	expiries := map[string]time.Time{
+		"active":           now.Add(time.Hour),
+		"recently expired": now.Add(-time.Hour),
+		"expired long ago": now.Add(-models.SessionRetention - time.Hour),
+	}
+	for device, expiresAt := range expiries {
+		if err := gdb.Create(&models.AuthTransaction{UserID: user.ID, TeamID: team.ID, DeviceID: device, ExpiresAt: expiresAt}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.HandleSessionCleanup(context.Background(), asynq.NewTask(TaskTypeSessionCleanup, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var kept []string
+	if err := gdb.Model(&models.AuthTransaction{}).Order("device_id").Pluck("device_id", &kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0] != "active" || kept[1] != "recently expired" {
+		t.Errorf("kept %v, want the active and recently expired sessions", kept)
+	}
+}
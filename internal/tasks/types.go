@@ -6,6 +6,39 @@ import "time"
 const (
 	// Queue related tasks
 	TaskTypeQueueConfig = "queue:config"
+
+	// File related tasks
+	TaskTypeFileCleanup      = "file:cleanup"
+	TaskTypeFileShareCleanup = "file:share_cleanup"
+	TaskTypeFileExtract      = "file:extract"
+
+	// Email related tasks
+	TaskTypeEmailSend         = "email:send"
+	TaskTypeNotificationEmail = "notification:email"
+
+	// Webhook related tasks
+	TaskTypeWebhookDelivery = "webhook:deliver"
+
+	// Sandbox related tasks
+	TaskTypeSandboxCleanup = "sandbox:cleanup"
+
+	// Auth related tasks
+	TaskTypeSessionCleanup = "auth:session_cleanup"
+
+	// Permission related tasks
+	TaskTypePermissionBackfill = "permissions:backfill"
+
+	// Migration related tasks
+	TaskTypeBackfill = "migrations:backfill"
+
+	// User related tasks
+	TaskTypeBulkUserAction = "users:bulk_action"
+
+	// Audit related tasks
+	TaskTypeAuditExport = "audit:export"
+
+	// API key related tasks
+	TaskTypeAPIRequestLogCleanup = "api_keys:request_log_cleanup"
 )
 
 // Task Queues
@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"be0/internal/models"
+	"be0/internal/utils/crypto"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+)
+
+// Webhook delivery headers
+const (
+	HeaderWebhookEvent     = "X-Be0-Event"
+	HeaderWebhookSignature = "X-Be0-Signature"
+	HeaderWebhookDelivery  = "X-Be0-Delivery"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookDeliveryPayload is one event body to POST to one subscription
+type WebhookDeliveryPayload struct {
+	SubscriptionID string          `json:"subscriptionId"`
+	Event          string          `json:"event"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// EnqueueWebhookDelivery schedules a signed POST of body to a subscription
+func (c *TaskClient) EnqueueWebhookDelivery(ctx context.Context, subscriptionID, event string, body []byte) error {
+	payload, err := json.Marshal(WebhookDeliveryPayload{SubscriptionID: subscriptionID, Event: event, Body: body})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook delivery payload: %w", err)
+	}
+
+	_, err = c.client.EnqueueContext(ctx, asynq.NewTask(TaskTypeWebhookDelivery, payload),
+		asynq.Queue(QueueDefault),
+		asynq.MaxRetry(RetryMax),
+		asynq.Timeout(TimeoutShort),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// HandleWebhookDelivery POSTs an event to the subscriber, signing the body with the subscription secret.
+// Non-2xx responses are retried.
+func (h *TaskHandler) HandleWebhookDelivery(ctx context.Context, t *asynq.Task) error {
+	var payload WebhookDeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("invalid webhook delivery payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	var subscription models.WebhookSubscription
+	if err := h.db.WithContext(ctx).
+		Where("id = ? AND active = true AND is_deleted = false", payload.SubscriptionID).
+		First(&subscription).Error; err != nil {
+		h.logger.Warn("webhook subscription %s is gone, dropping %s", payload.SubscriptionID, payload.Event)
+		return nil
+	}
+
+	// 🧪 Sandboxed teams get the delivery recorded instead of sent
+	team, err := models.GetTeamByID(subscription.TeamID, h.db.WithContext(ctx))
+	if err != nil {
+		h.logger.Warn("team %s of subscription %s is gone, dropping %s", subscription.TeamID, subscription.ID, payload.Event)
+		return nil
+	}
+	if team.SandboxMode {
+		return h.db.WithContext(ctx).Create(&models.SandboxWebhook{
+			TeamID:         team.ID,
+			SubscriptionID: subscription.ID,
+			URL:            subscription.URL,
+			Event:          payload.Event,
+			Payload:        datatypes.JSON(payload.Body),
+		}).Error
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload.Body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v: %w", err, asynq.SkipRetry)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, payload.Event)
+	req.Header.Set(HeaderWebhookSignature, "sha256="+crypto.ComputeWebhookSignature(payload.Body, subscription.Secret))
+	if id, ok := asynq.GetTaskID(ctx); ok {
+		req.Header.Set(HeaderWebhookDelivery, id)
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook delivery to %s failed: %w", subscription.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook delivery to %s returned %d", subscription.URL, resp.StatusCode)
+	}
+
+	h.logger.Info("delivered %s to subscription %s", payload.Event, subscription.ID)
+	return nil
+}
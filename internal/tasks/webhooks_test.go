@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be0/internal/models"
+	"be0/internal/testutil"
+	"be0/internal/utils/crypto"
+	"be0/internal/utils/logger"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+)
+
+func TestWebhookDeliveryIsSignedAndRetriedOnErrors(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	h := &TaskHandler{db: gdb, logger: logger.New("test")}
+
+	status := http.StatusNoContent
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	team := testutil.CreateTeam(t, gdb, "Acme")
+	subscription := &models.WebhookSubscription{TeamID: team.ID, URL: server.URL, Secret: "whsec-test", Events: datatypes.JSONSlice[string]{"users.created"}, Active: true}
+	if err := gdb.Create(subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"event":"users.created","data":{"email":"grace@example.com"}}`)
+	deliver := func() error {
+		payload, err := json.Marshal(WebhookDeliveryPayload{SubscriptionID: subscription.ID, Event: "users.created", Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = nil
+		return h.HandleWebhookDelivery(context.Background(), asynq.NewTask(TaskTypeWebhookDelivery, payload))
+	}
+
+	if err := deliver(); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil {
+		t.Fatal("nothing was delivered")
+	}
+	// 🔏 Subscribers check the body against the secret they were shown once
+	if got, want := received.Header.Get(HeaderWebhookSignature), "sha256="+crypto.ComputeWebhookSignature(receivedBody, "whsec-test"); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if string(receivedBody) != string(body) || received.Header.Get(HeaderWebhookEvent) != "users.created" {
+		t.Errorf("delivered %s as %q", receivedBody, received.Header.Get(HeaderWebhookEvent))
+	}
+
+	status = http.StatusBadGateway
+	if err := deliver(); err == nil {
+		t.Error("a 502 from the subscriber was not retried")
+	}
+
+	if err := gdb.Model(subscription).Update("active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(); err != nil || received != nil {
+		t.Errorf("a disabled subscription got a delivery, err %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// Package containers runs Postgres and Redis in Docker for integration tests. The
+// containers start once per test binary, when a test first asks for one, and Run stops
+// them after the tests. Every test gets a migrated schema and a Redis database of its own
+// so tests can run in parallel. Tests skip when Docker isn't available or with -short.
+//
+// A package using it calls Run from its TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(containers.Run(m))
+//	}
+package containers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/db"
+	"be0/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	redisImage    = "redis:7-alpine"
+
+	// startTimeout bounds pulling the images and starting both containers
+	startTimeout = 3 * time.Minute
+)
+
+var (
+	startOnce sync.Once
+	startErr  error
+
+	postgresContainer *tcpostgres.PostgresContainer
+	redisContainer    *tcredis.RedisContainer
+
+	// admin is connected to the public schema and creates and drops the test schemas
+	admin       *gorm.DB
+	postgresDSN string
+	redisAddr   string
+
+	// redisDBs hands out the logical Redis databases, 0 is left to whatever runs unscoped
+	redisDBs chan int
+)
+
+// Run runs the tests and stops the containers if any test started them
+func Run(m *testing.M) int {
+	code := m.Run()
+	stop()
+	return code
+}
+
+// Postgres returns a connection to a schema private to the test, migrated like on
+// startup and with the default permissions seeded. The schema is dropped when the test ends.
+func Postgres(t testing.TB) *gorm.DB {
+	t.Helper()
+	gdb := Schema(t)
+	if err := db.Migrate(gdb, time.Minute); err != nil {
+		t.Fatalf("failed to migrate test schema: %v", err)
+	}
+	if err := models.SeedPermissions(gdb); err != nil {
+		t.Fatalf("failed to seed permissions: %v", err)
+	}
+	return gdb
+}
+
+// Schema returns a connection to an empty schema private to the test, for tests of the
+// migrations themselves. The schema is dropped when the test ends.
+func Schema(t testing.TB) *gorm.DB {
+	t.Helper()
+	require(t)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:16]
+	if err := admin.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+
+	gdb, err := open(schema)
+	if err != nil {
+		t.Fatalf("failed to connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema))
+	})
+	return gdb
+}
+
+// open connects to a schema of the shared Postgres
+func open(schema string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(postgresDSN+"&search_path="+url.QueryEscape(schema)), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+		TranslateError:                           true,
+		NowFunc:                                  func() time.Time { return time.Now().UTC() },
+	})
+}
+
+// Redis returns the configuration of a Redis database private to the test, flushed when
+// the test ends. Tests build clients from it like the application does.
+func Redis(t testing.TB) config.RedisConfig {
+	t.Helper()
+	require(t)
+
+	var index int
+	select {
+	case index = <-redisDBs:
+	case <-time.After(time.Minute):
+		t.Fatal("timed out waiting for a free Redis database")
+	}
+
+	cfg := config.RedisConfig{Addr: redisAddr, DB: index}
+	t.Cleanup(func() {
+		client := redis.NewClient(&redis.Options{Addr: cfg.Addr, DB: cfg.DB})
+		client.FlushDB(context.Background())
+		client.Close()
+		redisDBs <- index
+	})
+	return cfg
+}
+
+// require starts the containers on first use and skips the test when they can't run
+func require(t testing.TB) {
+	t.Helper()
+	startOnce.Do(func() { startErr = start() })
+	if startErr != nil {
+		t.Skipf("integration test needs Docker: %v", startErr)
+	}
+}
+
+func start() (err error) {
+	if testing.Short() {
+		return errors.New("skipped in -short mode")
+	}
+	if err := dockerHealthy(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	// Stop whatever started if a later step fails, Run only stops a complete setup
+	defer func() {
+		if err != nil {
+			stop()
+		}
+	}()
+
+	postgresContainer, err = tcpostgres.Run(ctx, postgresImage,
+		tcpostgres.WithDatabase("be0"),
+		tcpostgres.WithUsername("be0"),
+		tcpostgres.WithPassword("be0"),
+		// The server restarts once after initdb, it's ready on the second message
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(startTimeout)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start Postgres: %w", err)
+	}
+	dsn, err := postgresContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return fmt.Errorf("failed to get the Postgres address: %w", err)
+	}
+	// Pin sessions to UTC like db.DSN
+	postgresDSN = dsn + "&TimeZone=UTC"
+
+	if admin, err = open("public"); err != nil {
+		return fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+
+	redisContainer, err = tcredis.Run(ctx, redisImage)
+	if err != nil {
+		return fmt.Errorf("failed to start Redis: %w", err)
+	}
+	endpoint, err := redisContainer.ConnectionString(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the Redis address: %w", err)
+	}
+	redisAddr = strings.TrimPrefix(endpoint, "redis://")
+
+	redisDBs = make(chan int, 15)
+	for index := 1; index <= 15; index++ {
+		redisDBs <- index
+	}
+	return nil
+}
+
+// dockerHealthy reports whether a Docker daemon answers. Resolving the Docker host panics
+// when none is configured, which counts as unavailable.
+func dockerHealthy() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("docker is unavailable: %v", r)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return fmt.Errorf("docker is unavailable: %w", err)
+	}
+	defer provider.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := provider.Health(ctx); err != nil {
+		return fmt.Errorf("docker is unavailable: %w", err)
+	}
+	return nil
+}
+
+func stop() {
+	if admin != nil {
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	if postgresContainer != nil {
+		testcontainers.TerminateContainer(postgresContainer)
+	}
+	if redisContainer != nil {
+		testcontainers.TerminateContainer(redisContainer)
+	}
+}
@@ -0,0 +1,116 @@
+package testutil
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SMTPServer is a fake SMTP server answering each command with a scripted reply
+type SMTPServer struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	mu       sync.Mutex
+	replies  map[string][]string
+	messages []string
+}
+
+// NewSMTPServer starts a fake SMTP server on localhost until the test ends. Commands reply
+// 250 unless Script says otherwise. It offers neither STARTTLS nor AUTH.
+func NewSMTPServer(t testing.TB) *SMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &SMTPServer{Host: "127.0.0.1", Port: addr.Port, listener: listener, replies: map[string][]string{}}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+// Script queues replies for a command, e.g. Script("RCPT", "550 5.1.1 No such user"). Each
+// reply answers one use of the command, in order, then the command replies 250 again.
+// "DATA" scripts the reply to the message, after its final dot.
+func (s *SMTPServer) Script(command string, replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[command] = append(s.replies[command], replies...)
+}
+
+// Messages returns the messages the server accepted, with their headers
+func (s *SMTPServer) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// reply pops the scripted reply of command, ok is its default
+func (s *SMTPServer) reply(command, ok string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if queued := s.replies[command]; len(queued) > 0 {
+		s.replies[command] = queued[1:]
+		return queued[0]
+	}
+	return ok
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	write("220 localhost fake SMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(strings.TrimSpace(line) + " ")[0])
+		switch command {
+		case "EHLO", "HELO":
+			write("250 localhost")
+		case "DATA":
+			write("354 End data with <CR><LF>.<CR><LF>")
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(strings.TrimPrefix(line, "."))
+			}
+			reply := s.reply("DATA", "250 OK")
+			if strings.HasPrefix(reply, "2") {
+				s.mu.Lock()
+				s.messages = append(s.messages, message.String())
+				s.mu.Unlock()
+			}
+			write(reply)
+		case "QUIT":
+			write("221 Bye")
+			return
+		default:
+			write(s.reply(command, "250 OK"))
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package testutil builds in-memory databases and fixtures for unit tests. Tests that need
+// Postgres itself use the containers package instead.
+package testutil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"be0/internal/config"
+	"be0/internal/db"
+	"be0/internal/models"
+	"be0/internal/utils/crypto"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Password is the password of the users created by CreateUser
+const Password = "correct-horse-battery"
+
+// JWTSecret signs the tokens of tests that call UseJWTSecret
+const JWTSecret = "test-jwt-secret"
+
+// NewDB returns a migrated SQLite database private to the test, with the default permissions
+// seeded. Writes are serialised, a transaction takes the write lock when it begins so
+// concurrent ones wait for each other instead of failing.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") +
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+		TranslateError:                           true,
+		NowFunc:                                  func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := gdb.AutoMigrate(db.Models()...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	if err := models.SeedPermissions(gdb); err != nil {
+		t.Fatalf("failed to seed permissions: %v", err)
+	}
+	return gdb
+}
+
+// NewRedis returns a client of an in-memory Redis private to the test, the server is
+// returned to move its clock with FastForward
+func NewRedis(t testing.TB) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+// UseDB installs gdb as db.DB until the test ends, for code that reads the global
+func UseDB(t testing.TB, gdb *gorm.DB) {
+	t.Helper()
+	previous := db.DB
+	db.DB = gdb
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// UseConfig loads the configuration for code reading config.GetConfig and returns it. Changes
+// the test makes to it are undone when the test ends.
+func UseConfig(t testing.TB) *config.Config {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := *cfg
+	t.Cleanup(func() { *cfg = snapshot })
+	return cfg
+}
+
+// UseJWTSecret sets JWT_SECRET to JWTSecret until the test ends
+func UseJWTSecret(t testing.TB) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", JWTSecret)
+}
+
+// IST is a non-UTC zone with a half hour offset, which catches times compared across zones
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// UseTimeZone runs the test with time.Local set to zone, as if the server ran with TZ set
+func UseTimeZone(t testing.TB, zone *time.Location) {
+	t.Helper()
+	previous := time.Local
+	time.Local = zone
+	t.Cleanup(func() { time.Local = previous })
+}
+
+// CreateTeam creates a team
+func CreateTeam(t testing.TB, gdb *gorm.DB, name string) *models.Team {
+	t.Helper()
+	team := &models.Team{Name: name}
+	if err := gdb.Create(team).Error; err != nil {
+		t.Fatalf("failed to create team: %v", err)
+	}
+	return team
+}
+
+// CreateUser creates a verified user of a team with the default permissions of role,
+// signing in with Password
+func CreateUser(t testing.TB, gdb *gorm.DB, teamID string, role models.UserRole) *models.User {
+	t.Helper()
+
+	// The minimum cost keeps tests fast, comparisons work the same
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	id := uuid.New().String()
+	user := &models.User{
+		Base:          models.Base{ID: id},
+		Email:         fmt.Sprintf("%s@example.com", id[:8]),
+		EmailVerified: true,
+		Password:      string(hash),
+		FirstName:     "Test",
+		LastName:      string(role),
+		Role:          role,
+		TeamID:        teamID,
+	}
+	if err := gdb.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := models.AssignDefaultPermissions(gdb, user); err != nil {
+		t.Fatalf("failed to assign permissions: %v", err)
+	}
+	return user
+}
+
+// UseKeys gives the crypto package a fresh key pair for the test
+func UseKeys(t testing.TB) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousPrivate, previousPublic := crypto.PrivateKey, crypto.PublicKey
+	crypto.PrivateKey, crypto.PublicKey = key, &key.PublicKey
+	t.Cleanup(func() { crypto.PrivateKey, crypto.PublicKey = previousPrivate, previousPublic })
+}
+
+// TOTP computes the 6 digit RFC 6238 code of a base32 secret at a time, independently of utils
+func TOTP(t testing.TB, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
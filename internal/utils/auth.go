@@ -3,33 +3,37 @@ package utils
 import (
 	"crypto/rand"
 	"fmt"
-
-	"github.com/golang-jwt/jwt/v4"
 )
 
-func ValidateRefreshToken(token string, secret string) (*jwt.RegisteredClaims, error) {
-	claims := &jwt.RegisteredClaims{}
-	_, _, err := new(jwt.Parser).ParseUnverified(token, claims)
-	if err != nil {
-		return nil, err
-	}
-	return claims, nil
-}
-
-// 🎲 GenerateRandomString generates a random string of specified length using crypto/rand
+// 🎲 GenerateRandomString generates a random string of specified length using crypto/rand.
+// Every character is drawn uniformly from a 62-symbol alphanumeric alphabet, so each one
+// carries log2(62) ≈ 5.95 bits of entropy; e.g. 11 characters give ≥64 bits.
 func GenerateRandomString(length int) (string, error) {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
+	// 🎯 Largest multiple of len(charset) that fits in a byte, bytes above it are rejected
+	// so that the modulo below does not favour the first characters of the charset
+	const maxByte = 256 - (256 % len(charset))
 
-	// 🔒 Use crypto/rand for secure random generation
-	if _, err := rand.Read(b); err != nil {
-		return "", fmt.Errorf("failed to generate random string: %w", err)
-	}
+	result := make([]byte, 0, length)
+	buf := make([]byte, length)
+
+	for len(result) < length {
+		// 🔒 Use crypto/rand for secure random generation
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate random string: %w", err)
+		}
 
-	// 🔄 Map random bytes to charset
-	for i := 0; i < length; i++ {
-		b[i] = charset[b[i]%byte(len(charset))]
+		// 🔄 Map accepted random bytes to charset
+		for _, v := range buf {
+			if int(v) >= maxByte {
+				continue
+			}
+			result = append(result, charset[int(v)%len(charset)])
+			if len(result) == length {
+				break
+			}
+		}
 	}
 
-	return string(b), nil
+	return string(result), nil
 }
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func TestGenerateRandomStringLengthAndCharset(t *testing.T) {
+	for _, length := range []int{0, 1, 7, 10, 12, 32, 100} {
+		for i := 0; i < 1000; i++ {
+			s, err := GenerateRandomString(length)
+			if err != nil {
+				t.Fatalf("GenerateRandomString(%d): %v", length, err)
+			}
+			if len(s) != length {
+				t.Fatalf("GenerateRandomString(%d) returned %d characters: %q", length, len(s), s)
+			}
+			if i := strings.IndexFunc(s, func(r rune) bool { return !strings.ContainsRune(alphanumeric, r) }); i >= 0 {
+				t.Fatalf("GenerateRandomString(%d) returned %q outside the alphanumeric charset", length, s[i])
+			}
+		}
+	}
+}
+
+func TestGenerateRandomStringIsUnbiased(t *testing.T) {
+	const samples = 62 * 2000
+	s, err := GenerateRandomString(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[rune]int, len(alphanumeric))
+	for _, r := range s {
+		counts[r]++
+	}
+	if len(counts) != len(alphanumeric) {
+		t.Fatalf("only %d of %d characters were drawn", len(counts), len(alphanumeric))
+	}
+
+	// Chi-square over 61 degrees of freedom, 120 is far beyond the 99.99th percentile (~105)
+	expected := float64(samples) / float64(len(alphanumeric))
+	var chi2 float64
+	for _, n := range counts {
+		chi2 += math.Pow(float64(n)-expected, 2) / expected
+	}
+	if chi2 > 120 {
+		t.Errorf("character distribution is skewed, chi-square %.1f", chi2)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"be0/internal/models"
+)
+
+// ClaimEnricher returns claims to add to the access tokens of a user acting in user.TeamID,
+// e.g. their team's plan, so services verifying the token need no API roundtrip.
+// A nil map adds nothing.
+type ClaimEnricher func(user models.User) map[string]any
+
+var (
+	claimEnrichers   []ClaimEnricher
+	claimEnrichersMu sync.RWMutex
+)
+
+// RegisterClaimEnricher adds an enricher run whenever an access token is issued. Its claims
+// are nested under the ext claim, later registrations win for the same key. Refresh tokens
+// never carry enriched claims.
+func RegisterClaimEnricher(enricher ClaimEnricher) {
+	claimEnrichersMu.Lock()
+	defer claimEnrichersMu.Unlock()
+	claimEnrichers = append(claimEnrichers, enricher)
+}
+
+// enrichClaims runs the registered enrichers, nil when none added anything
+func enrichClaims(user models.User) map[string]any {
+	claimEnrichersMu.RLock()
+	enrichers := append([]ClaimEnricher(nil), claimEnrichers...)
+	claimEnrichersMu.RUnlock()
+
+	var ext map[string]any
+	for _, enricher := range enrichers {
+		for key, value := range enricher(user) {
+			if ext == nil {
+				ext = map[string]any{}
+			}
+			ext[key] = value
+		}
+	}
+	return ext
+}
+
+// ErrTokenTooLarge is returned when an access token exceeds the configured byte budget,
+// usually because enrichers added too much to it
+var ErrTokenTooLarge = errors.New("access token too large")
+
+// checkTokenSize rejects signed tokens beyond AccessTokenConfig.MaxBytes, 0 is unlimited
+func checkTokenSize(token string) error {
+	if max := accessTokens.MaxBytes; max > 0 && len(token) > max {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrTokenTooLarge, len(token), max)
+	}
+	return nil
+}
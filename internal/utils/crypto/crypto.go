@@ -9,8 +9,10 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -22,7 +24,13 @@ var log = logger.New("crypto")
 var PrivateKey *rsa.PrivateKey
 var PublicKey *rsa.PublicKey
 
-func InitializeKeys(privateKeyEnv string) error {
+// PreviousPrivateKeys are keys PrivateKey replaced. They no longer seal or sign anything but
+// still open tokens and decrypt data produced before the rotation.
+var PreviousPrivateKeys []*rsa.PrivateKey
+
+// InitializeKeys sets PrivateKey, and the keys it replaced during a rotation, from base64
+// encoded PEM keys
+func InitializeKeys(privateKeyEnv string, previousKeysEnv ...string) error {
 
 	log.Info("Initializing keys")
 
@@ -30,23 +38,44 @@ func InitializeKeys(privateKeyEnv string) error {
 		return errors.New("private key not found")
 	}
 
-	privateKeyEnv, err := base64_.DecodeFromBase64(privateKeyEnv)
-
+	key, err := parsePrivateKey(privateKeyEnv)
 	if err != nil {
-		return fmt.Errorf("failed to decode private key: %w", err)
+		return err
 	}
 
-	key, err := ssh.ParseRawPrivateKey([]byte(privateKeyEnv))
-
-	if err != nil {
-		return fmt.Errorf("failed to parse private key: %w", err)
+	previous := make([]*rsa.PrivateKey, 0, len(previousKeysEnv))
+	for i, previousKeyEnv := range previousKeysEnv {
+		previousKey, err := parsePrivateKey(previousKeyEnv)
+		if err != nil {
+			return fmt.Errorf("previous key %d: %w", i+1, err)
+		}
+		previous = append(previous, previousKey)
 	}
 
-	PrivateKey = key.(*rsa.PrivateKey)
+	PrivateKey = key
 	PublicKey = &PrivateKey.PublicKey
+	PreviousPrivateKeys = previous
 	return nil
 }
 
+// parsePrivateKey decodes a base64 encoded PEM RSA key
+func parsePrivateKey(encoded string) (*rsa.PrivateKey, error) {
+	decoded, err := base64_.DecodeFromBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	key, err := ssh.ParseRawPrivateKey([]byte(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
 func SignJWT(data string) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
@@ -63,6 +92,55 @@ func SignJWT(data string) (string, error) {
 	return signedString, nil
 }
 
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// PublicJWK returns the public key as a JWK for verifying RS256 tokens
+func PublicJWK() (JWK, error) {
+	if PublicKey == nil {
+		return JWK{}, errors.New("public key not initialized")
+	}
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: KeyID(),
+		N:   base64.RawURLEncoding.EncodeToString(PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(PublicKey.E)).Bytes()),
+	}, nil
+}
+
+// KeyID is the RFC 7638 thumbprint of the public key, tokens name it in their kid header
+func KeyID() string {
+	if PublicKey == nil {
+		return ""
+	}
+	sum := thumbprint(PublicKey)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of key
+func thumbprint(key *rsa.PublicKey) [sha256.Size]byte {
+	// The thumbprint hashes the required members in lexicographic order
+	members, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+	return sha256.Sum256(members)
+}
+
 // Encrypt Not used in this snippet but can be used to Encrypt data
 func Encrypt(plaintext string) (string, error) {
 	if PublicKey == nil {
@@ -101,6 +179,13 @@ func Decrypt(ciphertext string) (string, error) {
 		decodedCiphertext,
 		nil,
 	)
+	// 🔁 Data encrypted before a key rotation is read with the key of its time
+	for _, previous := range PreviousPrivateKeys {
+		if err == nil {
+			break
+		}
+		plaintext, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, previous, decodedCiphertext, nil)
+	}
 	if err != nil {
 		return "", err
 	}
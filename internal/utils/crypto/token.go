@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// tokenVersion is the layout of sealed tokens: the version byte, the key ID, the nonce, then
+// the AES-GCM ciphertext of the expiry and the JSON payload
+const tokenVersion byte = 1
+
+const (
+	tokenKeyIDSize  = 4
+	tokenHeaderSize = 1 + tokenKeyIDSize
+	tokenNonceSize  = 12
+	tokenExpirySize = 8
+	tokenTagSize    = 16
+)
+
+// tokenKeyInfo separates the token key from anything else derived from the private key
+var tokenKeyInfo = []byte("be0 sealed token v1")
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, were tampered with or were
+	// sealed with a key that is no longer known
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for authentic tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// tokenKey is the AES key derived from one private key
+type tokenKey struct {
+	id   [tokenKeyIDSize]byte
+	aead cipher.AEAD
+}
+
+var (
+	tokenKeysMu sync.Mutex
+	tokenKeys   = map[*rsa.PrivateKey]*tokenKey{}
+)
+
+// deriveTokenKey returns the token key of key, derived with HKDF-SHA256 once per key
+func deriveTokenKey(key *rsa.PrivateKey) (*tokenKey, error) {
+	tokenKeysMu.Lock()
+	defer tokenKeysMu.Unlock()
+	if derived, ok := tokenKeys[key]; ok {
+		return derived, nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, x509.MarshalPKCS1PrivateKey(key), nil, tokenKeyInfo), secret); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	derived := &tokenKey{aead: aead}
+	// The key ID is public, it is taken from the thumbprint of the public key
+	sum := thumbprint(&key.PublicKey)
+	copy(derived.id[:], sum[:])
+	tokenKeys[key] = derived
+	return derived, nil
+}
+
+// SealToken encrypts payload as JSON into a URL-safe token that expires after ttl. Clients
+// can neither read nor alter it. Include what the token is for in payload, so a token of one
+// feature can't be passed to another.
+func SealToken(payload any, ttl time.Duration) (string, error) {
+	if PrivateKey == nil {
+		return "", errors.New("private key not initialized")
+	}
+	if ttl <= 0 {
+		return "", errors.New("token ttl must be positive")
+	}
+	key, err := deriveTokenKey(PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token payload: %w", err)
+	}
+	plaintext := binary.BigEndian.AppendUint64(make([]byte, 0, tokenExpirySize+len(body)), uint64(time.Now().Add(ttl).Unix()))
+	plaintext = append(plaintext, body...)
+
+	token := make([]byte, tokenHeaderSize+tokenNonceSize, tokenHeaderSize+tokenNonceSize+len(plaintext)+tokenTagSize)
+	token[0] = tokenVersion
+	copy(token[1:tokenHeaderSize], key.id[:])
+	nonce := token[tokenHeaderSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// 🔏 The header is authenticated too, so the version and key ID can't be swapped
+	token = key.aead.Seal(token, nonce, plaintext, token[:tokenHeaderSize])
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// OpenToken decrypts a token of SealToken into into. Tokens sealed before a key rotation
+// open with the key in PreviousPrivateKeys they were sealed with. It returns ErrTokenExpired
+// for expired tokens and ErrInvalidToken for any other token it can't open.
+func OpenToken(token string, into any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < tokenHeaderSize+tokenNonceSize+tokenExpirySize+tokenTagSize || raw[0] != tokenVersion {
+		return ErrInvalidToken
+	}
+
+	key, err := findTokenKey(raw[1:tokenHeaderSize])
+	if err != nil {
+		return err
+	}
+	nonce := raw[tokenHeaderSize : tokenHeaderSize+tokenNonceSize]
+	plaintext, err := key.aead.Open(nil, nonce, raw[tokenHeaderSize+tokenNonceSize:], raw[:tokenHeaderSize])
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if expiresAt := int64(binary.BigEndian.Uint64(plaintext)); time.Now().Unix() >= expiresAt {
+		return ErrTokenExpired
+	}
+	if err := json.Unmarshal(plaintext[tokenExpirySize:], into); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// findTokenKey returns the token key of the current or a previous private key with id
+func findTokenKey(id []byte) (*tokenKey, error) {
+	if PrivateKey == nil {
+		return nil, errors.New("private key not initialized")
+	}
+	for _, privateKey := range append([]*rsa.PrivateKey{PrivateKey}, PreviousPrivateKeys...) {
+		key, err := deriveTokenKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		if string(key.id[:]) == string(id) {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
@@ -0,0 +1,156 @@
+package crypto_test
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+	"be0/internal/utils/crypto"
+)
+
+type cursor struct {
+	Purpose string `json:"p"`
+	After   string `json:"a"`
+}
+
+// rotateKeys replaces the key, keeping the old one as a previous key, until the test ends
+func rotateKeys(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	old := crypto.PrivateKey
+	previous := crypto.PreviousPrivateKeys
+	testutil.UseKeys(t)
+	crypto.PreviousPrivateKeys = []*rsa.PrivateKey{old}
+	t.Cleanup(func() { crypto.PreviousPrivateKeys = previous })
+	return old
+}
+
+func TestSealTokenRoundTrip(t *testing.T) {
+	testutil.UseKeys(t)
+
+	token, err := crypto.SealToken(cursor{Purpose: "cursor", After: "2026-01-01T00:00:00Z"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(token, "+/=") || strings.Contains(token, "2026") {
+		t.Errorf("token %q is not opaque and URL-safe", token)
+	}
+
+	var opened cursor
+	if err := crypto.OpenToken(token, &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened != (cursor{Purpose: "cursor", After: "2026-01-01T00:00:00Z"}) {
+		t.Errorf("opened %+v", opened)
+	}
+
+	// Two seals of the same payload differ, they use fresh nonces
+	again, _ := crypto.SealToken(cursor{Purpose: "cursor", After: "2026-01-01T00:00:00Z"}, time.Minute)
+	if again == token {
+		t.Error("sealing twice produced the same token")
+	}
+}
+
+func TestOpenTokenRejectsTampering(t *testing.T) {
+	testutil.UseKeys(t)
+	token, err := crypto.SealToken(cursor{Purpose: "cursor", After: "a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+
+	// 🔧 Flipping any bit, header included, breaks the token
+	for i := range raw {
+		tampered := append([]byte(nil), raw...)
+		tampered[i] ^= 0x01
+		var opened cursor
+		if err := crypto.OpenToken(base64.RawURLEncoding.EncodeToString(tampered), &opened); !errors.Is(err, crypto.ErrInvalidToken) {
+			t.Fatalf("byte %d flipped: got %v, want ErrInvalidToken", i, err)
+		}
+	}
+	for _, malformed := range []string{"", "not a token", token[:len(token)-4], token + "AA", "AQ"} {
+		if err := crypto.OpenToken(malformed, &cursor{}); !errors.Is(err, crypto.ErrInvalidToken) {
+			t.Errorf("%q: got %v, want ErrInvalidToken", malformed, err)
+		}
+	}
+}
+
+func TestOpenTokenRejectsExpiredTokens(t *testing.T) {
+	testutil.UseKeys(t)
+	token, err := crypto.SealToken(cursor{After: "a"}, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.OpenToken(token, &cursor{}); !errors.Is(err, crypto.ErrTokenExpired) {
+		t.Errorf("got %v, want ErrTokenExpired", err)
+	}
+	if _, err := crypto.SealToken(cursor{}, 0); err == nil {
+		t.Error("sealed a token without a ttl")
+	}
+}
+
+func TestTokensSurviveKeyRotation(t *testing.T) {
+	testutil.UseKeys(t)
+	before, err := crypto.SealToken(cursor{After: "a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := crypto.Encrypt("two-factor secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotateKeys(t)
+	var opened cursor
+	if err := crypto.OpenToken(before, &opened); err != nil || opened.After != "a" {
+		t.Errorf("token sealed before the rotation: got %+v and %v", opened, err)
+	}
+	if secret, err := crypto.Decrypt(encrypted); err != nil || secret != "two-factor secret" {
+		t.Errorf("data encrypted before the rotation: got %q and %v", secret, err)
+	}
+	after, _ := crypto.SealToken(cursor{After: "b"}, time.Minute)
+	if err := crypto.OpenToken(after, &opened); err != nil || opened.After != "b" {
+		t.Errorf("token sealed after the rotation: got %+v and %v", opened, err)
+	}
+
+	// 🗑️ Once the old key is dropped its tokens stop opening
+	crypto.PreviousPrivateKeys = nil
+	if err := crypto.OpenToken(before, &opened); !errors.Is(err, crypto.ErrInvalidToken) {
+		t.Errorf("got %v after dropping the old key, want ErrInvalidToken", err)
+	}
+}
+
+// FuzzOpenToken throws arbitrary strings at OpenToken: it may not panic, and only the
+// tokens it sealed itself open
+func FuzzOpenToken(f *testing.F) {
+	testutil.UseKeys(f)
+	valid, err := crypto.SealToken(cursor{Purpose: "cursor", After: "a"}, time.Hour)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range []string{
+		valid,
+		valid[:len(valid)/2],
+		"AQ" + valid[2:],
+		"Ag" + valid[2:],
+		"",
+		"====",
+		strings.Repeat("A", 64),
+		"eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiJ4In0.c2ln",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		var opened cursor
+		err := crypto.OpenToken(token, &opened)
+		if err == nil && token != valid {
+			t.Errorf("forged token %q opened as %+v", token, opened)
+		}
+		if err != nil && !errors.Is(err, crypto.ErrInvalidToken) && !errors.Is(err, crypto.ErrTokenExpired) {
+			t.Errorf("%q: got %v, want ErrInvalidToken", token, err)
+		}
+	})
+}
@@ -1,52 +1,200 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"time"
 
 	"be0/internal/models"
+	"be0/internal/utils/crypto"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
-	UserID      string   `json:"user_id"`
-	TeamID      string   `json:"team_id"`
-	Email       string   `json:"email"`
-	Role        string   `json:"role"`
-	Permissions []string `json:"permissions"`
+	UserID string `json:"user_id"`
+	TeamID string `json:"team_id"`
+	// OrgID is the organization of the team, empty for standalone teams
+	OrgID string `json:"org_id,omitempty"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	// Scopes are the user's "resource:action" permissions, e.g. teams:read
+	Scopes []string `json:"scopes"`
+	// SessionID is the auth transaction a refresh token belongs to
+	SessionID string `json:"sid,omitempty"`
+	// ImpersonatedBy is the super admin an impersonation token was issued to
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	// Ext holds the claims of the registered ClaimEnrichers, access tokens only
+	Ext map[string]any `json:"ext,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(user models.User) (string, error) {
-	// Extract permissions
-	permissions := make([]string, 0)
+// Algorithms access tokens can be signed with
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+// Defaults of the iss and aud claims of access tokens
+const (
+	DefaultIssuer   = "be0"
+	DefaultAudience = "be0-api"
+)
+
+// AccessTokenConfig is how access tokens are signed and which iss and aud they carry
+type AccessTokenConfig struct {
+	Algorithm string
+	Issuer    string
+	Audience  string
+	// MaxBytes rejects larger signed tokens, 0 is unlimited
+	MaxBytes int
+}
+
+// accessTokens signs new access tokens, refresh and other internal tokens always use HS256
+var accessTokens = AccessTokenConfig{Algorithm: AlgorithmHS256, Issuer: DefaultIssuer, Audience: DefaultAudience}
+
+// ConfigureAccessTokens selects how new access tokens are signed and the iss and aud they must
+// carry. RS256 signs with crypto.PrivateKey, so other services can verify tokens offline with
+// the published public key.
+func ConfigureAccessTokens(cfg AccessTokenConfig) error {
+	switch cfg.Algorithm {
+	case AlgorithmHS256:
+	case AlgorithmRS256:
+		if crypto.PrivateKey == nil {
+			return errors.New("RS256 needs the private key to be initialized")
+		}
+	default:
+		return fmt.Errorf("unsupported access token algorithm %q", cfg.Algorithm)
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return errors.New("access tokens need an issuer and an audience")
+	}
+	accessTokens = cfg
+	return nil
+}
+
+// signAccessToken signs access token claims with the configured algorithm, within the byte budget
+func signAccessToken(claims Claims) (string, error) {
+	var signed string
+	var err error
+	if accessTokens.Algorithm == AlgorithmRS256 {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = crypto.KeyID()
+		signed, err = token.SignedString(crypto.PrivateKey)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		signed, err = token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := checkTokenSize(signed); err != nil {
+		return "", err
+	}
+	return signed, nil
+}
+
+// AccessTokenKey returns the key verifying an access token, picked by the alg of its header.
+// HS256 tokens are only checked against secret and RS256 tokens only against the public key,
+// so a token signed with the public key as an HMAC secret never verifies. Both are accepted
+// whichever algorithm signs new tokens, sessions survive switching JWT_ALGORITHM.
+func AccessTokenKey(secret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		switch token.Method {
+		case jwt.SigningMethodHS256:
+			return []byte(secret), nil
+		case jwt.SigningMethodRS256:
+			if crypto.PublicKey == nil {
+				return nil, errors.New("public key not initialized")
+			}
+			if kid, ok := token.Header["kid"]; ok && kid != crypto.KeyID() {
+				return nil, fmt.Errorf("unknown signing key %v", kid)
+			}
+			return crypto.PublicKey, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+// ErrInvalidAccessClaims is returned for access tokens of another issuer or audience, or without an ID
+var ErrInvalidAccessClaims = errors.New("invalid access token claims")
+
+// ValidateAccessClaims checks that an access token was issued by this service for its API and
+// names its jti, tokens signed with a shared secret by another service are rejected
+func ValidateAccessClaims(claims *jwt.RegisteredClaims) error {
+	if claims.ID == "" || !claims.VerifyIssuer(accessTokens.Issuer, true) || !claims.VerifyAudience(accessTokens.Audience, true) {
+		return ErrInvalidAccessClaims
+	}
+	return nil
+}
+
+// userScopes lists the scopes of a user's permissions, which must be loaded with their
+// ResourcePermission, see models.LoadScopes
+func userScopes(user models.User) []string {
+	scopes := make([]string, 0, len(user.Permissions))
 	for _, p := range user.Permissions {
-		permissions = append(permissions, p.ResourcePermission.Scope)
+		if p.ResourcePermission != nil {
+			scopes = append(scopes, p.ResourcePermission.Scope)
+		}
 	}
+	return scopes
+}
 
+// GenerateJWT issues an access token for the user acting in user.TeamID, which belongs to
+// organization orgID or to none when it is empty. tokenID is its jti, which sessions store.
+func GenerateJWT(user models.User, orgID string) (token, tokenID string, err error) {
 	claims := Claims{
-		UserID:      user.ID,
-		TeamID:      user.TeamID,
-		Email:       user.Email,
-		Role:        string(user.Role),
-		Permissions: permissions,
+		UserID: user.ID,
+		TeamID: user.TeamID,
+		OrgID:  orgID,
+		Email:  user.Email,
+		Role:   string(user.Role),
+		Scopes: userScopes(user),
+		Ext:    enrichClaims(user),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    accessTokens.Issuer,
+			Audience:  jwt.ClaimStrings{accessTokens.Audience},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token, err = signAccessToken(claims)
+	return token, claims.ID, err
+}
+
+// GenerateImpersonationJWT issues a token with the user's claims to a super admin acting as them.
+// It expires after models.ImpersonationLifetime and comes without a refresh token.
+func GenerateImpersonationJWT(user models.User, orgID, impersonatorID string) (token, tokenID string, err error) {
+	claims := Claims{
+		UserID:         user.ID,
+		TeamID:         user.TeamID,
+		OrgID:          orgID,
+		Email:          user.Email,
+		Role:           string(user.Role),
+		Scopes:         userScopes(user),
+		ImpersonatedBy: impersonatorID,
+		Ext:            enrichClaims(user),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    accessTokens.Issuer,
+			Audience:  jwt.ClaimStrings{accessTokens.Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(models.ImpersonationLifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err = signAccessToken(claims)
+	return token, claims.ID, err
 }
 
-// ParseJWT parses and validates a JWT token
+// ParseJWT parses and validates an access token signed with either algorithm, and its iss, aud and jti
 func ParseJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, AccessTokenKey(os.Getenv("JWT_SECRET")))
 
 	if err != nil {
 		return nil, err
@@ -55,16 +203,27 @@ func ParseJWT(tokenString string) (*Claims, error) {
 	if !token.Valid {
 		return nil, jwt.ErrSignatureInvalid
 	}
+	if err := ValidateAccessClaims(&claims.RegisteredClaims); err != nil {
+		return nil, err
+	}
 
 	return claims, nil
 }
 
-// GenerateRefreshToken generates a refresh token for a user
-func GenerateRefreshToken(user models.User) (string, error) {
+// refreshAudience keeps refresh tokens from being accepted where other tokens are expected, and vice versa
+const refreshAudience = "refresh"
+
+// GenerateRefreshToken generates a refresh token for a session of a user. Every token
+// carries a unique ID, so rotating within the same second still yields a new token.
+// Enriched claims are left out, they are added to the access tokens it is exchanged for.
+func GenerateRefreshToken(user models.User, sessionID string) (string, error) {
 	claims := Claims{
-		UserID: user.ID,
+		UserID:    user.ID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{refreshAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(models.SessionLifetime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -73,20 +232,80 @@ func GenerateRefreshToken(user models.User) (string, error) {
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 }
 
-// ParseRefreshToken parses and validates a refresh token
+// ParseRefreshToken verifies the signature, expiry and audience of a refresh token
 func ParseRefreshToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
+	if !token.Valid || claims.UserID == "" || claims.SessionID == "" || !claims.VerifyAudience(refreshAudience, true) {
 		return nil, jwt.ErrSignatureInvalid
 	}
-
 	return claims, nil
 }
+
+// TwoFactorChallengeTTL is how long a user has to enter their second factor after the password
+const TwoFactorChallengeTTL = 5 * time.Minute
+
+// TwoFactorEnrollmentTTL is how long a user has to set up 2FA when their team requires it at sign-in
+const TwoFactorEnrollmentTTL = 15 * time.Minute
+
+// GenerateTwoFactorChallenge issues a short-lived token proving the password of a user was checked
+func GenerateTwoFactorChallenge(userID string) (string, error) {
+	return generateSignInStep(userID, "two_factor", TwoFactorChallengeTTL)
+}
+
+// ValidateTwoFactorChallenge returns the user a challenge token was issued to
+func ValidateTwoFactorChallenge(tokenString string) (string, error) {
+	return validateSignInStep(tokenString, "two_factor")
+}
+
+// GenerateTwoFactorEnrollment issues a token letting a signed-in user without 2FA set it up,
+// when their team requires it before a session is issued
+func GenerateTwoFactorEnrollment(userID string) (string, error) {
+	return generateSignInStep(userID, "two_factor_enrollment", TwoFactorEnrollmentTTL)
+}
+
+// ValidateTwoFactorEnrollment returns the user an enrollment token was issued to
+func ValidateTwoFactorEnrollment(tokenString string) (string, error) {
+	return validateSignInStep(tokenString, "two_factor_enrollment")
+}
+
+// generateSignInStep signs a token for a sign-in that needs another step, the audience names the step
+func generateSignInStep(userID, audience string, ttl time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// validateSignInStep returns the user of a sign-in step token issued for audience
+func validateSignInStep(tokenString, audience string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid || claims.Subject == "" || !claims.VerifyAudience(audience, true) {
+		return "", jwt.ErrSignatureInvalid
+	}
+	return claims.Subject, nil
+}
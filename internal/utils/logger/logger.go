@@ -1,9 +1,13 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -11,8 +15,12 @@ import (
 
 type Logger struct {
 	serviceName string
+	fields      Fields
 }
 
+// Fields are appended to every line of a logger as key=value pairs, e.g. request_id=...
+type Fields map[string]string
+
 var (
 	// INFO_EMOJI Emoji constants
 	INFO_EMOJI    = "ℹ️ "
@@ -28,12 +36,56 @@ func New(serviceName string) *Logger {
 	}
 }
 
+// WithFields returns a logger appending fields to its lines, after those it already had
+func (l *Logger) WithFields(fields Fields) *Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{serviceName: l.serviceName, fields: merged}
+}
+
+// FromContext returns a logger appending the fields attached to ctx with NewContext,
+// such as the request and caller of an API request
+func (l *Logger) FromContext(ctx context.Context) *Logger {
+	return l.WithFields(FieldsFromContext(ctx))
+}
+
+type fieldsKey struct{}
+
+// NewContext returns a copy of ctx carrying fields, merged over those it already carried
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for k, v := range FieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the fields attached to ctx, nil without any
+func FieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return fields
+}
+
 func (l *Logger) formatMessage(level, emoji, msg string) string {
 	_, file, line, _ := runtime.Caller(2)
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	fileName := filepath.Base(file)
 
-	return fmt.Sprintf("%s | %s | %s | %s:%d | %s | %s",
+	return fmt.Sprintf("%s | %s | %s | %s:%d | %s | %s%s",
 		emoji,
 		timestamp,
 		level,
@@ -41,9 +93,32 @@ func (l *Logger) formatMessage(level, emoji, msg string) string {
 		line,
 		l.serviceName,
 		msg,
+		l.formatFields(),
 	)
 }
 
+// formatFields renders the fields sorted by key, quoting values that contain spaces
+func (l *Logger) formatFields() string {
+	if len(l.fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		v := l.fields[k]
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(" " + k + "=" + v)
+	}
+	return b.String()
+}
+
 func (l *Logger) Info(msg string, args ...interface{}) {
 	formatted := l.formatMessage("INFO", INFO_EMOJI, fmt.Sprintf(msg, args...))
 	color.Cyan(formatted)
@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// capture collects what loggers print during the test
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	output, noColor := color.Output, color.NoColor
+	color.Output, color.NoColor = &buf, true
+	t.Cleanup(func() { color.Output, color.NoColor = output, noColor })
+	return &buf
+}
+
+func TestFieldsAreAppendedSorted(t *testing.T) {
+	out := capture(t)
+	log := New("test").WithFields(Fields{"user_id": "u1", "request_id": "r1"}).WithFields(Fields{"note": "two words"})
+	log.Info("hello %s", "world")
+
+	line := strings.TrimSpace(out.String())
+	if !strings.HasSuffix(line, `| test | hello world note="two words" request_id=r1 user_id=u1`) {
+		t.Errorf("line %q does not end with the message and sorted fields", line)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	out := capture(t)
+	ctx := NewContext(context.Background(), Fields{"request_id": "r1"})
+	ctx = NewContext(ctx, Fields{"user_id": "u1"})
+
+	base := New("test")
+	base.FromContext(ctx).Warn("denied")
+	base.FromContext(context.Background()).Warn("plain")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "denied request_id=r1 user_id=u1") || !strings.HasSuffix(lines[1], "| plain") {
+		t.Errorf("lines %q, want the request fields on the first only", lines)
+	}
+	if base.fields != nil {
+		t.Error("FromContext changed the base logger")
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const microsoftGraphURL = "https://graph.microsoft.com/v1.0"
+
+var microsoftGraphClient = &http.Client{Timeout: 10 * time.Second}
+
+// MicrosoftUser is the Microsoft Graph profile of a signed in user
+type MicrosoftUser struct {
+	ID                string `json:"id"`
+	Mail              string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	GivenName         string `json:"givenName"`
+	Surname           string `json:"surname"`
+}
+
+// Email returns the user's mail address, falling back to the principal name for
+// accounts without a mailbox
+func (u *MicrosoftUser) Email() string {
+	if u.Mail != "" {
+		return u.Mail
+	}
+	return u.UserPrincipalName
+}
+
+// GetUserDataFromMicrosoft validates the access token by reading the user's profile from Graph
+func GetUserDataFromMicrosoft(accessToken string) (*MicrosoftUser, error) {
+	var user MicrosoftUser
+	if err := getMicrosoftGraph(accessToken, "/me?$select=id,mail,userPrincipalName,givenName,surname", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == "" || user.Email() == "" {
+		return nil, fmt.Errorf("profile has no id or email")
+	}
+	return &user, nil
+}
+
+// GetMicrosoftTenantID returns the Azure AD tenant the token's user belongs to, empty for
+// personal Microsoft accounts
+func GetMicrosoftTenantID(accessToken string) (string, error) {
+	var organizations struct {
+		Value []struct {
+			ID string `json:"id"`
+		} `json:"value"`
+	}
+	if err := getMicrosoftGraph(accessToken, "/organization?$select=id", &organizations); err != nil {
+		return "", err
+	}
+	if len(organizations.Value) == 0 {
+		return "", nil
+	}
+	return organizations.Value[0].ID, nil
+}
+
+// MicrosoftEmailVerified reports whether the access token has the xms_edov claim, set when
+// the tenant verified the domain of the user's email. The claim is only read, not checked,
+// so call it once Graph accepted the token. Opaque tokens of personal accounts are unverified.
+func MicrosoftEmailVerified(accessToken string) bool {
+	var claims struct {
+		jwt.RegisteredClaims
+		EmailDomainVerified interface{} `json:"xms_edov"`
+	}
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, &claims); err != nil {
+		return false
+	}
+	switch verified := claims.EmailDomainVerified.(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true" || verified == "1"
+	default:
+		return false
+	}
+}
+
+func getMicrosoftGraph(accessToken, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, microsoftGraphURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	response, err := microsoftGraphClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed getting %s: %s", path, err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("graph returned %d for %s", response.StatusCode, path)
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed decoding %s: %s", path, err.Error())
+	}
+	return nil
+}
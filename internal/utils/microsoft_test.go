@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestMicrosoftEmailVerified(t *testing.T) {
+	// Graph tokens are signed by Microsoft, the claim is read without checking the signature
+	token := func(claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("not-microsoft"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "verified", token: token(jwt.MapClaims{"xms_edov": true}), want: true},
+		{name: "verified as a string", token: token(jwt.MapClaims{"xms_edov": "1"}), want: true},
+		{name: "unverified", token: token(jwt.MapClaims{"xms_edov": false})},
+		{name: "no claim", token: token(jwt.MapClaims{"oid": "user"})},
+		{name: "opaque token", token: "EwBwA8l6BAAU-opaque"},
+		{name: "empty", token: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MicrosoftEmailVerified(tt.token); got != tt.want {
+				t.Errorf("MicrosoftEmailVerified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"be0/internal/utils/crypto"
+)
+
+// ShareUnlockTTL is how long an unlocked password-protected share stays accessible
+const ShareUnlockTTL = 15 * time.Minute
+
+// shareUnlockPurpose tells share unlock tokens apart from other sealed tokens
+const shareUnlockPurpose = "file_share"
+
+// shareUnlock is the payload of a share unlock token
+type shareUnlock struct {
+	Purpose string `json:"p"`
+	Share   string `json:"s"`
+}
+
+// GenerateShareUnlockToken issues a short-lived token proving the password of a share was entered
+func GenerateShareUnlockToken(shareToken string) (string, error) {
+	return crypto.SealToken(shareUnlock{Purpose: shareUnlockPurpose, Share: shareToken}, ShareUnlockTTL)
+}
+
+// ValidateShareUnlockToken checks that an unlock token is valid for the given share
+func ValidateShareUnlockToken(tokenString, shareToken string) error {
+	var unlock shareUnlock
+	if err := crypto.OpenToken(tokenString, &unlock); err != nil {
+		return err
+	}
+	if unlock.Purpose != shareUnlockPurpose || unlock.Share != shareToken {
+		return errors.New("unlock token is for another share")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+	"be0/internal/utils/crypto"
+)
+
+func TestShareUnlockToken(t *testing.T) {
+	testutil.UseKeys(t)
+
+	token, err := GenerateShareUnlockToken("share-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateShareUnlockToken(token, "share-a"); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+	if err := ValidateShareUnlockToken(token, "share-b"); err == nil {
+		t.Error("token of share-a unlocked share-b")
+	}
+
+	// Sealed tokens of other features don't unlock shares
+	other, err := crypto.SealToken(shareUnlock{Purpose: "cursor", Share: "share-a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateShareUnlockToken(other, "share-a"); err == nil {
+		t.Error("a token sealed for another purpose unlocked the share")
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"be0/internal/testutil"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		at := time.Unix(unix, 0)
+		if got := testutil.TOTP(t, rfcSecret, at); got != want {
+			t.Errorf("testutil.TOTP at %d = %s, want %s", unix, got, want)
+		}
+		step, ok := ValidateTOTP(rfcSecret, want, at)
+		if !ok || step != unix/30 {
+			t.Errorf("ValidateTOTP(%s) at %d = %d, %v, want step %d", want, unix, step, ok, unix/30)
+		}
+	}
+}
+
+func TestValidateTOTPClockSkew(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_800_000_015, 0)
+	step := now.Unix() / 30
+
+	tests := []struct {
+		name   string
+		offset int64 // periods between the code and now
+		ok     bool
+	}{
+		{"two periods behind", -2, false},
+		{"previous period", -1, true},
+		{"current period", 0, true},
+		{"next period", 1, true},
+		{"two periods ahead", 2, false},
+	}
+	for _, tt := range tests {
+		code := testutil.TOTP(t, secret, now.Add(time.Duration(tt.offset)*TOTPPeriod))
+		got, ok := ValidateTOTP(secret, code, now)
+		if ok != tt.ok {
+			t.Errorf("%s: accepted %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && got != step+tt.offset {
+			t.Errorf("%s: step %d, want %d", tt.name, got, step+tt.offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, tt := range []struct{ secret, code string }{
+		{rfcSecret, ""},
+		{rfcSecret, "28708"},
+		{rfcSecret, "2870820"},
+		{rfcSecret, "abcdef"},
+		{"not base32!", "287082"},
+	} {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok {
+			t.Errorf("ValidateTOTP(%q, %q) accepted", tt.secret, tt.code)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"be0/internal/events"
+	"be0/internal/models"
+)
+
+// EventDefinition describes an event teams can subscribe to
+type EventDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Example is a payload of the type delivered for this event
+	Example events.TeamScoped `json:"example"`
+	// payload converts the data emitted on the event bus to the delivered payload
+	payload func(data interface{}) (events.TeamScoped, bool)
+	// sample is example data as emitted on the event bus, when it differs from Example
+	sample interface{}
+}
+
+// Sample returns example data in the shape emitted on the event bus, for test invocations
+func (d EventDefinition) Sample() interface{} {
+	if d.sample != nil {
+		return d.sample
+	}
+	return d.Example
+}
+
+// setSample records bus-shaped example data for an event whose bus payload is converted
+func setSample(name string, sample interface{}) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	def := catalog[name]
+	def.sample = sample
+	catalog[name] = def
+}
+
+var (
+	catalog   = map[string]EventDefinition{}
+	catalogMu sync.RWMutex
+)
+
+// Register adds an event to the catalogue. convert may be nil when the bus
+// already emits the delivered payload type.
+func Register(name, description string, example events.TeamScoped, convert func(data interface{}) (events.TeamScoped, bool)) {
+	if convert == nil {
+		convert = func(data interface{}) (events.TeamScoped, bool) {
+			scoped, ok := data.(events.TeamScoped)
+			return scoped, ok
+		}
+	}
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalog[name] = EventDefinition{Name: name, Description: description, Example: example, payload: convert}
+}
+
+// Lookup returns a catalogue entry by event name
+func Lookup(name string) (EventDefinition, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	def, ok := catalog[name]
+	return def, ok
+}
+
+// Catalog returns every subscribable event sorted by name
+func Catalog() []EventDefinition {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	defs := make([]EventDefinition, 0, len(catalog))
+	for _, def := range catalog {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// UserCreated is delivered for users.created, without any credential fields
+type UserCreated struct {
+	UserID     string    `json:"userId"`
+	TeamID     string    `json:"teamId"`
+	Email      string    `json:"email"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	Role       string    `json:"role"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+func (e UserCreated) EventTeamID() string { return e.TeamID }
+
+var exampleTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	exampleUserID = "6f1c2b1e-8d7a-4c3b-9e2f-1a2b3c4d5e6f"
+	exampleTeamID = "0b9d8c7e-6f5a-4b3c-8d2e-1f0a9b8c7d6e"
+)
+
+func init() {
+	Register("users.created", "A user joined the team, by registration, invite or Google sign-in",
+		UserCreated{
+			UserID: exampleUserID, TeamID: exampleTeamID, Email: "jane@example.com",
+			FirstName: "Jane", LastName: "Doe", Role: string(models.UserRoleMember), OccurredAt: exampleTime,
+		},
+		func(data interface{}) (events.TeamScoped, bool) {
+			user, ok := data.(*models.User)
+			if !ok {
+				return nil, false
+			}
+			return UserCreated{
+				UserID: user.ID, TeamID: user.TeamID, Email: user.Email,
+				FirstName: user.FirstName, LastName: user.LastName, Role: string(user.Role), OccurredAt: time.Now().UTC(),
+			}, true
+		},
+	)
+
+	setSample("users.created", &models.User{
+		Base: models.Base{ID: exampleUserID}, TeamID: exampleTeamID, Email: "jane@example.com",
+		FirstName: "Jane", LastName: "Doe", Role: models.UserRoleMember,
+	})
+
+	Register(events.EventTeamMemberRemoved, "A user was removed from the team",
+		events.MemberRemoved{
+			UserID: exampleUserID, TeamID: exampleTeamID, Email: "jane@example.com",
+			RemovedBy: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d", OccurredAt: exampleTime,
+		}, nil)
+
+	Register(events.EventTeamInviteSent, "An invitation to join the team was sent or resent",
+		events.InviteSent{
+			InviteID: "3c2b1a09-8f7e-4d6c-9b5a-4e3d2c1b0a9f", TeamID: exampleTeamID, Email: "jane@example.com",
+			Name: "Jane Doe", Role: string(models.UserRoleMember), InviterID: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			ExpiresAt: exampleTime.Add(models.InviteLifetime), OccurredAt: exampleTime,
+		}, nil)
+
+	Register(events.EventSecurityAlert, "Suspicious or sensitive account activity: new_device, account_recovery_requested, account_recovered or two_factor_disabled",
+		events.SecurityAlert{
+			Alert: events.AlertNewDevice, UserID: exampleUserID, TeamID: exampleTeamID, Email: "jane@example.com",
+			IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", OccurredAt: exampleTime,
+		}, nil)
+
+	Register(events.EventNewDeviceLogin, "A team member signed in on a device they never used before",
+		events.NewDeviceLogin{
+			UserID: exampleUserID, TeamID: exampleTeamID, Email: "jane@example.com", DeviceID: "b1946ac92492d2347c6235b4d2611184",
+			IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", Country: "DE", OccurredAt: exampleTime,
+		}, nil)
+
+	Register(events.EventLoginFailed, "A team member failed to sign in",
+		events.LoginFailed{
+			UserID: exampleUserID, TeamID: exampleTeamID, Email: "jane@example.com", Reason: "invalid_password",
+			IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", OccurredAt: exampleTime,
+		}, nil)
+}
@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/utils/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var log = logger.New("webhooks")
+
+// Envelope is the body POSTed to subscribers
+type Envelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	TeamID    string      `json:"teamId"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Start subscribes to every catalogue event on the bus and enqueues a delivery
+// for each active subscription of the team the event belongs to
+func Start(db *gorm.DB, client *tasks.TaskClient) {
+	for _, def := range Catalog() {
+		def := def
+		events.OnNamed(def.Name, "webhooks.dispatch", func(data interface{}) {
+			data, dryRun := events.Unwrap(data)
+			payload, ok := def.payload(data)
+			if !ok {
+				if dryRun != nil {
+					dryRun.Fail(fmt.Errorf("unexpected payload %T for event %s", data, def.Name))
+					return
+				}
+				log.Warn("Unexpected payload %T for event %s", data, def.Name)
+				return
+			}
+			if dryRun != nil {
+				log.Info("Dry run of %s for team %s, nothing enqueued", def.Name, payload.EventTeamID())
+				return
+			}
+			dispatch(db, client, def.Name, payload)
+		})
+	}
+}
+
+func dispatch(db *gorm.DB, client *tasks.TaskClient, event string, payload events.TeamScoped) {
+	// 🏢 A team only ever receives its own events
+	teamID := payload.EventTeamID()
+	if teamID == "" {
+		return
+	}
+
+	var subscriptions []models.WebhookSubscription
+	if err := db.Where("team_id = ? AND active = true AND is_deleted = false", teamID).Find(&subscriptions).Error; err != nil {
+		log.Error("Failed to load webhook subscriptions", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(event) {
+			continue
+		}
+
+		body, err := json.Marshal(Envelope{
+			ID:        uuid.New().String(),
+			Event:     event,
+			TeamID:    teamID,
+			CreatedAt: time.Now().UTC(),
+			Data:      payload,
+		})
+		if err != nil {
+			log.Error("Failed to encode webhook payload", err)
+			return
+		}
+
+		if err := client.EnqueueWebhookDelivery(context.Background(), subscription.ID, event, body); err != nil {
+			log.Error("Failed to enqueue webhook delivery", err)
+		}
+	}
+}
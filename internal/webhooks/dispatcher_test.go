@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"be0/internal/config"
+	"be0/internal/events"
+	"be0/internal/models"
+	"be0/internal/tasks"
+	"be0/internal/testutil"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/datatypes"
+)
+
+func TestDispatchOnlyReachesTheTeamsSubscriptions(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	_, server := testutil.NewRedis(t)
+	client := tasks.NewTaskClient(config.RedisConfig{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+
+	acme, globex := testutil.CreateTeam(t, gdb, "Acme"), testutil.CreateTeam(t, gdb, "Globex")
+	subscribe := func(teamID string, active bool, events ...string) *models.WebhookSubscription {
+		subscription := &models.WebhookSubscription{TeamID: teamID, URL: "https://hooks.example.com/" + teamID, Secret: "whsec", Events: datatypes.JSONSlice[string](events), Active: true}
+		if err := gdb.Create(subscription).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !active {
+			// Active defaults to true, a false value has to be written explicitly
+			if err := gdb.Model(subscription).Update("active", false).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return subscription
+	}
+	wanted := subscribe(acme.ID, true, "users.created", "auth.login_failed")
+	subscribe(acme.ID, true, "auth.login_failed")
+	subscribe(acme.ID, false, "users.created")
+	subscribe(globex.ID, true, "users.created")
+
+	def, ok := Lookup("users.created")
+	if !ok {
+		t.Fatal("users.created is not in the catalogue")
+	}
+	payload, ok := def.payload(&models.User{
+		Base: models.Base{ID: "6f1c2b1e-8d7a-4c3b-9e2f-1a2b3c4d5e6f"}, TeamID: acme.ID, Email: "grace@example.com",
+		Password: "bcrypt-hash", TwoFactorSecret: "sealed", FirstName: "Grace", Role: models.UserRoleMember,
+	})
+	if !ok {
+		t.Fatal("a user is not a users.created payload")
+	}
+	dispatch(gdb, client, def.Name, payload)
+
+	// 🏢 Only the active Acme subscription to the event gets a delivery
+	queued, err := inspector.ListPendingTasks(tasks.QueueDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("queued %d deliveries, want 1", len(queued))
+	}
+	var delivery tasks.WebhookDeliveryPayload
+	if err := json.Unmarshal(queued[0].Payload, &delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.SubscriptionID != wanted.ID || delivery.Event != "users.created" {
+		t.Errorf("delivery to %s of %s, want %s of users.created", delivery.SubscriptionID, delivery.Event, wanted.ID)
+	}
+
+	var envelope struct {
+		Envelope
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(delivery.Body, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.ID == "" || envelope.Event != "users.created" || envelope.TeamID != acme.ID || envelope.Data["email"] != "grace@example.com" {
+		t.Errorf("envelope %+v", envelope)
+	}
+	for _, secret := range []string{"bcrypt-hash", "sealed"} {
+		if strings.Contains(string(delivery.Body), secret) {
+			t.Errorf("the delivery carries %q", secret)
+		}
+	}
+}
+
+func TestDispatcherDryRunEnqueuesNothing(t *testing.T) {
+	gdb := testutil.NewDB(t)
+	_, server := testutil.NewRedis(t)
+	client := tasks.NewTaskClient(config.RedisConfig{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+	t.Cleanup(func() { inspector.Close() })
+
+	acme := testutil.CreateTeam(t, gdb, "Acme")
+	if err := gdb.Create(&models.WebhookSubscription{TeamID: acme.ID, URL: "https://hooks.example.com", Secret: "whsec", Events: datatypes.JSONSlice[string]{"users.created"}, Active: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	Start(gdb, client)
+
+	def, _ := Lookup("users.created")
+	sample, ok := def.Sample().(*models.User)
+	if !ok {
+		t.Fatalf("the users.created sample is a %T, want the user emitted on the bus", def.Sample())
+	}
+	user := *sample
+	user.TeamID = acme.ID
+
+	result, err := events.Test("users.created", "webhooks.dispatch", &user)
+	if err != nil || result.Error != "" {
+		t.Fatalf("dry run of the sample got %+v, %v", result, err)
+	}
+	if result, _ := events.Test("users.created", "webhooks.dispatch", map[string]interface{}{"email": "x"}); !strings.Contains(result.Error, "unexpected payload") {
+		t.Errorf("dry run of a wrong payload reported %q", result.Error)
+	}
+	if queued, _ := inspector.ListPendingTasks(tasks.QueueDefault); len(queued) != 0 {
+		t.Errorf("dry runs queued %d deliveries", len(queued))
+	}
+}